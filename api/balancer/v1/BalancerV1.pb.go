@@ -29,6 +29,8 @@ const (
 	RegisterInstanceRequest_READY     RegisterInstanceRequest_EventType = 1
 	RegisterInstanceRequest_NOT_READY RegisterInstanceRequest_EventType = 2
 	RegisterInstanceRequest_STOPPED   RegisterInstanceRequest_EventType = 3
+	// Инстанс не принимает новые задания, но еще проверяет выданные
+	RegisterInstanceRequest_DRAINING RegisterInstanceRequest_EventType = 4
 )
 
 // Enum value maps for RegisterInstanceRequest_EventType.
@@ -38,12 +40,14 @@ var (
 		1: "READY",
 		2: "NOT_READY",
 		3: "STOPPED",
+		4: "DRAINING",
 	}
 	RegisterInstanceRequest_EventType_value = map[string]int32{
 		"UNKNOWN":   0,
 		"READY":     1,
 		"NOT_READY": 2,
 		"STOPPED":   3,
+		"DRAINING":  4,
 	}
 )
 
@@ -71,7 +75,7 @@ func (x RegisterInstanceRequest_EventType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use RegisterInstanceRequest_EventType.Descriptor instead.
 func (RegisterInstanceRequest_EventType) EnumDescriptor() ([]byte, []int) {
-	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{0, 0}
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{4, 0}
 }
 
 type RegisterInstanceResponse_Status int32
@@ -117,7 +121,217 @@ func (x RegisterInstanceResponse_Status) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use RegisterInstanceResponse_Status.Descriptor instead.
 func (RegisterInstanceResponse_Status) EnumDescriptor() ([]byte, []int) {
-	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{1, 0}
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{6, 0}
+}
+
+type HandoffChallengesRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	InstanceId string                 `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	// Зашифрованное состояние заданий; балансер передает его, не расшифровывая
+	SealedState   []byte `protobuf:"bytes,2,opt,name=sealed_state,json=sealedState,proto3" json:"sealed_state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HandoffChallengesRequest) Reset() {
+	*x = HandoffChallengesRequest{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HandoffChallengesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HandoffChallengesRequest) ProtoMessage() {}
+
+func (x *HandoffChallengesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HandoffChallengesRequest.ProtoReflect.Descriptor instead.
+func (*HandoffChallengesRequest) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *HandoffChallengesRequest) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
+func (x *HandoffChallengesRequest) GetSealedState() []byte {
+	if x != nil {
+		return x.SealedState
+	}
+	return nil
+}
+
+type HandoffChallengesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Инстанс, принявший задания
+	InstanceId    string `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	Imported      int32  `protobuf:"varint,2,opt,name=imported,proto3" json:"imported,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HandoffChallengesResponse) Reset() {
+	*x = HandoffChallengesResponse{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HandoffChallengesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HandoffChallengesResponse) ProtoMessage() {}
+
+func (x *HandoffChallengesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HandoffChallengesResponse.ProtoReflect.Descriptor instead.
+func (*HandoffChallengesResponse) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HandoffChallengesResponse) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
+func (x *HandoffChallengesResponse) GetImported() int32 {
+	if x != nil {
+		return x.Imported
+	}
+	return 0
+}
+
+type LookupChallengeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupChallengeRequest) Reset() {
+	*x = LookupChallengeRequest{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupChallengeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupChallengeRequest) ProtoMessage() {}
+
+func (x *LookupChallengeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupChallengeRequest.ProtoReflect.Descriptor instead.
+func (*LookupChallengeRequest) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LookupChallengeRequest) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+type LookupChallengeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InstanceId    string                 `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	Host          string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	PortNumber    int32                  `protobuf:"varint,3,opt,name=port_number,json=portNumber,proto3" json:"port_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupChallengeResponse) Reset() {
+	*x = LookupChallengeResponse{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupChallengeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupChallengeResponse) ProtoMessage() {}
+
+func (x *LookupChallengeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupChallengeResponse.ProtoReflect.Descriptor instead.
+func (*LookupChallengeResponse) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LookupChallengeResponse) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
+func (x *LookupChallengeResponse) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *LookupChallengeResponse) GetPortNumber() int32 {
+	if x != nil {
+		return x.PortNumber
+	}
+	return 0
 }
 
 type RegisterInstanceRequest struct {
@@ -127,14 +341,32 @@ type RegisterInstanceRequest struct {
 	ChallengeType string                            `protobuf:"bytes,3,opt,name=challenge_type,json=challengeType,proto3" json:"challenge_type,omitempty"`
 	Host          string                            `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
 	PortNumber    int32                             `protobuf:"varint,5,opt,name=port_number,json=portNumber,proto3" json:"port_number,omitempty"`
-	Timestamp     int64                             `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Unix-время инстанса при отправке
+	Timestamp int64 `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Скользящая статистика генерации по корзинам сложности для автомасштабирования
+	ComplexityStats []*ComplexityStats `protobuf:"bytes,7,rep,name=complexity_stats,json=complexityStats,proto3" json:"complexity_stats,omitempty"`
+	// Выданные и еще не решенные задания — основная метрика нагрузки для HPA
+	PendingChallenges int32 `protobuf:"varint,8,opt,name=pending_challenges,json=pendingChallenges,proto3" json:"pending_challenges,omitempty"`
+	// Доступная мощность в процентах, когда инстанс сам себя ограничивает
+	// (например, под давлением памяти); 0 — полная мощность
+	CapacityPercent uint32 `protobuf:"varint,9,opt,name=capacity_percent,json=capacityPercent,proto3" json:"capacity_percent,omitempty"`
+	// Версия протокола инстанс–балансер (ProtocolVersion в version.go); 0 —
+	// инстанс, собранный до появления поля
+	ProtocolVersion uint32 `protobuf:"varint,10,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	// Последняя версия настроек тенантов (политики и квоты), примененная
+	// инстансом; по ней балансер оповещает остальные инстансы об изменении
+	SettingsVersion int64 `protobuf:"varint,11,opt,name=settings_version,json=settingsVersion,proto3" json:"settings_version,omitempty"`
+	// Регион и зона размещения инстанса: балансер выдает задания прежде всего
+	// инстансами своего региона и зоны. Пусто — размещение неизвестно.
+	Region        string `protobuf:"bytes,12,opt,name=region,proto3" json:"region,omitempty"`
+	Zone          string `protobuf:"bytes,13,opt,name=zone,proto3" json:"zone,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RegisterInstanceRequest) Reset() {
 	*x = RegisterInstanceRequest{}
-	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[0]
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -146,7 +378,7 @@ func (x *RegisterInstanceRequest) String() string {
 func (*RegisterInstanceRequest) ProtoMessage() {}
 
 func (x *RegisterInstanceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[0]
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -159,7 +391,7 @@ func (x *RegisterInstanceRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterInstanceRequest.ProtoReflect.Descriptor instead.
 func (*RegisterInstanceRequest) Descriptor() ([]byte, []int) {
-	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{0}
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *RegisterInstanceRequest) GetEventType() RegisterInstanceRequest_EventType {
@@ -204,17 +436,167 @@ func (x *RegisterInstanceRequest) GetTimestamp() int64 {
 	return 0
 }
 
+func (x *RegisterInstanceRequest) GetComplexityStats() []*ComplexityStats {
+	if x != nil {
+		return x.ComplexityStats
+	}
+	return nil
+}
+
+func (x *RegisterInstanceRequest) GetPendingChallenges() int32 {
+	if x != nil {
+		return x.PendingChallenges
+	}
+	return 0
+}
+
+func (x *RegisterInstanceRequest) GetCapacityPercent() uint32 {
+	if x != nil {
+		return x.CapacityPercent
+	}
+	return 0
+}
+
+func (x *RegisterInstanceRequest) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *RegisterInstanceRequest) GetSettingsVersion() int64 {
+	if x != nil {
+		return x.SettingsVersion
+	}
+	return 0
+}
+
+func (x *RegisterInstanceRequest) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *RegisterInstanceRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+// ComplexityStats — статистика генерации заданий одной корзины сложности
+// за последние window_seconds
+type ComplexityStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Границы корзины сложности, включительно
+	MinComplexity int32 `protobuf:"varint,1,opt,name=min_complexity,json=minComplexity,proto3" json:"min_complexity,omitempty"`
+	MaxComplexity int32 `protobuf:"varint,2,opt,name=max_complexity,json=maxComplexity,proto3" json:"max_complexity,omitempty"`
+	WindowSeconds int32 `protobuf:"varint,3,opt,name=window_seconds,json=windowSeconds,proto3" json:"window_seconds,omitempty"`
+	// Сколько заданий сгенерировано за окно
+	Generated       int64   `protobuf:"varint,4,opt,name=generated,proto3" json:"generated,omitempty"`
+	AvgGenerationMs float64 `protobuf:"fixed64,5,opt,name=avg_generation_ms,json=avgGenerationMs,proto3" json:"avg_generation_ms,omitempty"`
+	P95GenerationMs float64 `protobuf:"fixed64,6,opt,name=p95_generation_ms,json=p95GenerationMs,proto3" json:"p95_generation_ms,omitempty"`
+	// Заданий в очереди на отрисовку и в отрисовке прямо сейчас
+	QueueDepth    int32 `protobuf:"varint,7,opt,name=queue_depth,json=queueDepth,proto3" json:"queue_depth,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ComplexityStats) Reset() {
+	*x = ComplexityStats{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ComplexityStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComplexityStats) ProtoMessage() {}
+
+func (x *ComplexityStats) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComplexityStats.ProtoReflect.Descriptor instead.
+func (*ComplexityStats) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ComplexityStats) GetMinComplexity() int32 {
+	if x != nil {
+		return x.MinComplexity
+	}
+	return 0
+}
+
+func (x *ComplexityStats) GetMaxComplexity() int32 {
+	if x != nil {
+		return x.MaxComplexity
+	}
+	return 0
+}
+
+func (x *ComplexityStats) GetWindowSeconds() int32 {
+	if x != nil {
+		return x.WindowSeconds
+	}
+	return 0
+}
+
+func (x *ComplexityStats) GetGenerated() int64 {
+	if x != nil {
+		return x.Generated
+	}
+	return 0
+}
+
+func (x *ComplexityStats) GetAvgGenerationMs() float64 {
+	if x != nil {
+		return x.AvgGenerationMs
+	}
+	return 0
+}
+
+func (x *ComplexityStats) GetP95GenerationMs() float64 {
+	if x != nil {
+		return x.P95GenerationMs
+	}
+	return 0
+}
+
+func (x *ComplexityStats) GetQueueDepth() int32 {
+	if x != nil {
+		return x.QueueDepth
+	}
+	return 0
+}
+
 type RegisterInstanceResponse struct {
-	state         protoimpl.MessageState          `protogen:"open.v1"`
-	Status        RegisterInstanceResponse_Status `protobuf:"varint,1,opt,name=status,proto3,enum=balancer.v1.RegisterInstanceResponse_Status" json:"status,omitempty"`
-	Message       string                          `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	state   protoimpl.MessageState          `protogen:"open.v1"`
+	Status  RegisterInstanceResponse_Status `protobuf:"varint,1,opt,name=status,proto3,enum=balancer.v1.RegisterInstanceResponse_Status" json:"status,omitempty"`
+	Message string                          `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// Конфигурация, которую балансер пушит инстансу; отсутствует в обычных ack
+	Config *InstanceConfig `protobuf:"bytes,4,opt,name=config,proto3" json:"config,omitempty"`
+	// Unix-время балансера при отправке: по нему инстанс замечает расхождение часов
+	Timestamp     int64 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RegisterInstanceResponse) Reset() {
 	*x = RegisterInstanceResponse{}
-	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[1]
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -226,7 +608,7 @@ func (x *RegisterInstanceResponse) String() string {
 func (*RegisterInstanceResponse) ProtoMessage() {}
 
 func (x *RegisterInstanceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[1]
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -239,7 +621,7 @@ func (x *RegisterInstanceResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterInstanceResponse.ProtoReflect.Descriptor instead.
 func (*RegisterInstanceResponse) Descriptor() ([]byte, []int) {
-	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{1}
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *RegisterInstanceResponse) GetStatus() RegisterInstanceResponse_Status {
@@ -256,11 +638,343 @@ func (x *RegisterInstanceResponse) GetMessage() string {
 	return ""
 }
 
+func (x *RegisterInstanceResponse) GetConfig() *InstanceConfig {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *RegisterInstanceResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// InstanceConfig — runtime-настройки инстанса, задаваемые балансером.
+// Нулевые значения означают "оставить локальную настройку".
+type InstanceConfig struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Версия конфигурации; инстанс игнорирует версии не новее уже примененной
+	Version int64 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Если задано, заменяет сложность из запросов NewChallenge
+	TargetComplexity int32 `protobuf:"varint,2,opt,name=target_complexity,json=targetComplexity,proto3" json:"target_complexity,omitempty"`
+	// Типы заданий, которые инстанс не должен выдавать (slider-rotate, slider-multi, ...)
+	DisabledChallengeTypes []string           `protobuf:"bytes,3,rep,name=disabled_challenge_types,json=disabledChallengeTypes,proto3" json:"disabled_challenge_types,omitempty"`
+	RateLimit              *RateLimitOverride `protobuf:"bytes,4,opt,name=rate_limit,json=rateLimit,proto3" json:"rate_limit,omitempty"`
+	// Текущая ротация защиты от автоматизации; задается планировщиком балансера
+	Rotation *Rotation `protobuf:"bytes,5,opt,name=rotation,proto3" json:"rotation,omitempty"`
+	// Правила флагов функций (см. internal/flags); перекрывают файл и окружение
+	// инстанса. Конфигурация без флагов снимает прежние правила балансера.
+	FeatureFlags map[string]*FeatureFlag `protobuf:"bytes,6,rep,name=feature_flags,json=featureFlags,proto3" json:"feature_flags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Новейшая версия настроек тенантов во флоте: инстанс с более старой сразу
+	// перечитывает общую историю настроек, не дожидаясь истечения кэша
+	SettingsVersion int64 `protobuf:"varint,7,opt,name=settings_version,json=settingsVersion,proto3" json:"settings_version,omitempty"`
+	// Эпоха балансера — выбирается при его старте. Версии считаются заново с
+	// каждой эпохой, поэтому конфигурацию новой эпохи инстанс применяет при
+	// любой версии: иначе после перезапуска балансера инстансы игнорировали бы
+	// его конфигурацию, пока счетчик не догонит прежний.
+	Epoch         int64 `protobuf:"varint,8,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InstanceConfig) Reset() {
+	*x = InstanceConfig{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InstanceConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstanceConfig) ProtoMessage() {}
+
+func (x *InstanceConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstanceConfig.ProtoReflect.Descriptor instead.
+func (*InstanceConfig) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *InstanceConfig) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *InstanceConfig) GetTargetComplexity() int32 {
+	if x != nil {
+		return x.TargetComplexity
+	}
+	return 0
+}
+
+func (x *InstanceConfig) GetDisabledChallengeTypes() []string {
+	if x != nil {
+		return x.DisabledChallengeTypes
+	}
+	return nil
+}
+
+func (x *InstanceConfig) GetRateLimit() *RateLimitOverride {
+	if x != nil {
+		return x.RateLimit
+	}
+	return nil
+}
+
+func (x *InstanceConfig) GetRotation() *Rotation {
+	if x != nil {
+		return x.Rotation
+	}
+	return nil
+}
+
+func (x *InstanceConfig) GetFeatureFlags() map[string]*FeatureFlag {
+	if x != nil {
+		return x.FeatureFlags
+	}
+	return nil
+}
+
+func (x *InstanceConfig) GetSettingsVersion() int64 {
+	if x != nil {
+		return x.SettingsVersion
+	}
+	return 0
+}
+
+func (x *InstanceConfig) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+// FeatureFlag — правило флага: явное значение для сайтов, для остальных —
+// доля трафика в процентах
+type FeatureFlag struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Percent       int32                  `protobuf:"varint,1,opt,name=percent,proto3" json:"percent,omitempty"`
+	Sites         map[string]bool        `protobuf:"bytes,2,rep,name=sites,proto3" json:"sites,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FeatureFlag) Reset() {
+	*x = FeatureFlag{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FeatureFlag) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FeatureFlag) ProtoMessage() {}
+
+func (x *FeatureFlag) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FeatureFlag.ProtoReflect.Descriptor instead.
+func (*FeatureFlag) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FeatureFlag) GetPercent() int32 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *FeatureFlag) GetSites() map[string]bool {
+	if x != nil {
+		return x.Sites
+	}
+	return nil
+}
+
+// Rotation — параметры очередной ротации: инстансы выводят из seed параметры
+// обфускации и стратегию приманок, версии шаблонов переключаются явно
+type Rotation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Номер ротации; инстанс применяет ротацию один раз при смене epoch
+	Epoch int64 `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Seed  int64 `protobuf:"varint,2,opt,name=seed,proto3" json:"seed,omitempty"`
+	// Версии шаблонов виджетов по типам заданий (slider-puzzle -> v2)
+	TemplateVersions map[string]string `protobuf:"bytes,3,rep,name=template_versions,json=templateVersions,proto3" json:"template_versions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Unix-время начала ротации
+	StartedAt     int64 `protobuf:"varint,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rotation) Reset() {
+	*x = Rotation{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rotation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rotation) ProtoMessage() {}
+
+func (x *Rotation) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rotation.ProtoReflect.Descriptor instead.
+func (*Rotation) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Rotation) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Rotation) GetSeed() int64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+func (x *Rotation) GetTemplateVersions() map[string]string {
+	if x != nil {
+		return x.TemplateVersions
+	}
+	return nil
+}
+
+func (x *Rotation) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+// RateLimitOverride переопределяет лимиты event-стримов для новых подключений
+type RateLimitOverride struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	EventsPerSecond float64                `protobuf:"fixed64,1,opt,name=events_per_second,json=eventsPerSecond,proto3" json:"events_per_second,omitempty"`
+	Burst           int32                  `protobuf:"varint,2,opt,name=burst,proto3" json:"burst,omitempty"`
+	MaxInFlight     int32                  `protobuf:"varint,3,opt,name=max_in_flight,json=maxInFlight,proto3" json:"max_in_flight,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RateLimitOverride) Reset() {
+	*x = RateLimitOverride{}
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitOverride) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitOverride) ProtoMessage() {}
+
+func (x *RateLimitOverride) ProtoReflect() protoreflect.Message {
+	mi := &file_api_balancer_v1_BalancerV1_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitOverride.ProtoReflect.Descriptor instead.
+func (*RateLimitOverride) Descriptor() ([]byte, []int) {
+	return file_api_balancer_v1_BalancerV1_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RateLimitOverride) GetEventsPerSecond() float64 {
+	if x != nil {
+		return x.EventsPerSecond
+	}
+	return 0
+}
+
+func (x *RateLimitOverride) GetBurst() int32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+func (x *RateLimitOverride) GetMaxInFlight() int32 {
+	if x != nil {
+		return x.MaxInFlight
+	}
+	return 0
+}
+
 var File_api_balancer_v1_BalancerV1_proto protoreflect.FileDescriptor
 
 const file_api_balancer_v1_BalancerV1_proto_rawDesc = "" +
 	"\n" +
-	" api/balancer/v1/BalancerV1.proto\x12\vbalancer.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc4\x02\n" +
+	" api/balancer/v1/BalancerV1.proto\x12\vbalancer.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"^\n" +
+	"\x18HandoffChallengesRequest\x12\x1f\n" +
+	"\vinstance_id\x18\x01 \x01(\tR\n" +
+	"instanceId\x12!\n" +
+	"\fsealed_state\x18\x02 \x01(\fR\vsealedState\"X\n" +
+	"\x19HandoffChallengesResponse\x12\x1f\n" +
+	"\vinstance_id\x18\x01 \x01(\tR\n" +
+	"instanceId\x12\x1a\n" +
+	"\bimported\x18\x02 \x01(\x05R\bimported\";\n" +
+	"\x16LookupChallengeRequest\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\"o\n" +
+	"\x17LookupChallengeResponse\x12\x1f\n" +
+	"\vinstance_id\x18\x01 \x01(\tR\n" +
+	"instanceId\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x1f\n" +
+	"\vport_number\x18\x03 \x01(\x05R\n" +
+	"portNumber\"\xf7\x04\n" +
 	"\x17RegisterInstanceRequest\x12M\n" +
 	"\n" +
 	"event_type\x18\x01 \x01(\x0e2..balancer.v1.RegisterInstanceRequest.EventTypeR\teventType\x12\x1f\n" +
@@ -270,20 +984,75 @@ const file_api_balancer_v1_BalancerV1_proto_rawDesc = "" +
 	"\x04host\x18\x04 \x01(\tR\x04host\x12\x1f\n" +
 	"\vport_number\x18\x05 \x01(\x05R\n" +
 	"portNumber\x12\x1c\n" +
-	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\"?\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\x12G\n" +
+	"\x10complexity_stats\x18\a \x03(\v2\x1c.balancer.v1.ComplexityStatsR\x0fcomplexityStats\x12-\n" +
+	"\x12pending_challenges\x18\b \x01(\x05R\x11pendingChallenges\x12)\n" +
+	"\x10capacity_percent\x18\t \x01(\rR\x0fcapacityPercent\x12)\n" +
+	"\x10protocol_version\x18\n" +
+	" \x01(\rR\x0fprotocolVersion\x12)\n" +
+	"\x10settings_version\x18\v \x01(\x03R\x0fsettingsVersion\x12\x16\n" +
+	"\x06region\x18\f \x01(\tR\x06region\x12\x12\n" +
+	"\x04zone\x18\r \x01(\tR\x04zone\"M\n" +
 	"\tEventType\x12\v\n" +
 	"\aUNKNOWN\x10\x00\x12\t\n" +
 	"\x05READY\x10\x01\x12\r\n" +
 	"\tNOT_READY\x10\x02\x12\v\n" +
-	"\aSTOPPED\x10\x03\"\x9c\x01\n" +
+	"\aSTOPPED\x10\x03\x12\f\n" +
+	"\bDRAINING\x10\x04\"\x9d\x02\n" +
+	"\x0fComplexityStats\x12%\n" +
+	"\x0emin_complexity\x18\x01 \x01(\x05R\rminComplexity\x12%\n" +
+	"\x0emax_complexity\x18\x02 \x01(\x05R\rmaxComplexity\x12%\n" +
+	"\x0ewindow_seconds\x18\x03 \x01(\x05R\rwindowSeconds\x12\x1c\n" +
+	"\tgenerated\x18\x04 \x01(\x03R\tgenerated\x12*\n" +
+	"\x11avg_generation_ms\x18\x05 \x01(\x01R\x0favgGenerationMs\x12*\n" +
+	"\x11p95_generation_ms\x18\x06 \x01(\x01R\x0fp95GenerationMs\x12\x1f\n" +
+	"\vqueue_depth\x18\a \x01(\x05R\n" +
+	"queueDepth\"\xef\x01\n" +
 	"\x18RegisterInstanceResponse\x12D\n" +
 	"\x06status\x18\x01 \x01(\x0e2,.balancer.v1.RegisterInstanceResponse.StatusR\x06status\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\" \n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x123\n" +
+	"\x06config\x18\x04 \x01(\v2\x1b.balancer.v1.InstanceConfigR\x06config\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\" \n" +
 	"\x06Status\x12\v\n" +
 	"\aSUCCESS\x10\x00\x12\t\n" +
-	"\x05ERROR\x10\x012x\n" +
+	"\x05ERROR\x10\x01\"\xf3\x03\n" +
+	"\x0eInstanceConfig\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12+\n" +
+	"\x11target_complexity\x18\x02 \x01(\x05R\x10targetComplexity\x128\n" +
+	"\x18disabled_challenge_types\x18\x03 \x03(\tR\x16disabledChallengeTypes\x12=\n" +
+	"\n" +
+	"rate_limit\x18\x04 \x01(\v2\x1e.balancer.v1.RateLimitOverrideR\trateLimit\x121\n" +
+	"\brotation\x18\x05 \x01(\v2\x15.balancer.v1.RotationR\brotation\x12R\n" +
+	"\rfeature_flags\x18\x06 \x03(\v2-.balancer.v1.InstanceConfig.FeatureFlagsEntryR\ffeatureFlags\x12)\n" +
+	"\x10settings_version\x18\a \x01(\x03R\x0fsettingsVersion\x12\x14\n" +
+	"\x05epoch\x18\b \x01(\x03R\x05epoch\x1aY\n" +
+	"\x11FeatureFlagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12.\n" +
+	"\x05value\x18\x02 \x01(\v2\x18.balancer.v1.FeatureFlagR\x05value:\x028\x01\"\x9c\x01\n" +
+	"\vFeatureFlag\x12\x18\n" +
+	"\apercent\x18\x01 \x01(\x05R\apercent\x129\n" +
+	"\x05sites\x18\x02 \x03(\v2#.balancer.v1.FeatureFlag.SitesEntryR\x05sites\x1a8\n" +
+	"\n" +
+	"SitesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\bR\x05value:\x028\x01\"\xf2\x01\n" +
+	"\bRotation\x12\x14\n" +
+	"\x05epoch\x18\x01 \x01(\x03R\x05epoch\x12\x12\n" +
+	"\x04seed\x18\x02 \x01(\x03R\x04seed\x12X\n" +
+	"\x11template_versions\x18\x03 \x03(\v2+.balancer.v1.Rotation.TemplateVersionsEntryR\x10templateVersions\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x04 \x01(\x03R\tstartedAt\x1aC\n" +
+	"\x15TemplateVersionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"y\n" +
+	"\x11RateLimitOverride\x12*\n" +
+	"\x11events_per_second\x18\x01 \x01(\x01R\x0feventsPerSecond\x12\x14\n" +
+	"\x05burst\x18\x02 \x01(\x05R\x05burst\x12\"\n" +
+	"\rmax_in_flight\x18\x03 \x01(\x05R\vmaxInFlight2\xbe\x02\n" +
 	"\x0fBalancerService\x12e\n" +
-	"\x10RegisterInstance\x12$.balancer.v1.RegisterInstanceRequest\x1a%.balancer.v1.RegisterInstanceResponse\"\x00(\x010\x01B\x12Z\x10./pb/balancer/v1b\x06proto3"
+	"\x10RegisterInstance\x12$.balancer.v1.RegisterInstanceRequest\x1a%.balancer.v1.RegisterInstanceResponse\"\x00(\x010\x01\x12^\n" +
+	"\x0fLookupChallenge\x12#.balancer.v1.LookupChallengeRequest\x1a$.balancer.v1.LookupChallengeResponse\"\x00\x12d\n" +
+	"\x11HandoffChallenges\x12%.balancer.v1.HandoffChallengesRequest\x1a&.balancer.v1.HandoffChallengesResponse\"\x00B\x12Z\x10./pb/balancer/v1b\x06proto3"
 
 var (
 	file_api_balancer_v1_BalancerV1_proto_rawDescOnce sync.Once
@@ -298,23 +1067,47 @@ func file_api_balancer_v1_BalancerV1_proto_rawDescGZIP() []byte {
 }
 
 var file_api_balancer_v1_BalancerV1_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_api_balancer_v1_BalancerV1_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_api_balancer_v1_BalancerV1_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_api_balancer_v1_BalancerV1_proto_goTypes = []any{
 	(RegisterInstanceRequest_EventType)(0), // 0: balancer.v1.RegisterInstanceRequest.EventType
 	(RegisterInstanceResponse_Status)(0),   // 1: balancer.v1.RegisterInstanceResponse.Status
-	(*RegisterInstanceRequest)(nil),        // 2: balancer.v1.RegisterInstanceRequest
-	(*RegisterInstanceResponse)(nil),       // 3: balancer.v1.RegisterInstanceResponse
+	(*HandoffChallengesRequest)(nil),       // 2: balancer.v1.HandoffChallengesRequest
+	(*HandoffChallengesResponse)(nil),      // 3: balancer.v1.HandoffChallengesResponse
+	(*LookupChallengeRequest)(nil),         // 4: balancer.v1.LookupChallengeRequest
+	(*LookupChallengeResponse)(nil),        // 5: balancer.v1.LookupChallengeResponse
+	(*RegisterInstanceRequest)(nil),        // 6: balancer.v1.RegisterInstanceRequest
+	(*ComplexityStats)(nil),                // 7: balancer.v1.ComplexityStats
+	(*RegisterInstanceResponse)(nil),       // 8: balancer.v1.RegisterInstanceResponse
+	(*InstanceConfig)(nil),                 // 9: balancer.v1.InstanceConfig
+	(*FeatureFlag)(nil),                    // 10: balancer.v1.FeatureFlag
+	(*Rotation)(nil),                       // 11: balancer.v1.Rotation
+	(*RateLimitOverride)(nil),              // 12: balancer.v1.RateLimitOverride
+	nil,                                    // 13: balancer.v1.InstanceConfig.FeatureFlagsEntry
+	nil,                                    // 14: balancer.v1.FeatureFlag.SitesEntry
+	nil,                                    // 15: balancer.v1.Rotation.TemplateVersionsEntry
 }
 var file_api_balancer_v1_BalancerV1_proto_depIdxs = []int32{
-	0, // 0: balancer.v1.RegisterInstanceRequest.event_type:type_name -> balancer.v1.RegisterInstanceRequest.EventType
-	1, // 1: balancer.v1.RegisterInstanceResponse.status:type_name -> balancer.v1.RegisterInstanceResponse.Status
-	2, // 2: balancer.v1.BalancerService.RegisterInstance:input_type -> balancer.v1.RegisterInstanceRequest
-	3, // 3: balancer.v1.BalancerService.RegisterInstance:output_type -> balancer.v1.RegisterInstanceResponse
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: balancer.v1.RegisterInstanceRequest.event_type:type_name -> balancer.v1.RegisterInstanceRequest.EventType
+	7,  // 1: balancer.v1.RegisterInstanceRequest.complexity_stats:type_name -> balancer.v1.ComplexityStats
+	1,  // 2: balancer.v1.RegisterInstanceResponse.status:type_name -> balancer.v1.RegisterInstanceResponse.Status
+	9,  // 3: balancer.v1.RegisterInstanceResponse.config:type_name -> balancer.v1.InstanceConfig
+	12, // 4: balancer.v1.InstanceConfig.rate_limit:type_name -> balancer.v1.RateLimitOverride
+	11, // 5: balancer.v1.InstanceConfig.rotation:type_name -> balancer.v1.Rotation
+	13, // 6: balancer.v1.InstanceConfig.feature_flags:type_name -> balancer.v1.InstanceConfig.FeatureFlagsEntry
+	14, // 7: balancer.v1.FeatureFlag.sites:type_name -> balancer.v1.FeatureFlag.SitesEntry
+	15, // 8: balancer.v1.Rotation.template_versions:type_name -> balancer.v1.Rotation.TemplateVersionsEntry
+	10, // 9: balancer.v1.InstanceConfig.FeatureFlagsEntry.value:type_name -> balancer.v1.FeatureFlag
+	6,  // 10: balancer.v1.BalancerService.RegisterInstance:input_type -> balancer.v1.RegisterInstanceRequest
+	4,  // 11: balancer.v1.BalancerService.LookupChallenge:input_type -> balancer.v1.LookupChallengeRequest
+	2,  // 12: balancer.v1.BalancerService.HandoffChallenges:input_type -> balancer.v1.HandoffChallengesRequest
+	8,  // 13: balancer.v1.BalancerService.RegisterInstance:output_type -> balancer.v1.RegisterInstanceResponse
+	5,  // 14: balancer.v1.BalancerService.LookupChallenge:output_type -> balancer.v1.LookupChallengeResponse
+	3,  // 15: balancer.v1.BalancerService.HandoffChallenges:output_type -> balancer.v1.HandoffChallengesResponse
+	13, // [13:16] is the sub-list for method output_type
+	10, // [10:13] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_api_balancer_v1_BalancerV1_proto_init() }
@@ -328,7 +1121,7 @@ func file_api_balancer_v1_BalancerV1_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_balancer_v1_BalancerV1_proto_rawDesc), len(file_api_balancer_v1_BalancerV1_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   2,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
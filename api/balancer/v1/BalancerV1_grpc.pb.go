@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	BalancerService_RegisterInstance_FullMethodName = "/balancer.v1.BalancerService/RegisterInstance"
+	BalancerService_RegisterInstance_FullMethodName  = "/balancer.v1.BalancerService/RegisterInstance"
+	BalancerService_LookupChallenge_FullMethodName   = "/balancer.v1.BalancerService/LookupChallenge"
+	BalancerService_HandoffChallenges_FullMethodName = "/balancer.v1.BalancerService/HandoffChallenges"
 )
 
 // BalancerServiceClient is the client API for BalancerService service.
@@ -27,6 +29,12 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type BalancerServiceClient interface {
 	RegisterInstance(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[RegisterInstanceRequest, RegisterInstanceResponse], error)
+	// Какой инстанс выдал задание: инстанс, получивший решение чужого задания,
+	// пересылает проверку на него. NOT_FOUND — маршрут неизвестен или истек.
+	LookupChallenge(ctx context.Context, in *LookupChallengeRequest, opts ...grpc.CallOption) (*LookupChallengeResponse, error)
+	// Инстанс, уходящий на остановку, передает незавершенные задания: балансер
+	// отдает их READY-инстансу и перенаправляет на него маршруты проверок
+	HandoffChallenges(ctx context.Context, in *HandoffChallengesRequest, opts ...grpc.CallOption) (*HandoffChallengesResponse, error)
 }
 
 type balancerServiceClient struct {
@@ -50,11 +58,37 @@ func (c *balancerServiceClient) RegisterInstance(ctx context.Context, opts ...gr
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type BalancerService_RegisterInstanceClient = grpc.BidiStreamingClient[RegisterInstanceRequest, RegisterInstanceResponse]
 
+func (c *balancerServiceClient) LookupChallenge(ctx context.Context, in *LookupChallengeRequest, opts ...grpc.CallOption) (*LookupChallengeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupChallengeResponse)
+	err := c.cc.Invoke(ctx, BalancerService_LookupChallenge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balancerServiceClient) HandoffChallenges(ctx context.Context, in *HandoffChallengesRequest, opts ...grpc.CallOption) (*HandoffChallengesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HandoffChallengesResponse)
+	err := c.cc.Invoke(ctx, BalancerService_HandoffChallenges_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BalancerServiceServer is the server API for BalancerService service.
 // All implementations must embed UnimplementedBalancerServiceServer
 // for forward compatibility.
 type BalancerServiceServer interface {
 	RegisterInstance(grpc.BidiStreamingServer[RegisterInstanceRequest, RegisterInstanceResponse]) error
+	// Какой инстанс выдал задание: инстанс, получивший решение чужого задания,
+	// пересылает проверку на него. NOT_FOUND — маршрут неизвестен или истек.
+	LookupChallenge(context.Context, *LookupChallengeRequest) (*LookupChallengeResponse, error)
+	// Инстанс, уходящий на остановку, передает незавершенные задания: балансер
+	// отдает их READY-инстансу и перенаправляет на него маршруты проверок
+	HandoffChallenges(context.Context, *HandoffChallengesRequest) (*HandoffChallengesResponse, error)
 	mustEmbedUnimplementedBalancerServiceServer()
 }
 
@@ -68,6 +102,12 @@ type UnimplementedBalancerServiceServer struct{}
 func (UnimplementedBalancerServiceServer) RegisterInstance(grpc.BidiStreamingServer[RegisterInstanceRequest, RegisterInstanceResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method RegisterInstance not implemented")
 }
+func (UnimplementedBalancerServiceServer) LookupChallenge(context.Context, *LookupChallengeRequest) (*LookupChallengeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupChallenge not implemented")
+}
+func (UnimplementedBalancerServiceServer) HandoffChallenges(context.Context, *HandoffChallengesRequest) (*HandoffChallengesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HandoffChallenges not implemented")
+}
 func (UnimplementedBalancerServiceServer) mustEmbedUnimplementedBalancerServiceServer() {}
 func (UnimplementedBalancerServiceServer) testEmbeddedByValue()                         {}
 
@@ -96,13 +136,58 @@ func _BalancerService_RegisterInstance_Handler(srv interface{}, stream grpc.Serv
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type BalancerService_RegisterInstanceServer = grpc.BidiStreamingServer[RegisterInstanceRequest, RegisterInstanceResponse]
 
+func _BalancerService_LookupChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalancerServiceServer).LookupChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalancerService_LookupChallenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalancerServiceServer).LookupChallenge(ctx, req.(*LookupChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalancerService_HandoffChallenges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandoffChallengesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalancerServiceServer).HandoffChallenges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalancerService_HandoffChallenges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalancerServiceServer).HandoffChallenges(ctx, req.(*HandoffChallengesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BalancerService_ServiceDesc is the grpc.ServiceDesc for BalancerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var BalancerService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "balancer.v1.BalancerService",
 	HandlerType: (*BalancerServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "LookupChallenge",
+			Handler:    _BalancerService_LookupChallenge_Handler,
+		},
+		{
+			MethodName: "HandoffChallenges",
+			Handler:    _BalancerService_HandoffChallenges_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "RegisterInstance",
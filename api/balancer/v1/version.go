@@ -0,0 +1,7 @@
+package v1
+
+// ProtocolVersion — версия протокола между инстансами и балансером. Растет при
+// несовместимых изменениях BalancerV1.proto и CaptchaV1.proto (новое
+// обязательное поле, смена смысла поля или RPC, на который другая сторона
+// рассчитывает); по ней балансер не пускает во флот несовместимые инстансы.
+const ProtocolVersion = 1
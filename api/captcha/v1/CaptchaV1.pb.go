@@ -21,12 +21,123 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Грубый уровень риска вместо сложности: сервер сам переводит его в сложность
+// и допуски для своего типа задания
+type ChallengeRequest_RiskLevel int32
+
+const (
+	ChallengeRequest_UNSPECIFIED ChallengeRequest_RiskLevel = 0
+	ChallengeRequest_LOW         ChallengeRequest_RiskLevel = 1
+	ChallengeRequest_MEDIUM      ChallengeRequest_RiskLevel = 2
+	ChallengeRequest_HIGH        ChallengeRequest_RiskLevel = 3
+)
+
+// Enum value maps for ChallengeRequest_RiskLevel.
+var (
+	ChallengeRequest_RiskLevel_name = map[int32]string{
+		0: "UNSPECIFIED",
+		1: "LOW",
+		2: "MEDIUM",
+		3: "HIGH",
+	}
+	ChallengeRequest_RiskLevel_value = map[string]int32{
+		"UNSPECIFIED": 0,
+		"LOW":         1,
+		"MEDIUM":      2,
+		"HIGH":        3,
+	}
+)
+
+func (x ChallengeRequest_RiskLevel) Enum() *ChallengeRequest_RiskLevel {
+	p := new(ChallengeRequest_RiskLevel)
+	*p = x
+	return p
+}
+
+func (x ChallengeRequest_RiskLevel) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChallengeRequest_RiskLevel) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[0].Descriptor()
+}
+
+func (ChallengeRequest_RiskLevel) Type() protoreflect.EnumType {
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[0]
+}
+
+func (x ChallengeRequest_RiskLevel) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChallengeRequest_RiskLevel.Descriptor instead.
+func (ChallengeRequest_RiskLevel) EnumDescriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{0, 0}
+}
+
+// Как виджет получает картинки задания
+type ChallengeRequest_Delivery int32
+
+const (
+	// По политике сайта, иначе по настройке инстанса
+	ChallengeRequest_DELIVERY_DEFAULT ChallengeRequest_Delivery = 0
+	// Data URI внутри HTML: для встраиваний, которым запрещены лишние запросы
+	ChallengeRequest_DELIVERY_INLINE ChallengeRequest_Delivery = 1
+	// Ссылки на HTTP-сервер инстанса; без него картинки встраиваются
+	ChallengeRequest_DELIVERY_URL ChallengeRequest_Delivery = 2
+)
+
+// Enum value maps for ChallengeRequest_Delivery.
+var (
+	ChallengeRequest_Delivery_name = map[int32]string{
+		0: "DELIVERY_DEFAULT",
+		1: "DELIVERY_INLINE",
+		2: "DELIVERY_URL",
+	}
+	ChallengeRequest_Delivery_value = map[string]int32{
+		"DELIVERY_DEFAULT": 0,
+		"DELIVERY_INLINE":  1,
+		"DELIVERY_URL":     2,
+	}
+)
+
+func (x ChallengeRequest_Delivery) Enum() *ChallengeRequest_Delivery {
+	p := new(ChallengeRequest_Delivery)
+	*p = x
+	return p
+}
+
+func (x ChallengeRequest_Delivery) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChallengeRequest_Delivery) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[1].Descriptor()
+}
+
+func (ChallengeRequest_Delivery) Type() protoreflect.EnumType {
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[1]
+}
+
+func (x ChallengeRequest_Delivery) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChallengeRequest_Delivery.Descriptor instead.
+func (ChallengeRequest_Delivery) EnumDescriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{0, 1}
+}
+
 type ClientEvent_EventType int32
 
 const (
 	ClientEvent_FRONTEND_EVENT    ClientEvent_EventType = 0
 	ClientEvent_CONNECTION_CLOSED ClientEvent_EventType = 1
 	ClientEvent_BALANCER_EVENT    ClientEvent_EventType = 2
+	// Первое событие стрима: возможности виджета, сервер отвечает Negotiated
+	ClientEvent_HELLO ClientEvent_EventType = 3
+	// До первого касания пазла: размер, в котором виджет отрисовал задание
+	ClientEvent_CALIBRATION ClientEvent_EventType = 4
 )
 
 // Enum value maps for ClientEvent_EventType.
@@ -35,11 +146,15 @@ var (
 		0: "FRONTEND_EVENT",
 		1: "CONNECTION_CLOSED",
 		2: "BALANCER_EVENT",
+		3: "HELLO",
+		4: "CALIBRATION",
 	}
 	ClientEvent_EventType_value = map[string]int32{
 		"FRONTEND_EVENT":    0,
 		"CONNECTION_CLOSED": 1,
 		"BALANCER_EVENT":    2,
+		"HELLO":             3,
+		"CALIBRATION":       4,
 	}
 )
 
@@ -54,11 +169,11 @@ func (x ClientEvent_EventType) String() string {
 }
 
 func (ClientEvent_EventType) Descriptor() protoreflect.EnumDescriptor {
-	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[0].Descriptor()
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[2].Descriptor()
 }
 
 func (ClientEvent_EventType) Type() protoreflect.EnumType {
-	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[0]
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[2]
 }
 
 func (x ClientEvent_EventType) Number() protoreflect.EnumNumber {
@@ -67,12 +182,252 @@ func (x ClientEvent_EventType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ClientEvent_EventType.Descriptor instead.
 func (ClientEvent_EventType) EnumDescriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{2, 0}
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{6, 0}
+}
+
+// Почему решение не принято из-за привязки задания: оно выдано другому
+// сайту, сессии или сети. Задание при этом не расходуется.
+type ServerEvent_ChallengeResult_BindingFailure int32
+
+const (
+	ServerEvent_ChallengeResult_NONE               ServerEvent_ChallengeResult_BindingFailure = 0
+	ServerEvent_ChallengeResult_SITE_MISMATCH      ServerEvent_ChallengeResult_BindingFailure = 1
+	ServerEvent_ChallengeResult_SESSION_MISMATCH   ServerEvent_ChallengeResult_BindingFailure = 2
+	ServerEvent_ChallengeResult_IP_PREFIX_MISMATCH ServerEvent_ChallengeResult_BindingFailure = 3
+	// Ответ пришел не из региона инстанса или страны клиента, где задание
+	// выдано (geo_pin политики сайта)
+	ServerEvent_ChallengeResult_REGION_MISMATCH ServerEvent_ChallengeResult_BindingFailure = 4
+)
+
+// Enum value maps for ServerEvent_ChallengeResult_BindingFailure.
+var (
+	ServerEvent_ChallengeResult_BindingFailure_name = map[int32]string{
+		0: "NONE",
+		1: "SITE_MISMATCH",
+		2: "SESSION_MISMATCH",
+		3: "IP_PREFIX_MISMATCH",
+		4: "REGION_MISMATCH",
+	}
+	ServerEvent_ChallengeResult_BindingFailure_value = map[string]int32{
+		"NONE":               0,
+		"SITE_MISMATCH":      1,
+		"SESSION_MISMATCH":   2,
+		"IP_PREFIX_MISMATCH": 3,
+		"REGION_MISMATCH":    4,
+	}
+)
+
+func (x ServerEvent_ChallengeResult_BindingFailure) Enum() *ServerEvent_ChallengeResult_BindingFailure {
+	p := new(ServerEvent_ChallengeResult_BindingFailure)
+	*p = x
+	return p
+}
+
+func (x ServerEvent_ChallengeResult_BindingFailure) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerEvent_ChallengeResult_BindingFailure) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[3].Descriptor()
+}
+
+func (ServerEvent_ChallengeResult_BindingFailure) Type() protoreflect.EnumType {
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[3]
+}
+
+func (x ServerEvent_ChallengeResult_BindingFailure) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerEvent_ChallengeResult_BindingFailure.Descriptor instead.
+func (ServerEvent_ChallengeResult_BindingFailure) EnumDescriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 0, 0}
+}
+
+type ServerEvent_ControlMessage_Kind int32
+
+const (
+	ServerEvent_ControlMessage_UNKNOWN  ServerEvent_ControlMessage_Kind = 0
+	ServerEvent_ControlMessage_DRAINING ServerEvent_ControlMessage_Kind = 1
+	ServerEvent_ControlMessage_REFRESH  ServerEvent_ControlMessage_Kind = 2
+	// Месячная квота проверок тенанта исчерпана
+	ServerEvent_ControlMessage_QUOTA_EXCEEDED ServerEvent_ControlMessage_Kind = 4
+)
+
+// Enum value maps for ServerEvent_ControlMessage_Kind.
+var (
+	ServerEvent_ControlMessage_Kind_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "DRAINING",
+		2: "REFRESH",
+		4: "QUOTA_EXCEEDED",
+	}
+	ServerEvent_ControlMessage_Kind_value = map[string]int32{
+		"UNKNOWN":        0,
+		"DRAINING":       1,
+		"REFRESH":        2,
+		"QUOTA_EXCEEDED": 4,
+	}
+)
+
+func (x ServerEvent_ControlMessage_Kind) Enum() *ServerEvent_ControlMessage_Kind {
+	p := new(ServerEvent_ControlMessage_Kind)
+	*p = x
+	return p
+}
+
+func (x ServerEvent_ControlMessage_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerEvent_ControlMessage_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[4].Descriptor()
+}
+
+func (ServerEvent_ControlMessage_Kind) Type() protoreflect.EnumType {
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[4]
+}
+
+func (x ServerEvent_ControlMessage_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerEvent_ControlMessage_Kind.Descriptor instead.
+func (ServerEvent_ControlMessage_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 3, 0}
+}
+
+type AssessResponse_Decision int32
+
+const (
+	AssessResponse_UNKNOWN AssessResponse_Decision = 0
+	AssessResponse_ALLOW   AssessResponse_Decision = 1
+	// Уверенность ниже порога: показать пользователю новое задание
+	AssessResponse_CHALLENGE AssessResponse_Decision = 2
+	AssessResponse_DENY      AssessResponse_Decision = 3
+)
+
+// Enum value maps for AssessResponse_Decision.
+var (
+	AssessResponse_Decision_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "ALLOW",
+		2: "CHALLENGE",
+		3: "DENY",
+	}
+	AssessResponse_Decision_value = map[string]int32{
+		"UNKNOWN":   0,
+		"ALLOW":     1,
+		"CHALLENGE": 2,
+		"DENY":      3,
+	}
+)
+
+func (x AssessResponse_Decision) Enum() *AssessResponse_Decision {
+	p := new(AssessResponse_Decision)
+	*p = x
+	return p
+}
+
+func (x AssessResponse_Decision) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AssessResponse_Decision) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[5].Descriptor()
+}
+
+func (AssessResponse_Decision) Type() protoreflect.EnumType {
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[5]
+}
+
+func (x AssessResponse_Decision) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AssessResponse_Decision.Descriptor instead.
+func (AssessResponse_Decision) EnumDescriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{10, 0}
+}
+
+type ChallengeResultResponse_Status int32
+
+const (
+	ChallengeResultResponse_UNKNOWN ChallengeResultResponse_Status = 0
+	// Задание выдано, решение еще не проверено
+	ChallengeResultResponse_PENDING ChallengeResultResponse_Status = 1
+	ChallengeResultResponse_SOLVED  ChallengeResultResponse_Status = 2
+	ChallengeResultResponse_FAILED  ChallengeResultResponse_Status = 3
+	// Задание не найдено: истекло, не существует или итог уже не хранится
+	ChallengeResultResponse_NOT_FOUND ChallengeResultResponse_Status = 4
+)
+
+// Enum value maps for ChallengeResultResponse_Status.
+var (
+	ChallengeResultResponse_Status_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "PENDING",
+		2: "SOLVED",
+		3: "FAILED",
+		4: "NOT_FOUND",
+	}
+	ChallengeResultResponse_Status_value = map[string]int32{
+		"UNKNOWN":   0,
+		"PENDING":   1,
+		"SOLVED":    2,
+		"FAILED":    3,
+		"NOT_FOUND": 4,
+	}
+)
+
+func (x ChallengeResultResponse_Status) Enum() *ChallengeResultResponse_Status {
+	p := new(ChallengeResultResponse_Status)
+	*p = x
+	return p
+}
+
+func (x ChallengeResultResponse_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChallengeResultResponse_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_captcha_v1_CaptchaV1_proto_enumTypes[6].Descriptor()
+}
+
+func (ChallengeResultResponse_Status) Type() protoreflect.EnumType {
+	return &file_api_captcha_v1_CaptchaV1_proto_enumTypes[6]
+}
+
+func (x ChallengeResultResponse_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChallengeResultResponse_Status.Descriptor instead.
+func (ChallengeResultResponse_Status) EnumDescriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{14, 0}
 }
 
 type ChallengeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Complexity    int32                  `protobuf:"varint,1,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Сложность 1–100; если задана, risk_level не учитывается
+	Complexity int32 `protobuf:"varint,1,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	// Ключ сайта (тенанта): по нему считаются квоты и биллинг
+	SiteKey string `protobuf:"bytes,2,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
+	// Действие на стороне сайта ("login", "signup", "checkout"): по нему
+	// выбирается политика сложности, типа задания и порога уверенности
+	Action string `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	// Аттестация платформы клиента (Private Access Token, Play Integrity и т.п.):
+	// если ее подтвердил верификатор провайдера, визуальное задание не выдается
+	Attestation *Attestation `protobuf:"bytes,4,opt,name=attestation,proto3" json:"attestation,omitempty"`
+	// Сведения о клиенте, которые relying party передает от браузера:
+	// по ним считается риск в невидимом режиме
+	Client *ClientContext `protobuf:"bytes,5,opt,name=client,proto3" json:"client,omitempty"`
+	// Локаль клиента ("ru", "pt-BR"), например из Accept-Language: по ней выбираются
+	// фон и шаблон виджета, если политика сайта не закрепила свою локаль
+	Locale    string                     `protobuf:"bytes,6,opt,name=locale,proto3" json:"locale,omitempty"`
+	RiskLevel ChallengeRequest_RiskLevel `protobuf:"varint,7,opt,name=risk_level,json=riskLevel,proto3,enum=captcha.v1.ChallengeRequest_RiskLevel" json:"risk_level,omitempty"`
+	// Способ доставки картинок; перекрывает политику сайта
+	Delivery      ChallengeRequest_Delivery `protobuf:"varint,8,opt,name=delivery,proto3,enum=captcha.v1.ChallengeRequest_Delivery" json:"delivery,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -114,82 +469,1395 @@ func (x *ChallengeRequest) GetComplexity() int32 {
 	return 0
 }
 
-type ChallengeResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
-	Html          string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+func (x *ChallengeRequest) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
+
+func (x *ChallengeRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ChallengeRequest) GetAttestation() *Attestation {
+	if x != nil {
+		return x.Attestation
+	}
+	return nil
+}
+
+func (x *ChallengeRequest) GetClient() *ClientContext {
+	if x != nil {
+		return x.Client
+	}
+	return nil
+}
+
+func (x *ChallengeRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *ChallengeRequest) GetRiskLevel() ChallengeRequest_RiskLevel {
+	if x != nil {
+		return x.RiskLevel
+	}
+	return ChallengeRequest_UNSPECIFIED
+}
+
+func (x *ChallengeRequest) GetDelivery() ChallengeRequest_Delivery {
+	if x != nil {
+		return x.Delivery
+	}
+	return ChallengeRequest_DELIVERY_DEFAULT
+}
+
+type ClientContext struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// IP клиента; пустой — берется адрес gRPC-соединения
+	Ip        string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	UserAgent string `protobuf:"bytes,2,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	// Отпечаток устройства из виджета (см. ClientEvent.fingerprint), если он
+	// уже известен: по нему, как и по IP, ограничивается частота выдачи заданий
+	Fingerprint string `protobuf:"bytes,3,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// Возможности виджета: по ним выбираются схема ответа и обфускация задания
+	Capabilities *WidgetCapabilities `protobuf:"bytes,4,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// Хост страницы, на которой показан виджет: попадает в результат проверки,
+	// и бэкенд сайта сверяет его со своими хостами
+	Hostname string `protobuf:"bytes,5,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// Идентификатор сессии пользователя на сайте: задание привязывается к нему,
+	// и решение принимается только с тем же session_id (хранится хэш)
+	SessionId string `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Страна клиента (ISO 3166-1 alpha-2) по геолокации сайта или CDN: с
+	// geo_pin=country в политике решение принимается только из той же страны
+	Country       string `protobuf:"bytes,7,opt,name=country,proto3" json:"country,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ChallengeResponse) Reset() {
-	*x = ChallengeResponse{}
+func (x *ClientContext) Reset() {
+	*x = ClientContext{}
 	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ChallengeResponse) String() string {
+func (x *ClientContext) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ChallengeResponse) ProtoMessage() {}
+func (*ClientContext) ProtoMessage() {}
+
+func (x *ClientContext) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientContext.ProtoReflect.Descriptor instead.
+func (*ClientContext) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClientContext) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *ClientContext) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *ClientContext) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *ClientContext) GetCapabilities() *WidgetCapabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *ClientContext) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *ClientContext) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ClientContext) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+// WidgetCapabilities — что умеет виджет (загрузчик на странице сайта). Старые
+// закэшированные виджеты их не передают и получают схему ответа 1 и полную обфускацию.
+type WidgetCapabilities struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WidgetVersion uint32                 `protobuf:"varint,1,opt,name=widget_version,json=widgetVersion,proto3" json:"widget_version,omitempty"`
+	// Сенсорный ввод: приманки-слайдеры мешают касаниям и не добавляются
+	Touch            bool   `protobuf:"varint,2,opt,name=touch,proto3" json:"touch,omitempty"`
+	TelemetryVersion uint32 `protobuf:"varint,3,opt,name=telemetry_version,json=telemetryVersion,proto3" json:"telemetry_version,omitempty"`
+	// WebCrypto доступен: виджет может посчитать отпечаток
+	Crypto bool `protobuf:"varint,4,opt,name=crypto,proto3" json:"crypto,omitempty"`
+	// Схемы ответа, которые виджет умеет передавать (см. internal/answer/schema.go)
+	AnswerSchemas []uint32 `protobuf:"varint,5,rep,packed,name=answer_schemas,json=answerSchemas,proto3" json:"answer_schemas,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WidgetCapabilities) Reset() {
+	*x = WidgetCapabilities{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WidgetCapabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WidgetCapabilities) ProtoMessage() {}
+
+func (x *WidgetCapabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WidgetCapabilities.ProtoReflect.Descriptor instead.
+func (*WidgetCapabilities) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WidgetCapabilities) GetWidgetVersion() uint32 {
+	if x != nil {
+		return x.WidgetVersion
+	}
+	return 0
+}
+
+func (x *WidgetCapabilities) GetTouch() bool {
+	if x != nil {
+		return x.Touch
+	}
+	return false
+}
+
+func (x *WidgetCapabilities) GetTelemetryVersion() uint32 {
+	if x != nil {
+		return x.TelemetryVersion
+	}
+	return 0
+}
+
+func (x *WidgetCapabilities) GetCrypto() bool {
+	if x != nil {
+		return x.Crypto
+	}
+	return false
+}
+
+func (x *WidgetCapabilities) GetAnswerSchemas() []uint32 {
+	if x != nil {
+		return x.AnswerSchemas
+	}
+	return nil
+}
+
+// Attestation — токен аттестации платформы в формате провайдера
+type Attestation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Имя провайдера, под которым на инстансе настроен верификатор ("apple-pat", "play-integrity")
+	Provider      string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Token         []byte `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Attestation) Reset() {
+	*x = Attestation{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attestation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attestation) ProtoMessage() {}
+
+func (x *Attestation) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attestation.ProtoReflect.Descriptor instead.
+func (*Attestation) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Attestation) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Attestation) GetToken() []byte {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
+
+type ChallengeHandle struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	// Время (unix), до которого нужно забрать задание через GetChallenge
+	ExpiresAt     int64 `protobuf:"varint,2,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeHandle) Reset() {
+	*x = ChallengeHandle{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeHandle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeHandle) ProtoMessage() {}
+
+func (x *ChallengeHandle) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeHandle.ProtoReflect.Descriptor instead.
+func (*ChallengeHandle) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ChallengeHandle) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ChallengeHandle) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type ChallengeResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Html        string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+	// attested — аттестация клиента принята: html пуст, а token сразу передается
+	// в Assess, как токен из ChallengeResult решенного задания
+	Attested bool   `protobuf:"varint,3,opt,name=attested,proto3" json:"attested,omitempty"`
+	Token    string `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`
+	// invisible_pass — сайт в невидимом режиме и оценка риска клиента не ниже
+	// порога: html пуст, token выдан с уверенностью risk_score
+	InvisiblePass bool  `protobuf:"varint,5,opt,name=invisible_pass,json=invisiblePass,proto3" json:"invisible_pass,omitempty"`
+	RiskScore     int32 `protobuf:"varint,6,opt,name=risk_score,json=riskScore,proto3" json:"risk_score,omitempty"`
+	// allowlisted — IP клиента в allowlist: html пуст, token выдан без задания
+	Allowlisted bool `protobuf:"varint,7,opt,name=allowlisted,proto3" json:"allowlisted,omitempty"`
+	// Время (unix), после которого ответ на задание не принимается; виджет
+	// показывает отсчет и просит новое задание незадолго до него (captcha:refresh)
+	ExpiresAt int64 `protobuf:"varint,8,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// Подписанный результат для офлайн-проверки по JWKS (см. ChallengeResult.jwt)
+	Jwt string `protobuf:"bytes,9,opt,name=jwt,proto3" json:"jwt,omitempty"`
+	// Открытый ключ задания (несжатая точка P-256), если выбрана схема ответа 3:
+	// нативный виджет шифрует им ответ так же, как HTML-виджет (internal/answer/seal.go)
+	AnswerKey     []byte `protobuf:"bytes,10,opt,name=answer_key,json=answerKey,proto3" json:"answer_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeResponse) Reset() {
+	*x = ChallengeResponse{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeResponse) ProtoMessage() {}
+
+func (x *ChallengeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeResponse.ProtoReflect.Descriptor instead.
+func (*ChallengeResponse) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChallengeResponse) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ChallengeResponse) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
+func (x *ChallengeResponse) GetAttested() bool {
+	if x != nil {
+		return x.Attested
+	}
+	return false
+}
+
+func (x *ChallengeResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ChallengeResponse) GetInvisiblePass() bool {
+	if x != nil {
+		return x.InvisiblePass
+	}
+	return false
+}
+
+func (x *ChallengeResponse) GetRiskScore() int32 {
+	if x != nil {
+		return x.RiskScore
+	}
+	return 0
+}
+
+func (x *ChallengeResponse) GetAllowlisted() bool {
+	if x != nil {
+		return x.Allowlisted
+	}
+	return false
+}
+
+func (x *ChallengeResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+func (x *ChallengeResponse) GetJwt() string {
+	if x != nil {
+		return x.Jwt
+	}
+	return ""
+}
+
+func (x *ChallengeResponse) GetAnswerKey() []byte {
+	if x != nil {
+		return x.AnswerKey
+	}
+	return nil
+}
+
+type ClientEvent struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	EventType   ClientEvent_EventType  `protobuf:"varint,1,opt,name=event_type,json=eventType,proto3,enum=captcha.v1.ClientEvent_EventType" json:"event_type,omitempty"`
+	ChallengeId string                 `protobuf:"bytes,2,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	// Для FRONTEND_EVENT пазла-слайдера: ASCII-число, X левого края пазла
+	// в пикселях исходного изображения, допускается дробная часть ("137.5").
+	// Сервер округляет X до ближайшего узла сетки шага слайдера (половина — от нуля)
+	// и сравнивает с допуском, увеличенным на половину шага.
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// Для FRONTEND_EVENT: грубый отпечаток устройства, SHA-256 в hex, посчитанный
+	// виджетом. Сервер хранит только его усеченный хэш на ротируемом ключе и
+	// использует его лишь для лимита частоты проверок.
+	Fingerprint string `protobuf:"bytes,4,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// Для HELLO: возможности виджета
+	Capabilities *WidgetCapabilities `protobuf:"bytes,5,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// Для FRONTEND_EVENT: чем relying party подтверждает привязку задания —
+	// сайт, сессия пользователя и IP клиента (пустой — адрес gRPC-соединения)
+	SiteKey   string `protobuf:"bytes,6,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
+	SessionId string `protobuf:"bytes,7,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	ClientIp  string `protobuf:"bytes,8,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	// Для CALIBRATION: отрисованный размер картинки задания. После калибровки
+	// виджет может присылать координаты в физических пикселях экрана с
+	// префиксом "dp=" (см. пакет answer), и сервер переводит их в пиксели
+	// исходного изображения по сохраненному для задания масштабу.
+	Calibration *Calibration `protobuf:"bytes,9,opt,name=calibration,proto3" json:"calibration,omitempty"`
+	// Для FRONTEND_EVENT: страна клиента при отправке ответа (см. ClientContext.country)
+	Country       string `protobuf:"bytes,10,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientEvent) Reset() {
+	*x = ClientEvent{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientEvent) ProtoMessage() {}
+
+func (x *ClientEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientEvent.ProtoReflect.Descriptor instead.
+func (*ClientEvent) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ClientEvent) GetEventType() ClientEvent_EventType {
+	if x != nil {
+		return x.EventType
+	}
+	return ClientEvent_FRONTEND_EVENT
+}
+
+func (x *ClientEvent) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ClientEvent) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ClientEvent) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *ClientEvent) GetCapabilities() *WidgetCapabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *ClientEvent) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
+
+func (x *ClientEvent) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ClientEvent) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *ClientEvent) GetCalibration() *Calibration {
+	if x != nil {
+		return x.Calibration
+	}
+	return nil
+}
+
+func (x *ClientEvent) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+// Calibration — размер картинки задания на экране клиента
+type Calibration struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ширина и высота фона в CSS-пикселях (getBoundingClientRect)
+	RenderedWidth  float64 `protobuf:"fixed64,1,opt,name=rendered_width,json=renderedWidth,proto3" json:"rendered_width,omitempty"`
+	RenderedHeight float64 `protobuf:"fixed64,2,opt,name=rendered_height,json=renderedHeight,proto3" json:"rendered_height,omitempty"`
+	// window.devicePixelRatio: физических пикселей на CSS-пиксель
+	DevicePixelRatio float64 `protobuf:"fixed64,3,opt,name=device_pixel_ratio,json=devicePixelRatio,proto3" json:"device_pixel_ratio,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Calibration) Reset() {
+	*x = Calibration{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Calibration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Calibration) ProtoMessage() {}
+
+func (x *Calibration) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Calibration.ProtoReflect.Descriptor instead.
+func (*Calibration) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Calibration) GetRenderedWidth() float64 {
+	if x != nil {
+		return x.RenderedWidth
+	}
+	return 0
+}
+
+func (x *Calibration) GetRenderedHeight() float64 {
+	if x != nil {
+		return x.RenderedHeight
+	}
+	return 0
+}
+
+func (x *Calibration) GetDevicePixelRatio() float64 {
+	if x != nil {
+		return x.DevicePixelRatio
+	}
+	return 0
+}
+
+type ServerEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ServerEvent_Result
+	//	*ServerEvent_ClientJs
+	//	*ServerEvent_ClientData
+	//	*ServerEvent_Control
+	//	*ServerEvent_Negotiated_
+	Event         isServerEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent) Reset() {
+	*x = ServerEvent{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent) ProtoMessage() {}
+
+func (x *ServerEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent.ProtoReflect.Descriptor instead.
+func (*ServerEvent) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ServerEvent) GetEvent() isServerEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetResult() *ServerEvent_ChallengeResult {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetClientJs() *ServerEvent_RunClientJS {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_ClientJs); ok {
+			return x.ClientJs
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetClientData() *ServerEvent_SendClientData {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_ClientData); ok {
+			return x.ClientData
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetControl() *ServerEvent_ControlMessage {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Control); ok {
+			return x.Control
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetNegotiated() *ServerEvent_Negotiated {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Negotiated_); ok {
+			return x.Negotiated
+		}
+	}
+	return nil
+}
+
+type isServerEvent_Event interface {
+	isServerEvent_Event()
+}
+
+type ServerEvent_Result struct {
+	Result *ServerEvent_ChallengeResult `protobuf:"bytes,1,opt,name=result,proto3,oneof"`
+}
+
+type ServerEvent_ClientJs struct {
+	ClientJs *ServerEvent_RunClientJS `protobuf:"bytes,2,opt,name=client_js,json=clientJs,proto3,oneof"`
+}
+
+type ServerEvent_ClientData struct {
+	ClientData *ServerEvent_SendClientData `protobuf:"bytes,3,opt,name=client_data,json=clientData,proto3,oneof"`
+}
+
+type ServerEvent_Control struct {
+	Control *ServerEvent_ControlMessage `protobuf:"bytes,4,opt,name=control,proto3,oneof"`
+}
+
+type ServerEvent_Negotiated_ struct {
+	Negotiated *ServerEvent_Negotiated `protobuf:"bytes,5,opt,name=negotiated,proto3,oneof"`
+}
+
+func (*ServerEvent_Result) isServerEvent_Event() {}
+
+func (*ServerEvent_ClientJs) isServerEvent_Event() {}
+
+func (*ServerEvent_ClientData) isServerEvent_Event() {}
+
+func (*ServerEvent_Control) isServerEvent_Event() {}
+
+func (*ServerEvent_Negotiated_) isServerEvent_Event() {}
+
+type AssessRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Token string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	// Действие, которое ожидает бэкенд; должно совпасть с действием задания
+	Action string `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	// Если задан, токен должен принадлежать этому сайту
+	SiteKey       string `protobuf:"bytes,3,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AssessRequest) Reset() {
+	*x = AssessRequest{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssessRequest) ProtoMessage() {}
+
+func (x *AssessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssessRequest.ProtoReflect.Descriptor instead.
+func (*AssessRequest) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AssessRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *AssessRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *AssessRequest) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
+
+type AssessResponse struct {
+	state             protoimpl.MessageState  `protogen:"open.v1"`
+	Decision          AssessResponse_Decision `protobuf:"varint,1,opt,name=decision,proto3,enum=captcha.v1.AssessResponse_Decision" json:"decision,omitempty"`
+	ConfidencePercent int32                   `protobuf:"varint,2,opt,name=confidence_percent,json=confidencePercent,proto3" json:"confidence_percent,omitempty"`
+	Threshold         int32                   `protobuf:"varint,3,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	Action            string                  `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
+	// Причина решения для логов бэкенда
+	Reason string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Хост страницы из ClientContext задания
+	Hostname string `protobuf:"bytes,6,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// Регион инстанса, проверившего решение: для анализа задержек по регионам
+	Region        string `protobuf:"bytes,7,opt,name=region,proto3" json:"region,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AssessResponse) Reset() {
+	*x = AssessResponse{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssessResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssessResponse) ProtoMessage() {}
+
+func (x *AssessResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssessResponse.ProtoReflect.Descriptor instead.
+func (*AssessResponse) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AssessResponse) GetDecision() AssessResponse_Decision {
+	if x != nil {
+		return x.Decision
+	}
+	return AssessResponse_UNKNOWN
+}
+
+func (x *AssessResponse) GetConfidencePercent() int32 {
+	if x != nil {
+		return x.ConfidencePercent
+	}
+	return 0
+}
+
+func (x *AssessResponse) GetThreshold() int32 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+func (x *AssessResponse) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *AssessResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *AssessResponse) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *AssessResponse) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+type ChallengeAssetsRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	// Смещения для докачки: имя ассета -> сколько байт уже получено.
+	// Ассеты, полученные целиком, передаются со смещением, равным размеру.
+	Offsets map[string]int64 `protobuf:"bytes,2,rep,name=offsets,proto3" json:"offsets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Размер части в байтах; 0 — значение сервера по умолчанию
+	ChunkSize     int32 `protobuf:"varint,3,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeAssetsRequest) Reset() {
+	*x = ChallengeAssetsRequest{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeAssetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeAssetsRequest) ProtoMessage() {}
+
+func (x *ChallengeAssetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeAssetsRequest.ProtoReflect.Descriptor instead.
+func (*ChallengeAssetsRequest) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ChallengeAssetsRequest) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ChallengeAssetsRequest) GetOffsets() map[string]int64 {
+	if x != nil {
+		return x.Offsets
+	}
+	return nil
+}
+
+func (x *ChallengeAssetsRequest) GetChunkSize() int32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+type AssetChunk struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Name      string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	MimeType  string                 `protobuf:"bytes,2,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	TotalSize int64                  `protobuf:"varint,3,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	// Смещение data внутри ассета
+	Offset int64  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	Data   []byte `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	// Последняя часть ассета
+	Last          bool `protobuf:"varint,6,opt,name=last,proto3" json:"last,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AssetChunk) Reset() {
+	*x = AssetChunk{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssetChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssetChunk) ProtoMessage() {}
+
+func (x *AssetChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssetChunk.ProtoReflect.Descriptor instead.
+func (*AssetChunk) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AssetChunk) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AssetChunk) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *AssetChunk) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *AssetChunk) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *AssetChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *AssetChunk) GetLast() bool {
+	if x != nil {
+		return x.Last
+	}
+	return false
+}
+
+type ChallengeResultRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	// Если задан, задание должно принадлежать этому сайту
+	SiteKey       string `protobuf:"bytes,2,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeResultRequest) Reset() {
+	*x = ChallengeResultRequest{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeResultRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeResultRequest) ProtoMessage() {}
+
+func (x *ChallengeResultRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeResultRequest.ProtoReflect.Descriptor instead.
+func (*ChallengeResultRequest) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ChallengeResultRequest) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ChallengeResultRequest) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
+
+type ChallengeResultResponse struct {
+	state             protoimpl.MessageState         `protogen:"open.v1"`
+	ChallengeId       string                         `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Status            ChallengeResultResponse_Status `protobuf:"varint,2,opt,name=status,proto3,enum=captcha.v1.ChallengeResultResponse_Status" json:"status,omitempty"`
+	ConfidencePercent int32                          `protobuf:"varint,3,opt,name=confidence_percent,json=confidencePercent,proto3" json:"confidence_percent,omitempty"`
+	Action            string                         `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
+	// Время проверки решения (unix)
+	VerifiedAt int64 `protobuf:"varint,5,opt,name=verified_at,json=verifiedAt,proto3" json:"verified_at,omitempty"`
+	// Вердикт по действующему порогу политики, как ChallengeResult.passed
+	Passed        bool  `protobuf:"varint,6,opt,name=passed,proto3" json:"passed,omitempty"`
+	Threshold     int32 `protobuf:"varint,7,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeResultResponse) Reset() {
+	*x = ChallengeResultResponse{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeResultResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeResultResponse) ProtoMessage() {}
+
+func (x *ChallengeResultResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeResultResponse.ProtoReflect.Descriptor instead.
+func (*ChallengeResultResponse) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ChallengeResultResponse) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ChallengeResultResponse) GetStatus() ChallengeResultResponse_Status {
+	if x != nil {
+		return x.Status
+	}
+	return ChallengeResultResponse_UNKNOWN
+}
+
+func (x *ChallengeResultResponse) GetConfidencePercent() int32 {
+	if x != nil {
+		return x.ConfidencePercent
+	}
+	return 0
+}
+
+func (x *ChallengeResultResponse) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ChallengeResultResponse) GetVerifiedAt() int64 {
+	if x != nil {
+		return x.VerifiedAt
+	}
+	return 0
+}
+
+func (x *ChallengeResultResponse) GetPassed() bool {
+	if x != nil {
+		return x.Passed
+	}
+	return false
+}
+
+func (x *ChallengeResultResponse) GetThreshold() int32 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+// ForwardSolutionRequest — решение из стрима другого инстанса
+type ForwardSolutionRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Data        []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Fingerprint string                 `protobuf:"bytes,3,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// Инстанс, получивший решение от виджета
+	FromInstance string `protobuf:"bytes,4,opt,name=from_instance,json=fromInstance,proto3" json:"from_instance,omitempty"`
+	// Привязка из ClientEvent; client_ip уже разрешен получившим инстансом
+	SiteKey   string `protobuf:"bytes,5,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
+	SessionId string `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	ClientIp  string `protobuf:"bytes,7,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	// Регион получившего инстанса и страна клиента — для geo_pin
+	Region  string `protobuf:"bytes,8,opt,name=region,proto3" json:"region,omitempty"`
+	Country string `protobuf:"bytes,9,opt,name=country,proto3" json:"country,omitempty"`
+	// Стрим виджета на получившем инстансе: по паре from_instance/stream_id
+	// инстанс-владелец замечает ответы на задание из разных стримов
+	StreamId      uint64 `protobuf:"varint,10,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForwardSolutionRequest) Reset() {
+	*x = ForwardSolutionRequest{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForwardSolutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForwardSolutionRequest) ProtoMessage() {}
+
+func (x *ForwardSolutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForwardSolutionRequest.ProtoReflect.Descriptor instead.
+func (*ForwardSolutionRequest) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ForwardSolutionRequest) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ForwardSolutionRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ForwardSolutionRequest) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *ForwardSolutionRequest) GetFromInstance() string {
+	if x != nil {
+		return x.FromInstance
+	}
+	return ""
+}
+
+func (x *ForwardSolutionRequest) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
 
-func (x *ChallengeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[1]
+func (x *ForwardSolutionRequest) GetSessionId() string {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.SessionId
 	}
-	return mi.MessageOf(x)
+	return ""
 }
 
-// Deprecated: Use ChallengeResponse.ProtoReflect.Descriptor instead.
-func (*ChallengeResponse) Descriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{1}
+func (x *ForwardSolutionRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
 }
 
-func (x *ChallengeResponse) GetChallengeId() string {
+func (x *ForwardSolutionRequest) GetRegion() string {
 	if x != nil {
-		return x.ChallengeId
+		return x.Region
 	}
 	return ""
 }
 
-func (x *ChallengeResponse) GetHtml() string {
+func (x *ForwardSolutionRequest) GetCountry() string {
 	if x != nil {
-		return x.Html
+		return x.Country
 	}
 	return ""
 }
 
-type ClientEvent struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	EventType     ClientEvent_EventType  `protobuf:"varint,1,opt,name=event_type,json=eventType,proto3,enum=captcha.v1.ClientEvent_EventType" json:"event_type,omitempty"`
-	ChallengeId   string                 `protobuf:"bytes,2,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
-	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+func (x *ForwardSolutionRequest) GetStreamId() uint64 {
+	if x != nil {
+		return x.StreamId
+	}
+	return 0
+}
+
+// ImportChallengesRequest — задания инстанса, уходящего на остановку
+type ImportChallengesRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	FromInstance string                 `protobuf:"bytes,1,opt,name=from_instance,json=fromInstance,proto3" json:"from_instance,omitempty"`
+	// Состояние заданий, зашифрованное общим секретом флота (HANDOFF_SECRET)
+	SealedState   []byte `protobuf:"bytes,2,opt,name=sealed_state,json=sealedState,proto3" json:"sealed_state,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ClientEvent) Reset() {
-	*x = ClientEvent{}
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[2]
+func (x *ImportChallengesRequest) Reset() {
+	*x = ImportChallengesRequest{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ClientEvent) String() string {
+func (x *ImportChallengesRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientEvent) ProtoMessage() {}
+func (*ImportChallengesRequest) ProtoMessage() {}
 
-func (x *ClientEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[2]
+func (x *ImportChallengesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -200,59 +1868,48 @@ func (x *ClientEvent) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientEvent.ProtoReflect.Descriptor instead.
-func (*ClientEvent) Descriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{2}
-}
-
-func (x *ClientEvent) GetEventType() ClientEvent_EventType {
-	if x != nil {
-		return x.EventType
-	}
-	return ClientEvent_FRONTEND_EVENT
+// Deprecated: Use ImportChallengesRequest.ProtoReflect.Descriptor instead.
+func (*ImportChallengesRequest) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{16}
 }
 
-func (x *ClientEvent) GetChallengeId() string {
+func (x *ImportChallengesRequest) GetFromInstance() string {
 	if x != nil {
-		return x.ChallengeId
+		return x.FromInstance
 	}
 	return ""
 }
 
-func (x *ClientEvent) GetData() []byte {
+func (x *ImportChallengesRequest) GetSealedState() []byte {
 	if x != nil {
-		return x.Data
+		return x.SealedState
 	}
 	return nil
 }
 
-type ServerEvent struct {
+type ImportChallengesResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
-	// Types that are valid to be assigned to Event:
-	//
-	//	*ServerEvent_Result
-	//	*ServerEvent_ClientJs
-	//	*ServerEvent_ClientData
-	Event         isServerEvent_Event `protobuf_oneof:"event"`
+	// Принятые задания: балансер направляет их проверки на принявший инстанс
+	ChallengeIds  []string `protobuf:"bytes,1,rep,name=challenge_ids,json=challengeIds,proto3" json:"challenge_ids,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerEvent) Reset() {
-	*x = ServerEvent{}
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[3]
+func (x *ImportChallengesResponse) Reset() {
+	*x = ImportChallengesResponse{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerEvent) String() string {
+func (x *ImportChallengesResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerEvent) ProtoMessage() {}
+func (*ImportChallengesResponse) ProtoMessage() {}
 
-func (x *ServerEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[3]
+func (x *ImportChallengesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -263,78 +1920,43 @@ func (x *ServerEvent) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerEvent.ProtoReflect.Descriptor instead.
-func (*ServerEvent) Descriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{3}
-}
-
-func (x *ServerEvent) GetEvent() isServerEvent_Event {
-	if x != nil {
-		return x.Event
-	}
-	return nil
-}
-
-func (x *ServerEvent) GetResult() *ServerEvent_ChallengeResult {
-	if x != nil {
-		if x, ok := x.Event.(*ServerEvent_Result); ok {
-			return x.Result
-		}
-	}
-	return nil
-}
-
-func (x *ServerEvent) GetClientJs() *ServerEvent_RunClientJS {
-	if x != nil {
-		if x, ok := x.Event.(*ServerEvent_ClientJs); ok {
-			return x.ClientJs
-		}
-	}
-	return nil
+// Deprecated: Use ImportChallengesResponse.ProtoReflect.Descriptor instead.
+func (*ImportChallengesResponse) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *ServerEvent) GetClientData() *ServerEvent_SendClientData {
+func (x *ImportChallengesResponse) GetChallengeIds() []string {
 	if x != nil {
-		if x, ok := x.Event.(*ServerEvent_ClientData); ok {
-			return x.ClientData
-		}
+		return x.ChallengeIds
 	}
 	return nil
 }
 
-type isServerEvent_Event interface {
-	isServerEvent_Event()
-}
-
-type ServerEvent_Result struct {
-	Result *ServerEvent_ChallengeResult `protobuf:"bytes,1,opt,name=result,proto3,oneof"`
-}
-
-type ServerEvent_ClientJs struct {
-	ClientJs *ServerEvent_RunClientJS `protobuf:"bytes,2,opt,name=client_js,json=clientJs,proto3,oneof"`
-}
-
-type ServerEvent_ClientData struct {
-	ClientData *ServerEvent_SendClientData `protobuf:"bytes,3,opt,name=client_data,json=clientData,proto3,oneof"`
-}
-
-func (*ServerEvent_Result) isServerEvent_Event() {}
-
-func (*ServerEvent_ClientJs) isServerEvent_Event() {}
-
-func (*ServerEvent_ClientData) isServerEvent_Event() {}
-
 type ServerEvent_ChallengeResult struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId       string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
 	ConfidencePercent int32                  `protobuf:"varint,2,opt,name=confidence_percent,json=confidencePercent,proto3" json:"confidence_percent,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+	// Действие из ChallengeRequest: сайт должен сверить его с ожидаемым
+	Action string `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	// Одноразовый токен для Assess; виджет передает его бэкенду сайта
+	Token string `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`
+	// Тот же результат в виде JWT (EdDSA), который бэкенд проверяет офлайн по
+	// /.well-known/jwks.json; только если на инстансе задан RESULT_JWT_KEY.
+	// В отличие от token, JWT не одноразовый и действует до exp.
+	Jwt            string                                     `protobuf:"bytes,5,opt,name=jwt,proto3" json:"jwt,omitempty"`
+	BindingFailure ServerEvent_ChallengeResult_BindingFailure `protobuf:"varint,6,opt,name=binding_failure,json=bindingFailure,proto3,enum=captcha.v1.ServerEvent_ChallengeResult_BindingFailure" json:"binding_failure,omitempty"`
+	// Вердикт для простых интеграций: задание решено, и уверенность не ниже
+	// порога политики сайта и действия (score_threshold) — то же, что ALLOW в
+	// Assess. threshold — порог, с которым сравнивалась уверенность.
+	Passed        bool  `protobuf:"varint,7,opt,name=passed,proto3" json:"passed,omitempty"`
+	Threshold     int32 `protobuf:"varint,8,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ServerEvent_ChallengeResult) Reset() {
 	*x = ServerEvent_ChallengeResult{}
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[4]
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -346,7 +1968,7 @@ func (x *ServerEvent_ChallengeResult) String() string {
 func (*ServerEvent_ChallengeResult) ProtoMessage() {}
 
 func (x *ServerEvent_ChallengeResult) ProtoReflect() protoreflect.Message {
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[4]
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -359,7 +1981,7 @@ func (x *ServerEvent_ChallengeResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerEvent_ChallengeResult.ProtoReflect.Descriptor instead.
 func (*ServerEvent_ChallengeResult) Descriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{3, 0}
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 0}
 }
 
 func (x *ServerEvent_ChallengeResult) GetChallengeId() string {
@@ -376,6 +1998,48 @@ func (x *ServerEvent_ChallengeResult) GetConfidencePercent() int32 {
 	return 0
 }
 
+func (x *ServerEvent_ChallengeResult) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ServerEvent_ChallengeResult) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ServerEvent_ChallengeResult) GetJwt() string {
+	if x != nil {
+		return x.Jwt
+	}
+	return ""
+}
+
+func (x *ServerEvent_ChallengeResult) GetBindingFailure() ServerEvent_ChallengeResult_BindingFailure {
+	if x != nil {
+		return x.BindingFailure
+	}
+	return ServerEvent_ChallengeResult_NONE
+}
+
+func (x *ServerEvent_ChallengeResult) GetPassed() bool {
+	if x != nil {
+		return x.Passed
+	}
+	return false
+}
+
+func (x *ServerEvent_ChallengeResult) GetThreshold() int32 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
 type ServerEvent_RunClientJS struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
@@ -386,7 +2050,7 @@ type ServerEvent_RunClientJS struct {
 
 func (x *ServerEvent_RunClientJS) Reset() {
 	*x = ServerEvent_RunClientJS{}
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[5]
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -398,7 +2062,7 @@ func (x *ServerEvent_RunClientJS) String() string {
 func (*ServerEvent_RunClientJS) ProtoMessage() {}
 
 func (x *ServerEvent_RunClientJS) ProtoReflect() protoreflect.Message {
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[5]
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -411,7 +2075,7 @@ func (x *ServerEvent_RunClientJS) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerEvent_RunClientJS.ProtoReflect.Descriptor instead.
 func (*ServerEvent_RunClientJS) Descriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{3, 1}
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 1}
 }
 
 func (x *ServerEvent_RunClientJS) GetChallengeId() string {
@@ -438,7 +2102,7 @@ type ServerEvent_SendClientData struct {
 
 func (x *ServerEvent_SendClientData) Reset() {
 	*x = ServerEvent_SendClientData{}
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[6]
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -450,7 +2114,7 @@ func (x *ServerEvent_SendClientData) String() string {
 func (*ServerEvent_SendClientData) ProtoMessage() {}
 
 func (x *ServerEvent_SendClientData) ProtoReflect() protoreflect.Message {
-	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[6]
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -463,7 +2127,7 @@ func (x *ServerEvent_SendClientData) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerEvent_SendClientData.ProtoReflect.Descriptor instead.
 func (*ServerEvent_SendClientData) Descriptor() ([]byte, []int) {
-	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{3, 2}
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 2}
 }
 
 func (x *ServerEvent_SendClientData) GetChallengeId() string {
@@ -480,46 +2144,345 @@ func (x *ServerEvent_SendClientData) GetData() []byte {
 	return nil
 }
 
+type ServerEvent_ControlMessage struct {
+	state             protoimpl.MessageState          `protogen:"open.v1"`
+	Kind              ServerEvent_ControlMessage_Kind `protobuf:"varint,1,opt,name=kind,proto3,enum=captcha.v1.ServerEvent_ControlMessage_Kind" json:"kind,omitempty"`
+	ChallengeId       string                          `protobuf:"bytes,2,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Message           string                          `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	RetryAfterSeconds int32                           `protobuf:"varint,4,opt,name=retry_after_seconds,json=retryAfterSeconds,proto3" json:"retry_after_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ServerEvent_ControlMessage) Reset() {
+	*x = ServerEvent_ControlMessage{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_ControlMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_ControlMessage) ProtoMessage() {}
+
+func (x *ServerEvent_ControlMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_ControlMessage.ProtoReflect.Descriptor instead.
+func (*ServerEvent_ControlMessage) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 3}
+}
+
+func (x *ServerEvent_ControlMessage) GetKind() ServerEvent_ControlMessage_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return ServerEvent_ControlMessage_UNKNOWN
+}
+
+func (x *ServerEvent_ControlMessage) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ServerEvent_ControlMessage) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ServerEvent_ControlMessage) GetRetryAfterSeconds() int32 {
+	if x != nil {
+		return x.RetryAfterSeconds
+	}
+	return 0
+}
+
+// Negotiated — ответ на HELLO: что сервер выбрал для виджета
+type ServerEvent_Negotiated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AnswerSchema  uint32                 `protobuf:"varint,1,opt,name=answer_schema,json=answerSchema,proto3" json:"answer_schema,omitempty"`
+	Obfuscation   string                 `protobuf:"bytes,2,opt,name=obfuscation,proto3" json:"obfuscation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent_Negotiated) Reset() {
+	*x = ServerEvent_Negotiated{}
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_Negotiated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_Negotiated) ProtoMessage() {}
+
+func (x *ServerEvent_Negotiated) ProtoReflect() protoreflect.Message {
+	mi := &file_api_captcha_v1_CaptchaV1_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_Negotiated.ProtoReflect.Descriptor instead.
+func (*ServerEvent_Negotiated) Descriptor() ([]byte, []int) {
+	return file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP(), []int{8, 4}
+}
+
+func (x *ServerEvent_Negotiated) GetAnswerSchema() uint32 {
+	if x != nil {
+		return x.AnswerSchema
+	}
+	return 0
+}
+
+func (x *ServerEvent_Negotiated) GetObfuscation() string {
+	if x != nil {
+		return x.Obfuscation
+	}
+	return ""
+}
+
 var File_api_captcha_v1_CaptchaV1_proto protoreflect.FileDescriptor
 
 const file_api_captcha_v1_CaptchaV1_proto_rawDesc = "" +
 	"\n" +
 	"\x1eapi/captcha/v1/CaptchaV1.proto\x12\n" +
-	"captcha.v1\"2\n" +
+	"captcha.v1\"\xfb\x03\n" +
 	"\x10ChallengeRequest\x12\x1e\n" +
 	"\n" +
 	"complexity\x18\x01 \x01(\x05R\n" +
-	"complexity\"J\n" +
+	"complexity\x12\x19\n" +
+	"\bsite_key\x18\x02 \x01(\tR\asiteKey\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x129\n" +
+	"\vattestation\x18\x04 \x01(\v2\x17.captcha.v1.AttestationR\vattestation\x121\n" +
+	"\x06client\x18\x05 \x01(\v2\x19.captcha.v1.ClientContextR\x06client\x12\x16\n" +
+	"\x06locale\x18\x06 \x01(\tR\x06locale\x12E\n" +
+	"\n" +
+	"risk_level\x18\a \x01(\x0e2&.captcha.v1.ChallengeRequest.RiskLevelR\triskLevel\x12A\n" +
+	"\bdelivery\x18\b \x01(\x0e2%.captcha.v1.ChallengeRequest.DeliveryR\bdelivery\";\n" +
+	"\tRiskLevel\x12\x0f\n" +
+	"\vUNSPECIFIED\x10\x00\x12\a\n" +
+	"\x03LOW\x10\x01\x12\n" +
+	"\n" +
+	"\x06MEDIUM\x10\x02\x12\b\n" +
+	"\x04HIGH\x10\x03\"G\n" +
+	"\bDelivery\x12\x14\n" +
+	"\x10DELIVERY_DEFAULT\x10\x00\x12\x13\n" +
+	"\x0fDELIVERY_INLINE\x10\x01\x12\x10\n" +
+	"\fDELIVERY_URL\x10\x02\"\xf9\x01\n" +
+	"\rClientContext\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x02 \x01(\tR\tuserAgent\x12 \n" +
+	"\vfingerprint\x18\x03 \x01(\tR\vfingerprint\x12B\n" +
+	"\fcapabilities\x18\x04 \x01(\v2\x1e.captcha.v1.WidgetCapabilitiesR\fcapabilities\x12\x1a\n" +
+	"\bhostname\x18\x05 \x01(\tR\bhostname\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\tR\tsessionId\x12\x18\n" +
+	"\acountry\x18\a \x01(\tR\acountry\"\xbd\x01\n" +
+	"\x12WidgetCapabilities\x12%\n" +
+	"\x0ewidget_version\x18\x01 \x01(\rR\rwidgetVersion\x12\x14\n" +
+	"\x05touch\x18\x02 \x01(\bR\x05touch\x12+\n" +
+	"\x11telemetry_version\x18\x03 \x01(\rR\x10telemetryVersion\x12\x16\n" +
+	"\x06crypto\x18\x04 \x01(\bR\x06crypto\x12%\n" +
+	"\x0eanswer_schemas\x18\x05 \x03(\rR\ranswerSchemas\"?\n" +
+	"\vAttestation\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\fR\x05token\"S\n" +
+	"\x0fChallengeHandle\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x02 \x01(\x03R\texpiresAt\"\xb4\x02\n" +
 	"\x11ChallengeResponse\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04html\x18\x02 \x01(\tR\x04html\"\xd2\x01\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html\x12\x1a\n" +
+	"\battested\x18\x03 \x01(\bR\battested\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\x12%\n" +
+	"\x0einvisible_pass\x18\x05 \x01(\bR\rinvisiblePass\x12\x1d\n" +
+	"\n" +
+	"risk_score\x18\x06 \x01(\x05R\triskScore\x12 \n" +
+	"\vallowlisted\x18\a \x01(\bR\vallowlisted\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\b \x01(\x03R\texpiresAt\x12\x10\n" +
+	"\x03jwt\x18\t \x01(\tR\x03jwt\x12\x1d\n" +
+	"\n" +
+	"answer_key\x18\n" +
+	" \x01(\fR\tanswerKey\"\x80\x04\n" +
 	"\vClientEvent\x12@\n" +
 	"\n" +
 	"event_type\x18\x01 \x01(\x0e2!.captcha.v1.ClientEvent.EventTypeR\teventType\x12!\n" +
 	"\fchallenge_id\x18\x02 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04data\x18\x03 \x01(\fR\x04data\"J\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12 \n" +
+	"\vfingerprint\x18\x04 \x01(\tR\vfingerprint\x12B\n" +
+	"\fcapabilities\x18\x05 \x01(\v2\x1e.captcha.v1.WidgetCapabilitiesR\fcapabilities\x12\x19\n" +
+	"\bsite_key\x18\x06 \x01(\tR\asiteKey\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\a \x01(\tR\tsessionId\x12\x1b\n" +
+	"\tclient_ip\x18\b \x01(\tR\bclientIp\x129\n" +
+	"\vcalibration\x18\t \x01(\v2\x17.captcha.v1.CalibrationR\vcalibration\x12\x18\n" +
+	"\acountry\x18\n" +
+	" \x01(\tR\acountry\"f\n" +
 	"\tEventType\x12\x12\n" +
 	"\x0eFRONTEND_EVENT\x10\x00\x12\x15\n" +
 	"\x11CONNECTION_CLOSED\x10\x01\x12\x12\n" +
-	"\x0eBALANCER_EVENT\x10\x02\"\xe1\x03\n" +
+	"\x0eBALANCER_EVENT\x10\x02\x12\t\n" +
+	"\x05HELLO\x10\x03\x12\x0f\n" +
+	"\vCALIBRATION\x10\x04\"\x8b\x01\n" +
+	"\vCalibration\x12%\n" +
+	"\x0erendered_width\x18\x01 \x01(\x01R\rrenderedWidth\x12'\n" +
+	"\x0frendered_height\x18\x02 \x01(\x01R\x0erenderedHeight\x12,\n" +
+	"\x12device_pixel_ratio\x18\x03 \x01(\x01R\x10devicePixelRatio\"\xa9\n" +
+	"\n" +
 	"\vServerEvent\x12A\n" +
 	"\x06result\x18\x01 \x01(\v2'.captcha.v1.ServerEvent.ChallengeResultH\x00R\x06result\x12B\n" +
 	"\tclient_js\x18\x02 \x01(\v2#.captcha.v1.ServerEvent.RunClientJSH\x00R\bclientJs\x12I\n" +
 	"\vclient_data\x18\x03 \x01(\v2&.captcha.v1.ServerEvent.SendClientDataH\x00R\n" +
-	"clientData\x1ac\n" +
+	"clientData\x12B\n" +
+	"\acontrol\x18\x04 \x01(\v2&.captcha.v1.ServerEvent.ControlMessageH\x00R\acontrol\x12D\n" +
+	"\n" +
+	"negotiated\x18\x05 \x01(\v2\".captcha.v1.ServerEvent.NegotiatedH\x00R\n" +
+	"negotiated\x1a\xac\x03\n" +
 	"\x0fChallengeResult\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12-\n" +
-	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x1aI\n" +
+	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\x12\x10\n" +
+	"\x03jwt\x18\x05 \x01(\tR\x03jwt\x12_\n" +
+	"\x0fbinding_failure\x18\x06 \x01(\x0e26.captcha.v1.ServerEvent.ChallengeResult.BindingFailureR\x0ebindingFailure\x12\x16\n" +
+	"\x06passed\x18\a \x01(\bR\x06passed\x12\x1c\n" +
+	"\tthreshold\x18\b \x01(\x05R\tthreshold\"p\n" +
+	"\x0eBindingFailure\x12\b\n" +
+	"\x04NONE\x10\x00\x12\x11\n" +
+	"\rSITE_MISMATCH\x10\x01\x12\x14\n" +
+	"\x10SESSION_MISMATCH\x10\x02\x12\x16\n" +
+	"\x12IP_PREFIX_MISMATCH\x10\x03\x12\x13\n" +
+	"\x0fREGION_MISMATCH\x10\x04\x1aI\n" +
 	"\vRunClientJS\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x17\n" +
 	"\ajs_code\x18\x02 \x01(\tR\x06jsCode\x1aG\n" +
 	"\x0eSendClientData\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\fR\x04dataB\a\n" +
-	"\x05event2\xaa\x01\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x1a\x9c\x02\n" +
+	"\x0eControlMessage\x12?\n" +
+	"\x04kind\x18\x01 \x01(\x0e2+.captcha.v1.ServerEvent.ControlMessage.KindR\x04kind\x12!\n" +
+	"\fchallenge_id\x18\x02 \x01(\tR\vchallengeId\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12.\n" +
+	"\x13retry_after_seconds\x18\x04 \x01(\x05R\x11retryAfterSeconds\"\\\n" +
+	"\x04Kind\x12\v\n" +
+	"\aUNKNOWN\x10\x00\x12\f\n" +
+	"\bDRAINING\x10\x01\x12\v\n" +
+	"\aREFRESH\x10\x02\x12\x12\n" +
+	"\x0eQUOTA_EXCEEDED\x10\x04\"\x04\b\x03\x10\x03*\x12CHALLENGE_REISSUED\x1aS\n" +
+	"\n" +
+	"Negotiated\x12#\n" +
+	"\ranswer_schema\x18\x01 \x01(\rR\fanswerSchema\x12 \n" +
+	"\vobfuscation\x18\x02 \x01(\tR\vobfuscationB\a\n" +
+	"\x05event\"X\n" +
+	"\rAssessRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\x12\x19\n" +
+	"\bsite_key\x18\x03 \x01(\tR\asiteKey\"\xbf\x02\n" +
+	"\x0eAssessResponse\x12?\n" +
+	"\bdecision\x18\x01 \x01(\x0e2#.captcha.v1.AssessResponse.DecisionR\bdecision\x12-\n" +
+	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x12\x1c\n" +
+	"\tthreshold\x18\x03 \x01(\x05R\tthreshold\x12\x16\n" +
+	"\x06action\x18\x04 \x01(\tR\x06action\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x12\x1a\n" +
+	"\bhostname\x18\x06 \x01(\tR\bhostname\x12\x16\n" +
+	"\x06region\x18\a \x01(\tR\x06region\";\n" +
+	"\bDecision\x12\v\n" +
+	"\aUNKNOWN\x10\x00\x12\t\n" +
+	"\x05ALLOW\x10\x01\x12\r\n" +
+	"\tCHALLENGE\x10\x02\x12\b\n" +
+	"\x04DENY\x10\x03\"\xe1\x01\n" +
+	"\x16ChallengeAssetsRequest\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12I\n" +
+	"\aoffsets\x18\x02 \x03(\v2/.captcha.v1.ChallengeAssetsRequest.OffsetsEntryR\aoffsets\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x03 \x01(\x05R\tchunkSize\x1a:\n" +
+	"\fOffsetsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\x9c\x01\n" +
+	"\n" +
+	"AssetChunk\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1b\n" +
+	"\tmime_type\x18\x02 \x01(\tR\bmimeType\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x03 \x01(\x03R\ttotalSize\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04data\x18\x05 \x01(\fR\x04data\x12\x12\n" +
+	"\x04last\x18\x06 \x01(\bR\x04last\"V\n" +
+	"\x16ChallengeResultRequest\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x19\n" +
+	"\bsite_key\x18\x02 \x01(\tR\asiteKey\"\xe9\x02\n" +
+	"\x17ChallengeResultResponse\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12B\n" +
+	"\x06status\x18\x02 \x01(\x0e2*.captcha.v1.ChallengeResultResponse.StatusR\x06status\x12-\n" +
+	"\x12confidence_percent\x18\x03 \x01(\x05R\x11confidencePercent\x12\x16\n" +
+	"\x06action\x18\x04 \x01(\tR\x06action\x12\x1f\n" +
+	"\vverified_at\x18\x05 \x01(\x03R\n" +
+	"verifiedAt\x12\x16\n" +
+	"\x06passed\x18\x06 \x01(\bR\x06passed\x12\x1c\n" +
+	"\tthreshold\x18\a \x01(\x05R\tthreshold\"I\n" +
+	"\x06Status\x12\v\n" +
+	"\aUNKNOWN\x10\x00\x12\v\n" +
+	"\aPENDING\x10\x01\x12\n" +
+	"\n" +
+	"\x06SOLVED\x10\x02\x12\n" +
+	"\n" +
+	"\x06FAILED\x10\x03\x12\r\n" +
+	"\tNOT_FOUND\x10\x04\"\xbc\x02\n" +
+	"\x16ForwardSolutionRequest\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12 \n" +
+	"\vfingerprint\x18\x03 \x01(\tR\vfingerprint\x12#\n" +
+	"\rfrom_instance\x18\x04 \x01(\tR\ffromInstance\x12\x19\n" +
+	"\bsite_key\x18\x05 \x01(\tR\asiteKey\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\tR\tsessionId\x12\x1b\n" +
+	"\tclient_ip\x18\a \x01(\tR\bclientIp\x12\x16\n" +
+	"\x06region\x18\b \x01(\tR\x06region\x12\x18\n" +
+	"\acountry\x18\t \x01(\tR\acountry\x12\x1b\n" +
+	"\tstream_id\x18\n" +
+	" \x01(\x04R\bstreamId\"a\n" +
+	"\x17ImportChallengesRequest\x12#\n" +
+	"\rfrom_instance\x18\x01 \x01(\tR\ffromInstance\x12!\n" +
+	"\fsealed_state\x18\x02 \x01(\fR\vsealedState\"?\n" +
+	"\x18ImportChallengesResponse\x12#\n" +
+	"\rchallenge_ids\x18\x01 \x03(\tR\fchallengeIds2\xf6\x05\n" +
 	"\x0eCaptchaService\x12M\n" +
 	"\fNewChallenge\x12\x1c.captcha.v1.ChallengeRequest\x1a\x1d.captcha.v1.ChallengeResponse\"\x00\x12I\n" +
-	"\x0fMakeEventStream\x12\x17.captcha.v1.ClientEvent\x1a\x17.captcha.v1.ServerEvent\"\x00(\x010\x01B\x11Z\x0f./pb/captcha/v1b\x06proto3"
+	"\x0fMakeEventStream\x12\x17.captcha.v1.ClientEvent\x1a\x17.captcha.v1.ServerEvent\"\x00(\x010\x01\x12A\n" +
+	"\x06Assess\x12\x19.captcha.v1.AssessRequest\x1a\x1a.captcha.v1.AssessResponse\"\x00\x12O\n" +
+	"\x10PrewarmChallenge\x12\x1c.captcha.v1.ChallengeRequest\x1a\x1b.captcha.v1.ChallengeHandle\"\x00\x12L\n" +
+	"\fGetChallenge\x12\x1b.captcha.v1.ChallengeHandle\x1a\x1d.captcha.v1.ChallengeResponse\"\x00\x12T\n" +
+	"\x12GetChallengeAssets\x12\".captcha.v1.ChallengeAssetsRequest\x1a\x16.captcha.v1.AssetChunk\"\x000\x01\x12_\n" +
+	"\x12GetChallengeResult\x12\".captcha.v1.ChallengeResultRequest\x1a#.captcha.v1.ChallengeResultResponse\"\x00\x12P\n" +
+	"\x0fForwardSolution\x12\".captcha.v1.ForwardSolutionRequest\x1a\x17.captcha.v1.ServerEvent\"\x00\x12_\n" +
+	"\x10ImportChallenges\x12#.captcha.v1.ImportChallengesRequest\x1a$.captcha.v1.ImportChallengesResponse\"\x00B\x11Z\x0f./pb/captcha/v1b\x06proto3"
 
 var (
 	file_api_captcha_v1_CaptchaV1_proto_rawDescOnce sync.Once
@@ -533,32 +2496,83 @@ func file_api_captcha_v1_CaptchaV1_proto_rawDescGZIP() []byte {
 	return file_api_captcha_v1_CaptchaV1_proto_rawDescData
 }
 
-var file_api_captcha_v1_CaptchaV1_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_api_captcha_v1_CaptchaV1_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_api_captcha_v1_CaptchaV1_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_api_captcha_v1_CaptchaV1_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_api_captcha_v1_CaptchaV1_proto_goTypes = []any{
-	(ClientEvent_EventType)(0),          // 0: captcha.v1.ClientEvent.EventType
-	(*ChallengeRequest)(nil),            // 1: captcha.v1.ChallengeRequest
-	(*ChallengeResponse)(nil),           // 2: captcha.v1.ChallengeResponse
-	(*ClientEvent)(nil),                 // 3: captcha.v1.ClientEvent
-	(*ServerEvent)(nil),                 // 4: captcha.v1.ServerEvent
-	(*ServerEvent_ChallengeResult)(nil), // 5: captcha.v1.ServerEvent.ChallengeResult
-	(*ServerEvent_RunClientJS)(nil),     // 6: captcha.v1.ServerEvent.RunClientJS
-	(*ServerEvent_SendClientData)(nil),  // 7: captcha.v1.ServerEvent.SendClientData
+	(ChallengeRequest_RiskLevel)(0),                 // 0: captcha.v1.ChallengeRequest.RiskLevel
+	(ChallengeRequest_Delivery)(0),                  // 1: captcha.v1.ChallengeRequest.Delivery
+	(ClientEvent_EventType)(0),                      // 2: captcha.v1.ClientEvent.EventType
+	(ServerEvent_ChallengeResult_BindingFailure)(0), // 3: captcha.v1.ServerEvent.ChallengeResult.BindingFailure
+	(ServerEvent_ControlMessage_Kind)(0),            // 4: captcha.v1.ServerEvent.ControlMessage.Kind
+	(AssessResponse_Decision)(0),                    // 5: captcha.v1.AssessResponse.Decision
+	(ChallengeResultResponse_Status)(0),             // 6: captcha.v1.ChallengeResultResponse.Status
+	(*ChallengeRequest)(nil),                        // 7: captcha.v1.ChallengeRequest
+	(*ClientContext)(nil),                           // 8: captcha.v1.ClientContext
+	(*WidgetCapabilities)(nil),                      // 9: captcha.v1.WidgetCapabilities
+	(*Attestation)(nil),                             // 10: captcha.v1.Attestation
+	(*ChallengeHandle)(nil),                         // 11: captcha.v1.ChallengeHandle
+	(*ChallengeResponse)(nil),                       // 12: captcha.v1.ChallengeResponse
+	(*ClientEvent)(nil),                             // 13: captcha.v1.ClientEvent
+	(*Calibration)(nil),                             // 14: captcha.v1.Calibration
+	(*ServerEvent)(nil),                             // 15: captcha.v1.ServerEvent
+	(*AssessRequest)(nil),                           // 16: captcha.v1.AssessRequest
+	(*AssessResponse)(nil),                          // 17: captcha.v1.AssessResponse
+	(*ChallengeAssetsRequest)(nil),                  // 18: captcha.v1.ChallengeAssetsRequest
+	(*AssetChunk)(nil),                              // 19: captcha.v1.AssetChunk
+	(*ChallengeResultRequest)(nil),                  // 20: captcha.v1.ChallengeResultRequest
+	(*ChallengeResultResponse)(nil),                 // 21: captcha.v1.ChallengeResultResponse
+	(*ForwardSolutionRequest)(nil),                  // 22: captcha.v1.ForwardSolutionRequest
+	(*ImportChallengesRequest)(nil),                 // 23: captcha.v1.ImportChallengesRequest
+	(*ImportChallengesResponse)(nil),                // 24: captcha.v1.ImportChallengesResponse
+	(*ServerEvent_ChallengeResult)(nil),             // 25: captcha.v1.ServerEvent.ChallengeResult
+	(*ServerEvent_RunClientJS)(nil),                 // 26: captcha.v1.ServerEvent.RunClientJS
+	(*ServerEvent_SendClientData)(nil),              // 27: captcha.v1.ServerEvent.SendClientData
+	(*ServerEvent_ControlMessage)(nil),              // 28: captcha.v1.ServerEvent.ControlMessage
+	(*ServerEvent_Negotiated)(nil),                  // 29: captcha.v1.ServerEvent.Negotiated
+	nil,                                             // 30: captcha.v1.ChallengeAssetsRequest.OffsetsEntry
 }
 var file_api_captcha_v1_CaptchaV1_proto_depIdxs = []int32{
-	0, // 0: captcha.v1.ClientEvent.event_type:type_name -> captcha.v1.ClientEvent.EventType
-	5, // 1: captcha.v1.ServerEvent.result:type_name -> captcha.v1.ServerEvent.ChallengeResult
-	6, // 2: captcha.v1.ServerEvent.client_js:type_name -> captcha.v1.ServerEvent.RunClientJS
-	7, // 3: captcha.v1.ServerEvent.client_data:type_name -> captcha.v1.ServerEvent.SendClientData
-	1, // 4: captcha.v1.CaptchaService.NewChallenge:input_type -> captcha.v1.ChallengeRequest
-	3, // 5: captcha.v1.CaptchaService.MakeEventStream:input_type -> captcha.v1.ClientEvent
-	2, // 6: captcha.v1.CaptchaService.NewChallenge:output_type -> captcha.v1.ChallengeResponse
-	4, // 7: captcha.v1.CaptchaService.MakeEventStream:output_type -> captcha.v1.ServerEvent
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	10, // 0: captcha.v1.ChallengeRequest.attestation:type_name -> captcha.v1.Attestation
+	8,  // 1: captcha.v1.ChallengeRequest.client:type_name -> captcha.v1.ClientContext
+	0,  // 2: captcha.v1.ChallengeRequest.risk_level:type_name -> captcha.v1.ChallengeRequest.RiskLevel
+	1,  // 3: captcha.v1.ChallengeRequest.delivery:type_name -> captcha.v1.ChallengeRequest.Delivery
+	9,  // 4: captcha.v1.ClientContext.capabilities:type_name -> captcha.v1.WidgetCapabilities
+	2,  // 5: captcha.v1.ClientEvent.event_type:type_name -> captcha.v1.ClientEvent.EventType
+	9,  // 6: captcha.v1.ClientEvent.capabilities:type_name -> captcha.v1.WidgetCapabilities
+	14, // 7: captcha.v1.ClientEvent.calibration:type_name -> captcha.v1.Calibration
+	25, // 8: captcha.v1.ServerEvent.result:type_name -> captcha.v1.ServerEvent.ChallengeResult
+	26, // 9: captcha.v1.ServerEvent.client_js:type_name -> captcha.v1.ServerEvent.RunClientJS
+	27, // 10: captcha.v1.ServerEvent.client_data:type_name -> captcha.v1.ServerEvent.SendClientData
+	28, // 11: captcha.v1.ServerEvent.control:type_name -> captcha.v1.ServerEvent.ControlMessage
+	29, // 12: captcha.v1.ServerEvent.negotiated:type_name -> captcha.v1.ServerEvent.Negotiated
+	5,  // 13: captcha.v1.AssessResponse.decision:type_name -> captcha.v1.AssessResponse.Decision
+	30, // 14: captcha.v1.ChallengeAssetsRequest.offsets:type_name -> captcha.v1.ChallengeAssetsRequest.OffsetsEntry
+	6,  // 15: captcha.v1.ChallengeResultResponse.status:type_name -> captcha.v1.ChallengeResultResponse.Status
+	3,  // 16: captcha.v1.ServerEvent.ChallengeResult.binding_failure:type_name -> captcha.v1.ServerEvent.ChallengeResult.BindingFailure
+	4,  // 17: captcha.v1.ServerEvent.ControlMessage.kind:type_name -> captcha.v1.ServerEvent.ControlMessage.Kind
+	7,  // 18: captcha.v1.CaptchaService.NewChallenge:input_type -> captcha.v1.ChallengeRequest
+	13, // 19: captcha.v1.CaptchaService.MakeEventStream:input_type -> captcha.v1.ClientEvent
+	16, // 20: captcha.v1.CaptchaService.Assess:input_type -> captcha.v1.AssessRequest
+	7,  // 21: captcha.v1.CaptchaService.PrewarmChallenge:input_type -> captcha.v1.ChallengeRequest
+	11, // 22: captcha.v1.CaptchaService.GetChallenge:input_type -> captcha.v1.ChallengeHandle
+	18, // 23: captcha.v1.CaptchaService.GetChallengeAssets:input_type -> captcha.v1.ChallengeAssetsRequest
+	20, // 24: captcha.v1.CaptchaService.GetChallengeResult:input_type -> captcha.v1.ChallengeResultRequest
+	22, // 25: captcha.v1.CaptchaService.ForwardSolution:input_type -> captcha.v1.ForwardSolutionRequest
+	23, // 26: captcha.v1.CaptchaService.ImportChallenges:input_type -> captcha.v1.ImportChallengesRequest
+	12, // 27: captcha.v1.CaptchaService.NewChallenge:output_type -> captcha.v1.ChallengeResponse
+	15, // 28: captcha.v1.CaptchaService.MakeEventStream:output_type -> captcha.v1.ServerEvent
+	17, // 29: captcha.v1.CaptchaService.Assess:output_type -> captcha.v1.AssessResponse
+	11, // 30: captcha.v1.CaptchaService.PrewarmChallenge:output_type -> captcha.v1.ChallengeHandle
+	12, // 31: captcha.v1.CaptchaService.GetChallenge:output_type -> captcha.v1.ChallengeResponse
+	19, // 32: captcha.v1.CaptchaService.GetChallengeAssets:output_type -> captcha.v1.AssetChunk
+	21, // 33: captcha.v1.CaptchaService.GetChallengeResult:output_type -> captcha.v1.ChallengeResultResponse
+	15, // 34: captcha.v1.CaptchaService.ForwardSolution:output_type -> captcha.v1.ServerEvent
+	24, // 35: captcha.v1.CaptchaService.ImportChallenges:output_type -> captcha.v1.ImportChallengesResponse
+	27, // [27:36] is the sub-list for method output_type
+	18, // [18:27] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
 }
 
 func init() { file_api_captcha_v1_CaptchaV1_proto_init() }
@@ -566,18 +2580,20 @@ func file_api_captcha_v1_CaptchaV1_proto_init() {
 	if File_api_captcha_v1_CaptchaV1_proto != nil {
 		return
 	}
-	file_api_captcha_v1_CaptchaV1_proto_msgTypes[3].OneofWrappers = []any{
+	file_api_captcha_v1_CaptchaV1_proto_msgTypes[8].OneofWrappers = []any{
 		(*ServerEvent_Result)(nil),
 		(*ServerEvent_ClientJs)(nil),
 		(*ServerEvent_ClientData)(nil),
+		(*ServerEvent_Control)(nil),
+		(*ServerEvent_Negotiated_)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_captcha_v1_CaptchaV1_proto_rawDesc), len(file_api_captcha_v1_CaptchaV1_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   7,
+			NumEnums:      7,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,8 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	CaptchaService_NewChallenge_FullMethodName    = "/captcha.v1.CaptchaService/NewChallenge"
-	CaptchaService_MakeEventStream_FullMethodName = "/captcha.v1.CaptchaService/MakeEventStream"
+	CaptchaService_NewChallenge_FullMethodName       = "/captcha.v1.CaptchaService/NewChallenge"
+	CaptchaService_MakeEventStream_FullMethodName    = "/captcha.v1.CaptchaService/MakeEventStream"
+	CaptchaService_Assess_FullMethodName             = "/captcha.v1.CaptchaService/Assess"
+	CaptchaService_PrewarmChallenge_FullMethodName   = "/captcha.v1.CaptchaService/PrewarmChallenge"
+	CaptchaService_GetChallenge_FullMethodName       = "/captcha.v1.CaptchaService/GetChallenge"
+	CaptchaService_GetChallengeAssets_FullMethodName = "/captcha.v1.CaptchaService/GetChallengeAssets"
+	CaptchaService_GetChallengeResult_FullMethodName = "/captcha.v1.CaptchaService/GetChallengeResult"
+	CaptchaService_ForwardSolution_FullMethodName    = "/captcha.v1.CaptchaService/ForwardSolution"
+	CaptchaService_ImportChallenges_FullMethodName   = "/captcha.v1.CaptchaService/ImportChallenges"
 )
 
 // CaptchaServiceClient is the client API for CaptchaService service.
@@ -29,6 +36,25 @@ const (
 type CaptchaServiceClient interface {
 	NewChallenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeResponse, error)
 	MakeEventStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ClientEvent, ServerEvent], error)
+	// Assess — серверная проверка токена результата: бэкенд сайта получает
+	// решение allow/challenge/deny по порогу политики действия
+	Assess(ctx context.Context, in *AssessRequest, opts ...grpc.CallOption) (*AssessResponse, error)
+	// PrewarmChallenge сразу возвращает handle задания, а картинки рисуются в фоне;
+	// виджет забирает готовое задание через GetChallenge, когда становится видимым
+	PrewarmChallenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeHandle, error)
+	GetChallenge(ctx context.Context, in *ChallengeHandle, opts ...grpc.CallOption) (*ChallengeResponse, error)
+	// GetChallengeAssets отдает картинки задания частями; работает, когда картинки
+	// не встроены в HTML (ленивая загрузка)
+	GetChallengeAssets(ctx context.Context, in *ChallengeAssetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AssetChunk], error)
+	// GetChallengeResult — идемпотентный запрос итога задания по ID в течение
+	// короткого времени после решения, для повторной проверки на бэкенде
+	GetChallengeResult(ctx context.Context, in *ChallengeResultRequest, opts ...grpc.CallOption) (*ChallengeResultResponse, error)
+	// Внутренний вызов между инстансами: проверка решения задания, выданного
+	// вызываемым инстансом, если решение пришло на другой
+	ForwardSolution(ctx context.Context, in *ForwardSolutionRequest, opts ...grpc.CallOption) (*ServerEvent, error)
+	// Внутренний вызов балансера: прием незавершенных заданий инстанса,
+	// уходящего на остановку
+	ImportChallenges(ctx context.Context, in *ImportChallengesRequest, opts ...grpc.CallOption) (*ImportChallengesResponse, error)
 }
 
 type captchaServiceClient struct {
@@ -62,12 +88,110 @@ func (c *captchaServiceClient) MakeEventStream(ctx context.Context, opts ...grpc
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type CaptchaService_MakeEventStreamClient = grpc.BidiStreamingClient[ClientEvent, ServerEvent]
 
+func (c *captchaServiceClient) Assess(ctx context.Context, in *AssessRequest, opts ...grpc.CallOption) (*AssessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AssessResponse)
+	err := c.cc.Invoke(ctx, CaptchaService_Assess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *captchaServiceClient) PrewarmChallenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeHandle, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChallengeHandle)
+	err := c.cc.Invoke(ctx, CaptchaService_PrewarmChallenge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *captchaServiceClient) GetChallenge(ctx context.Context, in *ChallengeHandle, opts ...grpc.CallOption) (*ChallengeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChallengeResponse)
+	err := c.cc.Invoke(ctx, CaptchaService_GetChallenge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *captchaServiceClient) GetChallengeAssets(ctx context.Context, in *ChallengeAssetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AssetChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CaptchaService_ServiceDesc.Streams[1], CaptchaService_GetChallengeAssets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ChallengeAssetsRequest, AssetChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CaptchaService_GetChallengeAssetsClient = grpc.ServerStreamingClient[AssetChunk]
+
+func (c *captchaServiceClient) GetChallengeResult(ctx context.Context, in *ChallengeResultRequest, opts ...grpc.CallOption) (*ChallengeResultResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChallengeResultResponse)
+	err := c.cc.Invoke(ctx, CaptchaService_GetChallengeResult_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *captchaServiceClient) ForwardSolution(ctx context.Context, in *ForwardSolutionRequest, opts ...grpc.CallOption) (*ServerEvent, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ServerEvent)
+	err := c.cc.Invoke(ctx, CaptchaService_ForwardSolution_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *captchaServiceClient) ImportChallenges(ctx context.Context, in *ImportChallengesRequest, opts ...grpc.CallOption) (*ImportChallengesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportChallengesResponse)
+	err := c.cc.Invoke(ctx, CaptchaService_ImportChallenges_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CaptchaServiceServer is the server API for CaptchaService service.
 // All implementations must embed UnimplementedCaptchaServiceServer
 // for forward compatibility.
 type CaptchaServiceServer interface {
 	NewChallenge(context.Context, *ChallengeRequest) (*ChallengeResponse, error)
 	MakeEventStream(grpc.BidiStreamingServer[ClientEvent, ServerEvent]) error
+	// Assess — серверная проверка токена результата: бэкенд сайта получает
+	// решение allow/challenge/deny по порогу политики действия
+	Assess(context.Context, *AssessRequest) (*AssessResponse, error)
+	// PrewarmChallenge сразу возвращает handle задания, а картинки рисуются в фоне;
+	// виджет забирает готовое задание через GetChallenge, когда становится видимым
+	PrewarmChallenge(context.Context, *ChallengeRequest) (*ChallengeHandle, error)
+	GetChallenge(context.Context, *ChallengeHandle) (*ChallengeResponse, error)
+	// GetChallengeAssets отдает картинки задания частями; работает, когда картинки
+	// не встроены в HTML (ленивая загрузка)
+	GetChallengeAssets(*ChallengeAssetsRequest, grpc.ServerStreamingServer[AssetChunk]) error
+	// GetChallengeResult — идемпотентный запрос итога задания по ID в течение
+	// короткого времени после решения, для повторной проверки на бэкенде
+	GetChallengeResult(context.Context, *ChallengeResultRequest) (*ChallengeResultResponse, error)
+	// Внутренний вызов между инстансами: проверка решения задания, выданного
+	// вызываемым инстансом, если решение пришло на другой
+	ForwardSolution(context.Context, *ForwardSolutionRequest) (*ServerEvent, error)
+	// Внутренний вызов балансера: прием незавершенных заданий инстанса,
+	// уходящего на остановку
+	ImportChallenges(context.Context, *ImportChallengesRequest) (*ImportChallengesResponse, error)
 	mustEmbedUnimplementedCaptchaServiceServer()
 }
 
@@ -84,6 +208,27 @@ func (UnimplementedCaptchaServiceServer) NewChallenge(context.Context, *Challeng
 func (UnimplementedCaptchaServiceServer) MakeEventStream(grpc.BidiStreamingServer[ClientEvent, ServerEvent]) error {
 	return status.Errorf(codes.Unimplemented, "method MakeEventStream not implemented")
 }
+func (UnimplementedCaptchaServiceServer) Assess(context.Context, *AssessRequest) (*AssessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Assess not implemented")
+}
+func (UnimplementedCaptchaServiceServer) PrewarmChallenge(context.Context, *ChallengeRequest) (*ChallengeHandle, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PrewarmChallenge not implemented")
+}
+func (UnimplementedCaptchaServiceServer) GetChallenge(context.Context, *ChallengeHandle) (*ChallengeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChallenge not implemented")
+}
+func (UnimplementedCaptchaServiceServer) GetChallengeAssets(*ChallengeAssetsRequest, grpc.ServerStreamingServer[AssetChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method GetChallengeAssets not implemented")
+}
+func (UnimplementedCaptchaServiceServer) GetChallengeResult(context.Context, *ChallengeResultRequest) (*ChallengeResultResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChallengeResult not implemented")
+}
+func (UnimplementedCaptchaServiceServer) ForwardSolution(context.Context, *ForwardSolutionRequest) (*ServerEvent, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ForwardSolution not implemented")
+}
+func (UnimplementedCaptchaServiceServer) ImportChallenges(context.Context, *ImportChallengesRequest) (*ImportChallengesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportChallenges not implemented")
+}
 func (UnimplementedCaptchaServiceServer) mustEmbedUnimplementedCaptchaServiceServer() {}
 func (UnimplementedCaptchaServiceServer) testEmbeddedByValue()                        {}
 
@@ -130,6 +275,125 @@ func _CaptchaService_MakeEventStream_Handler(srv interface{}, stream grpc.Server
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type CaptchaService_MakeEventStreamServer = grpc.BidiStreamingServer[ClientEvent, ServerEvent]
 
+func _CaptchaService_Assess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).Assess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_Assess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).Assess(ctx, req.(*AssessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaptchaService_PrewarmChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).PrewarmChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_PrewarmChallenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).PrewarmChallenge(ctx, req.(*ChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaptchaService_GetChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChallengeHandle)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).GetChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_GetChallenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).GetChallenge(ctx, req.(*ChallengeHandle))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaptchaService_GetChallengeAssets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChallengeAssetsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CaptchaServiceServer).GetChallengeAssets(m, &grpc.GenericServerStream[ChallengeAssetsRequest, AssetChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CaptchaService_GetChallengeAssetsServer = grpc.ServerStreamingServer[AssetChunk]
+
+func _CaptchaService_GetChallengeResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChallengeResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).GetChallengeResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_GetChallengeResult_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).GetChallengeResult(ctx, req.(*ChallengeResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaptchaService_ForwardSolution_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForwardSolutionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).ForwardSolution(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_ForwardSolution_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).ForwardSolution(ctx, req.(*ForwardSolutionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaptchaService_ImportChallenges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportChallengesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).ImportChallenges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_ImportChallenges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).ImportChallenges(ctx, req.(*ImportChallengesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CaptchaService_ServiceDesc is the grpc.ServiceDesc for CaptchaService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -141,6 +405,30 @@ var CaptchaService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "NewChallenge",
 			Handler:    _CaptchaService_NewChallenge_Handler,
 		},
+		{
+			MethodName: "Assess",
+			Handler:    _CaptchaService_Assess_Handler,
+		},
+		{
+			MethodName: "PrewarmChallenge",
+			Handler:    _CaptchaService_PrewarmChallenge_Handler,
+		},
+		{
+			MethodName: "GetChallenge",
+			Handler:    _CaptchaService_GetChallenge_Handler,
+		},
+		{
+			MethodName: "GetChallengeResult",
+			Handler:    _CaptchaService_GetChallengeResult_Handler,
+		},
+		{
+			MethodName: "ForwardSolution",
+			Handler:    _CaptchaService_ForwardSolution_Handler,
+		},
+		{
+			MethodName: "ImportChallenges",
+			Handler:    _CaptchaService_ImportChallenges_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -149,6 +437,11 @@ var CaptchaService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "GetChallengeAssets",
+			Handler:       _CaptchaService_GetChallengeAssets_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "api/captcha/v1/CaptchaV1.proto",
 }
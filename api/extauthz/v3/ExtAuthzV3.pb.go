@@ -0,0 +1,1001 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v6.32.1
+// source: api/extauthz/v3/ExtAuthzV3.proto
+
+// Подмножество API внешней авторизации Envoy (envoy/service/auth/v3/external_auth.proto),
+// достаточное для проверки токена капчи: имена пакета, сервиса и номера полей
+// совпадают с оригиналом, поэтому Envoy и Istio вызывают Check как у своего
+// ext_authz-сервера. Вложенные типы Envoy (config.core.v3, type.v3, google.rpc)
+// повторены здесь с теми же номерами полей: на проводе важны только они.
+
+package v3
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Attributes    *AttributeContext      `protobuf:"bytes,1,opt,name=attributes,proto3" json:"attributes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckRequest) Reset() {
+	*x = CheckRequest{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckRequest) ProtoMessage() {}
+
+func (x *CheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckRequest.ProtoReflect.Descriptor instead.
+func (*CheckRequest) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CheckRequest) GetAttributes() *AttributeContext {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+type AttributeContext struct {
+	state       protoimpl.MessageState    `protogen:"open.v1"`
+	Source      *AttributeContext_Peer    `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Destination *AttributeContext_Peer    `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	Request     *AttributeContext_Request `protobuf:"bytes,4,opt,name=request,proto3" json:"request,omitempty"`
+	// Параметры маршрута из per-route конфигурации фильтра ext_authz
+	// (check_settings.context_extensions): site_key и action защищаемого маршрута
+	ContextExtensions map[string]string `protobuf:"bytes,10,rep,name=context_extensions,json=contextExtensions,proto3" json:"context_extensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *AttributeContext) Reset() {
+	*x = AttributeContext{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttributeContext) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttributeContext) ProtoMessage() {}
+
+func (x *AttributeContext) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttributeContext.ProtoReflect.Descriptor instead.
+func (*AttributeContext) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AttributeContext) GetSource() *AttributeContext_Peer {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *AttributeContext) GetDestination() *AttributeContext_Peer {
+	if x != nil {
+		return x.Destination
+	}
+	return nil
+}
+
+func (x *AttributeContext) GetRequest() *AttributeContext_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *AttributeContext) GetContextExtensions() map[string]string {
+	if x != nil {
+		return x.ContextExtensions
+	}
+	return nil
+}
+
+// config.core.v3.Address, только socket_address
+type Address struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SocketAddress *SocketAddress         `protobuf:"bytes,1,opt,name=socket_address,json=socketAddress,proto3" json:"socket_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Address) Reset() {
+	*x = Address{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Address) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Address) ProtoMessage() {}
+
+func (x *Address) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Address.ProtoReflect.Descriptor instead.
+func (*Address) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Address) GetSocketAddress() *SocketAddress {
+	if x != nil {
+		return x.SocketAddress
+	}
+	return nil
+}
+
+type SocketAddress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	PortValue     uint32                 `protobuf:"varint,3,opt,name=port_value,json=portValue,proto3" json:"port_value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SocketAddress) Reset() {
+	*x = SocketAddress{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SocketAddress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SocketAddress) ProtoMessage() {}
+
+func (x *SocketAddress) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SocketAddress.ProtoReflect.Descriptor instead.
+func (*SocketAddress) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SocketAddress) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *SocketAddress) GetPortValue() uint32 {
+	if x != nil {
+		return x.PortValue
+	}
+	return 0
+}
+
+// google.rpc.Status: code 0 — запрос разрешен, иначе запрещен
+type RpcStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RpcStatus) Reset() {
+	*x = RpcStatus{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RpcStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RpcStatus) ProtoMessage() {}
+
+func (x *RpcStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RpcStatus.ProtoReflect.Descriptor instead.
+func (*RpcStatus) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RpcStatus) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *RpcStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// config.core.v3.HeaderValue
+type HeaderValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeaderValue) Reset() {
+	*x = HeaderValue{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeaderValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeaderValue) ProtoMessage() {}
+
+func (x *HeaderValue) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeaderValue.ProtoReflect.Descriptor instead.
+func (*HeaderValue) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *HeaderValue) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *HeaderValue) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// config.core.v3.HeaderValueOption
+type HeaderValueOption struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Header *HeaderValue           `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	// HeaderAppendAction: 2 — OVERWRITE_IF_EXISTS_OR_ADD
+	AppendAction  int32 `protobuf:"varint,3,opt,name=append_action,json=appendAction,proto3" json:"append_action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeaderValueOption) Reset() {
+	*x = HeaderValueOption{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeaderValueOption) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeaderValueOption) ProtoMessage() {}
+
+func (x *HeaderValueOption) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeaderValueOption.ProtoReflect.Descriptor instead.
+func (*HeaderValueOption) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HeaderValueOption) GetHeader() *HeaderValue {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *HeaderValueOption) GetAppendAction() int32 {
+	if x != nil {
+		return x.AppendAction
+	}
+	return 0
+}
+
+type DeniedHttpResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// type.v3.HttpStatus: код ответа клиенту
+	Status        *HttpStatus          `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Headers       []*HeaderValueOption `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body          string               `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeniedHttpResponse) Reset() {
+	*x = DeniedHttpResponse{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeniedHttpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeniedHttpResponse) ProtoMessage() {}
+
+func (x *DeniedHttpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeniedHttpResponse.ProtoReflect.Descriptor instead.
+func (*DeniedHttpResponse) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeniedHttpResponse) GetStatus() *HttpStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *DeniedHttpResponse) GetHeaders() []*HeaderValueOption {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *DeniedHttpResponse) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+type HttpStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// type.v3.StatusCode — числовой HTTP-код
+	Code          int32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HttpStatus) Reset() {
+	*x = HttpStatus{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HttpStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HttpStatus) ProtoMessage() {}
+
+func (x *HttpStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HttpStatus.ProtoReflect.Descriptor instead.
+func (*HttpStatus) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *HttpStatus) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+type OkHttpResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Заголовки, которые Envoy добавит к запросу перед отправкой в upstream
+	Headers []*HeaderValueOption `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	// Заголовки, которые Envoy удалит из запроса (токен не уходит в upstream)
+	HeadersToRemove []string `protobuf:"bytes,5,rep,name=headers_to_remove,json=headersToRemove,proto3" json:"headers_to_remove,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *OkHttpResponse) Reset() {
+	*x = OkHttpResponse{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OkHttpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OkHttpResponse) ProtoMessage() {}
+
+func (x *OkHttpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OkHttpResponse.ProtoReflect.Descriptor instead.
+func (*OkHttpResponse) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *OkHttpResponse) GetHeaders() []*HeaderValueOption {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *OkHttpResponse) GetHeadersToRemove() []string {
+	if x != nil {
+		return x.HeadersToRemove
+	}
+	return nil
+}
+
+type CheckResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Status *RpcStatus             `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Types that are valid to be assigned to HttpResponse:
+	//
+	//	*CheckResponse_DeniedResponse
+	//	*CheckResponse_OkResponse
+	HttpResponse  isCheckResponse_HttpResponse `protobuf_oneof:"http_response"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckResponse) Reset() {
+	*x = CheckResponse{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckResponse) ProtoMessage() {}
+
+func (x *CheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckResponse.ProtoReflect.Descriptor instead.
+func (*CheckResponse) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CheckResponse) GetStatus() *RpcStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *CheckResponse) GetHttpResponse() isCheckResponse_HttpResponse {
+	if x != nil {
+		return x.HttpResponse
+	}
+	return nil
+}
+
+func (x *CheckResponse) GetDeniedResponse() *DeniedHttpResponse {
+	if x != nil {
+		if x, ok := x.HttpResponse.(*CheckResponse_DeniedResponse); ok {
+			return x.DeniedResponse
+		}
+	}
+	return nil
+}
+
+func (x *CheckResponse) GetOkResponse() *OkHttpResponse {
+	if x != nil {
+		if x, ok := x.HttpResponse.(*CheckResponse_OkResponse); ok {
+			return x.OkResponse
+		}
+	}
+	return nil
+}
+
+type isCheckResponse_HttpResponse interface {
+	isCheckResponse_HttpResponse()
+}
+
+type CheckResponse_DeniedResponse struct {
+	DeniedResponse *DeniedHttpResponse `protobuf:"bytes,2,opt,name=denied_response,json=deniedResponse,proto3,oneof"`
+}
+
+type CheckResponse_OkResponse struct {
+	OkResponse *OkHttpResponse `protobuf:"bytes,3,opt,name=ok_response,json=okResponse,proto3,oneof"`
+}
+
+func (*CheckResponse_DeniedResponse) isCheckResponse_HttpResponse() {}
+
+func (*CheckResponse_OkResponse) isCheckResponse_HttpResponse() {}
+
+type AttributeContext_Peer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       *Address               `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Service       string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	Principal     string                 `protobuf:"bytes,4,opt,name=principal,proto3" json:"principal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttributeContext_Peer) Reset() {
+	*x = AttributeContext_Peer{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttributeContext_Peer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttributeContext_Peer) ProtoMessage() {}
+
+func (x *AttributeContext_Peer) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttributeContext_Peer.ProtoReflect.Descriptor instead.
+func (*AttributeContext_Peer) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *AttributeContext_Peer) GetAddress() *Address {
+	if x != nil {
+		return x.Address
+	}
+	return nil
+}
+
+func (x *AttributeContext_Peer) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *AttributeContext_Peer) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+type AttributeContext_Request struct {
+	state         protoimpl.MessageState        `protogen:"open.v1"`
+	Http          *AttributeContext_HttpRequest `protobuf:"bytes,2,opt,name=http,proto3" json:"http,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttributeContext_Request) Reset() {
+	*x = AttributeContext_Request{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttributeContext_Request) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttributeContext_Request) ProtoMessage() {}
+
+func (x *AttributeContext_Request) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttributeContext_Request.ProtoReflect.Descriptor instead.
+func (*AttributeContext_Request) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{1, 1}
+}
+
+func (x *AttributeContext_Request) GetHttp() *AttributeContext_HttpRequest {
+	if x != nil {
+		return x.Http
+	}
+	return nil
+}
+
+type AttributeContext_HttpRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Method string                 `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
+	// Заголовки запроса; имена в нижнем регистре
+	Headers       map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Path          string            `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	Host          string            `protobuf:"bytes,5,opt,name=host,proto3" json:"host,omitempty"`
+	Scheme        string            `protobuf:"bytes,6,opt,name=scheme,proto3" json:"scheme,omitempty"`
+	Protocol      string            `protobuf:"bytes,10,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttributeContext_HttpRequest) Reset() {
+	*x = AttributeContext_HttpRequest{}
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttributeContext_HttpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttributeContext_HttpRequest) ProtoMessage() {}
+
+func (x *AttributeContext_HttpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttributeContext_HttpRequest.ProtoReflect.Descriptor instead.
+func (*AttributeContext_HttpRequest) Descriptor() ([]byte, []int) {
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP(), []int{1, 2}
+}
+
+func (x *AttributeContext_HttpRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AttributeContext_HttpRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *AttributeContext_HttpRequest) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *AttributeContext_HttpRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *AttributeContext_HttpRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *AttributeContext_HttpRequest) GetScheme() string {
+	if x != nil {
+		return x.Scheme
+	}
+	return ""
+}
+
+func (x *AttributeContext_HttpRequest) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+var File_api_extauthz_v3_ExtAuthzV3_proto protoreflect.FileDescriptor
+
+const file_api_extauthz_v3_ExtAuthzV3_proto_rawDesc = "" +
+	"\n" +
+	" api/extauthz/v3/ExtAuthzV3.proto\x12\x15envoy.service.auth.v3\"W\n" +
+	"\fCheckRequest\x12G\n" +
+	"\n" +
+	"attributes\x18\x01 \x01(\v2'.envoy.service.auth.v3.AttributeContextR\n" +
+	"attributes\"\xa2\a\n" +
+	"\x10AttributeContext\x12D\n" +
+	"\x06source\x18\x01 \x01(\v2,.envoy.service.auth.v3.AttributeContext.PeerR\x06source\x12N\n" +
+	"\vdestination\x18\x02 \x01(\v2,.envoy.service.auth.v3.AttributeContext.PeerR\vdestination\x12I\n" +
+	"\arequest\x18\x04 \x01(\v2/.envoy.service.auth.v3.AttributeContext.RequestR\arequest\x12m\n" +
+	"\x12context_extensions\x18\n" +
+	" \x03(\v2>.envoy.service.auth.v3.AttributeContext.ContextExtensionsEntryR\x11contextExtensions\x1ax\n" +
+	"\x04Peer\x128\n" +
+	"\aaddress\x18\x01 \x01(\v2\x1e.envoy.service.auth.v3.AddressR\aaddress\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x1c\n" +
+	"\tprincipal\x18\x04 \x01(\tR\tprincipal\x1aR\n" +
+	"\aRequest\x12G\n" +
+	"\x04http\x18\x02 \x01(\v23.envoy.service.auth.v3.AttributeContext.HttpRequestR\x04http\x1a\xa9\x02\n" +
+	"\vHttpRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06method\x18\x02 \x01(\tR\x06method\x12Z\n" +
+	"\aheaders\x18\x03 \x03(\v2@.envoy.service.auth.v3.AttributeContext.HttpRequest.HeadersEntryR\aheaders\x12\x12\n" +
+	"\x04path\x18\x04 \x01(\tR\x04path\x12\x12\n" +
+	"\x04host\x18\x05 \x01(\tR\x04host\x12\x16\n" +
+	"\x06scheme\x18\x06 \x01(\tR\x06scheme\x12\x1a\n" +
+	"\bprotocol\x18\n" +
+	" \x01(\tR\bprotocol\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aD\n" +
+	"\x16ContextExtensionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"V\n" +
+	"\aAddress\x12K\n" +
+	"\x0esocket_address\x18\x01 \x01(\v2$.envoy.service.auth.v3.SocketAddressR\rsocketAddress\"H\n" +
+	"\rSocketAddress\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x1d\n" +
+	"\n" +
+	"port_value\x18\x03 \x01(\rR\tportValue\"9\n" +
+	"\tRpcStatus\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"5\n" +
+	"\vHeaderValue\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"t\n" +
+	"\x11HeaderValueOption\x12:\n" +
+	"\x06header\x18\x01 \x01(\v2\".envoy.service.auth.v3.HeaderValueR\x06header\x12#\n" +
+	"\rappend_action\x18\x03 \x01(\x05R\fappendAction\"\xa7\x01\n" +
+	"\x12DeniedHttpResponse\x129\n" +
+	"\x06status\x18\x01 \x01(\v2!.envoy.service.auth.v3.HttpStatusR\x06status\x12B\n" +
+	"\aheaders\x18\x02 \x03(\v2(.envoy.service.auth.v3.HeaderValueOptionR\aheaders\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\tR\x04body\" \n" +
+	"\n" +
+	"HttpStatus\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\"\x86\x01\n" +
+	"\x0eOkHttpResponse\x12B\n" +
+	"\aheaders\x18\x02 \x03(\v2(.envoy.service.auth.v3.HeaderValueOptionR\aheaders\x12*\n" +
+	"\x11headers_to_remove\x18\x05 \x03(\tR\x0fheadersToRemoveJ\x04\b\x01\x10\x02\"\xfa\x01\n" +
+	"\rCheckResponse\x128\n" +
+	"\x06status\x18\x01 \x01(\v2 .envoy.service.auth.v3.RpcStatusR\x06status\x12T\n" +
+	"\x0fdenied_response\x18\x02 \x01(\v2).envoy.service.auth.v3.DeniedHttpResponseH\x00R\x0edeniedResponse\x12H\n" +
+	"\vok_response\x18\x03 \x01(\v2%.envoy.service.auth.v3.OkHttpResponseH\x00R\n" +
+	"okResponseB\x0f\n" +
+	"\rhttp_response2e\n" +
+	"\rAuthorization\x12T\n" +
+	"\x05Check\x12#.envoy.service.auth.v3.CheckRequest\x1a$.envoy.service.auth.v3.CheckResponse\"\x00B\x12Z\x10./pb/extauthz/v3b\x06proto3"
+
+var (
+	file_api_extauthz_v3_ExtAuthzV3_proto_rawDescOnce sync.Once
+	file_api_extauthz_v3_ExtAuthzV3_proto_rawDescData []byte
+)
+
+func file_api_extauthz_v3_ExtAuthzV3_proto_rawDescGZIP() []byte {
+	file_api_extauthz_v3_ExtAuthzV3_proto_rawDescOnce.Do(func() {
+		file_api_extauthz_v3_ExtAuthzV3_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_extauthz_v3_ExtAuthzV3_proto_rawDesc), len(file_api_extauthz_v3_ExtAuthzV3_proto_rawDesc)))
+	})
+	return file_api_extauthz_v3_ExtAuthzV3_proto_rawDescData
+}
+
+var file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_api_extauthz_v3_ExtAuthzV3_proto_goTypes = []any{
+	(*CheckRequest)(nil),                 // 0: envoy.service.auth.v3.CheckRequest
+	(*AttributeContext)(nil),             // 1: envoy.service.auth.v3.AttributeContext
+	(*Address)(nil),                      // 2: envoy.service.auth.v3.Address
+	(*SocketAddress)(nil),                // 3: envoy.service.auth.v3.SocketAddress
+	(*RpcStatus)(nil),                    // 4: envoy.service.auth.v3.RpcStatus
+	(*HeaderValue)(nil),                  // 5: envoy.service.auth.v3.HeaderValue
+	(*HeaderValueOption)(nil),            // 6: envoy.service.auth.v3.HeaderValueOption
+	(*DeniedHttpResponse)(nil),           // 7: envoy.service.auth.v3.DeniedHttpResponse
+	(*HttpStatus)(nil),                   // 8: envoy.service.auth.v3.HttpStatus
+	(*OkHttpResponse)(nil),               // 9: envoy.service.auth.v3.OkHttpResponse
+	(*CheckResponse)(nil),                // 10: envoy.service.auth.v3.CheckResponse
+	(*AttributeContext_Peer)(nil),        // 11: envoy.service.auth.v3.AttributeContext.Peer
+	(*AttributeContext_Request)(nil),     // 12: envoy.service.auth.v3.AttributeContext.Request
+	(*AttributeContext_HttpRequest)(nil), // 13: envoy.service.auth.v3.AttributeContext.HttpRequest
+	nil,                                  // 14: envoy.service.auth.v3.AttributeContext.ContextExtensionsEntry
+	nil,                                  // 15: envoy.service.auth.v3.AttributeContext.HttpRequest.HeadersEntry
+}
+var file_api_extauthz_v3_ExtAuthzV3_proto_depIdxs = []int32{
+	1,  // 0: envoy.service.auth.v3.CheckRequest.attributes:type_name -> envoy.service.auth.v3.AttributeContext
+	11, // 1: envoy.service.auth.v3.AttributeContext.source:type_name -> envoy.service.auth.v3.AttributeContext.Peer
+	11, // 2: envoy.service.auth.v3.AttributeContext.destination:type_name -> envoy.service.auth.v3.AttributeContext.Peer
+	12, // 3: envoy.service.auth.v3.AttributeContext.request:type_name -> envoy.service.auth.v3.AttributeContext.Request
+	14, // 4: envoy.service.auth.v3.AttributeContext.context_extensions:type_name -> envoy.service.auth.v3.AttributeContext.ContextExtensionsEntry
+	3,  // 5: envoy.service.auth.v3.Address.socket_address:type_name -> envoy.service.auth.v3.SocketAddress
+	5,  // 6: envoy.service.auth.v3.HeaderValueOption.header:type_name -> envoy.service.auth.v3.HeaderValue
+	8,  // 7: envoy.service.auth.v3.DeniedHttpResponse.status:type_name -> envoy.service.auth.v3.HttpStatus
+	6,  // 8: envoy.service.auth.v3.DeniedHttpResponse.headers:type_name -> envoy.service.auth.v3.HeaderValueOption
+	6,  // 9: envoy.service.auth.v3.OkHttpResponse.headers:type_name -> envoy.service.auth.v3.HeaderValueOption
+	4,  // 10: envoy.service.auth.v3.CheckResponse.status:type_name -> envoy.service.auth.v3.RpcStatus
+	7,  // 11: envoy.service.auth.v3.CheckResponse.denied_response:type_name -> envoy.service.auth.v3.DeniedHttpResponse
+	9,  // 12: envoy.service.auth.v3.CheckResponse.ok_response:type_name -> envoy.service.auth.v3.OkHttpResponse
+	2,  // 13: envoy.service.auth.v3.AttributeContext.Peer.address:type_name -> envoy.service.auth.v3.Address
+	13, // 14: envoy.service.auth.v3.AttributeContext.Request.http:type_name -> envoy.service.auth.v3.AttributeContext.HttpRequest
+	15, // 15: envoy.service.auth.v3.AttributeContext.HttpRequest.headers:type_name -> envoy.service.auth.v3.AttributeContext.HttpRequest.HeadersEntry
+	0,  // 16: envoy.service.auth.v3.Authorization.Check:input_type -> envoy.service.auth.v3.CheckRequest
+	10, // 17: envoy.service.auth.v3.Authorization.Check:output_type -> envoy.service.auth.v3.CheckResponse
+	17, // [17:18] is the sub-list for method output_type
+	16, // [16:17] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_api_extauthz_v3_ExtAuthzV3_proto_init() }
+func file_api_extauthz_v3_ExtAuthzV3_proto_init() {
+	if File_api_extauthz_v3_ExtAuthzV3_proto != nil {
+		return
+	}
+	file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes[10].OneofWrappers = []any{
+		(*CheckResponse_DeniedResponse)(nil),
+		(*CheckResponse_OkResponse)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_extauthz_v3_ExtAuthzV3_proto_rawDesc), len(file_api_extauthz_v3_ExtAuthzV3_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_extauthz_v3_ExtAuthzV3_proto_goTypes,
+		DependencyIndexes: file_api_extauthz_v3_ExtAuthzV3_proto_depIdxs,
+		MessageInfos:      file_api_extauthz_v3_ExtAuthzV3_proto_msgTypes,
+	}.Build()
+	File_api_extauthz_v3_ExtAuthzV3_proto = out.File
+	file_api_extauthz_v3_ExtAuthzV3_proto_goTypes = nil
+	file_api_extauthz_v3_ExtAuthzV3_proto_depIdxs = nil
+}
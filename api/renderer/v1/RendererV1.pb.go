@@ -0,0 +1,596 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v6.32.1
+// source: api/renderer/v1/RendererV1.proto
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RenderRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Вид задания: slider-puzzle, slider-rotate или slider-multi
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	// Число фрагментов для slider-multi
+	Pieces int32 `protobuf:"varint,2,opt,name=pieces,proto3" json:"pieces,omitempty"`
+	// Адрес HTTP-сервера инстанса, с которого клиент загрузит картинки;
+	// пустой — картинки встраиваются в HTML как data URI
+	AssetBaseUrl string `protobuf:"bytes,3,opt,name=asset_base_url,json=assetBaseUrl,proto3" json:"asset_base_url,omitempty"`
+	// Картинки загружаются только после одноразового обмена render-токена
+	// по адресу asset_base_url/render/<asset_key>
+	BindRender bool `protobuf:"varint,4,opt,name=bind_render,json=bindRender,proto3" json:"bind_render,omitempty"`
+	// Локаль рынка ("ru", "pt-BR"): по ней выбираются фон и шаблон виджета
+	Locale string `protobuf:"bytes,5,opt,name=locale,proto3" json:"locale,omitempty"`
+	// Схема ответа, которую виджет передает в ClientEvent.data
+	AnswerSchema uint32 `protobuf:"varint,6,opt,name=answer_schema,json=answerSchema,proto3" json:"answer_schema,omitempty"`
+	// Уровень обфускации: full или touch (без приманок-слайдеров)
+	Obfuscation   string `protobuf:"bytes,7,opt,name=obfuscation,proto3" json:"obfuscation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderRequest) Reset() {
+	*x = RenderRequest{}
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderRequest) ProtoMessage() {}
+
+func (x *RenderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderRequest.ProtoReflect.Descriptor instead.
+func (*RenderRequest) Descriptor() ([]byte, []int) {
+	return file_api_renderer_v1_RendererV1_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RenderRequest) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetPieces() int32 {
+	if x != nil {
+		return x.Pieces
+	}
+	return 0
+}
+
+func (x *RenderRequest) GetAssetBaseUrl() string {
+	if x != nil {
+		return x.AssetBaseUrl
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetBindRender() bool {
+	if x != nil {
+		return x.BindRender
+	}
+	return false
+}
+
+func (x *RenderRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetAnswerSchema() uint32 {
+	if x != nil {
+		return x.AnswerSchema
+	}
+	return 0
+}
+
+func (x *RenderRequest) GetObfuscation() string {
+	if x != nil {
+		return x.Obfuscation
+	}
+	return ""
+}
+
+type RenderResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Kind     string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Html     string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+	X        int32                  `protobuf:"varint,3,opt,name=x,proto3" json:"x,omitempty"`
+	Step     float64                `protobuf:"fixed64,4,opt,name=step,proto3" json:"step,omitempty"`
+	Angle    float64                `protobuf:"fixed64,5,opt,name=angle,proto3" json:"angle,omitempty"`
+	Pieces   []*PieceAnswer         `protobuf:"bytes,6,rep,name=pieces,proto3" json:"pieces,omitempty"`
+	Template *Template              `protobuf:"bytes,7,opt,name=template,proto3" json:"template,omitempty"`
+	// Ключ и картинки задания в режиме ленивой загрузки
+	AssetKey string   `protobuf:"bytes,8,opt,name=asset_key,json=assetKey,proto3" json:"asset_key,omitempty"`
+	Assets   []*Asset `protobuf:"bytes,9,rep,name=assets,proto3" json:"assets,omitempty"`
+	// Время этапов отрисовки на рендерере: для логов медленных запросов инстанса
+	Stages *Stages `protobuf:"bytes,10,opt,name=stages,proto3" json:"stages,omitempty"`
+	// Размер исходного изображения: по нему калибруется ответ виджета
+	Width         int32 `protobuf:"varint,11,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32 `protobuf:"varint,12,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderResponse) Reset() {
+	*x = RenderResponse{}
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderResponse) ProtoMessage() {}
+
+func (x *RenderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderResponse.ProtoReflect.Descriptor instead.
+func (*RenderResponse) Descriptor() ([]byte, []int) {
+	return file_api_renderer_v1_RendererV1_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RenderResponse) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *RenderResponse) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
+func (x *RenderResponse) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *RenderResponse) GetStep() float64 {
+	if x != nil {
+		return x.Step
+	}
+	return 0
+}
+
+func (x *RenderResponse) GetAngle() float64 {
+	if x != nil {
+		return x.Angle
+	}
+	return 0
+}
+
+func (x *RenderResponse) GetPieces() []*PieceAnswer {
+	if x != nil {
+		return x.Pieces
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetTemplate() *Template {
+	if x != nil {
+		return x.Template
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetAssetKey() string {
+	if x != nil {
+		return x.AssetKey
+	}
+	return ""
+}
+
+func (x *RenderResponse) GetAssets() []*Asset {
+	if x != nil {
+		return x.Assets
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetStages() *Stages {
+	if x != nil {
+		return x.Stages
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *RenderResponse) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type Stages struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RenderUs      int64                  `protobuf:"varint,1,opt,name=render_us,json=renderUs,proto3" json:"render_us,omitempty"`
+	EncodeUs      int64                  `protobuf:"varint,2,opt,name=encode_us,json=encodeUs,proto3" json:"encode_us,omitempty"`
+	TemplateUs    int64                  `protobuf:"varint,3,opt,name=template_us,json=templateUs,proto3" json:"template_us,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stages) Reset() {
+	*x = Stages{}
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stages) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stages) ProtoMessage() {}
+
+func (x *Stages) ProtoReflect() protoreflect.Message {
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stages.ProtoReflect.Descriptor instead.
+func (*Stages) Descriptor() ([]byte, []int) {
+	return file_api_renderer_v1_RendererV1_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Stages) GetRenderUs() int64 {
+	if x != nil {
+		return x.RenderUs
+	}
+	return 0
+}
+
+func (x *Stages) GetEncodeUs() int64 {
+	if x != nil {
+		return x.EncodeUs
+	}
+	return 0
+}
+
+func (x *Stages) GetTemplateUs() int64 {
+	if x != nil {
+		return x.TemplateUs
+	}
+	return 0
+}
+
+type PieceAnswer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	X             int32                  `protobuf:"varint,2,opt,name=x,proto3" json:"x,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PieceAnswer) Reset() {
+	*x = PieceAnswer{}
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PieceAnswer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PieceAnswer) ProtoMessage() {}
+
+func (x *PieceAnswer) ProtoReflect() protoreflect.Message {
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PieceAnswer.ProtoReflect.Descriptor instead.
+func (*PieceAnswer) Descriptor() ([]byte, []int) {
+	return file_api_renderer_v1_RendererV1_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PieceAnswer) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PieceAnswer) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+type Template struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Locale        string                 `protobuf:"bytes,3,opt,name=locale,proto3" json:"locale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Template) Reset() {
+	*x = Template{}
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Template) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Template) ProtoMessage() {}
+
+func (x *Template) ProtoReflect() protoreflect.Message {
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Template.ProtoReflect.Descriptor instead.
+func (*Template) Descriptor() ([]byte, []int) {
+	return file_api_renderer_v1_RendererV1_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Template) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Template) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Template) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+type Asset struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Mime          string                 `protobuf:"bytes,2,opt,name=mime,proto3" json:"mime,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Asset) Reset() {
+	*x = Asset{}
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Asset) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Asset) ProtoMessage() {}
+
+func (x *Asset) ProtoReflect() protoreflect.Message {
+	mi := &file_api_renderer_v1_RendererV1_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Asset.ProtoReflect.Descriptor instead.
+func (*Asset) Descriptor() ([]byte, []int) {
+	return file_api_renderer_v1_RendererV1_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Asset) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Asset) GetMime() string {
+	if x != nil {
+		return x.Mime
+	}
+	return ""
+}
+
+func (x *Asset) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_api_renderer_v1_RendererV1_proto protoreflect.FileDescriptor
+
+const file_api_renderer_v1_RendererV1_proto_rawDesc = "" +
+	"\n" +
+	" api/renderer/v1/RendererV1.proto\x12\vrenderer.v1\"\xe1\x01\n" +
+	"\rRenderRequest\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06pieces\x18\x02 \x01(\x05R\x06pieces\x12$\n" +
+	"\x0easset_base_url\x18\x03 \x01(\tR\fassetBaseUrl\x12\x1f\n" +
+	"\vbind_render\x18\x04 \x01(\bR\n" +
+	"bindRender\x12\x16\n" +
+	"\x06locale\x18\x05 \x01(\tR\x06locale\x12#\n" +
+	"\ranswer_schema\x18\x06 \x01(\rR\fanswerSchema\x12 \n" +
+	"\vobfuscation\x18\a \x01(\tR\vobfuscation\"\xf9\x02\n" +
+	"\x0eRenderResponse\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x12\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html\x12\f\n" +
+	"\x01x\x18\x03 \x01(\x05R\x01x\x12\x12\n" +
+	"\x04step\x18\x04 \x01(\x01R\x04step\x12\x14\n" +
+	"\x05angle\x18\x05 \x01(\x01R\x05angle\x120\n" +
+	"\x06pieces\x18\x06 \x03(\v2\x18.renderer.v1.PieceAnswerR\x06pieces\x121\n" +
+	"\btemplate\x18\a \x01(\v2\x15.renderer.v1.TemplateR\btemplate\x12\x1b\n" +
+	"\tasset_key\x18\b \x01(\tR\bassetKey\x12*\n" +
+	"\x06assets\x18\t \x03(\v2\x12.renderer.v1.AssetR\x06assets\x12+\n" +
+	"\x06stages\x18\n" +
+	" \x01(\v2\x13.renderer.v1.StagesR\x06stages\x12\x14\n" +
+	"\x05width\x18\v \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\f \x01(\x05R\x06height\"c\n" +
+	"\x06Stages\x12\x1b\n" +
+	"\trender_us\x18\x01 \x01(\x03R\brenderUs\x12\x1b\n" +
+	"\tencode_us\x18\x02 \x01(\x03R\bencodeUs\x12\x1f\n" +
+	"\vtemplate_us\x18\x03 \x01(\x03R\n" +
+	"templateUs\"+\n" +
+	"\vPieceAnswer\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\f\n" +
+	"\x01x\x18\x02 \x01(\x05R\x01x\"P\n" +
+	"\bTemplate\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x16\n" +
+	"\x06locale\x18\x03 \x01(\tR\x06locale\"C\n" +
+	"\x05Asset\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04mime\x18\x02 \x01(\tR\x04mime\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data2V\n" +
+	"\x0fRendererService\x12C\n" +
+	"\x06Render\x12\x1a.renderer.v1.RenderRequest\x1a\x1b.renderer.v1.RenderResponse\"\x00B\x12Z\x10./pb/renderer/v1b\x06proto3"
+
+var (
+	file_api_renderer_v1_RendererV1_proto_rawDescOnce sync.Once
+	file_api_renderer_v1_RendererV1_proto_rawDescData []byte
+)
+
+func file_api_renderer_v1_RendererV1_proto_rawDescGZIP() []byte {
+	file_api_renderer_v1_RendererV1_proto_rawDescOnce.Do(func() {
+		file_api_renderer_v1_RendererV1_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_renderer_v1_RendererV1_proto_rawDesc), len(file_api_renderer_v1_RendererV1_proto_rawDesc)))
+	})
+	return file_api_renderer_v1_RendererV1_proto_rawDescData
+}
+
+var file_api_renderer_v1_RendererV1_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_api_renderer_v1_RendererV1_proto_goTypes = []any{
+	(*RenderRequest)(nil),  // 0: renderer.v1.RenderRequest
+	(*RenderResponse)(nil), // 1: renderer.v1.RenderResponse
+	(*Stages)(nil),         // 2: renderer.v1.Stages
+	(*PieceAnswer)(nil),    // 3: renderer.v1.PieceAnswer
+	(*Template)(nil),       // 4: renderer.v1.Template
+	(*Asset)(nil),          // 5: renderer.v1.Asset
+}
+var file_api_renderer_v1_RendererV1_proto_depIdxs = []int32{
+	3, // 0: renderer.v1.RenderResponse.pieces:type_name -> renderer.v1.PieceAnswer
+	4, // 1: renderer.v1.RenderResponse.template:type_name -> renderer.v1.Template
+	5, // 2: renderer.v1.RenderResponse.assets:type_name -> renderer.v1.Asset
+	2, // 3: renderer.v1.RenderResponse.stages:type_name -> renderer.v1.Stages
+	0, // 4: renderer.v1.RendererService.Render:input_type -> renderer.v1.RenderRequest
+	1, // 5: renderer.v1.RendererService.Render:output_type -> renderer.v1.RenderResponse
+	5, // [5:6] is the sub-list for method output_type
+	4, // [4:5] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_api_renderer_v1_RendererV1_proto_init() }
+func file_api_renderer_v1_RendererV1_proto_init() {
+	if File_api_renderer_v1_RendererV1_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_renderer_v1_RendererV1_proto_rawDesc), len(file_api_renderer_v1_RendererV1_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_renderer_v1_RendererV1_proto_goTypes,
+		DependencyIndexes: file_api_renderer_v1_RendererV1_proto_depIdxs,
+		MessageInfos:      file_api_renderer_v1_RendererV1_proto_msgTypes,
+	}.Build()
+	File_api_renderer_v1_RendererV1_proto = out.File
+	file_api_renderer_v1_RendererV1_proto_goTypes = nil
+	file_api_renderer_v1_RendererV1_proto_depIdxs = nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/logging"
+)
+
+// adminConfig — доступ к /admin/*: ручки меняют списки IP, настройки и
+// шаблоны и делят HTTP-сервер с публичными /assets, /render и /siteverify
+type adminConfig struct {
+	// Tokens — администратор -> токен (ADMIN_TOKENS="alice=...,deploy=...");
+	// запрос предъявляет токен в заголовке Authorization: Bearer. Без токенов
+	// административные ручки выключены.
+	Tokens map[string]string
+}
+
+func (c adminConfig) enabled() bool { return len(c.Tokens) > 0 }
+
+// adminPrincipalKey — ключ контекста с именем администратора из ADMIN_TOKENS
+type adminPrincipalKey struct{}
+
+// principal — администратор, которому принадлежит токен запроса. Сравниваются
+// все токены за постоянное время, чтобы время ответа не выдавало совпадение.
+func (c adminConfig) principal(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	found := ""
+	for name, t := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// authorize пропускает к ручке только администраторов с токеном и запоминает,
+// кто это: имя попадает в журналы аудита вместо заголовков клиента
+func (c adminConfig) authorize(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.enabled() {
+			httperr.Write(w, r, http.StatusForbidden, "admin API is disabled (ADMIN_TOKENS)")
+			return
+		}
+		name, ok := c.principal(r)
+		if !ok {
+			log.Printf("Rejected unauthenticated admin request %s %s from %s", r.Method, r.URL.Path, logging.IP(remoteIP(r)))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="captcha-admin"`)
+			httperr.Write(w, r, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminPrincipalKey{}, name)))
+	})
+}
+
+// adminAuthor — автор изменения для журнала аудита: администратор, чей токен
+// предъявлен в запросе
+func adminAuthor(r *http.Request) string {
+	if name, ok := r.Context().Value(adminPrincipalKey{}).(string); ok {
+		return name
+	}
+	return r.RemoteAddr
+}
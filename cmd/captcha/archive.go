@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"captcha-service/internal/archive"
+	"captcha-service/internal/audit"
+)
+
+// archiveConfig — выгрузка журнала заданий (AUDIT_LOG_FILE) в объектное
+// хранилище; включается ARCHIVE_URL
+type archiveConfig struct {
+	Store archive.Config
+	// Interval — как часто журнал ротируется и проверяются сегменты
+	Interval time.Duration
+	// Retention — сколько сегмент хранится на диске после последней записи в него
+	Retention time.Duration
+	// PurgeDelay — сколько выгруженный сегмент хранится до удаления
+	PurgeDelay time.Duration
+}
+
+func (c archiveConfig) enabled() bool { return c.Store.URL != "" }
+
+// archiveAudit ротирует журнал заданий и выгружает устаревшие сегменты до
+// отмены ctx
+func (s *captchaService) archiveAudit(ctx context.Context, path string, a *archive.Archiver, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.auditLog.Rotate(); err != nil {
+			log.Printf("Failed to rotate audit log: %v", err)
+		}
+		res, err := a.Sweep(ctx, audit.SegmentPattern(path), s.link.instanceID())
+		archivedSegments.Add(int64(res.Archived), "archived")
+		archivedSegments.Add(int64(res.Purged), "purged")
+		archivedSegments.Add(int64(res.Failed), "failed")
+		if err != nil {
+			log.Printf("Failed to archive audit log segments: %v", err)
+		}
+		if res.Archived > 0 || res.Purged > 0 {
+			log.Printf("Archived %d audit log segments (%d bytes compressed), purged %d", res.Archived, res.Bytes, res.Purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/audit"
+)
+
+// resultTokenTTL — сколько бэкенд сайта может ждать с вызовом Assess
+const resultTokenTTL = 2 * time.Minute
+
+// verdict — результат проверки решения, сохраненный под одноразовым токеном
+type verdict struct {
+	ChallengeID string
+	SiteKey     string
+	Action      string
+	// Confidence — уверенность до применения порога: Assess применяет актуальный порог сам
+	Confidence int32
+	// Threshold — порог действия при проверке; нужен только JWT результата
+	Threshold int32
+	// Hostname — хост страницы из ClientContext задания
+	Hostname string
+	// IssuedAt — время выдачи токена (iat интроспекции)
+	IssuedAt time.Time
+	// Region — регион инстанса, проверившего решение
+	Region string
+}
+
+// issueToken сохраняет результат проверки и возвращает токен вида "<challenge_id>.<secret>";
+// префикс позволяет балансеру направить Assess на инстанс, выдавший задание.
+// jwt — тот же результат для офлайн-проверки; пусто без RESULT_JWT_KEY.
+func (s *captchaService) issueToken(v verdict) (token, jwt string) {
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	token = v.ChallengeID + "." + base64.RawURLEncoding.EncodeToString(secret)
+	v.IssuedAt = time.Now()
+	v.Region = s.region
+	s.results.set(token, v, jitteredTTL(resultTokenTTL))
+	s.rememberForIntrospection(token, v)
+	return token, s.resultJWT.sign(v)
+}
+
+// passWithoutChallenge засчитывает задание spec без отрисовки (аттестация,
+// невидимый режим, allowlist) и возвращает токен результата для Assess и его JWT
+func (s *captchaService) passWithoutChallenge(spec challengeSpec, confidence int32) (token, jwt string) {
+	s.audit(audit.Record{
+		Event:       audit.EventPassed,
+		ChallengeID: spec.id,
+		SiteKey:     spec.siteKey,
+		Action:      spec.action,
+		Client:      auditClient(spec.clientIP),
+		Confidence:  confidence,
+	})
+	s.rememberOutcome(spec.id, outcome{
+		SiteKey:    spec.siteKey,
+		Action:     spec.action,
+		Confidence: confidence,
+		VerifiedAt: time.Now(),
+	})
+	return s.issueToken(verdict{
+		ChallengeID: spec.id,
+		SiteKey:     spec.siteKey,
+		Action:      spec.action,
+		Confidence:  confidence,
+		Threshold:   int32(spec.threshold),
+		Hostname:    spec.hostname,
+	})
+}
+
+// Assess применяет порог политики действия к результату и возвращает allow/challenge/deny.
+// Токен одноразовый: повторный Assess получит DENY.
+func (s *captchaService) Assess(ctx context.Context, req *captchapb.AssessRequest) (*captchapb.AssessResponse, error) {
+	res, reason := s.assess(req)
+	res.Reason = reason
+	log.Printf("Assess for challenge %s: %s (%s)", tokenChallengeID(req.GetToken()), res.Decision, reason)
+	return res, nil
+}
+
+func (s *captchaService) assess(req *captchapb.AssessRequest) (*captchapb.AssessResponse, string) {
+	res, reason := s.assessToken(req)
+	s.audit(audit.Record{
+		Event:       audit.EventAssessed,
+		ChallengeID: tokenChallengeID(req.GetToken()),
+		SiteKey:     req.GetSiteKey(),
+		Action:      req.GetAction(),
+		Confidence:  res.GetConfidencePercent(),
+		Result:      strings.ToLower(res.GetDecision().String()),
+	})
+	return res, reason
+}
+
+func (s *captchaService) assessToken(req *captchapb.AssessRequest) (*captchapb.AssessResponse, string) {
+	deny := &captchapb.AssessResponse{Decision: captchapb.AssessResponse_DENY, Action: req.GetAction()}
+
+	// consume: из двух одновременных Assess одного токена ALLOW получит только один
+	v, err := s.results.consume(req.GetToken())
+	if err != nil {
+		return deny, "token is invalid, expired or already used"
+	}
+
+	deny.ConfidencePercent = v.Confidence
+	deny.Action = v.Action
+	deny.Hostname = v.Hostname
+	deny.Region = v.Region
+	if s.sharedChallenge(v.ChallengeID) {
+		return deny, "challenge was answered from more than one stream"
+	}
+	if req.GetSiteKey() != "" && req.GetSiteKey() != v.SiteKey {
+		return deny, "token was issued for another site"
+	}
+	if req.GetAction() != v.Action {
+		return deny, fmt.Sprintf("action mismatch: expected %q, token has %q", req.GetAction(), v.Action)
+	}
+
+	// Порог берется из текущей политики, чтобы изменения применялись централизованно
+	threshold := s.scoreThreshold(v.SiteKey, v.Action)
+	res := &captchapb.AssessResponse{
+		ConfidencePercent: v.Confidence,
+		Threshold:         threshold,
+		Action:            v.Action,
+		Hostname:          v.Hostname,
+		Region:            v.Region,
+	}
+	switch {
+	case v.Confidence == 0:
+		res.Decision = captchapb.AssessResponse_DENY
+		return res, "challenge was not solved"
+	case v.Confidence < threshold:
+		res.Decision = captchapb.AssessResponse_CHALLENGE
+		return res, "confidence is below action threshold"
+	}
+	res.Decision = captchapb.AssessResponse_ALLOW
+	return res, "confidence meets action threshold"
+}
+
+// scoreThreshold — действующий порог уверенности политики сайта и действия
+func (s *captchaService) scoreThreshold(siteKey, action string) int32 {
+	return int32(s.policies.Resolve(siteKey, action).ScoreThreshold)
+}
+
+// passed — вердикт для интеграций без разбора уверенности: задание решено и
+// уверенность не ниже порога, то есть Assess ответил бы ALLOW
+func passed(confidence, threshold int32) bool {
+	return confidence > 0 && confidence >= threshold
+}
+
+// tokenChallengeID извлекает challenge_id из токена результата
+func tokenChallengeID(token string) string {
+	id, _, _ := strings.Cut(token, ".")
+	return id
+}
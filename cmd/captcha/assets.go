@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/generator"
+	"captcha-service/internal/httperr"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultAssetChunkSize = 64 << 10
+	maxAssetChunkSize     = 1 << 20
+)
+
+// assetStore хранит картинки заданий для ленивой загрузки виджетом.
+// Картинки живут столько же, сколько задание, и не удаляются при отдаче,
+// чтобы виджет мог перезапросить их на нестабильном соединении.
+type assetStore struct {
+	items *typedStore[[]generator.Asset]
+	// renders — сессии привязанной отрисовки по ключу картинок;
+	// пустая строка — render-токен еще не обменян
+	renders *typedStore[string]
+	mu      sync.Mutex
+}
+
+var (
+	errRenderUnknown  = errors.New("challenge not found or expired")
+	errRenderConsumed = errors.New("challenge was already rendered")
+)
+
+func newAssetStore() *assetStore {
+	return &assetStore{
+		items:   newTypedStore[[]generator.Asset]("assets", defaultExpiration),
+		renders: newTypedStore[string]("renders", defaultExpiration),
+	}
+}
+
+// put сохраняет ассеты в порядке генерации: фон идет первым, чтобы виджет мог рисовать его раньше.
+// bound — картинки отдаются только после обмена render-токена.
+func (a *assetStore) put(key string, assets []generator.Asset, bound bool) {
+	if key == "" {
+		return
+	}
+	a.items.set(key, assets, 0)
+	if bound {
+		a.renders.set(key, "", 0)
+	}
+}
+
+// exchangeRender обменивает render-токен на сессию; второй обмен получает errRenderConsumed
+func (a *assetStore) exchangeRender(key string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v, err := a.renders.get(key)
+	if err != nil {
+		return "", errRenderUnknown
+	}
+	if v != "" {
+		return "", errRenderConsumed
+	}
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	session := hex.EncodeToString(raw)
+	a.renders.set(key, session, 0)
+	return session, nil
+}
+
+// bound сообщает, привязана ли отрисовка картинок key к сессии
+func (a *assetStore) bound(key string) bool {
+	_, err := a.renders.get(key)
+	return err == nil
+}
+
+// authorized проверяет сессию отрисовки; непривязанные картинки доступны всегда
+func (a *assetStore) authorized(key, session string) bool {
+	want, err := a.renders.get(key)
+	if err != nil {
+		return true
+	}
+	return want != "" && subtle.ConstantTimeCompare([]byte(want), []byte(session)) == 1
+}
+
+func (a *assetStore) list(key string) ([]generator.Asset, bool) {
+	assets, err := a.items.get(key)
+	return assets, err == nil
+}
+
+func (a *assetStore) get(key, name string) (generator.Asset, bool) {
+	assets, _ := a.list(key)
+	for _, asset := range assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return generator.Asset{}, false
+}
+
+func (a *assetStore) delete(key string) {
+	if key != "" {
+		a.items.delete(key)
+		a.renders.delete(key)
+	}
+}
+
+// handleAsset — GET /assets/{key}/{name}: картинка задания.
+// С подписью адресов запрос без валидной подписи получает 403 до поиска ассета,
+// чтобы по ответам нельзя было перебирать ключи.
+func (s *captchaService) handleAsset(w http.ResponseWriter, r *http.Request) {
+	key, name := r.PathValue("key"), r.PathValue("name")
+	if s.assetSigner != nil {
+		if err := s.assetSigner.Verify(key, name, r.URL.Query(), time.Now()); err != nil {
+			httperr.Write(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+	if !s.assets.authorized(key, r.URL.Query().Get("rs")) {
+		renderRejections.Inc("no_session")
+		httperr.Write(w, r, http.StatusForbidden, "challenge was not rendered by this widget")
+		return
+	}
+	asset, ok := s.assets.get(key, name)
+	if !ok {
+		httperr.Write(w, r, http.StatusNotFound, "asset not found or expired")
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", asset.MIME)
+	h.Set("Content-Length", strconv.Itoa(len(asset.Data)))
+	// Ключ ассета уникален для задания, поэтому содержимое по адресу не меняется
+	h.Set("Cache-Control", "private, max-age=300, immutable")
+	w.Write(asset.Data)
+}
+
+// GetChallengeAssets стримит картинки задания частями с учетом смещений докачки
+func (s *captchaService) GetChallengeAssets(req *captchapb.ChallengeAssetsRequest, stream captchapb.CaptchaService_GetChallengeAssetsServer) error {
+	sol, found := s.challenges.get(req.GetChallengeId())
+	if !found {
+		return status.Error(codes.NotFound, "challenge not found (expired or already solved)")
+	}
+	if sol.AssetKey == "" {
+		return status.Error(codes.FailedPrecondition, "challenge images are embedded in html")
+	}
+	if s.assets.bound(sol.AssetKey) {
+		// Картинки привязанного задания получает только виджет через /render
+		return status.Error(codes.FailedPrecondition, "challenge images are bound to a widget render session")
+	}
+	assets, ok := s.assets.list(sol.AssetKey)
+	if !ok {
+		return status.Error(codes.NotFound, "challenge assets expired")
+	}
+
+	chunkSize := int(req.GetChunkSize())
+	switch {
+	case chunkSize <= 0:
+		chunkSize = defaultAssetChunkSize
+	case chunkSize > maxAssetChunkSize:
+		chunkSize = maxAssetChunkSize
+	}
+
+	for _, asset := range assets {
+		offset := int(req.GetOffsets()[asset.Name])
+		if offset < 0 || offset > len(asset.Data) {
+			return status.Errorf(codes.OutOfRange, "offset %d is out of range for asset %s of %d bytes", offset, asset.Name, len(asset.Data))
+		}
+		for offset < len(asset.Data) {
+			end := min(offset+chunkSize, len(asset.Data))
+			chunk := &captchapb.AssetChunk{
+				Name:      asset.Name,
+				MimeType:  asset.MIME,
+				TotalSize: int64(len(asset.Data)),
+				Offset:    int64(offset),
+				Data:      asset.Data[offset:end],
+				Last:      end == len(asset.Data),
+			}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			offset = end
+		}
+	}
+	return nil
+}
+
+// handleRender — POST /render/{key}: виджет обменивает render-токен задания на
+// сессию для загрузки картинок. Обмен одноразовый: HTML, собранный ботом и
+// разосланный решателям, отрисуется только у первого из них.
+func (s *captchaService) handleRender(w http.ResponseWriter, r *http.Request) {
+	session, err := s.assets.exchangeRender(r.PathValue("key"))
+	switch {
+	case errors.Is(err, errRenderConsumed):
+		renderRejections.Inc("already_rendered")
+		httperr.Write(w, r, http.StatusGone, err.Error())
+		return
+	case err != nil:
+		renderRejections.Inc("unknown")
+		httperr.Write(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"session": session})
+}
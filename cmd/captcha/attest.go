@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/attest"
+	"captcha-service/internal/iplist"
+	"captcha-service/internal/logging"
+)
+
+// attestReplayTTL — сколько помнится принятый токен аттестации: токены
+// провайдеров живут минуты, повторное предъявление того же токена отклоняется
+const attestReplayTTL = 10 * time.Minute
+
+// attestationConfig — верификаторы аттестации платформы по провайдерам
+type attestationConfig struct {
+	// Verifiers — адреса webhook-верификаторов ("apple-pat=http://...,play-integrity=http://...")
+	Verifiers map[string]string
+	// Timeout ограничивает одну проверку; при сбое клиент получает обычное задание
+	Timeout time.Duration
+	// Confidence — уверенность токена результата, если верификатор ее не сообщил
+	Confidence int
+}
+
+// attestGate пропускает клиентов с подтвержденной аттестацией без задания
+type attestGate struct {
+	registry   *attest.Registry
+	confidence int32
+	// seen — хэши принятых токенов для защиты от повторного предъявления
+	seen *typedStore[struct{}]
+}
+
+// newAttestGate возвращает nil, если ни один провайдер не настроен
+func newAttestGate(cfg attestationConfig) *attestGate {
+	if len(cfg.Verifiers) == 0 {
+		return nil
+	}
+	registry := attest.NewRegistry()
+	for provider, url := range cfg.Verifiers {
+		registry.Register(provider, attest.NewWebhook(url, cfg.Timeout))
+	}
+	log.Printf("Client attestation accepted from providers %v", registry.Providers())
+	return &attestGate{
+		registry:   registry,
+		confidence: int32(min(max(cfg.Confidence, 1), 100)),
+		seen:       newTypedStore[struct{}]("attestation_replay", attestReplayTTL),
+	}
+}
+
+// acceptAttestation проверяет аттестацию из запроса и при успехе выдает токен
+// результата вместо задания. false — задание нужно выдавать как обычно:
+// отклоненная или непроверенная аттестация не является ошибкой запроса.
+func (s *captchaService) acceptAttestation(ctx context.Context, req *captchapb.ChallengeRequest, spec challengeSpec) (*captchapb.ChallengeResponse, bool) {
+	a := req.GetAttestation()
+	// Клиенту из denylist аттестация задание не заменяет
+	if a == nil || spec.listed == iplist.Deny {
+		return nil, false
+	}
+	provider := a.GetProvider()
+	// Имя провайдера приходит от клиента: в метки попадают только подключенные
+	if s.attest == nil {
+		attestations.Inc("unknown", "disabled")
+		return nil, false
+	}
+	res, err := s.attest.registry.Verify(ctx, attest.Request{
+		Provider: provider,
+		Token:    a.GetToken(),
+		SiteKey:  spec.siteKey,
+		Action:   spec.action,
+	})
+	switch {
+	case errors.Is(err, attest.ErrUnknownProvider):
+		attestations.Inc("unknown", "unknown_provider")
+		logging.Debugf(logging.Verification, spec.siteKey, "Attestation from unknown provider %q ignored", provider)
+		return nil, false
+	case errors.Is(err, attest.ErrRejected):
+		attestations.Inc(provider, "rejected")
+		logging.Infof(logging.Verification, spec.siteKey, "Attestation from %s rejected: %v", provider, err)
+		return nil, false
+	case err != nil:
+		attestations.Inc(provider, "error")
+		logging.Warnf(logging.Verification, spec.siteKey, "Failed to verify attestation from %s, falling back to challenge: %v", provider, err)
+		return nil, false
+	}
+	digest := sha256.Sum256(append([]byte(provider+"\x00"), a.GetToken()...))
+	if s.attest.seen.add(hex.EncodeToString(digest[:]), struct{}{}, 0) != nil {
+		attestations.Inc(provider, "replayed")
+		logging.Infof(logging.Verification, spec.siteKey, "Attestation from %s was already used", provider)
+		return nil, false
+	}
+
+	confidence := res.Confidence
+	if confidence == 0 {
+		confidence = s.attest.confidence
+	}
+	attestations.Inc(provider, "accepted")
+	logging.Infof(logging.Verification, spec.siteKey, "Challenge %s skipped: attestation from %s accepted (%s), confidence %d%%",
+		spec.id, provider, res.Subject, confidence)
+	token, jwt := s.passWithoutChallenge(spec, confidence)
+	return &captchapb.ChallengeResponse{ChallengeId: spec.id, Attested: true, Token: token, Jwt: jwt}, true
+}
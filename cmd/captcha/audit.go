@@ -0,0 +1,23 @@
+package main
+
+import (
+	"captcha-service/internal/audit"
+	"captcha-service/internal/logging"
+)
+
+// audit дописывает запись в журнал заданий (AUDIT_LOG_FILE); сбой журнала не
+// влияет на ответ клиенту
+func (s *captchaService) audit(r audit.Record) {
+	if err := s.auditLog.Write(r); err != nil {
+		auditFailures.Inc()
+		logging.Warnf(logging.Verification, r.SiteKey, "Failed to write audit record for challenge %s: %v", r.ChallengeID, err)
+	}
+}
+
+// auditClient — адрес клиента для журнала, обезличенный так же, как в логах
+func auditClient(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return logging.IP(ip).String()
+}
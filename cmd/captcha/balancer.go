@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+	"captcha-service/internal/logging"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// balancerLink — регистрация инстанса в балансере. Heartbeat несет текущее
+// состояние инстанса, а смена состояния (DRAINING) отправляется сразу.
+// Обратный канал стрима балансер использует для пуша конфигурации.
+type balancerLink struct {
+	onLink   func(bool)
+	onConfig func(*balancerpb.InstanceConfig)
+	// dialOpts — дополнительные опции соединения с балансером
+	dialOpts []grpc.DialOption
+	// creds — TLS и токен регистрации (см. authenticate): с ними же инстанс
+	// соединяется с соседями при пересылке решений
+	creds []grpc.DialOption
+	// load дописывает в каждый heartbeat показатели нагрузки инстанса
+	load func(req *balancerpb.RegisterInstanceRequest)
+	// clockSkew — допустимое расхождение часов с балансером; 0 — не проверяется.
+	// clockOff — расхождение сейчас вне допуска (предупреждение уже в логе).
+	clockSkew time.Duration
+	clockOff  bool
+
+	mu     sync.Mutex
+	stream balancerpb.BalancerService_RegisterInstanceClient
+	client balancerpb.BalancerServiceClient
+	req    *balancerpb.RegisterInstanceRequest
+}
+
+func newBalancerLink(host string, port int, onLink func(bool), onConfig func(*balancerpb.InstanceConfig)) *balancerLink {
+	return &balancerLink{
+		onLink:   onLink,
+		onConfig: onConfig,
+		req: &balancerpb.RegisterInstanceRequest{
+			EventType:     balancerpb.RegisterInstanceRequest_READY,
+			InstanceId:    uuid.New().String(),
+			ChallengeType: challengeType,
+			Host:          host,
+			PortNumber:    int32(port),
+
+			ProtocolVersion: balancerpb.ProtocolVersion,
+		},
+	}
+}
+
+// Пауза перед повторной регистрацией после потери связи с балансером:
+// удваивается с каждой неудачей до linkRetryMax
+const (
+	linkRetryMin = time.Second
+	linkRetryMax = 30 * time.Second
+)
+
+// run регистрирует инстанс и шлет heartbeat до отмены ctx, после чего
+// отправляет STOPPED, чтобы балансер убрал инстанс из маршрутизации. Потеряв
+// связь (балансер перезапущен), инстанс регистрируется заново.
+func (l *balancerLink) run(ctx context.Context, addr string) {
+	opts := append(l.credentials(), l.dialOpts...)
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		log.Fatalf("Did not connect to balancer: %v", err)
+	}
+	defer conn.Close()
+	client := balancerpb.NewBalancerServiceClient(conn)
+
+	retry := linkRetryMin
+	for {
+		registered, err := l.session(ctx, client)
+		if ctx.Err() != nil {
+			return
+		}
+		if registered {
+			retry = linkRetryMin
+		}
+		logging.Warnf(logging.Balancer, "", "Lost connection to balancer: %v, registering again in %s", err, retry)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, linkRetryMax)
+	}
+}
+
+// session — одна регистрация: открывает стрим и шлет heartbeat, пока стрим
+// жив. registered — регистрация была отправлена, то есть связь была.
+func (l *balancerLink) session(ctx context.Context, client balancerpb.BalancerServiceClient) (registered bool, err error) {
+	stream, err := client.RegisterInstance(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("failed to open stream: %w", err)
+	}
+
+	logging.Infof(logging.Balancer, "", "Registering instance with ID: %s", l.req.InstanceId)
+	l.mu.Lock()
+	l.stream, l.client = stream, client
+	err = l.sendLocked()
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.stream, l.client = nil, nil
+		l.mu.Unlock()
+	}()
+	if err != nil {
+		return false, fmt.Errorf("failed to send registration message: %w", err)
+	}
+	l.onLink(true)
+	defer l.onLink(false)
+	closed := make(chan error, 1)
+	go func() { closed <- l.receive(stream) }()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := l.setState(balancerpb.RegisterInstanceRequest_STOPPED); err != nil {
+				logging.Warnf(logging.Balancer, "", "Failed to send STOPPED event: %v", err)
+			}
+			stream.CloseSend()
+			return true, nil
+		case err := <-closed:
+			return true, err
+		case <-ticker.C:
+			l.mu.Lock()
+			err := l.sendLocked()
+			l.mu.Unlock()
+			if err != nil {
+				return true, fmt.Errorf("failed to send heartbeat: %w", err)
+			}
+		}
+	}
+}
+
+// registrationToken передает токен регистрации в metadata каждого RPC к балансеру
+type registrationToken string
+
+func (t registrationToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity разрешает токен и без TLS: балансер бывает во внутренней сети
+func (t registrationToken) RequireTransportSecurity() bool { return false }
+
+// authenticate настраивает TLS соединения с балансером и то, чем инстанс
+// подтверждает принадлежность флоту: токеном регистрации и/или клиентским сертификатом
+func (l *balancerLink) authenticate(cfg config) error {
+	if cfg.BalancerTLS {
+		tlsConfig := &tls.Config{}
+		if cfg.BalancerClientCert != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.BalancerClientCert, cfg.BalancerClientKey)
+			if err != nil {
+				return fmt.Errorf("failed to load balancer client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		l.creds = append(l.creds, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else if cfg.BalancerClientCert != "" {
+		return errors.New("BALANCER_CLIENT_CERT requires BALANCER_TLS=true")
+	}
+	if cfg.BalancerToken != "" {
+		l.creds = append(l.creds, grpc.WithPerRPCCredentials(registrationToken(cfg.BalancerToken)))
+	}
+	return nil
+}
+
+// credentials — опции соединения инстанса флота: без BALANCER_TLS соединение открытое
+func (l *balancerLink) credentials() []grpc.DialOption {
+	return append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, l.creds...)
+}
+
+// errNoBalancer — инстанс еще не зарегистрирован в балансере или связь потеряна
+var errNoBalancer = errors.New("not connected to balancer")
+
+// lookupChallenge спрашивает балансер, какой инстанс выдал задание
+func (l *balancerLink) lookupChallenge(ctx context.Context, challengeID string) (*balancerpb.LookupChallengeResponse, error) {
+	l.mu.Lock()
+	client := l.client
+	l.mu.Unlock()
+	if client == nil {
+		return nil, errNoBalancer
+	}
+	return client.LookupChallenge(ctx, &balancerpb.LookupChallengeRequest{ChallengeId: challengeID})
+}
+
+// handoff передает балансеру зашифрованное состояние незавершенных заданий
+func (l *balancerLink) handoff(ctx context.Context, sealed []byte) (*balancerpb.HandoffChallengesResponse, error) {
+	l.mu.Lock()
+	client := l.client
+	l.mu.Unlock()
+	if client == nil {
+		return nil, errNoBalancer
+	}
+	return client.HandoffChallenges(ctx, &balancerpb.HandoffChallengesRequest{InstanceId: l.instanceID(), SealedState: sealed})
+}
+
+// instanceID — ID, под которым инстанс зарегистрирован в балансере
+func (l *balancerLink) instanceID() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.req.InstanceId
+}
+
+// setState меняет состояние инстанса и сразу сообщает о нем балансеру.
+// До регистрации состояние только запоминается и уйдет в первом сообщении.
+func (l *balancerLink) setState(state balancerpb.RegisterInstanceRequest_EventType) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.req.EventType == state {
+		return nil
+	}
+	l.req.EventType = state
+	if l.stream == nil {
+		return nil
+	}
+	return l.sendLocked()
+}
+
+// refresh отправляет внеочередной heartbeat, чтобы балансер сразу увидел
+// изменившиеся показатели нагрузки; до регистрации ничего не делает
+func (l *balancerLink) refresh() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stream == nil {
+		return nil
+	}
+	return l.sendLocked()
+}
+
+func (l *balancerLink) sendLocked() error {
+	l.req.Timestamp = time.Now().Unix()
+	if l.load != nil {
+		l.load(l.req)
+	}
+	return l.stream.Send(l.req)
+}
+
+// checkClock сравнивает часы инстанса со временем ответа балансера. Сроки
+// токенов результата, сессионных cookie и подписанных адресов сравниваются с
+// часами других инстансов, поэтому расхождение сверх допуска — повод для
+// предупреждения оператору.
+func (l *balancerLink) checkClock(timestamp int64) {
+	if timestamp == 0 || l.clockSkew == 0 {
+		return
+	}
+	skew := time.Now().Truncate(time.Second).Sub(time.Unix(timestamp, 0))
+	clockSkewSeconds.Set(int64(skew / time.Second))
+	off := skew > l.clockSkew || skew < -l.clockSkew
+	switch {
+	case off && !l.clockOff:
+		logging.Warnf(logging.Balancer, "", "Instance clock is off by %s from balancer (tolerance %s): check time synchronization", skew, l.clockSkew)
+	case !off && l.clockOff:
+		logging.Infof(logging.Balancer, "", "Instance clock is back within %s of balancer (off by %s)", l.clockSkew, skew)
+	}
+	l.clockOff = off
+}
+
+// receive читает ответы балансера и применяет присланную конфигурацию
+func (l *balancerLink) receive(stream balancerpb.BalancerService_RegisterInstanceClient) error {
+	for {
+		res, err := stream.Recv()
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.FailedPrecondition:
+			logging.Errorf(logging.Balancer, "", "Balancer refused registration: %v", status.Convert(err).Message())
+		}
+		if err != nil {
+			return err
+		}
+		if res.GetStatus() != balancerpb.RegisterInstanceResponse_SUCCESS {
+			logging.Warnf(logging.Balancer, "", "Balancer rejected instance event: %s", res.GetMessage())
+			continue
+		}
+		l.checkClock(res.GetTimestamp())
+		if cfg := res.GetConfig(); cfg != nil && l.onConfig != nil {
+			l.onConfig(cfg)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/policy"
+)
+
+// submission — ответ виджета и то, чем relying party подтверждает привязку
+// задания: сайт, сессия пользователя и IP клиента. region — регион инстанса,
+// получившего ответ, country — страна клиента при отправке (для geo_pin).
+// stream — стрим виджета, из которого пришел ответ (0 — не из стрима).
+type submission struct {
+	data        []byte
+	fingerprint string
+	siteKey     string
+	session     string
+	clientIP    string
+	region      string
+	country     string
+	stream      uint64
+}
+
+// flightKey — ключ схлопывания одновременных проверок: схлопываются только
+// повторы того же ответа с той же привязкой. Иначе чужой ответ (с любыми
+// данными) получил бы результат владельца задания вместе с токеном. Ответы
+// из разных стримов не схлопываются: каждый проходит привязку к стриму.
+func (sub submission) flightKey(challengeID string) string {
+	answer := sha256.Sum256(sub.data)
+	return strings.Join([]string{challengeID, hex.EncodeToString(answer[:]), sub.fingerprint, sub.siteKey, sessionHash(sub.session), sub.clientIP, sub.region, sub.country, strconv.FormatUint(sub.stream, 10)}, "\x00")
+}
+
+// sessionHash — сессия сайта хранится и сравнивается только хэшем
+func sessionHash(session string) string {
+	if session == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(session))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ipBinding — длины префиксов IPv4 и IPv6, к сети которых привязывается
+// задание; 0 — адреса этого семейства не привязываются
+type ipBinding struct {
+	v4, v6 int
+}
+
+// parseIPBinding разбирает BIND_IP_PREFIX: "24,56" (IPv4, IPv6) или "24"
+func parseIPBinding(s string) (ipBinding, error) {
+	if s == "" {
+		return ipBinding{}, nil
+	}
+	v4, v6, _ := strings.Cut(s, ",")
+	var b ipBinding
+	var err error
+	if b.v4, err = strconv.Atoi(strings.TrimSpace(v4)); err != nil || b.v4 < 0 || b.v4 > 32 {
+		return ipBinding{}, fmt.Errorf("invalid IPv4 prefix length %q", v4)
+	}
+	if v6 != "" {
+		if b.v6, err = strconv.Atoi(strings.TrimSpace(v6)); err != nil || b.v6 < 0 || b.v6 > 128 {
+			return ipBinding{}, fmt.Errorf("invalid IPv6 prefix length %q", v6)
+		}
+	}
+	return b, nil
+}
+
+// prefix — сеть клиента ip при выдаче задания; пусто — не привязывается
+func (b ipBinding) prefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := b.v6
+	if addr.Is4() {
+		bits = b.v4
+	}
+	if bits == 0 {
+		return ""
+	}
+	p, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return p.String()
+}
+
+// inPrefix сообщает, что ip из сети prefix, сохраненной при выдаче
+func inPrefix(prefix, ip string) bool {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && p.Contains(addr.Unmap())
+}
+
+// countryCode — код страны (ISO 3166-1 alpha-2) в верхнем регистре;
+// пусто, если код не похож на двухбуквенный
+func countryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return ""
+	}
+	return code
+}
+
+// geoPin — место выдачи, к которому политика pin привязывает проверку:
+// регион инстанса или страна клиента; пустые — не привязано (регион инстанса
+// или страна клиента неизвестны)
+func (s *captchaService) geoPin(pin, country string) (string, string) {
+	switch pin {
+	case policy.GeoPinRegion:
+		return s.region, ""
+	case policy.GeoPinCountry:
+		return "", country
+	}
+	return "", ""
+}
+
+// checkBinding сверяет привязку задания с ответом. Сайт сверяется, если
+// relying party его передал (в строгом режиме — всегда, и ответ без site key
+// на задание сайта отклоняется), сессия, сеть, регион и страна — если задание
+// к ним привязано при выдаче.
+func (s *captchaService) checkBinding(sol solution, sub submission) captchapb.ServerEvent_ChallengeResult_BindingFailure {
+	switch {
+	case sub.siteKey != sol.SiteKey && (sub.siteKey != "" || s.strictBinding):
+		return captchapb.ServerEvent_ChallengeResult_SITE_MISMATCH
+	case sol.Session != "" && subtle.ConstantTimeCompare([]byte(sol.Session), []byte(sessionHash(sub.session))) != 1:
+		return captchapb.ServerEvent_ChallengeResult_SESSION_MISMATCH
+	case sol.IPPrefix != "" && !inPrefix(sol.IPPrefix, sub.clientIP):
+		return captchapb.ServerEvent_ChallengeResult_IP_PREFIX_MISMATCH
+	case sol.Region != "" && sub.region != sol.Region, sol.Country != "" && sub.country != sol.Country:
+		return captchapb.ServerEvent_ChallengeResult_REGION_MISMATCH
+	}
+	return captchapb.ServerEvent_ChallengeResult_NONE
+}
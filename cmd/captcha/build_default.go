@@ -0,0 +1,7 @@
+//go:build !production
+
+package main
+
+// productionBuild — собрано ли с тегом production; в такой сборке отладочные
+// режимы (DEBUG_ANSWERS, DEBUG_CHALLENGES) отклоняются при старте
+const productionBuild = false
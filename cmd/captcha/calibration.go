@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/logging"
+)
+
+const (
+	// maxDevicePixelRatio — devicePixelRatio реальных экранов с учетом
+	// масштаба страницы не превышает нескольких единиц
+	maxDevicePixelRatio = 8
+	// minCalibrationScale и maxCalibrationScale — допустимый масштаб: картинка
+	// на экране не меньше десятой доли и не больше десятикратного размера
+	minCalibrationScale = 0.1
+	maxCalibrationScale = 10
+	// calibrationAspectSlack — насколько пропорции отрисованной картинки могут
+	// отличаться от исходных (округление CSS-пикселей). Искаженная картинка
+	// не переводится одним масштабом.
+	calibrationAspectSlack = 0.05
+)
+
+// errUncalibrated — ответ в физических пикселях на задание без калибровки
+var errUncalibrated = errors.New("answer is in device pixels, but the widget did not calibrate the challenge")
+
+// calibrationScale — масштаб ответа для картинки width×height, отрисованной
+// по калибровке c: пикселей исходного изображения на физический пиксель экрана
+func calibrationScale(c *captchapb.Calibration, width, height int) (float64, error) {
+	if width <= 0 || height <= 0 {
+		return 0, errors.New("challenge has no image size")
+	}
+	w, h, dpr := c.GetRenderedWidth(), c.GetRenderedHeight(), c.GetDevicePixelRatio()
+	// Сравнения записаны так, чтобы NaN не проходил проверку
+	if !(w > 0) || !(h > 0) || !(dpr > 0 && dpr <= maxDevicePixelRatio) {
+		return 0, fmt.Errorf("invalid calibration %gx%g@%g", w, h, dpr)
+	}
+	aspect := (h / w) / (float64(height) / float64(width))
+	if !(math.Abs(aspect-1) <= calibrationAspectSlack) {
+		return 0, fmt.Errorf("rendered %gx%g does not keep the %dx%d aspect ratio", w, h, width, height)
+	}
+	scale := float64(width) / (w * dpr)
+	if !(scale >= minCalibrationScale && scale <= maxCalibrationScale) {
+		return 0, fmt.Errorf("calibration scale %g is out of range", scale)
+	}
+	return scale, nil
+}
+
+// calibrate сохраняет масштаб задания из CALIBRATION. Повторная калибровка
+// (изменился размер окна) заменяет прежнюю.
+func (s *captchaService) calibrate(event *captchapb.ClientEvent) {
+	challengeID := event.GetChallengeId()
+	var siteKey string
+	var scale float64
+	err := s.challenges.update(challengeID, func(sol solution) (solution, error) {
+		siteKey = sol.SiteKey
+		var err error
+		if scale, err = calibrationScale(event.GetCalibration(), sol.Width, sol.Height); err != nil {
+			return sol, err
+		}
+		sol.Scale = scale
+		return sol, nil
+	})
+	switch {
+	case errors.Is(err, errStoreMiss):
+		calibrations.Inc("unknown")
+		logging.Debugf(logging.Verification, "", "Calibration for unknown challenge %s ignored", challengeID)
+	case err != nil:
+		calibrations.Inc("rejected")
+		logging.Warnf(logging.Verification, siteKey, "Calibration for challenge %s rejected: %v", challengeID, err)
+	default:
+		calibrations.Inc("accepted")
+		logging.Debugf(logging.Verification, siteKey, "Challenge %s calibrated: %g image px per device px", challengeID, scale)
+	}
+}
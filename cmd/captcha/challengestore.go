@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"captcha-service/internal/logging"
+)
+
+// tenantSeparator отделяет site key от ID задания в ключе хранилища;
+// ID — UUID и разделителя не содержит
+const tenantSeparator = "|"
+
+// errTenantFull — у тенанта уже максимум незавершенных заданий
+var errTenantFull = errors.New("too many pending challenges for this site key")
+
+// challengeStore хранит ответы выданных заданий в разделах по тенантам (site key).
+// Ключи записей имеют вид "<site key>|<challenge id>", а у каждого тенанта свой
+// лимит незавершенных заданий: поток заданий одного сайта упирается в его лимит
+// и не вытесняет задания других сайтов.
+type challengeStore struct {
+	items *typedStore[solution]
+	// owners — тенант каждого задания: проверки приходят только с ID
+	owners *typedStore[string]
+	// maxPerTenant — лимит незавершенных заданий тенанта; 0 — без ограничения
+	maxPerTenant int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newChallengeStore(ttl time.Duration, maxPerTenant int) *challengeStore {
+	st := &challengeStore{
+		items:        newTypedStore[solution]("challenges", ttl),
+		owners:       newTypedStore[string]("challenge_owners", ttl),
+		maxPerTenant: maxPerTenant,
+		counts:       make(map[string]int),
+	}
+	// Срабатывает и при явном удалении, и при истечении записи
+	st.items.onEvicted(func(key string, _ solution) {
+		i := strings.LastIndex(key, tenantSeparator)
+		if i < 0 {
+			return
+		}
+		st.release(key[:i])
+	})
+	return st
+}
+
+func tenantKey(siteKey, id string) string {
+	return siteKey + tenantSeparator + id
+}
+
+// reserve занимает место под задание тенанта или возвращает errTenantFull
+func (st *challengeStore) reserve(siteKey string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.maxPerTenant > 0 && st.counts[siteKey] >= st.maxPerTenant {
+		return errTenantFull
+	}
+	st.counts[siteKey]++
+	return nil
+}
+
+func (st *challengeStore) release(siteKey string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.counts[siteKey] <= 1 {
+		delete(st.counts, siteKey)
+		return
+	}
+	st.counts[siteKey]--
+}
+
+// full сообщает, исчерпан ли лимит тенанта: проверяется до дорогой отрисовки
+func (st *challengeStore) full(siteKey string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.maxPerTenant > 0 && st.counts[siteKey] >= st.maxPerTenant
+}
+
+// put сохраняет ответ задания в разделе его тенанта
+func (st *challengeStore) put(id string, sol solution) error {
+	if err := st.reserve(sol.SiteKey); err != nil {
+		return err
+	}
+	st.owners.set(id, sol.SiteKey, 0)
+	st.items.set(tenantKey(sol.SiteKey, id), sol, 0)
+	return nil
+}
+
+func (st *challengeStore) get(id string) (solution, bool) {
+	sol, _, ok := st.getWithExpiration(id)
+	return sol, ok
+}
+
+// getWithExpiration — ответ задания и срок его хранения
+func (st *challengeStore) getWithExpiration(id string) (solution, time.Time, bool) {
+	owner, err := st.owners.get(id)
+	if err != nil {
+		return solution{}, time.Time{}, false
+	}
+	sol, expires, err := st.items.getWithExpiration(tenantKey(owner, id))
+	if err != nil {
+		return solution{}, time.Time{}, false
+	}
+	return sol, expires, true
+}
+
+// update меняет ответ задания на месте, не продлевая его срок
+func (st *challengeStore) update(id string, fn func(solution) (solution, error)) error {
+	owner, err := st.owners.get(id)
+	if err != nil {
+		return err
+	}
+	return st.items.update(tenantKey(owner, id), fn)
+}
+
+func (st *challengeStore) delete(id string) {
+	owner, err := st.owners.consume(id)
+	if err != nil {
+		return
+	}
+	st.items.delete(tenantKey(owner, id))
+}
+
+// pendingChallenge — незавершенное задание со сроком его хранения
+type pendingChallenge struct {
+	ID        string
+	Solution  solution
+	ExpiresAt time.Time
+}
+
+// pending возвращает все незавершенные задания
+func (st *challengeStore) pending() []pendingChallenge {
+	var list []pendingChallenge
+	for _, e := range st.items.entries() {
+		i := strings.LastIndex(e.key, tenantSeparator)
+		if i < 0 {
+			continue
+		}
+		list = append(list, pendingChallenge{
+			ID:        e.key[i+1:],
+			Solution:  e.value,
+			ExpiresAt: e.expires,
+		})
+	}
+	return list
+}
+
+// adopt сохраняет задание, выданное другим инстансом, до его прежнего срока.
+// Лимит тенанта не проверяется: задание уже выдано, и отказ лишь сорвал бы проверку.
+// Повторно переданное задание не перезаписывается и не учитывается дважды.
+func (st *challengeStore) adopt(c pendingChallenge) bool {
+	ttl := time.Until(c.ExpiresAt)
+	if ttl <= 0 {
+		return false
+	}
+	st.mu.Lock()
+	st.counts[c.Solution.SiteKey]++
+	st.mu.Unlock()
+	if st.items.add(tenantKey(c.Solution.SiteKey, c.ID), c.Solution, ttl) != nil {
+		st.release(c.Solution.SiteKey)
+		return true
+	}
+	st.owners.set(c.ID, c.Solution.SiteKey, ttl)
+	return true
+}
+
+// count — незавершенные задания всех тенантов
+func (st *challengeStore) count() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	n := 0
+	for _, c := range st.counts {
+		n += c
+	}
+	return n
+}
+
+// tenantCounts — незавершенные задания по тенантам
+func (st *challengeStore) tenantCounts() map[string]int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	counts := make(map[string]int, len(st.counts))
+	for k, c := range st.counts {
+		counts[siteLabel(k)] = c
+	}
+	return counts
+}
+
+// clearTenant удаляет все задания тенанта и возвращает их ответы,
+// чтобы вызывающий освободил связанные картинки
+func (st *challengeStore) clearTenant(siteKey string) []solution {
+	prefix := siteKey + tenantSeparator
+	var cleared []solution
+	for _, e := range st.items.entries() {
+		id, ok := strings.CutPrefix(e.key, prefix)
+		if !ok || strings.Contains(id, tenantSeparator) {
+			continue
+		}
+		st.owners.delete(id)
+		st.items.delete(e.key)
+		cleared = append(cleared, e.value)
+	}
+	return cleared
+}
+
+// probe проверяет, что хранилище заданий отвечает
+func (st *challengeStore) probe() error {
+	return st.items.probe()
+}
+
+// handleTenants — GET /admin/tenants: незавершенные задания по тенантам;
+// DELETE /admin/tenants/{site_key}/challenges сбрасывает задания тенанта
+func (s *captchaService) handleTenants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"max_pending_per_tenant": s.challenges.maxPerTenant,
+		"pending":                s.challenges.tenantCounts(),
+	})
+}
+
+func (s *captchaService) handleClearTenant(w http.ResponseWriter, r *http.Request) {
+	siteKey := r.PathValue("site_key")
+	if siteKey == siteLabel("") {
+		siteKey = ""
+	}
+	cleared := s.challenges.clearTenant(siteKey)
+	for _, sol := range cleared {
+		s.assets.delete(sol.AssetKey)
+	}
+	logging.Warnf(logging.Generator, siteKey, "Cleared %d pending challenges of site %s via admin API", len(cleared), siteLabel(siteKey))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"site_key": siteLabel(siteKey), "cleared": len(cleared)})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	_ "captcha-service/internal/grpczstd" // регистрирует zstd
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // регистрирует gzip
+)
+
+// checkCompressor проверяет, что компрессор из конфигурации зарегистрирован
+func checkCompressor(name string) string {
+	if name == "" || name == "identity" {
+		return ""
+	}
+	if encoding.GetCompressor(name) == nil {
+		log.Printf("Unknown gRPC compressor %q, responses will not be compressed", name)
+		return ""
+	}
+	return name
+}
+
+// compressResponse включает сжатие ответа, если клиент поддерживает выбранный компрессор.
+// HTML с base64-картинками сжимается заметно, поэтому это важно для удаленных клиентов.
+func (s *captchaService) compressResponse(ctx context.Context) {
+	if s.responseCompressor == "" {
+		return
+	}
+	supported, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil || !slices.Contains(supported, s.responseCompressor) {
+		return
+	}
+	if err := grpc.SetSendCompressor(ctx, s.responseCompressor); err != nil {
+		log.Printf("Failed to set response compressor %s: %v", s.responseCompressor, err)
+	}
+}
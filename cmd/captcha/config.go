@@ -0,0 +1,624 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"captcha-service/internal/accesslog"
+	"captcha-service/internal/acme"
+	"captcha-service/internal/answer"
+	"captcha-service/internal/archive"
+	"captcha-service/internal/chaos"
+	"captcha-service/internal/httpsec"
+	"captcha-service/internal/httpserve"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/metrics"
+	"captcha-service/internal/middleware"
+
+	"google.golang.org/grpc/codes"
+)
+
+// config содержит параметры инстанса, переопределяемые через переменные окружения
+type config struct {
+	MinPort             int
+	MaxPort             int
+	BalancerAddr        string
+	MaxShutdownInterval time.Duration
+
+	// Порты служебного HTTP-сервера (метрики)
+	HTTPMinPort int
+	HTTPMaxPort int
+
+	StreamLimits streamLimits
+	// WarmupChallenges — сколько заданий каждого типа сгенерировать при старте
+	// до регистрации в балансере (0 — без прогрева)
+	WarmupChallenges int
+	// StreamJanitorInterval — период поиска брошенных стримов; StreamIdleTimeout —
+	// сколько стрим может молчать, прежде чем считается брошенным (0 — не проверять)
+	StreamJanitorInterval time.Duration
+	StreamIdleTimeout     time.Duration
+
+	// Пул проверки решений: число воркеров и длина очереди каждого
+	VerifyWorkers   int
+	VerifyQueueSize int
+
+	// Memory — самоограничение под давлением памяти: доли лимита, после которых
+	// инстанс урезает пре-генерацию, качество картинок и мощность для балансера
+	Memory memoryConfig
+
+	// RendererAddr — адрес пула рендереров (cmd/renderer); пустой — задания
+	// рисуются в процессе. RendererTimeout ограничивает одну отрисовку.
+	RendererAddr    string
+	RendererTimeout time.Duration
+
+	// ForwardSolutions — решения неизвестных инстансу заданий пересылаются на
+	// выдавший их инстанс по таблице маршрутов балансера; ForwardTimeout
+	// ограничивает поиск маршрута и проверку на другом инстансе. Пересылку
+	// инстанс принимает от доверенных прокси и от соседей с BALANCER_REGISTRATION_TOKEN.
+	ForwardSolutions bool
+	ForwardTimeout   time.Duration
+
+	// DebugAnswers — дописывать правильный ответ в HTML задания для smoke-тестов
+	// (cmd/smoketest); только для тестовых окружений
+	DebugAnswers bool
+
+	// RiskLevelComplexity переопределяет сложности уровней риска из запроса
+	// ("high=85,slider-rotate.high=95"), см. defaultRiskLevels
+	RiskLevelComplexity map[string]string
+	// RPCDeadlines переопределяет серверные дедлайны вызовов ("NewChallenge=3s"),
+	// см. defaultRPCDeadlines; SlowRequestThreshold — с какой длительности
+	// вызов попадает в лог медленных запросов (0 — не пишется)
+	RPCDeadlines         map[string]string
+	SlowRequestThreshold time.Duration
+	// MaxEventData — предел ClientEvent.data и ForwardSolutionRequest.data в
+	// байтах; более длинный или двоичный ответ отклоняется с INVALID_ARGUMENT
+	MaxEventData int
+	// DebugChallenges — ручка GET /debug/challenges/{id} с ответом и параметрами
+	// задания на служебном HTTP-сервере; только для тестовых окружений
+	DebugChallenges bool
+
+	// Handoff — передача незавершенных заданий другому инстансу при остановке
+	Handoff handoffConfig
+
+	// PrewarmConcurrency — сколько prewarm-заданий может рисоваться одновременно
+	PrewarmConcurrency int
+
+	// SliderStep — шаг слайдера в пикселях исходного изображения
+	SliderStep float64
+	// RotateMinComplexity — порог сложности для пазла с вращением
+	RotateMinComplexity int
+	// MultiPieceMinComplexity — порог сложности для задания из нескольких фрагментов
+	MultiPieceMinComplexity int
+	// ObfuscateWidget — уникальные имена и перестановка JS виджета для каждого задания
+	ObfuscateWidget bool
+	// MaxHTMLSize — бюджет размера HTML задания в байтах
+	MaxHTMLSize int
+	// HTMLSizeBudget — мягкий бюджет размера HTML, после которого качество картинок понижается
+	HTMLSizeBudget int
+
+	// MaxPendingPerTenant — сколько выданных и не решенных заданий может быть
+	// у одного site key на инстансе (0 — без ограничения)
+	MaxPendingPerTenant int
+
+	// Месячные квоты тенантов по умолчанию (0 — без ограничения) и файл с индивидуальными квотами
+	DefaultChallengeQuota    int64
+	DefaultVerificationQuota int64
+	QuotaFile                string
+	// Notifications — уведомления тенантов (исчерпанная квота и т.п.)
+	Notifications notificationsConfig
+	// PolicyFile — JSON с политиками сложности по сайтам и действиям
+	PolicyFile string
+	// SettingsHistoryFile — история версий политик и квот (JSONL); без него
+	// версии живут только в памяти инстанса. На общем томе — общая история флота.
+	SettingsHistoryFile string
+	// SettingsCacheTTL — как долго инстанс работает с закэшированной версией
+	// настроек, прежде чем проверить общую историю; изменения с других
+	// инстансов приходят раньше через балансер. 0 — только по оповещениям.
+	SettingsCacheTTL time.Duration
+	// TenantBundle — экспорт и импорт конфигурации тенантов подписанными пакетами
+	TenantBundle tenantBundleConfig
+	// Attestation — верификаторы токенов аттестации платформы (Private Access
+	// Tokens, Play Integrity): с принятой аттестацией задание не выдается
+	Attestation attestationConfig
+	// RiskBaseScore — оценка клиента до поправок правил риска; InvisiblePassScore —
+	// порог пропуска без задания для политик с invisible и без своего pass_score
+	RiskBaseScore      int
+	InvisiblePassScore int
+	// Fingerprint — лимит частоты проверок по отпечатку устройства из виджета
+	Fingerprint fingerprintConfig
+	// VelocityRules — лимиты выдачи заданий по источнику ("ip=20/5m,fingerprint=10/5m")
+	VelocityRules map[string]string
+	// IPLists — allowlist (без задания) и denylist (сложное задание или блок) по IP/CIDR
+	IPLists ipListsConfig
+	// Admin — токены администраторов для /admin/*
+	Admin adminConfig
+	// TrustedProxies — CIDR балансеров и соседних инстансов, которым разрешено
+	// передавать адрес и страну клиента; от остальных берется адрес соединения.
+	// ВАЖНО: по умолчанию список пуст, и за балансером (BALANCER_ADDR) клиентом
+	// считается сам балансер: привязка к сети, allowlist/denylist, история и
+	// лимиты частоты видят один адрес на всех. Укажите в TRUSTED_PROXIES адреса
+	// балансеров; при пустом списке инстанс предупреждает об этом при старте.
+	TrustedProxies []string
+	// Flags — правила флагов функций из файла и окружения
+	Flags flagsConfig
+
+	// LazyAssets — HTML задания содержит только ссылки на картинки, а сами картинки
+	// отдаются служебным HTTP-сервером; AssetBaseURL — внешний адрес этого сервера
+	LazyAssets   bool
+	AssetBaseURL string
+	// AssetURLSecret включает подпись адресов картинок (срок AssetURLTTL):
+	// без валидной подписи сервер картинку не отдает
+	AssetURLSecret []byte
+	AssetURLTTL    time.Duration
+	// ClockSkew — допустимое расхождение часов с балансером и другими инстансами:
+	// допуск к срокам подписанных адресов и сессионных cookie и порог
+	// предупреждения о рассинхронизации
+	ClockSkew time.Duration
+	// Region и Zone — размещение инстанса: балансер выдает задания инстансами
+	// своего региона, а регион попадает в результаты проверок
+	Region string
+	Zone   string
+	// RenderTokens — задание отрисовывается один раз: виджет обменивает
+	// render-токен на сессию, без которой картинки не отдаются
+	RenderTokens bool
+	// StaticFiles — CSS и JS виджета отдаются файлами /static/<хэш> с вечным
+	// кэшем вместо встраивания в каждое задание; нужен внешний адрес картинок
+	StaticFiles bool
+	// StrictBinding — ответ без site key relying party отклоняется; иначе сайт
+	// сверяется, только если он передан
+	StrictBinding bool
+	// BindIPPrefix — длины префиксов "v4,v6", к сети которых привязывается
+	// задание при выдаче (например "24,56"); пусто — без привязки к IP
+	BindIPPrefix string
+
+	// TemplateDir — директория шаблонов виджетов, перекрывающая встроенные;
+	// TemplateVersions закрепляет версии шаблонов по типам ("slider-rotate=v1,default=v2")
+	TemplateDir      string
+	TemplateVersions map[string]string
+	// BackgroundDir — фоны заданий по локалям (<dir>/<locale>/*.png)
+	BackgroundDir string
+	// ChallengeTypes — типы заданий, которые выдает развертывание; пустой — все
+	// собранные в бинарник. Остальные типы откатываются на более простые, их
+	// шаблоны и картинки не загружаются.
+	ChallengeTypes []string
+
+	// ResponseCompression — gRPC-компрессор для ChallengeResponse: gzip, zstd или identity
+	ResponseCompression string
+
+	// Session — сессионные cookie для серверных приложений; включается SESSION_COOKIE_SECRET
+	Session sessionConfig
+	// FormFlow — /challenge/form и /verify/form для серверных форм; включается FORM_RETURN_ORIGINS
+	FormFlow formFlowConfig
+	// ForwardAuth — /forward-auth для Traefik и nginx; требует режима сессионных cookie
+	ForwardAuth forwardAuthConfig
+	// ResultJWTKey — seed Ed25519 в base64 для JWT результатов (pkg/verify);
+	// пустой — результаты выдаются только одноразовыми токенами
+	ResultJWTKey string
+	// ResultJWTTTL — срок действия JWT результата
+	ResultJWTTTL time.Duration
+	// Introspection — /introspect для API-шлюзов; включается INTROSPECTION_SECRET
+	Introspection introspectionConfig
+	// ExtAuthz — сервис внешней авторизации Envoy на gRPC-порту; включается EXT_AUTHZ
+	ExtAuthz extAuthzConfig
+
+	// LogLevel — начальный уровень логирования; LogLevels — уровни компонентов
+	// ("verification=debug,generator=warn"); меняются на лету через /admin/loglevel
+	LogLevel  string
+	LogLevels map[string]string
+	// LogRedaction — как в лог попадают ответы и IP (mask, hash, plain);
+	// LogRedactions переопределяет режим класса ("solutions=hash,ips=plain").
+	// LogHashKey — общий ключ HMAC режима hash, чтобы хэши совпадали между инстансами.
+	LogRedaction  string
+	LogRedactions map[string]string
+	LogHashKey    []byte
+
+	// AccessLog — JSON-запись на каждый gRPC-вызов в stdout; AccessLogSampling — доли записей
+	AccessLog         bool
+	AccessLogSampling accesslog.Config
+	// AuditLogFile — журнал заданий и проверок (JSONL) с порядковыми номерами
+	// инстанса для разбора инцидентов; пусто — выключен
+	AuditLogFile string
+	// Archive — выгрузка устаревших сегментов журнала в объектное хранилище
+	Archive archiveConfig
+
+	// MetricsExporter — "prometheus" (только pull через /metrics) или push-экспорт
+	// "statsd"/"dogstatsd" в агент по StatsD.Addr; /metrics при этом продолжает работать
+	MetricsExporter string
+	StatsD          metrics.StatsDConfig
+	// MetricsService — метка service каждой метрики: по ней дашборды отделяют
+	// этот деплоймент от других сервисов в том же Prometheus
+	MetricsService string
+	// SLO — цели уровня обслуживания и алерты по скорости сжигания бюджета
+	SLO sloConfig
+
+	// HTTPServe — TLS и HTTP/3 служебного HTTP-сервера (адрес задается портом)
+	HTTPServe httpserve.Config
+
+	// ACME — автоматические сертификаты для служебного HTTP-сервера (ACME_HOSTS);
+	// ACMECache — хранилище сертификатов, ACMEHTTPAddr — адрес для HTTP-01 проверок
+	ACME         acme.Config
+	ACMECache    string
+	ACMEHTTPAddr string
+	// BalancerTLS — соединение с балансером и с соседями при пересылке решений
+	// по TLS (если их gRPC-порты публичные)
+	BalancerTLS bool
+	// BalancerToken — токен регистрации в балансере (REGISTRATION_TOKENS балансера);
+	// им же соседи подтверждают ForwardSolution;
+	// BalancerClientCert и BalancerClientKey — клиентский сертификат для mTLS
+	BalancerToken      string
+	BalancerClientCert string
+	BalancerClientKey  string
+
+	// HTTPSecurity — CORS для картинок и /session, политика встраивания во фрейм
+	// и стандартные заголовки безопасности служебного HTTP-сервера
+	HTTPSecurity httpsec.Policy
+
+	// Отчеты об ошибках и паниках в Sentry-совместимый сервис; пустой DSN — выключены.
+	// Release по умолчанию — ревизия VCS из сборки.
+	SentryDSN         string
+	SentryRelease     string
+	SentryEnvironment string
+
+	// Chaos — искусственная деградация для проверки интеграций; выключена по умолчанию
+	Chaos chaos.Config
+
+	// GRPCReflection регистрирует reflection-сервис для grpcurl
+	GRPCReflection bool
+}
+
+// applyLogLevels задает начальные уровни логирования из конфигурации
+func (c config) applyLogLevels() {
+	if l, err := logging.ParseLevel(c.LogLevel); err == nil {
+		logging.SetGlobal(l)
+	} else {
+		log.Printf("Invalid LOG_LEVEL: %v", err)
+	}
+	for component, name := range c.LogLevels {
+		l, err := logging.ParseLevel(name)
+		if err != nil || !slices.Contains(logging.Components, logging.Component(component)) {
+			log.Printf("Invalid entry %s=%s in LOG_LEVELS, skipping", component, name)
+			continue
+		}
+		logging.SetComponent(logging.Component(component), &l)
+	}
+	c.applyRedaction()
+}
+
+// applyRedaction задает режимы скрытия чувствительных полей в логах
+func (c config) applyRedaction() {
+	r, err := logging.ParseRedaction(c.LogRedaction)
+	if err != nil {
+		log.Printf("Invalid LOG_REDACTION: %v", err)
+	}
+	for _, f := range logging.Fields {
+		logging.SetRedaction(f, r)
+	}
+	for field, name := range c.LogRedactions {
+		r, err := logging.ParseRedaction(name)
+		if err != nil || !slices.Contains(logging.Fields, logging.Field(field)) {
+			log.Printf("Invalid entry %s=%s in LOG_REDACTIONS, skipping", field, name)
+			continue
+		}
+		logging.SetRedaction(logging.Field(field), r)
+	}
+	logging.SetHashKey(c.LogHashKey)
+	if logging.RedactionOf(logging.Solutions) == logging.Plain {
+		log.Println("WARNING: challenge answers are logged in plain text (LOG_REDACTION).")
+	}
+}
+
+// loadConfig читает конфигурацию из окружения, подставляя значения по умолчанию
+func loadConfig() config {
+	return config{
+		MinPort:             envInt("MIN_PORT", minPort),
+		MaxPort:             envInt("MAX_PORT", maxPort),
+		BalancerAddr:        envString("BALANCER_ADDR", balancerAddr),
+		MaxShutdownInterval: envDuration("MAX_SHUTDOWN_INTERVAL", defaultShutdownInterval),
+		HTTPMinPort:         envInt("HTTP_MIN_PORT", minHTTPPort),
+		HTTPMaxPort:         envInt("HTTP_MAX_PORT", maxHTTPPort),
+		StreamLimits: streamLimits{
+			EventsPerSecond: envFloat("STREAM_EVENTS_PER_SECOND", 500),
+			Burst:           envInt("STREAM_EVENTS_BURST", 1000),
+			MaxInFlight:     envInt("STREAM_MAX_IN_FLIGHT", 64),
+			AbuseThreshold:  envInt("STREAM_ABUSE_THRESHOLD", 5000),
+			AbuseWindow:     envDuration("STREAM_ABUSE_WINDOW", 10*time.Second),
+		},
+		WarmupChallenges:      envInt("WARMUP_CHALLENGES", 2),
+		StreamJanitorInterval: envDuration("STREAM_JANITOR_INTERVAL", 30*time.Second),
+		StreamIdleTimeout:     envDuration("STREAM_IDLE_TIMEOUT", 10*time.Minute),
+		VerifyWorkers:         envInt("VERIFY_WORKERS", 2*runtime.NumCPU()),
+		VerifyQueueSize:       envInt("VERIFY_QUEUE_SIZE", 256),
+		SliderStep:            envFloat("SLIDER_STEP", 0.5),
+
+		PrewarmConcurrency:   envInt("PREWARM_CONCURRENCY", runtime.NumCPU()),
+		RendererAddr:         envString("RENDERER_ADDR", ""),
+		RendererTimeout:      envDuration("RENDERER_TIMEOUT", 10*time.Second),
+		ForwardSolutions:     envBool("FORWARD_SOLUTIONS", true),
+		ForwardTimeout:       envDuration("FORWARD_TIMEOUT", 2*time.Second),
+		DebugAnswers:         envBool("DEBUG_ANSWERS", false),
+		RiskLevelComplexity:  envMap("RISK_LEVEL_COMPLEXITY"),
+		DebugChallenges:      envBool("DEBUG_CHALLENGES", false),
+		RPCDeadlines:         envMap("RPC_DEADLINES"),
+		SlowRequestThreshold: envDuration("SLOW_REQUEST_THRESHOLD", time.Second),
+		MaxEventData:         envInt("MAX_EVENT_DATA_BYTES", answer.MaxData),
+		Handoff: handoffConfig{
+			Secret:  []byte(envString("HANDOFF_SECRET", "")),
+			Timeout: envDuration("HANDOFF_TIMEOUT", 5*time.Second),
+		},
+		Memory: memoryConfig{
+			Limit:    uint64(envInt("MEMORY_LIMIT_BYTES", 0)),
+			High:     envFloat("MEMORY_PRESSURE_HIGH", 0.8),
+			Critical: envFloat("MEMORY_PRESSURE_CRITICAL", 0.9),
+			Interval: envDuration("MEMORY_CHECK_INTERVAL", 5*time.Second),
+		},
+
+		RotateMinComplexity:     envInt("ROTATE_MIN_COMPLEXITY", 70),
+		MultiPieceMinComplexity: envInt("MULTI_PIECE_MIN_COMPLEXITY", 90),
+		ObfuscateWidget:         envBool("OBFUSCATE_WIDGET", true),
+		MaxHTMLSize:             envInt("MAX_HTML_BYTES", defaultMaxHTMLSize),
+		HTMLSizeBudget:          envInt("HTML_SIZE_BUDGET_BYTES", defaultHTMLSizeBudget),
+		GRPCReflection:          envBool("GRPC_REFLECTION", false),
+		LazyAssets:              envBool("LAZY_ASSETS", true),
+		AssetBaseURL:            envString("ASSET_BASE_URL", ""),
+		AssetURLSecret:          []byte(envString("ASSET_URL_SECRET", "")),
+		AssetURLTTL:             envDuration("ASSET_URL_TTL", defaultExpiration),
+		ClockSkew:               envDuration("CLOCK_SKEW_TOLERANCE", 30*time.Second),
+		Region:                  envString("REGION", ""),
+		Zone:                    envString("ZONE", ""),
+		RenderTokens:            envBool("RENDER_TOKENS", false),
+		StaticFiles:             envBool("STATIC_FILES", false),
+		StrictBinding:           envBool("STRICT_BINDING", false),
+		BindIPPrefix:            envString("BIND_IP_PREFIX", ""),
+		ResponseCompression:     envString("GRPC_RESPONSE_COMPRESSION", "gzip"),
+		TemplateDir:             envString("TEMPLATE_DIR", ""),
+		BackgroundDir:           envString("BACKGROUND_DIR", ""),
+		TemplateVersions:        envMap("TEMPLATE_VERSIONS"),
+		ChallengeTypes:          envList("CHALLENGE_TYPES"),
+
+		MaxPendingPerTenant:      envInt("MAX_PENDING_PER_TENANT", 10000),
+		DefaultChallengeQuota:    int64(envInt("DEFAULT_MONTHLY_CHALLENGE_QUOTA", 0)),
+		DefaultVerificationQuota: int64(envInt("DEFAULT_MONTHLY_VERIFICATION_QUOTA", 0)),
+		QuotaFile:                envString("QUOTA_FILE", ""),
+		Notifications: notificationsConfig{
+			File:    envString("NOTIFICATIONS_FILE", ""),
+			Timeout: envDuration("NOTIFICATION_TIMEOUT", 10*time.Second),
+		},
+		PolicyFile:          envString("POLICY_FILE", ""),
+		SettingsHistoryFile: envString("SETTINGS_HISTORY_FILE", ""),
+		SettingsCacheTTL:    envDuration("SETTINGS_CACHE_TTL", 30*time.Second),
+		TenantBundle: tenantBundleConfig{
+			Secret:      []byte(envString("TENANT_BUNDLE_SECRET", "")),
+			Environment: envString("TENANT_BUNDLE_ENVIRONMENT", ""),
+		},
+		RiskBaseScore:      envInt("RISK_BASE_SCORE", 80),
+		InvisiblePassScore: envInt("INVISIBLE_PASS_SCORE", 70),
+		VelocityRules:      envMap("VELOCITY_RULES"),
+		IPLists: ipListsConfig{
+			File:           envString("IP_LISTS_FILE", ""),
+			AuditFile:      envString("IP_LISTS_AUDIT_FILE", ""),
+			ReloadInterval: envDuration("IP_LISTS_RELOAD_INTERVAL", 10*time.Second),
+		},
+		TrustedProxies: envList("TRUSTED_PROXIES"),
+		Admin:          adminConfig{Tokens: envMap("ADMIN_TOKENS")},
+		Flags: flagsConfig{
+			File:           envString("FEATURE_FLAGS_FILE", ""),
+			Env:            envString("FEATURE_FLAGS", ""),
+			ReloadInterval: envDuration("FEATURE_FLAGS_RELOAD_INTERVAL", 30*time.Second),
+		},
+		Fingerprint: fingerprintConfig{
+			MaxVerifications: envInt("FINGERPRINT_MAX_VERIFICATIONS", 0),
+			Window:           envDuration("FINGERPRINT_WINDOW", 10*time.Minute),
+			Bits:             envInt("FINGERPRINT_BITS", 20),
+			Rotation:         envDuration("FINGERPRINT_ROTATION", 24*time.Hour),
+		},
+		Attestation: attestationConfig{
+			Verifiers:  envMap("ATTESTATION_VERIFIERS"),
+			Timeout:    envDuration("ATTESTATION_TIMEOUT", 2*time.Second),
+			Confidence: envInt("ATTESTATION_CONFIDENCE", 100),
+		},
+
+		LogLevel:  envString("LOG_LEVEL", "info"),
+		LogLevels: envMap("LOG_LEVELS"),
+
+		LogRedaction:  envString("LOG_REDACTION", "mask"),
+		LogRedactions: envMap("LOG_REDACTIONS"),
+		LogHashKey:    []byte(envString("LOG_HASH_KEY", "")),
+
+		AccessLog: envBool("ACCESS_LOG", false),
+		AccessLogSampling: accesslog.Config{
+			SampleRate:      envFloat("ACCESS_LOG_SAMPLE_RATE", 1),
+			ErrorSampleRate: envFloat("ACCESS_LOG_ERROR_SAMPLE_RATE", 1),
+		},
+		AuditLogFile: envString("AUDIT_LOG_FILE", ""),
+		Archive: archiveConfig{
+			Store: archive.Config{
+				URL:             envString("ARCHIVE_URL", ""),
+				Endpoint:        envString("ARCHIVE_ENDPOINT", ""),
+				Region:          envString("ARCHIVE_REGION", ""),
+				AccessKeyID:     envString("ARCHIVE_ACCESS_KEY_ID", ""),
+				SecretAccessKey: envString("ARCHIVE_SECRET_ACCESS_KEY", ""),
+				Timeout:         envDuration("ARCHIVE_TIMEOUT", time.Minute),
+			},
+			Interval:   envDuration("ARCHIVE_INTERVAL", time.Hour),
+			Retention:  envDuration("AUDIT_LOG_RETENTION", 7*24*time.Hour),
+			PurgeDelay: envDuration("ARCHIVE_PURGE_DELAY", 24*time.Hour),
+		},
+
+		MetricsExporter: envString("METRICS_EXPORTER", metricsExporterPrometheus),
+		StatsD: metrics.StatsDConfig{
+			Addr:     envString("STATSD_ADDR", "localhost:8125"),
+			Prefix:   envString("STATSD_PREFIX", ""),
+			Interval: envDuration("STATSD_INTERVAL", 10*time.Second),
+			TagMap:   envMap("STATSD_TAG_MAP"),
+		},
+		MetricsService: envString("METRICS_SERVICE_NAME", "captcha"),
+		SLO: sloConfig{
+			Objectives:     envMap("SLO_OBJECTIVES"),
+			Webhooks:       envList("SLO_ALERT_WEBHOOKS"),
+			WebhookTimeout: envDuration("SLO_ALERT_TIMEOUT", 5*time.Second),
+			Interval:       envDuration("SLO_EVALUATION_INTERVAL", 30*time.Second),
+		},
+
+		HTTPServe: httpserve.Config{
+			CertFile: envString("HTTP_TLS_CERT", ""),
+			KeyFile:  envString("HTTP_TLS_KEY", ""),
+			HTTP3:    envBool("HTTP3", true),
+		},
+		ACME: acme.Config{
+			Hosts:        envList("ACME_HOSTS"),
+			Email:        envString("ACME_EMAIL", ""),
+			DirectoryURL: envString("ACME_DIRECTORY_URL", ""),
+		},
+		ACMECache:    envString("ACME_CACHE", "dir:acme-cache"),
+		ACMEHTTPAddr: envString("ACME_HTTP_ADDR", ""),
+		BalancerTLS:  envBool("BALANCER_TLS", false),
+
+		BalancerToken:      envString("BALANCER_REGISTRATION_TOKEN", ""),
+		BalancerClientCert: envString("BALANCER_CLIENT_CERT", ""),
+		BalancerClientKey:  envString("BALANCER_CLIENT_KEY", ""),
+
+		HTTPSecurity: httpsec.Policy{
+			AllowedOrigins:   envList("CORS_ALLOWED_ORIGINS"),
+			AllowCredentials: envBool("CORS_ALLOW_CREDENTIALS", true),
+			FrameAncestors:   envList("FRAME_ANCESTORS"),
+			HSTSMaxAge:       envDuration("HSTS_MAX_AGE", 0),
+		},
+
+		SentryDSN:         envString("SENTRY_DSN", ""),
+		SentryRelease:     envString("SENTRY_RELEASE", ""),
+		SentryEnvironment: envString("SENTRY_ENVIRONMENT", "production"),
+
+		Chaos: chaos.Config{
+			LatencyRate: envFloat("CHAOS_LATENCY_RATE", 0),
+			Latency:     envDuration("CHAOS_LATENCY", 500*time.Millisecond),
+			ErrorRate:   envFloat("CHAOS_ERROR_RATE", 0),
+			ErrorCode:   codes.Code(envInt("CHAOS_ERROR_CODE", int(codes.Unavailable))),
+			DropRate:    envFloat("CHAOS_DROP_RATE", 0),
+		},
+
+		Session: sessionConfig{
+			Secret: []byte(envString("SESSION_COOKIE_SECRET", "")),
+			Name:   envString("SESSION_COOKIE_NAME", middleware.DefaultCookieName),
+			TTL:    envDuration("SESSION_COOKIE_TTL", 10*time.Minute),
+			Domain: envString("SESSION_COOKIE_DOMAIN", ""),
+			Secure: envBool("SESSION_COOKIE_SECURE", true),
+		},
+		FormFlow: formFlowConfig{
+			ReturnOrigins: envList("FORM_RETURN_ORIGINS"),
+			CountryHeader: envString("FORM_COUNTRY_HEADER", ""),
+		},
+		ForwardAuth: forwardAuthConfig{
+			Enabled: envBool("FORWARD_AUTH", false),
+			Header:  envString("FORWARD_AUTH_HEADER", "X-Captcha-Session"),
+		},
+		ResultJWTKey: envString("RESULT_JWT_KEY", ""),
+		ResultJWTTTL: envDuration("RESULT_JWT_TTL", resultTokenTTL),
+		Introspection: introspectionConfig{
+			Secret: []byte(envString("INTROSPECTION_SECRET", "")),
+			TTL:    envDuration("INTROSPECTION_TOKEN_TTL", 30*time.Minute),
+		},
+		ExtAuthz: extAuthzConfig{
+			Enabled: envBool("EXT_AUTHZ", false),
+			// Envoy передает заголовки в нижнем регистре
+			TokenHeader:  strings.ToLower(envString("EXT_AUTHZ_TOKEN_HEADER", "x-captcha-token")),
+			HeaderPrefix: strings.ToLower(envString("EXT_AUTHZ_HEADER_PREFIX", "x-captcha-")),
+		},
+	}
+}
+
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %d", v, key, def)
+		return def
+	}
+	return n
+}
+
+func envBool(key string, def bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %t", v, key, def)
+		return def
+	}
+	return b
+}
+
+func envFloat(key string, def float64) float64 {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %g", v, key, def)
+		return def
+	}
+	return f
+}
+
+// envList разбирает значения вида "a,b,c"; пустые элементы пропускаются
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envMap разбирает значения вида "key=value,key2=value2"
+func envMap(key string) map[string]string {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		k, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || k == "" {
+			log.Printf("Invalid entry %q in %s, skipping", pair, key)
+			continue
+		}
+		m[k] = val
+	}
+	return m
+}
+
+// envDuration принимает как значения вида "90s", так и целое число секунд (как в ТЗ)
+func envDuration(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %s", v, key, def)
+		return def
+	}
+	return d
+}
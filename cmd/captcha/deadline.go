@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// defaultRPCDeadlines — серверные дедлайны вызовов CaptchaService по умолчанию.
+// Более короткий дедлайн клиента остается в силе; стримы не ограничиваются.
+func defaultRPCDeadlines() map[string]time.Duration {
+	return map[string]time.Duration{
+		"NewChallenge":     2 * time.Second,
+		"GetChallenge":     2 * time.Second,
+		"PrewarmChallenge": time.Second,
+		"Assess":           time.Second,
+		"ForwardSolution":  2 * time.Second,
+	}
+}
+
+// parseRPCDeadlines накладывает RPC_DEADLINES ("NewChallenge=3s,Assess=500ms")
+// на дедлайны по умолчанию; 0 снимает дедлайн метода
+func parseRPCDeadlines(overrides map[string]string) (map[string]time.Duration, error) {
+	deadlines := defaultRPCDeadlines()
+	for method, value := range overrides {
+		known := false
+		for _, m := range captchapb.CaptchaService_ServiceDesc.Methods {
+			known = known || m.MethodName == method
+		}
+		if !known {
+			return nil, fmt.Errorf("%s: unknown unary method of CaptchaService", method)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%s: invalid deadline %q", method, value)
+		}
+		deadlines[method] = d
+	}
+	return deadlines, nil
+}
+
+// stageTime — этап обработки вызова
+type stageTime struct {
+	name string
+	d    time.Duration
+}
+
+// callStages — этапы текущего вызова для лога медленных запросов
+type callStages struct {
+	mu     sync.Mutex
+	stages []stageTime
+}
+
+type callStagesKey struct{}
+
+// recordStage добавляет этап к вызову ctx; вне перехватчика ничего не делает
+func recordStage(ctx context.Context, name string, d time.Duration) {
+	cs, ok := ctx.Value(callStagesKey{}).(*callStages)
+	if !ok {
+		return
+	}
+	cs.mu.Lock()
+	cs.stages = append(cs.stages, stageTime{name, d})
+	cs.mu.Unlock()
+}
+
+func (cs *callStages) String() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if len(cs.stages) == 0 {
+		return "none recorded"
+	}
+	parts := make([]string, len(cs.stages))
+	for i, st := range cs.stages {
+		parts[i] = fmt.Sprintf("%s=%s", st.name, st.d.Round(time.Microsecond))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// deadlineInterceptor ограничивает унарные вызовы CaptchaService дедлайнами
+// deadlines и пишет в лог вызовы дольше slow (0 — не пишет) с разбивкой по этапам
+func deadlineInterceptor(deadlines map[string]time.Duration, slow time.Duration) grpc.UnaryServerInterceptor {
+	const prefix = "/captcha.v1.CaptchaService/"
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method, ours := strings.CutPrefix(info.FullMethod, prefix)
+		if !ours {
+			method = info.FullMethod
+		}
+		deadline := deadlines[method]
+		if ours && deadline > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, deadline)
+			defer cancel()
+		}
+		cs := &callStages{}
+		ctx = context.WithValue(ctx, callStagesKey{}, cs)
+		start := time.Now()
+		res, err := handler(ctx, req)
+		if elapsed := time.Since(start); slow > 0 && elapsed >= slow {
+			slowRequests.Inc(method)
+			component := logging.Verification
+			if strings.Contains(method, "Challenge") {
+				component = logging.Generator
+			}
+			logging.Warnf(component, siteKeyOf(req), "Slow request %s: %s (deadline %s, status %s), stages: %s",
+				method, elapsed.Round(time.Millisecond), deadline, status.Code(err), cs)
+		}
+		return res, err
+	}
+}
+
+// siteKeyOf — site key запроса, если он в нем есть
+func siteKeyOf(req any) string {
+	if r, ok := req.(interface{ GetSiteKey() string }); ok {
+		return r.GetSiteKey()
+	}
+	return ""
+}
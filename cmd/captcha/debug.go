@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"captcha-service/internal/generator"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/logging"
+)
+
+// errDebugInProduction — отладочные режимы запрошены в сборке с тегом production
+var errDebugInProduction = errors.New("DEBUG_ANSWERS and DEBUG_CHALLENGES are not available in production builds")
+
+// checkDebug отказывает в отладочных режимах в production-сборке
+func (cfg config) checkDebug() error {
+	if productionBuild && (cfg.DebugAnswers || cfg.DebugChallenges) {
+		return errDebugInProduction
+	}
+	return nil
+}
+
+// challengeDebug — внутренности задания для отладки виджета и допусков
+type challengeDebug struct {
+	ID             string                  `json:"id"`
+	Kind           string                  `json:"kind"`
+	X              int                     `json:"x"`
+	Angle          float64                 `json:"angle,omitempty"`
+	Pieces         []generator.PieceAnswer `json:"pieces,omitempty"`
+	Step           float64                 `json:"step"`
+	Complexity     int                     `json:"complexity"`
+	Tolerance      float64                 `json:"tolerance"`
+	AngleTolerance float64                 `json:"angle_tolerance,omitempty"`
+	// Payload — правильный ответ в формате ClientEvent.data
+	Payload   string    `json:"payload"`
+	SiteKey   string    `json:"site_key"`
+	Action    string    `json:"action"`
+	Threshold int       `json:"threshold"`
+	AssetKey  string    `json:"asset_key,omitempty"`
+	ClientIP  string    `json:"client_ip"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// debugChallenge возвращает внутренности незавершенного задания инстанса
+func (s *captchaService) debugChallenge(id string) (challengeDebug, bool) {
+	sol, expires, ok := s.challenges.getWithExpiration(id)
+	if !ok {
+		return challengeDebug{}, false
+	}
+	d := challengeDebug{
+		ID:         id,
+		Kind:       sol.Kind,
+		X:          sol.X,
+		Pieces:     sol.Pieces,
+		Step:       sol.Step,
+		Complexity: sol.Complexity,
+		Tolerance:  sol.tolerance(),
+		Payload:    sol.payload(),
+		SiteKey:    siteLabel(sol.SiteKey),
+		Action:     sol.Action,
+		Threshold:  sol.Threshold,
+		AssetKey:   sol.AssetKey,
+		ClientIP:   sol.ClientIP,
+		ExpiresAt:  expires,
+	}
+	if sol.Kind == generator.KindRotate {
+		d.Angle = sol.Angle
+		d.AngleTolerance = sol.angleTolerance()
+	}
+	return d, true
+}
+
+// handleDebugChallenge — GET /debug/challenges/{id}: сохраненный ответ и
+// параметры задания; доступно только с DEBUG_CHALLENGES
+func (s *captchaService) handleDebugChallenge(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.debugChallenge(r.PathValue("id"))
+	if !ok {
+		httperr.Write(w, r, http.StatusNotFound, "challenge not found on this instance")
+		return
+	}
+	log.Printf("Challenge %s internals requested via debug endpoint from %s", d.ID, logging.IP(r.RemoteAddr))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/generator"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/iplist"
+	"captcha-service/internal/policy"
+	"captcha-service/internal/testclient"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// devBalancerAddr и devUIAddr — адреса по умолчанию в режиме captcha dev
+	devBalancerAddr = ":50051"
+	devUIAddr       = ":8080"
+	// devTimeout — сколько ждать регистрации и остановки инстансов
+	devTimeout = 10 * time.Second
+)
+
+// devInstances — инстансы режима dev: обычный (тип по сложности) и
+// выдающий только пазл с вращением, чтобы во флоте было два типа заданий
+var devInstances = []struct {
+	kind     string
+	policies policy.Static
+}{
+	{generator.KindSlider, policy.Static{}},
+	{generator.KindRotate, policy.Static{policy.Wildcard: {policy.Wildcard: {ChallengeType: generator.KindRotate}}}},
+}
+
+// runDev — captcha dev: локальное окружение одной командой. В одном процессе
+// работают балансер (DEV_BALANCER_ADDR, по умолчанию :50051), два инстанса
+// разных типов на свободных портах из MIN_PORT–MAX_PORT и тестовая страница
+// (DEV_UI_ADDR, по умолчанию :8080), которая ходит за заданиями через балансер.
+// Картинки встраиваются в HTML, служебные HTTP-серверы инстансов не поднимаются;
+// внутренности заданий отдает /debug/challenges/{id} тестовой страницы.
+func runDev() error {
+	cfg := loadConfig()
+	cfg.applyLogLevels()
+	balancerAddr := envString("DEV_BALANCER_ADDR", devBalancerAddr)
+	uiAddr := envString("DEV_UI_ADDR", devUIAddr)
+
+	lis, err := net.Listen("tcp", balancerAddr)
+	if err != nil {
+		return fmt.Errorf("balancer: %w", err)
+	}
+	registry := balancer.NewRegistry(defaultExpiration)
+	balancerServer := grpc.NewServer()
+	balancer.RegisterServices(balancerServer, registry, balancer.NewControlPlane(), nil, nil, "", nil)
+	go balancerServer.Serve(lis)
+	defer balancerServer.Stop()
+	log.Printf("Dev balancer listening at %v", lis.Addr())
+
+	_, balancerPort, _ := net.SplitHostPort(lis.Addr().String())
+	linkAddr := net.JoinHostPort("localhost", balancerPort)
+	linkCtx, stopLinks := context.WithCancel(context.Background())
+	var (
+		stops    []func()
+		services []*captchaService
+	)
+	for _, inst := range devInstances {
+		service, stop, err := startDevInstance(linkCtx, cfg, linkAddr, inst.kind, inst.policies)
+		if err != nil {
+			stopLinks()
+			return fmt.Errorf("%s instance: %w", inst.kind, err)
+		}
+		services = append(services, service)
+		stops = append(stops, stop)
+	}
+
+	// Страница открывается, когда хотя бы один инстанс зарегистрировался
+	if err := waitFor(func() bool {
+		_, err := registry.PickForNewChallenge()
+		return err == nil
+	}); err != nil {
+		stopLinks()
+		return fmt.Errorf("instances did not register with the dev balancer: %w", err)
+	}
+	ui, err := testclient.New(linkAddr)
+	if err != nil {
+		stopLinks()
+		return fmt.Errorf("test client: %w", err)
+	}
+	defer ui.Close()
+	mux := http.NewServeMux()
+	mux.Handle("/", ui.Handler())
+	if !productionBuild {
+		mux.HandleFunc("GET /debug/challenges/{id}", func(w http.ResponseWriter, r *http.Request) {
+			devDebugChallenge(w, r, services)
+		})
+	}
+	uiServer := &http.Server{Addr: uiAddr, Handler: mux}
+	go func() {
+		if err := uiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Dev test client stopped: %v", err)
+		}
+	}()
+	log.Printf("Dev environment is ready: open http://localhost%s (balancer %s)", uiAddr, linkAddr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Println("Stopping dev environment...")
+	uiServer.Close()
+	stopLinks()
+	for _, stop := range stops {
+		stop()
+	}
+	return nil
+}
+
+// devDebugChallenge — GET /debug/challenges/{id} тестовой страницы: ищет
+// задание во всех инстансах режима dev, как DEBUG_CHALLENGES на одном инстансе
+func devDebugChallenge(w http.ResponseWriter, r *http.Request, services []*captchaService) {
+	for _, service := range services {
+		if _, ok := service.challenges.get(r.PathValue("id")); ok {
+			service.handleDebugChallenge(w, r)
+			return
+		}
+	}
+	httperr.Write(w, r, http.StatusNotFound, "challenge not found")
+}
+
+// startDevInstance поднимает инстанс, как TestSelfCheck: генератор без служебного
+// HTTP-сервера и связь с балансером; kind — тип, под которым инстанс регистрируется
+func startDevInstance(linkCtx context.Context, cfg config, balancerAddr, kind string, policies policy.Static) (*captchaService, func(), error) {
+	port, err := findFreePort(cfg.MinPort, cfg.MaxPort)
+	if err != nil {
+		return nil, nil, err
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, nil, err
+	}
+	gen, err := generator.New(generator.Config{SliderStep: cfg.SliderStep, Obfuscate: cfg.ObfuscateWidget})
+	if err != nil {
+		lis.Close()
+		return nil, nil, err
+	}
+	service := newCaptchaService(cfg, gen, nil, policies)
+	// Тестовая страница передает IP клиента: списки нужны хотя бы пустые
+	if service.ipLists, err = iplist.Open("", ""); err != nil {
+		lis.Close()
+		return nil, nil, err
+	}
+	service.link = newBalancerLink(instanceHost, port, service.health.setBalancerLinked, service.applyRemoteConfig)
+	service.link.req.ChallengeType = kind
+	service.link.load = service.reportLoad
+	server := grpc.NewServer()
+	service.register(server)
+	go server.Serve(lis)
+	log.Printf("Dev %s instance listening at %v", kind, lis.Addr())
+
+	linkDone := make(chan struct{})
+	go func() {
+		defer close(linkDone)
+		service.link.run(linkCtx, balancerAddr)
+	}()
+	return service, func() {
+		// linkCtx уже отменен: инстанс отправляет STOPPED и останавливается
+		select {
+		case <-linkDone:
+		case <-time.After(devTimeout):
+		}
+		server.Stop()
+		service.verifier.stop()
+	}, nil
+}
+
+// waitFor опрашивает cond, пока он не выполнится или не истечет devTimeout
+func waitFor(cond func() bool) error {
+	deadline := time.Now().Add(devTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return errors.New("timed out")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/iplist"
+	"captcha-service/internal/policy"
+	"captcha-service/internal/quota"
+)
+
+// dryRunRequest — гипотетический запрос задания для POST /admin/dryrun
+type dryRunRequest struct {
+	SiteKey     string `json:"site_key"`
+	Action      string `json:"action"`
+	IP          string `json:"ip"`
+	Fingerprint string `json:"fingerprint"`
+	UserAgent   string `json:"user_agent"`
+	Complexity  int32  `json:"complexity"`
+	Locale      string `json:"locale"`
+}
+
+// dryRunRule — проверенное правило и сработало ли оно
+type dryRunRule struct {
+	Rule   string `json:"rule"`
+	Fired  bool   `json:"fired"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// dryRunChallenge — задание, которое было бы выдано
+type dryRunChallenge struct {
+	Kind       string `json:"kind"`
+	Complexity int    `json:"complexity"`
+	Pieces     int    `json:"pieces,omitempty"`
+	Locale     string `json:"locale,omitempty"`
+	// Inline — картинки встроены в HTML, а не отдаются по ссылкам
+	Inline bool `json:"inline,omitempty"`
+}
+
+// dryRunResult — итог: decision — challenge, block, rate_limited,
+// quota_exceeded, tenant_full, unavailable, draining, allowlist_pass или
+// invisible_pass (аттестация в пробном прогоне не проверяется)
+type dryRunResult struct {
+	Decision  string           `json:"decision"`
+	Rules     []dryRunRule     `json:"rules"`
+	Policy    policy.Action    `json:"policy"`
+	Challenge *dryRunChallenge `json:"challenge,omitempty"`
+	RiskScore *int32           `json:"risk_score,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+func (r *dryRunResult) rule(name string, fired bool, detail string, args ...any) {
+	if len(args) > 0 {
+		detail = fmt.Sprintf(detail, args...)
+	}
+	r.Rules = append(r.Rules, dryRunRule{Rule: name, Fired: fired, Detail: detail})
+}
+
+// dryRun проходит те же проверки, что и NewChallenge, но ничего не расходует:
+// не учитывает задание в лимитах и квотах, не пишет метрики и не рисует задание
+func (s *captchaService) dryRun(in dryRunRequest) dryRunResult {
+	var res dryRunResult
+	req := &captchapb.ChallengeRequest{
+		SiteKey:    in.SiteKey,
+		Action:     in.Action,
+		Complexity: in.Complexity,
+		Locale:     in.Locale,
+		Client:     &captchapb.ClientContext{Ip: in.IP, UserAgent: in.UserAgent, Fingerprint: in.Fingerprint},
+	}
+	res.Policy = s.policies.Resolve(in.SiteKey, in.Action)
+	if s.health.draining.Load() {
+		res.Decision = "draining"
+		res.rule("draining", true, "instance is draining")
+		return res
+	}
+
+	var listed iplist.Entry
+	if addr, err := netip.ParseAddr(in.IP); err == nil {
+		listed, _ = s.ipLists.Match(addr)
+	}
+	switch listed.List {
+	case "":
+		res.rule("ip_list", false, "")
+	case iplist.Deny:
+		res.rule("ip_list", true, "deny/%s entry %s (%s)", listed.Action, listed.ID, listed.Prefix)
+		if listed.Action == iplist.Block {
+			res.Decision = "block"
+			return res
+		}
+	default:
+		res.rule("ip_list", true, "allow entry %s (%s)", listed.ID, listed.Prefix)
+	}
+
+	if listed.List != iplist.Allow {
+		exceeded := false
+		for _, w := range s.velocity.peek(map[string]string{
+			velocitySourceIP:          in.IP,
+			velocitySourceFingerprint: in.Fingerprint,
+		}, time.Now()) {
+			res.rule("velocity_"+w.rule.Source, w.exceeded(), "%.1f of %d challenges per %s", w.count, w.rule.Max, w.rule.Window)
+			exceeded = exceeded || w.exceeded()
+		}
+		if exceeded {
+			res.Decision = "rate_limited"
+			return res
+		}
+	}
+	if err := s.quotas.Check(in.SiteKey, quota.Challenges); err != nil {
+		res.Decision = "quota_exceeded"
+		res.rule("quota", true, err.Error())
+		return res
+	}
+	res.rule("quota", false, "")
+	if s.challenges.full(in.SiteKey) {
+		res.Decision = "tenant_full"
+		res.rule("tenant_capacity", true, "too many pending challenges")
+		return res
+	}
+
+	spec, err := s.specFor(req, in.IP, countryCode(req.GetClient().GetCountry()), listed)
+	if err != nil {
+		res.Decision = "unavailable"
+		res.Error = err.Error()
+		return res
+	}
+	if rc := s.remote.Load(); rc != nil && rc.targetComplexity > 0 {
+		res.rule("balancer_complexity", true, "complexity %d set by balancer config", rc.targetComplexity)
+	}
+	res.rule("policy_challenge_type", res.Policy.ChallengeType != "" && spec.kind == res.Policy.ChallengeType, res.Policy.ChallengeType)
+	res.Challenge = &dryRunChallenge{Kind: spec.kind, Complexity: spec.complexity, Pieces: spec.pieces, Locale: spec.locale, Inline: spec.inline}
+
+	if spec.listed == iplist.Allow {
+		res.Decision = "allowlist_pass"
+		return res
+	}
+	if spec.invisible {
+		a, passScore := s.assessRisk(req, spec)
+		for _, reason := range a.Reasons {
+			res.rule("risk_"+reason, true, "")
+		}
+		res.RiskScore = &a.Score
+		pass := int(a.Score) >= passScore
+		res.rule("invisible", pass, "risk score %d, pass score %d", a.Score, passScore)
+		if pass {
+			res.Decision = "invisible_pass"
+			return res
+		}
+	}
+	res.Decision = "challenge"
+	return res
+}
+
+// handleDryRun — POST /admin/dryrun: какие правила сработали бы и какое задание
+// было бы выдано для гипотетического запроса (site_key, action, ip, fingerprint,
+// user_agent, complexity, locale)
+func (s *captchaService) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	var in dryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	if in.Complexity < 0 || in.Complexity > 100 {
+		httperr.Write(w, r, http.StatusBadRequest, "complexity must be between 0 and 100")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.dryRun(in))
+}
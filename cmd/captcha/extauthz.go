@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	captchapb "captcha-service/api/captcha/v1"
+	extauthzpb "captcha-service/api/extauthz/v3"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc/codes"
+)
+
+// extAuthzConfig — режим внешней авторизации Envoy: сервис Authorization на
+// gRPC-порту инстанса; включается EXT_AUTHZ
+type extAuthzConfig struct {
+	Enabled bool
+	// TokenHeader — заголовок запроса с токеном результата
+	TokenHeader string
+	// HeaderPrefix — префикс заголовков с оценкой, которые Envoy добавит к
+	// пропущенному запросу ("x-captcha-" → x-captcha-score)
+	HeaderPrefix string
+}
+
+// extAuthzServer проверяет токен результата из запроса, который Envoy
+// авторизует перед отправкой в upstream. Сайт и действие задаются в
+// context_extensions маршрута (site_key, action): так проверка настраивается
+// в конфигурации mesh, а не в коде бэкенда.
+type extAuthzServer struct {
+	extauthzpb.UnimplementedAuthorizationServer
+	s   *captchaService
+	cfg extAuthzConfig
+}
+
+// Check — ext_authz: ALLOW пропускает запрос с заголовками оценки и без
+// заголовка токена, остальное отклоняется ответом Envoy клиенту (401 без
+// токена, 403 с токеном, который не прошел). Токен погашается, как в Assess.
+func (e *extAuthzServer) Check(ctx context.Context, req *extauthzpb.CheckRequest) (*extauthzpb.CheckResponse, error) {
+	attrs := req.GetAttributes()
+	ext := attrs.GetContextExtensions()
+	siteKey := ext["site_key"]
+	token := attrs.GetRequest().GetHttp().GetHeaders()[e.cfg.TokenHeader]
+	if token == "" {
+		extAuthzDecisions.Inc("missing_token")
+		return e.denied(codes.Unauthenticated, http.StatusUnauthorized, nil, "captcha token is missing", "captcha token is missing"), nil
+	}
+
+	res, reason := e.s.assessAnywhere(ctx, &captchapb.AssessRequest{Token: token, Action: ext["action"], SiteKey: siteKey})
+	decision := strings.ToLower(res.GetDecision().String())
+	extAuthzDecisions.Inc(decision)
+	logging.Infof(logging.Verification, siteKey, "ext_authz %s %s from %s, challenge %s: %s (%s)",
+		attrs.GetRequest().GetHttp().GetMethod(), attrs.GetRequest().GetHttp().GetPath(),
+		logging.IP(attrs.GetSource().GetAddress().GetSocketAddress().GetAddress()),
+		tokenChallengeID(token), res.GetDecision(), reason)
+
+	headers := e.headers(map[string]string{
+		"decision":     decision,
+		"score":        strconv.Itoa(int(res.GetConfidencePercent())),
+		"threshold":    strconv.Itoa(int(res.GetThreshold())),
+		"action":       res.GetAction(),
+		"challenge-id": tokenChallengeID(token),
+	})
+	if res.GetDecision() != captchapb.AssessResponse_ALLOW {
+		return e.denied(codes.PermissionDenied, http.StatusForbidden, headers, "captcha verification failed", reason), nil
+	}
+	return &extauthzpb.CheckResponse{
+		Status: &extauthzpb.RpcStatus{Code: int32(codes.OK)},
+		HttpResponse: &extauthzpb.CheckResponse_OkResponse{OkResponse: &extauthzpb.OkHttpResponse{
+			Headers: headers,
+			// upstream получает оценку, а не токен: повторно погасить его нельзя
+			HeadersToRemove: []string{e.cfg.TokenHeader},
+		}},
+	}, nil
+}
+
+// headers — заголовки оценки с префиксом в порядке имен; пустые значения пропускаются
+func (e *extAuthzServer) headers(values map[string]string) []*extauthzpb.HeaderValueOption {
+	list := make([]*extauthzpb.HeaderValueOption, 0, len(values))
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		v := values[name]
+		if v == "" {
+			continue
+		}
+		list = append(list, &extauthzpb.HeaderValueOption{
+			Header: &extauthzpb.HeaderValue{Key: e.cfg.HeaderPrefix + name, Value: v},
+			// OVERWRITE_IF_EXISTS_OR_ADD: клиент не подставит свою оценку
+			AppendAction: 2,
+		})
+	}
+	return list
+}
+
+// denied — отказ: Envoy отвечает клиенту code с JSON-телом {"error": public};
+// причина отказа остается в статусе Check для логов Envoy
+func (e *extAuthzServer) denied(rpc codes.Code, code int, headers []*extauthzpb.HeaderValueOption, public, reason string) *extauthzpb.CheckResponse {
+	body, _ := json.Marshal(map[string]string{"error": public})
+	headers = append(headers, &extauthzpb.HeaderValueOption{
+		Header:       &extauthzpb.HeaderValue{Key: "content-type", Value: "application/json"},
+		AppendAction: 2,
+	})
+	return &extauthzpb.CheckResponse{
+		Status: &extauthzpb.RpcStatus{Code: int32(rpc), Message: reason},
+		HttpResponse: &extauthzpb.CheckResponse_DeniedResponse{DeniedResponse: &extauthzpb.DeniedHttpResponse{
+			Status:  &extauthzpb.HttpStatus{Code: int32(code)},
+			Headers: headers,
+			Body:    string(body),
+		}},
+	}
+}
+
+// assessAnywhere — Assess токена, выданного любым инстансом: Envoy балансирует
+// Check без учета того, где решено задание, поэтому чужой токен погашается на
+// выдавшем его инстансе
+func (s *captchaService) assessAnywhere(ctx context.Context, req *captchapb.AssessRequest) (*captchapb.AssessResponse, string) {
+	if _, err := s.results.get(req.GetToken()); err != nil && s.forwarder != nil {
+		res, ok, err := s.forwarder.assess(ctx, req)
+		if err != nil {
+			logging.Warnf(logging.Verification, req.GetSiteKey(), "Failed to forward assessment of challenge %s: %v", tokenChallengeID(req.GetToken()), err)
+		}
+		if ok {
+			return res, res.GetReason()
+		}
+	}
+	return s.assess(req)
+}
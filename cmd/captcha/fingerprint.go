@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// fingerprintConfig — лимит частоты проверок на отпечаток устройства
+type fingerprintConfig struct {
+	// MaxVerifications — сколько проверок с одного отпечатка допускается за
+	// Window; 0 — лимит выключен и отпечатки не учитываются
+	MaxVerifications int
+	Window           time.Duration
+	// Bits — до скольких бит усекается хэш отпечатка: коллизии намеренные,
+	// по усеченному значению нельзя надежно отличить устройство
+	Bits int
+	// Rotation — период смены ключа хэширования: счетчики прошлого периода
+	// сбрасываются, и одно устройство нельзя связать между периодами
+	Rotation time.Duration
+}
+
+// fingerprintLimiter считает проверки по усеченным хэшам отпечатков. Сам
+// отпечаток из виджета не сохраняется и не пишется в логи.
+type fingerprintLimiter struct {
+	cfg fingerprintConfig
+
+	mu        sync.Mutex
+	key       []byte
+	rotatedAt time.Time
+	counts    *typedStore[int64]
+}
+
+// newFingerprintLimiter возвращает nil, если лимит выключен
+func newFingerprintLimiter(cfg fingerprintConfig) *fingerprintLimiter {
+	if cfg.MaxVerifications <= 0 {
+		return nil
+	}
+	cfg.Bits = min(max(cfg.Bits, 8), 64)
+	if cfg.Rotation <= 0 {
+		cfg.Rotation = 24 * time.Hour
+	}
+	log.Printf("Fingerprint velocity limit: %d verifications per %s, %d-bit buckets rotated every %s",
+		cfg.MaxVerifications, cfg.Window, cfg.Bits, cfg.Rotation)
+	l := &fingerprintLimiter{cfg: cfg, counts: newTypedStore[int64]("fingerprints", cfg.Window)}
+	l.rotate(time.Now())
+	return l
+}
+
+// rotate меняет ключ хэширования и сбрасывает счетчики; вызывается под mu
+func (l *fingerprintLimiter) rotate(now time.Time) {
+	l.key = make([]byte, 32)
+	rand.Read(l.key)
+	l.rotatedAt = now
+	l.counts.flush()
+}
+
+// bucket — усеченный HMAC отпечатка на текущем ключе
+func (l *fingerprintLimiter) bucket(fingerprint string) string {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(fingerprint))
+	sum := mac.Sum(nil)
+	n := (l.cfg.Bits + 7) / 8
+	sum = sum[:n]
+	if rem := l.cfg.Bits % 8; rem != 0 {
+		sum[n-1] &= byte(0xff << (8 - rem))
+	}
+	return hex.EncodeToString(sum)
+}
+
+// allow учитывает проверку с отпечатка и сообщает, укладывается ли она в лимит.
+// Пустой отпечаток (виджет без WebCrypto, старый хост) не ограничивается.
+func (l *fingerprintLimiter) allow(fingerprint string) bool {
+	if l == nil || fingerprint == "" {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := time.Now(); now.Sub(l.rotatedAt) >= l.cfg.Rotation {
+		l.rotate(now)
+	}
+	key := l.bucket(fingerprint)
+	return increment(l.counts, key, 1) <= int64(l.cfg.MaxVerifications)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/flags"
+	"captcha-service/internal/generator"
+	"captcha-service/internal/logging"
+)
+
+// Флаги функций инстанса
+const (
+	flagRotate = "challenge.slider-rotate"
+	flagMulti  = "challenge.slider-multi"
+	// flagHiddenControls — скрытые слайдеры-приманки в обфускации; выключенный
+	// флаг дает виджету уровень generator.ObfuscationTouch
+	flagHiddenControls = "obfuscation.hidden-controls"
+	flagInvisible      = "invisible"
+)
+
+// defaultFlags — известные флаги; все включены, как до появления флагов
+func defaultFlags() map[string]bool {
+	return map[string]bool{
+		flagRotate:         true,
+		flagMulti:          true,
+		flagHiddenControls: true,
+		flagInvisible:      true,
+	}
+}
+
+// kindFlags — флаги типов заданий; выключенный тип откатывается по kindFallback
+var kindFlags = map[string]string{
+	generator.KindRotate: flagRotate,
+	generator.KindMulti:  flagMulti,
+}
+
+// flagsConfig — источники правил флагов на инстансе
+type flagsConfig struct {
+	// File — JSON с правилами (см. flags.LoadFile), перечитывается раз в
+	// ReloadInterval при изменении; Env — FEATURE_FLAGS, перекрывает файл
+	File           string
+	Env            string
+	ReloadInterval time.Duration
+}
+
+// flagUnit — ключ клиента, по которому делится доля трафика флага
+func flagUnit(req *captchapb.ChallengeRequest, ip string) string {
+	return cmp.Or(req.GetClient().GetSessionId(), ip, req.GetClient().GetFingerprint())
+}
+
+// flag вычисляет флаг для запроса сайта siteKey и учитывает решение в метриках
+func (s *captchaService) flag(name, siteKey, unit string) bool {
+	d := s.flags.Enabled(name, siteKey, unit)
+	featureFlagEvaluations.Inc(name, strconv.FormatBool(d.Enabled), d.Source.String())
+	if !d.Enabled {
+		logging.Debugf(logging.Generator, siteKey, "Feature %s is off for this request (%s rule)", name, d.Source)
+	}
+	return d.Enabled
+}
+
+// flaggedKind откатывает тип задания, выключенный флагом, на более простой
+func (s *captchaService) flaggedKind(kind, siteKey, unit string) string {
+	for {
+		name, ok := kindFlags[kind]
+		if !ok || s.flag(name, siteKey, unit) {
+			return kind
+		}
+		kind = kindFallback[kind]
+	}
+}
+
+// loadFlags применяет правила из файла и окружения при старте
+func (s *captchaService) loadFlags(cfg flagsConfig) error {
+	if cfg.File != "" {
+		if _, err := s.reloadFlagsFile(cfg.File, time.Time{}); err != nil {
+			return err
+		}
+	}
+	env, err := flags.ParseEnv(cfg.Env)
+	if err != nil {
+		return err
+	}
+	if err := s.flags.Validate(env); err != nil {
+		return err
+	}
+	s.flags.Set(flags.Env, env)
+	return nil
+}
+
+// reloadFlagsFile перечитывает файл флагов, если он изменился после seen, и
+// возвращает время изменения примененной версии
+func (s *captchaService) reloadFlagsFile(path string, seen time.Time) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return seen, err
+	}
+	if !info.ModTime().After(seen) {
+		return seen, nil
+	}
+	set, err := flags.LoadFile(path)
+	if err != nil {
+		return seen, err
+	}
+	if err := s.flags.Validate(set); err != nil {
+		return seen, err
+	}
+	s.flags.Set(flags.File, set)
+	return info.ModTime(), nil
+}
+
+// watchFlags перечитывает файл флагов при изменении; битый файл не отменяет
+// действующие правила
+func (s *captchaService) watchFlags(ctx context.Context, path string, interval time.Duration) {
+	seen := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		seen = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		modTime, err := s.reloadFlagsFile(path, seen)
+		if err != nil {
+			logging.Errorf(logging.Generator, "", "Failed to reload feature flags, keeping previous rules: %v", err)
+			continue
+		}
+		if modTime != seen {
+			seen = modTime
+			logging.Infof(logging.Generator, "", "Feature flags reloaded: %s", s.flags.Summary())
+		}
+	}
+}
+
+// applyRemoteFlags заменяет правила балансера. Флаги, которых инстанс не
+// знает (балансер новее), и правила с неверной долей пропускаются.
+func (s *captchaService) applyRemoteFlags(remote map[string]*balancerpb.FeatureFlag) {
+	before := s.flags.Summary()
+	set := make(flags.Set, len(remote))
+	for name, f := range remote {
+		rule := flags.Rule{Percent: int(f.GetPercent()), Sites: f.GetSites()}
+		if err := s.flags.Validate(flags.Set{name: rule}); err != nil {
+			logging.Warnf(logging.Generator, "", "Ignoring balancer feature flag: %v", err)
+			continue
+		}
+		set[name] = rule
+	}
+	s.flags.Set(flags.Remote, set)
+	if after := s.flags.Summary(); after != before {
+		logging.Infof(logging.Generator, "", "Feature flags updated by balancer: %s", after)
+	}
+}
+
+// handleFlags — GET /admin/flags: действующее правило каждого флага и его источник
+func (s *captchaService) handleFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.flags.Effective())
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/httpsec"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// formTokenParam — параметр return_to, в котором сайт получает токен результата
+const formTokenParam = "captcha_token"
+
+// formFlowConfig — проверка для серверных форм без fetch и postMessage на
+// стороне сайта (WordPress, старые формы входа): сайт отправляет пользователя
+// на GET /challenge/form, а после решения получает его обратно на return_to
+// с токеном в captcha_token и погашает токен через /siteverify, как обычно.
+// Включается FORM_RETURN_ORIGINS.
+type formFlowConfig struct {
+	// ReturnOrigins — источники, на которые можно вернуть пользователя (как в
+	// CORS_ALLOWED_ORIGINS, "*." — любой поддомен); без них return_to был бы
+	// открытым редиректом
+	ReturnOrigins []string
+	// CountryHeader — заголовок, в котором CDN передает страну клиента
+	// (CF-IPCountry), для сайтов с geo_pin=country; пусто — страна неизвестна.
+	// Принимается только от TRUSTED_PROXIES, как и X-Forwarded-For.
+	CountryHeader string
+}
+
+// country — страна клиента из заголовка CDN
+func (c formFlowConfig) country(r *http.Request) string {
+	if c.CountryHeader == "" {
+		return ""
+	}
+	return countryCode(r.Header.Get(c.CountryHeader))
+}
+
+func (c formFlowConfig) enabled() bool { return len(c.ReturnOrigins) > 0 }
+
+// returnURL разбирает return_to и проверяет его источник
+func (c formFlowConfig) returnURL(raw string) (*url.URL, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, false
+	}
+	origin := u.Scheme + "://" + u.Host
+	for _, pattern := range c.ReturnOrigins {
+		if httpsec.MatchOrigin(pattern, origin) {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// formPage — страница задания: виджет в iframe, его сообщения заполняют
+// скрытые поля формы, а решение отправляется обычным submit
+var formPage = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <meta name="referrer" content="no-referrer">
+    <title>Verification</title>
+    <style>
+        body { font-family: sans-serif; display: flex; flex-direction: column; align-items: center; margin-top: 40px; }
+        iframe { border: 0; width: 420px; height: 420px; max-width: 100%; }
+        .error { color: #b00020; }
+    </style>
+</head>
+<body>
+    {{if .Retry}}<p class="error">Verification failed, please try again.</p>{{end}}
+    <noscript><p class="error">This check requires JavaScript.</p></noscript>
+    <iframe id="captcha-frame" srcdoc="{{.HTML}}"></iframe>
+    <form id="captcha-form" method="post" action="/verify/form">
+        <input type="hidden" name="challenge_id" value="{{.ChallengeID}}">
+        <input type="hidden" name="site_key" value="{{.SiteKey}}">
+        <input type="hidden" name="action" value="{{.Action}}">
+        <input type="hidden" name="locale" value="{{.Locale}}">
+        <input type="hidden" name="return_to" value="{{.ReturnTo}}">
+        <input type="hidden" name="data">
+        <input type="hidden" name="fingerprint">
+        <input type="hidden" name="width">
+        <input type="hidden" name="height">
+        <input type="hidden" name="dpr">
+    </form>
+    <script>
+        const form = document.getElementById("captcha-form");
+        const frame = document.getElementById("captcha-frame");
+        window.addEventListener("message", (e) => {
+            if (e.source !== frame.contentWindow) {
+                return;
+            }
+            switch (e.data?.type) {
+            case "captcha:calibrate":
+                form.width.value = e.data.width;
+                form.height.value = e.data.height;
+                form.dpr.value = e.data.dpr;
+                break;
+            case "captcha:sendData":
+                form.data.value = e.data.data;
+                form.fingerprint.value = e.data.fingerprint || "";
+                form.submit();
+                break;
+            case "captcha:refresh":
+            case "captcha:renderRejected":
+                location.reload();
+                break;
+            }
+        });
+    </script>
+</body>
+</html>
+`))
+
+// formPageData — поля formPage
+type formPageData struct {
+	HTML        string
+	ChallengeID string
+	SiteKey     string
+	Action      string
+	Locale      string
+	ReturnTo    string
+	Retry       bool
+}
+
+// handleChallengeForm — GET /challenge/form (site_key, action, return_to,
+// locale в query): страница с заданием. Клиенту, которому задание не нужно
+// (allowlist, невидимый режим), токен выдается сразу.
+func (s *captchaService) handleChallengeForm(w http.ResponseWriter, r *http.Request, cfg formFlowConfig) {
+	q := r.URL.Query()
+	returnTo, ok := cfg.returnURL(q.Get("return_to"))
+	if !ok {
+		httperr.Write(w, r, http.StatusBadRequest, "return_to is missing or its origin is not allowed")
+		return
+	}
+	res, err := s.NewChallenge(inProcess(r.Context()), &captchapb.ChallengeRequest{
+		SiteKey: q.Get("site_key"),
+		Action:  q.Get("action"),
+		Locale:  q.Get("locale"),
+		Client: &captchapb.ClientContext{
+			Ip:        s.httpClientIP(r),
+			UserAgent: r.UserAgent(),
+			Hostname:  returnTo.Hostname(),
+			Country:   s.httpCountry(r, cfg),
+		},
+	})
+	if err != nil {
+		httperr.Write(w, r, httpStatusFor(err), status.Convert(err).Message())
+		return
+	}
+	if res.GetToken() != "" {
+		formResults.Inc("passed_without_challenge")
+		redirectWithToken(w, r, returnTo, res.GetToken())
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err = formPage.Execute(w, formPageData{
+		HTML:        res.GetHtml(),
+		ChallengeID: res.GetChallengeId(),
+		SiteKey:     q.Get("site_key"),
+		Action:      q.Get("action"),
+		Locale:      q.Get("locale"),
+		ReturnTo:    returnTo.String(),
+		Retry:       q.Get("retry") != "",
+	})
+	if err != nil {
+		logging.Warnf(logging.Generator, q.Get("site_key"), "Failed to write challenge form for %s: %v", res.GetChallengeId(), err)
+	}
+}
+
+// handleVerifyForm — POST /verify/form: ответ со страницы formPage. Решенное
+// задание возвращает пользователя на return_to с токеном, нерешенное — на
+// новое задание.
+func (s *captchaService) handleVerifyForm(w http.ResponseWriter, r *http.Request, cfg formFlowConfig) {
+	returnTo, ok := cfg.returnURL(r.FormValue("return_to"))
+	if !ok {
+		httperr.Write(w, r, http.StatusBadRequest, "return_to is missing or its origin is not allowed")
+		return
+	}
+	challengeID := r.FormValue("challenge_id")
+	siteKey := r.FormValue("site_key")
+	if r.FormValue("width") != "" {
+		width, _ := strconv.ParseFloat(r.FormValue("width"), 64)
+		height, _ := strconv.ParseFloat(r.FormValue("height"), 64)
+		dpr, _ := strconv.ParseFloat(r.FormValue("dpr"), 64)
+		s.calibrate(&captchapb.ClientEvent{ChallengeId: challengeID, Calibration: &captchapb.Calibration{
+			RenderedWidth:    width,
+			RenderedHeight:   height,
+			DevicePixelRatio: dpr,
+		}})
+	}
+	sub := submission{
+		data:        []byte(r.FormValue("data")),
+		fingerprint: r.FormValue("fingerprint"),
+		siteKey:     siteKey,
+		clientIP:    s.httpClientIP(r),
+		region:      s.region,
+		country:     s.httpCountry(r, cfg),
+	}
+	v, _, _ := s.verifyFlight.Do(sub.flightKey(challengeID), func() (any, error) {
+		return s.evaluateSolution(challengeID, sub, false), nil
+	})
+	result := v.(verifyReply).event.GetResult()
+	if result.GetPassed() && result.GetToken() != "" {
+		formResults.Inc("passed")
+		redirectWithToken(w, r, returnTo, result.GetToken())
+		return
+	}
+	formResults.Inc("retry")
+	logging.Debugf(logging.Verification, siteKey, "Form solution for challenge %s did not pass, issuing a new challenge", challengeID)
+	retry := url.Values{
+		"site_key":  {siteKey},
+		"action":    {r.FormValue("action")},
+		"locale":    {r.FormValue("locale")},
+		"return_to": {returnTo.String()},
+		"retry":     {"1"},
+	}
+	http.Redirect(w, r, "/challenge/form?"+retry.Encode(), http.StatusSeeOther)
+}
+
+// redirectWithToken возвращает пользователя на return_to с токеном результата
+func redirectWithToken(w http.ResponseWriter, r *http.Request, returnTo *url.URL, token string) {
+	u := *returnTo
+	q := u.Query()
+	q.Set(formTokenParam, token)
+	u.RawQuery = q.Encode()
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
+
+// remoteIP — адрес HTTP-соединения клиента
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// httpClientIP — адрес клиента HTTP-запроса: через доверенные прокси
+// (TRUSTED_PROXIES) — из X-Forwarded-For, иначе адрес соединения, как
+// clientIP для gRPC
+func (s *captchaService) httpClientIP(r *http.Request) string {
+	return s.trustedProxies.ForwardedClient(remoteIP(r), r.Header.Get("X-Forwarded-For"))
+}
+
+// httpCountry — страна клиента из заголовка CDN, только если запрос пришел
+// от доверенного прокси: иначе заголовок подставил бы сам клиент
+func (s *captchaService) httpCountry(r *http.Request, cfg formFlowConfig) string {
+	if !s.trustedProxies.Trusted(remoteIP(r)) {
+		return ""
+	}
+	return cfg.country(r)
+}
+
+// httpStatusFor — HTTP-код для ошибки gRPC-метода
+func httpStatusFor(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// solutionForwarder пересылает решения заданий, неизвестных инстансу, на
+// инстанс, который их выдал: адрес берется из таблицы маршрутов балансера.
+// Дополняет общее хранилище заданий, но не требует его.
+type solutionForwarder struct {
+	link    *balancerLink
+	timeout time.Duration
+
+	mu    sync.Mutex
+	peers map[string]*grpc.ClientConn
+}
+
+func newSolutionForwarder(link *balancerLink, timeout time.Duration) *solutionForwarder {
+	return &solutionForwarder{link: link, timeout: timeout, peers: make(map[string]*grpc.ClientConn)}
+}
+
+// peer возвращает соединение с инстансом; соединения переиспользуются. Сосед
+// получает те же TLS и токен регистрации, что и балансер: без них он не
+// примет ForwardSolution.
+func (f *solutionForwarder) peer(addr string) (captchapb.CaptchaServiceClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	conn, ok := f.peers[addr]
+	if !ok {
+		var err error
+		if conn, err = grpc.NewClient(addr, f.link.credentials()...); err != nil {
+			return nil, err
+		}
+		f.peers[addr] = conn
+	}
+	return captchapb.NewCaptchaServiceClient(conn), nil
+}
+
+// owner находит инстанс, выдавший задание, и возвращает клиент к нему.
+// false — задание неизвестно балансеру или выдано этим же инстансом.
+func (f *solutionForwarder) owner(ctx context.Context, challengeID string) (captchapb.CaptchaServiceClient, string, bool, error) {
+	route, err := f.link.lookupChallenge(ctx, challengeID)
+	if status.Code(err) == codes.NotFound {
+		return nil, "", false, nil
+	}
+	if err != nil {
+		return nil, "", false, fmt.Errorf("balancer lookup failed: %w", err)
+	}
+	if route.GetInstanceId() == f.link.instanceID() {
+		return nil, "", false, nil
+	}
+	addr := net.JoinHostPort(route.GetHost(), strconv.Itoa(int(route.GetPortNumber())))
+	client, err := f.peer(addr)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to connect to instance %s at %s: %w", route.GetInstanceId(), addr, err)
+	}
+	return client, fmt.Sprintf("instance %s at %s", route.GetInstanceId(), addr), true, nil
+}
+
+// forward находит инстанс, выдавший задание, и проверяет решение на нем.
+// false — задание неизвестно балансеру или выдано этим же инстансом.
+func (f *solutionForwarder) forward(challengeID string, sub submission) (*captchapb.ServerEvent, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	client, name, ok, err := f.owner(ctx, challengeID)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	event, err := client.ForwardSolution(ctx, &captchapb.ForwardSolutionRequest{
+		ChallengeId:  challengeID,
+		Data:         sub.data,
+		Fingerprint:  sub.fingerprint,
+		FromInstance: f.link.instanceID(),
+		SiteKey:      sub.siteKey,
+		SessionId:    sub.session,
+		ClientIp:     sub.clientIP,
+		Region:       sub.region,
+		Country:      sub.country,
+		StreamId:     sub.stream,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return event, true, nil
+}
+
+// assess погашает токен результата на инстансе, выдавшем задание
+func (f *solutionForwarder) assess(ctx context.Context, req *captchapb.AssessRequest) (*captchapb.AssessResponse, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	client, name, ok, err := f.owner(ctx, tokenChallengeID(req.GetToken()))
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	res, err := client.Assess(ctx, req)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return res, true, nil
+}
+
+// close закрывает соединения с другими инстансами
+func (f *solutionForwarder) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for addr, conn := range f.peers {
+		conn.Close()
+		delete(f.peers, addr)
+	}
+}
+
+// forwardSolution пробует проверить решение неизвестного задания на инстансе,
+// который его выдал; false — переслать не удалось, и виджет получит REFRESH
+func (s *captchaService) forwardSolution(challengeID string, sub submission) (verifyReply, bool) {
+	if s.forwarder == nil {
+		return verifyReply{}, false
+	}
+	event, ok, err := s.forwarder.forward(challengeID, sub)
+	switch {
+	case err != nil:
+		forwardedVerifications.Inc("error")
+		logging.Warnf(logging.Verification, "", "Failed to forward solution for challenge %s: %v", challengeID, err)
+		return verifyReply{}, false
+	case !ok:
+		forwardedVerifications.Inc("unknown")
+		return verifyReply{}, false
+	}
+	forwardedVerifications.Inc("forwarded")
+	logging.Infof(logging.Verification, "", "Solution for challenge %s verified by the issuing instance", challengeID)
+	if event.GetEvent() == nil {
+		// Инстанс-владелец ответил пустым событием: виджету отвечать нечем
+		return verifyReply{}, true
+	}
+	return verifyReply{what: "forwarded result", event: event}, true
+}
+
+// fleetCaller — вызывающий входит во флот: доверенный прокси (TRUSTED_PROXIES)
+// или соседний инстанс с токеном регистрации (BALANCER_REGISTRATION_TOKEN)
+func (s *captchaService) fleetCaller(ctx context.Context) bool {
+	return s.trustedCaller(ctx) || (s.peerAuth != nil && s.peerAuth.Authenticate(ctx) == nil)
+}
+
+// ForwardSolution проверяет решение, которое виджет прислал другому инстансу.
+// Метод того же публичного сервиса, поэтому принимается только от флота.
+func (s *captchaService) ForwardSolution(ctx context.Context, req *captchapb.ForwardSolutionRequest) (*captchapb.ServerEvent, error) {
+	if !s.fleetCaller(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "ForwardSolution is accepted only from instances of the fleet")
+	}
+	challengeID := req.GetChallengeId()
+	if challengeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "challenge_id is required")
+	}
+	logging.Debugf(logging.Verification, "", "Solution for challenge %s forwarded by instance %s", challengeID, req.GetFromInstance())
+	// Адрес, регион и страну клиента уже разрешил получивший инстанс
+	sub := submission{
+		data:        req.GetData(),
+		fingerprint: req.GetFingerprint(),
+		siteKey:     req.GetSiteKey(),
+		session:     req.GetSessionId(),
+		clientIP:    cmp.Or(req.GetClientIp(), peerIP(ctx)),
+		region:      req.GetRegion(),
+		country:     countryCode(req.GetCountry()),
+	}
+	// Стрим виджета на получившем инстансе: ответы из разных стримов,
+	// в том числе разных инстансов, проваливают задание, как и локальные
+	sub.stream = req.GetStreamId()
+	claim := streamClaim{Instance: req.GetFromInstance(), Stream: req.GetStreamId(), IP: sub.clientIP}
+	reply, done := s.claimSolution(challengeID, sub, claim)
+	if !done {
+		v, _, shared := s.verifyFlight.Do(forwardFlightKey(req.GetFromInstance(), sub, challengeID), func() (any, error) {
+			return s.evaluateSolution(challengeID, sub, true), nil
+		})
+		if shared {
+			verificationsDeduplicated.Inc()
+		}
+		reply = v.(verifyReply)
+	}
+	if reply.event != nil {
+		return reply.event, nil
+	}
+	return &captchapb.ServerEvent{}, nil
+}
+
+// forwardFlightKey — ключ схлопывания пересланных проверок: номера стримов
+// разных инстансов совпадают, поэтому в ключ входит и переславший инстанс
+func forwardFlightKey(fromInstance string, sub submission, challengeID string) string {
+	return fromInstance + "\x00" + sub.flightKey(challengeID)
+}
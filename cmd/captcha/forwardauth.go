@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/middleware"
+)
+
+// forwardAuthConfig — /forward-auth для обратных прокси (Traefik ForwardAuth,
+// nginx auth_request); включается FORWARD_AUTH и работает поверх режима
+// сессионных cookie
+type forwardAuthConfig struct {
+	Enabled bool
+	// Header — заголовок с сессией для клиентов без cookie (API, мобильные приложения)
+	Header string
+	// ClockSkew — допуск к сроку сессии (CLOCK_SKEW_TOLERANCE): cookie мог
+	// выставить другой инстанс
+	ClockSkew time.Duration
+}
+
+// handleForwardAuth — /forward-auth: прокси передает сюда заголовки исходного
+// запроса и пропускает его при 200. Сессия берется из cookie или заголовка
+// Header; site_key и action в query, если заданы, должны с ней совпасть.
+// В отличие от /session сессия не погашается: прокси проверяет ее на каждом
+// запросе, пока она не истечет.
+func (s *captchaService) handleForwardAuth(w http.ResponseWriter, r *http.Request, cfg forwardAuthConfig, session sessionConfig) {
+	value := r.Header.Get(cfg.Header)
+	if cookie, err := r.Cookie(session.Name); err == nil {
+		value = cookie.Value
+	}
+	siteKey := r.URL.Query().Get("site_key")
+	opts := middleware.Options{SiteKey: siteKey, Action: r.URL.Query().Get("action"), ClockSkew: cfg.ClockSkew}
+
+	c, err := opts.Check(session.Secret, value, time.Now())
+	result := "allowed"
+	switch {
+	case value == "":
+		result, err = "missing", middleware.ErrNoSession
+	case errors.Is(err, middleware.ErrExpired):
+		result = "expired"
+	case err != nil:
+		result = "invalid"
+	case s.sharedChallenge(c.ChallengeID):
+		result, err = "shared", errors.New("captcha session belongs to a challenge answered from more than one stream")
+	}
+	forwardAuths.Inc(result)
+	// Прокси вызывает ручку на каждый запрос: в лог — только на debug
+	logging.Debugf(logging.Verification, siteKey, "Forward auth for %s %s from %s: %s",
+		r.Header.Get("X-Forwarded-Method"), r.Header.Get("X-Forwarded-Uri"), logging.IP(r.Header.Get("X-Forwarded-For")), result)
+
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil {
+		httperr.Write(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	// Заголовки для upstream: Traefik копирует перечисленные в authResponseHeaders,
+	// nginx читает их через auth_request_set
+	w.Header().Set("X-Captcha-Challenge-Id", c.ChallengeID)
+	w.Header().Set("X-Captcha-Action", c.Action)
+	w.Header().Set("X-Captcha-Score", strconv.Itoa(int(c.Confidence)))
+	w.Header().Set("X-Captcha-Expires", strconv.FormatInt(c.ExpiresAt, 10))
+	w.WriteHeader(http.StatusOK)
+}
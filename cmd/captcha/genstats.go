@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+)
+
+const (
+	// genStatsWindow — окно скользящей статистики генерации в heartbeat
+	genStatsWindow = time.Minute
+	// genStatsBucketWidth — ширина корзины сложности (0-24, 25-49, 50-74, 75-100)
+	genStatsBucketWidth = 25
+	genStatsBuckets     = 4
+	// genStatsMaxSamples ограничивает память корзины при пиковой нагрузке
+	genStatsMaxSamples = 4096
+)
+
+// genSample — одна генерация задания
+type genSample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// genBucket — статистика одной корзины сложности
+type genBucket struct {
+	mu      sync.Mutex
+	samples []genSample
+	// queued — задания, ждущие отрисовки или рисующиеся сейчас
+	queued atomic.Int32
+}
+
+// generationStats собирает время генерации и глубину очереди по корзинам сложности:
+// по ним балансер оценивает, сколько инстансов нужно под текущий спрос
+type generationStats struct {
+	buckets [genStatsBuckets]genBucket
+}
+
+func bucketFor(complexity int) int {
+	return min(max(complexity, 0)/genStatsBucketWidth, genStatsBuckets-1)
+}
+
+// enqueue учитывает задание в очереди; done вызывается по окончании отрисовки
+// и возвращает его в статистику; ok сообщает, удалось ли отрисовать задание
+func (g *generationStats) enqueue(complexity int) (done func(start time.Time, ok bool)) {
+	b := &g.buckets[bucketFor(complexity)]
+	b.queued.Add(1)
+	return func(start time.Time, ok bool) {
+		b.queued.Add(-1)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.prune(now)
+		if len(b.samples) >= genStatsMaxSamples {
+			b.samples = b.samples[1:]
+		}
+		b.samples = append(b.samples, genSample{at: now, duration: now.Sub(start)})
+	}
+}
+
+// prune удаляет замеры старше окна; вызывается под b.mu
+func (b *genBucket) prune(now time.Time) {
+	i := 0
+	for i < len(b.samples) && now.Sub(b.samples[i].at) > genStatsWindow {
+		i++
+	}
+	b.samples = b.samples[i:]
+}
+
+// snapshot возвращает статистику для heartbeat; пустые корзины пропускаются
+func (g *generationStats) snapshot() []*balancerpb.ComplexityStats {
+	now := time.Now()
+	var stats []*balancerpb.ComplexityStats
+	for i := range g.buckets {
+		b := &g.buckets[i]
+		b.mu.Lock()
+		b.prune(now)
+		durations := make([]time.Duration, len(b.samples))
+		for j, s := range b.samples {
+			durations[j] = s.duration
+		}
+		b.mu.Unlock()
+
+		queued := b.queued.Load()
+		if len(durations) == 0 && queued == 0 {
+			continue
+		}
+		st := &balancerpb.ComplexityStats{
+			MinComplexity: int32(i * genStatsBucketWidth),
+			MaxComplexity: int32((i+1)*genStatsBucketWidth - 1),
+			WindowSeconds: int32(genStatsWindow.Seconds()),
+			Generated:     int64(len(durations)),
+			QueueDepth:    queued,
+		}
+		if i == genStatsBuckets-1 {
+			st.MaxComplexity = 100
+		}
+		if len(durations) > 0 {
+			slices.Sort(durations)
+			var total time.Duration
+			for _, d := range durations {
+				total += d
+			}
+			st.AvgGenerationMs = float64(total.Microseconds()) / 1000 / float64(len(durations))
+			st.P95GenerationMs = float64(durations[(len(durations)*95-1)/100].Microseconds()) / 1000
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/handoff"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// handoffConfig — передача незавершенных заданий при остановке инстанса
+type handoffConfig struct {
+	// Secret — общий секрет флота, которым шифруется состояние; пустой — передача выключена
+	Secret []byte
+	// Timeout ограничивает передачу целиком
+	Timeout time.Duration
+}
+
+// handoffState — то, что инстанс передает при остановке
+type handoffState struct {
+	From       string
+	ExportedAt time.Time
+	Challenges []pendingChallenge
+}
+
+// handOffChallenges передает задания, не решенные к концу drain, другому
+// инстансу через балансер: после остановки инстанса они по-прежнему проверяются.
+// Переданные задания удаляются локально, их решения уйдут на новый инстанс.
+func (s *captchaService) handOffChallenges(timeout time.Duration) {
+	if s.handoff == nil {
+		return
+	}
+	pending := s.challenges.pending()
+	if len(pending) == 0 {
+		return
+	}
+	sealed, err := s.handoff.Seal(handoffState{From: s.link.instanceID(), ExportedAt: time.Now(), Challenges: pending})
+	if err != nil {
+		logging.Errorf(logging.Balancer, "", "Failed to seal %d pending challenges for handoff: %v", len(pending), err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	res, err := s.link.handoff(ctx, sealed)
+	if err != nil {
+		handoffChallenges.Add(int64(len(pending)), "failed")
+		logging.Warnf(logging.Balancer, "", "Failed to hand off %d pending challenges: %v", len(pending), err)
+		return
+	}
+	handoffChallenges.Add(int64(res.GetImported()), "exported")
+	logging.Infof(logging.Balancer, "", "Handed off %d of %d pending challenges to instance %s", res.GetImported(), len(pending), res.GetInstanceId())
+	for _, c := range pending {
+		s.challenges.delete(c.ID)
+	}
+}
+
+// ImportChallenges принимает задания инстанса, уходящего на остановку
+func (s *captchaService) ImportChallenges(ctx context.Context, req *captchapb.ImportChallengesRequest) (*captchapb.ImportChallengesResponse, error) {
+	if s.handoff == nil {
+		return nil, status.Error(codes.FailedPrecondition, "challenge handoff is disabled on this instance")
+	}
+	if s.health.draining.Load() {
+		return nil, status.Error(codes.Unavailable, "captcha instance is draining")
+	}
+	var state handoffState
+	if err := s.handoff.Open(req.GetSealedState(), &state); err != nil {
+		if errors.Is(err, handoff.ErrInvalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &captchapb.ImportChallengesResponse{}
+	for _, c := range state.Challenges {
+		if s.challenges.adopt(c) {
+			res.ChallengeIds = append(res.ChallengeIds, c.ID)
+		}
+	}
+	handoffChallenges.Add(int64(len(res.ChallengeIds)), "imported")
+	logging.Infof(logging.Balancer, "", "Imported %d of %d pending challenges from instance %s", len(res.ChallengeIds), len(state.Challenges), state.From)
+	return res, nil
+}
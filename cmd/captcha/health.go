@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// instanceHealth хранит состояние инстанса для liveness/readiness-проверок
+// по HTTP и синхронизирует его со стандартным gRPC health-сервисом
+type instanceHealth struct {
+	grpc *health.Server
+
+	balancerLinked atomic.Bool
+	draining       atomic.Bool
+
+	// Прогресс прогрева: пока warming, инстанс не готов
+	warming     atomic.Bool
+	warmupTotal atomic.Int32
+	warmupDone  atomic.Int32
+}
+
+func newInstanceHealth() *instanceHealth {
+	return &instanceHealth{grpc: health.NewServer()}
+}
+
+// setBalancerLinked отмечает, зарегистрирован ли инстанс в балансере
+func (h *instanceHealth) setBalancerLinked(linked bool) {
+	h.balancerLinked.Store(linked)
+	h.syncGRPC()
+}
+
+// setDraining переводит инстанс в режим остановки: он перестает быть ready
+func (h *instanceHealth) setDraining() {
+	h.draining.Store(true)
+	h.syncGRPC()
+}
+
+func (h *instanceHealth) startWarmup(total int) {
+	h.warmupTotal.Store(int32(total))
+	h.warmupDone.Store(0)
+	h.warming.Store(true)
+	h.syncGRPC()
+}
+
+func (h *instanceHealth) warmupProgress() {
+	h.warmupDone.Add(1)
+}
+
+func (h *instanceHealth) finishWarmup() {
+	h.warming.Store(false)
+	h.syncGRPC()
+}
+
+func (h *instanceHealth) syncGRPC() {
+	status := healthpb.HealthCheckResponse_SERVING
+	if h.draining.Load() || h.warming.Load() || !h.balancerLinked.Load() {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.grpc.SetServingStatus("", status)
+	h.grpc.SetServingStatus("captcha.v1.CaptchaService", status)
+}
+
+// readinessChecks проверяет зависимости, без которых инстанс не может обслуживать задания
+func (s *captchaService) readinessChecks() map[string]error {
+	checks := map[string]error{
+		"generator": nil,
+		"store":     nil,
+		"balancer":  nil,
+		"draining":  nil,
+		"warmup":    nil,
+	}
+	if s.generator == nil {
+		checks["generator"] = errors.New("generator is not initialized")
+	}
+	if err := s.challenges.probe(); err != nil {
+		checks["store"] = err
+	}
+	if !s.health.balancerLinked.Load() {
+		checks["balancer"] = errors.New("not registered in balancer")
+	}
+	if s.health.draining.Load() {
+		checks["draining"] = errors.New("instance is draining")
+	}
+	if s.health.warming.Load() {
+		checks["warmup"] = fmt.Errorf("warming up: %d/%d challenges generated", s.health.warmupDone.Load(), s.health.warmupTotal.Load())
+	}
+	return checks
+}
+
+// handleHealthz — liveness: процесс жив и отвечает
+func (s *captchaService) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+// handleReadyz — readiness: 200, если все проверки пройдены, иначе 503 с описанием
+func (s *captchaService) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := s.readinessChecks()
+	ready := true
+	body := make(map[string]string, len(checks))
+	for name, err := range checks {
+		if err != nil {
+			ready = false
+			body[name] = err.Error()
+			continue
+		}
+		body[name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ready": ready, "checks": body})
+}
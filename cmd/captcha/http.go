@@ -0,0 +1,297 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"captcha-service/internal/acme"
+	"captcha-service/internal/audit"
+	"captcha-service/internal/generator"
+	"captcha-service/internal/httpcompress"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/httpserve"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/metrics"
+	"captcha-service/internal/openapi"
+)
+
+// startHTTPServer поднимает служебный HTTP-сервер: метрики, liveness/readiness-проверки
+// для балансировщиков без поддержки gRPC, картинки заданий и административные ручки
+func startHTTPServer(cfg config, port int, service *captchaService) {
+	mux := http.NewServeMux()
+	// Ручки описываются при регистрации и попадают в /openapi.json
+	api := openapi.New("captcha-service")
+	handle := func(pattern string, h http.Handler, ops ...openapi.Op) {
+		mux.Handle(pattern, h)
+		api.Add(pattern, ops...)
+	}
+	handleFunc := func(pattern string, h http.HandlerFunc, ops ...openapi.Op) {
+		handle(pattern, h, ops...)
+	}
+	// Административные ручки — только с токеном из ADMIN_TOKENS
+	adminFunc := func(pattern string, h http.HandlerFunc, ops ...openapi.Op) {
+		handle(pattern, cfg.Admin.authorize(h), ops...)
+	}
+	if !cfg.Admin.enabled() {
+		log.Println("Admin API is disabled: set ADMIN_TOKENS to enable /admin/*")
+	}
+	mux.Handle("/metrics", httpcompress.Handler(metrics.Handler()))
+	handleFunc("/healthz", service.handleHealthz, openapi.Op{Summary: "Liveness probe"})
+	handleFunc("/readyz", service.handleReadyz, openapi.Op{Summary: "Readiness probe"})
+	// Картинки и /session запрашивают страницы сайтов-клиентов: им нужен CORS
+	assets := cfg.HTTPSecurity.CORS(http.HandlerFunc(service.handleAsset))
+	handle("GET /assets/{key}/{name}", assets, openapi.Op{Summary: "Challenge image", ContentType: "image/*"})
+	mux.Handle("OPTIONS /assets/{key}/{name}", assets)
+	if service.staticFiles != nil {
+		handle("GET /static/{name}", httpcompress.Handler(service.staticFiles), openapi.Op{Summary: "Widget CSS or JS by content hash", ContentType: "text/*"})
+	}
+	render := cfg.HTTPSecurity.CORS(http.HandlerFunc(service.handleRender))
+	handle("POST /render/{key}", render, openapi.Op{Summary: "Bind challenge images to the rendering widget"})
+	mux.Handle("OPTIONS /render/{key}", render)
+	adminFunc("/admin/usage", service.handleUsage, openapi.Op{Method: http.MethodGet, Summary: "Usage by tenant", Query: []string{"site_key"}})
+	adminFunc("/admin/templates", service.handleTemplates,
+		openapi.Op{Method: http.MethodGet, Summary: "Widget template versions"},
+		openapi.Op{Method: http.MethodPost, Summary: "Switch the active template version", Query: []string{"kind", "version"}})
+	adminFunc("GET /admin/preview", service.handlePreview, openapi.Op{Summary: "Render a challenge preview", Query: []string{"site_key", "action", "complexity", "kind", "locale", "theme", "pieces"}, ContentType: "text/html"})
+	adminFunc("POST /admin/dryrun", service.handleDryRun, openapi.Op{Summary: "Evaluate an answer against a synthetic challenge", JSONBody: true})
+	adminFunc("GET /admin/tenants", service.handleTenants, openapi.Op{Summary: "Pending challenges by tenant"})
+	adminFunc("DELETE /admin/tenants/{site_key}/challenges", service.handleClearTenant, openapi.Op{Summary: "Drop pending challenges of a tenant"})
+	adminFunc("GET /admin/iplists", service.handleIPLists, openapi.Op{Summary: "IP allow/deny list entries"})
+	adminFunc("POST /admin/iplists", service.handleIPLists, openapi.Op{Summary: "Add an IP list entry", JSONBody: true})
+	adminFunc("GET /admin/iplists/audit", service.handleIPListAudit, openapi.Op{Summary: "IP list audit log"})
+	adminFunc("PUT /admin/iplists/{id}", service.handleIPListEntry, openapi.Op{Summary: "Update an IP list entry", JSONBody: true})
+	adminFunc("DELETE /admin/iplists/{id}", service.handleIPListEntry, openapi.Op{Summary: "Delete an IP list entry"})
+	adminFunc("GET /admin/slo", service.handleSLO, openapi.Op{Summary: "SLO objectives, burn rates and firing alerts"})
+	adminFunc("GET /admin/grafana/dashboard", handleGrafanaDashboard,
+		openapi.Op{Summary: "Grafana dashboard for the current metric set", Query: []string{"datasource", "title", "uid", "format"}})
+	adminFunc("GET /admin/flags", service.handleFlags, openapi.Op{Summary: "Effective feature flag rules"})
+	adminFunc("GET /admin/settings", service.handleSettings, openapi.Op{Summary: "Runtime settings"})
+	adminFunc("PUT /admin/settings", service.handleSettings, openapi.Op{Summary: "Replace runtime settings", JSONBody: true})
+	adminFunc("GET /admin/settings/versions", service.handleSettingsVersions, openapi.Op{Summary: "Settings history"})
+	adminFunc("GET /admin/settings/versions/{version}", service.handleSettingsVersion, openapi.Op{Summary: "One settings version"})
+	adminFunc("POST /admin/settings/rollback", service.handleSettingsRollback, openapi.Op{Summary: "Roll settings back to a version", Query: []string{"version"}})
+	if cfg.TenantBundle.enabled() {
+		adminFunc("GET /admin/tenants/export", func(w http.ResponseWriter, r *http.Request) {
+			service.handleTenantExport(w, r, cfg.TenantBundle)
+		}, openapi.Op{Summary: "Signed bundle of all tenant configurations"})
+		adminFunc("POST /admin/tenants/import", func(w http.ResponseWriter, r *http.Request) {
+			service.handleTenantImport(w, r, cfg.TenantBundle)
+		}, openapi.Op{Summary: "Import a signed tenant bundle", Query: []string{"dry_run", "on_conflict"}, JSONBody: true})
+	}
+	adminFunc("/admin/loglevel", handleLogLevel,
+		openapi.Op{Method: http.MethodGet, Summary: "Log levels"},
+		openapi.Op{Method: http.MethodPost, Summary: "Change a log level", Query: []string{"level", "component", "site_key"}})
+	if cfg.AuditLogFile != "" {
+		reportQuery := []string{"site_key", "since"}
+		adminFunc("GET /admin/reports/solve-rate", handleReport(cfg.AuditLogFile, func(path string, q audit.Query, _ int) (any, error) {
+			return audit.SolveRate(path, q)
+		}), openapi.Op{Summary: "Daily challenge solve rate", Query: reportQuery})
+		adminFunc("GET /admin/reports/failing-clients", handleReport(cfg.AuditLogFile, func(path string, q audit.Query, limit int) (any, error) {
+			return audit.FailingClients(path, q, limit)
+		}), openapi.Op{Summary: "Clients with the most failed verifications", Query: append(reportQuery, "limit")})
+		adminFunc("GET /admin/reports/action-scores", handleReport(cfg.AuditLogFile, func(path string, q audit.Query, _ int) (any, error) {
+			return audit.ActionScores(path, q)
+		}), openapi.Op{Summary: "Verification confidence by action", Query: reportQuery})
+	}
+	if cfg.Session.enabled() {
+		handle("/session", cfg.HTTPSecurity.CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			service.handleSession(w, r, cfg.Session)
+		})), openapi.Op{Method: http.MethodPost, Summary: "Exchange a result token for a session cookie", Form: []string{"token", "site_key", "action"}})
+		log.Printf("Session cookie mode enabled (cookie %s, TTL %s)", cfg.Session.Name, cfg.Session.TTL)
+	}
+	if cfg.FormFlow.enabled() {
+		handleFunc("GET /challenge/form", func(w http.ResponseWriter, r *http.Request) {
+			service.handleChallengeForm(w, r, cfg.FormFlow)
+		}, openapi.Op{Summary: "Challenge page for server-rendered forms", ContentType: "text/html", Query: []string{"site_key", "action", "return_to", "locale"}})
+		handleFunc("POST /verify/form", func(w http.ResponseWriter, r *http.Request) {
+			service.handleVerifyForm(w, r, cfg.FormFlow)
+		}, openapi.Op{Summary: "Submit a form challenge answer and redirect to return_to", Form: []string{"challenge_id", "site_key", "action", "return_to", "data"}})
+		log.Printf("Form-post flow enabled at /challenge/form (return origins %v)", cfg.FormFlow.ReturnOrigins)
+	}
+	switch {
+	case cfg.ForwardAuth.Enabled && !cfg.Session.enabled():
+		log.Println("WARNING: FORWARD_AUTH requires SESSION_COOKIE_SECRET, /forward-auth is disabled.")
+	case cfg.ForwardAuth.Enabled:
+		forwardAuth := cfg.ForwardAuth
+		forwardAuth.ClockSkew = cfg.ClockSkew
+		// Метод любой: nginx auth_request повторяет метод исходного запроса
+		handleFunc("/forward-auth", func(w http.ResponseWriter, r *http.Request) {
+			service.handleForwardAuth(w, r, forwardAuth, cfg.Session)
+		}, openapi.Op{Method: http.MethodGet, Summary: "Check a captcha session for a reverse proxy (Traefik ForwardAuth, nginx auth_request)", Query: []string{"site_key", "action"}})
+		log.Printf("Forward auth enabled at /forward-auth (cookie %s or header %s)", cfg.Session.Name, cfg.ForwardAuth.Header)
+	}
+	if cfg.Introspection.enabled() {
+		handleFunc("POST /introspect", func(w http.ResponseWriter, r *http.Request) {
+			service.handleIntrospect(w, r, cfg.Introspection)
+		}, openapi.Op{Summary: "Introspect a result token (RFC 7662)", Form: []string{"token", "token_type_hint"}})
+		log.Printf("Token introspection enabled at /introspect (tokens stay active for %s)", cfg.Introspection.TTL)
+	}
+	handleFunc("POST /siteverify", service.handleSiteverify, openapi.Op{Summary: "Redeem a result token", Form: []string{"token", "site_key", "action"}})
+	if service.resultJWT != nil {
+		handleFunc("GET /.well-known/jwks.json", service.resultJWT.handleJWKS, openapi.Op{Summary: "Keys of result JWTs"})
+		log.Printf("Challenge results are also issued as JWT (TTL %s), keys at /.well-known/jwks.json", service.resultJWT.ttl)
+	}
+	if cfg.DebugChallenges {
+		handleFunc("GET /debug/challenges/{id}", service.handleDebugChallenge, openapi.Op{Summary: "Challenge internals (debug only)"})
+		log.Println("WARNING: DEBUG_CHALLENGES is enabled, /debug/challenges exposes challenge answers. Never enable it in production.")
+	}
+	adminFunc("/admin/drain", func(w http.ResponseWriter, r *http.Request) {
+		service.handleDrain(w, r, cfg.MaxShutdownInterval)
+	}, openapi.Op{Method: http.MethodPost, Summary: "Drain the instance"})
+	mux.Handle("GET /openapi.json", api)
+
+	serveCfg := cfg.HTTPServe
+	serveCfg.Addr = fmt.Sprintf(":%d", port)
+	protocols := "HTTP/1.1, h2c"
+	if serveCfg.TLS() {
+		protocols = "HTTPS, HTTP/2"
+		if serveCfg.HTTP3 {
+			protocols += ", HTTP/3"
+		}
+	}
+	log.Printf("HTTP server (metrics, health, assets, admin) listening at %s (%s)", serveCfg.Addr, protocols)
+	go func() {
+		handler := httperr.RequestID(cfg.HTTPSecurity.Secure(mux))
+		if service.reporter != nil {
+			handler = service.reporter.Handler(handler)
+		}
+		if err := httpserve.ListenAndServe(serveCfg, handler); err != nil {
+			log.Printf("HTTP server stopped: %v", err)
+		}
+	}()
+}
+
+// startACME создает менеджер сертификатов и, если задан ACME_HTTP_ADDR,
+// поднимает слушатель HTTP-01 проверок; возвращает TLS-конфигурацию сервера
+func startACME(cfg config) *tls.Config {
+	cache, err := acme.NewCache(cfg.ACMECache)
+	if err != nil {
+		log.Fatalf("Failed to set up ACME: %v", err)
+	}
+	acmeCfg := cfg.ACME
+	acmeCfg.Cache = cache
+	manager, err := acme.NewManager(acmeCfg)
+	if err != nil {
+		log.Fatalf("Failed to set up ACME: %v", err)
+	}
+	if cfg.ACMEHTTPAddr != "" {
+		go func() {
+			if err := acme.ServeHTTPChallenge(manager, cfg.ACMEHTTPAddr); err != nil {
+				log.Printf("ACME challenge server stopped: %v", err)
+			}
+		}()
+	}
+	log.Printf("ACME certificates enabled for %s", strings.Join(cfg.ACME.Hosts, ", "))
+	return manager.TLSConfig()
+}
+
+// handleDrain — POST /admin/drain: перевод инстанса в drain без остановки процесса
+func (s *captchaService) handleDrain(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.drain(retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"draining":    true,
+		"outstanding": s.outstandingChallenges(),
+	})
+}
+
+// handleTemplates — GET /admin/templates: версии шаблонов виджетов по типам;
+// POST /admin/templates?kind=...&version=...: переключение (откат) версии,
+// пустая version возвращает последнюю
+func (s *captchaService) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := s.generator.Templates()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		kind, version := r.URL.Query().Get("kind"), r.URL.Query().Get("version")
+		if kind == "" {
+			httperr.Write(w, r, http.StatusBadRequest, "kind is required")
+			return
+		}
+		if err := templates.SetActive(kind, version); err != nil {
+			httperr.Write(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Template version for %s set to %q", kind, templates.ActiveVersion(kind))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	type kindInfo struct {
+		Active   string   `json:"active"`
+		Versions []string `json:"versions"`
+	}
+	body := make(map[string]kindInfo)
+	for _, kind := range []string{generator.DefaultTemplateKind, generator.KindSlider, generator.KindRotate, generator.KindMulti} {
+		if versions := templates.Versions(kind); len(versions) > 0 {
+			body[kind] = kindInfo{Active: templates.ActiveVersion(kind), Versions: versions}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleLogLevel — GET /admin/loglevel: текущие уровни логирования;
+// POST /admin/loglevel?level=debug[&component=verification|&site_key=...]: смена
+// уровня глобально, для компонента или сайта; пустой level снимает переопределение
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		q := r.URL.Query()
+		component, siteKey := q.Get("component"), q.Get("site_key")
+		var level *logging.Level
+		if v := q.Get("level"); v != "" {
+			l, err := logging.ParseLevel(v)
+			if err != nil {
+				httperr.Write(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			level = &l
+		}
+		switch {
+		case siteKey != "":
+			logging.SetSite(siteKey, level)
+			log.Printf("Log level for site %s set to %v", siteKey, describeLevel(level))
+		case component != "":
+			if !slices.Contains(logging.Components, logging.Component(component)) {
+				httperr.Write(w, r, http.StatusBadRequest, fmt.Sprintf("unknown component %q", component))
+				return
+			}
+			logging.SetComponent(logging.Component(component), level)
+			log.Printf("Log level for component %s set to %v", component, describeLevel(level))
+		case level != nil:
+			logging.SetGlobal(*level)
+			log.Printf("Global log level set to %s", level)
+		default:
+			httperr.Write(w, r, http.StatusBadRequest, "level is required")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logging.Snapshot())
+}
+
+func describeLevel(l *logging.Level) string {
+	if l == nil {
+		return "default"
+	}
+	return l.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"captcha-service/internal/httperr"
+	"captcha-service/pkg/verify"
+)
+
+// introspectionConfig — интроспекция токенов результата для API-шлюзов;
+// включается INTROSPECTION_SECRET
+type introspectionConfig struct {
+	// Secret — секрет шлюза: Bearer-токен или пароль Basic-авторизации
+	Secret []byte
+	// TTL — сколько токен решенного задания остается активным для интроспекции
+	TTL time.Duration
+}
+
+func (c introspectionConfig) enabled() bool { return len(c.Secret) > 0 }
+
+// authorized проверяет секрет шлюза в Authorization: "Bearer <secret>" или
+// Basic с секретом в качестве пароля (client_secret_basic)
+func (c introspectionConfig) authorized(r *http.Request) bool {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, secret, ok = r.BasicAuth()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(secret), c.Secret) == 1
+}
+
+// introspectionResponse — ответ в формате RFC 7662. Неактивный токен — только
+// {"active": false}: шлюзу не нужно знать, почему.
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	// Subject — ID задания, Audience — site key сайта
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	// Score — уверенность, Threshold — порог политики, с которым она сравнивалась
+	Score     int32  `json:"score,omitempty"`
+	Threshold int32  `json:"threshold,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+	// Region — регион инстанса, проверившего решение
+	Region string `json:"region,omitempty"`
+}
+
+// introspectionTokenType — token_type токенов результата
+const introspectionTokenType = "captcha_result"
+
+// rememberForIntrospection сохраняет токен решенного задания для интроспекции
+func (s *captchaService) rememberForIntrospection(token string, v verdict) {
+	if s.introspection == nil || v.Confidence == 0 {
+		return
+	}
+	s.introspection.set(token, v, 0)
+}
+
+// revokeIntrospection отзывает токены задания (задание признано поделенным)
+func (s *captchaService) revokeIntrospection(challengeID string) {
+	if s.introspection == nil {
+		return
+	}
+	for _, e := range s.introspection.entries() {
+		if tokenChallengeID(e.key) == challengeID {
+			s.introspection.delete(e.key)
+		}
+	}
+}
+
+// handleIntrospect — POST /introspect (token в форме, RFC 7662): активен ли
+// токен результата. В отличие от Assess и /siteverify токен не погашается:
+// шлюз проверяет его на каждом запросе, пока не истечет INTROSPECTION_TOKEN_TTL.
+// Активен токен задания, решенного с уверенностью не ниже действующего порога.
+func (s *captchaService) handleIntrospect(w http.ResponseWriter, r *http.Request, cfg introspectionConfig) {
+	if !cfg.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="captcha-introspection"`)
+		httperr.Write(w, r, http.StatusUnauthorized, "introspection requires the gateway secret")
+		return
+	}
+	res := introspectionResponse{}
+	if v, expires, err := s.introspection.getWithExpiration(r.FormValue("token")); err == nil {
+		threshold := s.scoreThreshold(v.SiteKey, v.Action)
+		if passed(v.Confidence, threshold) && !s.sharedChallenge(v.ChallengeID) {
+			res = introspectionResponse{
+				Active:    true,
+				TokenType: introspectionTokenType,
+				Issuer:    verify.Issuer,
+				Subject:   v.ChallengeID,
+				Audience:  v.SiteKey,
+				IssuedAt:  v.IssuedAt.Unix(),
+				ExpiresAt: expires.Unix(),
+				Score:     v.Confidence,
+				Threshold: threshold,
+				Action:    v.Action,
+				Hostname:  v.Hostname,
+				Region:    v.Region,
+			}
+		}
+	}
+	introspections.Inc(strconv.FormatBool(res.Active))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/iplist"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ipListsConfig — allowlist/denylist IP и CIDR
+type ipListsConfig struct {
+	// File — JSON со списками; пустой — списки живут только в памяти инстанса.
+	// AuditFile — журнал изменений (по умолчанию рядом с File).
+	File      string
+	AuditFile string
+	// ReloadInterval — как часто проверять изменения файла и истекшие записи
+	ReloadInterval time.Duration
+}
+
+// matchIPList ищет клиента в списках
+func (s *captchaService) matchIPList(ip string) (iplist.Entry, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return iplist.Entry{}, false
+	}
+	e, ok := s.ipLists.Match(addr)
+	if ok {
+		ipListMatches.Inc(string(e.List), string(e.Action))
+	}
+	return e, ok
+}
+
+// clientBlocked строит ошибку PermissionDenied с причиной CLIENT_BLOCKED
+func clientBlocked(siteKey string) error {
+	st := status.New(codes.PermissionDenied, "client is blocked")
+	detailed, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   "CLIENT_BLOCKED",
+		Domain:   "captcha.v1",
+		Metadata: map[string]string{"site_key": siteLabel(siteKey)},
+	})
+	if derr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// allowlistPass выдает токен без задания клиенту из allowlist
+func (s *captchaService) allowlistPass(spec challengeSpec) (*captchapb.ChallengeResponse, bool) {
+	if spec.listed != iplist.Allow {
+		return nil, false
+	}
+	logging.Infof(logging.Verification, spec.siteKey, "Challenge %s skipped: client %s is allowlisted", spec.id, logging.IP(spec.clientIP))
+	token, jwt := s.passWithoutChallenge(spec, 100)
+	return &captchapb.ChallengeResponse{ChallengeId: spec.id, Allowlisted: true, Token: token, Jwt: jwt}, true
+}
+
+// watchIPLists перечитывает файл списков при изменении и удаляет истекшие записи
+func (s *captchaService) watchIPLists(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if reloaded, err := s.ipLists.Reload(); err != nil {
+			logging.Errorf(logging.Generator, "", "Failed to reload ip lists, keeping previous entries: %v", err)
+		} else if reloaded {
+			logging.Infof(logging.Generator, "", "IP lists reloaded: %d entries", len(s.ipLists.Entries()))
+		}
+		expired, err := s.ipLists.PurgeExpired()
+		if err != nil {
+			logging.Errorf(logging.Generator, "", "Failed to save ip lists after expiry: %v", err)
+		}
+		for _, e := range expired {
+			logging.Infof(logging.Generator, "", "IP list entry %s (%s %s) expired", e.ID, e.List, e.Prefix)
+		}
+	}
+}
+
+// ipListRequest — тело POST/PUT /admin/iplists. Срок задается либо моментом
+// expires_at, либо длительностью ttl ("24h").
+type ipListRequest struct {
+	CIDR      string        `json:"cidr"`
+	List      iplist.List   `json:"list"`
+	Action    iplist.Action `json:"action"`
+	Comment   string        `json:"comment"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	TTL       string        `json:"ttl"`
+}
+
+func (req ipListRequest) entry() (iplist.Entry, error) {
+	prefix, err := iplist.ParsePrefix(req.CIDR)
+	if err != nil {
+		return iplist.Entry{}, err
+	}
+	e := iplist.Entry{Prefix: prefix, List: req.List, Action: req.Action, Comment: req.Comment, ExpiresAt: req.ExpiresAt}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return iplist.Entry{}, fmt.Errorf("%w: invalid ttl %q", iplist.ErrInvalid, req.TTL)
+		}
+		e.ExpiresAt = time.Now().Add(ttl)
+	}
+	return e, nil
+}
+
+// handleIPLists — GET /admin/iplists: все записи; POST добавляет запись
+func (s *captchaService) handleIPLists(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.ipLists.Entries())
+		return
+	}
+	var req ipListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	e, err := req.entry()
+	if err == nil {
+		e, err = s.ipLists.Create(e, adminAuthor(r))
+	}
+	if !ipListResult(w, r, err) {
+		return
+	}
+	logging.Warnf(logging.Generator, "", "IP list entry %s added by %s: %s %s %s", e.ID, e.Author, e.List, e.Action, e.Prefix)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+// handleIPListEntry — PUT /admin/iplists/{id} заменяет запись, DELETE удаляет
+func (s *captchaService) handleIPListEntry(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if r.Method == http.MethodDelete {
+		if ipListResult(w, r, s.ipLists.Delete(id, adminAuthor(r))) {
+			logging.Warnf(logging.Generator, "", "IP list entry %s deleted by %s", id, adminAuthor(r))
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+	var req ipListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	e, err := req.entry()
+	if err == nil {
+		e, err = s.ipLists.Update(id, e, adminAuthor(r))
+	}
+	if !ipListResult(w, r, err) {
+		return
+	}
+	logging.Warnf(logging.Generator, "", "IP list entry %s updated by %s: %s %s %s", e.ID, e.Author, e.List, e.Action, e.Prefix)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// handleIPListAudit — GET /admin/iplists/audit: последние изменения списков
+func (s *captchaService) handleIPListAudit(w http.ResponseWriter, r *http.Request) {
+	records, err := s.ipLists.Audit()
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// ipListResult отвечает ошибкой изменения списков и сообщает, было ли оно успешным
+func ipListResult(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, iplist.ErrNotFound):
+		httperr.Write(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, iplist.ErrInvalid):
+		httperr.Write(w, r, http.StatusBadRequest, err.Error())
+	default:
+		httperr.Write(w, r, http.StatusInternalServerError, err.Error())
+	}
+	return false
+}
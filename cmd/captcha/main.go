@@ -1,22 +1,53 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	balancerpb "captcha-service/api/balancer/v1"
 	captchapb "captcha-service/api/captcha/v1"
+	extauthzpb "captcha-service/api/extauthz/v3"
+	"captcha-service/internal/accesslog"
+	"captcha-service/internal/answer"
+	"captcha-service/internal/archive"
+	"captcha-service/internal/assetsig"
+	"captcha-service/internal/audit"
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/errreport"
+	"captcha-service/internal/flags"
 	"captcha-service/internal/generator" // <-- Убедитесь, что этот импорт есть
+	"captcha-service/internal/handoff"
+	"captcha-service/internal/iplist"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/notify"
+	"captcha-service/internal/policy"
+	"captcha-service/internal/quota"
+	"captcha-service/internal/renderer"
+	"captcha-service/internal/risk"
+	"captcha-service/internal/settings"
+	"captcha-service/internal/slo"
+	"captcha-service/internal/static"
 
 	"github.com/google/uuid"
-	"github.com/patrickmn/go-cache"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -28,115 +59,838 @@ const (
 	challengeType     = "slider-puzzle" // <-- Тип нашей новой капчи
 	instanceHost      = "localhost"
 	heartbeatInterval = 15 * time.Second
-)
 
-// Структура для хранения ответа
-type solution struct {
-	X          int
-	Complexity int
-}
+	defaultShutdownInterval = 600 * time.Second
+
+	// Бюджет HTML с запасом под лимит gRPC-сообщения в 4 МБ
+	defaultMaxHTMLSize = 3<<20 + 512<<10
+	// Мягкий бюджет, после которого генератор понижает качество картинок
+	defaultHTMLSizeBudget = 2 << 20
+
+	minHTTPPort = 48000
+	maxHTTPPort = 50000
+)
 
 // captchaService теперь хранит генератор
 type captchaService struct {
 	captchapb.UnimplementedCaptchaServiceServer
-	challenges *cache.Cache
-	generator  *generator.Generator // <-- Поле для генератора
+	challenges *challengeStore
+	results    *typedStore[verdict]
+	outcomes   *typedStore[outcome]
+	// claims — к какому стриму привязано задание первым ответом (см. claimChallenge)
+	claims *typedStore[streamClaim]
+	// notifier — провайдеры уведомлений тенантов; nil — уведомления выключены.
+	// notified — какие уведомления уже отправлены (без повторов на каждый запрос)
+	notifier      *notify.Router
+	notifyTimeout time.Duration
+	notified      *typedStore[bool]
+	// slo — цели уровня обслуживания методов; nil — не заданы
+	slo *slo.Tracker
+	// auditLog — журнал заданий и проверок; nil — выключен
+	auditLog *audit.Log
+	// region — регион инстанса в результатах проверок; пусто — не задан
+	region string
+	// introspection — токены решенных заданий для /introspect; nil — выключена
+	introspection *typedStore[verdict]
+	// genStats — статистика генерации по сложности для heartbeat
+	genStats *generationStats
+	// warm — задания, сгенерированные при прогреве
+	warm *warmPool
+	// bindRender — картинки заданий отдаются только после обмена render-токена
+	bindRender bool
+	// attest — проверка аттестации платформы клиента; nil — аттестации не принимаются
+	attest *attestGate
+	// risk и history — оценка клиента в невидимом режиме и исходы его проверок;
+	// invisiblePassScore — порог пропуска, если политика его не задала
+	risk               *risk.Engine
+	history            *clientHistory
+	invisiblePassScore int
+	// fingerprints — лимит частоты проверок по отпечатку устройства; nil — выключен
+	fingerprints *fingerprintLimiter
+	// velocity — лимиты выдачи заданий по IP и отпечатку; nil — выключены
+	velocity *velocityLimiter
+	// ipLists — allowlist и denylist IP/CIDR
+	ipLists *iplist.Store
+	// trustedProxies — вызывающие, чьим адресу и стране клиента можно верить
+	trustedProxies iplist.Proxies
+	// assetSigner проверяет подписи адресов картинок; nil — адреса не подписываются
+	assetSigner *assetsig.Signer
+	// staticFiles — CSS и JS оболочек виджета; nil — встраиваются в HTML
+	staticFiles *static.Store
+	// strictBinding и ipBinding — привязка заданий к сайту и сети клиента
+	strictBinding bool
+	ipBinding     ipBinding
+	// reporter — отчеты об ошибках; nil, если SENTRY_DSN не задан
+	reporter  *errreport.Reporter
+	prewarm   *prewarmPool
+	assets    *assetStore
+	generator *generator.Generator // <-- Поле для генератора
+	// renderer рисует задания: в процессе или в отдельном пуле (RENDERER_ADDR)
+	renderer renderer.Renderer
+	streams  *streamHub
+	verifier *verifyPool
+	health   *instanceHealth
+	quotas   *quota.Tracker
+	policies *policy.Dynamic
+	// flags — флаги рискованных функций по сайтам и доле трафика
+	flags *flags.Store
+	// settings — история версий политик и квот для отката; appliedSettings —
+	// последняя версия из нее, которую инстанс применил или отверг
+	settings        *settings.History
+	settingsMu      sync.Mutex
+	appliedSettings atomic.Int64
+	link            *balancerLink
+	// forwarder проверяет решения чужих заданий на выдавшем их инстансе;
+	// peerAuth — токен регистрации, которым соседи подтверждают пересылку
+	forwarder *solutionForwarder
+	peerAuth  *balancer.RegistrationAuth
+	// handoff шифрует задания, передаваемые при остановке (nil — передача выключена)
+	handoff   *handoff.Sealer
+	drainOnce sync.Once
+	// verifyFlight схлопывает одновременные проверки одного задания
+	verifyFlight singleflight.Group
+	// capacityPercent — мощность для балансера при самоограничении; 0 — полная
+	capacityPercent atomic.Int32
+	// remote — последняя конфигурация, присланная балансером (nil, пока ее не было)
+	remote atomic.Pointer[remoteConfig]
+
+	// responseCompressor — компрессор для ChallengeResponse (gzip, zstd или пусто)
+	responseCompressor string
+
+	// rotateMinComplexity — начиная с этой сложности выдается пазл с вращением
+	rotateMinComplexity int
+	// multiPieceMinComplexity — начиная с этой сложности выдается задание из нескольких фрагментов
+	multiPieceMinComplexity int
+	// riskLevels — сложность для уровня риска из запроса по типу задания
+	riskLevels riskLevelTable
+	// debugAnswers — дописывать правильный ответ в HTML (DEBUG_ANSWERS)
+	debugAnswers bool
+	// resultJWT подписывает результаты JWT; nil — без RESULT_JWT_KEY
+	resultJWT *resultSigner
 }
 
 // NewChallenge использует генератор
 func (s *captchaService) NewChallenge(ctx context.Context, req *captchapb.ChallengeRequest) (*captchapb.ChallengeResponse, error) {
-	challengeID := uuid.New().String()
-	log.Printf("Generating new slider-puzzle challenge (complexity %d) with ID: %s", req.Complexity, challengeID)
+	spec, err := s.prepareChallenge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res, ok := s.allowlistPass(spec); ok {
+		return res, nil
+	}
+	if res, ok := s.acceptAttestation(ctx, req, spec); ok {
+		return res, nil
+	}
+	if res, ok := s.invisiblePass(req, spec); ok {
+		return res, nil
+	}
+	s.compressResponse(ctx)
+	done := s.genStats.enqueue(spec.complexity)
+	start := time.Now()
+	res, err := s.renderChallenge(ctx, spec)
+	done(start, err == nil)
+	return res, err
+}
+
+// challengeSpec — все, что решено о задании до тяжелой отрисовки картинок
+type challengeSpec struct {
+	id         string
+	kind       string
+	complexity int
+	pieces     int
+	siteKey    string
+	action     string
+	threshold  int
+	clientIP   string
+	// invisible и passScore — невидимый режим политики действия
+	invisible bool
+	passScore int
+	// listed — в каком списке IP клиента (пусто — ни в каком)
+	listed iplist.List
+	// locale — локаль фона и шаблона: из политики сайта, иначе из запроса
+	locale string
+	// widget — схема ответа и обфускация по возможностям виджета
+	widget generator.Widget
+	// hostname — хост страницы виджета для результата проверки
+	hostname string
+	// inline — картинки встраиваются в HTML, хотя инстанс отдает их по ссылкам
+	inline bool
+	// session и ipPrefix — привязка задания к сессии сайта (хэш) и сети клиента
+	session  string
+	ipPrefix string
+	// region и country — привязка проверки к месту выдачи (geo_pin политики)
+	region, country string
+}
+
+// prepareChallenge проверяет квоту и выбирает тип и сложность задания
+func (s *captchaService) prepareChallenge(ctx context.Context, req *captchapb.ChallengeRequest) (challengeSpec, error) {
+	if s.health.draining.Load() {
+		// Выданные задания еще проверяются, но новые должен выдать другой инстанс
+		return challengeSpec{}, status.Error(codes.Unavailable, "captcha instance is draining")
+	}
+	ip := s.clientIP(ctx, req.GetClient().GetIp())
+	listed, _ := s.matchIPList(ip)
+	if listed.List == iplist.Deny && listed.Action == iplist.Block {
+		logging.Infof(logging.Generator, req.GetSiteKey(), "Challenge rejected: client %s is blocked by ip list entry %s", logging.IP(ip), listed.ID)
+		return challengeSpec{}, clientBlocked(req.GetSiteKey())
+	}
+	// Клиенты из allowlist (мониторинг, офисы) не ограничиваются по частоте
+	if listed.List != iplist.Allow {
+		if e := s.velocity.take(map[string]string{
+			velocitySourceIP:          ip,
+			velocitySourceFingerprint: req.GetClient().GetFingerprint(),
+		}, time.Now()); e != nil {
+			logging.Infof(logging.Generator, req.GetSiteKey(), "Challenge rejected: more than %d challenges per %s from one %s",
+				e.rule.Max, e.rule.Window, e.rule.Source)
+			return challengeSpec{}, tooManyChallenges(req.GetSiteKey(), e)
+		}
+	}
+	if err := s.quotas.Consume(req.GetSiteKey(), quota.Challenges); err != nil {
+		s.notifyQuota(req.GetSiteKey(), quota.Challenges)
+		return challengeSpec{}, quotaExceeded(req.GetSiteKey(), quota.Challenges, err)
+	}
+	siteUsage.Inc(siteLabel(req.GetSiteKey()), string(quota.Challenges))
+	if s.challenges.full(req.GetSiteKey()) {
+		return challengeSpec{}, tenantFull(req.GetSiteKey())
+	}
+	return s.specFor(req, ip, s.clientCountry(ctx, req.GetClient().GetCountry()), listed)
+}
+
+// specFor выбирает сложность, тип и параметры задания по политике сайта,
+// конфигурации балансера и спискам IP; ничего не расходует и не учитывает
+func (s *captchaService) specFor(req *captchapb.ChallengeRequest, ip, country string, listed iplist.Entry) (challengeSpec, error) {
+	// Сложность: из запроса (числом или уровнем риска), иначе из политики действия;
+	// балансер может переопределить обе
+	act := s.policies.Resolve(req.GetSiteKey(), req.GetAction())
+	complexity := int(req.GetComplexity())
+	if complexity == 0 {
+		complexity = s.riskLevels.complexity(act.ChallengeType, req.GetRiskLevel())
+	}
+	if complexity == 0 {
+		complexity = act.Complexity
+	}
+	if rc := s.remote.Load(); rc != nil && rc.targetComplexity > 0 {
+		complexity = rc.targetComplexity
+	}
+	// Клиент из denylist всегда получает самое сложное задание
+	hard := listed.List == iplist.Deny
+	if hard {
+		complexity = 100
+	}
+	kind := s.kindForComplexity(complexity)
+	if act.ChallengeType != "" && !hard {
+		kind = act.ChallengeType
+	}
+	kind, ok := s.enabledKind(kind)
+	if !ok {
+		return challengeSpec{}, status.Error(codes.FailedPrecondition, "all challenge types are disabled by balancer config")
+	}
+	unit := flagUnit(req, ip)
+	kind = s.flaggedKind(kind, req.GetSiteKey(), unit)
+	spec := challengeSpec{
+		id:         uuid.New().String(),
+		kind:       kind,
+		complexity: complexity,
+		siteKey:    req.GetSiteKey(),
+		action:     req.GetAction(),
+		threshold:  act.ScoreThreshold,
+		clientIP:   ip,
+		invisible:  act.Invisible && !hard && s.flag(flagInvisible, req.GetSiteKey(), unit),
+		listed:     listed.List,
+		locale:     cmp.Or(act.Locale, req.GetLocale()),
+		widget:     widgetFor(req.GetClient().GetCapabilities()),
+		passScore:  act.PassScore,
+		hostname:   clientHostname(req),
+		inline:     s.inlineDelivery(req.GetDelivery(), act.Delivery),
+		session:    sessionHash(req.GetClient().GetSessionId()),
+		ipPrefix:   s.ipBinding.prefix(ip),
+	}
+	spec.region, spec.country = s.geoPin(act.GeoPin, country)
+	if spec.passScore == 0 {
+		spec.passScore = s.invisiblePassScore
+	}
+	if spec.widget.Obfuscation != generator.ObfuscationTouch && !s.flag(flagHiddenControls, spec.siteKey, unit) {
+		spec.widget.Obfuscation = generator.ObfuscationTouch
+	}
+	if kind == generator.KindMulti {
+		spec.pieces = s.multiPieces(complexity)
+	}
+	return spec, nil
+}
+
+// inlineDelivery решает, встраивать ли картинки в HTML: запрос перекрывает
+// политику сайта, та — настройку инстанса. Без HTTP-сервера картинок они
+// встраиваются всегда, и отдельное решение не нужно.
+func (s *captchaService) inlineDelivery(requested captchapb.ChallengeRequest_Delivery, policyDelivery string) bool {
+	if s.generator.Assets().BaseURL == "" {
+		return false
+	}
+	switch requested {
+	case captchapb.ChallengeRequest_DELIVERY_INLINE:
+		return true
+	case captchapb.ChallengeRequest_DELIVERY_URL:
+		return false
+	}
+	return policyDelivery == policy.DeliveryInline
+}
+
+// kindForComplexity — тип задания по сложности: на высокой сложности пазл
+// с вращением, на очень высокой — несколько фрагментов
+func (s *captchaService) kindForComplexity(complexity int) string {
+	switch {
+	case complexity >= s.multiPieceMinComplexity:
+		return generator.KindMulti
+	case complexity >= s.rotateMinComplexity:
+		return generator.KindRotate
+	}
+	return generator.KindSlider
+}
+
+// multiPieces — число фрагментов многопазлового задания: третий фрагмент
+// добавляется в верхней половине диапазона сложности
+func (s *captchaService) multiPieces(complexity int) int {
+	if complexity >= (s.multiPieceMinComplexity+100)/2 {
+		return 3
+	}
+	return 2
+}
 
-	// Вызываем наш генератор
-	html, correctX, err := s.generator.Generate()
+// renderChallenge генерирует картинки и HTML задания и сохраняет правильный ответ
+func (s *captchaService) renderChallenge(ctx context.Context, spec challengeSpec) (*captchapb.ChallengeResponse, error) {
+	// Сначала берем задание, сгенерированное при прогреве, иначе рисуем новое.
+	// Прогрев рисует с локалью по умолчанию для старых виджетов, поэтому для
+	// локали рынка, виджетов с заявленными возможностями и встроенных картинок
+	// пул не годится.
+	var (
+		challenge *generator.Challenge
+		warm      bool
+		err       error
+	)
+	if spec.locale == "" && spec.widget.Legacy() && !spec.inline {
+		challenge, warm = s.warm.take(spec.kind, spec.pieces)
+	}
+	if warm {
+		logging.Infof(logging.Generator, spec.siteKey, "Issuing pre-generated %s challenge (complexity %d, action %q) with ID: %s", spec.kind, spec.complexity, spec.action, spec.id)
+	} else {
+		logging.Infof(logging.Generator, spec.siteKey, "Generating new %s challenge (complexity %d, action %q) with ID: %s", spec.kind, spec.complexity, spec.action, spec.id)
+		start := time.Now()
+		challenge, err = s.generateKind(ctx, spec)
+		if err != nil {
+			recordStage(ctx, "render (failed)", time.Since(start))
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		// Дедлайн вызова истек или клиент ушел: это не сбой генератора
+		logging.Warnf(logging.Generator, spec.siteKey, "Challenge %s rendering aborted: %v", spec.id, ctx.Err())
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		s.reporter.Capture(err, map[string]string{
+			"challenge_id": spec.id,
+			"site_key":     siteLabel(spec.siteKey),
+			"kind":         spec.kind,
+			"complexity":   strconv.Itoa(spec.complexity),
+		})
+	}
+	if errors.Is(err, generator.ErrHTMLTooLarge) {
+		logging.Errorf(logging.Generator, spec.siteKey, "Failed to generate challenge: %v", err)
+		return nil, status.Error(codes.Internal, "challenge exceeds configured html size budget")
+	}
 	if err != nil {
-		log.Printf("Failed to generate challenge: %v", err)
+		logging.Errorf(logging.Generator, spec.siteKey, "Failed to generate challenge: %v", err)
 		return nil, fmt.Errorf("internal server error")
 	}
+	logging.Infof(logging.Generator, spec.siteKey, "Challenge %s rendered with template %s", spec.id, challenge.Template)
+	if !warm {
+		recordStage(ctx, "render", challenge.Stages.Render)
+		recordStage(ctx, "encode", challenge.Stages.Encode)
+		recordStage(ctx, "template", challenge.Stages.Template)
+	}
+	challengeHTMLBytes.Observe(float64(len(challenge.HTML)))
+	imageQualityLevel.Set(int64(s.generator.QualityLevel()))
 
 	// Сохраняем правильный ответ в кэш
 	sol := solution{
-		X:          correctX,
-		Complexity: int(req.GetComplexity()),
+		Kind:       challenge.Kind,
+		X:          challenge.X,
+		Step:       challenge.Step,
+		Angle:      challenge.Angle,
+		Pieces:     challenge.Pieces,
+		Complexity: spec.complexity,
+		SiteKey:    spec.siteKey,
+		Action:     spec.action,
+		Threshold:  spec.threshold,
+		AssetKey:   challenge.AssetKey,
+		ClientIP:   spec.clientIP,
+
+		AnswerSchema: spec.widget.AnswerSchema,
+		Hostname:     spec.hostname,
+		Session:      spec.session,
+		IPPrefix:     spec.ipPrefix,
+		Region:       spec.region,
+		Country:      spec.country,
+		Width:        challenge.Width,
+		Height:       challenge.Height,
 	}
-	s.challenges.Set(challengeID, sol, cache.DefaultExpiration)
+	html := challenge.HTML
+	var answerKey []byte
+	if sol.AnswerSchema == answer.SchemaSealed {
+		var ok bool
+		if sol.AnswerKey, answerKey, err = answer.NewKey(); err != nil {
+			logging.Errorf(logging.Generator, spec.siteKey, "Failed to create answer key: %v", err)
+			return nil, fmt.Errorf("internal server error")
+		}
+		if html, ok = generator.WithAnswerKey(html, answerKey); !ok {
+			// Шаблон без места под ключ: его виджет шлет незашифрованный конверт
+			sol.AnswerSchema, sol.AnswerKey, answerKey = answer.SchemaEnvelope, nil, nil
+		}
+	}
+	if err := s.challenges.put(spec.id, sol); err != nil {
+		return nil, tenantFull(spec.siteKey)
+	}
+	s.assets.put(challenge.AssetKey, challenge.Assets, s.bindRender)
+
+	challengesIssued.Inc(siteLabel(spec.siteKey), actionLabel(spec.action))
+	s.audit(audit.Record{
+		Event:       audit.EventIssued,
+		ChallengeID: spec.id,
+		SiteKey:     spec.siteKey,
+		Action:      spec.action,
+		Kind:        challenge.Kind,
+		Complexity:  spec.complexity,
+		Client:      auditClient(spec.clientIP),
+	})
 
+	issued := time.Now()
+	html = generator.WithExpiry(html, issued, issued.Add(defaultExpiration))
+	if s.debugAnswers {
+		html = answer.WithDebug(html, sol.payload())
+	}
 	return &captchapb.ChallengeResponse{
-		ChallengeId: challengeID,
+		ChallengeId: spec.id,
 		Html:        html,
+		ExpiresAt:   issued.Add(defaultExpiration).Unix(),
+		AnswerKey:   answerKey,
 	}, nil
 }
 
-// MakeEventStream проверяет решение для пазла
+// generateKind отрисовывает задание: локальным генератором или пулом рендереров
+func (s *captchaService) generateKind(ctx context.Context, spec challengeSpec) (*generator.Challenge, error) {
+	return s.renderer.Render(ctx, renderer.Request{
+		Kind:   spec.kind,
+		Pieces: spec.pieces,
+		Locale: spec.locale,
+		Widget: spec.widget,
+		Inline: spec.inline,
+	})
+}
+
+// MakeEventStream принимает события клиента и проверяет решения пазла.
+// Поток событий ограничивается по частоте; стрим, который упорно превышает
+// лимиты, разрывается с кодом ResourceExhausted.
 func (s *captchaService) MakeEventStream(stream captchapb.CaptchaService_MakeEventStreamServer) error {
 	log.Println("Client connected to event stream.")
-	for {
-		event, err := stream.Recv()
-		if err == io.EOF {
-			log.Println("Client stream closed.")
-			return nil
+	// ctx отменяет и janitor, если клиент пропал без EOF
+	ctx, cancel := context.WithCancelCause(stream.Context())
+	defer cancel(nil)
+	es := s.streams.add(stream, cancel)
+	defer s.streams.remove(es)
+
+	events := make(chan *captchapb.ClientEvent)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
 		}
-		if err != nil {
+	}()
+
+	for {
+		var event *captchapb.ClientEvent
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), errOrphanedStream) {
+				return status.Error(codes.Unavailable, errOrphanedStream.Error())
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		case err := <-recvErr:
+			if err == io.EOF {
+				log.Println("Client stream closed.")
+				return nil
+			}
 			log.Printf("Error receiving event: %v", err)
 			return err
+		case event = <-events:
+		}
+		es.touch()
+		streamEventsReceived.Inc()
+
+		if !es.admit() {
+			if es.recordDrop(dropReasonRateLimit) {
+				return s.disconnectAbusive(es, dropReasonRateLimit)
+			}
+			continue
 		}
 
+		if event.EventType == captchapb.ClientEvent_HELLO {
+			s.hello(es, event)
+			continue
+		}
+		if event.EventType == captchapb.ClientEvent_CALIBRATION {
+			s.calibrate(event)
+			continue
+		}
 		if event.EventType == captchapb.ClientEvent_FRONTEND_EVENT {
-			challengeID := event.GetChallengeId()
-			clientXStr := string(event.GetData())
-			clientX, err := strconv.Atoi(clientXStr)
-			if err != nil {
-				log.Printf("Failed to parse client solution for %s: %v", challengeID, err)
+			if !es.acquire(event.GetChallengeId()) {
+				if es.recordDrop(dropReasonInFlight) {
+					return s.disconnectAbusive(es, dropReasonInFlight)
+				}
 				continue
 			}
+			// Слот освобождает воркер пула после отправки результата
+			if !s.verifier.submit(ctx, es, event) {
+				es.release(event.GetChallengeId())
+				if err := ctx.Err(); err != nil {
+					return status.FromContextError(err).Err()
+				}
+				return status.Error(codes.Unavailable, "captcha instance is shutting down")
+			}
+		}
+	}
+}
 
-			log.Printf("Received solution for challenge %s: X=%d", challengeID, clientX)
+// disconnectAbusive завершает стрим, превысивший порог отброшенных событий
+func (s *captchaService) disconnectAbusive(es *eventStream, reason string) error {
+	log.Printf("Event stream %d exceeded abuse threshold (%s), disconnecting.", es.id, reason)
+	streamDisconnects.Inc("abuse")
+	return status.Errorf(codes.ResourceExhausted, "event stream flooded: too many events dropped (%s)", reason)
+}
 
-			expected, found := s.challenges.Get(challengeID)
-			if !found {
-				log.Printf("Challenge ID %s not found (expired or already solved).", challengeID)
-				continue
-			}
-			sol := expected.(solution)
+// verifyReply — итог проверки ответа: событие для виджета (nil — ничего не отправлять)
+type verifyReply struct {
+	event   *captchapb.ServerEvent
+	siteKey string
+	what    string
+}
 
-			tolerance := 5 - (sol.Complexity / 25)
-			if tolerance < 1 {
-				tolerance = 1
-			}
+// verifySolution сверяет присланный ответ с сохраненным и отправляет результат.
+// Привязка задания к сайту, сессии и сети проверяется до привязки к стриму:
+// чужой ответ с украденным ID не должен сжигать задание владельца.
+// Одновременные проверки одного и того же ответа из одного стрима (повторная
+// отправка) схлопываются в одну: задание проверяется и списывает квоту
+// один раз, а все ожидающие получают тот же ответ (см. submission.flightKey).
+func (s *captchaService) verifySolution(es *eventStream, event *captchapb.ClientEvent) {
+	challengeID := event.GetChallengeId()
+	// Паника проверки одного ответа не должна ронять воркер пула
+	defer s.reporter.Recover(map[string]string{"challenge_id": challengeID})
+	sub := submission{
+		data:        event.GetData(),
+		fingerprint: event.GetFingerprint(),
+		siteKey:     event.GetSiteKey(),
+		session:     event.GetSessionId(),
+		clientIP:    s.clientIP(es.stream.Context(), event.GetClientIp()),
+		region:      s.region,
+		country:     s.clientCountry(es.stream.Context(), event.GetCountry()),
+		stream:      es.id,
+	}
+	reply, done := s.claimSolution(challengeID, sub, streamClaim{Stream: es.id, IP: sub.clientIP})
+	if !done {
+		v, _, shared := s.verifyFlight.Do(sub.flightKey(challengeID), func() (any, error) {
+			return s.evaluateSolution(challengeID, sub, false), nil
+		})
+		if shared {
+			verificationsDeduplicated.Inc()
+		}
+		reply = v.(verifyReply)
+	}
+	if reply.event == nil {
+		return
+	}
+	if err := es.send(reply.event); err != nil {
+		logging.Warnf(logging.Verification, reply.siteKey, "Failed to send %s for challenge %s: %v", reply.what, challengeID, err)
+	}
+}
 
-			var confidence int32 = 0
-			delta := clientX - sol.X
-			if delta < 0 {
-				delta = -delta
-			}
+// claimSolution проверяет привязку задания этого инстанса и привязывает ответ
+// к стриму claim. true — ответ уже отклонен и reply — итог для виджета.
+// Задание другого инстанса привязывается к стриму там, куда пересылается.
+func (s *captchaService) claimSolution(challengeID string, sub submission, claim streamClaim) (verifyReply, bool) {
+	sol, found := s.challenges.get(challengeID)
+	if found {
+		if reply, rejected := s.rejectBinding(challengeID, sol, sub); rejected {
+			return reply, true
+		}
+	} else if !s.claimedChallenge(challengeID) {
+		return verifyReply{}, false
+	}
+	if prior, result := s.claimChallenge(challengeID, claim); result != claimOwned {
+		return s.rejectSharedChallenge(challengeID, prior, claim, result == claimShared), true
+	}
+	return verifyReply{}, false
+}
 
-			if delta <= tolerance {
-				confidence = 100
-				log.Printf("Challenge %s solved SUCCESSFULLY (delta: %d, tolerance: %d).", challengeID, delta, tolerance)
-			} else {
-				log.Printf("Challenge %s FAILED. Expected ~%d, got %d (delta: %d, tolerance: %d).", challengeID, sol.X, clientX, delta, tolerance)
-			}
+// rejectBinding отклоняет ответ, не прошедший привязку задания (checkBinding).
+// Задание не расходуется: иначе чужой ответ с украденным ID сжигал бы задание владельца.
+func (s *captchaService) rejectBinding(challengeID string, sol solution, sub submission) (verifyReply, bool) {
+	failure := s.checkBinding(sol, sub)
+	if failure == captchapb.ServerEvent_ChallengeResult_NONE {
+		return verifyReply{}, false
+	}
+	bindingRejections.Inc(strings.ToLower(failure.String()))
+	logging.Warnf(logging.Verification, sol.SiteKey, "Solution for challenge %s rejected: %s (client %s)",
+		challengeID, failure, logging.IP(sub.clientIP))
+	s.audit(audit.Record{
+		Event:       audit.EventRejected,
+		ChallengeID: challengeID,
+		SiteKey:     sol.SiteKey,
+		Action:      sol.Action,
+		Kind:        sol.Kind,
+		Client:      auditClient(sub.clientIP),
+		Result:      strings.ToLower(failure.String()),
+	})
+	return verifyReply{siteKey: sol.SiteKey, what: "binding rejection", event: &captchapb.ServerEvent{
+		Event: &captchapb.ServerEvent_Result{Result: &captchapb.ServerEvent_ChallengeResult{
+			ChallengeId:    challengeID,
+			Action:         sol.Action,
+			BindingFailure: failure,
+		}},
+	}}, true
+}
 
-			resultEvent := &captchapb.ServerEvent{
-				Event: &captchapb.ServerEvent_Result{
-					Result: &captchapb.ServerEvent_ChallengeResult{
-						ChallengeId:       challengeID,
-						ConfidencePercent: confidence,
-					},
-				},
-			}
-			if err := stream.Send(resultEvent); err != nil {
-				log.Printf("Failed to send result for challenge %s: %v", challengeID, err)
-			}
-			s.challenges.Delete(challengeID)
+// evaluateSolution проверяет ответ и удаляет решенное задание из хранилища.
+// Решение неизвестного задания пересылается на выдавший его инстанс, если
+// оно само не пришло пересланным (forwarded).
+func (s *captchaService) evaluateSolution(challengeID string, sub submission, forwarded bool) verifyReply {
+	sol, found := s.challenges.get(challengeID)
+	logging.Infof(logging.Verification, sol.SiteKey, "Received solution for challenge %s: %s", challengeID, logging.Solution(payloadForLog(sub.data)))
+	if !found && !forwarded {
+		if reply, ok := s.forwardSolution(challengeID, sub); ok {
+			return reply
+		}
+	}
+	if !found {
+		logging.Infof(logging.Verification, "", "Challenge ID %s not found (expired or already solved).", challengeID)
+		// Просим виджет запросить новое задание вместо молчаливого игнора
+		return verifyReply{what: "refresh", event: controlEvent(&captchapb.ServerEvent_ControlMessage{
+			Kind:        captchapb.ServerEvent_ControlMessage_REFRESH,
+			ChallengeId: challengeID,
+			Message:     "challenge expired or already solved",
+		})}
+	}
+	if reply, rejected := s.rejectBinding(challengeID, sol, sub); rejected {
+		return reply
+	}
+	// Квота списывается только за разобранный ответ: неразборчивый сжигает
+	// задание, не расходуя квоту сайта
+	data, enveloped, err := answer.Unwrap(sol.AnswerSchema, sol.AnswerKey, sub.data)
+	var (
+		confidence int32
+		detail     string
+	)
+	if err == nil {
+		confidence, detail, err = sol.check(data)
+	}
+	if err != nil {
+		return s.rejectMalformed(challengeID, sol, sub, err)
+	}
+	fingerprint := cmp.Or(sub.fingerprint, enveloped)
+	if err := s.quotas.Consume(sol.SiteKey, quota.Verifications); err != nil {
+		logging.Warnf(logging.Verification, sol.SiteKey, "Verification of challenge %s rejected: %v", challengeID, err)
+		quotaRejections.Inc(siteLabel(sol.SiteKey), string(quota.Verifications))
+		s.notifyQuota(sol.SiteKey, quota.Verifications)
+		s.audit(audit.Record{
+			Event:       audit.EventRejected,
+			ChallengeID: challengeID,
+			SiteKey:     sol.SiteKey,
+			Action:      sol.Action,
+			Kind:        sol.Kind,
+			Client:      auditClient(sub.clientIP),
+			Result:      "quota_exceeded",
+		})
+		return verifyReply{siteKey: sol.SiteKey, what: "quota rejection", event: controlEvent(&captchapb.ServerEvent_ControlMessage{
+			Kind:        captchapb.ServerEvent_ControlMessage_QUOTA_EXCEEDED,
+			ChallengeId: challengeID,
+			Message:     "monthly verification quota exceeded",
+		})}
+	}
+	siteUsage.Inc(siteLabel(sol.SiteKey), string(quota.Verifications))
+	logging.Debugf(logging.Verification, sol.SiteKey, "Challenge %s (%s, complexity %d, action %q): %s, threshold %d%%",
+		challengeID, sol.Kind, sol.Complexity, sol.Action, detail, sol.Threshold)
+	if !s.fingerprints.allow(fingerprint) {
+		// Решение с отпечатка сверх лимита не засчитывается, даже если верно
+		fingerprintRejections.Inc()
+		detail = detail + ", fingerprint velocity limit exceeded"
+		confidence = 0
+	}
+	if s.sharedChallenge(challengeID) {
+		// Второй стрим ответил, пока этот ответ проверялся
+		detail += ", challenge answered from another stream"
+		confidence = 0
+	}
+	if confidence > 0 && int(confidence) < sol.Threshold {
+		// Частичное решение ниже порога действия не засчитывается
+		detail = fmt.Sprintf("%s, confidence %d%% is below threshold %d%%", detail, confidence, sol.Threshold)
+		confidence = 0
+	}
+	if confidence > 0 {
+		logging.Infof(logging.Verification, sol.SiteKey, "Challenge %s solved SUCCESSFULLY (%s).", challengeID, logging.Solution(detail))
+	} else {
+		logging.Infof(logging.Verification, sol.SiteKey, "Challenge %s FAILED: %s.", challengeID, logging.Solution(detail))
+	}
+	s.history.record(sol.ClientIP, confidence > 0)
+	s.rememberOutcome(challengeID, outcome{
+		SiteKey:    sol.SiteKey,
+		Action:     sol.Action,
+		Confidence: confidence,
+		VerifiedAt: time.Now(),
+	})
+
+	threshold := s.scoreThreshold(sol.SiteKey, sol.Action)
+	// Токен подписывает итоговую уверенность: обнуленная ниже порога или
+	// дележом не должна пройти Assess и офлайн-проверку JWT
+	token, jwt := s.issueToken(verdict{
+		ChallengeID: challengeID,
+		SiteKey:     sol.SiteKey,
+		Action:      sol.Action,
+		Confidence:  confidence,
+		Threshold:   int32(sol.Threshold),
+		Hostname:    sol.Hostname,
+	})
+	result := "failed"
+	if passed(confidence, threshold) {
+		result = "passed"
+	}
+	verificationResults.Inc(siteLabel(sol.SiteKey), actionLabel(sol.Action), result)
+	s.audit(audit.Record{
+		Event:       audit.EventVerified,
+		ChallengeID: challengeID,
+		SiteKey:     sol.SiteKey,
+		Action:      sol.Action,
+		Kind:        sol.Kind,
+		Complexity:  sol.Complexity,
+		Client:      auditClient(sub.clientIP),
+		Confidence:  confidence,
+		Result:      result,
+	})
+	resultEvent := &captchapb.ServerEvent{
+		Event: &captchapb.ServerEvent_Result{
+			Result: &captchapb.ServerEvent_ChallengeResult{
+				ChallengeId:       challengeID,
+				ConfidencePercent: confidence,
+				Action:            sol.Action,
+				Token:             token,
+				Jwt:               jwt,
+				Passed:            passed(confidence, threshold),
+				Threshold:         threshold,
+			},
+		},
+	}
+	s.challenges.delete(challengeID)
+	s.assets.delete(sol.AssetKey)
+	return verifyReply{siteKey: sol.SiteKey, what: "result", event: resultEvent}
+}
+
+// rejectMalformed проваливает задание с неразборчивым ответом: задание
+// сгорает, как после неверного ответа, иначе его можно было бы перебирать
+func (s *captchaService) rejectMalformed(challengeID string, sol solution, sub submission, err error) verifyReply {
+	logging.Infof(logging.Verification, sol.SiteKey, "Failed to parse client solution for %s: %v", challengeID, err)
+	s.challenges.delete(challengeID)
+	s.assets.delete(sol.AssetKey)
+	s.history.record(sol.ClientIP, false)
+	s.rememberOutcome(challengeID, outcome{SiteKey: sol.SiteKey, Action: sol.Action, VerifiedAt: time.Now()})
+	verificationResults.Inc(siteLabel(sol.SiteKey), actionLabel(sol.Action), "malformed")
+	s.audit(audit.Record{
+		Event:       audit.EventRejected,
+		ChallengeID: challengeID,
+		SiteKey:     sol.SiteKey,
+		Action:      sol.Action,
+		Kind:        sol.Kind,
+		Client:      auditClient(sub.clientIP),
+		Result:      "malformed",
+	})
+	return verifyReply{siteKey: sol.SiteKey, what: "malformed solution rejection", event: &captchapb.ServerEvent{
+		Event: &captchapb.ServerEvent_Result{Result: &captchapb.ServerEvent_ChallengeResult{
+			ChallengeId: challengeID,
+			Action:      sol.Action,
+			Threshold:   s.scoreThreshold(sol.SiteKey, sol.Action),
+		}},
+	}}
+}
+
+// notifyDraining предупреждает подключенные виджеты, что инстанс уходит на остановку
+func (s *captchaService) notifyDraining(retryAfter time.Duration) {
+	s.streams.broadcast(&captchapb.ServerEvent_ControlMessage{
+		Kind:              captchapb.ServerEvent_ControlMessage_DRAINING,
+		Message:           "captcha instance is shutting down",
+		RetryAfterSeconds: int32(retryAfter / time.Second),
+	})
+}
+
+// drain переводит инстанс в режим остановки: новые задания не выдаются,
+// балансер перестает слать их сюда, а выданные задания проверяются до истечения
+func (s *captchaService) drain(retryAfter time.Duration) {
+	s.drainOnce.Do(func() {
+		log.Printf("Draining instance, outstanding challenges: %d", s.outstandingChallenges())
+		s.health.setDraining()
+		s.notifyDraining(retryAfter)
+		if err := s.link.setState(balancerpb.RegisterInstanceRequest_DRAINING); err != nil {
+			log.Printf("Failed to send DRAINING event: %v", err)
 		}
+	})
+}
+
+// outstandingChallenges считает выданные и еще не истекшие задания
+func (s *captchaService) outstandingChallenges() int {
+	return s.challenges.count()
+}
+
+// reportLoad заполняет показатели нагрузки в heartbeat для автомасштабирования
+func (s *captchaService) reportLoad(req *balancerpb.RegisterInstanceRequest) {
+	req.ComplexityStats = s.genStats.snapshot()
+	req.PendingChallenges = int32(s.outstandingChallenges())
+	req.CapacityPercent = uint32(s.capacityPercent.Load())
+	req.SettingsVersion = s.appliedSettings.Load()
+}
+
+// waitForChallenges ждет, пока выданные задания будут решены или истекут, но не дольше deadline
+func (s *captchaService) waitForChallenges(deadline time.Time) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for s.outstandingChallenges() > 0 {
+		if time.Now().After(deadline) {
+			log.Printf("Drain deadline reached with %d outstanding challenges", s.outstandingChallenges())
+			return
+		}
+		<-ticker.C
 	}
+	log.Println("All outstanding challenges are settled.")
 }
 
 // main инициализирует сервис с генератором
 func main() {
-	port, err := findFreePort(minPort, maxPort)
+	if len(os.Args) > 1 && os.Args[1] == "dev" {
+		if err := runDev(); err != nil {
+			log.Fatalf("Dev environment failed: %v", err)
+		}
+		return
+	}
+
+	cfg := loadConfig()
+	setMetricLabels(cfg)
+	cfg.applyLogLevels()
+	if err := cfg.checkDebug(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	port, err := findFreePort(cfg.MinPort, cfg.MaxPort)
 	if err != nil {
 		log.Fatalf("Failed to find a free port: %v", err)
 	}
@@ -147,79 +901,394 @@ func main() {
 		log.Fatalf("Failed to listen on port %d: %v", port, err)
 	}
 
-	grpcServer := grpc.NewServer()
+	reporter, err := errreport.New(cfg.SentryDSN, cfg.SentryRelease, cfg.SentryEnvironment)
+	if err != nil {
+		log.Fatalf("Failed to set up error reporting: %v", err)
+	}
+	defer reporter.Close(2 * time.Second)
+
+	// Access-лог снаружи: в него попадают и паники, и сбои, внесенные chaos
+	var unary []grpc.UnaryServerInterceptor
+	var streaming []grpc.StreamServerInterceptor
+	if cfg.AccessLog {
+		access := accesslog.New(os.Stdout, cfg.AccessLogSampling)
+		unary = append(unary, access.UnaryInterceptor())
+		streaming = append(streaming, access.StreamInterceptor())
+	}
+	if reporter != nil {
+		log.Printf("Error reporting enabled (release %q, environment %q)", cfg.SentryRelease, cfg.SentryEnvironment)
+		unary = append(unary, reporter.UnaryInterceptor())
+		streaming = append(streaming, reporter.StreamInterceptor())
+	}
+	// Ответы проверяются до обработчиков: чужой мусор не доходит до разбора
+	unary = append(unary, payloadUnaryInterceptor(cfg.MaxEventData))
+	streaming = append(streaming, payloadStreamInterceptor(cfg.MaxEventData))
+	// Дедлайн снаружи chaos: внесенная задержка тоже расходует бюджет вызова
+	rpcDeadlines, err := parseRPCDeadlines(cfg.RPCDeadlines)
+	if err != nil {
+		log.Fatalf("Invalid RPC_DEADLINES: %v", err)
+	}
+	// SLO снаружи дедлайна: вызов, упавший по дедлайну, тоже сжигает бюджет
+	sloTracker := newSLOTracker(cfg.SLO)
+	if sloTracker != nil {
+		unary = append(unary, sloInterceptor(sloTracker))
+	}
+	unary = append(unary, deadlineInterceptor(rpcDeadlines, cfg.SlowRequestThreshold))
+	if cfg.Chaos.Enabled() {
+		log.Printf("WARNING: chaos injection enabled: latency %s at %.2f, errors %s at %.2f, stream drops at %.2f",
+			cfg.Chaos.Latency, cfg.Chaos.LatencyRate, cfg.Chaos.ErrorCode, cfg.Chaos.ErrorRate, cfg.Chaos.DropRate)
+		unary = append(unary, cfg.Chaos.UnaryInterceptor())
+		streaming = append(streaming, cfg.Chaos.StreamInterceptor())
+	}
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(streaming...))
+
+	httpPort, err := findFreePort(cfg.HTTPMinPort, cfg.HTTPMaxPort)
+	if err != nil {
+		log.Printf("HTTP server disabled: %v", err)
+		httpPort = 0
+	}
+	if cfg.ACME.Enabled() && httpPort != 0 {
+		cfg.HTTPServe.TLSConfig = startACME(cfg)
+	}
+	// Картинки отдает служебный HTTP-сервер; без него встраиваем их в HTML
+	assetBaseURL := ""
+	if cfg.LazyAssets && httpPort != 0 {
+		assetBaseURL = cfg.AssetBaseURL
+		if assetBaseURL == "" {
+			scheme := "http"
+			if cfg.HTTPServe.TLS() {
+				scheme = "https"
+			}
+			assetBaseURL = fmt.Sprintf("%s://%s:%d", scheme, instanceHost, httpPort)
+		}
+		log.Printf("Challenge images are served lazily from %s", assetBaseURL)
+	}
+	assetSigner := assetsig.New(cfg.AssetURLSecret, cfg.AssetURLTTL).WithClockSkew(cfg.ClockSkew)
+	var signAssetURL func(key, name string) string
+	if assetSigner != nil && assetBaseURL != "" {
+		log.Printf("Challenge image URLs are signed, valid for %s", cfg.AssetURLTTL)
+		signAssetURL = func(key, name string) string { return assetSigner.Sign(key, name, time.Now()) }
+	}
+
+	bindRender := cfg.RenderTokens && assetBaseURL != ""
+	if bindRender {
+		log.Println("Challenge rendering is bound to one-time widget render tokens")
+	} else if cfg.RenderTokens {
+		log.Println("RENDER_TOKENS requires lazy challenge images, render binding disabled")
+	}
+
+	var staticFiles *static.Store
+	if cfg.StaticFiles && assetBaseURL != "" {
+		staticFiles = static.New(static.DefaultLimit)
+		log.Printf("Widget CSS and JS are served as immutable files from %s/static/", assetBaseURL)
+	} else if cfg.StaticFiles {
+		log.Println("STATIC_FILES requires lazy challenge images, widget CSS and JS stay inline")
+	}
 
 	// Инициализируем генератор
-	gen, err := generator.New()
+	gen, err := generator.New(generator.Config{
+		SliderStep:   cfg.SliderStep,
+		Obfuscate:    cfg.ObfuscateWidget,
+		MaxHTMLSize:  cfg.MaxHTMLSize,
+		SizeBudget:   cfg.HTMLSizeBudget,
+		AssetBaseURL: assetBaseURL,
+		SignAssetURL: signAssetURL,
+		BindRender:   bindRender,
+		Static:       staticFiles,
+
+		BackgroundDir:    cfg.BackgroundDir,
+		TemplateDir:      cfg.TemplateDir,
+		TemplateVersions: cfg.TemplateVersions,
+		Kinds:            cfg.ChallengeTypes,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create captcha generator: %v", err)
 	}
+	log.Printf("Challenge types: %s (compiled in: %s)", strings.Join(gen.Kinds(), ", "), strings.Join(generator.CompiledKinds(), ", "))
+	if locales := gen.BackgroundLocales(); len(locales) > 0 {
+		log.Printf("Localized backgrounds loaded for: %s", strings.Join(locales, ", "))
+	}
 
-	c := cache.New(defaultExpiration, cleanupInterval)
-	// Создаем сервис, передавая ему генератор
-	service := &captchaService{
-		challenges: c,
-		generator:  gen,
+	quotaLimits := map[string]quota.Limits{}
+	if cfg.QuotaFile != "" {
+		if quotaLimits, err = quota.LoadFile(cfg.QuotaFile); err != nil {
+			log.Fatalf("Failed to load quotas: %v", err)
+		}
+	}
+
+	policies := policy.Static{}
+	if cfg.PolicyFile != "" {
+		if policies, err = policy.LoadFile(cfg.PolicyFile); err != nil {
+			log.Fatalf("Failed to load action policies: %v", err)
+		}
+		if err := validatePolicyKinds(policies); err != nil {
+			log.Fatalf("Failed to load action policies: %v", err)
+		}
+	}
+
+	service := newCaptchaService(cfg, gen, quotaLimits, policies)
+	if service.settings, err = settings.Open(cfg.SettingsHistoryFile, validateSettings); err != nil {
+		log.Fatalf("Failed to load settings history: %v", err)
+	}
+	if err := service.restoreSettings(); err != nil {
+		log.Fatalf("Failed to restore settings: %v", err)
+	}
+	velocityRules, err := parseVelocityRules(cfg.VelocityRules)
+	if err != nil {
+		log.Fatalf("Invalid VELOCITY_RULES: %v", err)
+	}
+	service.velocity = newVelocityLimiter(velocityRules)
+	if service.riskLevels, err = parseRiskLevels(cfg.RiskLevelComplexity); err != nil {
+		log.Fatalf("Invalid RISK_LEVEL_COMPLEXITY: %v", err)
+	}
+	if service.resultJWT, err = newResultSigner(cfg.ResultJWTKey, cfg.ResultJWTTTL); err != nil {
+		log.Fatalf("Invalid RESULT_JWT_KEY: %v", err)
+	}
+	if service.ipLists, err = iplist.Open(cfg.IPLists.File, cfg.IPLists.AuditFile); err != nil {
+		log.Fatalf("Failed to load ip lists: %v", err)
+	}
+	if service.trustedProxies, err = iplist.ParseProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	if len(service.trustedProxies) == 0 && cfg.BalancerAddr != "" {
+		log.Printf("WARNING: TRUSTED_PROXIES is empty: client addresses from the balancer at %s are ignored, "+
+			"IP binding, ip lists and rate limits will see the balancer address", cfg.BalancerAddr)
+	}
+	if cfg.Notifications.File != "" {
+		if service.notifier, err = notify.LoadFile(cfg.Notifications.File, cfg.Notifications.Timeout); err != nil {
+			log.Fatalf("Failed to load notification providers: %v", err)
+		}
+		service.notifyTimeout = cfg.Notifications.Timeout
+		service.notified = newTypedStore[bool]("notified", quotaNotifyInterval)
+		log.Printf("Tenant notifications enabled from %s", cfg.Notifications.File)
+	}
+	if cfg.AuditLogFile != "" {
+		if service.auditLog, err = audit.Open(cfg.AuditLogFile, func() string { return service.link.instanceID() }); err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer service.auditLog.Close()
+		log.Printf("Challenge audit log enabled at %s", cfg.AuditLogFile)
+	}
+	if err := service.loadFlags(cfg.Flags); err != nil {
+		log.Fatalf("Invalid feature flags: %v", err)
+	}
+	log.Printf("Feature flags: %s", service.flags.Summary())
+	if cfg.RendererAddr != "" {
+		remote, err := renderer.Dial(cfg.RendererAddr, gen.Assets(), cfg.RendererTimeout)
+		if err != nil {
+			log.Fatalf("Failed to set up renderer pool: %v", err)
+		}
+		defer remote.Close()
+		service.renderer = remote
+		log.Printf("Challenge rendering is offloaded to renderer pool at %s", cfg.RendererAddr)
+	}
+	service.reporter = reporter
+	service.assetSigner = assetSigner
+	service.staticFiles = staticFiles
+	service.strictBinding = cfg.StrictBinding
+	if service.ipBinding, err = parseIPBinding(cfg.BindIPPrefix); err != nil {
+		log.Fatalf("Invalid BIND_IP_PREFIX: %v", err)
+	}
+	service.bindRender = bindRender
+	service.slo = sloTracker
+	service.link = newBalancerLink(instanceHost, port, service.health.setBalancerLinked, service.applyRemoteConfig)
+	service.link.load = service.reportLoad
+	service.link.clockSkew = cfg.ClockSkew
+	service.link.req.Region, service.link.req.Zone = cfg.Region, cfg.Zone
+	service.region = cfg.Region
+	if cfg.ForwardSolutions {
+		service.forwarder = newSolutionForwarder(service.link, cfg.ForwardTimeout)
+		service.peerAuth = balancer.NewRegistrationAuth([]string{cfg.BalancerToken}, false, false)
+		defer service.forwarder.close()
+	}
+	if service.handoff = handoff.New(cfg.Handoff.Secret); service.handoff != nil {
+		log.Println("Pending challenges will be handed off to another instance on shutdown.")
+	}
+	registerLoadMetrics(service)
+	if err := service.link.authenticate(cfg); err != nil {
+		log.Fatalf("Failed to set up balancer credentials: %v", err)
+	}
+	service.register(grpcServer)
+	if cfg.ExtAuthz.Enabled {
+		extauthzpb.RegisterAuthorizationServer(grpcServer, &extAuthzServer{s: service, cfg: cfg.ExtAuthz})
+		log.Printf("Envoy ext_authz service enabled, token header %s.", cfg.ExtAuthz.TokenHeader)
+	}
+	if cfg.GRPCReflection {
+		// Только для отладки интеграции (grpcurl): в проде по умолчанию выключено
+		reflection.Register(grpcServer)
+		log.Println("gRPC reflection service enabled.")
+	}
+	if cfg.DebugAnswers {
+		log.Println("WARNING: DEBUG_ANSWERS is enabled, challenge HTML carries the correct answer. Never enable it in production.")
 	}
-	captchapb.RegisterCaptchaServiceServer(grpcServer, service)
 
 	log.Printf("Captcha gRPC server listening at %v", lis.Addr())
 
-	go connectToBalancer(instanceHost, port)
+	if httpPort != 0 {
+		startHTTPServer(cfg, httpPort, service)
+	}
 
-	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve gRPC: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if sloTracker != nil {
+		go sloTracker.Run(ctx, cfg.SLO.Interval)
+	}
+	if cfg.Archive.enabled() {
+		if cfg.AuditLogFile == "" {
+			log.Fatalf("ARCHIVE_URL needs AUDIT_LOG_FILE")
+		}
+		store, prefix, err := archive.Open(cfg.Archive.Store)
+		if err != nil {
+			log.Fatalf("Failed to set up audit log archive: %v", err)
+		}
+		archiver := &archive.Archiver{Store: store, Prefix: prefix, Retention: cfg.Archive.Retention, PurgeDelay: cfg.Archive.PurgeDelay}
+		go service.archiveAudit(ctx, cfg.AuditLogFile, archiver, cfg.Archive.Interval)
+		log.Printf("Audit log segments older than %s are archived to %s", cfg.Archive.Retention, cfg.Archive.Store.URL)
+	}
+	if cfg.SettingsHistoryFile != "" && cfg.SettingsCacheTTL > 0 {
+		go service.watchSettings(ctx, cfg.SettingsCacheTTL)
+	}
+	if cfg.IPLists.ReloadInterval > 0 {
+		go service.watchIPLists(ctx, cfg.IPLists.ReloadInterval)
+	}
+	if cfg.Flags.File != "" && cfg.Flags.ReloadInterval > 0 {
+		go service.watchFlags(ctx, cfg.Flags.File, cfg.Flags.ReloadInterval)
 	}
-}
 
-func findFreePort(min, max int) (int, error) {
-	for port := min; port <= max; port++ {
-		addr := fmt.Sprintf(":%d", port)
-		l, err := net.Listen("tcp", addr)
-		if err == nil {
-			l.Close()
-			return port, nil
+	// Связь с балансером живет дольше сигнального ctx: во время drain
+	// инстанс остается зарегистрированным в состоянии DRAINING
+	linkCtx, stopLink := context.WithCancel(context.Background())
+	defer stopLink()
+	balancerDone := make(chan struct{})
+	go func() {
+		defer close(balancerDone)
+		// Регистрируемся READY только после прогрева, чтобы первые запросы не ждали отрисовки
+		if !service.warmup(linkCtx, cfg.WarmupChallenges, cfg.PrewarmConcurrency) {
+			return
 		}
+		service.link.run(linkCtx, cfg.BalancerAddr)
+	}()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		deadline := time.Now().Add(cfg.MaxShutdownInterval)
+		log.Printf("Shutdown requested, draining for up to %s", cfg.MaxShutdownInterval)
+		service.drain(cfg.MaxShutdownInterval)
+		service.waitForChallenges(deadline)
+		service.handOffChallenges(cfg.Handoff.Timeout)
+		stopLink()
+		<-balancerDone
+		gracefulStop(grpcServer, max(time.Until(deadline), time.Second))
+		service.verifier.stop()
+	}()
+
+	exportDone := make(chan struct{})
+	exportCtx, stopExport := context.WithCancel(context.Background())
+	if exporter := newMetricsExporter(cfg); exporter != nil {
+		go func() {
+			defer close(exportDone)
+			exporter.Run(exportCtx)
+		}()
+	} else {
+		close(exportDone)
 	}
-	return 0, fmt.Errorf("no free ports in range %d-%d", min, max)
-}
 
-func connectToBalancer(host string, port int) {
-	conn, err := grpc.Dial(balancerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	go service.streams.runJanitor(linkCtx, cfg.StreamJanitorInterval, cfg.StreamIdleTimeout)
+
+	guard, err := newMemoryGuard(cfg.Memory, service)
 	if err != nil {
-		log.Fatalf("Did not connect to balancer: %v", err)
+		log.Fatalf("Invalid memory pressure settings: %v", err)
+	}
+	if guard != nil {
+		registerMemoryMetrics(guard)
+		go guard.run(linkCtx)
+	} else {
+		log.Println("No memory limit detected, memory pressure throttling disabled.")
 	}
-	defer conn.Close()
 
-	client := balancerpb.NewBalancerServiceClient(conn)
-	stream, err := client.RegisterInstance(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to open stream to balancer: %v", err)
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("Failed to serve gRPC: %v", err)
 	}
+	<-shutdownDone
+	// Последняя отправка метрик после drain
+	stopExport()
+	<-exportDone
+	log.Println("Captcha gRPC server stopped.")
+}
 
-	instanceID := uuid.New().String()
-	log.Printf("Registering instance with ID: %s", instanceID)
+// newCaptchaService создает сервис с генератором, квотами и политиками;
+// связь с балансером (link) задает вызывающий
+func newCaptchaService(cfg config, gen *generator.Generator, quotaLimits map[string]quota.Limits, policies policy.Static) *captchaService {
+	service := &captchaService{
+		challenges: newChallengeStore(defaultExpiration, cfg.MaxPendingPerTenant),
+		results:    newTypedStore[verdict]("results", resultTokenTTL),
+		outcomes:   newTypedStore[outcome]("outcomes", outcomeTTL),
+		claims:     newTypedStore[streamClaim]("stream_claims", streamClaimTTL),
+		genStats:   &generationStats{},
+		warm:       newWarmPool(),
+		prewarm:    newPrewarmPool(cfg.PrewarmConcurrency),
+		assets:     newAssetStore(),
+		generator:  gen,
+		renderer:   renderer.NewLocal(gen, gen.Assets()),
+		streams:    newStreamHub(cfg.StreamLimits),
+		health:     newInstanceHealth(),
+		quotas: quota.New(quota.Limits{
+			Challenges:    cfg.DefaultChallengeQuota,
+			Verifications: cfg.DefaultVerificationQuota,
+		}, quotaLimits),
+		policies: policy.NewDynamic(policies),
+		flags:    flags.New(defaultFlags()),
+		attest:   newAttestGate(cfg.Attestation),
+		risk:     risk.NewEngine(cfg.RiskBaseScore),
+		history:  newClientHistory(),
 
-	req := &balancerpb.RegisterInstanceRequest{
-		EventType:     balancerpb.RegisterInstanceRequest_READY,
-		InstanceId:    instanceID,
-		ChallengeType: challengeType,
-		Host:          host,
-		PortNumber:    int32(port),
-		Timestamp:     time.Now().Unix(),
+		fingerprints:            newFingerprintLimiter(cfg.Fingerprint),
+		invisiblePassScore:      cfg.InvisiblePassScore,
+		responseCompressor:      checkCompressor(cfg.ResponseCompression),
+		rotateMinComplexity:     cfg.RotateMinComplexity,
+		multiPieceMinComplexity: cfg.MultiPieceMinComplexity,
+		debugAnswers:            cfg.DebugAnswers,
+		riskLevels:              defaultRiskLevels(),
 	}
-	if err := stream.Send(req); err != nil {
-		log.Fatalf("Failed to send registration message: %v", err)
+	if cfg.Introspection.enabled() {
+		service.introspection = newTypedStore[verdict]("introspection", cfg.Introspection.TTL)
 	}
+	service.verifier = newVerifyPool(cfg.VerifyWorkers, cfg.VerifyQueueSize, service.verifySolution)
+	return service
+}
 
-	ticker := time.NewTicker(heartbeatInterval)
-	defer ticker.Stop()
+// register регистрирует CaptchaService и health-сервис на gRPC-сервере
+func (s *captchaService) register(grpcServer *grpc.Server) {
+	captchapb.RegisterCaptchaServiceServer(grpcServer, s)
+	healthpb.RegisterHealthServer(grpcServer, s.health.grpc)
+	s.health.syncGRPC()
+}
 
-	for range ticker.C {
-		req.Timestamp = time.Now().Unix()
-		if err := stream.Send(req); err != nil {
-			log.Printf("Failed to send heartbeat: %v", err)
-			return
+// gracefulStop ждет завершения активных стримов, но не дольше timeout
+func gracefulStop(srv *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Graceful shutdown timed out after %s, forcing stop", timeout)
+		srv.Stop()
+	}
+}
+
+func findFreePort(min, max int) (int, error) {
+	for port := min; port <= max; port++ {
+		addr := fmt.Sprintf(":%d", port)
+		l, err := net.Listen("tcp", addr)
+		if err == nil {
+			l.Close()
+			return port, nil
 		}
 	}
+	return 0, fmt.Errorf("no free ports in range %d-%d", min, max)
 }
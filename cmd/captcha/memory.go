@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// memoryHysteresis — насколько использование должно опуститься ниже порога,
+// чтобы инстанс вышел из режима давления: иначе он будет дергаться на границе
+const memoryHysteresis = 0.05
+
+// memoryState — уровень давления памяти
+type memoryState int32
+
+const (
+	memoryNormal memoryState = iota
+	memoryHigh
+	memoryCritical
+)
+
+func (st memoryState) String() string {
+	switch st {
+	case memoryHigh:
+		return "high"
+	case memoryCritical:
+		return "critical"
+	}
+	return "normal"
+}
+
+// memoryThrottle — что инстанс урезает на уровне давления
+type memoryThrottle struct {
+	// qualityFloor — ступень качества картинок, не выше которой рисуются задания
+	qualityFloor int
+	// prewarmShare — доля слотов фоновой отрисовки, которая остается доступной
+	prewarmShare float64
+	// capacityPercent — мощность, о которой инстанс сообщает балансеру
+	capacityPercent int
+}
+
+var memoryThrottles = map[memoryState]memoryThrottle{
+	memoryNormal:   {qualityFloor: 0, prewarmShare: 1, capacityPercent: 100},
+	memoryHigh:     {qualityFloor: 2, prewarmShare: 0.5, capacityPercent: 50},
+	memoryCritical: {qualityFloor: 3, prewarmShare: 0, capacityPercent: 10},
+}
+
+// memoryConfig — пороги давления памяти как доли от лимита
+type memoryConfig struct {
+	// Limit — лимит памяти в байтах; 0 — взять из GOMEMLIMIT или cgroup контейнера
+	Limit    uint64
+	High     float64
+	Critical float64
+	Interval time.Duration
+}
+
+// memoryGuard следит за памятью процесса и под давлением сам урезает нагрузку:
+// сбрасывает заранее сгенерированные задания, снижает параллелизм фоновой
+// отрисовки и качество картинок и сообщает балансеру о сниженной мощности.
+// Так инстанс деградирует постепенно, а не падает по OOM.
+type memoryGuard struct {
+	cfg     memoryConfig
+	service *captchaService
+
+	state memoryState
+	usage atomic.Uint64
+}
+
+func newMemoryGuard(cfg memoryConfig, service *captchaService) (*memoryGuard, error) {
+	if cfg.High <= 0 || cfg.Critical < cfg.High || cfg.Critical > 1 {
+		return nil, fmt.Errorf("memory pressure thresholds must satisfy 0 < high (%g) <= critical (%g) <= 1", cfg.High, cfg.Critical)
+	}
+	if cfg.Limit == 0 {
+		limit, source := detectMemoryLimit()
+		if limit == 0 {
+			return nil, nil
+		}
+		cfg.Limit = limit
+		log.Printf("Memory limit %d MiB detected from %s", limit>>20, source)
+	}
+	return &memoryGuard{cfg: cfg, service: service}, nil
+}
+
+// detectMemoryLimit берет лимит из GOMEMLIMIT, затем из cgroup v2 и v1
+func detectMemoryLimit() (uint64, string) {
+	if l := debug.SetMemoryLimit(-1); l > 0 && l < math.MaxInt64 {
+		return uint64(l), "GOMEMLIMIT"
+	}
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		// "max" в v2 и почти 2^63 в v1 означают отсутствие лимита
+		if err != nil || v >= 1<<62 {
+			continue
+		}
+		return v, path
+	}
+	return 0, ""
+}
+
+// memoryUsage — память, которую рантайм Go держит у ОС (без возвращенной куче)
+func memoryUsage() uint64 {
+	samples := []rtmetrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	rtmetrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// run проверяет память каждые cfg.Interval до отмены ctx
+func (g *memoryGuard) run(ctx context.Context) {
+	log.Printf("Memory guard enabled: limit %d MiB, high %.0f%%, critical %.0f%%",
+		g.cfg.Limit>>20, g.cfg.High*100, g.cfg.Critical*100)
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.check()
+		}
+	}
+}
+
+func (g *memoryGuard) check() {
+	usage := memoryUsage()
+	g.usage.Store(usage)
+	ratio := float64(usage) / float64(g.cfg.Limit)
+	if next := g.next(ratio); next != g.state {
+		g.apply(next, ratio)
+	}
+}
+
+// next выбирает уровень по доле использования с учетом гистерезиса при снижении
+func (g *memoryGuard) next(ratio float64) memoryState {
+	switch {
+	case ratio >= g.cfg.Critical:
+		return memoryCritical
+	case g.state == memoryCritical && ratio >= g.cfg.Critical-memoryHysteresis:
+		return memoryCritical
+	case ratio >= g.cfg.High:
+		return memoryHigh
+	case g.state >= memoryHigh && ratio >= g.cfg.High-memoryHysteresis:
+		return memoryHigh
+	}
+	return memoryNormal
+}
+
+func (g *memoryGuard) apply(state memoryState, ratio float64) {
+	prev := g.state
+	g.state = state
+	t := memoryThrottles[state]
+	reason := fmt.Sprintf("memory pressure %s (%.0f%% of limit)", state, ratio*100)
+	if state > prev {
+		log.Printf("Memory pressure rising %s -> %s: %.0f%% of %d MiB limit", prev, state, ratio*100, g.cfg.Limit>>20)
+	} else {
+		log.Printf("Memory pressure easing %s -> %s: %.0f%% of %d MiB limit", prev, state, ratio*100, g.cfg.Limit>>20)
+	}
+	memoryPressureLevel.Set(int64(state))
+
+	s := g.service
+	if state > memoryNormal {
+		if n := s.warm.drop(); n > 0 {
+			log.Printf("Dropped %d pre-generated challenges to free memory", n)
+		}
+	}
+	s.prewarm.limit(int(math.Floor(float64(cap(s.prewarm.slots)) * t.prewarmShare)))
+	s.generator.SetQualityFloor(t.qualityFloor, reason)
+	imageQualityLevel.Set(int64(s.generator.QualityLevel()))
+	s.capacityPercent.Store(int32(t.capacityPercent))
+	if state == memoryCritical {
+		// Сброшенные задания и буферы отдаем ОС сразу, не дожидаясь GC
+		runtime.GC()
+		debug.FreeOSMemory()
+	}
+	if s.link != nil {
+		if err := s.link.refresh(); err != nil {
+			log.Printf("Failed to report reduced capacity to balancer: %v", err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"captcha-service/internal/metrics"
+)
+
+const metricsExporterPrometheus = "prometheus"
+
+// Метрики инстанса капчи
+var (
+	activeStreams = metrics.NewGauge(
+		"captcha_event_streams_active",
+		"Number of currently open event streams.")
+	streamEventsReceived = metrics.NewCounter(
+		"captcha_stream_events_received_total",
+		"Client events received over event streams.")
+	streamEventsDropped = metrics.NewCounterVec(
+		"captcha_stream_events_dropped_total",
+		"Client events dropped by stream protection, by reason.",
+		"reason")
+	streamDisconnects = metrics.NewCounterVec(
+		"captcha_stream_disconnects_total",
+		"Event streams terminated by the server, by reason.",
+		"reason")
+	orphanedStreams = metrics.NewCounterVec(
+		"captcha_stream_orphans_purged_total",
+		"Event streams whose clients vanished without EOF, purged by the janitor, by reason.",
+		"reason")
+	renderRejections = metrics.NewCounterVec(
+		"captcha_render_rejections_total",
+		"Widget render token exchanges and asset requests rejected by render binding, by reason.",
+		"reason")
+	attestations = metrics.NewCounterVec(
+		"captcha_attestations_total",
+		"Client platform attestations presented with NewChallenge, by provider and result.",
+		"provider", "result")
+	invisibleDecisions = metrics.NewCounterVec(
+		"captcha_invisible_decisions_total",
+		"Invisible mode decisions on NewChallenge: pass without a challenge or challenge.",
+		"decision")
+	fingerprintRejections = metrics.NewCounter(
+		"captcha_fingerprint_velocity_rejections_total",
+		"Solutions rejected because their device fingerprint bucket exceeded the verification rate limit.")
+	rejectedPayloads = metrics.NewCounterVec(
+		"captcha_rejected_payloads_total",
+		"Solution payloads rejected before parsing, by reason (oversized, binary).",
+		"reason")
+	rejectedPayloadBytes = metrics.NewHistogram(
+		"captcha_rejected_payload_bytes",
+		"Size of solution payloads rejected before parsing.",
+		metrics.ExponentialBuckets(2<<10, 4, 8))
+	storeTypeErrors = metrics.NewCounterVec(
+		"captcha_store_type_errors_total",
+		"Reads of in-memory store entries holding a value of an unexpected type, by store. Always a bug.",
+		"store")
+	featureFlagEvaluations = metrics.NewCounterVec(
+		"captcha_feature_flag_evaluations_total",
+		"Feature flag evaluations on NewChallenge, by flag, result and the rule source that decided it (default, file, env, remote).",
+		"flag", "enabled", "source")
+	bindingRejections = metrics.NewCounterVec(
+		"captcha_binding_rejections_total",
+		"Solutions rejected because the challenge was bound to another site, session or client network, by reason.",
+		"reason")
+	sharedChallenges = metrics.NewCounterVec(
+		"captcha_shared_challenges_total",
+		"Challenges answered from more than one event stream: rejected (both streams failed) or reconnect (same client on a new stream, accepted).",
+		"outcome")
+	introspections = metrics.NewCounterVec(
+		"captcha_token_introspections_total",
+		"Result token introspection requests (POST /introspect) by whether the token was active.",
+		"active")
+	notifications = metrics.NewCounterVec(
+		"captcha_notifications_total",
+		"Tenant notifications by event (quota_exceeded, ...) and result: sent or failed (at least one provider failed).",
+		"event", "result")
+	formResults = metrics.NewCounterVec(
+		"captcha_form_results_total",
+		"Form-post flow outcomes (/challenge/form, /verify/form): passed, retry or passed_without_challenge (allowlist, invisible mode).",
+		"result")
+	forwardAuths = metrics.NewCounterVec(
+		"captcha_forward_auth_total",
+		"Reverse proxy forward-auth checks (/forward-auth) by result: allowed, missing, invalid, expired or shared.",
+		"result")
+	extAuthzDecisions = metrics.NewCounterVec(
+		"captcha_ext_authz_decisions_total",
+		"Envoy ext_authz Check calls by decision: allow, challenge, deny or missing_token.",
+		"decision")
+	auditFailures = metrics.NewCounter(
+		"captcha_audit_write_failures_total",
+		"Challenge audit records (AUDIT_LOG_FILE) that could not be written.")
+	archivedSegments = metrics.NewCounterVec(
+		"captcha_audit_archive_segments_total",
+		"Audit log segments by result: archived (uploaded to ARCHIVE_URL), purged (removed from disk after upload) or failed.",
+		"result")
+	calibrations = metrics.NewCounterVec(
+		"captcha_calibrations_total",
+		"Widget calibration events by result: accepted, rejected (implausible size or pixel ratio) or unknown (challenge not found).",
+		"result")
+	velocityRejections = metrics.NewCounterVec(
+		"captcha_velocity_rejections_total",
+		"Challenge requests rejected by per-source velocity rules, by source.",
+		"source")
+	ipListMatches = metrics.NewCounterVec(
+		"captcha_ip_list_matches_total",
+		"Challenge requests whose client IP matched an allowlist or denylist entry, by list and entry effect (hard, block).",
+		"list", "effect")
+	forwardedVerifications = metrics.NewCounterVec(
+		"captcha_forwarded_verifications_total",
+		"Solutions for challenges unknown to this instance, by forwarding result (forwarded, unknown, error).",
+		"result")
+	handoffChallenges = metrics.NewCounterVec(
+		"captcha_handoff_challenges_total",
+		"Pending challenges handed off between instances at drain, by result (exported, imported, failed).",
+		"result")
+	verificationsDeduplicated = metrics.NewCounter(
+		"captcha_verifications_deduplicated_total",
+		"Concurrent verifications of the same challenge answered by a single in-flight check.")
+	orphanedResults = metrics.NewCounter(
+		"captcha_stream_orphaned_results_total",
+		"Pending verification results dropped together with purged orphaned streams.")
+
+	verifyQueueLength = metrics.NewGauge(
+		"captcha_verify_queue_length",
+		"Solutions waiting in the verification pool queues.")
+	verifyDuration = metrics.NewHistogram(
+		"captcha_verify_duration_seconds",
+		"Time spent verifying a single solution.",
+		metrics.ExponentialBuckets(0.0001, 4, 8))
+
+	challengeHTMLBytes = metrics.NewHistogram(
+		"captcha_challenge_html_bytes",
+		"Size of generated challenge HTML in bytes.",
+		metrics.ExponentialBuckets(64<<10, 2, 8))
+	riskScores = metrics.NewHistogram(
+		"captcha_risk_score",
+		"Client risk scores computed in invisible mode.",
+		[]float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100})
+	challengesIssued = metrics.NewCounterVec(
+		"captcha_challenges_issued_total",
+		"Challenges issued, by site and action.",
+		"site", "action")
+	verificationResults = metrics.NewCounterVec(
+		"captcha_verifications_total",
+		"Checked challenge answers, by site, action and result: passed, failed or malformed.",
+		"site", "action", "result")
+	siteUsage = metrics.NewCounterVec(
+		"captcha_site_usage_total",
+		"Billable challenges and verifications per site key.",
+		"site", "kind")
+	quotaRejections = metrics.NewCounterVec(
+		"captcha_quota_rejections_total",
+		"Requests rejected because the site key exhausted its monthly quota.",
+		"site", "kind")
+	widgetHellos = metrics.NewCounterVec(
+		"captcha_widget_hellos_total",
+		"Widget capability handshakes on event streams, by widget version and negotiated answer schema.",
+		"widget_version", "answer_schema")
+	slowRequests = metrics.NewCounterVec(
+		"captcha_slow_requests_total",
+		"Unary calls that took longer than SLOW_REQUEST_THRESHOLD, by method.",
+		"method")
+
+	sloRequests = metrics.NewCounterVec(
+		"captcha_slo_requests_total",
+		"Unary calls of methods with an SLO objective, by method and result: good or bad (server error or over the latency threshold).",
+		"method", "result")
+	sloAlertsFiring = metrics.NewGaugeVec(
+		"captcha_slo_alerts_firing",
+		"1 while an SLO burn-rate alert is firing, by method and severity (page, ticket).",
+		"method", "severity")
+
+	settingsVersion = metrics.NewGauge(
+		"captcha_settings_version",
+		"Settings (policies and quotas) version applied by the instance.")
+
+	clockSkewSeconds = metrics.NewGauge(
+		"captcha_clock_skew_seconds",
+		"How far the instance clock is ahead of the balancer clock (negative: behind), by the last balancer response.")
+	imageQualityLevel = metrics.NewGauge(
+		"captcha_image_quality_level",
+		"Current image quality degradation level (0 is the best quality).")
+
+	memoryPressureLevel = metrics.NewGauge(
+		"captcha_memory_pressure_level",
+		"Memory pressure level: 0 normal, 1 high, 2 critical.")
+)
+
+// maxActionLabels — сколько разных действий получают собственное значение
+// метки action; действие приходит от клиента, и без предела любой мог бы
+// раздуть число рядов в Prometheus
+const maxActionLabels = 64
+
+var (
+	actionLabelsMu sync.Mutex
+	actionLabels   = map[string]bool{}
+)
+
+// actionLabel — значение метки action: первые maxActionLabels действий как
+// есть, остальные — "other"
+func actionLabel(action string) string {
+	if action == "" {
+		return "none"
+	}
+	actionLabelsMu.Lock()
+	defer actionLabelsMu.Unlock()
+	if actionLabels[action] {
+		return action
+	}
+	if len(actionLabels) >= maxActionLabels {
+		return "other"
+	}
+	actionLabels[action] = true
+	return action
+}
+
+// setMetricLabels задает постоянные метки всех метрик: service из
+// METRICS_SERVICE_NAME, type — тип заданий инстанса и region, если он задан.
+// Метку instance проставляет Prometheus по цели скрейпа.
+func setMetricLabels(cfg config) {
+	labels := map[string]string{"service": cfg.MetricsService, "type": challengeType}
+	if cfg.Region != "" {
+		labels["region"] = cfg.Region
+	}
+	metrics.SetConstLabels(labels)
+}
+
+// handleGrafanaDashboard — GET /admin/grafana/dashboard: дашборд Grafana по
+// текущему набору метрик. datasource — UID источника Prometheus; с
+// format=api ответ готов к POST в /api/dashboards/db.
+func handleGrafanaDashboard(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dashboard := metrics.Dashboard(metrics.DashboardOptions{
+		Title:      cmp.Or(q.Get("title"), "Captcha service"),
+		UID:        cmp.Or(q.Get("uid"), "captcha-service"),
+		Datasource: q.Get("datasource"),
+	})
+	var body any = dashboard
+	if q.Get("format") == "api" {
+		body = map[string]any{"dashboard": dashboard, "overwrite": true}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// registerLoadMetrics регистрирует метрики, которые считаются из состояния сервиса
+// в момент скрейпа: по ним можно масштабировать деплоймент через Prometheus adapter
+func registerLoadMetrics(s *captchaService) {
+	metrics.NewGaugeFunc(
+		"captcha_pending_challenges",
+		"Issued challenges that are not solved or expired yet.",
+		func() int64 { return int64(s.outstandingChallenges()) })
+}
+
+// registerMemoryMetrics регистрирует использование и лимит памяти, по которым считается давление
+func registerMemoryMetrics(g *memoryGuard) {
+	metrics.NewGaugeFunc(
+		"captcha_memory_usage_bytes",
+		"Memory held by the Go runtime at the last memory pressure check.",
+		func() int64 { return int64(g.usage.Load()) })
+	metrics.NewGaugeFunc(
+		"captcha_memory_limit_bytes",
+		"Memory limit the pressure level is computed against.",
+		func() int64 { return int64(g.cfg.Limit) })
+}
+
+// newMetricsExporter создает push-экспортер метрик; nil — только pull через /metrics
+func newMetricsExporter(cfg config) *metrics.StatsD {
+	if cfg.MetricsExporter == metricsExporterPrometheus {
+		return nil
+	}
+	sc := cfg.StatsD
+	sc.Flavor = cfg.MetricsExporter
+	exporter, err := metrics.NewStatsD(sc)
+	if err != nil {
+		log.Fatalf("Failed to set up metrics exporter: %v", err)
+	}
+	log.Printf("Pushing metrics to %s agent at %s every %s", sc.Flavor, sc.Addr, sc.Interval)
+	return exporter
+}
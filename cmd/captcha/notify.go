@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"captcha-service/internal/logging"
+	"captcha-service/internal/notify"
+	"captcha-service/internal/quota"
+)
+
+// quotaNotifyInterval — как часто тенант получает повторное уведомление об
+// исчерпанной квоте: отказ на каждый запрос не должен давать письмо на каждый
+const quotaNotifyInterval = 24 * time.Hour
+
+// notificationsConfig — уведомления тенантов; включаются NOTIFICATIONS_FILE
+type notificationsConfig struct {
+	// File — провайдеры тенантов (см. notify.LoadFile)
+	File string
+	// Timeout — предел отправки одного уведомления одному провайдеру
+	Timeout time.Duration
+}
+
+// notify отправляет уведомление в фоне: запрос, который его вызвал, не ждет
+// почтового сервера или вебхука
+func (s *captchaService) notify(n notify.Notification) {
+	if s.notifier == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.notifyTimeout)
+		defer cancel()
+		if err := s.notifier.Send(ctx, n); err != nil {
+			notifications.Inc(n.Event, "failed")
+			logging.Warnf(logging.Generator, n.SiteKey, "Failed to send %s notification: %v", n.Event, err)
+			return
+		}
+		notifications.Inc(n.Event, "sent")
+	}()
+}
+
+// notifyQuota сообщает тенанту об исчерпанной квоте kind не чаще раза в
+// quotaNotifyInterval
+func (s *captchaService) notifyQuota(siteKey string, kind quota.Kind) {
+	if s.notifier == nil || s.notified.add(siteLabel(siteKey)+"|"+string(kind), true, quotaNotifyInterval) != nil {
+		return
+	}
+	usage := s.quotas.Usage(siteKey)
+	used, limit := usage.Challenges, usage.Limits.Challenges
+	if kind == quota.Verifications {
+		used, limit = usage.Verifications, usage.Limits.Verifications
+	}
+	s.notify(notify.Notification{
+		Event:   notify.EventQuotaExceeded,
+		SiteKey: siteLabel(siteKey),
+		Subject: fmt.Sprintf("Captcha %s quota exceeded for %s", kind, siteLabel(siteKey)),
+		Text:    fmt.Sprintf("The monthly %s quota is exhausted: requests are rejected until the next billing period.", kind),
+		Fields: map[string]string{
+			"site_key": siteLabel(siteKey),
+			"kind":     string(kind),
+			"period":   usage.Period,
+			"used":     strconv.FormatInt(used, 10),
+			"limit":    strconv.FormatInt(limit, 10),
+		},
+	})
+}
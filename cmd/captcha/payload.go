@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"unicode/utf8"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkData отклоняет ответ виджета длиннее limit байт или двоичный: все
+// схемы ответа — текст (см. answer.Schemas), и такой data разбирать незачем
+func checkData(data []byte, limit int) error {
+	reason := ""
+	switch {
+	case len(data) > limit:
+		reason = "oversized"
+	case !textual(data):
+		reason = "binary"
+	default:
+		return nil
+	}
+	rejectedPayloads.Inc(reason)
+	rejectedPayloadBytes.Observe(float64(len(data)))
+	if reason == "oversized" {
+		return status.Errorf(codes.InvalidArgument, "data is %d bytes, limit is %d", len(data), limit)
+	}
+	return status.Error(codes.InvalidArgument, "data must be UTF-8 text")
+}
+
+// textual — валидный UTF-8 без управляющих символов, кроме пробельных
+func textual(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' || b == 0x7f {
+			return false
+		}
+	}
+	return utf8.Valid(data)
+}
+
+// payloadUnaryInterceptor проверяет data пересланных решений
+func payloadUnaryInterceptor(limit int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if r, ok := req.(*captchapb.ForwardSolutionRequest); ok {
+			if err := checkData(r.GetData(), limit); err != nil {
+				logging.Warnf(logging.Verification, "", "Rejected forwarded solution for challenge %s from instance %s: %v",
+					r.GetChallengeId(), r.GetFromInstance(), status.Convert(err).Message())
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// payloadStreamInterceptor проверяет data событий стрима. Отклоненное событие
+// закрывает стрим с INVALID_ARGUMENT: виджет таких ответов не присылает.
+func payloadStreamInterceptor(limit int) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &payloadStream{ServerStream: ss, limit: limit})
+	}
+}
+
+type payloadStream struct {
+	grpc.ServerStream
+	limit int
+}
+
+func (s *payloadStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	ev, ok := m.(*captchapb.ClientEvent)
+	if !ok {
+		return nil
+	}
+	if err := checkData(ev.GetData(), s.limit); err != nil {
+		logging.Warnf(logging.Verification, ev.GetSiteKey(), "Rejected event for challenge %s from %s: %v",
+			ev.GetChallengeId(), logging.IP(peerIP(s.Context())), status.Convert(err).Message())
+		return err
+	}
+	return nil
+}
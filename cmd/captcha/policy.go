@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"captcha-service/internal/generator"
+	"captcha-service/internal/policy"
+)
+
+// validatePolicyKinds проверяет, что политики ссылаются только на известные типы заданий
+func validatePolicyKinds(p policy.Static) error {
+	for site, actions := range p {
+		for name, a := range actions {
+			switch a.ChallengeType {
+			case "", generator.KindSlider, generator.KindRotate, generator.KindMulti:
+			default:
+				return fmt.Errorf("policy %s/%s: unknown challenge type %q", site, name, a.ChallengeType)
+			}
+		}
+	}
+	return nil
+}
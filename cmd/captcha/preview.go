@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"strconv"
+
+	"captcha-service/internal/generator"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/logging"
+)
+
+// handlePreview — GET /admin/preview: HTML образца задания для предпросмотра
+// виджета. Параметры: kind или complexity (0–100), theme — версия шаблона
+// (по умолчанию активная), locale; site_key и action подставляют сложность,
+// тип и локаль из политики сайта. Образец не попадает в хранилище заданий,
+// не расходует квоту и не может быть решен.
+func (s *captchaService) handlePreview(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	act := s.policies.Resolve(q.Get("site_key"), q.Get("action"))
+	complexity := act.Complexity
+	if v := q.Get("complexity"); v != "" {
+		c, err := strconv.Atoi(v)
+		if err != nil || c < 0 || c > 100 {
+			httperr.Write(w, r, http.StatusBadRequest, "complexity must be an integer between 0 and 100")
+			return
+		}
+		complexity = c
+	}
+	kind := cmp.Or(q.Get("kind"), act.ChallengeType, s.kindForComplexity(complexity))
+	if q.Get("kind") == "" {
+		// Тип по политике и сложности откатывается так же, как при выдаче
+		if k, ok := s.enabledKind(kind); ok {
+			kind = k
+		}
+	}
+	p := generator.Preview{
+		Kind:    kind,
+		Locale:  cmp.Or(q.Get("locale"), act.Locale),
+		Version: q.Get("theme"),
+	}
+	if kind == generator.KindMulti {
+		p.Pieces = s.multiPieces(complexity)
+		if v := q.Get("pieces"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				httperr.Write(w, r, http.StatusBadRequest, "pieces must be an integer")
+				return
+			}
+			p.Pieces = n
+		}
+	}
+	challenge, err := s.generator.GeneratePreview(p)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	logging.Debugf(logging.Generator, q.Get("site_key"), "Rendered %s preview with template %s", challenge.Kind, challenge.Template)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Challenge-Kind", challenge.Kind)
+	w.Header().Set("X-Challenge-Template", challenge.Template.String())
+	w.Write([]byte(challenge.HTML))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// prewarmed — задание, которое рисуется (или уже нарисовано) в фоне
+type prewarmed struct {
+	done chan struct{}
+	res  *captchapb.ChallengeResponse
+	err  error
+}
+
+// prewarmPool ограничивает число одновременных фоновых отрисовок,
+// чтобы волна prewarm-запросов не отъела CPU у синхронных NewChallenge
+type prewarmPool struct {
+	slots   chan struct{}
+	pending *typedStore[*prewarmed]
+
+	// reserved — слоты, занятые самим пулом, чтобы временно снизить параллелизм
+	mu       sync.Mutex
+	reserved int
+}
+
+func newPrewarmPool(concurrency int) *prewarmPool {
+	return &prewarmPool{
+		slots:   make(chan struct{}, max(concurrency, 1)),
+		pending: newTypedStore[*prewarmed]("prewarmed", defaultExpiration),
+	}
+}
+
+// limit снижает число одновременных фоновых отрисовок до n (не меньше одной);
+// n больше емкости пула возвращает исходный параллелизм. Слоты, занятые
+// отрисовками, освобождаются по их завершении, поэтому limit не блокируется.
+func (p *prewarmPool) limit(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	want := cap(p.slots) - min(max(n, 1), cap(p.slots))
+	for ; p.reserved < want; p.reserved++ {
+		go func() { p.slots <- struct{}{} }()
+	}
+	for ; p.reserved > want; p.reserved-- {
+		go func() { <-p.slots }()
+	}
+}
+
+// PrewarmChallenge резервирует задание и запускает его отрисовку в фоне
+func (s *captchaService) PrewarmChallenge(ctx context.Context, req *captchapb.ChallengeRequest) (*captchapb.ChallengeHandle, error) {
+	spec, err := s.prepareChallenge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p := &prewarmed{done: make(chan struct{})}
+	s.prewarm.pending.set(spec.id, p, 0)
+	done := s.genStats.enqueue(spec.complexity)
+	go func() {
+		defer close(p.done)
+		s.prewarm.slots <- struct{}{}
+		defer func() { <-s.prewarm.slots }()
+		start := time.Now()
+		// Отрисовка живет дольше вызова PrewarmChallenge: его дедлайн ее не прерывает
+		p.res, p.err = s.renderChallenge(context.Background(), spec)
+		done(start, p.err == nil)
+	}()
+	return &captchapb.ChallengeHandle{
+		ChallengeId: spec.id,
+		ExpiresAt:   time.Now().Add(defaultExpiration).Unix(),
+	}, nil
+}
+
+// GetChallenge отдает prewarm-задание, дожидаясь окончания отрисовки.
+// Задание отдается один раз: повторный запрос получит NotFound.
+func (s *captchaService) GetChallenge(ctx context.Context, req *captchapb.ChallengeHandle) (*captchapb.ChallengeResponse, error) {
+	notFound := status.Error(codes.NotFound, "prewarmed challenge not found, expired or already fetched")
+	p, err := s.prewarm.pending.get(req.GetChallengeId())
+	if err != nil {
+		return nil, notFound
+	}
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	// Задание забирает только один из одновременных GetChallenge
+	if _, err := s.prewarm.pending.consume(req.GetChallengeId()); err != nil {
+		return nil, notFound
+	}
+	if p.err == nil {
+		s.compressResponse(ctx)
+	}
+	return p.res, p.err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	balancerpb "captcha-service/api/balancer/v1"
+	"captcha-service/internal/generator"
+)
+
+// remoteConfig — настройки, которые балансер пушит инстансу во время работы
+type remoteConfig struct {
+	// epoch и version — эпоха балансера и версия конфигурации в ней
+	epoch   int64
+	version int64
+	// targetComplexity, если больше нуля, заменяет сложность из запроса
+	targetComplexity int
+	disabled         map[string]bool
+	// rotationEpoch — номер последней примененной ротации
+	rotationEpoch int64
+}
+
+// kindFallback — на какой тип задания откатываться, если выбранный отключен
+var kindFallback = map[string]string{
+	generator.KindMulti:  generator.KindRotate,
+	generator.KindRotate: generator.KindSlider,
+}
+
+// applyRemoteConfig применяет конфигурацию от балансера; устаревшие версии
+// игнорируются. Конфигурация новой эпохи (балансер перезапущен и считает
+// версии заново) применяется при любой версии.
+func (s *captchaService) applyRemoteConfig(cfg *balancerpb.InstanceConfig) {
+	cur := s.remote.Load()
+	if cur != nil && cfg.GetEpoch() == cur.epoch && cfg.GetVersion() <= cur.version {
+		return
+	}
+	if cur != nil && cfg.GetEpoch() != cur.epoch {
+		log.Printf("Balancer epoch changed (restarted balancer), accepting its config version %d", cfg.GetVersion())
+	}
+	rc := &remoteConfig{
+		epoch:            cfg.GetEpoch(),
+		version:          cfg.GetVersion(),
+		targetComplexity: int(cfg.GetTargetComplexity()),
+		disabled:         make(map[string]bool, len(cfg.GetDisabledChallengeTypes())),
+	}
+	// Номера ротаций тоже считаются заново с эпохой балансера
+	if cur != nil && cur.epoch == rc.epoch {
+		rc.rotationEpoch = cur.rotationEpoch
+	}
+	if rot := cfg.GetRotation(); rot != nil && rot.GetEpoch() != rc.rotationEpoch {
+		s.applyRotation(rot)
+		rc.rotationEpoch = rot.GetEpoch()
+	}
+	for _, kind := range cfg.GetDisabledChallengeTypes() {
+		rc.disabled[kind] = true
+	}
+	s.remote.Store(rc)
+	s.applyRemoteFlags(cfg.GetFeatureFlags())
+	if cfg.GetSettingsVersion() > s.appliedSettings.Load() {
+		go s.refreshSettings("balancer notification")
+	}
+
+	limits := s.streams.baseLimits()
+	if rl := cfg.GetRateLimit(); rl != nil {
+		if rl.GetEventsPerSecond() > 0 {
+			limits.EventsPerSecond = rl.GetEventsPerSecond()
+		}
+		if rl.GetBurst() > 0 {
+			limits.Burst = int(rl.GetBurst())
+		}
+		if rl.GetMaxInFlight() > 0 {
+			limits.MaxInFlight = int(rl.GetMaxInFlight())
+		}
+	}
+	s.streams.setLimits(limits)
+
+	log.Printf("Applied balancer config v%d: target complexity %d, disabled types [%s], stream limits %.0f/s burst %d in-flight %d",
+		rc.version, rc.targetComplexity, strings.Join(cfg.GetDisabledChallengeTypes(), ","),
+		limits.EventsPerSecond, limits.Burst, limits.MaxInFlight)
+}
+
+// applyRotation переключает параметры обфускации и версии шаблонов на новую ротацию.
+// Версия шаблона, которой нет на инстансе, пропускается: остается текущая.
+func (s *captchaService) applyRotation(rot *balancerpb.Rotation) {
+	params := generator.ObfuscationFromSeed(rot.GetEpoch(), rot.GetSeed())
+	s.generator.SetObfuscation(params)
+	templates := s.generator.Templates()
+	for kind, version := range rot.GetTemplateVersions() {
+		if err := templates.SetActive(kind, version); err != nil {
+			log.Printf("Rotation %d: keeping template %s/%s: %v", rot.GetEpoch(), kind, templates.ActiveVersion(kind), err)
+		}
+	}
+	log.Printf("Applied rotation %d: name length %d-%d, decoys %s, template versions %v",
+		rot.GetEpoch(), params.MinNameLen, params.MaxNameLen, params.Decoy, rot.GetTemplateVersions())
+}
+
+// enabledKind возвращает kind или ближайший более простой тип, который выдает
+// развертывание (CHALLENGE_TYPES, теги сборки) и не отключил балансер
+func (s *captchaService) enabledKind(kind string) (string, bool) {
+	rc := s.remote.Load()
+	for !s.generator.Supports(kind) || (rc != nil && rc.disabled[kind]) {
+		next, ok := kindFallback[kind]
+		if !ok {
+			return "", false
+		}
+		kind = next
+	}
+	return kind, true
+}
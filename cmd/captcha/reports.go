@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"captcha-service/internal/audit"
+	"captcha-service/internal/httperr"
+)
+
+// Пределы параметров отчетов: отчет читает журнал с диска, и окно без
+// ограничений превратило бы его в полный проход по истории
+const (
+	defaultReportWindow = 24 * time.Hour
+	maxReportWindow     = 31 * 24 * time.Hour
+	defaultReportLimit  = 10
+	maxReportLimit      = 100
+)
+
+// reportQuery разбирает параметры отчета: site_key, since (длительность
+// назад от текущего момента, например 168h) и limit
+func reportQuery(r *http.Request) (audit.Query, int, error) {
+	q := r.URL.Query()
+	window := defaultReportWindow
+	if v := q.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxReportWindow {
+			return audit.Query{}, 0, fmt.Errorf("since must be a positive duration up to %s", maxReportWindow)
+		}
+		window = d
+	}
+	limit := defaultReportLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxReportLimit {
+			return audit.Query{}, 0, fmt.Errorf("limit must be between 1 and %d", maxReportLimit)
+		}
+		limit = n
+	}
+	return audit.Query{SiteKey: q.Get("site_key"), Since: time.Now().Add(-window)}, limit, nil
+}
+
+// handleReport — готовые отчеты по журналу заданий (AUDIT_LOG_FILE) для
+// аналитиков без доступа к файлам инстанса. Отчет строится по горячей
+// истории этого инстанса: выгруженные в архив сегменты в нем не участвуют.
+func handleReport(path string, report func(path string, q audit.Query, limit int) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, limit, err := reportQuery(r)
+		if err != nil {
+			httperr.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		rows, err := report(path, q, limit)
+		if err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"site_key": q.SiteKey,
+			"since":    q.Since.UTC().Format(time.RFC3339),
+			"rows":     rows,
+		})
+	}
+}
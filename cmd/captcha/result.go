@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+)
+
+// outcomeTTL — сколько итог задания доступен через GetChallengeResult после проверки
+const outcomeTTL = 2 * time.Minute
+
+// outcome — итог проверки задания, как его увидел виджет
+type outcome struct {
+	SiteKey    string
+	Action     string
+	Confidence int32
+	VerifiedAt time.Time
+}
+
+func (s *captchaService) rememberOutcome(challengeID string, o outcome) {
+	s.outcomes.set(challengeID, o, jitteredTTL(outcomeTTL))
+}
+
+// GetChallengeResult возвращает итог задания; в отличие от Assess, запрос можно повторять
+func (s *captchaService) GetChallengeResult(ctx context.Context, req *captchapb.ChallengeResultRequest) (*captchapb.ChallengeResultResponse, error) {
+	res := &captchapb.ChallengeResultResponse{
+		ChallengeId: req.GetChallengeId(),
+		Status:      captchapb.ChallengeResultResponse_NOT_FOUND,
+	}
+	if o, err := s.outcomes.get(req.GetChallengeId()); err == nil {
+		if req.GetSiteKey() != "" && req.GetSiteKey() != o.SiteKey {
+			return res, nil
+		}
+		res.Status = captchapb.ChallengeResultResponse_FAILED
+		if o.Confidence > 0 {
+			res.Status = captchapb.ChallengeResultResponse_SOLVED
+		}
+		res.ConfidencePercent = o.Confidence
+		res.Action = o.Action
+		res.VerifiedAt = o.VerifiedAt.Unix()
+		res.Threshold = s.scoreThreshold(o.SiteKey, o.Action)
+		res.Passed = passed(o.Confidence, res.Threshold)
+		return res, nil
+	}
+	if sol, found := s.challenges.get(req.GetChallengeId()); found {
+		if req.GetSiteKey() != "" && req.GetSiteKey() != sol.SiteKey {
+			return res, nil
+		}
+		res.Status = captchapb.ChallengeResultResponse_PENDING
+		res.Action = sol.Action
+	}
+	return res, nil
+}
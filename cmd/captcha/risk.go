@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/risk"
+
+	"google.golang.org/grpc/peer"
+)
+
+// clientHistoryWindow — за какое время учитываются исходы проверок клиента
+const clientHistoryWindow = 15 * time.Minute
+
+// clientHistory считает решенные и проваленные задания по IP клиента и
+// задания, поделенные им с другими стримами
+type clientHistory struct {
+	counts *typedStore[int64]
+}
+
+func newClientHistory() *clientHistory {
+	return &clientHistory{counts: newTypedStore[int64]("client_history", clientHistoryWindow)}
+}
+
+func (h *clientHistory) record(ip string, solved bool) {
+	if ip == "" {
+		return
+	}
+	key := "f|" + ip
+	if solved {
+		key = "s|" + ip
+	}
+	increment(h.counts, key, 1)
+}
+
+// flagShared отмечает клиента, ответившего на задание, которое решали и из
+// другого стрима (см. rejectSharedChallenge)
+func (h *clientHistory) flagShared(ip string) {
+	if ip == "" {
+		return
+	}
+	increment(h.counts, "x|"+ip, 1)
+}
+
+// shared — сколько раз клиент отмечен flagShared за окно истории
+func (h *clientHistory) shared(ip string) int {
+	n, _ := h.counts.get("x|" + ip)
+	return int(n)
+}
+
+func (h *clientHistory) get(ip string) (failures, solves int) {
+	f, _ := h.counts.get("f|" + ip)
+	s, _ := h.counts.get("s|" + ip)
+	return int(f), int(s)
+}
+
+// inProcessKey помечает вызовы изнутри процесса (форма без JavaScript): адрес
+// и страну клиента они берут из собственного соединения с ним
+type inProcessKey struct{}
+
+func inProcess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, inProcessKey{}, true)
+}
+
+// trustedCaller — вызывающему можно верить в адресе и стране клиента: это
+// доверенный прокси (TRUSTED_PROXIES) или вызов изнутри процесса
+func (s *captchaService) trustedCaller(ctx context.Context) bool {
+	return ctx.Value(inProcessKey{}) != nil || s.trustedProxies.Trusted(peerIP(ctx))
+}
+
+// clientIP — адрес клиента claimed из запроса, если вызывающий доверенный,
+// иначе адрес gRPC-соединения: любой клиент мог бы подставить адрес из
+// allowlist или уйти из denylist и лимитов частоты
+func (s *captchaService) clientIP(ctx context.Context, claimed string) string {
+	if claimed != "" && s.trustedCaller(ctx) {
+		return claimed
+	}
+	return peerIP(ctx)
+}
+
+// clientCountry — страна клиента claimed, если вызывающий доверенный; иначе
+// страна неизвестна
+func (s *captchaService) clientCountry(ctx context.Context, claimed string) string {
+	if !s.trustedCaller(ctx) {
+		return ""
+	}
+	return countryCode(claimed)
+}
+
+// peerIP — адрес gRPC-соединения вызова
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// assessRisk оценивает риск клиента и возвращает порог, с которого невидимый
+// режим выдает токен без задания
+func (s *captchaService) assessRisk(req *captchapb.ChallengeRequest, spec challengeSpec) (risk.Assessment, int) {
+	failures, solves := s.history.get(spec.clientIP)
+	a := s.risk.Score(risk.Signals{
+		ClientIP:         spec.clientIP,
+		UserAgent:        req.GetClient().GetUserAgent(),
+		RecentFailures:   failures,
+		RecentSolves:     solves,
+		SharedChallenges: s.history.shared(spec.clientIP),
+	})
+	// Токен ниже порога действия Assess все равно не пропустит
+	return a, max(spec.passScore, spec.threshold)
+}
+
+// invisiblePass в невидимом режиме оценивает риск клиента и при оценке не ниже
+// порога выдает токен результата вместо задания
+func (s *captchaService) invisiblePass(req *captchapb.ChallengeRequest, spec challengeSpec) (*captchapb.ChallengeResponse, bool) {
+	if !spec.invisible {
+		return nil, false
+	}
+	a, passScore := s.assessRisk(req, spec)
+	riskScores.Observe(float64(a.Score))
+	if int(a.Score) < passScore {
+		invisibleDecisions.Inc("challenge")
+		logging.Debugf(logging.Verification, spec.siteKey, "Risk score %d for %s is below %d (%v), issuing challenge %s",
+			a.Score, spec.clientIP, passScore, a.Reasons, spec.id)
+		return nil, false
+	}
+	invisibleDecisions.Inc("pass")
+	logging.Infof(logging.Verification, spec.siteKey, "Challenge %s skipped in invisible mode: risk score %d (%v)", spec.id, a.Score, a.Reasons)
+	token, jwt := s.passWithoutChallenge(spec, a.Score)
+	return &captchapb.ChallengeResponse{ChallengeId: spec.id, InvisiblePass: true, RiskScore: a.Score, Token: token, Jwt: jwt}, true
+}
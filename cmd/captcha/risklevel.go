@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/generator"
+)
+
+// riskLevelTable — сложность для уровня риска из запроса по типу задания.
+// Тип "" — задания без закрепленного политикой типа: там сложность выбирает
+// и тип (HIGH по умолчанию попадает в пазл с вращением).
+type riskLevelTable map[string]map[captchapb.ChallengeRequest_RiskLevel]int
+
+// defaultRiskLevels — сложности по умолчанию: у каждого типа свой диапазон,
+// в котором его допуски и число фрагментов осмысленно меняются
+func defaultRiskLevels() riskLevelTable {
+	return riskLevelTable{
+		"":                   {captchapb.ChallengeRequest_LOW: 20, captchapb.ChallengeRequest_MEDIUM: 50, captchapb.ChallengeRequest_HIGH: 80},
+		generator.KindSlider: {captchapb.ChallengeRequest_LOW: 20, captchapb.ChallengeRequest_MEDIUM: 50, captchapb.ChallengeRequest_HIGH: 65},
+		generator.KindRotate: {captchapb.ChallengeRequest_LOW: 30, captchapb.ChallengeRequest_MEDIUM: 60, captchapb.ChallengeRequest_HIGH: 90},
+		generator.KindMulti:  {captchapb.ChallengeRequest_LOW: 90, captchapb.ChallengeRequest_MEDIUM: 95, captchapb.ChallengeRequest_HIGH: 100},
+	}
+}
+
+// parseRiskLevels накладывает RISK_LEVEL_COMPLEXITY на таблицу по умолчанию:
+// "high=85" меняет уровень заданий без закрепленного типа,
+// "slider-rotate.high=95" — уровень одного типа
+func parseRiskLevels(overrides map[string]string) (riskLevelTable, error) {
+	table := defaultRiskLevels()
+	for key, value := range overrides {
+		kind, name, ok := strings.Cut(key, ".")
+		if !ok {
+			kind, name = "", key
+		}
+		levels, known := table[kind]
+		if !known {
+			return nil, fmt.Errorf("%s: unknown challenge type %q", key, kind)
+		}
+		level, ok := captchapb.ChallengeRequest_RiskLevel_value[strings.ToUpper(name)]
+		if !ok || level == int32(captchapb.ChallengeRequest_UNSPECIFIED) {
+			return nil, fmt.Errorf("%s: unknown risk level %q (want low, medium or high)", key, name)
+		}
+		complexity, err := strconv.Atoi(value)
+		if err != nil || complexity < 1 || complexity > 100 {
+			return nil, fmt.Errorf("%s: complexity must be 1-100, got %q", key, value)
+		}
+		levels[captchapb.ChallengeRequest_RiskLevel(level)] = complexity
+	}
+	return table, nil
+}
+
+// complexity — сложность уровня риска для типа задания, закрепленного
+// политикой ("" — тип не закреплен); 0 — уровень не задан
+func (t riskLevelTable) complexity(kind string, level captchapb.ChallengeRequest_RiskLevel) int {
+	levels, ok := t[kind]
+	if !ok {
+		levels = t[""]
+	}
+	return levels[level]
+}
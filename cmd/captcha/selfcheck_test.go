@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/generator"
+	"captcha-service/internal/policy"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	selfCheckBufSize = 4 << 20
+	// selfCheckTimeout с запасом: под -race генерация задания идет секунды
+	selfCheckTimeout = time.Minute
+)
+
+// TestSelfCheck поднимает в одном процессе балансер, инстанс и скриптового клиента,
+// соединенных через bufconn без реальных портов, и проходит полный цикл протокола:
+// регистрация → задание → решение → результат → Assess/GetChallengeResult → STOPPED.
+func TestSelfCheck(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integration test: generates real challenges")
+	}
+	cfg := loadConfig()
+	balancerLis := bufconn.Listen(selfCheckBufSize)
+	instanceLis := bufconn.Listen(selfCheckBufSize)
+	dialer := func(lis *bufconn.Listener) grpc.DialOption {
+		return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		})
+	}
+
+	// Балансер: регистрация инстансов и прокси CaptchaService
+	registry := balancer.NewRegistry(time.Minute, dialer(instanceLis))
+	balancerServer := grpc.NewServer()
+	balancer.RegisterServices(balancerServer, registry, balancer.NewControlPlane(), nil, nil, "", nil)
+	go balancerServer.Serve(balancerLis)
+	defer balancerServer.Stop()
+
+	// Инстанс с картинками внутри HTML: служебного HTTP-сервера здесь нет
+	gen, err := generator.New(generator.Config{SliderStep: cfg.SliderStep, Obfuscate: cfg.ObfuscateWidget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newCaptchaService(cfg, gen, nil, policy.Static{})
+	defer service.verifier.stop()
+	service.link = newBalancerLink(instanceHost, 0, service.health.setBalancerLinked, service.applyRemoteConfig)
+	service.link.dialOpts = []grpc.DialOption{dialer(balancerLis)}
+	instanceServer := grpc.NewServer()
+	service.register(instanceServer)
+	go instanceServer.Serve(instanceLis)
+	defer instanceServer.Stop()
+
+	linkCtx, stopLink := context.WithCancel(context.Background())
+	defer stopLink()
+	linkDone := make(chan struct{})
+	go func() {
+		defer close(linkDone)
+		service.link.run(linkCtx, "passthrough:///balancer")
+	}()
+
+	if err := waitFor(func() bool {
+		_, err := registry.PickForNewChallenge()
+		return err == nil
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	conn, err := grpc.NewClient("passthrough:///balancer",
+		grpc.WithTransportCredentials(insecure.NewCredentials()), dialer(balancerLis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := captchapb.NewCaptchaServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *captchapb.ServerEvent, 8)
+	go func() {
+		defer close(events)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	// Верное и неверное решение: ответ берем из хранилища инстанса
+	for _, tc := range []struct {
+		name     string
+		offset   int
+		want     captchapb.ChallengeResultResponse_Status
+		decision captchapb.AssessResponse_Decision
+	}{
+		{"solve", 0, captchapb.ChallengeResultResponse_SOLVED, captchapb.AssessResponse_ALLOW},
+		{"fail", 100, captchapb.ChallengeResultResponse_FAILED, captchapb.AssessResponse_DENY},
+	} {
+		res, err := client.NewChallenge(ctx, &captchapb.ChallengeRequest{Complexity: 10})
+		if err != nil {
+			t.Fatalf("%s: generate: %v", tc.name, err)
+		}
+		stored, ok := service.challenges.get(res.GetChallengeId())
+		if !ok {
+			t.Fatalf("%s: challenge %s is not stored on the instance", tc.name, res.GetChallengeId())
+		}
+		x := stored.X + tc.offset
+		if err := stream.Send(&captchapb.ClientEvent{ChallengeId: res.GetChallengeId(), Data: []byte(strconv.Itoa(x))}); err != nil {
+			t.Fatalf("%s: send answer: %v", tc.name, err)
+		}
+
+		result, err := awaitEvent(events, func(ev *captchapb.ServerEvent) bool { return ev.GetResult() != nil })
+		if err != nil {
+			t.Fatalf("%s: result: %v", tc.name, err)
+		}
+		if confidence := result.GetResult().GetConfidencePercent(); (tc.offset == 0) != (confidence > 0) {
+			t.Fatalf("%s: unexpected confidence %d%%", tc.name, confidence)
+		}
+
+		outcome, err := client.GetChallengeResult(ctx, &captchapb.ChallengeResultRequest{ChallengeId: res.GetChallengeId()})
+		if err != nil {
+			t.Fatalf("%s: GetChallengeResult: %v", tc.name, err)
+		}
+		if outcome.GetStatus() != tc.want {
+			t.Fatalf("%s: status %s, want %s", tc.name, outcome.GetStatus(), tc.want)
+		}
+
+		assessed, err := client.Assess(ctx, &captchapb.AssessRequest{Token: result.GetResult().GetToken()})
+		if err != nil {
+			t.Fatalf("%s: assess: %v", tc.name, err)
+		}
+		if assessed.GetDecision() != tc.decision {
+			t.Fatalf("%s: decision %s, want %s (%s)", tc.name, assessed.GetDecision(), tc.decision, assessed.GetReason())
+		}
+	}
+
+	// Ответ на неизвестное задание — REFRESH
+	if err := stream.Send(&captchapb.ClientEvent{ChallengeId: "self-check-unknown", Data: []byte("1")}); err != nil {
+		t.Fatalf("refresh unknown challenge: %v", err)
+	}
+	if _, err := awaitEvent(events, func(ev *captchapb.ServerEvent) bool {
+		return ev.GetControl().GetKind() == captchapb.ServerEvent_ControlMessage_REFRESH
+	}); err != nil {
+		t.Fatalf("refresh unknown challenge: %v", err)
+	}
+	stream.CloseSend()
+
+	// Остановка: инстанс шлет STOPPED и пропадает из маршрутизации
+	stopLink()
+	<-linkDone
+	if err := waitFor(func() bool {
+		_, err := registry.PickForNewChallenge()
+		return errors.Is(err, balancer.ErrNoInstances)
+	}); err != nil {
+		t.Fatalf("deregister: %v", err)
+	}
+}
+
+// awaitEvent ждет первое событие стрима, подходящее под match
+func awaitEvent(events <-chan *captchapb.ServerEvent, match func(*captchapb.ServerEvent) bool) (*captchapb.ServerEvent, error) {
+	timeout := time.After(selfCheckTimeout)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil, errors.New("event stream closed")
+			}
+			if match(ev) {
+				return ev, nil
+			}
+		case <-timeout:
+			return nil, errors.New("timed out waiting for event")
+		}
+	}
+}
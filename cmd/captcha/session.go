@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/middleware"
+)
+
+// sessionConfig — режим сессионных cookie: после успешного решения HTTP-шлюз
+// обменивает токен результата на подписанную cookie с коротким сроком жизни
+type sessionConfig struct {
+	Secret []byte
+	Name   string
+	TTL    time.Duration
+	Domain string
+	Secure bool
+}
+
+func (c sessionConfig) enabled() bool { return len(c.Secret) > 0 }
+
+// handleSession — POST /session (token, site_key, action в форме): одноразово погашает
+// токен результата как Assess и при ALLOW выставляет сессионную cookie. Запрос должен
+// прийти на инстанс, выдавший задание, — как и картинки заданий. site_key обязателен:
+// Assess сверяет его с токеном, и только так он годится в claims cookie.
+func (s *captchaService) handleSession(w http.ResponseWriter, r *http.Request, cfg sessionConfig) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	req := &captchapb.AssessRequest{
+		Token:   r.FormValue("token"),
+		SiteKey: r.FormValue("site_key"),
+		Action:  r.FormValue("action"),
+	}
+	if req.GetSiteKey() == "" {
+		httperr.Write(w, r, http.StatusBadRequest, "site_key is required")
+		return
+	}
+	res, reason := s.assess(req)
+	res.Reason = reason
+	logging.Infof(logging.Verification, req.GetSiteKey(), "Session request for challenge %s: %s (%s)", tokenChallengeID(req.GetToken()), res.Decision, reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.Decision != captchapb.AssessResponse_ALLOW {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{"decision": res.Decision.String(), "reason": reason})
+		return
+	}
+
+	expires := time.Now().Add(cfg.TTL)
+	http.SetCookie(w, &http.Cookie{
+		Name: cfg.Name,
+		Value: middleware.Sign(cfg.Secret, middleware.Claims{
+			ChallengeID: tokenChallengeID(req.GetToken()),
+			SiteKey:     req.GetSiteKey(),
+			Action:      res.Action,
+			Confidence:  res.ConfidencePercent,
+			ExpiresAt:   expires.Unix(),
+		}),
+		Path:     "/",
+		Domain:   cfg.Domain,
+		Expires:  expires,
+		MaxAge:   int(cfg.TTL.Seconds()),
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	json.NewEncoder(w).Encode(map[string]any{"decision": res.Decision.String(), "expires_at": expires.Unix()})
+}
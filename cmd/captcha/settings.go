@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/settings"
+)
+
+// currentSettings — действующие политики и квоты инстанса
+func (s *captchaService) currentSettings() settings.Settings {
+	defaults, perSite := s.quotas.Limits()
+	return settings.Settings{Policies: s.policies.Current(), QuotaDefaults: defaults, Quotas: perSite}
+}
+
+// validateSettings проверяет версию политик и квот перед записью в историю
+func validateSettings(st settings.Settings) error {
+	if err := st.Policies.Validate(); err != nil {
+		return err
+	}
+	return validatePolicyKinds(st.Policies)
+}
+
+// applySettings применяет проверенную версию к новым запросам. Запросы читают
+// политики и квоты из памяти инстанса: история настроек на каждый запрос не читается.
+func (s *captchaService) applySettings(snapshot settings.Snapshot) {
+	s.policies.Set(snapshot.Settings.Policies)
+	s.quotas.SetLimits(snapshot.Settings.QuotaDefaults, snapshot.Settings.Quotas)
+	s.appliedSettings.Store(int64(snapshot.Version))
+	settingsVersion.Set(int64(snapshot.Version))
+}
+
+// refreshSettings применяет новую версию из общей истории, если ее записал
+// другой инстанс; reason — что вызвало проверку (для лога)
+func (s *captchaService) refreshSettings(reason string) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	if _, err := s.settings.Reload(); err != nil {
+		logging.Errorf(logging.Generator, "", "Failed to reload settings history, keeping version %d: %v", s.appliedSettings.Load(), err)
+		return
+	}
+	current, ok := s.settings.Current()
+	if !ok || int64(current.Version) <= s.appliedSettings.Load() {
+		return
+	}
+	if err := validateSettings(current.Settings); err != nil {
+		// Отвергнутая версия считается обработанной: ошибка не повторяется на каждой проверке
+		logging.Errorf(logging.Generator, "", "Settings version %d from shared history rejected, keeping version %d: %v",
+			current.Version, s.appliedSettings.Load(), err)
+		s.appliedSettings.Store(int64(current.Version))
+		return
+	}
+	s.applySettings(current)
+	logging.Infof(logging.Generator, "", "Settings version %d loaded from shared history on %s (changed by %s): %v",
+		current.Version, reason, current.Author, current.Changes)
+}
+
+// watchSettings проверяет общую историю настроек каждые ttl: так изменения
+// доходят до инстанса, даже если оповещение балансера потерялось
+func (s *captchaService) watchSettings(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshSettings("cache expiry")
+		}
+	}
+}
+
+// restoreSettings при старте применяет последнюю версию из истории; пустую
+// историю начинает конфигурация из POLICY_FILE, QUOTA_FILE и переменных окружения
+func (s *captchaService) restoreSettings() error {
+	loaded := s.currentSettings()
+	current, ok := s.settings.Current()
+	if !ok {
+		snapshot, err := s.settings.Commit(loaded, "startup", "initial configuration")
+		if err == nil {
+			s.applySettings(snapshot)
+		}
+		return err
+	}
+	if changes := settings.Diff(loaded, current.Settings); len(changes) > 0 {
+		logging.Warnf(logging.Generator, "", "Settings version %d from history differs from configuration files in %d places; using the history version",
+			current.Version, len(changes))
+	}
+	if err := validateSettings(current.Settings); err != nil {
+		return fmt.Errorf("settings version %d: %w", current.Version, err)
+	}
+	s.applySettings(current)
+	logging.Infof(logging.Generator, "", "Settings version %d restored (changed by %s at %s)", current.Version, current.Author, current.Time.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// handleSettings — GET /admin/settings: действующая версия политик и квот;
+// PUT /admin/settings[?comment=...] заменяет их новой версией
+func (s *captchaService) handleSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		current, _ := s.settings.Current()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(current)
+		return
+	}
+	var st settings.Settings
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	snapshot, err := s.settings.Commit(st, adminAuthor(r), r.URL.Query().Get("comment"))
+	s.settingsResult(w, r, snapshot, err)
+}
+
+// handleSettingsVersions — GET /admin/settings/versions: кто, когда и что менял
+func (s *captchaService) handleSettingsVersions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.settings.List())
+}
+
+// handleSettingsVersion — GET /admin/settings/versions/{version}: версия целиком
+func (s *captchaService) handleSettingsVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "version must be an integer")
+		return
+	}
+	snapshot, err := s.settings.Get(version)
+	if err != nil {
+		httperr.Write(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// handleSettingsRollback — POST /admin/settings/rollback[?version=N]: возврат к
+// версии N (по умолчанию к предыдущей); откат записывается новой версией
+func (s *captchaService) handleSettingsRollback(w http.ResponseWriter, r *http.Request) {
+	var version int
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httperr.Write(w, r, http.StatusBadRequest, "version must be a positive integer")
+			return
+		}
+		version = n
+	}
+	snapshot, err := s.settings.Rollback(version, adminAuthor(r))
+	s.settingsResult(w, r, snapshot, err)
+}
+
+// settingsResult применяет записанную версию и отвечает ею либо ошибкой записи
+func (s *captchaService) settingsResult(w http.ResponseWriter, r *http.Request, snapshot settings.Snapshot, err error) {
+	switch {
+	case errors.Is(err, settings.ErrNotFound):
+		httperr.Write(w, r, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, settings.ErrInvalid):
+		httperr.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		httperr.Write(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.publishSettings(snapshot)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// publishSettings применяет записанную версию и сообщает о ней балансеру
+func (s *captchaService) publishSettings(snapshot settings.Snapshot) {
+	s.settingsMu.Lock()
+	// Версию новее, пришедшую от другого инстанса, запись не перетирает
+	if int64(snapshot.Version) > s.appliedSettings.Load() {
+		s.applySettings(snapshot)
+	}
+	s.settingsMu.Unlock()
+	logging.Warnf(logging.Generator, "", "Settings version %d applied by %s: %v", snapshot.Version, snapshot.Author, snapshot.Changes)
+	// Внеочередной heartbeat: балансер сразу оповестит остальные инстансы
+	if err := s.link.refresh(); err != nil {
+		logging.Warnf(logging.Balancer, "", "Failed to announce settings version %d to balancer: %v", snapshot.Version, err)
+	}
+}
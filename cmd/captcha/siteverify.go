@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/pkg/verify"
+)
+
+// resultSigner подписывает результаты заданий JWT для офлайн-проверки
+// бэкендом сайта (pkg/verify). Ключ общий для флота: JWT любого инстанса
+// проверяется по JWKS любого другого.
+type resultSigner struct {
+	key ed25519.PrivateKey
+	ttl time.Duration
+}
+
+// newResultSigner разбирает RESULT_JWT_KEY — seed Ed25519 (32 байта) в base64;
+// пустой ключ выключает JWT (nil)
+func newResultSigner(encoded string, ttl time.Duration) (*resultSigner, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("want base64 of a %d-byte Ed25519 seed", ed25519.SeedSize)
+	}
+	return &resultSigner{key: ed25519.NewKeyFromSeed(seed), ttl: ttl}, nil
+}
+
+// sign возвращает JWT результата; пусто, если JWT выключены
+func (r *resultSigner) sign(v verdict) string {
+	if r == nil {
+		return ""
+	}
+	now := time.Now()
+	return verify.Sign(r.key, verify.Claims{
+		Issuer:      verify.Issuer,
+		ChallengeID: v.ChallengeID,
+		SiteKey:     v.SiteKey,
+		Action:      v.Action,
+		Hostname:    v.Hostname,
+		Region:      v.Region,
+		Confidence:  v.Confidence,
+		Threshold:   v.Threshold,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(r.ttl).Unix(),
+	})
+}
+
+// maxHostname — длина DNS-имени; длиннее — мусор из запроса, в результат не попадает
+const maxHostname = 253
+
+// clientHostname — хост страницы из ClientContext в нормализованном виде
+func clientHostname(req *captchapb.ChallengeRequest) string {
+	host := verify.NormalizeHostname(req.GetClient().GetHostname())
+	if len(host) > maxHostname {
+		return ""
+	}
+	return host
+}
+
+// handleJWKS — GET /.well-known/jwks.json: публичный ключ для проверки JWT результатов
+func (r *resultSigner) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(verify.JWKS{Keys: []verify.JWK{verify.PublicJWK(r.key.Public().(ed25519.PublicKey))}})
+}
+
+// handleSiteverify — POST /siteverify (token, site_key, action в форме): HTTP-версия
+// Assess для бэкендов без gRPC. Токен погашается одноразово; запрос должен прийти
+// на инстанс, выдавший задание, как и /session.
+func (s *captchaService) handleSiteverify(w http.ResponseWriter, r *http.Request) {
+	req := &captchapb.AssessRequest{
+		Token:   r.FormValue("token"),
+		SiteKey: r.FormValue("site_key"),
+		Action:  r.FormValue("action"),
+	}
+	res, reason := s.assess(req)
+	log.Printf("Siteverify for challenge %s: %s (%s)", tokenChallengeID(req.GetToken()), res.Decision, reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verify.SiteverifyResponse{
+		Success:     res.Decision == captchapb.AssessResponse_ALLOW,
+		Decision:    res.Decision.String(),
+		Reason:      reason,
+		ChallengeID: tokenChallengeID(req.GetToken()),
+		Action:      res.Action,
+		Hostname:    res.Hostname,
+		Region:      res.Region,
+		Confidence:  res.ConfidencePercent,
+		Threshold:   res.Threshold,
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/slo"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sloConfig — цели уровня обслуживания унарных методов CaptchaService и
+// алерты по скорости сжигания бюджета; включается SLO_OBJECTIVES
+type sloConfig struct {
+	// Objectives — метод -> "цель%[/порог задержки]", например
+	// NewChallenge=99.9/300ms,Assess=99.95
+	Objectives map[string]string
+	// Webhooks — адреса, куда уходят алерты (POST с slo.Alert)
+	Webhooks       []string
+	WebhookTimeout time.Duration
+	// Interval — как часто проверяются правила
+	Interval time.Duration
+}
+
+// newSLOTracker создает трекер целей из cfg; nil — цели не заданы
+func newSLOTracker(cfg sloConfig) *slo.Tracker {
+	if len(cfg.Objectives) == 0 {
+		return nil
+	}
+	objectives, err := slo.ParseObjectives(cfg.Objectives)
+	if err != nil {
+		log.Fatalf("Invalid SLO_OBJECTIVES: %v", err)
+	}
+	t := slo.NewTracker(objectives, slo.DefaultRules, cfg.Webhooks, cfg.WebhookTimeout)
+	t.OnAlert = func(a slo.Alert) {
+		if a.Alert == slo.AlertBurnRate {
+			sloAlertsFiring.Set(1, a.Objective.Method, a.Severity)
+			log.Printf("WARNING: SLO %s alert: %s", a.Severity, a.Summary)
+			return
+		}
+		sloAlertsFiring.Set(0, a.Objective.Method, a.Severity)
+		log.Printf("SLO %s alert resolved: %s", a.Severity, a.Summary)
+	}
+	for _, o := range objectives {
+		if !slices.ContainsFunc(captchapb.CaptchaService_ServiceDesc.Methods, func(m grpc.MethodDesc) bool { return m.MethodName == o.Method }) {
+			log.Fatalf("Invalid SLO_OBJECTIVES: %s: unknown unary method of CaptchaService", o.Method)
+		}
+		for _, r := range slo.DefaultRules {
+			sloAlertsFiring.Set(0, o.Method, r.Severity)
+		}
+		log.Printf("SLO objective: %s", o)
+	}
+	return t
+}
+
+// serverFailure — коды, которыми сервис сжигает бюджет: ошибки клиента
+// (неверный аргумент, исчерпанная квота) к доступности сервиса не относятся
+func serverFailure(code codes.Code) bool {
+	switch code {
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DeadlineExceeded, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+// sloInterceptor учитывает унарные вызовы CaptchaService в целях t
+func sloInterceptor(t *slo.Tracker) grpc.UnaryServerInterceptor {
+	const prefix = "/captcha.v1.CaptchaService/"
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method, ours := strings.CutPrefix(info.FullMethod, prefix)
+		if !ours {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		res, err := handler(ctx, req)
+		if good, tracked := t.Record(method, time.Since(start), serverFailure(status.Code(err))); tracked {
+			result := "bad"
+			if good {
+				result = "good"
+			}
+			sloRequests.Inc(method, result)
+		}
+		return res, err
+	}
+}
+
+// handleSLO — GET /admin/slo: вызовы, скорости сжигания по окнам и сработавшие правила
+func (s *captchaService) handleSLO(w http.ResponseWriter, r *http.Request) {
+	if s.slo == nil {
+		httperr.Write(w, r, http.StatusNotFound, "no SLO objectives configured (SLO_OBJECTIVES)")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.slo.Status())
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"captcha-service/internal/answer"
+	"captcha-service/internal/generator"
+)
+
+// Структура для хранения ответа
+type solution struct {
+	Kind       string
+	X          int
+	Step       float64
+	Angle      float64
+	Pieces     []generator.PieceAnswer
+	Complexity int
+	// SiteKey — тенант, которому выдано задание; на него тарифицируется проверка
+	SiteKey string
+	// Action и Threshold — действие из запроса и порог уверенности его политики
+	Action    string
+	Threshold int
+	// AssetKey — ключ картинок задания при ленивой загрузке
+	AssetKey string
+	// ClientIP — клиент, запросивший задание: исход проверки идет в его историю
+	ClientIP string
+	// AnswerSchema — схема, в которой виджет задания присылает ответ (см. answer.Schemas)
+	AnswerSchema uint32
+	// Hostname — хост страницы виджета (ClientContext.hostname)
+	Hostname string
+	// AnswerKey — закрытый ключ задания для ответа в answer.SchemaSealed
+	AnswerKey []byte
+	// Session и IPPrefix — привязка задания: хэш сессии сайта и сеть клиента
+	// при выдаче; пустые — не привязано (см. checkBinding)
+	Session  string
+	IPPrefix string
+	// Region и Country — регион инстанса и страна клиента при выдаче, если
+	// политика привязывает к ним проверку (geo_pin); пустые — не привязано
+	Region  string
+	Country string
+	// Width и Height — размер исходного изображения задания
+	Width  int
+	Height int
+	// Scale — пикселей исходного изображения на физический пиксель экрана по
+	// калибровке виджета; 0 — виджет не калиброван (см. calibrate)
+	Scale float64
+}
+
+// tolerance — допуск по X в пикселях исходного изображения
+func (sol solution) tolerance() float64 {
+	return answer.Tolerance(sol.Complexity)
+}
+
+// angleTolerance — допуск по углу в градусах для пазла с вращением
+func (sol solution) angleTolerance() float64 {
+	return answer.AngleTolerance(sol.Complexity)
+}
+
+// check разбирает ответ клиента и возвращает уверенность (0-100) и
+// человекочитаемое описание сравнения для логов
+func (sol solution) check(data []byte) (int32, string, error) {
+	data, device := answer.Device(data)
+	if device && sol.Scale == 0 {
+		return 0, "", errUncalibrated
+	}
+	// px переводит координату ответа в пиксели исходного изображения
+	px := func(x float64) float64 {
+		if device {
+			return x * sol.Scale
+		}
+		return x
+	}
+	switch sol.Kind {
+	case generator.KindMulti:
+		positions, err := answer.ParseMulti(data)
+		if err != nil {
+			return 0, "", err
+		}
+		for id, x := range positions {
+			positions[id] = px(x)
+		}
+		// Частичная расстановка дает частичную уверенность
+		placed := 0
+		for _, p := range sol.Pieces {
+			x, ok := positions[p.ID]
+			if !ok {
+				continue
+			}
+			if within, _ := answer.Within(x, float64(p.X), sol.tolerance(), sol.Step); within {
+				placed++
+			}
+		}
+		detail := fmt.Sprintf("placed %d of %d pieces (expected %v, got %v, tolerance: %g)",
+			placed, len(sol.Pieces), sol.Pieces, positions, sol.tolerance())
+		return int32(100 * placed / len(sol.Pieces)), detail, nil
+	case generator.KindRotate:
+		x, angle, err := answer.ParseRotate(data)
+		if err != nil {
+			return 0, "", err
+		}
+		x = px(x)
+		okX, deltaX := answer.Within(x, float64(sol.X), sol.tolerance(), sol.Step)
+		deltaAngle := answer.AngleDelta(angle, sol.Angle)
+		okAngle := deltaAngle <= sol.angleTolerance()
+		detail := fmt.Sprintf("expected ~%d/%g°, got %g/%g° (delta: %g/%g°, tolerance: %g/%g°)",
+			sol.X, sol.Angle, x, angle, deltaX, deltaAngle, sol.tolerance(), sol.angleTolerance())
+		if okX && okAngle {
+			return 100, detail, nil
+		}
+		return 0, detail, nil
+	default:
+		x, err := answer.ParseSlider(data)
+		if err != nil {
+			return 0, "", err
+		}
+		x = px(x)
+		ok, delta := answer.Within(x, float64(sol.X), sol.tolerance(), sol.Step)
+		detail := fmt.Sprintf("expected ~%d, got %g (delta: %g, tolerance: %g, step: %g)",
+			sol.X, x, delta, sol.tolerance(), sol.Step)
+		if ok {
+			return 100, detail, nil
+		}
+		return 0, detail, nil
+	}
+}
+
+// payload — правильный ответ в формате виджета; только для DEBUG_ANSWERS
+func (sol solution) payload() string {
+	var p string
+	switch sol.Kind {
+	case generator.KindMulti:
+		parts := make([]string, len(sol.Pieces))
+		for i, p := range sol.Pieces {
+			parts[i] = fmt.Sprintf("%s:%d", p.ID, p.X)
+		}
+		p = strings.Join(parts, ";")
+	case generator.KindRotate:
+		p = fmt.Sprintf("%d,%g", sol.X, sol.Angle)
+	default:
+		p = strconv.Itoa(sol.X)
+	}
+	var key []byte
+	if sol.AnswerSchema == answer.SchemaSealed {
+		key, _ = answer.PublicKey(sol.AnswerKey)
+	}
+	data, _ := answer.Wrap(sol.AnswerSchema, key, p, "")
+	return string(data)
+}
+
+// maxLoggedPayload — сколько байт ответа попадает в лог: враждебный клиент
+// может прислать мегабайты в data
+const maxLoggedPayload = 64
+
+// payloadForLog возвращает ответ клиента для лога, обрезанный до maxLoggedPayload
+func payloadForLog(data []byte) string {
+	if len(data) <= maxLoggedPayload {
+		return fmt.Sprintf("%q", data)
+	}
+	return fmt.Sprintf("%q... (%d bytes)", data[:maxLoggedPayload], len(data))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"captcha-service/internal/generator"
+)
+
+// fuzzSolutions — по заданию каждого вида, с сеткой и калибровкой и без
+var fuzzSolutions = []solution{
+	{Kind: generator.KindSlider, X: 137, Complexity: 50},
+	{Kind: generator.KindSlider, X: 137, Step: 2.5, Complexity: 100, Scale: 0.5},
+	{Kind: generator.KindRotate, X: 137, Angle: 355, Complexity: 30},
+	{Kind: generator.KindMulti, Pieces: []generator.PieceAnswer{{ID: "0", X: 137}, {ID: "1", X: 402}}, Complexity: 70, Scale: 2},
+}
+
+// FuzzCheck: любой ответ клиента дает уверенность 0-100, ошибка разбора —
+// нулевую, а правильный ответ всегда принимается
+func FuzzCheck(f *testing.F) {
+	for i, sol := range fuzzSolutions {
+		f.Add(uint8(i), []byte(sol.payload()))
+	}
+	for _, seed := range []string{"", "138.4", "dp=274", "137,5", "0:137", "0:137;1:402;2:1", "1e400", "-0"} {
+		f.Add(uint8(0), []byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		sol := fuzzSolutions[int(i)%len(fuzzSolutions)]
+		confidence, _, err := sol.check(data)
+		if confidence < 0 || confidence > 100 {
+			t.Fatalf("confidence %d for %q", confidence, data)
+		}
+		if err != nil && confidence != 0 {
+			t.Fatalf("confidence %d with error %v", confidence, err)
+		}
+	})
+}
+
+// Правильный ответ принимается в любом виде задания
+func TestCheckAcceptsPayload(t *testing.T) {
+	for _, sol := range fuzzSolutions {
+		confidence, detail, err := sol.check([]byte(sol.payload()))
+		if err != nil || confidence != 100 {
+			t.Errorf("%s: confidence %d, err %v (%s)", sol.Kind, confidence, err, detail)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"captcha-service/internal/logging"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	// storeCleanupJitter — разброс периода очистки хранилищ: хранилища создаются
+	// при старте одновременно и без разброса сканировали бы истекшие записи разом
+	storeCleanupJitter = 0.2
+	// storeTTLJitter — до какой доли TTL запись может прожить дольше: записи,
+	// созданные волной запросов, не истекают одной пачкой в одном проходе очистки
+	storeTTLJitter = 0.1
+	// storeProbeTimeout — сколько readiness-проверка ждет хранилище
+	storeProbeTimeout = time.Second
+)
+
+var (
+	// errStoreMiss — записи нет или она истекла
+	errStoreMiss = errors.New("entry not found or expired")
+	// errStoreType — под ключом лежит значение другого типа: ошибка в коде,
+	// который пишет в хранилище, а не в данных клиента
+	errStoreType = errors.New("entry holds a value of unexpected type")
+	// errStoreExists — add: запись уже есть
+	errStoreExists = errors.New("entry already exists")
+)
+
+// typedStore — in-memory хранилище с TTL значений одного типа V. Все чтения
+// проверяют тип: значение другого типа не роняет сервис паникой, а дает
+// errStoreType, счетчик captcha_store_type_errors_total и запись в лог.
+type typedStore[V any] struct {
+	// name — имя хранилища в метриках и логах
+	name  string
+	items *cache.Cache
+	// mu делает consume и increment атомарными относительно друг друга:
+	// из двух одновременных consume запись получает только один
+	mu sync.Mutex
+}
+
+// newTypedStore создает хранилище name с TTL записей ttl и периодом очистки
+// cleanupInterval с разбросом
+func newTypedStore[V any](name string, ttl time.Duration) *typedStore[V] {
+	spread := 1 + storeCleanupJitter*(2*rand.Float64()-1)
+	return &typedStore[V]{name: name, items: cache.New(ttl, time.Duration(float64(cleanupInterval)*spread))}
+}
+
+// jitteredTTL продлевает ttl на случайную долю до storeTTLJitter. Подходит только
+// для записей, срок которых не обещан клиенту (в отличие от ExpiresAt задания).
+func jitteredTTL(ttl time.Duration) time.Duration {
+	return ttl + time.Duration(rand.Float64()*storeTTLJitter*float64(ttl))
+}
+
+// set сохраняет v на ttl; 0 — TTL хранилища
+func (t *typedStore[V]) set(key string, v V, ttl time.Duration) {
+	t.items.Set(key, v, ttl)
+}
+
+// add сохраняет v, только если записи key еще нет, иначе errStoreExists
+func (t *typedStore[V]) add(key string, v V, ttl time.Duration) error {
+	if t.items.Add(key, v, ttl) != nil {
+		return errStoreExists
+	}
+	return nil
+}
+
+// get возвращает значение key, errStoreMiss или errStoreType
+func (t *typedStore[V]) get(key string) (V, error) {
+	v, _, err := t.getWithExpiration(key)
+	return v, err
+}
+
+// getWithExpiration — значение key и срок его хранения (нулевой — бессрочно)
+func (t *typedStore[V]) getWithExpiration(key string) (V, time.Time, error) {
+	raw, expires, ok := t.items.GetWithExpiration(key)
+	if !ok {
+		var zero V
+		return zero, time.Time{}, errStoreMiss
+	}
+	v, err := t.typed(key, raw)
+	return v, expires, err
+}
+
+// consume возвращает значение key и удаляет запись; одновременный consume
+// того же ключа получит errStoreMiss
+func (t *typedStore[V]) consume(key string) (V, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, err := t.get(key)
+	if !errors.Is(err, errStoreMiss) {
+		t.items.Delete(key)
+	}
+	return v, err
+}
+
+// update заменяет значение key результатом fn, сохраняя срок хранения записи.
+// Ошибка fn возвращается как есть, и запись не меняется.
+func (t *typedStore[V]) update(key string, fn func(V) (V, error)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, expires, err := t.getWithExpiration(key)
+	if err != nil {
+		return err
+	}
+	if v, err = fn(v); err != nil {
+		return err
+	}
+	ttl := time.Duration(cache.NoExpiration)
+	if !expires.IsZero() {
+		if ttl = time.Until(expires); ttl <= 0 {
+			return errStoreMiss
+		}
+	}
+	t.items.Set(key, v, ttl)
+	return nil
+}
+
+func (t *typedStore[V]) delete(key string) {
+	t.items.Delete(key)
+}
+
+// flush удаляет все записи
+func (t *typedStore[V]) flush() {
+	t.items.Flush()
+}
+
+// storeEntry — запись хранилища со сроком хранения
+type storeEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// entries — все неистекшие записи; записи другого типа пропускаются
+func (t *typedStore[V]) entries() []storeEntry[V] {
+	items := t.items.Items()
+	list := make([]storeEntry[V], 0, len(items))
+	for key, item := range items {
+		v, err := t.typed(key, item.Object)
+		if err != nil {
+			continue
+		}
+		e := storeEntry[V]{key: key, value: v}
+		if item.Expiration > 0 {
+			e.expires = time.Unix(0, item.Expiration)
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+// onEvicted вызывает fn при удалении и истечении записей
+func (t *typedStore[V]) onEvicted(fn func(key string, v V)) {
+	t.items.OnEvicted(func(key string, raw any) {
+		if v, err := t.typed(key, raw); err == nil {
+			fn(key, v)
+		}
+	})
+}
+
+// probe проверяет, что хранилище отвечает: чтение берет ту же блокировку, что
+// и запросы, поэтому зависшее хранилище проверку не пройдет. Проба ничего не
+// пишет: служебная запись попала бы в одно пространство ключей с заданиями,
+// которые клиент называет по ID.
+func (t *typedStore[V]) probe() error {
+	if t == nil {
+		return fmt.Errorf("store is not initialized")
+	}
+	done := make(chan struct{})
+	go func() {
+		t.items.ItemCount()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(storeProbeTimeout):
+		return fmt.Errorf("%s store did not respond within %s", t.name, storeProbeTimeout)
+	}
+}
+
+func (t *typedStore[V]) typed(key string, raw any) (V, error) {
+	v, ok := raw.(V)
+	if !ok {
+		storeTypeErrors.Inc(t.name)
+		logging.Errorf(logging.Generator, "", "Store %s: key %q holds %T, want %T", t.name, key, raw, v)
+		return v, fmt.Errorf("%s store, key %q: %w", t.name, key, errStoreType)
+	}
+	return v, nil
+}
+
+// increment прибавляет delta к счетчику key и возвращает новое значение;
+// отсутствующий счетчик создается с TTL хранилища
+func increment(t *typedStore[int64], key string, delta int64) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, err := t.items.IncrementInt64(key, delta)
+	if err != nil {
+		// Нет записи или в ней не int64: счетчик начинается заново
+		t.items.SetDefault(key, delta)
+		return delta
+	}
+	return n
+}
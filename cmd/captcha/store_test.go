@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Из одновременных consume одного ключа запись получает ровно один, а
+// параллельные get и set не ломают ни его, ни друг друга. Запускать с -race.
+func TestStoreConsumeRace(t *testing.T) {
+	const (
+		rounds    = 2000
+		consumers = 16
+		others    = 4
+	)
+	st := newTypedStore[int]("test", time.Minute)
+	for round := range rounds {
+		key := fmt.Sprint("challenge-", round)
+		st.set(key, round, 0)
+
+		var (
+			wg      sync.WaitGroup
+			winners atomic.Int32
+			start   = make(chan struct{})
+		)
+		for range consumers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				v, err := st.consume(key)
+				switch {
+				case err == nil:
+					winners.Add(1)
+					if v != round {
+						t.Errorf("round %d: consumed %d", round, v)
+					}
+				case !errors.Is(err, errStoreMiss):
+					t.Errorf("round %d: consume: %v", round, err)
+				}
+			}()
+		}
+		for i := range others {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if v, err := st.get(key); err == nil && v != round {
+					t.Errorf("round %d: read %d", round, v)
+				} else if err != nil && !errors.Is(err, errStoreMiss) {
+					t.Errorf("round %d: get: %v", round, err)
+				}
+				st.set(fmt.Sprint("other-", round, "-", i), i, 0)
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if n := winners.Load(); n != 1 {
+			t.Fatalf("round %d: %d consumers won, want exactly 1", round, n)
+		}
+		if _, err := st.get(key); !errors.Is(err, errStoreMiss) {
+			t.Fatalf("round %d: entry survived consume: %v", round, err)
+		}
+	}
+}
+
+// Одновременные increment не теряют приращений
+func TestStoreIncrementRace(t *testing.T) {
+	const workers, perWorker = 8, 500
+	st := newTypedStore[int64]("test", time.Minute)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				increment(st, "counter", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, err := st.get("counter"); err != nil || got != workers*perWorker {
+		t.Fatalf("counter = %d (%v), want %d", got, err, workers*perWorker)
+	}
+}
+
+// Повторно переданное задание учитывается в лимите тенанта один раз
+func TestChallengeStoreAdoptTwice(t *testing.T) {
+	st := newChallengeStore(time.Minute, 10)
+	c := pendingChallenge{ID: "challenge-1", Solution: solution{SiteKey: "site-1", X: 137}, ExpiresAt: time.Now().Add(time.Minute)}
+	for range 2 {
+		if !st.adopt(c) {
+			t.Fatal("adopt rejected a pending challenge")
+		}
+	}
+	if n := st.count(); n != 1 {
+		t.Fatalf("count = %d after adopting the same challenge twice, want 1", n)
+	}
+	st.delete(c.ID)
+	if n := st.count(); n != 0 {
+		t.Fatalf("count = %d after delete, want 0", n)
+	}
+}
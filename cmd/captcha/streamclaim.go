@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/logging"
+)
+
+// streamClaimTTL — сколько помнится привязка задания к стриму: весь срок
+// задания и срок выданного по нему токена, который отзывается при дележе
+const streamClaimTTL = defaultExpiration + resultTokenTTL
+
+// streamClaim — стрим, первым приславший ответ на задание, и IP его клиента.
+// Instance — инстанс, переславший ответ из своего стрима (пусто — стрим этого
+// инстанса). Shared — ответы пришли из двух стримов: задание и его токен не
+// засчитываются.
+type streamClaim struct {
+	Instance string
+	Stream   uint64
+	IP       string
+	Shared   bool
+}
+
+// sameStream сообщает, что обе привязки — один и тот же стрим
+func (c streamClaim) sameStream(other streamClaim) bool {
+	return c.Instance == other.Instance && c.Stream == other.Stream
+}
+
+// String — стрим привязки для логов
+func (c streamClaim) String() string {
+	if c.Instance == "" {
+		return fmt.Sprintf("stream %d", c.Stream)
+	}
+	return fmt.Sprintf("stream %d of instance %s", c.Stream, c.Instance)
+}
+
+// Итог привязки ответа к стриму
+type claimResult int
+
+const (
+	// claimOwned — первый ответ на задание или ответ из стрима-владельца
+	claimOwned claimResult = iota
+	// claimShared — ответ из второго стрима: задание только что помечено поделенным
+	claimShared
+	// claimAlreadyShared — задание уже помечено поделенным
+	claimAlreadyShared
+)
+
+// claimChallenge привязывает задание к стриму при первом ответе. Повтор из
+// того же стрима — не дубль; ответ из нового стрима того же клиента после
+// закрытия прежнего — переподключение, и привязка переходит к новому стриму.
+// Иначе задание помечается поделенным; возвращается прежняя привязка.
+func (s *captchaService) claimChallenge(challengeID string, claim streamClaim) (streamClaim, claimResult) {
+	if s.claims.add(challengeID, claim, 0) == nil {
+		return claim, claimOwned
+	}
+	var prior streamClaim
+	result := claimOwned
+	err := s.claims.update(challengeID, func(c streamClaim) (streamClaim, error) {
+		prior = c
+		switch {
+		case c.Shared:
+			result = claimAlreadyShared
+			return c, nil
+		case c.sameStream(claim):
+			return c, nil
+		case c.IP == claim.IP && !s.claimConnected(c):
+			sharedChallenges.Inc("reconnect")
+			return claim, nil
+		}
+		result = claimShared
+		c.Shared = true
+		return c, nil
+	})
+	if err != nil {
+		// Привязка истекла между add и update: задание истекло вместе с ней
+		return claim, claimOwned
+	}
+	return prior, result
+}
+
+// claimConnected сообщает, что стрим привязки еще открыт. О стримах других
+// инстансов это неизвестно: ответ того же клиента из другого стрима после
+// пересылки считается переподключением.
+func (s *captchaService) claimConnected(c streamClaim) bool {
+	return c.Instance == "" && s.streams.connected(c.Stream)
+}
+
+// claimedChallenge сообщает, что ответ на задание уже привязан к стриму
+func (s *captchaService) claimedChallenge(challengeID string) bool {
+	_, err := s.claims.get(challengeID)
+	return err == nil
+}
+
+// sharedChallenge сообщает, что ответы на задание пришли из разных стримов
+func (s *captchaService) sharedChallenge(challengeID string) bool {
+	c, err := s.claims.get(challengeID)
+	return err == nil && c.Shared
+}
+
+// rejectSharedChallenge проваливает задание, на которое ответили из двух стримов
+// (пересылка токена, ферма решателей): задание сгорает, уже выданный по нему
+// токен не пройдет Assess и интроспекцию, оба стрима получают провал, а IP их клиентов
+// отмечаются в истории и снижают оценку риска. Возвращается провал для стрима
+// claim; стрим-владелец этого инстанса получает его сразу, стрим другого
+// инстанса узнает о провале при следующем ответе.
+func (s *captchaService) rejectSharedChallenge(challengeID string, prior, claim streamClaim, first bool) verifyReply {
+	var siteKey, action string
+	if sol, found := s.challenges.get(challengeID); found {
+		s.challenges.delete(challengeID)
+		s.assets.delete(sol.AssetKey)
+		siteKey, action = sol.SiteKey, sol.Action
+		s.rememberOutcome(challengeID, outcome{SiteKey: sol.SiteKey, Action: sol.Action, VerifiedAt: time.Now()})
+	} else if o, err := s.outcomes.get(challengeID); err == nil {
+		siteKey, action = o.SiteKey, o.Action
+		o.Confidence = 0
+		s.rememberOutcome(challengeID, o)
+	}
+	event := &captchapb.ServerEvent{Event: &captchapb.ServerEvent_Result{Result: &captchapb.ServerEvent_ChallengeResult{
+		ChallengeId: challengeID,
+		Action:      action,
+	}}}
+	if first {
+		s.revokeIntrospection(challengeID)
+		sharedChallenges.Inc("rejected")
+		logging.Warnf(logging.Verification, siteKey, "Challenge %s answered from %s (%s) and %s (%s), failing both",
+			challengeID, prior, logging.IP(prior.IP), claim, logging.IP(claim.IP))
+		s.history.flagShared(prior.IP)
+		if claim.IP != prior.IP {
+			s.history.flagShared(claim.IP)
+		}
+		if owner, ok := s.streams.get(prior.Stream); ok && prior.Instance == "" && !prior.sameStream(claim) {
+			owner.send(event)
+		}
+	}
+	return verifyReply{siteKey: siteKey, what: "shared challenge failure", event: event}
+}
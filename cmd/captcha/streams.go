@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+)
+
+// streamLimits задает ограничения на один event-стрим
+type streamLimits struct {
+	// EventsPerSecond и Burst — параметры token bucket для входящих событий
+	EventsPerSecond float64
+	Burst           int
+	// MaxInFlight — сколько проверок решений одновременно может висеть на стриме
+	MaxInFlight int
+	// AbuseThreshold — сколько отброшенных событий за AbuseWindow приводит к разрыву стрима
+	AbuseThreshold int
+	AbuseWindow    time.Duration
+}
+
+// Причины отброса событий (значения метки reason)
+const (
+	dropReasonRateLimit = "rate_limit"
+	dropReasonInFlight  = "in_flight"
+)
+
+// errOrphanedStream — причина закрытия стрима, который janitor признал брошенным
+var errOrphanedStream = errors.New("event stream purged as orphaned")
+
+// eventStream оборачивает серверный стрим: gRPC не разрешает конкурентный Send,
+// поэтому все отправки идут через мьютекс
+type eventStream struct {
+	id     uint64
+	stream captchapb.CaptchaService_MakeEventStreamServer
+	mu     sync.Mutex
+	// cancel завершает обработчик стрима, если janitor признал его брошенным
+	cancel context.CancelCauseFunc
+
+	// lastActive — время последнего принятого или успешно отправленного события (UnixNano)
+	lastActive atomic.Int64
+	sendFailed atomic.Bool
+
+	// interests — задания, по которым стрим ждет результат проверки (с кратностью)
+	interestMu sync.Mutex
+	interests  map[string]int
+
+	limits   streamLimits
+	limiter  *tokenBucket
+	inFlight chan struct{}
+
+	dropMu      sync.Mutex
+	drops       int
+	windowStart time.Time
+}
+
+func (es *eventStream) send(event *captchapb.ServerEvent) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if err := es.stream.Send(event); err != nil {
+		es.sendFailed.Store(true)
+		return err
+	}
+	es.touch()
+	return nil
+}
+
+func (es *eventStream) touch() {
+	es.lastActive.Store(time.Now().UnixNano())
+}
+
+// admit проверяет, укладывается ли очередное событие в лимит частоты
+func (es *eventStream) admit() bool {
+	return es.limiter.allow()
+}
+
+// acquire занимает слот проверки и регистрирует ожидание результата по заданию;
+// false, если стрим исчерпал MaxInFlight
+func (es *eventStream) acquire(challengeID string) bool {
+	select {
+	case es.inFlight <- struct{}{}:
+	default:
+		return false
+	}
+	es.interestMu.Lock()
+	es.interests[challengeID]++
+	es.interestMu.Unlock()
+	return true
+}
+
+// release освобождает слот проверки, занятый acquire
+func (es *eventStream) release(challengeID string) {
+	es.interestMu.Lock()
+	if es.interests[challengeID]--; es.interests[challengeID] <= 0 {
+		delete(es.interests, challengeID)
+	}
+	es.interestMu.Unlock()
+	<-es.inFlight
+}
+
+// pending — сколько результатов проверки стрим еще ждет
+func (es *eventStream) pending() int {
+	es.interestMu.Lock()
+	defer es.interestMu.Unlock()
+	n := 0
+	for _, c := range es.interests {
+		n += c
+	}
+	return n
+}
+
+// orphanReason сообщает, почему стрим считается брошенным, или "", если он жив.
+// Клиент мог пропасть без EOF (обрыв сети, убитая вкладка за NAT): такой стрим
+// висит, пока не сработает keepalive, и держит слоты и ожидания результатов.
+func (es *eventStream) orphanReason(now time.Time, idleTimeout time.Duration) string {
+	switch {
+	case es.stream.Context().Err() != nil:
+		return orphanReasonContextDone
+	case es.sendFailed.Load():
+		return orphanReasonSendFailed
+	case idleTimeout > 0 && now.Sub(time.Unix(0, es.lastActive.Load())) > idleTimeout:
+		return orphanReasonIdle
+	}
+	return ""
+}
+
+// recordDrop учитывает отброшенное событие и сообщает, пора ли рвать стрим
+func (es *eventStream) recordDrop(reason string) (abusive bool) {
+	streamEventsDropped.Inc(reason)
+
+	es.dropMu.Lock()
+	defer es.dropMu.Unlock()
+	now := time.Now()
+	if now.Sub(es.windowStart) > es.limits.AbuseWindow {
+		es.windowStart = now
+		es.drops = 0
+	}
+	es.drops++
+	return es.drops > es.limits.AbuseThreshold
+}
+
+// streamHub хранит все открытые event-стримы, чтобы сервер мог
+// проактивно рассылать управляющие сообщения (drain, refresh и т.п.)
+type streamHub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	streams map[uint64]*eventStream
+	// base — лимиты из локальной конфигурации, limits — с учетом переопределений балансера
+	base   streamLimits
+	limits streamLimits
+}
+
+func newStreamHub(limits streamLimits) *streamHub {
+	return &streamHub{streams: make(map[uint64]*eventStream), base: limits, limits: limits}
+}
+
+func (h *streamHub) baseLimits() streamLimits {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.base
+}
+
+// setLimits меняет лимиты для новых стримов; открытые стримы доживают со старыми
+func (h *streamHub) setLimits(limits streamLimits) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limits = limits
+}
+
+func (h *streamHub) add(stream captchapb.CaptchaService_MakeEventStreamServer, cancel context.CancelCauseFunc) *eventStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	es := &eventStream{
+		id:          h.nextID,
+		stream:      stream,
+		cancel:      cancel,
+		interests:   make(map[string]int),
+		limits:      h.limits,
+		limiter:     newTokenBucket(h.limits.EventsPerSecond, h.limits.Burst),
+		inFlight:    make(chan struct{}, h.limits.MaxInFlight),
+		windowStart: time.Now(),
+	}
+	es.touch()
+	h.streams[es.id] = es
+	activeStreams.Inc()
+	return es
+}
+
+// remove убирает стрим из хаба; false, если его уже убрали
+func (h *streamHub) remove(es *eventStream) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.streams[es.id]; !ok {
+		return false
+	}
+	delete(h.streams, es.id)
+	activeStreams.Dec()
+	return true
+}
+
+// get возвращает открытый стрим по id
+func (h *streamHub) get(id uint64) (*eventStream, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	es, ok := h.streams[id]
+	return es, ok
+}
+
+// connected сообщает, что стрим id еще открыт
+func (h *streamHub) connected(id uint64) bool {
+	_, ok := h.get(id)
+	return ok
+}
+
+func (h *streamHub) snapshot() []*eventStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]*eventStream, 0, len(h.streams))
+	for _, es := range h.streams {
+		list = append(list, es)
+	}
+	return list
+}
+
+// broadcast отправляет управляющее сообщение во все подключенные стримы.
+// Пустой challengeID означает уведомление уровня инстанса.
+func (h *streamHub) broadcast(ctrl *captchapb.ServerEvent_ControlMessage) {
+	event := controlEvent(ctrl)
+	for _, es := range h.snapshot() {
+		if err := es.send(event); err != nil {
+			log.Printf("Failed to send %s control message to stream %d: %v", ctrl.GetKind(), es.id, err)
+		}
+	}
+}
+
+// Причины, по которым janitor закрывает стрим (значения метки reason)
+const (
+	orphanReasonContextDone = "context_done"
+	orphanReasonSendFailed  = "send_failed"
+	orphanReasonIdle        = "idle"
+)
+
+// runJanitor раз в interval ищет брошенные стримы до отмены ctx
+func (h *streamHub) runJanitor(ctx context.Context, interval, idleTimeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.purgeOrphans(idleTimeout)
+		}
+	}
+}
+
+// purgeOrphans закрывает брошенные стримы, убирает их из хаба и сообщает,
+// сколько стримов и ожидаемых результатов было потеряно
+func (h *streamHub) purgeOrphans(idleTimeout time.Duration) (purged, lostResults int) {
+	now := time.Now()
+	for _, es := range h.snapshot() {
+		reason := es.orphanReason(now, idleTimeout)
+		if reason == "" || !h.remove(es) {
+			continue
+		}
+		es.cancel(errOrphanedStream)
+		pending := es.pending()
+		orphanedStreams.Inc(reason)
+		orphanedResults.Add(int64(pending))
+		purged++
+		lostResults += pending
+		log.Printf("Purged orphaned event stream %d (%s), %d pending results dropped", es.id, reason, pending)
+	}
+	if purged > 0 {
+		log.Printf("Stream janitor purged %d orphaned streams, %d pending results lost, %d streams remain", purged, lostResults, activeStreams.Value())
+	}
+	return purged, lostResults
+}
+
+func controlEvent(ctrl *captchapb.ServerEvent_ControlMessage) *captchapb.ServerEvent {
+	return &captchapb.ServerEvent{
+		Event: &captchapb.ServerEvent_Control{Control: ctrl},
+	}
+}
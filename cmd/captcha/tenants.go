@@ -0,0 +1,126 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/settings"
+)
+
+// tenantBundleConfig — перенос конфигурации тенантов между окружениями
+// (staging -> prod) подписанными пакетами
+type tenantBundleConfig struct {
+	// Secret — общий секрет окружений, между которыми переносятся пакеты;
+	// пустой — экспорт и импорт выключены
+	Secret []byte
+	// Environment — имя окружения в экспортированных пакетах
+	Environment string
+}
+
+func (c tenantBundleConfig) enabled() bool { return len(c.Secret) > 0 }
+
+// handleTenantExport — GET /admin/tenants/export: подписанный пакет с
+// политиками и квотами всех тенантов
+func (s *captchaService) handleTenantExport(w http.ResponseWriter, r *http.Request, cfg tenantBundleConfig) {
+	current, _ := s.settings.Current()
+	signed, err := settings.Sign(settings.Bundle{
+		Environment: cfg.Environment,
+		Exported:    time.Now().UTC(),
+		Version:     current.Version,
+		Tenants:     settings.Tenants(current.Settings),
+	}, cfg.Secret)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(signed)
+}
+
+// tenantImportReport — ответ импорта: что происходит с каждым тенантом пакета
+type tenantImportReport struct {
+	DryRun bool `json:"dry_run"`
+	// Environment, Exported и BundleVersion — откуда пакет
+	Environment   string                  `json:"environment,omitempty"`
+	Exported      time.Time               `json:"exported"`
+	BundleVersion int                     `json:"bundle_version"`
+	Tenants       []settings.TenantResult `json:"tenants"`
+	Conflicts     int                     `json:"conflicts"`
+	// Error — почему импорт не выполнен (конфликты, проверка настроек)
+	Error string `json:"error,omitempty"`
+	// Version — записанная версия настроек; 0 — ничего не записано
+	Version int `json:"version,omitempty"`
+}
+
+// handleTenantImport — POST /admin/tenants/import[?dry_run=true&on_conflict=fail|overwrite|skip]:
+// проверяет подпись пакета и накладывает его тенантов на текущие настройки.
+// Импорт записывается одной новой версией настроек: его можно откатить как
+// любое другое изменение. С dry_run — только отчет, без записи.
+func (s *captchaService) handleTenantImport(w http.ResponseWriter, r *http.Request, cfg tenantBundleConfig) {
+	onConflict := r.URL.Query().Get("on_conflict")
+	switch onConflict {
+	case "":
+		onConflict = settings.OnConflictFail
+	case settings.OnConflictFail, settings.OnConflictOverwrite, settings.OnConflictSkip:
+	default:
+		httperr.Write(w, r, http.StatusBadRequest, "on_conflict must be fail, overwrite or skip")
+		return
+	}
+	var signed settings.SignedBundle
+	if err := json.NewDecoder(r.Body).Decode(&signed); err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	bundle, err := signed.Verify(cfg.Secret)
+	if errors.Is(err, settings.ErrSignature) {
+		httperr.Write(w, r, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, "invalid bundle: "+err.Error())
+		return
+	}
+
+	current, _ := s.settings.Current()
+	next, results := settings.Merge(current.Settings, bundle.Tenants, onConflict)
+	report := tenantImportReport{
+		DryRun:        r.URL.Query().Get("dry_run") == "true",
+		Environment:   bundle.Environment,
+		Exported:      bundle.Exported,
+		BundleVersion: bundle.Version,
+		Tenants:       results,
+	}
+	changed := false
+	for _, res := range results {
+		switch res.Result {
+		case "conflict":
+			report.Conflicts++
+		case "added", "updated":
+			changed = true
+		}
+	}
+	code := http.StatusOK
+	switch err := validateSettings(next); {
+	case err != nil:
+		report.Error, code = err.Error(), http.StatusBadRequest
+	case report.Conflicts > 0:
+		report.Error, code = fmt.Sprintf("%d tenants conflict with existing configuration (use on_conflict=overwrite or skip)", report.Conflicts), http.StatusConflict
+	case changed && !report.DryRun:
+		comment := fmt.Sprintf("import of %d tenants from %s bundle version %d", len(results), cmp.Or(bundle.Environment, "unnamed"), bundle.Version)
+		snapshot, err := s.settings.Commit(next, adminAuthor(r), comment)
+		if err != nil {
+			s.settingsResult(w, r, snapshot, err)
+			return
+		}
+		s.publishSettings(snapshot)
+		report.Version = snapshot.Version
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/quota"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// quotaExceeded строит gRPC-ошибку ResourceExhausted с причиной QUOTA_EXCEEDED,
+// чтобы клиенты отличали исчерпанную квоту от перегрузки инстанса
+func quotaExceeded(siteKey string, kind quota.Kind, err error) error {
+	quotaRejections.Inc(siteLabel(siteKey), string(kind))
+	st := status.New(codes.ResourceExhausted, err.Error())
+	detailed, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   "QUOTA_EXCEEDED",
+		Domain:   "captcha.v1",
+		Metadata: map[string]string{"site_key": siteLabel(siteKey), "kind": string(kind)},
+	})
+	if derr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// tenantFull строит ошибку ResourceExhausted с причиной TENANT_PENDING_LIMIT:
+// у сайта слишком много выданных и еще не решенных заданий
+func tenantFull(siteKey string) error {
+	quotaRejections.Inc(siteLabel(siteKey), "pending")
+	st := status.New(codes.ResourceExhausted, errTenantFull.Error())
+	detailed, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   "TENANT_PENDING_LIMIT",
+		Domain:   "captcha.v1",
+		Metadata: map[string]string{"site_key": siteLabel(siteKey)},
+	})
+	if derr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func siteLabel(siteKey string) string {
+	if siteKey == "" {
+		return quota.DefaultSiteKey
+	}
+	return siteKey
+}
+
+// handleUsage — GET /admin/usage[?site_key=...]: потребление тенантов за текущий месяц
+func (s *captchaService) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if siteKey := r.URL.Query().Get("site_key"); siteKey != "" {
+		json.NewEncoder(w).Encode(s.quotas.Usage(siteKey))
+		return
+	}
+	json.NewEncoder(w).Encode(s.quotas.All())
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Источники, по которым считаются лимиты выдачи заданий
+const (
+	velocitySourceIP          = "ip"
+	velocitySourceFingerprint = "fingerprint"
+)
+
+// velocityRule — не больше Max заданий на источник за скользящее окно Window
+type velocityRule struct {
+	Source string
+	Max    int
+	Window time.Duration
+}
+
+// parseVelocityRules разбирает VELOCITY_RULES вида "ip=20/5m,fingerprint=10/5m"
+func parseVelocityRules(spec map[string]string) ([]velocityRule, error) {
+	var rules []velocityRule
+	for source, limit := range spec {
+		if source != velocitySourceIP && source != velocitySourceFingerprint {
+			return nil, fmt.Errorf("unknown velocity source %q, expected ip or fingerprint", source)
+		}
+		count, window, ok := strings.Cut(limit, "/")
+		if !ok {
+			return nil, fmt.Errorf("velocity rule %s=%s: expected <max>/<window>", source, limit)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("velocity rule %s=%s: invalid max %q", source, limit, count)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("velocity rule %s=%s: invalid window %q", source, limit, window)
+		}
+		rules = append(rules, velocityRule{Source: source, Max: n, Window: d})
+	}
+	return rules, nil
+}
+
+// velocityLimiter считает выданные задания по источникам скользящим окном:
+// счетчики текущего и предыдущего интервала, предыдущий берется с весом
+// непрошедшей доли окна. Источники хранятся только хэшами на ключе процесса.
+type velocityLimiter struct {
+	rules []velocityRule
+	salt  []byte
+
+	mu     sync.Mutex
+	counts *typedStore[int64]
+}
+
+// newVelocityLimiter возвращает nil, если правил нет
+func newVelocityLimiter(rules []velocityRule) *velocityLimiter {
+	if len(rules) == 0 {
+		return nil
+	}
+	longest := time.Duration(0)
+	for _, r := range rules {
+		log.Printf("Velocity limit: %d challenges per %s per %s", r.Max, r.Window, r.Source)
+		longest = max(longest, r.Window)
+	}
+	salt := make([]byte, 32)
+	rand.Read(salt)
+	// Счетчик нужен, пока он может быть предыдущим интервалом
+	return &velocityLimiter{rules: rules, salt: salt, counts: newTypedStore[int64]("velocity", 2*longest)}
+}
+
+func (l *velocityLimiter) hash(value string) string {
+	sum := sha256.Sum256(append(l.salt, value...))
+	return hex.EncodeToString(sum[:12])
+}
+
+// velocityExceeded — источник превысил лимит правила
+type velocityExceeded struct {
+	rule       velocityRule
+	retryAfter time.Duration
+}
+
+// velocityWindow — сколько заданий источник получил за окно правила
+type velocityWindow struct {
+	rule velocityRule
+	// count — число заданий в скользящем окне (с весом предыдущего интервала)
+	count      float64
+	retryAfter time.Duration
+	// key — счетчик текущего интервала
+	key string
+}
+
+func (w velocityWindow) exceeded() bool {
+	return w.count >= float64(w.rule.Max)
+}
+
+// windowsLocked считает окна правил для источников запроса; вызывается под mu
+func (l *velocityLimiter) windowsLocked(sources map[string]string, now time.Time) []velocityWindow {
+	var windows []velocityWindow
+	for _, r := range l.rules {
+		value := sources[r.Source]
+		if value == "" {
+			continue
+		}
+		slot := now.UnixNano() / int64(r.Window)
+		elapsed := time.Duration(now.UnixNano() - slot*int64(r.Window))
+		base := r.Source + "|" + r.Window.String() + "|" + l.hash(value) + "|"
+		current := base + strconv.FormatInt(slot, 10)
+		prev, _ := l.counts.get(base + strconv.FormatInt(slot-1, 10))
+		cur, _ := l.counts.get(current)
+		weight := 1 - float64(elapsed)/float64(r.Window)
+		windows = append(windows, velocityWindow{
+			rule:       r,
+			count:      float64(prev)*weight + float64(cur),
+			retryAfter: r.Window - elapsed,
+			key:        current,
+		})
+	}
+	return windows
+}
+
+// take учитывает задание для источников запроса. Задание, превысившее лимит
+// хотя бы одного правила, не учитывается ни в одном.
+func (l *velocityLimiter) take(sources map[string]string, now time.Time) *velocityExceeded {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	windows := l.windowsLocked(sources, now)
+	for _, w := range windows {
+		if w.exceeded() {
+			return &velocityExceeded{rule: w.rule, retryAfter: w.retryAfter}
+		}
+	}
+	for _, w := range windows {
+		increment(l.counts, w.key, 1)
+	}
+	return nil
+}
+
+// peek считает окна правил, не учитывая задание
+func (l *velocityLimiter) peek(sources map[string]string, now time.Time) []velocityWindow {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.windowsLocked(sources, now)
+}
+
+// tooManyChallenges строит ошибку ResourceExhausted с причиной TOO_MANY_CHALLENGES;
+// LocalizedMessage виджет показывает пользователю, RetryInfo — когда повторить
+func tooManyChallenges(siteKey string, e *velocityExceeded) error {
+	velocityRejections.Inc(e.rule.Source)
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("too many challenges from this %s", e.rule.Source))
+	detailed, derr := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   "TOO_MANY_CHALLENGES",
+			Domain:   "captcha.v1",
+			Metadata: map[string]string{"site_key": siteLabel(siteKey), "source": e.rule.Source},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(e.retryAfter.Round(time.Second))},
+		&errdetails.LocalizedMessage{
+			Locale:  "en",
+			Message: "Too many attempts. Please wait a few minutes and try again.",
+		},
+	)
+	if derr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
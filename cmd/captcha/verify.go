@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+)
+
+// verifyTask — одно событие с решением, ожидающее проверки
+type verifyTask struct {
+	es    *eventStream
+	event *captchapb.ClientEvent
+}
+
+// verifyPool — ограниченный пул воркеров для проверки решений.
+// Задачи шардируются по challenge_id: все события одного задания попадают
+// к одному воркеру и обрабатываются строго в порядке поступления, а медленная
+// проверка одного задания не блокирует прием событий стрима.
+type verifyPool struct {
+	shards []chan verifyTask
+	handle func(es *eventStream, event *captchapb.ClientEvent)
+	wg     sync.WaitGroup
+
+	// mu защищает закрытие очередей от гонки с submit
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newVerifyPool(workers, queueSize int, handle func(*eventStream, *captchapb.ClientEvent)) *verifyPool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &verifyPool{
+		shards: make([]chan verifyTask, workers),
+		handle: handle,
+	}
+	for i := range p.shards {
+		p.shards[i] = make(chan verifyTask, queueSize)
+		p.wg.Add(1)
+		go p.worker(p.shards[i])
+	}
+	return p
+}
+
+func (p *verifyPool) worker(tasks <-chan verifyTask) {
+	defer p.wg.Done()
+	for t := range tasks {
+		verifyQueueLength.Dec()
+		start := time.Now()
+		p.handle(t.es, t.event)
+		verifyDuration.Observe(time.Since(start).Seconds())
+		t.es.release(t.event.GetChallengeId())
+	}
+}
+
+// submit ставит задачу в очередь шарда. Если очередь заполнена, вызывающий
+// ждет (это и есть backpressure для стрима) до отмены ctx.
+func (p *verifyPool) submit(ctx context.Context, es *eventStream, event *captchapb.ClientEvent) bool {
+	h := fnv.New32a()
+	h.Write([]byte(event.GetChallengeId()))
+	shard := p.shards[h.Sum32()%uint32(len(p.shards))]
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	verifyQueueLength.Inc()
+	select {
+	case shard <- verifyTask{es: es, event: event}:
+		return true
+	case <-ctx.Done():
+		verifyQueueLength.Dec()
+		return false
+	}
+}
+
+// stop дожидается обработки уже поставленных задач
+func (p *verifyPool) stop() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	for _, shard := range p.shards {
+		close(shard)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"captcha-service/internal/generator"
+)
+
+// warmMaxAge — сколько заранее сгенерированное задание может ждать выдачи.
+// Подписи адресов картинок и параметры ротации со временем устаревают.
+const warmMaxAge = defaultExpiration / 2
+
+// warmKey — вариант генерации: тип задания и число фрагментов для slider-multi
+type warmKey struct {
+	kind   string
+	pieces int
+}
+
+// warmKeys — варианты, которые прогреваются при старте
+var warmKeys = []warmKey{
+	{kind: generator.KindSlider},
+	{kind: generator.KindRotate},
+	{kind: generator.KindMulti, pieces: 2},
+	{kind: generator.KindMulti, pieces: 3},
+}
+
+type warmChallenge struct {
+	challenge *generator.Challenge
+	at        time.Time
+}
+
+// warmPool — задания, сгенерированные при старте до регистрации в балансере:
+// первые запросы свежего инстанса получают готовые картинки вместо холодной отрисовки
+type warmPool struct {
+	mu    sync.Mutex
+	items map[warmKey][]warmChallenge
+}
+
+func newWarmPool() *warmPool {
+	return &warmPool{items: make(map[warmKey][]warmChallenge)}
+}
+
+func (p *warmPool) put(key warmKey, c *generator.Challenge) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.items[key] = append(p.items[key], warmChallenge{challenge: c, at: time.Now()})
+}
+
+// take забирает готовое задание; устаревшие задания отбрасываются
+func (p *warmPool) take(kind string, pieces int) (*generator.Challenge, bool) {
+	key := warmKey{kind: kind}
+	if kind == generator.KindMulti {
+		key.pieces = pieces
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for list := p.items[key]; len(list) > 0; list = p.items[key] {
+		item := list[0]
+		p.items[key] = list[1:]
+		if time.Since(item.at) <= warmMaxAge {
+			return item.challenge, true
+		}
+	}
+	return nil, false
+}
+
+// drop освобождает все готовые задания и возвращает, сколько их было
+func (p *warmPool) drop() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, list := range p.items {
+		n += len(list)
+	}
+	clear(p.items)
+	return n
+}
+
+// warmup генерирует perKind заданий каждого варианта в workers потоков и
+// отражает прогресс в health. Возвращает false, если прогрев прерван отменой ctx.
+func (s *captchaService) warmup(ctx context.Context, perKind, workers int) bool {
+	if perKind <= 0 {
+		return true
+	}
+	// Прогреваются только типы развертывания: шаблоны и картинки остальных не
+	// загружаются вовсе
+	var keys []warmKey
+	for _, key := range warmKeys {
+		if s.generator.Supports(key.kind) {
+			keys = append(keys, key)
+		}
+	}
+	total := perKind * len(keys)
+	s.health.startWarmup(total)
+	defer s.health.finishWarmup()
+	start := time.Now()
+	log.Printf("Warming up: pre-generating %d challenges of each type before registering", perKind)
+
+	jobs := make(chan warmKey)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				c, err := s.generateKind(context.Background(), challengeSpec{kind: key.kind, pieces: key.pieces})
+				if err != nil {
+					log.Printf("Warmup generation of %s failed: %v", key.kind, err)
+				} else {
+					s.warm.put(key, c)
+				}
+				s.health.warmupProgress()
+			}
+		}()
+	}
+
+	completed := true
+	for i := 0; i < total && completed; i++ {
+		select {
+		case jobs <- keys[i%len(keys)]:
+		case <-ctx.Done():
+			completed = false
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if !completed {
+		log.Printf("Warmup interrupted after %s", time.Since(start).Round(time.Millisecond))
+		return false
+	}
+	log.Printf("Warmup of %d challenges finished in %s", total, time.Since(start).Round(time.Millisecond))
+	return true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strconv"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/generator"
+)
+
+// widgetFor выбирает схему ответа и обфускацию задания по возможностям виджета
+// из запроса; виджет без возможностей получает то же, что и раньше
+func widgetFor(caps *captchapb.WidgetCapabilities) generator.Widget {
+	if caps == nil {
+		return generator.Widget{}
+	}
+	return generator.NegotiateWidget(caps.GetTouch(), caps.GetCrypto(), caps.GetAnswerSchemas())
+}
+
+// hello отвечает на HELLO стрима: что сервер выбрал для виджета с такими возможностями
+func (s *captchaService) hello(es *eventStream, event *captchapb.ClientEvent) {
+	caps := event.GetCapabilities()
+	w := generator.NegotiateWidget(caps.GetTouch(), caps.GetCrypto(), caps.GetAnswerSchemas())
+	widgetHellos.Inc(strconv.FormatUint(uint64(caps.GetWidgetVersion()), 10), strconv.FormatUint(uint64(w.AnswerSchema), 10))
+	es.send(negotiatedEvent(w))
+}
+
+// negotiatedEvent — ответ на HELLO
+func negotiatedEvent(w generator.Widget) *captchapb.ServerEvent {
+	return &captchapb.ServerEvent{Event: &captchapb.ServerEvent_Negotiated_{Negotiated: &captchapb.ServerEvent_Negotiated{
+		AnswerSchema: w.AnswerSchema,
+		Obfuscation:  w.Obfuscation,
+	}}}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/answer"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// liveTimeout — сколько ждать ответа инстанса на одно событие
+const liveTimeout = 5 * time.Second
+
+// live отправляет враждебные ответы на настоящие задания через event-стрим.
+// Инстанс не должен разрывать стрим, а ответ, который не разбирается ни одним
+// разборщиком, не должен получить ненулевую уверенность.
+func (f *fuzzer) live(addr string, iterations int) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(8<<20)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := captchapb.NewCaptchaServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		return err
+	}
+	events := make(chan *captchapb.ServerEvent)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	complexities := []int32{10, 80, 95}
+	// Проверки идут в пуле воркеров, поэтому результат может прийти после
+	// следующих событий: ответ ищем по challenge_id
+	sent := make(map[string][]byte)
+	for i := range iterations {
+		challenge, err := client.NewChallenge(ctx, &captchapb.ChallengeRequest{Complexity: complexities[i%len(complexities)]})
+		if err != nil {
+			return fmt.Errorf("NewChallenge: %w", err)
+		}
+		data := f.payload()
+		sent[challenge.GetChallengeId()] = data
+		if err := stream.Send(&captchapb.ClientEvent{ChallengeId: challenge.GetChallengeId(), Data: data}); err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		// На неразбираемый ответ инстанс молчит, поэтому следом шлем проверочное событие
+		// с несуществующим заданием: REFRESH на него означает, что стрим жив
+		probe := fmt.Sprintf("fuzz-probe-%d", i)
+		if err := stream.Send(&captchapb.ClientEvent{ChallengeId: probe}); err != nil {
+			return fmt.Errorf("send probe: %w", err)
+		}
+		if err := f.awaitProbe(events, recvErr, probe, sent); err != nil {
+			return err
+		}
+	}
+	stream.CloseSend()
+	return nil
+}
+
+// awaitProbe читает события до REFRESH на проверочное задание, проверяя результаты по пути
+func (f *fuzzer) awaitProbe(events <-chan *captchapb.ServerEvent, recvErr <-chan error, probe string, sent map[string][]byte) error {
+	timeout := time.After(liveTimeout)
+	for {
+		select {
+		case ev := <-events:
+			if res := ev.GetResult(); res != nil {
+				if data := sent[res.GetChallengeId()]; res.GetConfidencePercent() > 0 && !parsable(data) {
+					f.failf("unparsable answer %.64q scored %d%%", data, res.GetConfidencePercent())
+				}
+			}
+			if ctrl := ev.GetControl(); ctrl != nil && ctrl.GetChallengeId() == probe {
+				return nil
+			}
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return errors.New("instance closed the event stream")
+			}
+			if status.Code(err) == codes.ResourceExhausted {
+				return fmt.Errorf("stream rate limited, lower -iterations or raise STREAM_EVENTS_PER_SECOND: %w", err)
+			}
+			return fmt.Errorf("event stream failed before %s: %w", probe, err)
+		case <-timeout:
+			return fmt.Errorf("no reply to %s within %s", probe, liveTimeout)
+		}
+	}
+}
+
+func parsable(data []byte) bool {
+	if _, err := answer.ParseSlider(data); err == nil {
+		return true
+	}
+	if _, _, err := answer.ParseRotate(data); err == nil {
+		return true
+	}
+	_, err := answer.ParseMulti(data)
+	return err == nil
+}
@@ -0,0 +1,176 @@
+// Команда fuzz проверяет конвейер проверки решений на враждебных входных данных:
+// разборщики ответов (internal/answer) не должны паниковать и принимать мусор,
+// а математика допусков — нарушать свои инварианты. С флагом -addr те же данные
+// отправляются в event-стрим живого инстанса или балансера.
+//
+//	go run ./cmd/fuzz -iterations 100000
+//	go run ./cmd/fuzz -addr localhost:50051 -iterations 200
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"captcha-service/internal/answer"
+)
+
+func main() {
+	iterations := flag.Int("iterations", 10000, "number of generated inputs")
+	seed := flag.Int64("seed", 0, "random seed; 0 picks one")
+	addr := flag.String("addr", "", "captcha instance or balancer to fuzz over gRPC; empty runs in-process checks only")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = rand.Int63()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	fmt.Printf("fuzz seed %d\n", *seed)
+
+	f := &fuzzer{rng: rng}
+	for range *iterations {
+		f.parsers(f.payload())
+		f.tolerance()
+	}
+	if *addr != "" {
+		if err := f.live(*addr, *iterations); err != nil {
+			f.failf("live: %v", err)
+		}
+	}
+
+	fmt.Printf("%d inputs, %d failures\n", *iterations, f.failures)
+	if f.failures > 0 {
+		os.Exit(1)
+	}
+}
+
+type fuzzer struct {
+	rng      *rand.Rand
+	failures int
+}
+
+func (f *fuzzer) failf(format string, args ...any) {
+	f.failures++
+	if f.failures <= 20 {
+		fmt.Printf("FAIL: "+format+"\n", args...)
+	}
+}
+
+// payload генерирует враждебный ответ: мусорный JSON, огромные блобы, невалидный UTF-8,
+// отрицательные и особые числа, а также мутации корректных ответов
+func (f *fuzzer) payload() []byte {
+	r := f.rng
+	switch r.Intn(10) {
+	case 0:
+		return []byte(`{"x":` + strconv.Itoa(r.Intn(2000)) + `,"y":[` + strings.Repeat("{", r.Intn(64)))
+	case 1:
+		blob := make([]byte, 1<<(10+r.Intn(12)))
+		r.Read(blob)
+		return blob
+	case 2:
+		return []byte{0xff, 0xfe, byte('0' + r.Intn(10)), 0xc3, 0x28}
+	case 3:
+		return []byte("-" + strconv.FormatFloat(r.Float64()*2000, 'f', -1, 64))
+	case 4:
+		specials := []string{"NaN", "Inf", "-Inf", "+Inf", "1e309", "-0", "0x1p-2", "1_000", " 12 ", "", "1e-400", "00000000000000000000000000000000001"}
+		return []byte(specials[r.Intn(len(specials))])
+	case 5:
+		return []byte(strings.Repeat(";", r.Intn(1<<16)))
+	case 6:
+		var b strings.Builder
+		for i := range 1 + r.Intn(12) {
+			if i > 0 {
+				b.WriteByte(';')
+			}
+			fmt.Fprintf(&b, "%d:%g", r.Intn(10)-2, r.NormFloat64()*500)
+		}
+		return []byte(b.String())
+	case 7:
+		return []byte(fmt.Sprintf("%g,%g", r.NormFloat64()*1000, r.NormFloat64()*400))
+	case 8:
+		valid := []byte(strconv.Itoa(r.Intn(2000)))
+		valid[r.Intn(len(valid))] = byte(r.Intn(256))
+		return valid
+	default:
+		return []byte(strings.Repeat("9", r.Intn(400)))
+	}
+}
+
+// parsers прогоняет ответ через все разборщики: без паник, а принятые значения
+// должны быть конечными и лежать в допустимых диапазонах
+func (f *fuzzer) parsers(data []byte) {
+	defer func() {
+		if p := recover(); p != nil {
+			f.failf("parser panicked on %.64q: %v", data, p)
+		}
+	}()
+	if x, err := answer.ParseSlider(data); err == nil && !validX(x) {
+		f.failf("ParseSlider accepted %.64q as %v", data, x)
+	}
+	if x, angle, err := answer.ParseRotate(data); err == nil && (!validX(x) || angle < 0 || angle >= 360 || math.IsNaN(angle)) {
+		f.failf("ParseRotate accepted %.64q as %v,%v", data, x, angle)
+	}
+	if positions, err := answer.ParseMulti(data); err == nil {
+		for id, x := range positions {
+			if id == "" || !validX(x) {
+				f.failf("ParseMulti accepted %.64q with piece %q at %v", data, id, x)
+			}
+		}
+	}
+}
+
+func validX(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0) && x >= 0
+}
+
+// tolerance проверяет свойства математики допусков на случайных значениях
+func (f *fuzzer) tolerance() {
+	r := f.rng
+	steps := []float64{0.25, 0.5, 1, 2}
+	step := steps[r.Intn(len(steps))]
+	complexity := r.Intn(101)
+	tol := answer.Tolerance(complexity)
+	want := float64(r.Intn(2000))
+
+	// Допуск не растет со сложностью и не бывает меньше пикселя
+	if tol < 1 || tol < answer.Tolerance(complexity+1) {
+		f.failf("Tolerance(%d) = %v is not monotonic", complexity, tol)
+	}
+	if at := answer.AngleTolerance(complexity); at < 4 || at < answer.AngleTolerance(complexity+1) {
+		f.failf("AngleTolerance(%d) = %v is not monotonic", complexity, at)
+	}
+	// Точный ответ принимается всегда
+	if ok, _ := answer.Within(want, want, tol, step); !ok {
+		f.failf("exact answer %v rejected (tolerance %v, step %v)", want, tol, step)
+	}
+	// Привязка к сетке идемпотентна
+	if s := answer.Snap(want+r.Float64()*10, step); answer.Snap(s, step) != s {
+		f.failf("Snap is not idempotent at %v (step %v)", s, step)
+	}
+	// Чем ближе ответ к правильному с той же стороны, тем он не хуже
+	near, far := r.Float64()*20, r.Float64()*20
+	if near > far {
+		near, far = far, near
+	}
+	for _, sign := range []float64{-1, 1} {
+		okFar, _ := answer.Within(want+sign*far, want, tol, step)
+		okNear, _ := answer.Within(want+sign*near, want, tol, step)
+		if okFar && !okNear {
+			f.failf("Within accepts %v but rejects closer %v (want %v, tolerance %v, step %v)",
+				want+sign*far, want+sign*near, want, tol, step)
+		}
+	}
+	// Ответ далеко за пределами допуска не принимается
+	if ok, _ := answer.Within(want+tol+step+1, want, tol, step); ok {
+		f.failf("answer %v beyond tolerance %v accepted (want %v, step %v)", want+tol+step+1, tol, want, step)
+	}
+	// Разница углов симметрична и лежит в [0, 180]
+	a, b := r.Float64()*720-360, r.Float64()*720-360
+	if d := answer.AngleDelta(a, b); d < 0 || d > 180 || d != answer.AngleDelta(b, a) {
+		f.failf("AngleDelta(%v, %v) = %v", a, b, d)
+	}
+}
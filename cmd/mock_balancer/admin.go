@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+
+	pb "captcha-service/api/balancer/v1"
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/httperr"
+	"captcha-service/internal/httpsec"
+	"captcha-service/internal/metrics"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// startAdminServer поднимает HTTP control plane флота:
+// GET /config отдает текущую конфигурацию, PUT /config публикует новую,
+// POST /rotate начинает внеочередную ротацию,
+// GET /scaling отдает сигнал для внешнего автоскейлера (желаемое число инстансов по типам),
+// GET /alarms — READY-инстансы против минимумов по типам, GET /metrics — метрики балансера,
+// GET /quarantine — инстансы, пытавшиеся зарегистрироваться без аутентификации,
+// GET /compatibility — матрица совместимости версий протокола и версии инстансов,
+// PUT /compatibility заменяет матрицу,
+// /apis/external.metrics.k8s.io/v1beta1/... — те же данные для HPA
+func startAdminServer(addr string, registry *balancer.Registry, control *balancer.ControlPlane, rotation *balancer.RotationScheduler, scaling balancer.ScalingPolicy, alarm *balancer.CapacityAlarm, auth *balancer.RegistrationAuth, compat *balancer.Compatibility) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /alarms", func(w http.ResponseWriter, r *http.Request) {
+		status := []balancer.CapacityStatus{}
+		if alarm != nil {
+			status = alarm.Status()
+		}
+		writeJSON(w, status)
+	})
+	mux.HandleFunc("GET /compatibility", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"protocol_version": pb.ProtocolVersion,
+			"matrix":           compat.Matrix(),
+			"instances":        registry.Versions(),
+		})
+	})
+	mux.HandleFunc("PUT /compatibility", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			httperr.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		m, err := balancer.ParseCompatibility(body)
+		if err == nil {
+			err = compat.Set(m)
+		}
+		if err != nil {
+			httperr.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Compatibility matrix updated: %v, default %s", m.Versions, m.Default)
+		writeJSON(w, m)
+	})
+	mux.HandleFunc("GET /quarantine", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, append([]balancer.QuarantinedInstance{}, auth.Quarantined()...))
+	})
+	mux.HandleFunc("GET /scaling", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"policy": scaling,
+			"types":  registry.Scaling(scaling),
+		})
+	})
+	registerExternalMetrics(mux, registry, scaling)
+	mux.HandleFunc("/rotate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeConfig(w, r, rotation.Rotate())
+	})
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeConfig(w, r, control.Current())
+		case http.MethodPut, http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				httperr.Write(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			var cfg pb.InstanceConfig
+			if err := protojson.Unmarshal(body, &cfg); err != nil {
+				httperr.Write(w, r, http.StatusBadRequest, "invalid config: "+err.Error())
+				return
+			}
+			applied := control.Set(&cfg)
+			log.Printf("Fleet config updated to v%d", applied.GetVersion())
+			writeConfig(w, r, applied)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			httperr.Write(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+
+	log.Printf("Balancer admin server listening at %s", addr)
+	go func() {
+		// Админке CORS не нужен, а встраивать ее во фрейм нельзя никому
+		if err := http.ListenAndServe(addr, httperr.RequestID(httpsec.Policy{}.Secure(mux))); err != nil {
+			log.Printf("Balancer admin server stopped: %v", err)
+		}
+	}()
+}
+
+func writeConfig(w http.ResponseWriter, r *http.Request, cfg *pb.InstanceConfig) {
+	data, err := protojson.Marshal(cfg)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/metrics"
+)
+
+var (
+	readyInstances = metrics.NewGaugeVec(
+		"balancer_ready_instances",
+		"READY captcha instances by challenge type, for types with a configured minimum.",
+		"type")
+	minReadyInstances = metrics.NewGaugeVec(
+		"balancer_min_ready_instances",
+		"Configured minimum of READY captcha instances by challenge type.",
+		"type")
+	capacityAtRisk = metrics.NewGaugeVec(
+		"balancer_capacity_at_risk",
+		"1 when a challenge type has fewer READY instances than its configured minimum.",
+		"type")
+)
+
+// minInstances читает MIN_READY_INSTANCES вида "slider-puzzle=2,default=1":
+// минимум READY-инстансов по типам заданий
+func minInstances() map[string]int {
+	v := os.Getenv("MIN_READY_INSTANCES")
+	if v == "" {
+		return nil
+	}
+	result := make(map[string]int)
+	for _, pair := range strings.Split(v, ",") {
+		kind, count, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(count)
+		if !ok || kind == "" || err != nil || n < 0 {
+			log.Printf("Invalid entry %q in MIN_READY_INSTANCES, skipping", pair)
+			continue
+		}
+		result[kind] = n
+	}
+	return result
+}
+
+// durationEnv читает длительность из key ("30s", "1m")
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Invalid value %q for %s, using default %s", v, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// capacityAlarm создает проверку минимумов флота, если задан MIN_READY_INSTANCES.
+// ALERT_WEBHOOKS — URL вебхуков через запятую, ALERT_WEBHOOK_TIMEOUT — таймаут
+// одного запроса, ALERT_STARTUP_GRACE — сколько после старта ждать регистрации инстансов.
+func capacityAlarm(registry *balancer.Registry) *balancer.CapacityAlarm {
+	min := minInstances()
+	if len(min) == 0 {
+		return nil
+	}
+	var webhooks []string
+	for _, url := range strings.Split(os.Getenv("ALERT_WEBHOOKS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			webhooks = append(webhooks, url)
+		}
+	}
+	alarm := balancer.NewCapacityAlarm(registry, min, webhooks,
+		durationEnv("ALERT_WEBHOOK_TIMEOUT", 5*time.Second), durationEnv("ALERT_STARTUP_GRACE", time.Minute))
+	alarm.OnCheck = func(list []balancer.CapacityStatus) {
+		for _, st := range list {
+			readyInstances.Set(int64(st.Ready), st.ChallengeType)
+			minReadyInstances.Set(int64(st.Min), st.ChallengeType)
+			atRisk := int64(0)
+			if st.AtRisk {
+				atRisk = 1
+			}
+			capacityAtRisk.Set(atRisk, st.ChallengeType)
+		}
+	}
+	log.Printf("Minimum ready instances: %v, alert webhooks: %d", min, len(webhooks))
+	return alarm
+}
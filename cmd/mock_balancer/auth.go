@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"captcha-service/internal/balancer"
+)
+
+// registrationAuth включает аутентификацию инстансов. REGISTRATION_TOKENS —
+// токены регистрации через запятую (несколько — на время смены токена);
+// REGISTRATION_CLIENT_CA — PEM с CA клиентских сертификатов инстансов для mTLS
+// (нужен TLS публичного порта, ACME_HOSTS); REGISTRATION_QUARANTINE=true держит
+// неаутентифицированные инстансы в карантине вместо отказа.
+func registrationAuth(tlsConfig *tls.Config) *balancer.RegistrationAuth {
+	var tokens []string
+	for _, t := range strings.Split(os.Getenv("REGISTRATION_TOKENS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	mtls := false
+	if path := os.Getenv("REGISTRATION_CLIENT_CA"); path != "" {
+		if tlsConfig == nil {
+			log.Fatalf("REGISTRATION_CLIENT_CA requires TLS on the balancer port (ACME_HOSTS)")
+		}
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read REGISTRATION_CLIENT_CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("No certificates found in REGISTRATION_CLIENT_CA %s", path)
+		}
+		// Клиенты виджетов ходят на тот же порт без сертификата: он нужен только инстансам
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		mtls = true
+	}
+	quarantine, _ := strconv.ParseBool(os.Getenv("REGISTRATION_QUARANTINE"))
+	auth := balancer.NewRegistrationAuth(tokens, mtls, quarantine)
+	if auth == nil {
+		log.Println("Instance registration is open: set REGISTRATION_TOKENS or REGISTRATION_CLIENT_CA to require authentication")
+		return nil
+	}
+	log.Printf("Instance registration requires authentication (tokens: %d, mTLS: %t, quarantine: %t)", len(tokens), mtls, quarantine)
+	return auth
+}
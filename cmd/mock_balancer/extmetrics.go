@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/httperr"
+)
+
+// Kubernetes external metrics API (external.metrics.k8s.io/v1beta1): балансер видит
+// нагрузку всего флота, поэтому HPA может брать метрики прямо у него. Админ-сервер
+// регистрируется в кластере как APIService для этой группы.
+const (
+	externalMetricsGroup   = "external.metrics.k8s.io"
+	externalMetricsVersion = "v1beta1"
+	externalMetricsPrefix  = "/apis/" + externalMetricsGroup + "/" + externalMetricsVersion
+
+	// Имена метрик для HPA (spec.metrics[].external.metric.name)
+	metricPendingPerInstance = "captcha-pending-challenges-per-instance"
+	metricPendingTotal       = "captcha-pending-challenges"
+	metricDesiredInstances   = "captcha-desired-instances"
+
+	// challengeTypeLabel — метка, по которой HPA выбирает тип заданий (metric.selector)
+	challengeTypeLabel = "challenge_type"
+)
+
+var externalMetricNames = []string{metricPendingPerInstance, metricPendingTotal, metricDesiredInstances}
+
+type externalMetricValue struct {
+	MetricName   string            `json:"metricName"`
+	MetricLabels map[string]string `json:"metricLabels"`
+	Timestamp    string            `json:"timestamp"`
+	Value        string            `json:"value"`
+}
+
+// registerExternalMetrics добавляет в mux discovery и чтение значений external metrics
+func registerExternalMetrics(mux *http.ServeMux, registry *balancer.Registry, scaling balancer.ScalingPolicy) {
+	mux.HandleFunc("GET "+externalMetricsPrefix, func(w http.ResponseWriter, r *http.Request) {
+		resources := make([]map[string]any, 0, len(externalMetricNames))
+		for _, name := range externalMetricNames {
+			resources = append(resources, map[string]any{
+				"name":       name,
+				"namespaced": true,
+				"kind":       "ExternalMetricValueList",
+				"verbs":      []string{"get"},
+			})
+		}
+		writeJSON(w, map[string]any{
+			"kind":         "APIResourceList",
+			"apiVersion":   "v1",
+			"groupVersion": externalMetricsGroup + "/" + externalMetricsVersion,
+			"resources":    resources,
+		})
+	})
+
+	// Пространство имен не используется: флот один на балансер
+	mux.HandleFunc("GET "+externalMetricsPrefix+"/namespaces/{namespace}/{metric}", func(w http.ResponseWriter, r *http.Request) {
+		selector, err := parseLabelSelector(r.URL.Query().Get("labelSelector"))
+		if err != nil {
+			httperr.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		metric := r.PathValue("metric")
+		values := make(map[string]string)
+		switch metric {
+		case metricPendingPerInstance:
+			for typ, l := range registry.Pending() {
+				values[typ] = formatQuantity(l.PerInstance())
+			}
+		case metricPendingTotal:
+			for typ, l := range registry.Pending() {
+				values[typ] = strconv.Itoa(l.Pending)
+			}
+		case metricDesiredInstances:
+			for typ, ts := range registry.Scaling(scaling) {
+				values[typ] = strconv.Itoa(ts.Desired)
+			}
+		default:
+			httperr.Write(w, r, http.StatusNotFound, "unknown external metric "+metric)
+			return
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		items := []externalMetricValue{}
+		for typ, v := range values {
+			if want, ok := selector[challengeTypeLabel]; ok && want != typ {
+				continue
+			}
+			items = append(items, externalMetricValue{
+				MetricName:   metric,
+				MetricLabels: map[string]string{challengeTypeLabel: typ},
+				Timestamp:    now,
+				Value:        v,
+			})
+		}
+		writeJSON(w, map[string]any{
+			"kind":       "ExternalMetricValueList",
+			"apiVersion": externalMetricsGroup + "/" + externalMetricsVersion,
+			"metadata":   map[string]any{},
+			"items":      items,
+		})
+	})
+}
+
+// parseLabelSelector разбирает селекторы равенства ("a=b,c==d"); HPA передает
+// metric.selector.matchLabels именно в таком виде
+func parseLabelSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	if s == "" {
+		return selector, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok || strings.HasSuffix(k, "!") {
+			return nil, fmt.Errorf("unsupported label selector %q: only equality is supported", part)
+		}
+		selector[strings.TrimSpace(k)] = strings.TrimSpace(strings.TrimPrefix(v, "="))
+	}
+	return selector, nil
+}
+
+// formatQuantity записывает дробное значение как resource.Quantity в милли-единицах
+func formatQuantity(v float64) string {
+	return strconv.FormatInt(int64(v*1000+0.5), 10) + "m"
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -1,46 +1,187 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"log"
 	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	pb "captcha-service/api/balancer/v1" // Путь к сгенерированному коду
+	"captcha-service/internal/acme"
+	"captcha-service/internal/balancer"
+	"captcha-service/internal/iplist"
+	"captcha-service/internal/metrics"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 )
 
-const mockBalancerPort = 50051
+const (
+	mockBalancerPort = 50051
+	// defaultRouteTTL совпадает со сроком жизни задания на инстансе
+	defaultRouteTTL = 5 * time.Minute
+	// defaultAdminAddr — HTTP-адрес control plane (GET/PUT /config)
+	defaultAdminAddr = ":50052"
+	// defaultRotationInterval — период ротации обфускации и шаблонов по флоту
+	defaultRotationInterval = time.Hour
+	// defaultMaxRecvBytes — предел входящего сообщения клиента: запросы
+	// заданий и ответы виджета на порядки меньше, а больше gRPC не буферизует
+	defaultMaxRecvBytes = 256 << 10
+)
+
+var crossRegionChallenges = metrics.NewCounterVec(
+	"balancer_cross_region_challenges_total",
+	"Challenges routed outside the balancer region for lack of READY local instances, by instance region.",
+	"region")
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// routeTTL читает ROUTE_TTL (например, "5m"); маршрут должен жить не меньше задания
+func routeTTL() time.Duration {
+	if v := os.Getenv("ROUTE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid value %q for ROUTE_TTL, using default %s", v, defaultRouteTTL)
+	}
+	return defaultRouteTTL
+}
+
+// maxRecvBytes читает GRPC_MAX_RECV_BYTES — предел входящего сообщения
+func maxRecvBytes() int {
+	if v := os.Getenv("GRPC_MAX_RECV_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("Invalid value %q for GRPC_MAX_RECV_BYTES, using default %d", v, defaultMaxRecvBytes)
+	}
+	return defaultMaxRecvBytes
+}
 
-// balancerService - наша реализация-заглушка для сервера балансера
-type balancerService struct {
-	pb.UnimplementedBalancerServiceServer
+// rotationInterval читает ROTATION_INTERVAL ("1h", "30m"); "0" отключает ротацию по расписанию
+func rotationInterval() time.Duration {
+	v := os.Getenv("ROTATION_INTERVAL")
+	if v == "" {
+		return defaultRotationInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid value %q for ROTATION_INTERVAL, using default %s", v, defaultRotationInterval)
+		return defaultRotationInterval
+	}
+	return d
 }
 
-// RegisterInstance - реализует стриминговый RPC для регистрации инстансов
-func (s *balancerService) RegisterInstance(stream pb.BalancerService_RegisterInstanceServer) error {
-	log.Println("New captcha instance trying to register...")
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			log.Println("Captcha instance disconnected.")
-			return nil
+// rotationVariants читает ROTATION_TEMPLATE_VARIANTS вида "slider-puzzle=v1|v2,default=v1|v3":
+// версии шаблонов, между которыми чередуются ротации
+func rotationVariants() map[string][]string {
+	v := os.Getenv("ROTATION_TEMPLATE_VARIANTS")
+	if v == "" {
+		return nil
+	}
+	variants := make(map[string][]string)
+	for _, pair := range strings.Split(v, ",") {
+		kind, versions, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || kind == "" || versions == "" {
+			log.Printf("Invalid entry %q in ROTATION_TEMPLATE_VARIANTS, skipping", pair)
+			continue
 		}
-		if err != nil {
-			log.Printf("Error receiving from stream: %v", err)
-			return err
+		variants[kind] = strings.Split(versions, "|")
+	}
+	return variants
+}
+
+// scalingPolicy читает SCALING_TARGET_CONCURRENCY (одновременных генераций на инстанс),
+// SCALING_MIN_INSTANCES и SCALING_MAX_INSTANCES (0 — без предела)
+func scalingPolicy() balancer.ScalingPolicy {
+	p := balancer.ScalingPolicy{TargetConcurrency: 2, MinInstances: 1}
+	if v, err := strconv.ParseFloat(os.Getenv("SCALING_TARGET_CONCURRENCY"), 64); err == nil && v > 0 {
+		p.TargetConcurrency = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SCALING_MIN_INSTANCES")); err == nil && v >= 0 {
+		p.MinInstances = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SCALING_MAX_INSTANCES")); err == nil && v >= 0 {
+		p.MaxInstances = v
+	}
+	return p
+}
+
+// acmeTLSConfig включает TLS с сертификатами ACME для публичного gRPC-порта,
+// если задан ACME_HOSTS (через запятую). Инстансам тогда нужен BALANCER_TLS=true.
+func acmeTLSConfig() *tls.Config {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("ACME_HOSTS"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
 		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	cache, err := acme.NewCache(envOr("ACME_CACHE", "dir:acme-cache"))
+	if err != nil {
+		log.Fatalf("Failed to set up ACME: %v", err)
+	}
+	manager, err := acme.NewManager(acme.Config{
+		Hosts:        hosts,
+		Email:        os.Getenv("ACME_EMAIL"),
+		Cache:        cache,
+		DirectoryURL: os.Getenv("ACME_DIRECTORY_URL"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up ACME: %v", err)
+	}
+	if addr := os.Getenv("ACME_HTTP_ADDR"); addr != "" {
+		go func() {
+			if err := acme.ServeHTTPChallenge(manager, addr); err != nil {
+				log.Printf("ACME challenge server stopped: %v", err)
+			}
+		}()
+	}
+	log.Printf("ACME certificates enabled for %s", strings.Join(hosts, ", "))
+	return manager.TLSConfig()
+}
 
-		// Просто логируем все, что получаем от сервиса капчи
-		log.Printf(
-			"Received event from captcha instance: ID=%s, Type=%s, Host=%s, Port=%d",
-			req.InstanceId,
-			req.EventType,
-			req.Host,
-			req.PortNumber,
-		)
+// compatibility читает COMPATIBILITY_MATRIX — JSON вида
+// {"versions": {"1": "compatible", "0": "deprecated"}, "default": "incompatible"};
+// по умолчанию совместима версия балансера, остальные помечаются как deprecated
+func compatibility() *balancer.Compatibility {
+	v := os.Getenv("COMPATIBILITY_MATRIX")
+	if v == "" {
+		return balancer.NewCompatibility(balancer.DefaultCompatibility())
 	}
+	m, err := balancer.ParseCompatibility([]byte(v))
+	if err != nil {
+		log.Fatalf("Invalid COMPATIBILITY_MATRIX: %v", err)
+	}
+	return balancer.NewCompatibility(m)
+}
+
+// trustedProxies читает TRUSTED_PROXIES — CIDR прокси перед балансером, которым
+// разрешено передавать адрес и страну клиента; у остальных клиентов балансер
+// подставляет адрес соединения
+func trustedProxies() iplist.Proxies {
+	v := os.Getenv("TRUSTED_PROXIES")
+	if v == "" {
+		return nil
+	}
+	proxies, err := iplist.ParseProxies(strings.Split(v, ","))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	return proxies
 }
 
 func main() {
@@ -49,8 +190,42 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterBalancerServiceServer(s, &balancerService{})
+	// Метка service — как у инстансов (METRICS_SERVICE_NAME), чтобы дашборды
+	// отличали балансер от других сервисов
+	metrics.SetConstLabels(map[string]string{"service": cmp.Or(os.Getenv("METRICS_SERVICE_NAME"), "captcha-balancer")})
+	registry := balancer.NewRegistry(routeTTL())
+	registry.SetClockSkewTolerance(durationEnv("CLOCK_SKEW_TOLERANCE", 30*time.Second))
+	// REGION и ZONE — размещение балансера: задания выдают инстансы его
+	// региона, а в другой регион они уходят, только если своих READY нет
+	registry.SetLocality(os.Getenv("REGION"), os.Getenv("ZONE"))
+	registry.OnCrossRegion = func(region string) { crossRegionChallenges.Inc(cmp.Or(region, "unknown")) }
+	control := balancer.NewControlPlane()
+	rotation := balancer.NewRotationScheduler(control, rotationInterval(), rotationVariants())
+	go rotation.Run(context.Background())
+	alarm := capacityAlarm(registry)
+	if alarm != nil {
+		go alarm.Run(context.Background(), durationEnv("ALERT_CHECK_INTERVAL", 15*time.Second))
+	}
+	tlsConfig := acmeTLSConfig()
+	auth := registrationAuth(tlsConfig)
+	compat := compatibility()
+	startAdminServer(envOr("BALANCER_ADMIN_ADDR", defaultAdminAddr), registry, control, rotation, scalingPolicy(), alarm, auth, compat)
+
+	// Слишком длинное сообщение отклоняется с RESOURCE_EXHAUSTED до разбора;
+	// длинные ответы виджета в пределах лимита отклоняет инстанс
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(maxRecvBytes())}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(opts...)
+	// Клиенты ходят за заданиями через балансер: он выбирает инстанс и
+	// направляет проверки решений туда, где задание было выдано
+	balancer.RegisterServices(s, registry, control, auth, compat, envOr("GRPC_RESPONSE_COMPRESSION", "gzip"), trustedProxies())
+	// GRPC_REFLECTION=true включает reflection для отладки через grpcurl
+	if enabled, _ := strconv.ParseBool(os.Getenv("GRPC_REFLECTION")); enabled {
+		reflection.Register(s)
+		log.Println("gRPC reflection service enabled.")
+	}
 
 	log.Printf("Mock balancer server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
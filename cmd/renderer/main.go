@@ -0,0 +1,131 @@
+// Команда renderer — пул отрисовки заданий для больших инсталляций: API-инстансы
+// капчи с RENDERER_ADDR отдают ему генерацию картинок, а пул масштабируется
+// независимо от них. Параметры генератора должны совпадать с инстансами.
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	rendererpb "captcha-service/api/renderer/v1"
+	"captcha-service/internal/assetsig"
+	"captcha-service/internal/generator"
+	"captcha-service/internal/renderer"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultAddr = ":50061"
+	// defaultAssetURLTTL совпадает со сроком жизни задания на инстансе
+	defaultAssetURLTTL = 5 * time.Minute
+	shutdownTimeout    = 10 * time.Second
+
+	// Бюджеты HTML совпадают с умолчаниями инстанса капчи
+	defaultMaxHTMLSize    = 3<<20 + 512<<10
+	defaultHTMLSizeBudget = 2 << 20
+)
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+// templateVersions разбирает TEMPLATE_VERSIONS ("slider-rotate=v1,default=v2")
+func templateVersions() map[string]string {
+	versions := make(map[string]string)
+	for _, item := range strings.Split(os.Getenv("TEMPLATE_VERSIONS"), ",") {
+		if k, v, ok := strings.Cut(item, "="); ok {
+			versions[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return versions
+}
+
+func main() {
+	step, err := strconv.ParseFloat(envOr("SLIDER_STEP", "0.5"), 64)
+	if err != nil {
+		log.Fatalf("Invalid SLIDER_STEP: %v", err)
+	}
+	obfuscate, err := strconv.ParseBool(envOr("OBFUSCATE_WIDGET", "true"))
+	if err != nil {
+		log.Fatalf("Invalid OBFUSCATE_WIDGET: %v", err)
+	}
+	gen, err := generator.New(generator.Config{
+		SliderStep:       step,
+		Obfuscate:        obfuscate,
+		MaxHTMLSize:      envInt("MAX_HTML_BYTES", defaultMaxHTMLSize),
+		SizeBudget:       envInt("HTML_SIZE_BUDGET_BYTES", defaultHTMLSizeBudget),
+		BackgroundDir:    os.Getenv("BACKGROUND_DIR"),
+		TemplateDir:      os.Getenv("TEMPLATE_DIR"),
+		TemplateVersions: templateVersions(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create captcha generator: %v", err)
+	}
+
+	// Адреса картинок подписываются тем же ключом, что проверяют инстансы
+	var sign func(key, name string) string
+	ttl := defaultAssetURLTTL
+	if v := os.Getenv("ASSET_URL_TTL"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("Invalid ASSET_URL_TTL: %v", err)
+		}
+	}
+	if signer := assetsig.New([]byte(os.Getenv("ASSET_URL_SECRET")), ttl); signer != nil {
+		log.Printf("Challenge image URLs are signed, valid for %s", ttl)
+		sign = func(key, name string) string { return signer.Sign(key, name, time.Now()) }
+	}
+
+	addr := envOr("RENDERER_ADDR", defaultAddr)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
+	s := grpc.NewServer()
+	rendererpb.RegisterRendererServiceServer(s, renderer.NewServer(gen, sign))
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthSrv)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutdown requested, finishing in-flight renders")
+		healthSrv.Shutdown()
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			s.Stop()
+		}
+	}()
+
+	log.Printf("Renderer gRPC server listening at %v", lis.Addr())
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("Failed to serve gRPC: %v", err)
+	}
+	log.Println("Renderer stopped.")
+}
@@ -0,0 +1,64 @@
+// Команда selftest прогоняет известные атаки (internal/attacker) против генератора
+// в процессе и печатает долю решенных заданий для каждой атаки.
+//
+//	go run ./cmd/selftest -trials 200 -complexity 50
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"captcha-service/internal/attacker"
+	"captcha-service/internal/generator"
+)
+
+func main() {
+	trials := flag.Int("trials", 100, "number of challenges to attack")
+	complexity := flag.Int("complexity", 50, "challenge complexity used for answer tolerance")
+	attempts := flag.Int("attempts", 1, "guesses per challenge for the brute-force sweep")
+	dragSpeed := flag.Float64("drag-speed", 4, "slider pixels per frame for the constant-velocity drag")
+	step := flag.Float64("slider-step", 0.5, "slider step of the generator")
+	failAbove := flag.Float64("fail-above", 1, "exit with status 1 if any attack solves more than this fraction")
+	flag.Parse()
+
+	gen, err := generator.New(generator.Config{
+		SliderStep: *step,
+		Obfuscate:  true,
+		// Атакующему нужны картинки отдельно от HTML, как после загрузки виджета
+		AssetBaseURL: "http://selftest.invalid",
+	})
+	if err != nil {
+		log.Fatalf("Failed to create generator: %v", err)
+	}
+	// Генератор логирует каждое задание
+	log.SetOutput(io.Discard)
+
+	attacks := []attacker.Attack{
+		attacker.HoleMatch{},
+		attacker.Sweep{Attempts: *attempts},
+		attacker.ConstantDrag{PixelsPerFrame: *dragSpeed},
+	}
+	results, err := attacker.Run(gen.Generate, attacks, *trials, *complexity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Self-test failed: %v\n", err)
+		os.Exit(2)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ATTACK\tSOLVED\tFIRST TRY\tRATE")
+	failed := false
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d/%d\t%d\t%.1f%%\n", r.Attack, r.Solved, r.Trials, r.FirstTry, 100*r.Rate())
+		if r.Rate() > *failAbove {
+			failed = true
+		}
+	}
+	w.Flush()
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,137 @@
+// Команда smoketest проходит полный путь задания на живом инстансе или балансере:
+// выдает задание, берет правильный ответ из отладочного комментария в HTML,
+// отправляет его в event-стрим, ждет результат и проверяет токен через Assess.
+// Инстансы должны быть запущены с DEBUG_ANSWERS=true; без него ответа в HTML нет
+// и команда завершается с кодом 2. Провал проверки — код 1.
+//
+//	go run ./cmd/smoketest -addr localhost:50051 -complexity 10,80,95
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/answer"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:50051", "captcha instance or balancer")
+	complexities := flag.String("complexity", "10", "comma-separated complexities, one round trip each")
+	siteKey := flag.String("site-key", "", "site key of the challenges")
+	action := flag.String("action", "smoketest", "action of the challenges")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline of one round trip")
+	schema := flag.Uint("answer-schema", uint(answer.SchemaText), "answer schema the widget declares (see internal/answer/schema.go)")
+	flag.Parse()
+
+	var rounds []int32
+	for _, v := range strings.Split(*complexities, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || c < 1 || c > 100 {
+			fmt.Fprintf(os.Stderr, "Invalid complexity %q: want 1-100\n", v)
+			os.Exit(2)
+		}
+		rounds = append(rounds, int32(c))
+	}
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to %s: %v\n", *addr, err)
+		os.Exit(2)
+	}
+	defer conn.Close()
+	client := captchapb.NewCaptchaServiceClient(conn)
+
+	failed := false
+	for _, complexity := range rounds {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		start := time.Now()
+		id, err := roundTrip(ctx, client, &captchapb.ChallengeRequest{
+			Complexity: complexity,
+			SiteKey:    *siteKey,
+			Action:     *action,
+			Client: &captchapb.ClientContext{
+				Capabilities: &captchapb.WidgetCapabilities{AnswerSchemas: []uint32{uint32(*schema)}, Crypto: true},
+			},
+		})
+		cancel()
+		if errors.Is(err, answer.ErrNoDebugAnswer) {
+			fmt.Fprintf(os.Stderr, "FAIL complexity %d: %v\n", complexity, err)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Printf("FAIL complexity %d (%s): %v\n", complexity, id, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("OK   complexity %d (%s) in %s\n", complexity, id, time.Since(start).Round(time.Millisecond))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// roundTrip проходит одно задание целиком и возвращает его ID
+func roundTrip(ctx context.Context, client captchapb.CaptchaServiceClient, req *captchapb.ChallengeRequest) (string, error) {
+	challenge, err := client.NewChallenge(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("NewChallenge: %w", err)
+	}
+	id := challenge.GetChallengeId()
+	if challenge.GetAllowlisted() {
+		return id, fmt.Errorf("client is allowlisted, no challenge was issued")
+	}
+	payload, err := answer.FromDebug(challenge.GetHtml())
+	if err != nil {
+		return id, err
+	}
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		return id, fmt.Errorf("MakeEventStream: %w", err)
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&captchapb.ClientEvent{ChallengeId: id, Data: payload, SiteKey: req.GetSiteKey()}); err != nil {
+		return id, fmt.Errorf("send: %w", err)
+	}
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			return id, fmt.Errorf("waiting for result: %w", err)
+		}
+		if ctrl := ev.GetControl(); ctrl != nil && ctrl.GetChallengeId() == id {
+			return id, fmt.Errorf("instance answered %s: %s", ctrl.GetKind(), ctrl.GetMessage())
+		}
+		res := ev.GetResult()
+		if res == nil || res.GetChallengeId() != id {
+			continue
+		}
+		if res.GetConfidencePercent() < 100 {
+			return id, fmt.Errorf("correct answer %q got confidence %d%%", payload, res.GetConfidencePercent())
+		}
+		if res.GetToken() == "" {
+			return id, nil
+		}
+		assessed, err := client.Assess(ctx, &captchapb.AssessRequest{
+			Token:   res.GetToken(),
+			Action:  req.GetAction(),
+			SiteKey: req.GetSiteKey(),
+		})
+		if err != nil {
+			return id, fmt.Errorf("Assess: %w", err)
+		}
+		if assessed.GetDecision() != captchapb.AssessResponse_ALLOW {
+			return id, fmt.Errorf("Assess decided %s: %s", assessed.GetDecision(), assessed.GetReason())
+		}
+		return id, nil
+	}
+}
@@ -0,0 +1,150 @@
+// Package accesslog пишет одну структурированную (JSON) запись на каждый
+// gRPC-вызов или стрим: метод, пир, site key, длительность, статус и объем
+// трафика. Записи успешных вызовов можно сэмплировать, ошибки — отдельно.
+package accesslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"captcha-service/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Config задает доли записываемых вызовов (0..1)
+type Config struct {
+	SampleRate      float64
+	ErrorSampleRate float64
+}
+
+// Logger — перехватчики access-лога поверх slog
+type Logger struct {
+	cfg    Config
+	logger *slog.Logger
+}
+
+// New создает логгер, пишущий JSON-записи в w
+func New(w io.Writer, cfg Config) *Logger {
+	return &Logger{cfg: cfg, logger: slog.New(slog.NewJSONHandler(w, nil))}
+}
+
+// siteKeyed — запросы, в которых есть site key тенанта
+type siteKeyed interface{ GetSiteKey() string }
+
+func siteKey(msg any) string {
+	if m, ok := msg.(siteKeyed); ok {
+		return m.GetSiteKey()
+	}
+	return ""
+}
+
+func size(msg any) int64 {
+	if m, ok := msg.(proto.Message); ok {
+		return int64(proto.Size(m))
+	}
+	return 0
+}
+
+// skip — health-проверки оркестратора только зашумляют лог
+func skip(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.")
+}
+
+func (l *Logger) sampled(err error) bool {
+	rate := l.cfg.SampleRate
+	if err != nil {
+		rate = l.cfg.ErrorSampleRate
+	}
+	return rate >= 1 || rate > 0 && rand.Float64() < rate
+}
+
+func (l *Logger) write(ctx context.Context, kind, method, site string, start time.Time, err error, attrs ...slog.Attr) {
+	if !l.sampled(err) {
+		return
+	}
+	peerAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		peerAddr = logging.IP(p.Addr.String()).String()
+	}
+	attrs = append([]slog.Attr{
+		slog.String("kind", kind),
+		slog.String("method", method),
+		slog.String("peer", peerAddr),
+		slog.String("site_key", site),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("code", status.Code(err).String()),
+	}, attrs...)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, "grpc access", attrs...)
+}
+
+// UnaryInterceptor пишет запись по завершении унарного вызова
+func (l *Logger) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		res, err := handler(ctx, req)
+		l.write(ctx, "unary", info.FullMethod, siteKey(req), start, err,
+			slog.Int64("bytes_in", size(req)), slog.Int64("bytes_out", size(res)))
+		return res, err
+	}
+}
+
+// StreamInterceptor пишет запись по закрытии стрима с суммарным трафиком
+func (l *Logger) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skip(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		cs := &countingStream{ServerStream: ss}
+		err := handler(srv, cs)
+		site, _ := cs.site.Load().(string)
+		l.write(ss.Context(), "stream", info.FullMethod, site, start, err,
+			slog.Int64("msgs_in", cs.msgsIn.Load()), slog.Int64("msgs_out", cs.msgsOut.Load()),
+			slog.Int64("bytes_in", cs.bytesIn.Load()), slog.Int64("bytes_out", cs.bytesOut.Load()))
+		return err
+	}
+}
+
+// countingStream считает сообщения и байты стрима; site key берется из первого сообщения с ним
+type countingStream struct {
+	grpc.ServerStream
+	msgsIn, msgsOut   atomic.Int64
+	bytesIn, bytesOut atomic.Int64
+	site              atomic.Value
+}
+
+func (s *countingStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.msgsOut.Add(1)
+		s.bytesOut.Add(size(m))
+	}
+	return err
+}
+
+func (s *countingStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.msgsIn.Add(1)
+		s.bytesIn.Add(size(m))
+		if key := siteKey(m); key != "" && s.site.Load() == nil {
+			s.site.Store(key)
+		}
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+// Package acme — автоматическое получение TLS-сертификатов по ACME (Let's Encrypt),
+// чтобы небольшим установкам не нужны были внешние инструменты для TLS.
+// Сертификаты выдаются только для хостов из явного списка: иначе любой,
+// кто направит свой домен на сервер, расходовал бы лимиты выдачи.
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Config — параметры ACME
+type Config struct {
+	// Hosts — разрешенные имена хостов; пустой список выключает ACME
+	Hosts []string
+	// Email — контакт аккаунта для уведомлений об истечении сертификатов
+	Email string
+	// Cache — хранилище сертификатов (см. NewCache)
+	Cache Cache
+	// DirectoryURL — адрес ACME-сервера; пусто — боевой Let's Encrypt
+	DirectoryURL string
+}
+
+// Enabled сообщает, задан ли хотя бы один хост
+func (c Config) Enabled() bool {
+	return len(c.Hosts) > 0
+}
+
+// Cache — хранилище сертификатов и ключа аккаунта; тот же контракт, что у autocert.Cache,
+// поэтому подходит любая его реализация (например, общее хранилище для нескольких реплик)
+type Cache = autocert.Cache
+
+// NewCache создает хранилище по описанию: "memory" — в памяти процесса
+// (сертификаты перевыпускаются при каждом рестарте), "dir:<path>" или просто путь — директория
+func NewCache(spec string) (Cache, error) {
+	switch {
+	case spec == "memory":
+		return &memoryCache{items: map[string][]byte{}}, nil
+	case strings.HasPrefix(spec, "dir:"):
+		spec = strings.TrimPrefix(spec, "dir:")
+	}
+	if spec == "" {
+		return nil, errors.New("acme cache directory is empty")
+	}
+	return autocert.DirCache(spec), nil
+}
+
+// NewManager создает менеджер сертификатов; его TLSConfig подходит и для HTTPS, и для gRPC
+func NewManager(cfg Config) (*autocert.Manager, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("acme requires at least one allowed host")
+	}
+	if cfg.Cache == nil {
+		return nil, errors.New("acme requires a certificate cache")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Hosts...),
+		Cache:      cfg.Cache,
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	return m, nil
+}
+
+// ServeHTTPChallenge отвечает на HTTP-01 проверки на addr (обычно :80),
+// остальные запросы перенаправляет на HTTPS
+func ServeHTTPChallenge(m *autocert.Manager, addr string) error {
+	if err := http.ListenAndServe(addr, m.HTTPHandler(nil)); err != nil {
+		return fmt.Errorf("acme http-01 listener: %w", err)
+	}
+	return nil
+}
+
+// memoryCache — хранилище в памяти процесса
+type memoryCache struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.items[key]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (c *memoryCache) Put(_ context.Context, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = data
+	return nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package answer разбирает решение пазла, присланное виджетом в ClientEvent.data.
+//
+// Формат слайдера: ASCII-строка с десятичным числом — координата X левого края
+// пазла в пикселях исходного изображения (не CSS-пикселях), например "137" или
+// "137.5". Дробная часть допустима: на HiDPI-экранах положение не целое.
+//
+// Правила округления: сервер приводит X к сетке шага слайдера (step),
+// округляя до ближайшего узла, половина — от нуля. Поскольку правильный ответ
+// может лежать между узлами сетки, к допуску добавляется половина шага.
+//
+// Формат пазла с вращением: "X,угол", например "137.5,212". Угол — поворот
+// пазла по часовой стрелке в градусах, [0, 360). X и угол проверяются
+// независимо, каждый со своим допуском; угол сравнивается по окружности.
+//
+// Формат многопазлового задания: пары "ID:X" через ";", например "0:137;1:402.5".
+// ID — значение data-piece фрагмента из HTML. Порядок пар не важен, каждый
+// фрагмент проверяется отдельно по тем же правилам, что и слайдер; фрагменты
+// без ответа считаются не поставленными.
+//
+// Откалиброванный виджет (событие CALIBRATION) может прислать любой из этих
+// форматов с префиксом DevicePrefix: тогда все координаты в нем — физические
+// пиксели отрисованной картинки, и сервер переводит их в пиксели исходного
+// изображения по масштабу калибровки, например "dp=274" или "dp=0:274;1:805".
+package answer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSliderPayload — ответ слайдера не может быть длиннее пары десятков байт
+	maxSliderPayload = 32
+	// maxPieces — сколько пар "ID:X" принимается в многопазловом ответе
+	maxPieces = 8
+	// maxPieceID — ID фрагмента короткий: это индекс из data-piece
+	maxPieceID = 16
+)
+
+var errEmpty = errors.New("empty answer payload")
+
+// DevicePrefix — префикс ответа в физических пикселях экрана
+const DevicePrefix = "dp="
+
+// Device отделяет DevicePrefix от ответа и сообщает, был ли он
+func Device(data []byte) ([]byte, bool) {
+	return bytes.CutPrefix(data, []byte(DevicePrefix))
+}
+
+// ParseSlider разбирает координату X из полезной нагрузки
+func ParseSlider(data []byte) (float64, error) {
+	if len(data) == 0 {
+		return 0, errEmpty
+	}
+	if len(data) > maxSliderPayload {
+		return 0, fmt.Errorf("answer payload too long: %d bytes", len(data))
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid slider position: %w", err)
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) || x < 0 {
+		return 0, fmt.Errorf("slider position out of range: %v", x)
+	}
+	return x, nil
+}
+
+// ParseRotate разбирает ответ пазла с вращением: координату X и угол
+func ParseRotate(data []byte) (x, angle float64, err error) {
+	if len(data) > 2*maxSliderPayload {
+		return 0, 0, fmt.Errorf("answer payload too long: %d bytes", len(data))
+	}
+	xPart, anglePart, ok := strings.Cut(string(data), ",")
+	if !ok {
+		return 0, 0, errors.New("rotate answer must be in \"X,angle\" format")
+	}
+	if x, err = ParseSlider([]byte(xPart)); err != nil {
+		return 0, 0, err
+	}
+	angle, err = strconv.ParseFloat(strings.TrimSpace(anglePart), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid rotation angle: %w", err)
+	}
+	if math.IsNaN(angle) || angle < 0 || angle >= 360 {
+		return 0, 0, fmt.Errorf("rotation angle out of range: %v", angle)
+	}
+	return x, angle, nil
+}
+
+// ParseMulti разбирает ответ многопазлового задания в карту ID -> X
+func ParseMulti(data []byte) (map[string]float64, error) {
+	if len(data) == 0 {
+		return nil, errEmpty
+	}
+	if len(data) > maxPieces*(maxPieceID+1+maxSliderPayload+1) {
+		return nil, fmt.Errorf("answer payload too long: %d bytes", len(data))
+	}
+	// SplitN не дает враждебному ответу из одних ";" раздуть срез
+	parts := strings.SplitN(string(data), ";", maxPieces+1)
+	if len(parts) > maxPieces {
+		return nil, fmt.Errorf("too many pieces in answer: more than %d", maxPieces)
+	}
+	positions := make(map[string]float64, len(parts))
+	for _, part := range parts {
+		id, xPart, ok := strings.Cut(part, ":")
+		if !ok || id == "" || len(id) > maxPieceID {
+			return nil, fmt.Errorf("piece answer %q must be in \"ID:X\" format", part)
+		}
+		if _, dup := positions[id]; dup {
+			return nil, fmt.Errorf("duplicate answer for piece %q", id)
+		}
+		x, err := ParseSlider([]byte(xPart))
+		if err != nil {
+			return nil, fmt.Errorf("piece %q: %w", id, err)
+		}
+		positions[id] = x
+	}
+	return positions, nil
+}
+
+// Tolerance — допуск по X в пикселях исходного изображения: чем выше сложность, тем он уже
+func Tolerance(complexity int) float64 {
+	tolerance := 5 - (complexity / 25)
+	if tolerance < 1 {
+		tolerance = 1
+	}
+	return float64(tolerance)
+}
+
+// AngleTolerance — допуск по углу в градусах для пазла с вращением
+func AngleTolerance(complexity int) float64 {
+	tolerance := 12 - (complexity / 10)
+	if tolerance < 4 {
+		tolerance = 4
+	}
+	return float64(tolerance)
+}
+
+// AngleDelta возвращает кратчайшее расстояние между углами по окружности, [0, 180]
+func AngleDelta(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// Snap приводит значение к сетке шага step (round half away from zero)
+func Snap(x, step float64) float64 {
+	if step <= 0 {
+		return x
+	}
+	return math.Round(x/step) * step
+}
+
+// Within сообщает, попадает ли ответ в допуск после привязки к сетке,
+// и возвращает фактическое отклонение
+func Within(got, want, tolerance, step float64) (bool, float64) {
+	delta := math.Abs(Snap(got, step) - want)
+	return delta <= tolerance+step/2, delta
+}
@@ -0,0 +1,198 @@
+package answer
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+// FuzzUnwrap: враждебный data в любой схеме не роняет разбор, а конверт,
+// который удалось разобрать, не длиннее ограничения
+func FuzzUnwrap(f *testing.F) {
+	private, public, err := NewKey()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(SchemaText, []byte("137.5"))
+	f.Add(SchemaEnvelope, []byte(`{"answer":"137,212","fingerprint":"fp"}`))
+	f.Add(SchemaEnvelope, []byte(`{"answer":1}`))
+	f.Add(SchemaSealed, []byte(`{"epk":"","iv":"","ct":""}`))
+	if sealed, err := Wrap(SchemaSealed, public, "0:137;1:402.5", "fp"); err == nil {
+		f.Add(SchemaSealed, sealed)
+	}
+	f.Add(uint32(42), []byte("137"))
+
+	f.Fuzz(func(t *testing.T, schema uint32, data []byte) {
+		payload, fingerprint, err := Unwrap(schema, private, data)
+		if err != nil {
+			return
+		}
+		switch schema {
+		case 0, SchemaText:
+			if !bytes.Equal(payload, data) || fingerprint != "" {
+				t.Fatalf("text schema changed the answer: %q -> %q, %q", data, payload, fingerprint)
+			}
+		case SchemaEnvelope:
+			if len(data) > maxEnvelope {
+				t.Fatalf("accepted a %d-byte envelope", len(data))
+			}
+		case SchemaSealed:
+		default:
+			t.Fatalf("unknown schema %d accepted", schema)
+		}
+	})
+}
+
+// FuzzWrap: то, что упаковал виджет, сервер распаковывает без изменений
+func FuzzWrap(f *testing.F) {
+	private, public, err := NewKey()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add("137.5", "fp")
+	f.Add("137,212", "")
+	f.Add("dp=0:274;1:805", "\x00\"\\")
+
+	f.Fuzz(func(t *testing.T, payload, fingerprint string) {
+		for _, schema := range Schemas {
+			data, err := Wrap(schema, public, payload, fingerprint)
+			if err != nil {
+				t.Fatalf("schema %d: %v", schema, err)
+			}
+			if len(data) > MaxData {
+				continue
+			}
+			got, fp, err := Unwrap(schema, private, data)
+			if err != nil {
+				if schema != SchemaText && len(data) > maxEnvelope {
+					continue
+				}
+				t.Fatalf("schema %d: %v", schema, err)
+			}
+			// JSON заменяет невалидный UTF-8 на U+FFFD: виджет шлет только строки JS
+			if schema != SchemaText && !validUTF8(payload, fingerprint) {
+				continue
+			}
+			if string(got) != payload {
+				t.Fatalf("schema %d: answer %q came back as %q", schema, payload, got)
+			}
+			if schema != SchemaText && fp != fingerprint {
+				t.Fatalf("schema %d: fingerprint %q came back as %q", schema, fingerprint, fp)
+			}
+		}
+	})
+}
+
+func validUTF8(s ...string) bool {
+	for _, v := range s {
+		if !bytes.Equal([]byte(v), bytes.ToValidUTF8([]byte(v), nil)) {
+			return false
+		}
+	}
+	return true
+}
+
+// FuzzParse: разбор ответа не роняет сервис и возвращает только координаты,
+// которые можно сравнивать с допуском
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{"137", "137.5", " 137 ", "-1", "NaN", "Inf", "1e308", "137,212", "137,360", "0:137;1:402.5", "0:1;0:2", ";;;", "dp=274"} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if x, err := ParseSlider(data); err == nil {
+			checkX(t, x)
+		}
+		if x, angle, err := ParseRotate(data); err == nil {
+			checkX(t, x)
+			if !(angle >= 0 && angle < 360) {
+				t.Fatalf("angle %v out of [0, 360)", angle)
+			}
+		}
+		if positions, err := ParseMulti(data); err == nil {
+			if len(positions) == 0 || len(positions) > maxPieces {
+				t.Fatalf("%d pieces parsed", len(positions))
+			}
+			for id, x := range positions {
+				if id == "" || len(id) > maxPieceID {
+					t.Fatalf("piece ID %q accepted", id)
+				}
+				checkX(t, x)
+			}
+		}
+	})
+}
+
+func checkX(t *testing.T, x float64) {
+	t.Helper()
+	if math.IsNaN(x) || math.IsInf(x, 0) || x < 0 {
+		t.Fatalf("position %v accepted", x)
+	}
+}
+
+// Допуски сужаются с ростом сложности, но никогда не исчезают
+func TestToleranceMonotonic(t *testing.T) {
+	for c := -10; c <= 200; c++ {
+		if tol := Tolerance(c); tol < 1 || tol > Tolerance(c-1) {
+			t.Errorf("Tolerance(%d) = %v after %v", c, tol, Tolerance(c-1))
+		}
+		if tol := AngleTolerance(c); tol < 4 || tol > AngleTolerance(c-1) {
+			t.Errorf("AngleTolerance(%d) = %v after %v", c, tol, AngleTolerance(c-1))
+		}
+	}
+}
+
+// Расстояние по окружности симметрично, лежит в [0, 180] и не зависит от оборотов
+func TestAngleDeltaProperties(t *testing.T) {
+	prop := func(a, b float64, turns int8) bool {
+		a, b = math.Mod(a, 1e6), math.Mod(b, 1e6)
+		d := AngleDelta(a, b)
+		shifted := AngleDelta(a+360*float64(turns), b)
+		return d >= 0 && d <= 180 && d == AngleDelta(b, a) && math.Abs(d-shifted) < 1e-6
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Error(err)
+	}
+	if d := AngleDelta(350, 10); d != 20 {
+		t.Errorf("AngleDelta(350, 10) = %v, want 20", d)
+	}
+}
+
+// Привязка к сетке идемпотентна и сдвигает ответ не больше чем на полшага
+func TestSnapProperties(t *testing.T) {
+	prop := func(x float64, step uint8) bool {
+		x = math.Mod(math.Abs(x), 1e4)
+		s := float64(step) / 4
+		snapped := Snap(x, s)
+		if s == 0 {
+			return snapped == x
+		}
+		return Snap(snapped, s) == snapped && math.Abs(snapped-x) <= s/2+1e-9
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Error(err)
+	}
+	if got := Snap(2.5, 1); got != 3 {
+		t.Errorf("Snap(2.5, 1) = %v, want 3 (half away from zero)", got)
+	}
+}
+
+// Правильный ответ принимается при любом шаге; ответ дальше допуска и
+// полушага от правильного отклоняется
+func TestWithinProperties(t *testing.T) {
+	prop := func(want uint16, complexity uint8, step uint8, offset float64) bool {
+		w := float64(want % 1000)
+		tol := Tolerance(int(complexity))
+		s := float64(step%16) / 2
+		if ok, _ := Within(w, w, tol, s); !ok {
+			return false
+		}
+		far := w + tol + s + 1 + math.Mod(math.Abs(offset), 100)
+		ok, delta := Within(far, w, tol, s)
+		return !ok && delta > tol+s/2
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Error(err)
+	}
+}
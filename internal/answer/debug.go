@@ -0,0 +1,36 @@
+package answer
+
+import (
+	"errors"
+	"strings"
+)
+
+// Отладочный ответ: инстанс, запущенный с DEBUG_ANSWERS, дописывает в HTML
+// задания HTML-комментарий с готовой полезной нагрузкой правильного ответа.
+// Комментарий переживает прокси балансера, поэтому smoke-тест (cmd/smoketest)
+// может пройти полный путь через балансер. В проде режим выключен.
+const (
+	debugPrefix = "<!-- captcha-debug-answer: "
+	debugSuffix = " -->"
+)
+
+// ErrNoDebugAnswer — в HTML нет отладочного ответа: инстанс запущен без DEBUG_ANSWERS
+var ErrNoDebugAnswer = errors.New("challenge html has no debug answer (is DEBUG_ANSWERS enabled on the instance?)")
+
+// WithDebug дописывает в HTML комментарий с полезной нагрузкой ответа
+func WithDebug(html, payload string) string {
+	return html + debugPrefix + payload + debugSuffix
+}
+
+// FromDebug достает полезную нагрузку ответа из HTML задания
+func FromDebug(html string) ([]byte, error) {
+	i := strings.LastIndex(html, debugPrefix)
+	if i < 0 {
+		return nil, ErrNoDebugAnswer
+	}
+	payload, _, ok := strings.Cut(html[i+len(debugPrefix):], debugSuffix)
+	if !ok || payload == "" {
+		return nil, ErrNoDebugAnswer
+	}
+	return []byte(payload), nil
+}
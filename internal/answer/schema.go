@@ -0,0 +1,95 @@
+package answer
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Схемы ответа — как виджет упаковывает решение в ClientEvent.data.
+// Сервер выдает виджету задание в схеме, которую тот поддерживает, поэтому
+// новая схема не ломает закэшированные виджеты старых версий.
+const (
+	// SchemaText — решение как есть в форматах из описания пакета; отпечаток
+	// идет отдельным полем ClientEvent.fingerprint. Схема старых виджетов.
+	SchemaText uint32 = 1
+	// SchemaEnvelope — JSON {"answer": "...", "fingerprint": "..."}: все, что
+	// посчитал виджет, едет внутри data и не теряется в загрузчиках, которые
+	// пересылают только data
+	SchemaEnvelope uint32 = 2
+	// SchemaSealed — конверт SchemaEnvelope, зашифрованный открытым ключом
+	// задания (см. Seal): прокси сайта, через который идет ответ, не видит
+	// решение рядом с токеном. Нужен WebCrypto в виджете.
+	SchemaSealed uint32 = 3
+)
+
+// Schemas — схемы, которые понимает сервер, от старой к новой
+var Schemas = []uint32{SchemaText, SchemaEnvelope, SchemaSealed}
+
+// Negotiate выбирает самую новую схему, которую понимают и виджет, и сервер;
+// виджет без списка схем получает SchemaText, без WebCrypto — не SchemaSealed
+func Negotiate(client []uint32, crypto bool) uint32 {
+	for _, s := range slices.Backward(Schemas) {
+		if s == SchemaSealed && !crypto {
+			continue
+		}
+		if slices.Contains(client, s) {
+			return s
+		}
+	}
+	return SchemaText
+}
+
+// maxEnvelope — конверт не длиннее самого длинного ответа с отпечатком и разметкой
+const maxEnvelope = 1024
+
+// envelope — ответ в схеме SchemaEnvelope
+type envelope struct {
+	Answer      string `json:"answer"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Wrap упаковывает решение и отпечаток в data схемы schema — как это делает
+// виджет; для SchemaText отпечаток отправляется отдельным полем события,
+// для SchemaSealed нужен открытый ключ задания key
+func Wrap(schema uint32, key []byte, payload, fingerprint string) ([]byte, error) {
+	switch schema {
+	case 0, SchemaText:
+		return []byte(payload), nil
+	case SchemaEnvelope:
+		return json.Marshal(envelope{Answer: payload, Fingerprint: fingerprint})
+	case SchemaSealed:
+		data, err := json.Marshal(envelope{Answer: payload, Fingerprint: fingerprint})
+		if err != nil {
+			return nil, err
+		}
+		return Seal(key, data)
+	}
+	return nil, fmt.Errorf("unknown answer schema %d", schema)
+}
+
+// Unwrap достает решение и отпечаток из data в схеме schema; для SchemaText
+// отпечаток пуст: он приходит отдельным полем события. key — закрытый ключ
+// задания для SchemaSealed.
+func Unwrap(schema uint32, key, data []byte) (payload []byte, fingerprint string, err error) {
+	switch schema {
+	case 0, SchemaText:
+		return data, "", nil
+	case SchemaSealed:
+		plaintext, err := open(key, data)
+		if err != nil {
+			return nil, "", err
+		}
+		return Unwrap(SchemaEnvelope, nil, plaintext)
+	case SchemaEnvelope:
+		if len(data) > maxEnvelope {
+			return nil, "", fmt.Errorf("answer envelope too long: %d bytes", len(data))
+		}
+		var e envelope
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, "", fmt.Errorf("invalid answer envelope: %w", err)
+		}
+		return []byte(e.Answer), e.Fingerprint, nil
+	}
+	return nil, "", fmt.Errorf("unknown answer schema %d", schema)
+}
@@ -0,0 +1,124 @@
+package answer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// sealInfo — info HKDF; виджет выводит ключ AES с тем же значением
+const sealInfo = "captcha answer v3"
+
+// maxSealed — запечатанный конверт: maxEnvelope в base64 плюс ключ и nonce
+const maxSealed = 2048
+
+// MaxData — самый длинный ClientEvent.data, который виджет присылает в любой
+// схеме; более длинный ответ заведомо не разбирается
+const MaxData = maxSealed
+
+// sealed — ответ в схеме SchemaSealed: эфемерный открытый ключ виджета,
+// nonce и шифртекст AES-GCM конверта SchemaEnvelope, все в base64
+type sealed struct {
+	EphemeralKey []byte `json:"epk"`
+	IV           []byte `json:"iv"`
+	Ciphertext   []byte `json:"ct"`
+}
+
+// NewKey создает ключ задания: закрытый остается на сервере, открытый
+// (несжатая точка P-256) встраивается в задание
+func NewKey() (private, public []byte, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key.Bytes(), key.PublicKey().Bytes(), nil
+}
+
+// PublicKey возвращает открытый ключ по закрытому из NewKey
+func PublicKey(private []byte) ([]byte, error) {
+	key, err := ecdh.P256().NewPrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey().Bytes(), nil
+}
+
+// Seal шифрует plaintext открытым ключом задания так же, как виджет:
+// ECDH с эфемерным ключом, HKDF-SHA256 и AES-256-GCM
+func Seal(public, plaintext []byte) ([]byte, error) {
+	peer, err := ecdh.P256().NewPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("invalid answer key: %w", err)
+	}
+	eph, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := sealCipher(shared)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aead.NonceSize())
+	rand.Read(iv)
+	return json.Marshal(sealed{
+		EphemeralKey: eph.PublicKey().Bytes(),
+		IV:           iv,
+		Ciphertext:   aead.Seal(nil, iv, plaintext, nil),
+	})
+}
+
+// open расшифровывает запечатанный ответ закрытым ключом задания
+func open(private, data []byte) ([]byte, error) {
+	if len(data) > maxSealed {
+		return nil, fmt.Errorf("sealed answer too long: %d bytes", len(data))
+	}
+	var s sealed
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid sealed answer: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("invalid answer key: %w", err)
+	}
+	peer, err := ecdh.P256().NewPublicKey(s.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed answer: %w", err)
+	}
+	shared, err := key.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed answer: %w", err)
+	}
+	aead, err := sealCipher(shared)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.IV) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed answer: nonce is %d bytes", len(s.IV))
+	}
+	plaintext, err := aead.Open(nil, s.IV, s.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed answer: %w", err)
+	}
+	return plaintext, nil
+}
+
+func sealCipher(shared []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, shared, nil, sealInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,86 @@
+package answer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Запечатанный ответ открывается только закрытым ключом своего задания и без изменений
+func TestSealOpen(t *testing.T) {
+	private, public, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derived, err := PublicKey(private); err != nil || !bytes.Equal(derived, public) {
+		t.Fatalf("PublicKey = %x, %v; want %x", derived, err, public)
+	}
+	otherPrivate, _, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte(`{"answer":"137.5","fingerprint":"fp"}`)
+	data, err := Seal(public, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reseal меняет одно поле запечатанного ответа
+	reseal := func(change func(*sealed)) []byte {
+		var s sealed
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatal(err)
+		}
+		change(&s)
+		out, _ := json.Marshal(s)
+		return out
+	}
+
+	for _, tc := range []struct {
+		name    string
+		private []byte
+		data    []byte
+		ok      bool
+	}{
+		{"valid", private, data, true},
+		{"other challenge key", otherPrivate, data, false},
+		{"tampered ciphertext", private, reseal(func(s *sealed) { s.Ciphertext[0] ^= 1 }), false},
+		{"truncated ciphertext", private, reseal(func(s *sealed) { s.Ciphertext = s.Ciphertext[:len(s.Ciphertext)-1] }), false},
+		{"tampered nonce", private, reseal(func(s *sealed) { s.IV[0] ^= 1 }), false},
+		{"short nonce", private, reseal(func(s *sealed) { s.IV = s.IV[:4] }), false},
+		{"bad ephemeral key", private, reseal(func(s *sealed) { s.EphemeralKey = s.EphemeralKey[1:] }), false},
+		{"truncated json", private, data[:len(data)-5], false},
+		{"garbled", private, []byte("not sealed"), false},
+		{"too long", private, []byte(`{"ct":"` + strings.Repeat("A", maxSealed) + `"}`), false},
+		{"bad private key", []byte("short"), data, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := open(tc.private, tc.data)
+			if tc.ok != (err == nil) {
+				t.Fatalf("open: %v, want ok=%v", err, tc.ok)
+			}
+			if tc.ok && !bytes.Equal(got, plaintext) {
+				t.Fatalf("open = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+// Каждый Seal — новый эфемерный ключ и nonce: одинаковые ответы не совпадают
+func TestSealIsRandomized(t *testing.T) {
+	_, public, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, errA := Seal(public, []byte("137"))
+	b, errB := Seal(public, []byte("137"))
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("two seals of the same answer are identical")
+	}
+	if _, err := Seal([]byte("not a key"), []byte("137")); err == nil {
+		t.Fatal("Seal accepted an invalid public key")
+	}
+}
@@ -0,0 +1,183 @@
+// Package archive выгружает устаревшие сегменты журналов (аудит заданий) в
+// объектное хранилище и убирает их с диска инстанса: локально остается только
+// свежая история, а полная доступна для офлайн-анализа. Сегменты хранятся
+// как JSONL, сжатый gzip. Поддерживаются S3 и совместимые с ним хранилища
+// (GCS через HMAC-ключи interoperability, MinIO) и локальный каталог.
+package archive
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archivedSuffix — сегмент выгружен и помечен к удалению
+const archivedSuffix = ".archived"
+
+// Store сохраняет объект под ключом key
+type Store interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
+// Config — хранилище архива
+type Config struct {
+	// URL — s3://bucket/prefix, gs://bucket/prefix или file:///dir
+	URL string
+	// Endpoint — адрес S3-совместимого API вместо стандартного (MinIO и т.п.)
+	Endpoint string
+	Region   string
+	// AccessKeyID и SecretAccessKey — ключи S3 или HMAC-ключи GCS
+	AccessKeyID     string
+	SecretAccessKey string
+	Timeout         time.Duration
+}
+
+// Open создает хранилище по cfg.URL; ключи объектов получают префикс из URL
+func Open(cfg Config) (Store, string, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid archive url: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, "", errors.New("archive url file:// needs a directory")
+		}
+		return Dir(u.Path), "", nil
+	case "s3", "gs":
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("archive url %s needs a bucket", cfg.URL)
+		}
+		if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, "", errors.New("archive storage needs an access key id and a secret access key")
+		}
+		endpoint := cfg.Endpoint
+		region := cmp.Or(cfg.Region, "us-east-1")
+		if endpoint == "" && u.Scheme == "gs" {
+			endpoint, region = "https://storage.googleapis.com", cmp.Or(cfg.Region, "auto")
+		}
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		s, err := NewS3(endpoint, region, u.Host, cfg.AccessKeyID, cfg.SecretAccessKey, cfg.Timeout)
+		return s, prefix, err
+	}
+	return nil, "", fmt.Errorf("unsupported archive url scheme %q", u.Scheme)
+}
+
+// Dir — архив в локальном каталоге (смонтированный том, тесты)
+type Dir string
+
+func (d Dir) Put(_ context.Context, key string, body []byte) error {
+	name := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Archiver выгружает закрытые сегменты журнала старше Retention и помечает
+// их выгруженными; помеченные сегменты удаляются через PurgeDelay. Отложенное
+// удаление оставляет время заметить, что архив пишется не туда.
+type Archiver struct {
+	Store Store
+	// Prefix — префикс ключей объектов (из URL архива)
+	Prefix     string
+	Retention  time.Duration
+	PurgeDelay time.Duration
+}
+
+// Result — итог одного прохода
+type Result struct {
+	Archived int
+	Purged   int
+	Failed   int
+	Bytes    int64
+}
+
+// Sweep обрабатывает сегменты, подходящие под glob pattern; ключ объекта —
+// Prefix/instance/<имя сегмента>.gz. Ошибка одного сегмента не останавливает
+// остальные: сегмент останется на диске до следующего прохода.
+func (a *Archiver) Sweep(ctx context.Context, pattern, instance string) (Result, error) {
+	var res Result
+	segments, err := filepath.Glob(pattern)
+	if err != nil {
+		return res, err
+	}
+	now := time.Now()
+	var errs []error
+	for _, segment := range segments {
+		info, err := os.Stat(segment)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		age := now.Sub(info.ModTime())
+		if strings.HasSuffix(segment, archivedSuffix) {
+			if age >= a.PurgeDelay {
+				if err := os.Remove(segment); err != nil {
+					res.Failed++
+					errs = append(errs, err)
+					continue
+				}
+				res.Purged++
+			}
+			continue
+		}
+		if age < a.Retention {
+			continue
+		}
+		size, err := a.archive(ctx, segment, instance)
+		if err != nil {
+			res.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(segment), err))
+			continue
+		}
+		res.Archived++
+		res.Bytes += size
+	}
+	return res, errors.Join(errs...)
+}
+
+// archive выгружает сегмент и только после успешной выгрузки помечает его
+func (a *Archiver) archive(ctx context.Context, segment, instance string) (int64, error) {
+	f, err := os.Open(segment)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, f); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	key := path.Join(a.Prefix, instance, filepath.Base(segment)+".gz")
+	if err := a.Store.Put(ctx, key, buf.Bytes()); err != nil {
+		return 0, err
+	}
+	archived := segment + archivedSuffix
+	if err := os.Rename(segment, archived); err != nil {
+		return 0, err
+	}
+	// Отсчет PurgeDelay — от выгрузки, а не от последней записи в сегмент
+	now := time.Now()
+	os.Chtimes(archived, now, now)
+	return int64(buf.Len()), nil
+}
@@ -0,0 +1,87 @@
+// Package assetsig подписывает адреса картинок заданий: подпись HMAC-SHA256
+// покрывает ключ ассетов задания, имя картинки и срок действия, поэтому
+// адреса нельзя перебрать или переиспользовать на чужом сайте после истечения.
+package assetsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrMissing — в адресе нет подписи или срока действия
+	ErrMissing = errors.New("asset url is not signed")
+	// ErrInvalid — подпись не совпадает
+	ErrInvalid = errors.New("asset url signature is invalid")
+	// ErrExpired — срок действия адреса истек
+	ErrExpired = errors.New("asset url has expired")
+)
+
+// Signer подписывает и проверяет адреса ассетов
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	// skew — допуск к сроку на расхождение часов: адреса подписывает и пул
+	// рендереров, а проверяет инстанс
+	skew time.Duration
+}
+
+// New создает подписчик; пустой секрет означает, что адреса не подписываются (nil)
+func New(secret []byte, ttl time.Duration) *Signer {
+	if len(secret) == 0 {
+		return nil
+	}
+	return &Signer{secret: secret, ttl: ttl}
+}
+
+// WithClockSkew задает допуск к сроку действия при проверке; nil остается nil
+func (s *Signer) WithClockSkew(skew time.Duration) *Signer {
+	if s != nil {
+		s.skew = skew
+	}
+	return s
+}
+
+// Sign возвращает query-строку "exp=...&sig=..." для ассета name задания с ключом key
+func (s *Signer) Sign(key, name string, now time.Time) string {
+	exp := strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+	q := url.Values{"exp": {exp}, "sig": {s.mac(key, name, exp)}}
+	return q.Encode()
+}
+
+// Verify проверяет подпись из query запроса; сравнение подписи — за постоянное время
+func (s *Signer) Verify(key, name string, q url.Values, now time.Time) error {
+	exp, sig := q.Get("exp"), q.Get("sig")
+	if exp == "" || sig == "" {
+		return ErrMissing
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrInvalid
+	}
+	want, _ := hex.DecodeString(s.mac(key, name, exp))
+	if !hmac.Equal(got, want) {
+		return ErrInvalid
+	}
+	// Срок проверяется только у подлинной подписи
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	if now.Add(-s.skew).Unix() > unix {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) mac(key, name, exp string) string {
+	m := hmac.New(sha256.New, s.secret)
+	// Разделитель исключает склейку полей: ключ — hex, имя и exp не содержат "\n"
+	m.Write([]byte(key + "\n" + name + "\n" + exp))
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package assetsig
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	signer := New([]byte("asset-secret"), time.Minute).WithClockSkew(5 * time.Second)
+	now := time.Now()
+	signed, err := url.ParseQuery(signer.Sign("key-1", "bg.png", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(field, value string) url.Values {
+		q := url.Values{"exp": {signed.Get("exp")}, "sig": {signed.Get("sig")}}
+		q.Set(field, value)
+		return q
+	}
+	sig := signed.Get("sig")
+
+	for _, tc := range []struct {
+		name   string
+		signer *Signer
+		key    string
+		asset  string
+		q      url.Values
+		now    time.Time
+		want   error
+	}{
+		{"valid", signer, "key-1", "bg.png", signed, now, nil},
+		{"expired within skew", signer, "key-1", "bg.png", signed, now.Add(time.Minute + 4*time.Second), nil},
+		{"expired", signer, "key-1", "bg.png", signed, now.Add(time.Minute + 6*time.Second), ErrExpired},
+		{"other asset", signer, "key-1", "piece.png", signed, now, ErrInvalid},
+		{"other challenge", signer, "key-2", "bg.png", signed, now, ErrInvalid},
+		{"extended exp", signer, "key-1", "bg.png", with("exp", strconv.FormatInt(now.Add(time.Hour).Unix(), 10)), now, ErrInvalid},
+		{"tampered signature", signer, "key-1", "bg.png", with("sig", strings.Repeat("0", len(sig))), now, ErrInvalid},
+		{"truncated signature", signer, "key-1", "bg.png", with("sig", sig[:len(sig)-2]), now, ErrInvalid},
+		{"garbled signature", signer, "key-1", "bg.png", with("sig", "not-hex"), now, ErrInvalid},
+		{"garbled exp", signer, "key-1", "bg.png", with("exp", "soon"), now, ErrInvalid},
+		{"missing signature", signer, "key-1", "bg.png", url.Values{"exp": {signed.Get("exp")}}, now, ErrMissing},
+		{"missing exp", signer, "key-1", "bg.png", url.Values{"sig": {sig}}, now, ErrMissing},
+		{"rotated secret", New([]byte("new-secret"), time.Minute), "key-1", "bg.png", signed, now, ErrInvalid},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.signer.Verify(tc.key, tc.asset, tc.q, tc.now)
+			if !errors.Is(err, tc.want) || (tc.want == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+// Без секрета адреса не подписываются
+func TestNewWithoutSecret(t *testing.T) {
+	if s := New(nil, time.Minute).WithClockSkew(time.Second); s != nil {
+		t.Fatalf("New(nil) = %+v, want nil", s)
+	}
+}
@@ -0,0 +1,107 @@
+// Package attacker реализует известные автоматические атаки на слайдер-пазл и
+// считает, какую долю заданий они решают. Это измеритель для изменений защиты:
+// доля решенных до и после показывает, помогло ли усиление, а не предполагает.
+package attacker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // фон на деградированных ступенях качества
+	_ "image/png"
+
+	"captcha-service/internal/answer"
+	"captcha-service/internal/generator"
+)
+
+// Target — то, что видит атакующий в виджете: фон с дыркой и фрагмент пазла
+type Target struct {
+	Background image.Image
+	Puzzle     image.Image
+	// SliderMax — максимальная координата X левого края пазла
+	SliderMax int
+}
+
+// NewTarget собирает цель из задания, сгенерированного с ленивой загрузкой картинок
+func NewTarget(c *generator.Challenge) (*Target, error) {
+	if c.Kind != generator.KindSlider {
+		return nil, fmt.Errorf("unsupported challenge type %s", c.Kind)
+	}
+	t := &Target{}
+	for _, a := range c.Assets {
+		img, _, err := image.Decode(bytes.NewReader(a.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", a.Name, err)
+		}
+		switch a.Name {
+		case "background":
+			t.Background = img
+		case "puzzle":
+			t.Puzzle = img
+		}
+	}
+	if t.Background == nil || t.Puzzle == nil {
+		return nil, errors.New("challenge has no image assets: generator must run with AssetBaseURL")
+	}
+	t.SliderMax = t.Background.Bounds().Dx() - t.Puzzle.Bounds().Dx()
+	return t, nil
+}
+
+// Attack — одна стратегия атаки. Solve возвращает догадки о координате X в порядке
+// попыток; сервис принимает одну попытку на задание, лишние имеют смысл
+// только для оценки, насколько опасен повтор попыток.
+type Attack interface {
+	Name() string
+	Solve(t *Target) []float64
+}
+
+// Result — итог атаки по всем заданиям
+type Result struct {
+	Attack string
+	Trials int
+	Solved int
+	// FirstTry — сколько заданий решено первой же попыткой
+	FirstTry int
+}
+
+// Rate — доля решенных заданий
+func (r Result) Rate() float64 {
+	if r.Trials == 0 {
+		return 0
+	}
+	return float64(r.Solved) / float64(r.Trials)
+}
+
+// Run генерирует trials заданий и прогоняет на каждом все атаки.
+// Ответ засчитывается по тем же правилам допуска, что и на инстансе.
+func Run(generate func() (*generator.Challenge, error), attacks []Attack, trials, complexity int) ([]Result, error) {
+	results := make([]Result, len(attacks))
+	for i, a := range attacks {
+		results[i].Attack = a.Name()
+	}
+	tolerance := answer.Tolerance(complexity)
+	for range trials {
+		c, err := generate()
+		if err != nil {
+			return nil, err
+		}
+		t, err := NewTarget(c)
+		if err != nil {
+			return nil, err
+		}
+		for i, a := range attacks {
+			results[i].Trials++
+			for n, x := range a.Solve(t) {
+				if ok, _ := answer.Within(x, float64(c.X), tolerance, c.Step); ok {
+					results[i].Solved++
+					if n == 0 {
+						results[i].FirstTry++
+					}
+					break
+				}
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,111 @@
+package attacker
+
+import (
+	"image"
+	"math/rand"
+)
+
+// Дырка — полупрозрачный черный: поверх белой страницы это ровный серый.
+// holeLow и holeHigh — диапазон его яркости (из 0xffff), holeSpread — допустимый разброс каналов.
+const (
+	holeLow    = 0x6000
+	holeHigh   = 0xa000
+	holeSpread = 0x0800
+)
+
+// HoleMatch ищет дырку сопоставлением с шаблоном: окно размером с фрагмент,
+// в котором больше всего пикселей цвета дырки. Так работают типовые солверы
+// на OpenCV: дырка рисуется одноцветной и выделяется на любом фоне.
+type HoleMatch struct{}
+
+func (HoleMatch) Name() string { return "hole-template-match" }
+
+func (HoleMatch) Solve(t *Target) []float64 {
+	return []float64{float64(matchHole(t))}
+}
+
+// matchHole возвращает X окна с максимумом пикселей дырки (через интегральное изображение)
+func matchHole(t *Target) int {
+	b := t.Background.Bounds()
+	w, h := b.Dx(), b.Dy()
+	pw, ph := t.Puzzle.Bounds().Dx(), t.Puzzle.Bounds().Dy()
+
+	// sum[y][x] — число пикселей дырки в прямоугольнике [0,x)×[0,y)
+	sum := make([][]int, h+1)
+	sum[0] = make([]int, w+1)
+	for y := 1; y <= h; y++ {
+		sum[y] = make([]int, w+1)
+		row := 0
+		for x := 1; x <= w; x++ {
+			if isHole(t.Background, b.Min.X+x-1, b.Min.Y+y-1) {
+				row++
+			}
+			sum[y][x] = sum[y-1][x] + row
+		}
+	}
+
+	bestX, best := 0, -1
+	for y := 0; y+ph <= h; y++ {
+		for x := 0; x+pw <= w && x <= t.SliderMax; x++ {
+			n := sum[y+ph][x+pw] - sum[y][x+pw] - sum[y+ph][x] + sum[y][x]
+			if n > best {
+				best, bestX = n, x
+			}
+		}
+	}
+	return bestX
+}
+
+// isHole сообщает, похож ли пиксель, наложенный на белый фон страницы, на цвет дырки
+func isHole(img image.Image, x, y int) bool {
+	r, g, b, a := img.At(x, y).RGBA()
+	// RGBA возвращает цвет, умноженный на альфу: досыпаем белый под прозрачную часть
+	white := 0xffff - a
+	r, g, b = r+white, g+white, b+white
+	lo, hi := min(r, g, b), max(r, g, b)
+	return hi-lo <= holeSpread && lo >= holeLow && hi <= holeHigh
+}
+
+// Sweep перебирает X равномерно по всей длине слайдера без анализа картинки.
+// Attempts = 1 — честная оценка угадывания: сервис дает одну попытку на задание.
+type Sweep struct {
+	Attempts int
+}
+
+func (Sweep) Name() string { return "brute-force-sweep" }
+
+func (s Sweep) Solve(t *Target) []float64 {
+	n := max(s.Attempts, 1)
+	span := float64(t.SliderMax) / float64(n)
+	guesses := make([]float64, n)
+	offset := rand.Float64()
+	for i := range guesses {
+		guesses[i] = (float64(i) + offset) * span
+	}
+	rand.Shuffle(n, func(i, j int) { guesses[i], guesses[j] = guesses[j], guesses[i] })
+	return guesses
+}
+
+// ConstantDrag имитирует бота, который тянет слайдер с постоянной скоростью и
+// отпускает его на первом кадре за найденной дыркой. Сервис видит только итоговую
+// координату, поэтому доля решенных, близкая к HoleMatch, означает, что траектория
+// перетаскивания никак не проверяется.
+type ConstantDrag struct {
+	// PixelsPerFrame — смещение ползунка за кадр (при 60 fps)
+	PixelsPerFrame float64
+}
+
+func (ConstantDrag) Name() string { return "constant-velocity-drag" }
+
+func (d ConstantDrag) Solve(t *Target) []float64 {
+	v := d.PixelsPerFrame
+	if v <= 0 {
+		v = 4
+	}
+	target := float64(matchHole(t))
+	x := 0.0
+	for x < target && x < float64(t.SliderMax) {
+		x += v
+	}
+	return []float64{min(x, float64(t.SliderMax))}
+}
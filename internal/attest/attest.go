@@ -0,0 +1,101 @@
+// Package attest проверяет токены аттестации платформы клиента (Apple Private
+// Access Tokens, Play Integrity и т.п.). Клиент с подтвержденной аттестацией
+// получает токен результата без визуального задания. Проверка конкретного
+// формата делегируется верификатору провайдера: встроенный Webhook отдает токен
+// внешнему сервису, другие реализации регистрируются через Registry.
+package attest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownProvider — для провайдера не зарегистрирован верификатор
+	ErrUnknownProvider = errors.New("unknown attestation provider")
+	// ErrRejected — верификатор отклонил токен
+	ErrRejected = errors.New("attestation rejected")
+)
+
+// Request — токен аттестации вместе с контекстом запроса задания
+type Request struct {
+	Provider string
+	Token    []byte
+	SiteKey  string
+	Action   string
+}
+
+// Result — подтвержденная аттестация
+type Result struct {
+	// Confidence — уверенность (0–100), с которой выдается токен результата;
+	// 0 — верификатор ее не оценивает, используется значение по умолчанию
+	Confidence int32
+	// Subject — идентификатор, который верификатор сообщил для логов (issuer, package name)
+	Subject string
+}
+
+// Verifier проверяет токены одного провайдера. Ошибка, обернутая в ErrRejected,
+// означает невалидный токен; остальные ошибки — сбой проверки.
+type Verifier interface {
+	Verify(ctx context.Context, req Request) (Result, error)
+}
+
+// VerifierFunc позволяет использовать функцию как Verifier
+type VerifierFunc func(ctx context.Context, req Request) (Result, error)
+
+func (f VerifierFunc) Verify(ctx context.Context, req Request) (Result, error) {
+	return f(ctx, req)
+}
+
+// Registry — верификаторы по именам провайдеров
+type Registry struct {
+	mu        sync.RWMutex
+	verifiers map[string]Verifier
+}
+
+func NewRegistry() *Registry {
+	return &Registry{verifiers: make(map[string]Verifier)}
+}
+
+// Register подключает верификатор провайдера, заменяя прежний
+func (r *Registry) Register(provider string, v Verifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifiers[provider] = v
+}
+
+// Providers — имена подключенных провайдеров
+func (r *Registry) Providers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.verifiers))
+	for name := range r.verifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verify проверяет токен верификатором его провайдера
+func (r *Registry) Verify(ctx context.Context, req Request) (Result, error) {
+	r.mu.RLock()
+	v, ok := r.verifiers[req.Provider]
+	r.mu.RUnlock()
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownProvider, req.Provider)
+	}
+	if len(req.Token) == 0 {
+		return Result{}, fmt.Errorf("%w: empty token", ErrRejected)
+	}
+	res, err := v.Verify(ctx, req)
+	if err != nil {
+		return Result{}, err
+	}
+	if res.Confidence < 0 || res.Confidence > 100 {
+		return Result{}, fmt.Errorf("verifier for %q returned confidence %d out of range 0-100", req.Provider, res.Confidence)
+	}
+	return res, nil
+}
@@ -0,0 +1,76 @@
+package attest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxWebhookResponse — предел ответа верификатора
+const maxWebhookResponse = 64 << 10
+
+// Webhook отдает токен внешнему сервису проверки: POST с JSON
+// {"provider", "token" (base64), "site_key", "action"} и ответ
+// {"valid": true, "confidence": 100, "subject": "...", "reason": "..."}.
+// Так подключаются проверки, требующие ключей и SDK провайдера
+// (расшифровка вердикта Play Integrity, проверка подписи issuer'а PAT).
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook создает верификатор с таймаутом одного запроса timeout
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+type webhookRequest struct {
+	Provider string `json:"provider"`
+	Token    []byte `json:"token"`
+	SiteKey  string `json:"site_key"`
+	Action   string `json:"action"`
+}
+
+type webhookResponse struct {
+	Valid      bool   `json:"valid"`
+	Confidence int32  `json:"confidence"`
+	Subject    string `json:"subject"`
+	Reason     string `json:"reason"`
+}
+
+func (w *Webhook) Verify(ctx context.Context, req Request) (Result, error) {
+	body, err := json.Marshal(webhookRequest{
+		Provider: req.Provider,
+		Token:    req.Token,
+		SiteKey:  req.SiteKey,
+		Action:   req.Action,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to build attestation request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := w.Client.Do(httpReq)
+	if err != nil {
+		return Result{}, fmt.Errorf("attestation verifier is unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("attestation verifier returned %s", resp.Status)
+	}
+	var res webhookResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWebhookResponse)).Decode(&res); err != nil {
+		return Result{}, fmt.Errorf("failed to decode attestation verifier response: %w", err)
+	}
+	if !res.Valid {
+		return Result{}, fmt.Errorf("%w: %s", ErrRejected, res.Reason)
+	}
+	return Result{Confidence: res.Confidence, Subject: res.Subject}, nil
+}
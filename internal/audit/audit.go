@@ -0,0 +1,149 @@
+// Package audit пишет журнал заданий и проверок (JSONL) для разбора
+// инцидентов. Каждая запись несет ID инстанса и его монотонный порядковый
+// номер: записи одного инстанса полностью упорядочены по seq, даже если его
+// часы сбиты или переводились во время инцидента.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// События журнала
+const (
+	// EventIssued — задание выдано клиенту
+	EventIssued = "issued"
+	// EventPassed — клиент пропущен без задания (allowlist, невидимый режим, аттестация)
+	EventPassed = "passed_without_challenge"
+	// EventVerified — ответ на задание проверен; Result — passed или failed
+	EventVerified = "verified"
+	// EventRejected — ответ не проверялся; Result — причина (привязка, квота)
+	EventRejected = "rejected"
+	// EventAssessed — токен результата погашен; Result — решение Assess
+	EventAssessed = "assessed"
+)
+
+// Record — запись журнала
+type Record struct {
+	// Seq — номер записи на инстансе Instance, без пропусков и повторов
+	Seq      uint64 `json:"seq"`
+	Instance string `json:"instance"`
+	// UptimeMs — время от открытия журнала по монотонным часам процесса: в
+	// отличие от Time не прыгает при переводе часов
+	UptimeMs int64     `json:"uptime_ms"`
+	Time     time.Time `json:"time"`
+
+	Event       string `json:"event"`
+	ChallengeID string `json:"challenge_id"`
+	SiteKey     string `json:"site_key,omitempty"`
+	Action      string `json:"action,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Complexity  int    `json:"complexity,omitempty"`
+	// Client — адрес клиента, уже обезличенный по настройкам логов
+	Client     string `json:"client,omitempty"`
+	Confidence int32  `json:"confidence,omitempty"`
+	Result     string `json:"result,omitempty"`
+}
+
+// Log — журнал одного инстанса
+type Log struct {
+	instance func() string
+	start    time.Time
+
+	mu  sync.Mutex
+	w   io.Writer
+	seq uint64
+	// path и file — журнал в файле, который можно ротировать (Open)
+	path string
+	file *os.File
+	// written — в текущий файл есть записи с последней ротации
+	written bool
+}
+
+// New создает журнал, пишущий в w; instance возвращает ID инстанса для записей
+func New(w io.Writer, instance func() string) *Log {
+	return &Log{w: w, instance: instance, start: time.Now()}
+}
+
+// Open создает журнал в файле path (дописывая существующий); такой журнал
+// ротируется Rotate
+func Open(path string, instance func() string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := New(f, instance)
+	l.path, l.file = path, f
+	if info, err := f.Stat(); err == nil {
+		l.written = info.Size() > 0
+	}
+	return l, nil
+}
+
+// Close закрывает файл журнала
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// SegmentPattern — glob закрытых сегментов журнала path
+func SegmentPattern(path string) string {
+	return path + ".*"
+}
+
+// Rotate закрывает текущий файл как сегмент path.<время UTC> и начинает
+// новый. Пустой журнал не ротируется: возвращается "".
+func (l *Log) Rotate() (string, error) {
+	if l == nil || l.file == nil {
+		return "", nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.written {
+		return "", nil
+	}
+	segment := fmt.Sprintf("%s.%s", l.path, time.Now().UTC().Format("20060102T150405.000Z"))
+	if err := os.Rename(l.path, segment); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		// Новый файл не создать: возвращаем старый на место и пишем в него дальше
+		os.Rename(segment, l.path)
+		return "", err
+	}
+	l.file.Close()
+	l.w, l.file, l.written = f, f, false
+	return segment, nil
+}
+
+// Write проставляет записи номер, инстанс и время и дописывает ее в журнал.
+// Номер выдается под той же блокировкой, что и запись, поэтому порядок строк
+// в файле совпадает с порядком seq. У nil-журнала ничего не делает.
+func (l *Log) Write(r Record) error {
+	if l == nil {
+		return nil
+	}
+	r.Instance = l.instance()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	r.Seq = l.seq
+	r.Time = time.Now()
+	r.UptimeMs = r.Time.Sub(l.start).Milliseconds()
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = l.w.Write(append(line, '\n'))
+	l.written = true
+	return err
+}
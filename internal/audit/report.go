@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Query — окно отчета: записи тенанта SiteKey (пусто — всех) с Since до Until
+type Query struct {
+	SiteKey string
+	Since   time.Time
+	Until   time.Time
+}
+
+func (q Query) match(r Record) bool {
+	return (q.SiteKey == "" || r.SiteKey == q.SiteKey) &&
+		!r.Time.Before(q.Since) && (q.Until.IsZero() || r.Time.Before(q.Until))
+}
+
+// Scan передает fn записи журнала path и его сегментов, еще не выгруженных в
+// архив, попавшие в окно q. Сегменты, последняя запись которых старше
+// q.Since, не читаются.
+func Scan(path string, q Query, fn func(Record)) error {
+	segments, err := filepath.Glob(SegmentPattern(path))
+	if err != nil {
+		return err
+	}
+	slices.Sort(segments)
+	for _, name := range append(segments, path) {
+		// Выгруженные в архив сегменты (см. archive) уже удалены из горячей истории
+		if strings.HasSuffix(name, ".archived") {
+			continue
+		}
+		if info, err := os.Stat(name); err != nil || info.ModTime().Before(q.Since) {
+			continue
+		}
+		if err := scanFile(name, q, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(name string, q Query, fn func(Record)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Сегмент выгрузили и удалили между Glob и Open
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var r Record
+		if json.Unmarshal(sc.Bytes(), &r) != nil || !q.match(r) {
+			continue
+		}
+		fn(r)
+	}
+	return sc.Err()
+}
+
+// DayRate — задания и проверки за сутки (UTC)
+type DayRate struct {
+	Day    string `json:"day"`
+	Issued int    `json:"issued"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+	// SolveRate — доля пройденных проверок среди проверенных ответов
+	SolveRate float64 `json:"solve_rate"`
+}
+
+// SolveRate — доля решенных заданий по дням
+func SolveRate(path string, q Query) ([]DayRate, error) {
+	days := map[string]*DayRate{}
+	err := Scan(path, q, func(r Record) {
+		day := r.Time.UTC().Format(time.DateOnly)
+		d := days[day]
+		if d == nil {
+			d = &DayRate{Day: day}
+			days[day] = d
+		}
+		switch {
+		case r.Event == EventIssued:
+			d.Issued++
+		case r.Event == EventVerified && r.Result == "passed":
+			d.Passed++
+		case r.Event == EventVerified:
+			d.Failed++
+		}
+	})
+	list := make([]DayRate, 0, len(days))
+	for _, d := range days {
+		if total := d.Passed + d.Failed; total > 0 {
+			d.SolveRate = float64(d.Passed) / float64(total)
+		}
+		list = append(list, *d)
+	}
+	slices.SortFunc(list, func(a, b DayRate) int { return cmp.Compare(a.Day, b.Day) })
+	return list, err
+}
+
+// ClientFailures — неудачи одного клиента. Client обезличен так же, как в
+// логах (LOG_REDACTION): при маскировании это подсеть, а не адрес.
+type ClientFailures struct {
+	Client string `json:"client"`
+	Failed int    `json:"failed"`
+	// Rejected — ответы, отклоненные без проверки (привязка, квота)
+	Rejected int `json:"rejected"`
+	Passed   int `json:"passed"`
+}
+
+// FailingClients — limit клиентов с наибольшим числом неудачных проверок
+func FailingClients(path string, q Query, limit int) ([]ClientFailures, error) {
+	clients := map[string]*ClientFailures{}
+	err := Scan(path, q, func(r Record) {
+		if r.Client == "" || (r.Event != EventVerified && r.Event != EventRejected) {
+			return
+		}
+		c := clients[r.Client]
+		if c == nil {
+			c = &ClientFailures{Client: r.Client}
+			clients[r.Client] = c
+		}
+		switch {
+		case r.Event == EventRejected:
+			c.Rejected++
+		case r.Result == "passed":
+			c.Passed++
+		default:
+			c.Failed++
+		}
+	})
+	list := make([]ClientFailures, 0, len(clients))
+	for _, c := range clients {
+		if c.Failed+c.Rejected > 0 {
+			list = append(list, *c)
+		}
+	}
+	slices.SortFunc(list, func(a, b ClientFailures) int {
+		return cmp.Or(cmp.Compare(b.Failed+b.Rejected, a.Failed+a.Rejected), cmp.Compare(a.Client, b.Client))
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// ActionScore — уверенность проверок одного действия
+type ActionScore struct {
+	Action   string  `json:"action"`
+	Verified int     `json:"verified"`
+	PassRate float64 `json:"pass_rate"`
+	// AvgConfidence и MedianConfidence — по всем проверенным ответам, включая неудачные
+	AvgConfidence    float64 `json:"avg_confidence"`
+	MedianConfidence int32   `json:"median_confidence"`
+}
+
+// ActionScores — уверенность проверок по действиям
+func ActionScores(path string, q Query) ([]ActionScore, error) {
+	scores := map[string][]int32{}
+	passed := map[string]int{}
+	err := Scan(path, q, func(r Record) {
+		if r.Event != EventVerified {
+			return
+		}
+		scores[r.Action] = append(scores[r.Action], r.Confidence)
+		if r.Result == "passed" {
+			passed[r.Action]++
+		}
+	})
+	list := make([]ActionScore, 0, len(scores))
+	for action, values := range scores {
+		slices.Sort(values)
+		var sum int64
+		for _, v := range values {
+			sum += int64(v)
+		}
+		list = append(list, ActionScore{
+			Action:           action,
+			Verified:         len(values),
+			PassRate:         float64(passed[action]) / float64(len(values)),
+			AvgConfidence:    float64(sum) / float64(len(values)),
+			MedianConfidence: values[len(values)/2],
+		})
+	}
+	slices.SortFunc(list, func(a, b ActionScore) int { return cmp.Compare(a.Action, b.Action) })
+	return list, err
+}
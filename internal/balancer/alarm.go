@@ -0,0 +1,155 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Алерты, которые CapacityAlarm отправляет в вебхуки
+const (
+	AlertCapacityAtRisk   = "capacity_at_risk"
+	AlertCapacityRestored = "capacity_restored"
+)
+
+// CapacityStatus — здоровые инстансы типа заданий против заданного минимума
+type CapacityStatus struct {
+	ChallengeType string `json:"challenge_type"`
+	Ready         int    `json:"ready"`
+	Min           int    `json:"min"`
+	AtRisk        bool   `json:"at_risk"`
+	// Since — когда тип перешел в текущее состояние
+	Since time.Time `json:"since"`
+}
+
+// Alert — тело POST в вебхук при переходе типа в at risk и обратно
+type Alert struct {
+	Alert string `json:"alert"`
+	CapacityStatus
+}
+
+// CapacityAlarm следит, чтобы у каждого типа заданий было не меньше
+// минимального числа READY-инстансов, и сообщает вебхукам о нехватке до того,
+// как ее заметят пользователи. Алерт уходит только при смене состояния.
+type CapacityAlarm struct {
+	registry *Registry
+	min      map[string]int
+	webhooks []string
+	client   *http.Client
+	// grace — после старта балансера инстансы еще регистрируются: нехватка не считается
+	grace   time.Duration
+	started time.Time
+
+	// OnCheck получает состояние всех типов после каждой проверки (для метрик)
+	OnCheck func([]CapacityStatus)
+
+	mu     sync.Mutex
+	status map[string]CapacityStatus
+}
+
+// NewCapacityAlarm создает проверку минимумов min (тип заданий -> READY-инстансов)
+func NewCapacityAlarm(registry *Registry, min map[string]int, webhooks []string, timeout, grace time.Duration) *CapacityAlarm {
+	return &CapacityAlarm{
+		registry: registry,
+		min:      min,
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: timeout},
+		grace:    grace,
+		started:  time.Now(),
+		status:   make(map[string]CapacityStatus),
+	}
+}
+
+// Run проверяет флот каждые interval, пока не отменен ctx
+func (a *CapacityAlarm) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Check()
+		}
+	}
+}
+
+// Check сравнивает READY-инстансы с минимумами и шлет алерты о переходах
+func (a *CapacityAlarm) Check() []CapacityStatus {
+	now := time.Now()
+	pending := a.registry.Pending()
+	inGrace := now.Sub(a.started) < a.grace
+
+	a.mu.Lock()
+	var alerts []Alert
+	for typ, min := range a.min {
+		ready := pending[typ].Instances
+		prev, seen := a.status[typ]
+		st := CapacityStatus{ChallengeType: typ, Ready: ready, Min: min, AtRisk: ready < min && !inGrace, Since: prev.Since}
+		if !seen || st.AtRisk != prev.AtRisk {
+			st.Since = now
+			switch {
+			case st.AtRisk:
+				alerts = append(alerts, Alert{Alert: AlertCapacityAtRisk, CapacityStatus: st})
+			case seen:
+				alerts = append(alerts, Alert{Alert: AlertCapacityRestored, CapacityStatus: st})
+			}
+		}
+		a.status[typ] = st
+	}
+	a.mu.Unlock()
+
+	for _, alert := range alerts {
+		log.Printf("Capacity alert %s: %s has %d ready instances, minimum %d", alert.Alert, alert.ChallengeType, alert.Ready, alert.Min)
+		go a.notify(alert)
+	}
+	list := a.Status()
+	if a.OnCheck != nil {
+		a.OnCheck(list)
+	}
+	return list
+}
+
+// Status возвращает состояние типов на момент последней проверки
+func (a *CapacityAlarm) Status() []CapacityStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]CapacityStatus, 0, len(a.status))
+	for _, st := range a.status {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ChallengeType < list[j].ChallengeType })
+	return list
+}
+
+// notify отправляет алерт во все вебхуки; ошибки только логируются
+func (a *CapacityAlarm) notify(alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Failed to encode capacity alert: %v", err)
+		return
+	}
+	for _, url := range a.webhooks {
+		if err := a.post(url, body); err != nil {
+			log.Printf("Failed to send capacity alert to %s: %v", url, err)
+		}
+	}
+}
+
+func (a *CapacityAlarm) post(url string, body []byte) error {
+	resp, err := a.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
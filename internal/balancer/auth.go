@@ -0,0 +1,166 @@
+package balancer
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ErrUnauthenticated — инстанс не предъявил ни токена регистрации, ни клиентского сертификата
+var ErrUnauthenticated = errors.New("instance is not authenticated: registration token or client certificate required")
+
+// QuarantinedInstance — инстанс, пытавшийся зарегистрироваться без аутентификации
+type QuarantinedInstance struct {
+	ID            string    `json:"id"`
+	ChallengeType string    `json:"challenge_type"`
+	Host          string    `json:"host"`
+	Port          int       `json:"port"`
+	Peer          string    `json:"peer"`
+	Reason        string    `json:"reason"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// Карантин не должен расти без предела: неаутентифицированный клиент может
+// открывать стримы сколько угодно. Сверх quarantineLimit новые инстансы
+// получают отказ, а записи без heartbeat дольше quarantineTTL (несколько
+// пропущенных heartbeat) удаляются.
+const (
+	quarantineLimit = 1024
+	quarantineTTL   = 2 * time.Minute
+)
+
+// ErrQuarantineFull — карантин заполнен, инстанс отключается без записи
+var ErrQuarantineFull = errors.New("quarantine is full")
+
+// RegistrationAuth проверяет, что RPC BalancerService вызывает инстанс флота:
+// по общему токену регистрации (metadata "authorization: Bearer <token>") или по
+// клиентскому сертификату mTLS, прошедшему проверку при рукопожатии. Токенов
+// может быть несколько, чтобы менять их без остановки флота. В режиме
+// карантина неаутентифицированные инстансы не отключаются, а держатся вне
+// маршрутизации и видны оператору.
+type RegistrationAuth struct {
+	tokens     [][]byte
+	mtls       bool
+	quarantine bool
+
+	mu          sync.Mutex
+	quarantined map[string]*QuarantinedInstance
+}
+
+// NewRegistrationAuth создает проверку; без токенов и mTLS регистрация открыта (nil)
+func NewRegistrationAuth(tokens []string, mtls, quarantine bool) *RegistrationAuth {
+	a := &RegistrationAuth{mtls: mtls, quarantine: quarantine, quarantined: make(map[string]*QuarantinedInstance)}
+	for _, t := range tokens {
+		if t != "" {
+			a.tokens = append(a.tokens, []byte(t))
+		}
+	}
+	if len(a.tokens) == 0 && !mtls {
+		return nil
+	}
+	return a
+}
+
+// Authenticate проверяет токен или клиентский сертификат вызывающего
+func (a *RegistrationAuth) Authenticate(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	if a.mtls {
+		if p, ok := peer.FromContext(ctx); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+				return nil
+			}
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(v, "Bearer ")
+		if !ok {
+			continue
+		}
+		for _, want := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), want) == 1 {
+				return nil
+			}
+		}
+	}
+	return ErrUnauthenticated
+}
+
+// Quarantines — держать ли неаутентифицированные инстансы в карантине вместо отказа
+func (a *RegistrationAuth) Quarantines() bool {
+	return a != nil && a.quarantine
+}
+
+// Quarantine запоминает (или обновляет) инстанс в карантине. ErrQuarantineFull —
+// места для нового инстанса нет даже после удаления устаревших записей.
+func (a *RegistrationAuth) Quarantine(ctx context.Context, req *balancerpb.RegisterInstanceRequest, reason error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	q, ok := a.quarantined[req.GetInstanceId()]
+	if !ok {
+		if len(a.quarantined) >= quarantineLimit {
+			a.expireLocked(now)
+		}
+		if len(a.quarantined) >= quarantineLimit {
+			return ErrQuarantineFull
+		}
+		q = &QuarantinedInstance{ID: req.GetInstanceId(), FirstSeen: now, Reason: reason.Error()}
+		if p, ok := peer.FromContext(ctx); ok {
+			q.Peer = p.Addr.String()
+		}
+		a.quarantined[q.ID] = q
+		log.Printf("Instance %s at %s:%d (peer %s) quarantined: %v", q.ID, req.GetHost(), req.GetPortNumber(), q.Peer, reason)
+	}
+	q.ChallengeType = req.GetChallengeType()
+	q.Host = req.GetHost()
+	q.Port = int(req.GetPortNumber())
+	q.LastSeen = now
+	return nil
+}
+
+// expireLocked удаляет инстансы, давно не присылавшие heartbeat
+func (a *RegistrationAuth) expireLocked(now time.Time) {
+	for id, q := range a.quarantined {
+		if now.Sub(q.LastSeen) > quarantineTTL {
+			delete(a.quarantined, id)
+		}
+	}
+}
+
+// Release убирает отключившийся инстанс из карантина
+func (a *RegistrationAuth) Release(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.quarantined, id)
+}
+
+// Quarantined возвращает инстансы в карантине
+func (a *RegistrationAuth) Quarantined() []QuarantinedInstance {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expireLocked(time.Now())
+	list := make([]QuarantinedInstance, 0, len(a.quarantined))
+	for _, q := range a.quarantined {
+		list = append(list, *q)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].FirstSeen.Before(list[j].FirstSeen) })
+	return list
+}
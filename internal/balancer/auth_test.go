@@ -0,0 +1,132 @@
+package balancer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// withToken — входящий вызов с заголовком authorization
+func withToken(value string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", value))
+}
+
+func TestRegistrationAuthOpen(t *testing.T) {
+	a := NewRegistrationAuth([]string{"", ""}, false, true)
+	if a != nil {
+		t.Fatalf("auth without tokens and mTLS = %+v, want nil (open)", a)
+	}
+	if err := a.Authenticate(context.Background()); err != nil {
+		t.Fatalf("open registration rejected a caller: %v", err)
+	}
+	if a.Quarantines() || a.Quarantined() != nil {
+		t.Fatal("open registration quarantines instances")
+	}
+}
+
+func TestRegistrationAuthenticate(t *testing.T) {
+	// Два токена: старый и новый действуют одновременно на время ротации
+	a := NewRegistrationAuth([]string{"old-token", "new-token"}, true, false)
+	verified := peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}},
+	})
+	unverified := peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000},
+		AuthInfo: credentials.TLSInfo{},
+	})
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		ok   bool
+	}{
+		{"current token", withToken("Bearer new-token"), true},
+		{"previous token", withToken("Bearer old-token"), true},
+		{"wrong token", withToken("Bearer other-token"), false},
+		{"token prefix", withToken("Bearer new-tok"), false},
+		{"no bearer scheme", withToken("new-token"), false},
+		{"no metadata", context.Background(), false},
+		{"verified client certificate", verified, true},
+		{"unverified client certificate", unverified, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := a.Authenticate(tc.ctx)
+			if tc.ok != (err == nil) {
+				t.Fatalf("Authenticate: %v, want ok=%v", err, tc.ok)
+			}
+			if err != nil && !errors.Is(err, ErrUnauthenticated) {
+				t.Fatalf("Authenticate: %v, want ErrUnauthenticated", err)
+			}
+		})
+	}
+	// Без mTLS сертификат не заменяет токен
+	if err := NewRegistrationAuth([]string{"new-token"}, false, false).Authenticate(verified); err == nil {
+		t.Fatal("client certificate accepted with mTLS disabled")
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	a := NewRegistrationAuth([]string{"token"}, false, true)
+	if !a.Quarantines() {
+		t.Fatal("quarantine mode is off")
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 6000}})
+	req := &balancerpb.RegisterInstanceRequest{InstanceId: "rogue", Host: "10.0.0.2", PortNumber: 38000}
+	if err := a.Quarantine(ctx, req, ErrUnauthenticated); err != nil {
+		t.Fatal(err)
+	}
+	// Heartbeat обновляет запись, а не добавляет новую
+	req.PortNumber = 38001
+	if err := a.Quarantine(ctx, req, ErrUnauthenticated); err != nil {
+		t.Fatal(err)
+	}
+	list := a.Quarantined()
+	if len(list) != 1 || list[0].Port != 38001 || list[0].Peer != "10.0.0.2:6000" || list[0].Reason != ErrUnauthenticated.Error() {
+		t.Fatalf("quarantined = %+v", list)
+	}
+	a.Release("rogue")
+	if list := a.Quarantined(); len(list) != 0 {
+		t.Fatalf("released instance still quarantined: %+v", list)
+	}
+}
+
+// Карантин ограничен: сверх лимита новые инстансы получают отказ, пока
+// устаревшие записи не освободят место
+func TestQuarantineLimit(t *testing.T) {
+	a := NewRegistrationAuth([]string{"token"}, false, true)
+	ctx := context.Background()
+	for i := range quarantineLimit {
+		if err := a.Quarantine(ctx, &balancerpb.RegisterInstanceRequest{InstanceId: fmt.Sprint("rogue-", i)}, ErrUnauthenticated); err != nil {
+			t.Fatalf("instance %d: %v", i, err)
+		}
+	}
+	extra := &balancerpb.RegisterInstanceRequest{InstanceId: "rogue-extra"}
+	if err := a.Quarantine(ctx, extra, ErrUnauthenticated); !errors.Is(err, ErrQuarantineFull) {
+		t.Fatalf("over the limit: %v, want ErrQuarantineFull", err)
+	}
+	// Уже известный инстанс обновляется и при заполненном карантине
+	if err := a.Quarantine(ctx, &balancerpb.RegisterInstanceRequest{InstanceId: "rogue-0"}, ErrUnauthenticated); err != nil {
+		t.Fatalf("heartbeat of a quarantined instance: %v", err)
+	}
+
+	a.mu.Lock()
+	a.quarantined["rogue-1"].LastSeen = time.Now().Add(-quarantineTTL - time.Second)
+	a.mu.Unlock()
+	if err := a.Quarantine(ctx, extra, ErrUnauthenticated); err != nil {
+		t.Fatalf("after a stale entry expired: %v", err)
+	}
+	if n := len(a.Quarantined()); n != quarantineLimit {
+		t.Fatalf("%d quarantined, want %d", n, quarantineLimit)
+	}
+}
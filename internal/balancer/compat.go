@@ -0,0 +1,113 @@
+package balancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	balancerpb "captcha-service/api/balancer/v1"
+)
+
+// Совместимость версии протокола инстанса с балансером
+const (
+	// Compatible — инстанс работает как обычно
+	Compatible = "compatible"
+	// Deprecated — инстанс принимается, но помечается: его пора обновить
+	Deprecated = "deprecated"
+	// Incompatible — регистрация отклоняется, уже зарегистрированный инстанс отключается
+	Incompatible = "incompatible"
+)
+
+// CompatibilityMatrix — совместимость версий протокола. Версии, которых нет в
+// Versions, получают Default. Во время выкатки в матрицу добавляют новую
+// версию, а после нее переводят старую в deprecated или incompatible.
+type CompatibilityMatrix struct {
+	Versions map[uint32]string `json:"versions"`
+	Default  string            `json:"default"`
+}
+
+// DefaultCompatibility — своя версия совместима, остальные только помечаются,
+// чтобы смешанный флот во время выкатки продолжал работать
+func DefaultCompatibility() CompatibilityMatrix {
+	return CompatibilityMatrix{
+		Versions: map[uint32]string{balancerpb.ProtocolVersion: Compatible},
+		Default:  Deprecated,
+	}
+}
+
+// Validate проверяет, что все значения матрицы известны
+func (m CompatibilityMatrix) Validate() error {
+	check := func(what, status string) error {
+		switch status {
+		case Compatible, Deprecated, Incompatible:
+			return nil
+		}
+		return fmt.Errorf("%s: unknown compatibility %q (want %s, %s or %s)", what, status, Compatible, Deprecated, Incompatible)
+	}
+	if err := check("default", m.Default); err != nil {
+		return err
+	}
+	for v, status := range m.Versions {
+		if err := check("version "+strconv.FormatUint(uint64(v), 10), status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status возвращает совместимость версии протокола
+func (m CompatibilityMatrix) Status(version uint32) string {
+	if status, ok := m.Versions[version]; ok {
+		return status
+	}
+	return m.Default
+}
+
+// ParseCompatibility разбирает матрицу из JSON вида
+// {"versions": {"1": "compatible", "0": "deprecated"}, "default": "incompatible"}
+func ParseCompatibility(data []byte) (CompatibilityMatrix, error) {
+	var m CompatibilityMatrix
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("invalid compatibility matrix: %w", err)
+	}
+	if m.Default == "" {
+		m.Default = Deprecated
+	}
+	return m, m.Validate()
+}
+
+// Compatibility — матрица, которую можно менять на лету; проверка идет на
+// каждом сообщении регистрации, поэтому новая матрица действует с ближайшего heartbeat
+type Compatibility struct {
+	current atomic.Pointer[CompatibilityMatrix]
+}
+
+// NewCompatibility создает проверку с начальной матрицей m
+func NewCompatibility(m CompatibilityMatrix) *Compatibility {
+	c := &Compatibility{}
+	c.current.Store(&m)
+	return c
+}
+
+// Set заменяет матрицу
+func (c *Compatibility) Set(m CompatibilityMatrix) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	c.current.Store(&m)
+	return nil
+}
+
+// Matrix возвращает действующую матрицу
+func (c *Compatibility) Matrix() CompatibilityMatrix {
+	return *c.current.Load()
+}
+
+// Status — совместимость версии; без проверки (nil) все версии совместимы
+func (c *Compatibility) Status(version uint32) string {
+	if c == nil {
+		return Compatible
+	}
+	return c.current.Load().Status(version)
+}
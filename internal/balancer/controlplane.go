@@ -0,0 +1,104 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ControlPlane хранит текущую конфигурацию флота и раздает ее обновления
+// всем инстансам через обратный канал стрима регистрации
+type ControlPlane struct {
+	mu          sync.Mutex
+	current     *balancerpb.InstanceConfig
+	subscribers map[string]chan *balancerpb.InstanceConfig
+}
+
+// NewControlPlane создает control plane с пустой конфигурацией версии 0 новой
+// эпохи: время старта отличает конфигурации перезапущенного балансера
+func NewControlPlane() *ControlPlane {
+	return &ControlPlane{
+		current:     &balancerpb.InstanceConfig{Epoch: time.Now().UnixNano()},
+		subscribers: make(map[string]chan *balancerpb.InstanceConfig),
+	}
+}
+
+// Current возвращает копию текущей конфигурации
+func (c *ControlPlane) Current() *balancerpb.InstanceConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return proto.Clone(c.current).(*balancerpb.InstanceConfig)
+}
+
+// Set публикует новую конфигурацию с увеличенной версией и рассылает ее подписчикам.
+// Если в cfg нет ротации, сохраняется текущая: ее ведет планировщик. Версия
+// настроек тенантов не откатывается: ее сообщают сами инстансы.
+func (c *ControlPlane) Set(cfg *balancerpb.InstanceConfig) *balancerpb.InstanceConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := proto.Clone(cfg).(*balancerpb.InstanceConfig)
+	if next.Rotation == nil {
+		next.Rotation = c.current.GetRotation()
+	}
+	next.SettingsVersion = max(next.GetSettingsVersion(), c.current.GetSettingsVersion())
+	return c.publishLocked(next)
+}
+
+// NoteSettingsVersion оповещает инстансы о новой версии настроек тенантов,
+// примененной одним из них; не новее известной — ничего не рассылает
+func (c *ControlPlane) NoteSettingsVersion(version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version <= c.current.GetSettingsVersion() {
+		return
+	}
+	next := proto.Clone(c.current).(*balancerpb.InstanceConfig)
+	next.SettingsVersion = version
+	c.publishLocked(next)
+}
+
+// SetRotation публикует текущую конфигурацию с новой ротацией
+func (c *ControlPlane) SetRotation(rotation *balancerpb.Rotation) *balancerpb.InstanceConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := proto.Clone(c.current).(*balancerpb.InstanceConfig)
+	next.Rotation = rotation
+	return c.publishLocked(next)
+}
+
+func (c *ControlPlane) publishLocked(next *balancerpb.InstanceConfig) *balancerpb.InstanceConfig {
+	next.Version = c.current.GetVersion() + 1
+	next.Epoch = c.current.GetEpoch()
+	c.current = next
+	for _, ch := range c.subscribers {
+		// Медленному подписчику важна только последняя версия: старую выбрасываем
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- proto.Clone(next).(*balancerpb.InstanceConfig)
+	}
+	return proto.Clone(next).(*balancerpb.InstanceConfig)
+}
+
+// Subscribe подписывает инстанс на обновления; текущая конфигурация
+// (если она уже задавалась) приходит в канал сразу
+func (c *ControlPlane) Subscribe(instanceID string) (<-chan *balancerpb.InstanceConfig, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan *balancerpb.InstanceConfig, 1)
+	if c.current.GetVersion() > 0 {
+		ch <- proto.Clone(c.current).(*balancerpb.InstanceConfig)
+	}
+	c.subscribers[instanceID] = ch
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.subscribers[instanceID] == ch {
+			delete(c.subscribers, instanceID)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	captchapb "captcha-service/api/captcha/v1"
+	_ "captcha-service/internal/grpczstd" // регистрирует zstd
+	"captcha-service/internal/iplist"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // регистрирует gzip
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Proxy реализует CaptchaService на стороне балансера: выдает задания через
+// READY-инстансы и проксирует события стрима на инстанс, выдавший задание.
+type Proxy struct {
+	captchapb.UnimplementedCaptchaServiceServer
+	registry *Registry
+	// compressor — gRPC-компрессор для ChallengeResponse клиенту; пусто — без сжатия
+	compressor string
+	// trusted — прокси перед балансером, которым разрешено передавать адрес и
+	// страну клиента; у остальных вызывающих они заменяются адресом соединения
+	trusted iplist.Proxies
+}
+
+// NewProxy создает прокси поверх реестра инстансов
+func NewProxy(registry *Registry, compressor string, trusted iplist.Proxies) *Proxy {
+	return &Proxy{registry: registry, compressor: compressor, trusted: trusted}
+}
+
+// caller — адрес вызывающего и можно ли верить переданным им адресу и стране клиента
+func (p *Proxy) caller(ctx context.Context) (ip string, trusted bool) {
+	pr, ok := peer.FromContext(ctx)
+	if !ok || pr.Addr == nil {
+		return "", false
+	}
+	ip, _, err := net.SplitHostPort(pr.Addr.String())
+	if err != nil {
+		ip = pr.Addr.String()
+	}
+	return ip, p.trusted.Trusted(ip)
+}
+
+// clientFromPeer заменяет адрес и страну клиента в запросе недоверенного
+// вызывающего адресом его соединения: инстансы верят тому, что передал балансер
+func (p *Proxy) clientFromPeer(ctx context.Context, req *captchapb.ChallengeRequest) {
+	ip, trusted := p.caller(ctx)
+	if trusted {
+		return
+	}
+	if req.Client == nil {
+		req.Client = &captchapb.ClientContext{}
+	}
+	req.Client.Ip, req.Client.Country = ip, ""
+}
+
+// compressResponse сжимает ответ клиенту, если он поддерживает компрессор прокси
+func (p *Proxy) compressResponse(ctx context.Context) {
+	if p.compressor == "" {
+		return
+	}
+	if supported, err := grpc.ClientSupportedCompressors(ctx); err == nil && slices.Contains(supported, p.compressor) {
+		grpc.SetSendCompressor(ctx, p.compressor)
+	}
+}
+
+// NewChallenge запрашивает задание у очередного READY-инстанса и запоминает маршрут
+func (p *Proxy) NewChallenge(ctx context.Context, req *captchapb.ChallengeRequest) (*captchapb.ChallengeResponse, error) {
+	p.clientFromPeer(ctx, req)
+	inst, err := p.registry.PickForNewChallenge()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	res, err := inst.Client.NewChallenge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.registry.Remember(res.GetChallengeId(), inst.ID)
+	p.compressResponse(ctx)
+	return res, nil
+}
+
+// PrewarmChallenge резервирует задание на READY-инстансе и запоминает маршрут
+func (p *Proxy) PrewarmChallenge(ctx context.Context, req *captchapb.ChallengeRequest) (*captchapb.ChallengeHandle, error) {
+	p.clientFromPeer(ctx, req)
+	inst, err := p.registry.PickForNewChallenge()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	res, err := inst.Client.PrewarmChallenge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.registry.Remember(res.GetChallengeId(), inst.ID)
+	return res, nil
+}
+
+// GetChallenge забирает prewarm-задание с инстанса, где оно рисуется
+func (p *Proxy) GetChallenge(ctx context.Context, req *captchapb.ChallengeHandle) (*captchapb.ChallengeResponse, error) {
+	inst, ok := p.registry.Route(req.GetChallengeId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "prewarmed challenge not found, expired or already fetched")
+	}
+	res, err := inst.Client.GetChallenge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.compressResponse(ctx)
+	return res, nil
+}
+
+// GetChallengeAssets проксирует стрим картинок с инстанса, выдавшего задание
+func (p *Proxy) GetChallengeAssets(req *captchapb.ChallengeAssetsRequest, stream captchapb.CaptchaService_GetChallengeAssetsServer) error {
+	inst, ok := p.registry.Route(req.GetChallengeId())
+	if !ok {
+		return status.Error(codes.NotFound, "challenge route not found")
+	}
+	upstream, err := inst.Client.GetChallengeAssets(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := upstream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// GetChallengeResult спрашивает итог задания у инстанса, который его выдал
+func (p *Proxy) GetChallengeResult(ctx context.Context, req *captchapb.ChallengeResultRequest) (*captchapb.ChallengeResultResponse, error) {
+	inst, ok := p.registry.Route(req.GetChallengeId())
+	if !ok {
+		return &captchapb.ChallengeResultResponse{
+			ChallengeId: req.GetChallengeId(),
+			Status:      captchapb.ChallengeResultResponse_NOT_FOUND,
+		}, nil
+	}
+	return inst.Client.GetChallengeResult(ctx, req)
+}
+
+// Assess направляет проверку токена на инстанс, выдавший задание:
+// токен начинается с challenge_id
+func (p *Proxy) Assess(ctx context.Context, req *captchapb.AssessRequest) (*captchapb.AssessResponse, error) {
+	challengeID, _, _ := strings.Cut(req.GetToken(), ".")
+	inst, ok := p.registry.Route(challengeID)
+	if !ok {
+		return &captchapb.AssessResponse{
+			Decision: captchapb.AssessResponse_DENY,
+			Action:   req.GetAction(),
+			Reason:   "token is invalid, expired or already used",
+		}, nil
+	}
+	return inst.Client.Assess(ctx, req)
+}
+
+// MakeEventStream открывает по одному upstream-стриму на каждый инстанс, к которому
+// относятся задания клиента, и пересылает ответы инстансов обратно клиенту
+func (p *Proxy) MakeEventStream(stream captchapb.CaptchaService_MakeEventStreamServer) error {
+	session := &proxySession{
+		downstream: stream,
+		upstreams:  make(map[string]captchapb.CaptchaService_MakeEventStreamClient),
+	}
+	defer session.closeAll()
+	ip, trusted := p.caller(stream.Context())
+
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !trusted {
+			event.ClientIp, event.Country = ip, ""
+		}
+		if event.GetEventType() == captchapb.ClientEvent_HELLO {
+			p.hello(session, event)
+			continue
+		}
+
+		inst, ok := p.registry.Route(event.GetChallengeId())
+		if !ok {
+			// Маршрут истек или инстанс остановлен — просим клиента взять новое задание
+			session.send(&captchapb.ServerEvent{
+				Event: &captchapb.ServerEvent_Control{Control: &captchapb.ServerEvent_ControlMessage{
+					Kind:        captchapb.ServerEvent_ControlMessage_REFRESH,
+					ChallengeId: event.GetChallengeId(),
+					Message:     "challenge route not found",
+				}},
+			})
+			continue
+		}
+
+		upstream, err := session.upstream(inst)
+		if err != nil {
+			log.Printf("Failed to open event stream to instance %s: %v", inst.ID, err)
+			continue
+		}
+		if err := upstream.Send(event); err != nil {
+			log.Printf("Failed to forward event to instance %s: %v", inst.ID, err)
+			continue
+		}
+		// Продлеваем маршрут: по нему еще пойдет Assess с токеном результата
+		p.registry.Remember(event.GetChallengeId(), inst.ID)
+	}
+}
+
+// hello передает HELLO одному READY-инстансу: схему ответа выбирают инстансы,
+// и их ответ Negotiated возвращается клиенту обычной пересылкой
+func (p *Proxy) hello(session *proxySession, event *captchapb.ClientEvent) {
+	inst, err := p.registry.PickForNewChallenge()
+	if err != nil {
+		log.Printf("No instance to negotiate widget capabilities with: %v", err)
+		return
+	}
+	upstream, err := session.upstream(inst)
+	if err != nil {
+		log.Printf("Failed to open event stream to instance %s: %v", inst.ID, err)
+		return
+	}
+	if err := upstream.Send(event); err != nil {
+		log.Printf("Failed to forward HELLO to instance %s: %v", inst.ID, err)
+	}
+}
+
+// proxySession — состояние одного клиентского стрима
+type proxySession struct {
+	downstream captchapb.CaptchaService_MakeEventStreamServer
+	sendMu     sync.Mutex
+
+	mu        sync.Mutex
+	upstreams map[string]captchapb.CaptchaService_MakeEventStreamClient
+	wg        sync.WaitGroup
+}
+
+func (s *proxySession) send(event *captchapb.ServerEvent) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if err := s.downstream.Send(event); err != nil {
+		log.Printf("Failed to send event to client: %v", err)
+	}
+}
+
+// upstream возвращает (или открывает) стрим к инстансу и запускает пересылку его ответов
+func (s *proxySession) upstream(inst *Instance) (captchapb.CaptchaService_MakeEventStreamClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if up, ok := s.upstreams[inst.ID]; ok {
+		return up, nil
+	}
+	up, err := inst.Client.MakeEventStream(s.downstream.Context())
+	if err != nil {
+		return nil, err
+	}
+	s.upstreams[inst.ID] = up
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			event, err := up.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
+					log.Printf("Event stream from instance %s closed: %v", inst.ID, err)
+				}
+				s.mu.Lock()
+				delete(s.upstreams, inst.ID)
+				s.mu.Unlock()
+				return
+			}
+			s.send(event)
+		}
+	}()
+	return up, nil
+}
+
+func (s *proxySession) closeAll() {
+	s.mu.Lock()
+	for _, up := range s.upstreams {
+		up.CloseSend()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
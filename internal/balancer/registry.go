@@ -0,0 +1,324 @@
+// Package balancer — реестр инстансов капчи и маршрутизация запросов к ним.
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+	captchapb "captcha-service/api/captcha/v1"
+
+	"github.com/patrickmn/go-cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ErrNoInstances — нет ни одного инстанса, готового выдать задание
+var ErrNoInstances = errors.New("no ready captcha instances")
+
+// Instance — зарегистрированный инстанс капчи и соединение с ним
+type Instance struct {
+	ID            string
+	ChallengeType string
+	Host          string
+	Port          int
+	// Region и Zone — размещение инстанса; пусто — неизвестно
+	Region   string
+	Zone     string
+	State    balancerpb.RegisterInstanceRequest_EventType
+	LastSeen time.Time
+	// Stats — статистика генерации из последнего heartbeat
+	Stats []*balancerpb.ComplexityStats
+	// PendingChallenges — выданные и не решенные задания по последнему heartbeat
+	PendingChallenges int
+	// CapacityPercent — доступная мощность инстанса (100 — полная); меньше,
+	// когда инстанс сам себя ограничивает, например под давлением памяти
+	CapacityPercent int
+	// ProtocolVersion — версия протокола инстанса, Compatibility — ее совместимость
+	// с балансером по матрице (deprecated-инстансы работают, но помечены)
+	ProtocolVersion uint32
+	Compatibility   string
+	// ClockSkew — насколько часы инстанса спешат относительно балансера
+	// (отрицательное — отстают) по последнему heartbeat, с точностью до секунды
+	// и задержки сети
+	ClockSkew time.Duration
+
+	// credit копит доли выдачи для инстансов с урезанной мощностью
+	credit int
+	conn   *grpc.ClientConn
+	Client captchapb.CaptchaServiceClient
+}
+
+// Addr возвращает адрес gRPC-сервера инстанса
+func (i *Instance) Addr() string {
+	return fmt.Sprintf("%s:%d", i.Host, i.Port)
+}
+
+// Registry хранит инстансы и таблицу маршрутов challenge_id -> инстанс.
+// Новые задания выдаются только READY-инстансами; проверки решений идут на
+// инстанс, выдавший задание, в том числе если он в режиме DRAINING.
+type Registry struct {
+	mu        sync.RWMutex
+	instances map[string]*Instance
+	order     []string
+	next      int
+
+	// routes живут столько же, сколько задания на инстансах
+	routes *cache.Cache
+	// dialOpts добавляются к соединениям с инстансами (например, in-process транспорт)
+	dialOpts []grpc.DialOption
+	// clockSkew — допустимое расхождение часов инстанса; 0 — не проверяется
+	clockSkew time.Duration
+	// region и zone — размещение балансера: задания выдаются прежде всего
+	// инстансами той же зоны, затем того же региона
+	region, zone string
+
+	// OnCrossRegion получает регион инстанса, которому ушло задание, когда в
+	// регионе балансера не нашлось READY-инстанса (для метрик)
+	OnCrossRegion func(region string)
+}
+
+// NewRegistry создает реестр; routeTTL должен совпадать со сроком жизни заданий
+func NewRegistry(routeTTL time.Duration, dialOpts ...grpc.DialOption) *Registry {
+	return &Registry{
+		instances: make(map[string]*Instance),
+		routes:    cache.New(routeTTL, routeTTL),
+		dialOpts:  dialOpts,
+	}
+}
+
+// SetClockSkewTolerance задает допустимое расхождение часов инстансов с
+// балансером: сверх него в лог пишется предупреждение. Токены и подписи,
+// выданные одним инстансом, проверяют другие, и их сроки сравниваются с
+// чужими часами.
+func (r *Registry) SetClockSkewTolerance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clockSkew = d
+}
+
+// SetLocality задает регион и зону балансера. Пустой регион выключает
+// предпочтение: задания распределяются по всем инстансам поровну.
+func (r *Registry) SetLocality(region, zone string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.region, r.zone = region, zone
+}
+
+// Update применяет событие регистрации/heartbeat инстанса; compat —
+// совместимость его версии протокола
+func (r *Registry) Update(req *balancerpb.RegisterInstanceRequest, compat string) error {
+	if req.GetEventType() == balancerpb.RegisterInstanceRequest_STOPPED {
+		r.Remove(req.GetInstanceId())
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inst, ok := r.instances[req.GetInstanceId()]
+	if !ok {
+		inst = &Instance{
+			ID:            req.GetInstanceId(),
+			ChallengeType: req.GetChallengeType(),
+			Host:          req.GetHost(),
+			Port:          int(req.GetPortNumber()),
+			Region:        req.GetRegion(),
+			Zone:          req.GetZone(),
+		}
+		opts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, r.dialOpts...)
+		conn, err := grpc.NewClient(inst.Addr(), opts...)
+		if err != nil {
+			return fmt.Errorf("failed to connect to instance %s at %s: %w", inst.ID, inst.Addr(), err)
+		}
+		inst.conn = conn
+		inst.Client = captchapb.NewCaptchaServiceClient(conn)
+		r.instances[inst.ID] = inst
+		r.order = append(r.order, inst.ID)
+		log.Printf("Instance %s (%s) registered at %s in %s", inst.ID, inst.ChallengeType, inst.Addr(), inst.locality())
+	}
+	if inst.State != req.GetEventType() {
+		log.Printf("Instance %s state: %s -> %s", inst.ID, inst.State, req.GetEventType())
+	}
+	inst.State = req.GetEventType()
+	inst.LastSeen = time.Now()
+	r.checkClockLocked(inst, req.GetTimestamp())
+	inst.Stats = req.GetComplexityStats()
+	inst.PendingChallenges = int(req.GetPendingChallenges())
+	capacity := int(req.GetCapacityPercent())
+	if capacity == 0 || capacity > 100 {
+		capacity = 100
+	}
+	if capacity != inst.CapacityPercent && inst.CapacityPercent != 0 {
+		log.Printf("Instance %s capacity: %d%% -> %d%%", inst.ID, inst.CapacityPercent, capacity)
+	}
+	inst.CapacityPercent = capacity
+	if compat != inst.Compatibility && compat != Compatible {
+		log.Printf("Instance %s runs protocol version %d (balancer %d): %s", inst.ID, req.GetProtocolVersion(), balancerpb.ProtocolVersion, compat)
+	}
+	inst.ProtocolVersion = req.GetProtocolVersion()
+	inst.Compatibility = compat
+	return nil
+}
+
+// checkClockLocked сравнивает время heartbeat с часами балансера и пишет в лог,
+// когда расхождение выходит за допуск и когда возвращается в него
+func (r *Registry) checkClockLocked(inst *Instance, timestamp int64) {
+	if timestamp == 0 || r.clockSkew == 0 {
+		return
+	}
+	skew := time.Unix(timestamp, 0).Sub(inst.LastSeen.Truncate(time.Second))
+	was, is := outOfTolerance(inst.ClockSkew, r.clockSkew), outOfTolerance(skew, r.clockSkew)
+	switch {
+	case is && !was:
+		log.Printf("WARNING: instance %s clock is off by %s from balancer (tolerance %s): tokens it signs may be rejected or outlive their TTL on other instances",
+			inst.ID, skew, r.clockSkew)
+	case was && !is:
+		log.Printf("Instance %s clock is back within %s of balancer (off by %s)", inst.ID, r.clockSkew, skew)
+	}
+	inst.ClockSkew = skew
+}
+
+func outOfTolerance(skew, tolerance time.Duration) bool {
+	return skew > tolerance || skew < -tolerance
+}
+
+// Remove убирает инстанс из реестра и закрывает соединение с ним
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inst, ok := r.instances[id]
+	if !ok {
+		return
+	}
+	delete(r.instances, id)
+	for i, v := range r.order {
+		if v == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	inst.conn.Close()
+	log.Printf("Instance %s removed from registry", id)
+}
+
+// PickForNewChallenge выбирает READY-инстанс по кругу, предпочитая инстансы
+// зоны и региона балансера: в другой регион задание уходит, только когда в
+// своем нет ни одного READY-инстанса.
+func (r *Registry) PickForNewChallenge() (*Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.region != "" {
+		if r.zone != "" {
+			if inst := r.pickLocked(func(i *Instance) bool { return i.Region == r.region && i.Zone == r.zone }); inst != nil {
+				return inst, nil
+			}
+		}
+		if inst := r.pickLocked(func(i *Instance) bool { return i.Region == r.region }); inst != nil {
+			return inst, nil
+		}
+	}
+	if inst := r.pickLocked(func(*Instance) bool { return true }); inst != nil {
+		if r.region != "" && r.OnCrossRegion != nil {
+			r.OnCrossRegion(inst.Region)
+		}
+		return inst, nil
+	}
+	return nil, ErrNoInstances
+}
+
+// pickLocked выбирает по кругу READY-инстанс из подходящих под match; nil —
+// таких нет. Инстанс с урезанной мощностью получает только свою долю заданий:
+// за каждый проход он копит CapacityPercent и выдает задание, когда накопит
+// 100. Если урезаны все, задание получает первый подходящий инстанс, чтобы не
+// отказывать клиенту.
+func (r *Registry) pickLocked(match func(*Instance) bool) *Instance {
+	var fallback *Instance
+	for range r.order {
+		r.next = (r.next + 1) % len(r.order)
+		inst := r.instances[r.order[r.next]]
+		if inst.State != balancerpb.RegisterInstanceRequest_READY || !match(inst) {
+			continue
+		}
+		if inst.CapacityPercent >= 100 {
+			return inst
+		}
+		inst.credit += inst.CapacityPercent
+		if inst.credit >= 100 {
+			inst.credit -= 100
+			return inst
+		}
+		if fallback == nil {
+			fallback = inst
+		}
+	}
+	return fallback
+}
+
+// locality — регион и зона инстанса для логов
+func (i *Instance) locality() string {
+	switch {
+	case i.Region == "":
+		return "unknown region"
+	case i.Zone == "":
+		return "region " + i.Region
+	}
+	return "region " + i.Region + ", zone " + i.Zone
+}
+
+// Remember запоминает, какой инстанс выдал задание
+func (r *Registry) Remember(challengeID, instanceID string) {
+	r.routes.SetDefault(challengeID, instanceID)
+}
+
+// Route находит инстанс, который должен проверить решение задания.
+// DRAINING-инстансы продолжают получать проверки, пока маршрут не истек.
+func (r *Registry) Route(challengeID string) (*Instance, bool) {
+	id, ok := r.routes.Get(challengeID)
+	if !ok {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst, ok := r.instances[id.(string)]
+	if !ok {
+		return nil, false
+	}
+	switch inst.State {
+	case balancerpb.RegisterInstanceRequest_READY, balancerpb.RegisterInstanceRequest_DRAINING:
+		return inst, true
+	}
+	return nil, false
+}
+
+// InstanceVersion — версия протокола инстанса и ее совместимость
+type InstanceVersion struct {
+	ID              string `json:"id"`
+	ProtocolVersion uint32 `json:"protocol_version"`
+	Compatibility   string `json:"compatibility"`
+}
+
+// Versions возвращает версии протокола зарегистрированных инстансов
+func (r *Registry) Versions() []InstanceVersion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]InstanceVersion, 0, len(r.order))
+	for _, id := range r.order {
+		inst := r.instances[id]
+		list = append(list, InstanceVersion{ID: inst.ID, ProtocolVersion: inst.ProtocolVersion, Compatibility: inst.Compatibility})
+	}
+	return list
+}
+
+// Get возвращает инстанс по ID
+func (r *Registry) Get(id string) (*Instance, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst, ok := r.instances[id]
+	return inst, ok
+}
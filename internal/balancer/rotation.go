@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"log"
+	"sync"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+)
+
+// RotationScheduler периодически начинает новую ротацию защиты от автоматизации:
+// новый seed параметров обфускации и приманок и очередные версии шаблонов.
+// Ротация уходит инстансам вместе с конфигурацией флота, поэтому весь флот
+// переключается одновременно, а разобранный солвер живет не дольше одного периода.
+type RotationScheduler struct {
+	control  *ControlPlane
+	interval time.Duration
+	// variants — версии шаблонов, между которыми чередуются ротации, по типам заданий
+	variants map[string][]string
+
+	mu sync.Mutex
+}
+
+// NewRotationScheduler создает планировщик; interval <= 0 отключает автоматическую ротацию
+func NewRotationScheduler(control *ControlPlane, interval time.Duration, variants map[string][]string) *RotationScheduler {
+	return &RotationScheduler{control: control, interval: interval, variants: variants}
+}
+
+// Run запускает ротации с заданным периодом до отмены ctx
+func (s *RotationScheduler) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	s.Rotate()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Rotate()
+		}
+	}
+}
+
+// Rotate немедленно начинает следующую ротацию и публикует ее
+func (s *RotationScheduler) Rotate() *balancerpb.InstanceConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	epoch := s.control.Current().GetRotation().GetEpoch() + 1
+	rotation := &balancerpb.Rotation{
+		Epoch:     epoch,
+		Seed:      randomSeed(),
+		StartedAt: time.Now().Unix(),
+	}
+	if len(s.variants) > 0 {
+		rotation.TemplateVersions = make(map[string]string, len(s.variants))
+		for kind, versions := range s.variants {
+			if len(versions) > 0 {
+				rotation.TemplateVersions[kind] = versions[epoch%int64(len(versions))]
+			}
+		}
+	}
+	cfg := s.control.SetRotation(rotation)
+	log.Printf("Started rotation %d (config v%d), template versions %v", epoch, cfg.GetVersion(), rotation.GetTemplateVersions())
+	return cfg
+}
+
+func randomSeed() int64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
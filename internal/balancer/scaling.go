@@ -0,0 +1,138 @@
+package balancer
+
+import (
+	"math"
+	"sort"
+
+	balancerpb "captcha-service/api/balancer/v1"
+)
+
+// ScalingPolicy задает, как перевести спрос на генерацию в число инстансов
+type ScalingPolicy struct {
+	// TargetConcurrency — сколько одновременных генераций должен держать один инстанс
+	TargetConcurrency float64 `json:"target_concurrency"`
+	// MinInstances и MaxInstances ограничивают рекомендацию; MaxInstances 0 — без предела
+	MinInstances int `json:"min_instances"`
+	MaxInstances int `json:"max_instances"`
+}
+
+// BucketLoad — агрегированная по флоту статистика корзины сложности
+type BucketLoad struct {
+	MinComplexity int     `json:"min_complexity"`
+	MaxComplexity int     `json:"max_complexity"`
+	Generated     int64   `json:"generated"`
+	RatePerSecond float64 `json:"rate_per_second"`
+	AvgMs         float64 `json:"avg_generation_ms"`
+	// P95Ms — худший p95 среди инстансов: точный p95 по флоту из агрегатов не собрать
+	P95Ms      float64 `json:"p95_generation_ms"`
+	QueueDepth int     `json:"queue_depth"`
+}
+
+// TypeScaling — сигнал масштабирования для одного типа заданий
+type TypeScaling struct {
+	Ready    int `json:"ready"`
+	Draining int `json:"draining"`
+	// Throttled — READY-инстансы, урезавшие мощность (например, под давлением памяти)
+	Throttled int `json:"throttled"`
+	// Load — оценка одновременных генераций: поток заданий × время генерации + очередь
+	Load    float64      `json:"load"`
+	Desired int          `json:"desired"`
+	Buckets []BucketLoad `json:"buckets"`
+}
+
+// Scaling считает желаемое число инстансов по типам заданий из статистики
+// последних heartbeat. По закону Литтла средняя занятость генерацией равна
+// потоку заданий, умноженному на время генерации; очередь добавляется сверху,
+// чтобы накопившийся спрос тоже требовал мощности.
+func (r *Registry) Scaling(p ScalingPolicy) map[string]*TypeScaling {
+	if p.TargetConcurrency <= 0 {
+		p.TargetConcurrency = 1
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type bucketKey struct{ min, max int }
+	result := make(map[string]*TypeScaling)
+	buckets := make(map[string]map[bucketKey]*BucketLoad)
+	for _, inst := range r.instances {
+		ts, ok := result[inst.ChallengeType]
+		if !ok {
+			ts = &TypeScaling{}
+			result[inst.ChallengeType] = ts
+			buckets[inst.ChallengeType] = make(map[bucketKey]*BucketLoad)
+		}
+		switch inst.State {
+		case balancerpb.RegisterInstanceRequest_READY:
+			ts.Ready++
+			if inst.CapacityPercent < 100 {
+				ts.Throttled++
+			}
+		case balancerpb.RegisterInstanceRequest_DRAINING:
+			ts.Draining++
+		}
+		for _, st := range inst.Stats {
+			key := bucketKey{int(st.GetMinComplexity()), int(st.GetMaxComplexity())}
+			b, ok := buckets[inst.ChallengeType][key]
+			if !ok {
+				b = &BucketLoad{MinComplexity: key.min, MaxComplexity: key.max}
+				buckets[inst.ChallengeType][key] = b
+			}
+			if st.GetGenerated() > 0 {
+				// Взвешенное по числу заданий среднее
+				total := b.AvgMs*float64(b.Generated) + st.GetAvgGenerationMs()*float64(st.GetGenerated())
+				b.Generated += st.GetGenerated()
+				b.AvgMs = total / float64(b.Generated)
+			}
+			if window := st.GetWindowSeconds(); window > 0 {
+				b.RatePerSecond += float64(st.GetGenerated()) / float64(window)
+			}
+			b.P95Ms = math.Max(b.P95Ms, st.GetP95GenerationMs())
+			b.QueueDepth += int(st.GetQueueDepth())
+		}
+	}
+
+	for typ, ts := range result {
+		for _, b := range buckets[typ] {
+			ts.Load += b.RatePerSecond*b.AvgMs/1000 + float64(b.QueueDepth)
+			ts.Buckets = append(ts.Buckets, *b)
+		}
+		sort.Slice(ts.Buckets, func(i, j int) bool { return ts.Buckets[i].MinComplexity < ts.Buckets[j].MinComplexity })
+		ts.Desired = max(int(math.Ceil(ts.Load/p.TargetConcurrency)), p.MinInstances)
+		if p.MaxInstances > 0 {
+			ts.Desired = min(ts.Desired, p.MaxInstances)
+		}
+	}
+	return result
+}
+
+// PendingLoad — незавершенные задания на READY-инстансах одного типа
+type PendingLoad struct {
+	Instances int
+	Pending   int
+}
+
+// PerInstance — среднее число незавершенных заданий на инстанс
+func (l PendingLoad) PerInstance() float64 {
+	if l.Instances == 0 {
+		return 0
+	}
+	return float64(l.Pending) / float64(l.Instances)
+}
+
+// Pending возвращает незавершенные задания по типам заданий. DRAINING-инстансы
+// не учитываются: их задания уйдут вместе с ними и новых мощностей не требуют.
+func (r *Registry) Pending() map[string]PendingLoad {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(map[string]PendingLoad)
+	for _, inst := range r.instances {
+		if inst.State != balancerpb.RegisterInstanceRequest_READY {
+			continue
+		}
+		l := result[inst.ChallengeType]
+		l.Instances++
+		l.Pending += inst.PendingChallenges
+		result[inst.ChallengeType] = l
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+package balancer
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	balancerpb "captcha-service/api/balancer/v1"
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/iplist"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Service реализует BalancerService: регистрирует инстансы в реестре и
+// пушит им конфигурацию флота через обратный канал стрима регистрации
+type Service struct {
+	balancerpb.UnimplementedBalancerServiceServer
+	registry *Registry
+	control  *ControlPlane
+	// auth — проверка инстансов; nil — регистрация открыта
+	auth *RegistrationAuth
+	// compat — совместимость версий протокола; nil — принимаются все версии
+	compat *Compatibility
+}
+
+// NewService создает сервис регистрации поверх реестра и control plane
+func NewService(registry *Registry, control *ControlPlane, auth *RegistrationAuth, compat *Compatibility) *Service {
+	return &Service{registry: registry, control: control, auth: auth, compat: compat}
+}
+
+// RegisterServices регистрирует на сервере балансера сервис регистрации инстансов
+// и прокси CaptchaService, через который клиенты ходят за заданиями
+func RegisterServices(s *grpc.Server, registry *Registry, control *ControlPlane, auth *RegistrationAuth, compat *Compatibility, compressor string, trusted iplist.Proxies) {
+	balancerpb.RegisterBalancerServiceServer(s, NewService(registry, control, auth, compat))
+	captchapb.RegisterCaptchaServiceServer(s, NewProxy(registry, compressor, trusted))
+}
+
+// RegisterInstance - реализует стриминговый RPC для регистрации инстансов
+func (s *Service) RegisterInstance(stream balancerpb.BalancerService_RegisterInstanceServer) error {
+	log.Println("New captcha instance trying to register...")
+	if err := s.auth.Authenticate(stream.Context()); err != nil {
+		if s.auth.Quarantines() {
+			return s.quarantine(stream, err)
+		}
+		if p, ok := peer.FromContext(stream.Context()); ok {
+			log.Printf("Rejected registration from %s: %v", p.Addr, err)
+		}
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	var instanceID string
+	stopped := false
+	// Инстанс, пропавший без STOPPED, больше не может проверять решения
+	defer func() {
+		if instanceID != "" && !stopped {
+			s.registry.Remove(instanceID)
+		}
+	}()
+
+	// Ответы и пуши конфигурации идут из разных горутин
+	var sendMu sync.Mutex
+	send := func(res *balancerpb.RegisterInstanceResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		res.Timestamp = time.Now().Unix()
+		return stream.Send(res)
+	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			log.Println("Captcha instance disconnected.")
+			return nil
+		}
+		if err != nil {
+			log.Printf("Error receiving from stream: %v", err)
+			return err
+		}
+
+		// Стрим принадлежит инстансу, который в нем зарегистрировался
+		if instanceID != "" && req.GetInstanceId() != instanceID {
+			log.Printf("Instance %s tried to switch its registration stream to ID %s", instanceID, req.GetInstanceId())
+			return errInstanceSwitched
+		}
+
+		// Просто логируем все, что получаем от сервиса капчи
+		log.Printf(
+			"Received event from captcha instance: ID=%s, Type=%s, Host=%s, Port=%d",
+			req.InstanceId,
+			req.EventType,
+			req.Host,
+			req.PortNumber,
+		)
+
+		// Версия проверяется на каждом сообщении: матрица может поменяться на лету
+		compat := s.compat.Status(req.GetProtocolVersion())
+		if compat == Incompatible {
+			log.Printf("Instance %s refused: protocol version %d is incompatible with balancer version %d",
+				req.GetInstanceId(), req.GetProtocolVersion(), balancerpb.ProtocolVersion)
+			return status.Errorf(codes.FailedPrecondition, "protocol version %d is incompatible with balancer protocol version %d",
+				req.GetProtocolVersion(), balancerpb.ProtocolVersion)
+		}
+		if err := s.registry.Update(req, compat); err != nil {
+			log.Printf("Failed to register instance: %v", err)
+			send(&balancerpb.RegisterInstanceResponse{Status: balancerpb.RegisterInstanceResponse_ERROR, Message: err.Error()})
+			continue
+		}
+		stopped = req.EventType == balancerpb.RegisterInstanceRequest_STOPPED
+		s.control.NoteSettingsVersion(req.GetSettingsVersion())
+		if instanceID == "" {
+			instanceID = req.InstanceId
+			if err := send(&balancerpb.RegisterInstanceResponse{Status: balancerpb.RegisterInstanceResponse_SUCCESS}); err != nil {
+				return err
+			}
+			updates, unsubscribe := s.control.Subscribe(instanceID)
+			defer unsubscribe()
+			go pushConfig(stream.Context(), instanceID, updates, send)
+		}
+	}
+}
+
+// errInstanceSwitched — в стриме регистрации пришел ID другого инстанса
+var errInstanceSwitched = status.Error(codes.InvalidArgument, "instance ID cannot change within a registration stream")
+
+// quarantine держит стрим неаутентифицированного инстанса открытым, не пуская
+// его в реестр: инстанс не получает трафик, а оператор видит его в карантине.
+// Стрим закреплен за первым ID, иначе один клиент заполнил бы карантин,
+// меняя ID в каждом heartbeat.
+func (s *Service) quarantine(stream balancerpb.BalancerService_RegisterInstanceServer, reason error) error {
+	var instanceID string
+	defer func() {
+		if instanceID != "" {
+			s.auth.Release(instanceID)
+		}
+	}()
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if instanceID != "" && req.GetInstanceId() != instanceID {
+			log.Printf("Quarantined instance %s tried to switch its registration stream to ID %s", instanceID, req.GetInstanceId())
+			return errInstanceSwitched
+		}
+		if err := s.auth.Quarantine(stream.Context(), req, reason); err != nil {
+			log.Printf("Rejected registration of instance %s: %v", req.GetInstanceId(), err)
+			return status.Error(codes.Unauthenticated, reason.Error())
+		}
+		if instanceID == "" {
+			instanceID = req.GetInstanceId()
+			res := &balancerpb.RegisterInstanceResponse{Status: balancerpb.RegisterInstanceResponse_ERROR, Message: "instance is quarantined: " + reason.Error()}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pushConfig пересылает инстансу обновления конфигурации флота, пока жив стрим
+func pushConfig(ctx context.Context, instanceID string, updates <-chan *balancerpb.InstanceConfig, send func(*balancerpb.RegisterInstanceResponse) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case cfg := <-updates:
+			res := &balancerpb.RegisterInstanceResponse{Status: balancerpb.RegisterInstanceResponse_SUCCESS, Config: cfg}
+			if err := send(res); err != nil {
+				log.Printf("Failed to push config v%d to instance %s: %v", cfg.GetVersion(), instanceID, err)
+				return
+			}
+			log.Printf("Pushed config v%d to instance %s", cfg.GetVersion(), instanceID)
+		}
+	}
+}
+
+// LookupChallenge сообщает инстансу, получившему решение чужого задания,
+// какой инстанс его выдал
+func (s *Service) LookupChallenge(ctx context.Context, req *balancerpb.LookupChallengeRequest) (*balancerpb.LookupChallengeResponse, error) {
+	if err := s.auth.Authenticate(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	inst, ok := s.registry.Route(req.GetChallengeId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "challenge route not found or expired")
+	}
+	return &balancerpb.LookupChallengeResponse{
+		InstanceId: inst.ID,
+		Host:       inst.Host,
+		PortNumber: int32(inst.Port),
+	}, nil
+}
+
+// HandoffChallenges передает незавершенные задания остановившегося инстанса
+// READY-инстансу и направляет проверки принятых заданий на него
+func (s *Service) HandoffChallenges(ctx context.Context, req *balancerpb.HandoffChallengesRequest) (*balancerpb.HandoffChallengesResponse, error) {
+	if err := s.auth.Authenticate(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	inst, err := s.registry.PickForNewChallenge()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if inst.ID == req.GetInstanceId() {
+		return nil, status.Error(codes.FailedPrecondition, "no other ready instance to take over challenges")
+	}
+	res, err := inst.Client.ImportChallenges(ctx, &captchapb.ImportChallengesRequest{
+		FromInstance: req.GetInstanceId(),
+		SealedState:  req.GetSealedState(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range res.GetChallengeIds() {
+		s.registry.Remember(id, inst.ID)
+	}
+	log.Printf("Instance %s handed off %d challenges to instance %s", req.GetInstanceId(), len(res.GetChallengeIds()), inst.ID)
+	return &balancerpb.HandoffChallengesResponse{InstanceId: inst.ID, Imported: int32(len(res.GetChallengeIds()))}, nil
+}
@@ -0,0 +1,116 @@
+// Package chaos — gRPC-перехватчики, которые намеренно деградируют сервис:
+// добавляют задержку, возвращают ошибки и теряют сообщения стримов с заданной
+// вероятностью. Нужны, чтобы проверять, как интеграции сайтов переживают
+// проблемы капчи. В проде не включаются.
+package chaos
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Config задает вероятности (0..1) для каждого вида сбоя
+type Config struct {
+	// LatencyRate — доля вызовов и сообщений стрима, которым добавляется Latency
+	LatencyRate float64
+	Latency     time.Duration
+	// ErrorRate — доля унарных вызовов и открытий стрима, завершаемых с ErrorCode
+	ErrorRate float64
+	ErrorCode codes.Code
+	// DropRate — доля сообщений стрима (в обе стороны), которые теряются молча
+	DropRate float64
+}
+
+// Enabled сообщает, задан ли хоть один вид сбоя
+func (c Config) Enabled() bool {
+	return c.LatencyRate > 0 && c.Latency > 0 || c.ErrorRate > 0 || c.DropRate > 0
+}
+
+// exempt — служебные сервисы, которые не деградируются: иначе оркестратор
+// перезапустит инстанс вместо того, чтобы сайт увидел сбой
+func exempt(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.") || strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+func (c Config) delay(ctx context.Context) {
+	if !hit(c.LatencyRate) {
+		return
+	}
+	select {
+	case <-time.After(c.Latency):
+	case <-ctx.Done():
+	}
+}
+
+func (c Config) injectedError() error {
+	code := c.ErrorCode
+	if code == codes.OK {
+		code = codes.Unavailable
+	}
+	return status.Error(code, "chaos: injected failure")
+}
+
+// UnaryInterceptor задерживает унарные вызовы и возвращает ошибки
+func (c Config) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if exempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		c.delay(ctx)
+		if hit(c.ErrorRate) {
+			return nil, c.injectedError()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor срывает открытие стримов, задерживает и теряет их сообщения
+func (c Config) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if exempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		c.delay(ss.Context())
+		if hit(c.ErrorRate) {
+			return c.injectedError()
+		}
+		return handler(srv, &stream{ServerStream: ss, cfg: c})
+	}
+}
+
+type stream struct {
+	grpc.ServerStream
+	cfg Config
+}
+
+// SendMsg теряет исходящее сообщение, сообщая обработчику об успехе
+func (s *stream) SendMsg(m any) error {
+	s.cfg.delay(s.Context())
+	if hit(s.cfg.DropRate) {
+		return nil
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// RecvMsg теряет входящие сообщения: обработчик получает следующее
+func (s *stream) RecvMsg(m any) error {
+	for {
+		if err := s.ServerStream.RecvMsg(m); err != nil {
+			return err
+		}
+		if !hit(s.cfg.DropRate) {
+			s.cfg.delay(s.Context())
+			return nil
+		}
+	}
+}
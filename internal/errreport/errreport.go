@@ -0,0 +1,263 @@
+// Package errreport отправляет ошибки и паники в Sentry-совместимый сервис
+// (envelope API), чтобы сбои флота собирались в одном месте, а не терялись
+// в логах контейнеров. Отправка асинхронная; при переполнении очереди события
+// отбрасываются. Nil *Reporter допустим и ничего не делает.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	queueSize   = 256
+	sendTimeout = 5 * time.Second
+	clientName  = "captcha-service/1"
+)
+
+// Reporter — клиент Sentry-совместимого сервиса
+type Reporter struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	release     string
+	environment string
+	serverName  string
+
+	client *http.Client
+	queue  chan []byte
+	wg     sync.WaitGroup
+}
+
+// New разбирает DSN вида https://<key>@host/<project> и запускает отправку.
+// Пустой DSN возвращает nil: отчеты выключены.
+func New(dsn, release, environment string) (*Reporter, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error reporting DSN: %w", err)
+	}
+	key := u.User.Username()
+	path, project, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if project == "" {
+		path, project = "", path
+	}
+	if key == "" || project == "" {
+		return nil, fmt.Errorf("invalid error reporting DSN: key and project id are required")
+	}
+	if path != "" {
+		path = "/" + path
+	}
+	if release == "" {
+		release = buildRevision()
+	}
+	host, _ := os.Hostname()
+	r := &Reporter{
+		dsn:         dsn,
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path, project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_key=%s, sentry_client=%s", key, clientName),
+		release:     release,
+		environment: environment,
+		serverName:  host,
+		client:      &http.Client{Timeout: sendTimeout},
+		queue:       make(chan []byte, queueSize),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// buildRevision — ревизия VCS из сборки как тег release по умолчанию
+func buildRevision() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+type exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type event struct {
+	EventID     string  `json:"event_id"`
+	Timestamp   float64 `json:"timestamp"`
+	Level       string  `json:"level"`
+	Platform    string  `json:"platform"`
+	Release     string  `json:"release,omitempty"`
+	Environment string  `json:"environment,omitempty"`
+	ServerName  string  `json:"server_name,omitempty"`
+	Exception   struct {
+		Values []exception `json:"values"`
+	} `json:"exception"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Capture отправляет ошибку; tags — контекст (challenge_id, site_key, ...)
+func (r *Reporter) Capture(err error, tags map[string]string) {
+	if r == nil || err == nil {
+		return
+	}
+	r.enqueue("error", fmt.Sprintf("%T", err), err.Error(), tags, nil)
+}
+
+// CapturePanic отправляет восстановленную панику со стеком
+func (r *Reporter) CapturePanic(recovered any, stack []byte, tags map[string]string) {
+	if r == nil {
+		return
+	}
+	r.enqueue("fatal", "panic", fmt.Sprint(recovered), tags, map[string]string{"stack": string(stack)})
+}
+
+func (r *Reporter) enqueue(level, typ, value string, tags, extra map[string]string) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	now := time.Now()
+	ev := event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   float64(now.UnixNano()) / 1e9,
+		Level:       level,
+		Platform:    "go",
+		Release:     r.release,
+		Environment: r.environment,
+		ServerName:  r.serverName,
+		Tags:        tags,
+		Extra:       extra,
+	}
+	ev.Exception.Values = []exception{{Type: typ, Value: value}}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	header, _ := json.Marshal(map[string]string{"event_id": ev.EventID, "sent_at": now.UTC().Format(time.RFC3339), "dsn": r.dsn})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+	envelope := bytes.Join([][]byte{header, item, payload}, []byte("\n"))
+
+	select {
+	case r.queue <- envelope:
+	default:
+		log.Printf("Error reporting queue is full, dropping event %s", ev.EventID)
+	}
+}
+
+func (r *Reporter) run() {
+	defer r.wg.Done()
+	for envelope := range r.queue {
+		req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(envelope))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", "application/x-sentry-envelope")
+		req.Header.Set("X-Sentry-Auth", r.auth)
+		res, err := r.client.Do(req)
+		if err != nil {
+			log.Printf("Failed to send error report: %v", err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			log.Printf("Error reporting service responded %s", res.Status)
+		}
+	}
+}
+
+// Close дожидается отправки очереди, но не дольше timeout; после Close отчеты не принимаются
+func (r *Reporter) Close(timeout time.Duration) {
+	if r == nil {
+		return
+	}
+	close(r.queue)
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Error reporting flush timed out after %s", timeout)
+	}
+}
+
+// Recover перехватывает панику горутины, отправляет ее и дает горутине продолжить работу.
+// Использование: defer reporter.Recover(tags).
+func (r *Reporter) Recover(tags map[string]string) {
+	if r == nil {
+		// Без отчетов паника не перехватывается, как и раньше
+		return
+	}
+	if p := recover(); p != nil {
+		log.Printf("Recovered panic: %v\n%s", p, debug.Stack())
+		r.CapturePanic(p, debug.Stack(), tags)
+	}
+}
+
+// UnaryInterceptor превращает панику обработчика в codes.Internal и отправляет отчет
+func (r *Reporter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("Panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+				r.CapturePanic(p, debug.Stack(), map[string]string{"method": info.FullMethod})
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor — то же для стримов
+func (r *Reporter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("Panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+				r.CapturePanic(p, debug.Stack(), map[string]string{"method": info.FullMethod})
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// Handler отправляет отчет о панике HTTP-обработчика и отвечает 500
+func (r *Reporter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				log.Printf("Panic in HTTP %s %s: %v\n%s", req.Method, req.URL.Path, p, debug.Stack())
+				r.CapturePanic(p, debug.Stack(), map[string]string{"http_path": req.URL.Path})
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,233 @@
+// Package flags — флаги рискованных функций (новые типы заданий, уровни
+// обфускации, невидимый режим). Флаг включается для отдельных сайтов или доли
+// трафика и вычисляется на каждый запрос, поэтому выкладка кода и включение
+// функции — разные шаги: функция уезжает в прод выключенной и включается или
+// откатывается без деплоя.
+//
+// Правила приходят из трех источников, и более поздний в списке перекрывает
+// более ранний: файл, переменная окружения, конфигурация от балансера. Флаг
+// без правила ни в одном источнике имеет значение по умолчанию.
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Source — источник правил, от младшего к старшему
+type Source int
+
+const (
+	Default Source = iota
+	File
+	Env
+	Remote
+	numSources
+)
+
+func (s Source) String() string {
+	switch s {
+	case File:
+		return "file"
+	case Env:
+		return "env"
+	case Remote:
+		return "remote"
+	}
+	return "default"
+}
+
+// Rule — правило флага: явное значение для перечисленных сайтов, для
+// остальных — доля трафика в процентах (0 — выключен, 100 — включен).
+// В JSON вместо объекта можно писать true/false.
+type Rule struct {
+	Percent int             `json:"percent"`
+	Sites   map[string]bool `json:"sites,omitempty"`
+}
+
+// UnmarshalJSON принимает и объект правила, и true/false
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var on bool
+	if json.Unmarshal(data, &on) == nil {
+		*r = Rule{Percent: percentOf(on)}
+		return nil
+	}
+	type plain Rule
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+func percentOf(on bool) int {
+	if on {
+		return 100
+	}
+	return 0
+}
+
+// Set — правила по именам флагов
+type Set map[string]Rule
+
+// LoadFile читает правила из JSON вида
+// {"invisible": false, "challenge.slider-multi": {"percent": 10, "sites": {"site-key": true}}}
+func LoadFile(path string) (Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature flags file: %w", err)
+	}
+	var set Set
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse feature flags file %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// ParseEnv разбирает FEATURE_FLAGS: "invisible=off,challenge.slider-multi=25%";
+// значения — on, off, true, false или доля в процентах. Сайтовые правила
+// задаются только файлом и балансером.
+func ParseEnv(s string) (Set, error) {
+	set := Set{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("feature flag %q: want name=value", item)
+		}
+		name = strings.TrimSpace(name)
+		switch value = strings.TrimSpace(value); value {
+		case "on", "true":
+			set[name] = Rule{Percent: 100}
+		case "off", "false":
+			set[name] = Rule{Percent: 0}
+		default:
+			p, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			if err != nil {
+				return nil, fmt.Errorf("feature flag %s: invalid value %q", name, value)
+			}
+			set[name] = Rule{Percent: p}
+		}
+	}
+	return set, nil
+}
+
+// Decision — значение флага для запроса и источник правила, которое его дало
+type Decision struct {
+	Enabled bool
+	Source  Source
+}
+
+// Store — известные флаги со значениями по умолчанию и правила источников;
+// правила источника заменяются целиком, запросы видят старый или новый набор
+type Store struct {
+	defaults map[string]bool
+	sources  [numSources]atomic.Pointer[Set]
+}
+
+// New создает хранилище флагов с именами и значениями по умолчанию defaults
+func New(defaults map[string]bool) *Store {
+	return &Store{defaults: defaults}
+}
+
+// Validate проверяет правила: неизвестные флаги и доли вне 0–100
+func (s *Store) Validate(set Set) error {
+	for name, r := range set {
+		if _, ok := s.defaults[name]; !ok {
+			return fmt.Errorf("unknown feature flag %q", name)
+		}
+		if r.Percent < 0 || r.Percent > 100 {
+			return fmt.Errorf("feature flag %s: percent %d is out of range 0-100", name, r.Percent)
+		}
+	}
+	return nil
+}
+
+// Set заменяет правила источника src
+func (s *Store) Set(src Source, set Set) {
+	s.sources[src].Store(&set)
+}
+
+// Enabled вычисляет флаг name для сайта siteKey. unit — устойчивый ключ
+// клиента (сессия, IP): с ним доля трафика делится по клиентам, а не по
+// запросам, и один клиент не видит функцию через раз.
+func (s *Store) Enabled(name, siteKey, unit string) Decision {
+	for src := numSources - 1; src > Default; src-- {
+		set := s.sources[src].Load()
+		if set == nil {
+			continue
+		}
+		if r, ok := (*set)[name]; ok {
+			return Decision{Enabled: r.enabled(name, siteKey, unit), Source: src}
+		}
+	}
+	return Decision{Enabled: s.defaults[name], Source: Default}
+}
+
+func (r Rule) enabled(name, siteKey, unit string) bool {
+	if on, ok := r.Sites[siteKey]; ok {
+		return on
+	}
+	switch r.Percent {
+	case 0:
+		return false
+	case 100:
+		return true
+	}
+	// Корзина зависит и от имени флага: иначе на 10% всех флагов оказывались
+	// бы одни и те же клиенты
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(unit))
+	return int(h.Sum32()%100) < r.Percent
+}
+
+// Effective — действующее правило каждого известного флага и его источник
+func (s *Store) Effective() map[string]Effective {
+	out := make(map[string]Effective, len(s.defaults))
+	for name, def := range s.defaults {
+		e := Effective{Rule: Rule{Percent: percentOf(def)}, Source: Default.String()}
+		for src := numSources - 1; src > Default; src-- {
+			if set := s.sources[src].Load(); set != nil {
+				if r, ok := (*set)[name]; ok {
+					e = Effective{Rule: r, Source: src.String()}
+					break
+				}
+			}
+		}
+		out[name] = e
+	}
+	return out
+}
+
+// Effective — правило флага вместе с источником
+type Effective struct {
+	Rule
+	Source string `json:"source"`
+}
+
+// Summary — действующие правила одной строкой для лога
+func (s *Store) Summary() string {
+	eff := s.Effective()
+	names := make([]string, 0, len(eff))
+	for name := range eff {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		e := eff[name]
+		parts[i] = fmt.Sprintf("%s=%d%%", name, e.Percent)
+		if len(e.Sites) > 0 {
+			parts[i] += fmt.Sprintf(" +%d sites", len(e.Sites))
+		}
+		parts[i] += " (" + e.Source + ")"
+	}
+	return strings.Join(parts, ", ")
+}
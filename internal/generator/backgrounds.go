@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // фоны из BackgroundDir могут быть в JPEG
+	_ "image/png"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"captcha-service/internal/logging"
+)
+
+// background — фон, который декодируется и готовится для всех ступеней
+// качества при первом задании на нем: холодный старт не ждет картинок,
+// которые могут и не понадобиться (фоны рынков без трафика)
+type background struct {
+	name string
+	open func() (io.ReadCloser, error)
+
+	once     sync.Once
+	canvases []*canvas
+	err      error
+}
+
+// get возвращает холсты фона, декодируя его при первом вызове
+func (b *background) get() ([]*canvas, error) {
+	b.once.Do(func() {
+		start := time.Now()
+		f, err := b.open()
+		if err != nil {
+			b.err = fmt.Errorf("failed to open background %s: %w", b.name, err)
+			return
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			b.err = fmt.Errorf("failed to decode background %s: %w", b.name, err)
+			return
+		}
+		b.canvases = newCanvases(img)
+		logging.Debugf(logging.Generator, "", "Background %s decoded in %s", b.name, time.Since(start).Round(time.Millisecond))
+	})
+	return b.canvases, b.err
+}
+
+// embeddedBackground — встроенный фон по умолчанию
+func embeddedBackground() *background {
+	return &background{name: "assets/background.png", open: func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(backgroundAsset)), nil
+	}}
+}
+
+// backgrounds — фоны по локалям. Ключ "" — фоны для локалей без своего набора.
+type backgrounds map[string][]*background
+
+// loadBackgrounds перечисляет встроенный фон и фоны из dir. Раскладка dir:
+// <dir>/<locale>/*.png|*.jpg — фоны рынка ("ru", "pt-BR"), файлы в корне dir
+// заменяют встроенный фон по умолчанию. Сразу читаются только заголовки
+// файлов (формат и размер), сами картинки — при первом задании.
+func loadBackgrounds(dir string, fallback *background) (backgrounds, error) {
+	b := backgrounds{"": {fallback}}
+	if dir == "" {
+		return b, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read background dir: %w", err)
+	}
+	var defaults []*background
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !e.IsDir() {
+			c, ok, err := loadBackground(path)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				defaults = append(defaults, c)
+			}
+			continue
+		}
+		files, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read background dir: %w", err)
+		}
+		var set []*background
+		for _, f := range files {
+			c, ok, err := loadBackground(filepath.Join(path, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				set = append(set, c)
+			}
+		}
+		if len(set) > 0 {
+			b[normalizeLocale(e.Name())] = set
+		}
+	}
+	if len(defaults) > 0 {
+		b[""] = defaults
+	}
+	return b, nil
+}
+
+// loadBackground проверяет заголовок файла фона; файлы других форматов пропускаются
+func loadBackground(path string) (*background, bool, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		return nil, false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open background %s: %w", path, err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode background %s: %w", path, err)
+	}
+	if cfg.Width < 4*puzzleWidth || cfg.Height < 2*puzzleHeight {
+		return nil, false, fmt.Errorf("background %s is too small: %dx%d", path, cfg.Width, cfg.Height)
+	}
+	return &background{name: path, open: func() (io.ReadCloser, error) { return os.Open(path) }}, true, nil
+}
+
+// pick выбирает случайный фон для локали: сначала точное совпадение ("pt-br"),
+// затем язык ("pt"), затем фоны по умолчанию
+func (b backgrounds) pick(locale string) *background {
+	for _, l := range localeCandidates(locale) {
+		if set, ok := b[l]; ok {
+			return set[rand.Intn(len(set))]
+		}
+	}
+	set := b[""]
+	return set[rand.Intn(len(set))]
+}
+
+// locales — локали, для которых есть собственные фоны
+func (b backgrounds) locales() []string {
+	var list []string
+	for l := range b {
+		if l != "" {
+			list = append(list, l)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// normalizeLocale приводит тег к виду "pt-br": нижний регистр, дефис
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// localeCandidates — тег локали и его язык: "pt-BR" -> ["pt-br", "pt"]
+func localeCandidates(locale string) []string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		return []string{locale, lang}
+	}
+	return []string{locale}
+}
@@ -2,21 +2,28 @@ package generator
 
 import (
 	"bytes"
-	"embed"
+	"context"
+	crand "crypto/rand"
+	_ "embed"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
 	"image"
-	"image/color"
 	"image/draw"
 	"image/png"
-	"log"
 	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
-)
 
-//go:embed template.html
-var captchaTemplateFS embed.FS
+	"captcha-service/internal/answer"
+	"captcha-service/internal/logging"
+	"captcha-service/internal/static"
+)
 
 //go:embed assets/background.png
 var backgroundAsset []byte
@@ -24,113 +31,544 @@ var backgroundAsset []byte
 const (
 	puzzleWidth  = 60
 	puzzleHeight = 60
+
+	defaultSliderStep = 1.0
+
+	// templateOverhead — оценка размера разметки и скрипта шаблона без картинок
+	templateOverhead = 8 << 10
 )
 
+// ErrHTMLTooLarge возвращается, если HTML задания не укладывается в MaxHTMLSize
+var ErrHTMLTooLarge = errors.New("challenge html exceeds size budget")
+
+// Config задает параметры генератора
+type Config struct {
+	// SliderStep — шаг слайдера в пикселях исходного изображения; допускаются дробные значения
+	SliderStep float64
+	// Obfuscate включает постобработку HTML: случайные имена, перестановку и мертвый код в JS
+	Obfuscate bool
+	// MaxHTMLSize — предельный размер HTML задания в байтах; 0 — без ограничения.
+	// Base64-картинки легко превышают лимиты прокси и gRPC (4 МБ по умолчанию).
+	MaxHTMLSize int
+	// SizeBudget — мягкий бюджет размера HTML: при превышении генератор понижает
+	// качество и размер фона для следующих заданий; 0 — без автоматической деградации
+	SizeBudget int
+	// AssetBaseURL включает ленивую загрузку картинок: HTML содержит только ссылки
+	// вида AssetBaseURL/assets/<key>/<name>, а сами картинки лежат в Challenge.Assets.
+	// Пустая строка — картинки встраиваются в HTML как data URI.
+	AssetBaseURL string
+	// SignAssetURL, если задан, возвращает query-строку подписи для адреса
+	// картинки name задания с ключом key
+	SignAssetURL func(key, name string) string
+	// BindRender привязывает загрузку картинок к одноразовому обмену render-токена
+	// (см. AssetOptions.BindRender); действует только вместе с AssetBaseURL
+	BindRender bool
+	// BackgroundDir — фоны заданий по рынкам: <dir>/<locale>/*.png|*.jpg, файлы
+	// в корне заменяют встроенный фон; пустая — только встроенный фон
+	BackgroundDir string
+	// TemplateDir — внешняя директория шаблонов (<type>/<version>/<locale>.html),
+	// перекрывающая встроенные; TemplateVersions закрепляет версии по типам заданий
+	TemplateDir      string
+	TemplateVersions map[string]string
+	// Static, если задано, получает CSS и JS оболочек виджета: HTML задания
+	// ссылается на них как AssetBaseURL/static/<хэш>, и CDN кэширует их навсегда.
+	// Действует только вместе с AssetBaseURL.
+	Static *static.Store
+	// Kinds — виды заданий, которые выдает развертывание; пустой — все собранные
+	// в бинарник (см. CompiledKinds). Пазл исключить нельзя.
+	Kinds []string
+}
+
+// Виды заданий
+const (
+	KindSlider = "slider-puzzle"
+	KindRotate = "slider-rotate"
+	KindMulti  = "slider-multi"
+)
+
+// Challenge — сгенерированное задание: HTML для клиента и данные для проверки ответа
+type Challenge struct {
+	Kind string
+	HTML string
+	// X — правильная координата левого края пазла в пикселях исходного изображения
+	X int
+	// Width и Height — размер исходного изображения
+	Width  int
+	Height int
+	// Step — шаг слайдера, к которому сервер приводит ответ при проверке
+	Step float64
+	// Angle — угол в градусах (по часовой), на который нужно повернуть пазл (только KindRotate)
+	Angle float64
+	// Pieces — правильные X для каждого фрагмента (только KindMulti)
+	Pieces []PieceAnswer
+	// Template — шаблон виджета, по которому отрисовано задание
+	Template TemplateKey
+	// AssetKey и Assets заполняются в режиме ленивой загрузки картинок
+	AssetKey string
+	Assets   []Asset
+	// Stages — сколько заняли этапы отрисовки
+	Stages Stages
+}
+
+// Stages — время этапов отрисовки: картинки (включая повторы при превышении
+// бюджета), кодирование картинок и исполнение шаблона с обфускацией
+type Stages struct {
+	Render   time.Duration
+	Encode   time.Duration
+	Template time.Duration
+}
+
+// Asset — картинка задания, которую виджет загружает отдельно от HTML
+type Asset struct {
+	Name string
+	MIME string
+	Data []byte
+}
+
+// PayloadSize — сколько байт клиент скачает ради задания: HTML и все картинки
+func (c *Challenge) PayloadSize() int {
+	size := len(c.HTML)
+	for _, a := range c.Assets {
+		size += len(a.Data)
+	}
+	return size
+}
+
+// PieceAnswer — правильная координата одного фрагмента многопазлового задания
+type PieceAnswer struct {
+	ID string
+	X  int
+}
+
 // ChallengeData содержит все данные, необходимые для рендеринга HTML-шаблона
 type ChallengeData struct {
-	BackgroundImg   string
-	PuzzleImg       string
+	// BackgroundSrc и PuzzleSrc — data URI или ссылка на ассет
+	BackgroundSrc template.URL
+	PuzzleSrc     template.URL
+	LazyAssets    bool
+	// RenderURL — адрес обмена render-токена; пустой, если отрисовка не привязана
+	RenderURL template.URL
+	// BindRender — отрисовка привязана; в оболочке RenderURL пуст, а адрес едет в данных
+	BindRender bool
+	// Data — место JSON-блока с данными задания (см. shell.go). Шаблон с ним
+	// кэшируется как оболочка: картинки, позиции, адрес отрисовки и время
+	// виджет берет из блока, а не из полей выше.
+	Data            template.JS
 	PuzzleYPos      int
 	PuzzleWidth     int
 	PuzzleHeight    int
 	ContainerWidth  int
 	ContainerHeight int
 	SliderMax       int
+	SliderStep      float64
+	Rotatable       bool
+	// AnswerEnvelope — виджет упаковывает ответ в конверт answer.SchemaEnvelope;
+	// AnswerSealed — и шифрует его открытым ключом задания (answer.SchemaSealed)
+	AnswerEnvelope bool
+	AnswerSealed   bool
+	// ExpiresAt и IssuedAt — срок задания и время выдачи по часам сервера (unix, мс).
+	// Генератор оставляет заглушки: задание может быть нарисовано заранее, а срок
+	// начинается при выдаче, поэтому время подставляет WithExpiry.
+	ExpiresAt template.JS
+	IssuedAt  template.JS
+	// RefreshLead — за сколько секунд до истечения виджет просит новое задание
+	RefreshLead int
+	// Pieces заполняется только для многопазлового задания
+	Pieces []PieceData
+}
+
+// PieceData — данные одного фрагмента для шаблона
+type PieceData struct {
+	ID   string
+	Src  template.URL
+	YPos int
+
+	img image.Image
 }
 
 // Generator отвечает за создание заданий капчи
 type Generator struct {
-	bgImage  image.Image
-	bgWidth  int
-	bgHeight int
-	template *template.Template
+	// kinds — виды заданий развертывания (см. Config.Kinds)
+	kinds map[string]kindFunc
+	// backgrounds — фоны по локалям с холстами всех ступеней качества,
+	// level — текущая ступень
+	backgrounds backgrounds
+	level       atomic.Int32
+	// floor — временная нижняя ступень качества, задаваемая извне (давление памяти)
+	floor     atomic.Int32
+	templates *TemplateRepository
+	step      float64
+	obfuscate bool
+	// obfuscation — текущие параметры обфускации, меняются ротацией
+	obfuscation atomic.Pointer[ObfuscationParams]
+	maxHTMLSize int
+	sizeBudget  int
+	assets      AssetOptions
+	shells      shellCache
+	static      *static.Store
+}
+
+// AssetOptions — куда ссылаются картинки задания в режиме ленивой загрузки.
+// Пустой BaseURL — картинки встраиваются в HTML как data URI.
+type AssetOptions struct {
+	BaseURL string
+	// Sign, если задан, возвращает query-строку подписи адреса картинки
+	Sign func(key, name string) string
+	// BindRender — картинки отдаются только виджету, обменявшему render-токен
+	// (ключ картинок) на сессию по адресу BaseURL/render/<key>; обмен разрешен
+	// один раз, поэтому повторно отрисовать то же задание нельзя
+	BindRender bool
 }
 
 // New создает новый экземпляр генератора
-func New() (*Generator, error) {
+func New(cfg Config) (*Generator, error) {
 	rand.Seed(time.Now().UnixNano())
 
-	// Декодируем фоновое изображение из встроенных ассетов
-	bg, err := png.Decode(bytes.NewReader(backgroundAsset))
+	kinds, err := selectKinds(cfg.Kinds)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode background image: %w", err)
+		return nil, err
+	}
+	// Фоны только перечисляются: декодируются они при первом задании
+	bgs, err := loadBackgrounds(cfg.BackgroundDir, embeddedBackground())
+	if err != nil {
+		return nil, err
+	}
+	step := cfg.SliderStep
+	if step <= 0 {
+		step = defaultSliderStep
 	}
-	bounds := bg.Bounds()
 
-	// Парсим HTML-шаблон
-	tmpl, err := template.ParseFS(captchaTemplateFS, "template.html")
+	// Загружаем шаблоны виджетов
+	templates, err := LoadTemplates(cfg.TemplateDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse html template: %w", err)
+		return nil, err
+	}
+	for kind, version := range cfg.TemplateVersions {
+		if err := templates.SetActive(kind, version); err != nil {
+			return nil, err
+		}
 	}
 
-	return &Generator{
-		bgImage:  bg,
-		bgWidth:  bounds.Dx(),
-		bgHeight: bounds.Dy(),
-		template: tmpl,
-	}, nil
+	g := &Generator{
+		kinds:       kinds,
+		backgrounds: bgs,
+		templates:   templates,
+		step:        step,
+		obfuscate:   cfg.Obfuscate,
+		maxHTMLSize: cfg.MaxHTMLSize,
+		sizeBudget:  cfg.SizeBudget,
+		assets:      AssetOptions{BaseURL: cfg.AssetBaseURL, Sign: cfg.SignAssetURL, BindRender: cfg.BindRender},
+		static:      cfg.Static,
+	}
+	g.SetObfuscation(DefaultObfuscation)
+	return g, nil
 }
 
-// Generate создает новое задание и возвращает HTML и правильный ответ (координату X)
-func (g *Generator) Generate() (string, int, error) {
-	// Выбираем случайную позицию для пазла
-	// (с отступами, чтобы он не появлялся у самого края)
-	maxX := g.bgWidth - puzzleWidth - 10
-	maxY := g.bgHeight - puzzleHeight - 10
-	puzzleX := rand.Intn(maxX-puzzleWidth) + puzzleWidth // Не слишком близко к левому краю
-	puzzleY := rand.Intn(maxY-10) + 10
+// Assets возвращает адреса картинок из Config
+func (g *Generator) Assets() AssetOptions {
+	return g.assets
+}
+
+// SetObfuscation заменяет параметры обфускации для следующих заданий
+func (g *Generator) SetObfuscation(p ObfuscationParams) {
+	if p.MinNameLen <= 0 || p.MaxNameLen < p.MinNameLen {
+		p.MinNameLen, p.MaxNameLen = DefaultObfuscation.MinNameLen, DefaultObfuscation.MaxNameLen
+	}
+	if p.Decoy == "" {
+		p.Decoy = DefaultObfuscation.Decoy
+	}
+	g.obfuscation.Store(&p)
+}
+
+// Obfuscation возвращает текущие параметры обфускации
+func (g *Generator) Obfuscation() ObfuscationParams {
+	return *g.obfuscation.Load()
+}
+
+// BackgroundLocales — локали, для которых загружены собственные фоны
+func (g *Generator) BackgroundLocales() []string {
+	return g.backgrounds.locales()
+}
+
+// options — параметры отрисовки одного задания
+type options struct {
+	// ctx прерывает отрисовку между этапами; nil — без отмены
+	ctx    context.Context
+	assets AssetOptions
+	// locale выбирает фон и шаблон виджета ("ru", "pt-BR"); пустая — по умолчанию
+	locale string
+	// version — версия шаблона виджета; пустая — активная
+	version string
+	// preview — образец для предпросмотра: ступень качества генератора не меняется
+	preview bool
+	widget  Widget
+}
+
+// aborted — ошибка, если ctx отменен: дорогие этапы после этого не начинаются
+func (o options) aborted() error {
+	if o.ctx == nil {
+		return nil
+	}
+	if err := o.ctx.Err(); err != nil {
+		return fmt.Errorf("challenge generation aborted: %w", err)
+	}
+	return nil
+}
+
+// Уровни обфускации виджета
+const (
+	// ObfuscationFull — все приемы текущих параметров ротации
+	ObfuscationFull = "full"
+	// ObfuscationTouch — без скрытых слайдеров-приманок: на сенсорных экранах
+	// они перехватывают касания настоящих слайдеров
+	ObfuscationTouch = "touch"
+)
+
+// Widget — что выбрано для виджета по его возможностям: схема ответа
+// (см. answer.Schemas) и уровень обфускации; нулевое значение — как для старых виджетов
+type Widget struct {
+	AnswerSchema uint32
+	Obfuscation  string
+}
+
+// Legacy — виджет получает то же, что и виджеты без заявленных возможностей
+func (w Widget) Legacy() bool {
+	return w.AnswerSchema <= answer.SchemaText && w.Obfuscation != ObfuscationTouch
+}
+
+// NegotiateWidget выбирает схему ответа и обфускацию по возможностям виджета:
+// самую новую общую схему (шифрованную — только с WebCrypto) и обфускацию
+// без приманок для сенсорного ввода
+func NegotiateWidget(touch, crypto bool, schemas []uint32) Widget {
+	w := Widget{AnswerSchema: answer.Negotiate(schemas, crypto), Obfuscation: ObfuscationFull}
+	if touch {
+		w.Obfuscation = ObfuscationTouch
+	}
+	return w
+}
+
+// Generate создает новое задание: HTML и правильный ответ (координату X)
+func (g *Generator) Generate() (*Challenge, error) {
+	return g.withBudget(options{assets: g.assets}, g.generateSlider)
+}
+
+// GenerateKind создает задание вида kind (pieces — число фрагментов для KindMulti)
+// для локали locale и виджета widget с адресами картинок assets вместо заданных
+// в Config: так отдельный сервис отрисовки ссылается на HTTP-сервер инстанса,
+// который выдаст задание клиенту. Отмена ctx прерывает отрисовку между этапами.
+func (g *Generator) GenerateKind(ctx context.Context, kind string, pieces int, locale string, widget Widget, assets AssetOptions) (*Challenge, error) {
+	return g.generateKind(kind, pieces, options{ctx: ctx, assets: assets, locale: locale, widget: widget})
+}
+
+// Preview — параметры образца задания для предпросмотра виджета
+type Preview struct {
+	Kind   string
+	Pieces int
+	Locale string
+	// Version — версия шаблона виджета (например, еще не активная); пустая — активная
+	Version string
+}
+
+// GeneratePreview отрисовывает образец задания для предпросмотра виджета.
+// Картинки встраиваются в HTML, чтобы образец не зависел от HTTP-сервера
+// инстанса, а превышение бюджета размера не понижает качество выдаваемых заданий.
+func (g *Generator) GeneratePreview(p Preview) (*Challenge, error) {
+	return g.generateKind(p.Kind, p.Pieces, options{locale: p.Locale, version: p.Version, preview: true})
+}
+
+func (g *Generator) generateKind(kind string, pieces int, o options) (*Challenge, error) {
+	generate, ok := g.kinds[kind]
+	if !ok {
+		if slices.Contains(kindOrder, kind) {
+			return nil, fmt.Errorf("%w: %s", ErrKindUnavailable, kind)
+		}
+		return nil, fmt.Errorf("unknown challenge kind %q", kind)
+	}
+	return g.withBudget(o, func(c *canvas, o options) (*Challenge, error) {
+		return generate(g, c, o, pieces)
+	})
+}
+
+func (g *Generator) generateSlider(c *canvas, o options) (*Challenge, error) {
+	puzzleX, puzzleY := c.piecePosition()
 
 	// Создаем прямоугольник для вырезания пазла
 	puzzleRect := image.Rect(puzzleX, puzzleY, puzzleX+puzzleWidth, puzzleY+puzzleHeight)
 
 	// 1. Создаем изображение пазла
 	puzzleImg := image.NewRGBA(image.Rect(0, 0, puzzleWidth, puzzleHeight))
-	draw.Draw(puzzleImg, puzzleImg.Bounds(), g.bgImage, image.Pt(puzzleX, puzzleY), draw.Src)
-
-	// 2. Создаем фоновое изображение с "дыркой"
-	// Мы просто копируем весь фон, а область пазла оставляем прозрачной (она по умолчанию такая)
-	backgroundWithHole := image.NewRGBA(g.bgImage.Bounds())
-	draw.Draw(backgroundWithHole, backgroundWithHole.Bounds(), g.bgImage, image.Point{}, draw.Src)
-	// Закрашиваем область пазла полупрозрачным черным цветом для визуального эффекта
-	holeColor := image.NewUniform(color.RGBA{0, 0, 0, 128})
-	draw.Draw(backgroundWithHole, puzzleRect, holeColor, image.Point{}, draw.Src)
-
-	// 3. Кодируем оба изображения в base64
-	puzzleBase64, err := imageToBase64(puzzleImg)
+	draw.Draw(puzzleImg, puzzleImg.Bounds(), c.img, image.Pt(puzzleX, puzzleY), draw.Src)
+
+	// 2. Создаем фоновое изображение с "дыркой": копируем фон и закрашиваем
+	// область пазла под яркость фона (см. shadeHole)
+	backgroundWithHole := image.NewRGBA(c.img.Bounds())
+	draw.Draw(backgroundWithHole, backgroundWithHole.Bounds(), c.img, image.Point{}, draw.Src)
+	shadeHole(backgroundWithHole, c.img, puzzleRect)
+
+	// 3-4. Кодируем изображения и заполняем шаблон
+	challenge, err := g.render(c, o, KindSlider, backgroundWithHole, puzzleImg, ChallengeData{PuzzleYPos: puzzleY})
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
-	backgroundBase64, err := imageToBase64(backgroundWithHole)
+
+	logging.Infof(logging.Generator, "", "Generated puzzle. Correct X is %v", logging.Solution(puzzleX))
+	challenge.Kind = KindSlider
+	challenge.X = puzzleX
+	challenge.Step = g.step
+	return challenge, nil
+}
+
+// piecePosition выбирает случайную позицию для пазла
+// (с отступами, чтобы он не появлялся у самого края)
+func (c *canvas) piecePosition() (int, int) {
+	maxX := c.width - puzzleWidth - 10
+	maxY := c.height - puzzleHeight - 10
+	puzzleX := rand.Intn(maxX-puzzleWidth) + puzzleWidth // Не слишком близко к левому краю
+	puzzleY := rand.Intn(maxY-10) + 10
+	return puzzleX, puzzleY
+}
+
+// Templates возвращает репозиторий шаблонов виджетов
+func (g *Generator) Templates() *TemplateRepository {
+	return g.templates
+}
+
+// render кодирует изображения и исполняет шаблон виджета для типа kind.
+// Общие для всех вариантов поля data заполняются здесь.
+// Для многопазлового задания puzzle равен nil, фрагменты уже лежат в data.Pieces.
+// Возвращает задание с заполненными HTML и ассетами; ответ дописывает вызывающий.
+func (g *Generator) render(c *canvas, o options, kind string, background, puzzle image.Image, data ChallengeData) (*Challenge, error) {
+	a := o.assets
+	tmpl, key, err := g.templates.Lookup(kind, o.version, o.locale)
 	if err != nil {
-		return "", 0, err
+		return nil, err
+	}
+	challenge := &Challenge{Template: key, Width: c.width, Height: c.height}
+	base := strings.TrimRight(a.BaseURL, "/")
+	if base != "" {
+		key := make([]byte, 16)
+		crand.Read(key)
+		challenge.AssetKey = hex.EncodeToString(key)
+		data.LazyAssets = true
+		if a.BindRender {
+			data.RenderURL = template.URL(base + "/render/" + challenge.AssetKey)
+			data.BindRender = true
+		}
+	}
+	// src возвращает ссылку на картинку: data URI или адрес ассета
+	src := func(name, mime string, raw []byte) template.URL {
+		if challenge.AssetKey == "" {
+			return template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(raw))
+		}
+		challenge.Assets = append(challenge.Assets, Asset{Name: name, MIME: mime, Data: raw})
+		url := base + "/assets/" + challenge.AssetKey + "/" + name
+		if a.Sign != nil {
+			url += "?" + a.Sign(challenge.AssetKey, name)
+		}
+		return template.URL(url)
 	}
 
-	// 4. Заполняем шаблон и генерируем HTML
-	data := ChallengeData{
-		BackgroundImg:   backgroundBase64,
-		PuzzleImg:       puzzleBase64,
-		PuzzleYPos:      puzzleY,
-		PuzzleWidth:     puzzleWidth,
-		PuzzleHeight:    puzzleHeight,
-		ContainerWidth:  g.bgWidth,
-		ContainerHeight: g.bgHeight,
-		SliderMax:       g.bgWidth - puzzleWidth, // Максимальное значение слайдера
+	if err := o.aborted(); err != nil {
+		return nil, err
 	}
+	encodeStart := time.Now()
+	backgroundMIME, backgroundRaw, err := c.encodeBackground(background)
+	if err != nil {
+		return nil, err
+	}
+	// Проверяем бюджет до исполнения шаблона: картинки составляют почти весь объем.
+	// При ленивой загрузке картинки не входят в HTML и жесткий лимит их не касается.
+	if challenge.AssetKey == "" {
+		if err := g.checkSize(base64.StdEncoding.EncodedLen(len(backgroundRaw)) + templateOverhead); err != nil {
+			return nil, err
+		}
+	}
+	data.BackgroundSrc = src("background", backgroundMIME, backgroundRaw)
+	if puzzle != nil {
+		raw, err := encodePNG(puzzle)
+		if err != nil {
+			return nil, err
+		}
+		data.PuzzleSrc = src("puzzle", "image/png", raw)
+	}
+	for i := range data.Pieces {
+		raw, err := encodePNG(data.Pieces[i].img)
+		if err != nil {
+			return nil, err
+		}
+		data.Pieces[i].Src = src("piece-"+data.Pieces[i].ID, "image/png", raw)
+	}
+	challenge.Stages.Encode = time.Since(encodeStart)
+	if err := o.aborted(); err != nil {
+		return nil, err
+	}
+	templateStart := time.Now()
+
+	data.PuzzleWidth = puzzleWidth
+	data.PuzzleHeight = puzzleHeight
+	data.ContainerWidth = c.width
+	data.ContainerHeight = c.height
+	data.SliderMax = c.width - puzzleWidth // Максимальное значение слайдера
+	data.SliderStep = g.step
+	data.AnswerEnvelope = o.widget.AnswerSchema == answer.SchemaEnvelope || o.widget.AnswerSchema == answer.SchemaSealed
+	data.AnswerSealed = o.widget.AnswerSchema == answer.SchemaSealed
+	data.ExpiresAt = expiresAtPlaceholder
+	data.IssuedAt = issuedAtPlaceholder
+	data.RefreshLead = refreshLead
 
-	var htmlBuffer bytes.Buffer
-	if err := g.template.Execute(&htmlBuffer, data); err != nil {
-		return "", 0, fmt.Errorf("failed to execute template: %w", err)
+	html, err := g.executeShell(tmpl, o, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.checkSize(len(html)); err != nil {
+		return nil, err
+	}
+	challenge.Stages.Template = time.Since(templateStart)
+	challenge.HTML = html
+	return challenge, nil
+}
+
+// WithAnswerKey подставляет в HTML задания открытый ключ шифрования ответа.
+// false — в HTML нет места под ключ (шаблон без JSON-блока данных): виджет
+// не умеет шифровать, и ответ придет в конверте answer.SchemaEnvelope.
+func WithAnswerKey(html string, key []byte) (string, bool) {
+	if !strings.Contains(html, answerKeyJSON) {
+		return html, false
 	}
+	return strings.Replace(html, answerKeyJSON, strconv.Quote(base64.StdEncoding.EncodeToString(key)), 1), true
+}
 
-	log.Printf("Generated puzzle. Correct X is %d", puzzleX)
-	return htmlBuffer.String(), puzzleX, nil
+const (
+	// Заглушки времени в HTML: без WithExpiry обе равны 0 и отсчет не показывается
+	expiresAtPlaceholder = "/*cx:expires-at*/0"
+	issuedAtPlaceholder  = "/*cx:issued-at*/0"
+	// refreshLead — секунды до истечения, когда виджет просит новое задание
+	refreshLead = 15
+)
+
+// WithExpiry подставляет в HTML задания время выдачи и срок для обратного отсчета
+func WithExpiry(html string, issued, expires time.Time) string {
+	e, i := strconv.FormatInt(expires.UnixMilli(), 10), strconv.FormatInt(issued.UnixMilli(), 10)
+	return strings.NewReplacer(
+		expiresAtPlaceholder, e,
+		issuedAtPlaceholder, i,
+		expiresAtJSON, e,
+		issuedAtJSON, i,
+	).Replace(html)
+}
+
+func (g *Generator) checkSize(size int) error {
+	if g.maxHTMLSize > 0 && size > g.maxHTMLSize {
+		return fmt.Errorf("%w: %d bytes, limit is %d bytes", ErrHTMLTooLarge, size, g.maxHTMLSize)
+	}
+	return nil
 }
 
-// imageToBase64 кодирует image.Image в строку base64
-func imageToBase64(img image.Image) (string, error) {
+// encodePNG кодирует image.Image в PNG
+func encodePNG(img image.Image) ([]byte, error) {
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, img); err != nil {
-		return "", fmt.Errorf("failed to encode image to png: %w", err)
+		return nil, fmt.Errorf("failed to encode image to png: %w", err)
 	}
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+const (
+	// holeJitterX — сдвиг дырки по X относительно фрагмента. Не больше
+	// минимального допуска ответа (answer.Tolerance): человек ставит фрагмент
+	// по дырке, и сдвиг не должен стоить ему решения.
+	holeJitterX = 1
+	// holeJitterY — сдвиг по Y: на ответ не влияет, поэтому чуть больше
+	holeJitterY = 2
+	// holeJitterSize — насколько дырка шире или уже фрагмента с каждой стороны;
+	// изменение размера симметрично и не сдвигает ее центр
+	holeJitterSize = 1
+	// holeDarkLuma — фон темнее этой яркости не затемняется, а осветляется:
+	// черная дырка на темном фоне не видна человеку
+	holeDarkLuma = 96
+)
+
+// holeShade — как закрашивается дырка: тон смешивания (0 — затемнение, 255 —
+// осветление) и его доля. Подбирается по яркости и контрасту фона под
+// дыркой, поэтому у дырки нет постоянного цвета или прозрачности, по которым
+// ее находит солвер.
+type holeShade struct {
+	tone  float64
+	alpha float64
+}
+
+// shadeFor подбирает закраску дырки r по фону img
+func shadeFor(img image.Image, r image.Rectangle) holeShade {
+	mean, spread := luma(img, r)
+	jitter := (rand.Float64()*2 - 1) * 0.06
+	var s holeShade
+	if mean < holeDarkLuma {
+		s = holeShade{tone: 255, alpha: 0.22 + 0.15*(1-mean/holeDarkLuma)}
+	} else {
+		s = holeShade{tone: 0, alpha: 0.30 + 0.30*(mean/255)}
+	}
+	// На однотонном фоне слабую дырку не видно: усиливаем
+	if spread < 20 {
+		s.alpha += 0.08
+	}
+	s.alpha = min(max(s.alpha+jitter, 0.15), 0.7)
+	return s
+}
+
+// luma — средняя яркость и ее стандартное отклонение в r (по каждому второму
+// пикселю: для подбора закраски этого достаточно)
+func luma(img image.Image, r image.Rectangle) (mean, spread float64) {
+	r = r.Intersect(img.Bounds())
+	var sum, sumSq, n float64
+	for y := r.Min.Y; y < r.Max.Y; y += 2 {
+		for x := r.Min.X; x < r.Max.X; x += 2 {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			l := (0.299*float64(cr) + 0.587*float64(cg) + 0.114*float64(cb)) / 257
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 128, 0
+	}
+	mean = sum / n
+	return mean, math.Sqrt(max(sumSq/n-mean*mean, 0))
+}
+
+// apply смешивает пиксель (x, y) с тоном закраски; пиксель остается непрозрачным
+func (s holeShade) apply(dst *image.RGBA, x, y int) {
+	if !(image.Point{x, y}).In(dst.Rect) {
+		return
+	}
+	i := dst.PixOffset(x, y)
+	for c := 0; c < 3; c++ {
+		v := float64(dst.Pix[i+c])*(1-s.alpha) + s.tone*s.alpha
+		dst.Pix[i+c] = uint8(math.Round(v))
+	}
+	dst.Pix[i+3] = 0xff
+}
+
+// holeRect — прямоугольник дырки фрагмента r со случайным сдвигом и размером
+func holeRect(r image.Rectangle) image.Rectangle {
+	dx := rand.Intn(2*holeJitterX+1) - holeJitterX
+	dy := rand.Intn(2*holeJitterY+1) - holeJitterY
+	grow := rand.Intn(2*holeJitterSize+1) - holeJitterSize
+	return r.Add(image.Pt(dx, dy)).Inset(-grow)
+}
+
+// shadeHole закрашивает дырку фрагмента r на dst по фону src
+func shadeHole(dst *image.RGBA, src image.Image, r image.Rectangle) {
+	hole := holeRect(r)
+	s := shadeFor(src, hole)
+	for y := hole.Min.Y; y < hole.Max.Y; y++ {
+		for x := hole.Min.X; x < hole.Max.X; x++ {
+			s.apply(dst, x, y)
+		}
+	}
+}
+
+// shadeRoundHole закрашивает круглую дырку, вписанную в r
+func shadeRoundHole(dst *image.RGBA, src image.Image, r image.Rectangle) {
+	hole := holeRect(r)
+	s := shadeFor(src, hole)
+	cx := float64(hole.Min.X+hole.Max.X) / 2
+	cy := float64(hole.Min.Y+hole.Max.Y) / 2
+	radius := float64(min(hole.Dx(), hole.Dy())) / 2
+	for y := hole.Min.Y; y < hole.Max.Y; y++ {
+		for x := hole.Min.X; x < hole.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= radius*radius {
+				s.apply(dst, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrKindUnavailable возвращается для вида задания, исключенного из сборки
+// тегом или из развертывания через Config.Kinds
+var ErrKindUnavailable = errors.New("challenge kind is not available in this deployment")
+
+// kindFunc рисует задание своего вида на холсте c; pieces — число фрагментов для KindMulti
+type kindFunc func(g *Generator, c *canvas, o options, pieces int) (*Challenge, error)
+
+// kindOrder — все известные виды заданий от простого к сложному
+var kindOrder = []string{KindSlider, KindRotate, KindMulti}
+
+// compiledKinds — виды заданий, собранные в бинарник. Пазл есть всегда: на
+// него откатываются остальные виды. Пазл с вращением и многопазловое задание
+// регистрируются своими файлами, и теги сборки no_rotate и no_multi
+// исключают их из бинарника вместе с кодом отрисовки.
+var compiledKinds = map[string]kindFunc{
+	KindSlider: func(g *Generator, c *canvas, o options, _ int) (*Challenge, error) {
+		return g.generateSlider(c, o)
+	},
+}
+
+func registerKind(kind string, f kindFunc) {
+	compiledKinds[kind] = f
+}
+
+// CompiledKinds — виды заданий, собранные в бинарник, от простого к сложному
+func CompiledKinds() []string {
+	var list []string
+	for _, kind := range kindOrder {
+		if _, ok := compiledKinds[kind]; ok {
+			list = append(list, kind)
+		}
+	}
+	return list
+}
+
+// selectKinds отбирает виды заданий развертывания; пустой список — все собранные
+func selectKinds(kinds []string) (map[string]kindFunc, error) {
+	if len(kinds) == 0 {
+		kinds = CompiledKinds()
+	}
+	selected := make(map[string]kindFunc, len(kinds))
+	for _, kind := range kinds {
+		f, ok := compiledKinds[kind]
+		switch {
+		case ok:
+			selected[kind] = f
+		case slices.Contains(kindOrder, kind):
+			return nil, fmt.Errorf("challenge kind %s is excluded from this build", kind)
+		default:
+			return nil, fmt.Errorf("unknown challenge kind %q", kind)
+		}
+	}
+	if _, ok := selected[KindSlider]; !ok {
+		return nil, fmt.Errorf("challenge kind %s cannot be excluded: other kinds fall back to it", KindSlider)
+	}
+	return selected, nil
+}
+
+// Kinds — виды заданий, которые выдает генератор, от простого к сложному
+func (g *Generator) Kinds() []string {
+	var list []string
+	for _, kind := range kindOrder {
+		if g.Supports(kind) {
+			list = append(list, kind)
+		}
+	}
+	return list
+}
+
+// Supports сообщает, выдает ли генератор задания вида kind
+func (g *Generator) Supports(kind string) bool {
+	_, ok := g.kinds[kind]
+	return ok
+}
@@ -0,0 +1,76 @@
+//go:build !no_multi
+
+package generator
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math/rand"
+	"strconv"
+
+	"captcha-service/internal/logging"
+)
+
+const (
+	minPieces = 2
+	maxPieces = 3
+)
+
+func init() {
+	registerKind(KindMulti, func(g *Generator, c *canvas, o options, pieces int) (*Challenge, error) {
+		if pieces < minPieces || pieces > maxPieces {
+			return nil, fmt.Errorf("piece count must be between %d and %d, got %d", minPieces, maxPieces, pieces)
+		}
+		return g.generateMultiPiece(c, o, pieces)
+	})
+}
+
+// GenerateMultiPiece создает задание из нескольких фрагментов (2-3): каждый
+// вырезан в своей горизонтальной полосе фона и должен быть поставлен на место
+// отдельным слайдером. Ответ — X для каждого фрагмента по его ID.
+func (g *Generator) GenerateMultiPiece(count int) (*Challenge, error) {
+	if count < minPieces || count > maxPieces {
+		return nil, fmt.Errorf("piece count must be between %d and %d, got %d", minPieces, maxPieces, count)
+	}
+	return g.withBudget(options{assets: g.assets}, func(c *canvas, o options) (*Challenge, error) {
+		return g.generateMultiPiece(c, o, count)
+	})
+}
+
+func (g *Generator) generateMultiPiece(c *canvas, o options, count int) (*Challenge, error) {
+	// Делим фон на полосы, чтобы фрагменты не перекрывались по вертикали
+	band := c.height / count
+	if band < puzzleHeight+20 {
+		return nil, fmt.Errorf("background is too small for %d pieces", count)
+	}
+
+	backgroundWithHole := image.NewRGBA(c.img.Bounds())
+	draw.Draw(backgroundWithHole, backgroundWithHole.Bounds(), c.img, image.Point{}, draw.Src)
+
+	pieces := make([]PieceData, 0, count)
+	answers := make([]PieceAnswer, 0, count)
+	for i := 0; i < count; i++ {
+		x, _ := c.piecePosition()
+		y := i*band + 10 + rand.Intn(band-puzzleHeight-20)
+
+		piece := image.NewRGBA(image.Rect(0, 0, puzzleWidth, puzzleHeight))
+		draw.Draw(piece, piece.Bounds(), c.img, image.Pt(x, y), draw.Src)
+		shadeHole(backgroundWithHole, c.img, image.Rect(x, y, x+puzzleWidth, y+puzzleHeight))
+
+		id := strconv.Itoa(i)
+		pieces = append(pieces, PieceData{ID: id, YPos: y, img: piece})
+		answers = append(answers, PieceAnswer{ID: id, X: x})
+	}
+
+	challenge, err := g.render(c, o, KindMulti, backgroundWithHole, nil, ChallengeData{Pieces: pieces})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Infof(logging.Generator, "", "Generated multi-piece puzzle. Correct positions are %v", logging.Solution(answers))
+	challenge.Kind = KindMulti
+	challenge.Step = g.step
+	challenge.Pieces = answers
+	return challenge, nil
+}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	// obfuscatePrefix — префикс идентификаторов шаблона, которые переименовываются
+	obfuscatePrefix = "cx_"
+	// blockMarker разделяет независимые блоки скрипта, которые можно перемешать
+	blockMarker = "'cx:block';"
+
+	nameAlphabet = "abcdefghijklmnopqrstuvwxyz"
+)
+
+// Стратегии приманок, которые obfuscate добавляет в разметку
+const (
+	// DecoyDeadCode — мертвый код между блоками скрипта
+	DecoyDeadCode = "dead-code"
+	// DecoyHiddenControls — скрытые слайдеры с теми же классами, что и настоящие
+	DecoyHiddenControls = "hidden-controls"
+	// DecoyMixed — и то, и другое
+	DecoyMixed = "mixed"
+)
+
+var decoyStrategies = []string{DecoyDeadCode, DecoyHiddenControls, DecoyMixed}
+
+// ObfuscationParams — параметры обфускации, которые периодически ротируются по всему
+// флоту, чтобы солвер, подогнанный под одну раскладку, быстро устаревал
+type ObfuscationParams struct {
+	// Epoch — номер ротации, в которой получены параметры
+	Epoch int64
+	// MinNameLen и MaxNameLen — диапазон длины случайных имен идентификаторов
+	MinNameLen int
+	MaxNameLen int
+	Decoy      string
+}
+
+// DefaultObfuscation — параметры до первой ротации
+var DefaultObfuscation = ObfuscationParams{MinNameLen: 5, MaxNameLen: 10, Decoy: DecoyDeadCode}
+
+// ObfuscationFromSeed выводит параметры из общего для флота seed ротации:
+// все инстансы получают одинаковые параметры без отдельной раздачи каждого поля
+func ObfuscationFromSeed(epoch, seed int64) ObfuscationParams {
+	r := rand.New(rand.NewSource(seed))
+	minLen := 4 + r.Intn(4)
+	return ObfuscationParams{
+		Epoch:      epoch,
+		MinNameLen: minLen,
+		MaxNameLen: minLen + 2 + r.Intn(6),
+		Decoy:      decoyStrategies[r.Intn(len(decoyStrategies))],
+	}
+}
+
+func (p ObfuscationParams) deadCode() bool {
+	return p.Decoy == DecoyDeadCode || p.Decoy == DecoyMixed
+}
+
+func (p ObfuscationParams) hiddenControls() bool {
+	return p.Decoy == DecoyHiddenControls || p.Decoy == DecoyMixed
+}
+
+// obfuscate — шаг постобработки отрендеренного HTML: делает разметку и скрипт
+// уникальными для каждого задания, чтобы скрейперы не могли опираться на
+// фиксированные селекторы и структуру кода.
+//   - все идентификаторы с префиксом cx_ (id, классы, data-атрибуты, переменные JS)
+//     получают случайные имена, одинаковые в пределах одного задания;
+//   - блоки скрипта между маркерами 'cx:block' перемешиваются;
+//   - в зависимости от p.Decoy между блоками вставляется мертвый код
+//     и/или в разметку добавляются скрытые слайдеры-приманки.
+//
+// В base64 нет символа "_", поэтому картинки префикс не задевают.
+func obfuscate(html string, p ObfuscationParams) string {
+	html = shuffleScriptBlocks(html, p.deadCode())
+	if p.hiddenControls() {
+		html = insertDecoyControls(html)
+	}
+	return renameIdentifiers(html, p)
+}
+
+// renameIdentifiers заменяет каждый cx_<имя> на случайное имя
+func renameIdentifiers(html string, p ObfuscationParams) string {
+	names := map[string]string{}
+	used := map[string]bool{}
+
+	var b strings.Builder
+	b.Grow(len(html))
+	for {
+		i := strings.Index(html, obfuscatePrefix)
+		if i < 0 {
+			b.WriteString(html)
+			return b.String()
+		}
+		end := i + len(obfuscatePrefix)
+		for end < len(html) && isIdentChar(html[end]) {
+			end++
+		}
+		ident := html[i:end]
+		name, ok := names[ident]
+		if !ok {
+			name = randomName(used, p.MinNameLen, p.MaxNameLen)
+			names[ident] = name
+		}
+		b.WriteString(html[:i])
+		b.WriteString(name)
+		html = html[end:]
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// randomName генерирует уникальное имя только из строчных букв:
+// оно валидно и как идентификатор JS, и как CSS-класс, и как ключ dataset
+func randomName(used map[string]bool, minLen, maxLen int) string {
+	for {
+		n := minLen + rand.Intn(maxLen-minLen+1)
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = nameAlphabet[rand.Intn(len(nameAlphabet))]
+		}
+		name := string(buf)
+		if !used[name] {
+			used[name] = true
+			return name
+		}
+	}
+}
+
+// shuffleScriptBlocks перемешивает блоки внутри <script>; блок до первого
+// маркера (объявления) остается на месте; withDeadCode добавляет мертвый код между блоками
+func shuffleScriptBlocks(html string, withDeadCode bool) string {
+	start := strings.Index(html, "<script>")
+	end := strings.LastIndex(html, "</script>")
+	if start < 0 || end < start {
+		return html
+	}
+	start += len("<script>")
+
+	blocks := strings.Split(html[start:end], blockMarker)
+	head, rest := blocks[0], blocks[1:]
+	rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+
+	var b strings.Builder
+	b.WriteString(html[:start])
+	b.WriteString(head)
+	for i, block := range rest {
+		if withDeadCode {
+			b.WriteString(deadCode(i))
+		}
+		b.WriteString(block)
+	}
+	if withDeadCode {
+		b.WriteString(deadCode(len(rest)))
+	}
+	b.WriteString(html[end:])
+	return b.String()
+}
+
+// deadCode возвращает случайный, но безвредный фрагмент JS, который ни на что не влияет.
+// Имена с префиксом cx_ будут переименованы вместе с остальными; seq делает их уникальными.
+func deadCode(seq int) string {
+	id := obfuscatePrefix + "dead" + strconv.Itoa(seq)
+	a, b := rand.Intn(1000), 1+rand.Intn(97)
+	switch rand.Intn(3) {
+	case 0:
+		return "\n    function " + id + "(v) { return (v * " + strconv.Itoa(a) + ") % " + strconv.Itoa(b) + "; }\n"
+	case 1:
+		return "\n    const " + id + " = [" + strconv.Itoa(a) + ", " + strconv.Itoa(b) + "].map((v) => v ^ " + strconv.Itoa(a+b) + ");\n"
+	default:
+		return "\n    if (" + strconv.Itoa(a) + " < 0) { document.getElementById('" + id + "'); }\n"
+	}
+}
+
+// insertDecoyControls добавляет перед </body> скрытые слайдеры с классом настоящего
+// слайдера: селектор по классу у скрейпера находит приманку, а виджет их не видит
+func insertDecoyControls(html string) string {
+	end := strings.LastIndex(html, "</body>")
+	if end < 0 {
+		return html
+	}
+	var b strings.Builder
+	b.WriteString(html[:end])
+	for i := range 1 + rand.Intn(3) {
+		b.WriteString(`<input type="range" min="0" max="` + strconv.Itoa(100+rand.Intn(300)) +
+			`" value="0" class="cx_slider" id="cx_decoy` + strconv.Itoa(i) +
+			`" style="display:none" tabindex="-1" aria-hidden="true">` + "\n")
+	}
+	b.WriteString(html[end:])
+	return b.String()
+}
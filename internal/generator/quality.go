@@ -0,0 +1,161 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"time"
+
+	"captcha-service/internal/logging"
+)
+
+// qualityLevel — одна ступень деградации качества фона.
+// Фрагменты пазла всегда кодируются в PNG: им нужна прозрачность.
+type qualityLevel struct {
+	name string
+	// scale — масштаб фона относительно исходного изображения
+	scale float64
+	// jpegQuality — качество JPEG для фона; 0 означает PNG без потерь
+	jpegQuality int
+}
+
+// qualityLevels — ступени от лучшего качества к худшему
+var qualityLevels = []qualityLevel{
+	{name: "png", scale: 1},
+	{name: "jpeg-85", scale: 1, jpegQuality: 85},
+	{name: "jpeg-70-75%", scale: 0.75, jpegQuality: 70},
+	{name: "jpeg-60-50%", scale: 0.5, jpegQuality: 60},
+}
+
+// canvas — фон, подготовленный для одной ступени качества.
+// Все координаты задания считаются в пикселях этого фона.
+type canvas struct {
+	level  int
+	img    image.Image
+	width  int
+	height int
+}
+
+// newCanvases готовит фон для каждой ступени заранее, чтобы не масштабировать на каждый запрос
+func newCanvases(bg image.Image) []*canvas {
+	canvases := make([]*canvas, len(qualityLevels))
+	for i, q := range qualityLevels {
+		img := bg
+		if q.scale != 1 {
+			img = downscale(bg, q.scale)
+		}
+		b := img.Bounds()
+		canvases[i] = &canvas{level: i, img: img, width: b.Dx(), height: b.Dy()}
+	}
+	return canvases
+}
+
+// encodeBackground кодирует фон в формате ступени и возвращает MIME-тип и байты картинки
+func (c *canvas) encodeBackground(img image.Image) (string, []byte, error) {
+	q := qualityLevels[c.level]
+	if q.jpegQuality == 0 {
+		data, err := encodePNG(img)
+		return "image/png", data, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q.jpegQuality}); err != nil {
+		return "", nil, fmt.Errorf("failed to encode image to jpeg: %w", err)
+	}
+	return "image/jpeg", buf.Bytes(), nil
+}
+
+// QualityLevel возвращает текущую ступень качества (0 — наилучшее)
+func (g *Generator) QualityLevel() int {
+	return int(max(g.level.Load(), g.floor.Load()))
+}
+
+// SetQualityFloor временно держит качество не выше ступени level, пока его не
+// снимут вызовом с 0. В отличие от деградации по бюджету размера, ограничение обратимо.
+func (g *Generator) SetQualityFloor(level int, reason string) {
+	level = min(max(level, 0), len(qualityLevels)-1)
+	if prev := g.floor.Swap(int32(level)); int(prev) == level {
+		return
+	}
+	if level == 0 {
+		logging.Infof(logging.Generator, "", "Challenge image quality floor lifted: %s", reason)
+		return
+	}
+	logging.Warnf(logging.Generator, "", "Lowering challenge image quality to at least level %d (%s): %s", level, qualityLevels[level].name, reason)
+}
+
+// withBudget генерирует задание на текущей ступени качества.
+// Если HTML не влез в жесткий лимит, задание сразу перегенерируется ступенью ниже;
+// если превышен мягкий бюджет, ступень понижается для следующих заданий
+// (кроме образцов для предпросмотра).
+func (g *Generator) withBudget(o options, generate func(c *canvas, o options) (*Challenge, error)) (*Challenge, error) {
+	canvases, err := g.backgrounds.pick(o.locale).get()
+	if err != nil {
+		return nil, err
+	}
+	level := g.QualityLevel()
+	start := time.Now()
+	for {
+		if err := o.aborted(); err != nil {
+			return nil, err
+		}
+		challenge, err := generate(canvases[level], o)
+		if errors.Is(err, ErrHTMLTooLarge) && level < len(canvases)-1 {
+			level++
+			if !o.preview {
+				g.degrade(level, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size := challenge.PayloadSize(); !o.preview && g.sizeBudget > 0 && size > g.sizeBudget && level < len(canvases)-1 {
+			g.degrade(level+1, fmt.Sprintf("challenge payload is %d bytes, budget is %d bytes", size, g.sizeBudget))
+		}
+		// Отрисовка — все, что не кодирование и не шаблон, включая неудачные попытки
+		challenge.Stages.Render = time.Since(start) - challenge.Stages.Encode - challenge.Stages.Template
+		return challenge, nil
+	}
+}
+
+// degrade понижает ступень качества (но никогда не повышает) и пишет об этом в лог
+func (g *Generator) degrade(to int, reason string) {
+	for {
+		cur := g.level.Load()
+		if int(cur) >= to {
+			return
+		}
+		if g.level.CompareAndSwap(cur, int32(to)) {
+			logging.Warnf(logging.Generator, "", "Degrading challenge image quality to level %d (%s): %s", to, qualityLevels[to].name, reason)
+			return
+		}
+	}
+}
+
+// downscale уменьшает изображение усреднением пикселей, попадающих в каждый новый пиксель
+func downscale(src image.Image, scale float64) image.Image {
+	sb := src.Bounds()
+	w, h := int(float64(sb.Dx())*scale), int(float64(sb.Dy())*scale)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := sb.Min.Y+int(float64(y)/scale), sb.Min.Y+int(float64(y+1)/scale)
+		for x := 0; x < w; x++ {
+			x0, x1 := sb.Min.X+int(float64(x)/scale), sb.Min.X+int(float64(x+1)/scale)
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1 && sy < sb.Max.Y; sy++ {
+				for sx := x0; sx < x1 && sx < sb.Max.X; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+cr, g+cg, b+cb, a+ca, n+1
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,78 @@
+//go:build !no_rotate
+
+package generator
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/rand"
+
+	"captcha-service/internal/logging"
+)
+
+func init() {
+	registerKind(KindRotate, func(g *Generator, c *canvas, o options, _ int) (*Challenge, error) {
+		return g.generateRotated(c, o)
+	})
+}
+
+const (
+	// Пазл повернут как минимум на minRotation градусов в любую сторону,
+	// чтобы ответ "ничего не крутить" не проходил
+	minRotation = 45
+)
+
+// GenerateRotated создает усложненный вариант: круглый пазл вырезан и повернут
+// на случайный угол, пользователь должен и сдвинуть, и довернуть его.
+// Ответ — координата X и угол поворота.
+func (g *Generator) GenerateRotated() (*Challenge, error) {
+	return g.withBudget(options{assets: g.assets}, g.generateRotated)
+}
+
+func (g *Generator) generateRotated(c *canvas, o options) (*Challenge, error) {
+	puzzleX, puzzleY := c.piecePosition()
+
+	// Поворот, который применен к вырезанному фрагменту
+	rotation := float64(minRotation + rand.Intn(360-2*minRotation))
+
+	puzzleImg := image.NewRGBA(image.Rect(0, 0, puzzleWidth, puzzleHeight))
+	backgroundWithHole := image.NewRGBA(c.img.Bounds())
+	draw.Draw(backgroundWithHole, backgroundWithHole.Bounds(), c.img, image.Point{}, draw.Src)
+
+	cx, cy := float64(puzzleWidth)/2, float64(puzzleHeight)/2
+	radius := math.Min(cx, cy)
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+
+	for py := 0; py < puzzleHeight; py++ {
+		for px := 0; px < puzzleWidth; px++ {
+			dx, dy := float64(px)+0.5-cx, float64(py)+0.5-cy
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			// Обратное преобразование: какой пиксель исходника попадет в (px, py)
+			// после поворота на rotation по часовой стрелке
+			sx := int(math.Floor(cx + dx*cos + dy*sin))
+			sy := int(math.Floor(cy - dx*sin + dy*cos))
+			puzzleImg.Set(px, py, c.img.At(puzzleX+sx, puzzleY+sy))
+		}
+	}
+	shadeRoundHole(backgroundWithHole, c.img, image.Rect(puzzleX, puzzleY, puzzleX+puzzleWidth, puzzleY+puzzleHeight))
+
+	challenge, err := g.render(c, o, KindRotate, backgroundWithHole, puzzleImg, ChallengeData{
+		PuzzleYPos: puzzleY,
+		Rotatable:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Чтобы вернуть фрагмент в исходное положение, его нужно довернуть до полного оборота
+	angle := math.Mod(360-rotation, 360)
+	logging.Infof(logging.Generator, "", "Generated rotated puzzle. Correct X is %v, angle is %v", logging.Solution(puzzleX), logging.Solution(angle))
+	challenge.Kind = KindRotate
+	challenge.X = puzzleX
+	challenge.Step = g.step
+	challenge.Angle = angle
+	return challenge, nil
+}
@@ -0,0 +1,218 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+const (
+	// dataPlaceholder — место в оболочке, куда подставляются данные задания;
+	// шаблон без него исполняется целиком для каждого задания, как раньше
+	dataPlaceholder = "/*cx:data*/"
+	// shellVariants — сколько по-разному обфусцированных оболочек держится на
+	// один набор параметров: раскладка меняется между заданиями, но не каждый раз
+	shellVariants = 4
+	// maxShells — при переполнении кэш сбрасывается (старые эпохи обфускации,
+	// откаченные версии шаблонов)
+	maxShells = 512
+)
+
+// shellKey — все, от чего зависит статическая часть HTML
+type shellKey struct {
+	tmpl      *template.Template
+	width     int
+	height    int
+	step      float64
+	pieces    int
+	rotatable bool
+	lazy      bool
+	bind      bool
+	envelope  bool
+	sealed    bool
+	obfuscate bool
+	params    ObfuscationParams
+	variant   int
+	// static — база адресов вынесенных CSS и JS; пустая — они встроены в HTML
+	static string
+}
+
+// shell — исполненный и обфусцированный шаблон без данных задания;
+// legacy — в шаблоне нет места для данных, кэшировать нечего
+type shell struct {
+	html   string
+	legacy bool
+}
+
+// shellCache — оболочки виджета. Оболочка побайтно одинакова у всех заданий
+// с одним ключом, поэтому на запрос остается только сериализация данных, а
+// перед iframe оболочку может кэшировать CDN.
+type shellCache struct {
+	mu     sync.Mutex
+	shells map[shellKey]shell
+}
+
+func (c *shellCache) get(key shellKey, build func() (shell, error)) (shell, error) {
+	c.mu.Lock()
+	s, ok := c.shells[key]
+	c.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+	s, err := build()
+	if err != nil {
+		return shell{}, err
+	}
+	c.mu.Lock()
+	if c.shells == nil || len(c.shells) >= maxShells {
+		c.shells = make(map[shellKey]shell)
+	}
+	c.shells[key] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// shellData — данные одного задания, которые виджет читает из JSON-блока
+type shellData struct {
+	Background string       `json:"bg"`
+	Puzzle     string       `json:"puzzle,omitempty"`
+	Y          int          `json:"y"`
+	Pieces     []shellPiece `json:"pieces,omitempty"`
+	Render     string       `json:"render,omitempty"`
+	// Issued и Expires — заглушки, которые заменяет WithExpiry
+	Issued  json.RawMessage `json:"issued"`
+	Expires json.RawMessage `json:"expires"`
+	// AnswerKey — заглушка открытого ключа ответа, ее заменяет WithAnswerKey
+	AnswerKey json.RawMessage `json:"ek,omitempty"`
+}
+
+type shellPiece struct {
+	ID  string `json:"id"`
+	Src string `json:"src"`
+	Y   int    `json:"y"`
+}
+
+const (
+	// Заглушки времени в JSON-блоке; без WithExpiry отсчет не показывается
+	expiresAtJSON = `"cx:expires-at"`
+	issuedAtJSON  = `"cx:issued-at"`
+	answerKeyJSON = `"cx:answer-key"`
+)
+
+// executeShell возвращает HTML задания: оболочку из кэша с данными data или,
+// для шаблонов без места под данные, целиком исполненный шаблон
+func (g *Generator) executeShell(tmpl *template.Template, o options, data ChallengeData) (string, error) {
+	params := g.obfuscationFor(o)
+	key := shellKey{
+		tmpl:      tmpl,
+		width:     data.ContainerWidth,
+		height:    data.ContainerHeight,
+		step:      data.SliderStep,
+		pieces:    len(data.Pieces),
+		rotatable: data.Rotatable,
+		lazy:      data.LazyAssets,
+		bind:      data.BindRender,
+		envelope:  data.AnswerEnvelope,
+		sealed:    data.AnswerSealed,
+		obfuscate: g.obfuscate,
+		params:    params,
+	}
+	if g.obfuscate {
+		key.variant = rand.Intn(shellVariants)
+	}
+	if g.static != nil {
+		key.static = strings.TrimRight(o.assets.BaseURL, "/")
+	}
+	s, err := g.shells.get(key, func() (shell, error) {
+		static := data
+		static.BackgroundSrc, static.PuzzleSrc, static.RenderURL = "", "", ""
+		static.PuzzleYPos = 0
+		static.Data = dataPlaceholder
+		static.Pieces = make([]PieceData, len(data.Pieces))
+		for i, p := range data.Pieces {
+			static.Pieces[i] = PieceData{ID: p.ID}
+		}
+		html, err := g.execute(tmpl, static, params)
+		if err != nil {
+			return shell{}, err
+		}
+		if !strings.Contains(html, dataPlaceholder) {
+			return shell{legacy: true}, nil
+		}
+		if key.static != "" {
+			html = g.externalize(html, key.static)
+		}
+		return shell{html: html}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if s.legacy {
+		return g.execute(tmpl, data, params)
+	}
+
+	blob := shellData{
+		Background: string(data.BackgroundSrc),
+		Puzzle:     string(data.PuzzleSrc),
+		Y:          data.PuzzleYPos,
+		Render:     string(data.RenderURL),
+		Issued:     json.RawMessage(issuedAtJSON),
+		Expires:    json.RawMessage(expiresAtJSON),
+	}
+	if data.AnswerSealed {
+		blob.AnswerKey = json.RawMessage(answerKeyJSON)
+	}
+	for _, p := range data.Pieces {
+		blob.Pieces = append(blob.Pieces, shellPiece{ID: p.ID, Src: string(p.Src), Y: p.YPos})
+	}
+	// json.Marshal экранирует <, > и &, так что "</script>" в данных невозможен
+	raw, err := json.Marshal(blob)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode challenge data: %w", err)
+	}
+	return strings.Replace(s.html, dataPlaceholder, string(raw), 1), nil
+}
+
+// execute исполняет шаблон и обфусцирует результат
+func (g *Generator) execute(tmpl *template.Template, data ChallengeData, params ObfuscationParams) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	html := buf.String()
+	if g.obfuscate {
+		html = obfuscate(html, params)
+	}
+	return html, nil
+}
+
+// obfuscationFor — параметры обфускации для виджета задания
+func (g *Generator) obfuscationFor(o options) ObfuscationParams {
+	if !g.obfuscate {
+		return ObfuscationParams{}
+	}
+	params := g.Obfuscation()
+	if o.widget.Obfuscation == ObfuscationTouch && params.hiddenControls() {
+		params.Decoy = DecoyDeadCode
+	}
+	return params
+}
+
+// externalize выносит стили и основной скрипт оболочки в файлы Static и
+// оставляет в HTML ссылки на них. JSON-блок данных остается в HTML: скрипт
+// подключается после него и читает его так же, как встроенный.
+func (g *Generator) externalize(html, base string) string {
+	if start, end := strings.Index(html, "<style>"), strings.Index(html, "</style>"); start >= 0 && end > start {
+		name := g.static.Put(".css", []byte(html[start+len("<style>"):end]))
+		html = html[:start] + `<link rel="stylesheet" href="` + base + "/static/" + name + `">` + html[end+len("</style>"):]
+	}
+	if start, end := strings.LastIndex(html, "<script>"), strings.LastIndex(html, "</script>"); start >= 0 && end > start {
+		name := g.static.Put(".js", []byte(html[start+len("<script>"):end]))
+		html = html[:start] + `<script src="` + base + "/static/" + name + `">` + html[end:]
+	}
+	return html
+}
@@ -0,0 +1,207 @@
+package generator
+
+import (
+	"cmp"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+const (
+	// DefaultTemplateKind — шаблоны для типов заданий без собственного виджета
+	DefaultTemplateKind = "default"
+	// DefaultLocale — локаль, на которую откатывается поиск шаблона
+	DefaultLocale = "en"
+)
+
+// TemplateKey идентифицирует шаблон виджета: templates/<kind>/<version>/<locale>.html
+type TemplateKey struct {
+	Kind    string
+	Version string
+	Locale  string
+}
+
+func (k TemplateKey) String() string {
+	return k.Kind + "/" + k.Version + "/" + k.Locale
+}
+
+// TemplateRepository хранит шаблоны виджетов по типу задания, версии и локали.
+// Встроенные шаблоны перекрываются файлами из внешней директории с той же раскладкой;
+// активная версия каждого типа переключается на лету, что позволяет откатить виджет.
+type TemplateRepository struct {
+	mu        sync.RWMutex
+	templates map[TemplateKey]*templateFile
+	// active — выбранная версия по типу; по умолчанию последняя
+	active map[string]string
+}
+
+// templateFile — файл шаблона, который разбирается при первом обращении:
+// шаблоны типов заданий и локалей без трафика не разбираются вовсе
+type templateFile struct {
+	fsys fs.FS
+	path string
+
+	once sync.Once
+	tmpl *template.Template
+	err  error
+}
+
+func (f *templateFile) parse(key TemplateKey) (*template.Template, error) {
+	f.once.Do(func() {
+		if f.tmpl, f.err = template.ParseFS(f.fsys, f.path); f.err != nil {
+			f.err = fmt.Errorf("failed to parse template %s: %w", key, f.err)
+		}
+	})
+	return f.tmpl, f.err
+}
+
+// LoadTemplates находит встроенные шаблоны и, если overrideDir не пуст, шаблоны в нем.
+// Шаблоны разбираются при первом обращении (см. Lookup).
+func LoadTemplates(overrideDir string) (*TemplateRepository, error) {
+	r := &TemplateRepository{
+		templates: make(map[TemplateKey]*templateFile),
+		active:    make(map[string]string),
+	}
+	sub, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(sub); err != nil {
+		return nil, fmt.Errorf("failed to load embedded templates: %w", err)
+	}
+	if overrideDir != "" {
+		if err := r.load(os.DirFS(overrideDir)); err != nil {
+			return nil, fmt.Errorf("failed to load templates from %s: %w", overrideDir, err)
+		}
+	}
+	if _, _, err := r.find(DefaultTemplateKind, "", DefaultLocale); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *TemplateRepository) load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*/*/*.html")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		parts := strings.Split(file, "/")
+		key := TemplateKey{Kind: parts[0], Version: parts[1], Locale: normalizeLocale(strings.TrimSuffix(parts[2], ".html"))}
+		r.templates[key] = &templateFile{fsys: fsys, path: file}
+	}
+	return nil
+}
+
+// Versions возвращает версии шаблонов типа от старой к новой
+func (r *TemplateRepository) Versions(kind string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.versionsLocked(kind)
+}
+
+func (r *TemplateRepository) versionsLocked(kind string) []string {
+	seen := make(map[string]bool)
+	for key := range r.templates {
+		if key.Kind == kind {
+			seen[key.Version] = true
+		}
+	}
+	versions := make([]string, 0, len(seen))
+	for v := range seen {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+	return versions
+}
+
+// ActiveVersion возвращает версию шаблона, которая сейчас выдается для типа
+func (r *TemplateRepository) ActiveVersion(kind string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.activeLocked(kind)
+}
+
+func (r *TemplateRepository) activeLocked(kind string) string {
+	if v, ok := r.active[kind]; ok {
+		return v
+	}
+	versions := r.versionsLocked(kind)
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
+// SetActive закрепляет версию шаблона для типа (например, для отката); пустая версия — последняя
+func (r *TemplateRepository) SetActive(kind, version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if version == "" {
+		delete(r.active, kind)
+		return nil
+	}
+	for key := range r.templates {
+		if key.Kind == kind && key.Version == version {
+			r.active[kind] = version
+			return nil
+		}
+	}
+	return fmt.Errorf("template %s/%s not found", kind, version)
+}
+
+// Lookup находит шаблон версии version (пустая — активная) для типа и локали.
+// Если у типа нет своего виджета, используется DefaultTemplateKind. Локаль ищется
+// точно ("pt-br"), затем по языку ("pt"), затем используется DefaultLocale.
+func (r *TemplateRepository) Lookup(kind, version, locale string) (*template.Template, TemplateKey, error) {
+	f, key, err := r.find(kind, version, locale)
+	if err != nil {
+		return nil, TemplateKey{}, err
+	}
+	tmpl, err := f.parse(key)
+	if err != nil {
+		return nil, TemplateKey{}, err
+	}
+	return tmpl, key, nil
+}
+
+// find — поиск Lookup без разбора шаблона
+func (r *TemplateRepository) find(kind, version, locale string) (*templateFile, TemplateKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range []string{kind, DefaultTemplateKind} {
+		version := cmp.Or(version, r.activeLocked(k))
+		if version == "" {
+			continue
+		}
+		for _, l := range append(localeCandidates(locale), DefaultLocale) {
+			key := TemplateKey{Kind: k, Version: version, Locale: l}
+			if f, ok := r.templates[key]; ok {
+				return f, key, nil
+			}
+		}
+	}
+	if version != "" {
+		return nil, TemplateKey{}, fmt.Errorf("no template %s for challenge type %s and locale %s", version, kind, locale)
+	}
+	return nil, TemplateKey{}, fmt.Errorf("no template for challenge type %s and locale %s", kind, locale)
+}
+
+// versionLess сравнивает версии вида v1, v2, v10 по числу, остальные — как строки
+func versionLess(a, b string) bool {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
@@ -0,0 +1,66 @@
+// Package handoff шифрует состояние незавершенных заданий, которое инстанс
+// передает другому инстансу при остановке: gob-кодирование и AES-256-GCM на
+// ключе, выведенном из общего секрета флота. Балансер, через который идет
+// передача, состояние не читает.
+package handoff
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+// version — формат зашифрованного состояния; меняется вместе с gob-структурами
+const version byte = 1
+
+// ErrInvalid — состояние повреждено, зашифровано другим секретом или в другом формате
+var ErrInvalid = errors.New("invalid handoff state")
+
+// Sealer шифрует и расшифровывает состояние
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// New создает шифратор; пустой секрет означает, что передача выключена (nil)
+func New(secret []byte) *Sealer {
+	if len(secret) == 0 {
+		return nil
+	}
+	key := sha256.Sum256(secret)
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return &Sealer{aead: aead}
+}
+
+// Seal кодирует v и шифрует результат: версия, nonce, шифртекст
+func (s *Sealer) Seal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode handoff state: %w", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	out := append([]byte{version}, nonce...)
+	return s.aead.Seal(out, nonce, buf.Bytes(), []byte{version}), nil
+}
+
+// Open расшифровывает состояние и декодирует его в v
+func (s *Sealer) Open(data []byte, v any) error {
+	n := s.aead.NonceSize()
+	if len(data) < 1+n || data[0] != version {
+		return ErrInvalid
+	}
+	plain, err := s.aead.Open(nil, data[1:1+n], data[1+n:], data[:1])
+	if err != nil {
+		return ErrInvalid
+	}
+	if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package httpcompress сжимает HTTP-ответы (brotli или gzip) по Accept-Encoding клиента.
+// HTML задания содержит base64-картинки и весит мегабайты, поэтому без сжатия
+// он легко упирается в лимиты прокси и медленные соединения.
+package httpcompress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// MinSize — ответы с известной длиной меньше этого размера не сжимаются
+const MinSize = 1024
+
+// Encoding выбирает кодировку по заголовку Accept-Encoding: brotli предпочтительнее gzip.
+// Пустая строка означает, что сжатие не поддерживается клиентом.
+func Encoding(acceptEncoding string) string {
+	var gz bool
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "br":
+			return "br"
+		case "gzip":
+			gz = true
+		}
+	}
+	if gz {
+		return "gzip"
+	}
+	return ""
+}
+
+// Writer возвращает сжимающий writer для кодировки encoding
+func Writer(w io.Writer, encoding string) io.WriteCloser {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "gzip":
+		gz, _ := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		return gz
+	}
+	return nopCloser{w}
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// Handler оборачивает обработчик, сжимая ответы для поддерживающих это клиентов
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := Encoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter откладывает решение о сжатии до первой записи тела:
+// заведомо маленькие и уже сжатые ответы отдаются как есть
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int
+	decided     bool
+	wroteHeader bool
+	zw          io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.decide()
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) decide() {
+	cw.decided = true
+	h := cw.Header()
+	small := false
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < MinSize {
+		small = true
+	}
+	if h.Get("Content-Encoding") == "" && !small {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		cw.zw = Writer(cw.ResponseWriter, cw.encoding)
+	}
+	cw.flushHeader()
+}
+
+func (cw *compressWriter) flushHeader() {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+}
+
+// Close дописывает сжатый поток; для пустого ответа просто отправляет статус
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		cw.decided = true
+		cw.flushHeader()
+	}
+	if cw.zw != nil {
+		return cw.zw.Close()
+	}
+	return nil
+}
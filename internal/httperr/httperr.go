@@ -0,0 +1,114 @@
+// Package httperr — единый формат ошибок REST-ручек: JSON-конверт
+// {"code", "message", "reason", "request_id"} вместо текста http.Error.
+// code дублирует HTTP-статус, reason — машинно-читаемая причина в стиле кодов
+// gRPC, request_id совпадает с заголовком X-Request-Id ответа.
+package httperr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// Header — заголовок с ID запроса: берется из запроса или генерируется
+const Header = "X-Request-Id"
+
+// maxRequestID — длиннее ID из запроса не принимается: он попадает в логи
+const maxRequestID = 128
+
+// Причины ошибок
+const (
+	InvalidArgument  = "INVALID_ARGUMENT"
+	NotFound         = "NOT_FOUND"
+	MethodNotAllowed = "METHOD_NOT_ALLOWED"
+	PermissionDenied = "PERMISSION_DENIED"
+	Unauthenticated  = "UNAUTHENTICATED"
+	Expired          = "EXPIRED"
+	Unavailable      = "UNAVAILABLE"
+	Internal         = "INTERNAL"
+)
+
+// Error — тело ответа с ошибкой
+type Error struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Reason    string `json:"reason"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// reasonFor — причина по умолчанию для HTTP-статуса
+func reasonFor(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return InvalidArgument
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusGone:
+		return Expired
+	case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return Unavailable
+	}
+	return Internal
+}
+
+// Write отвечает ошибкой с причиной по статусу code
+func Write(w http.ResponseWriter, r *http.Request, code int, message string) {
+	WriteReason(w, r, code, reasonFor(code), message)
+}
+
+// WriteReason отвечает ошибкой с явной причиной
+func WriteReason(w http.ResponseWriter, r *http.Request, code int, reason, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(Error{
+		Code:      code,
+		Message:   message,
+		Reason:    reason,
+		RequestID: RequestIDFrom(r.Context()),
+	})
+}
+
+type requestIDKey struct{}
+
+// RequestID присваивает запросу ID: из X-Request-Id прокси или новый, и
+// возвращает его в заголовке ответа
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFrom возвращает ID запроса, присвоенный RequestID
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// valid — ID из запроса непустой, короткий и из печатных ASCII-символов
+func valid(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
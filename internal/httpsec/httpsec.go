@@ -0,0 +1,129 @@
+// Package httpsec добавляет к HTTP-ответам заголовки безопасности и CORS.
+// Виджет и картинки заданий встраиваются на чужие сайты, поэтому разрешенные
+// источники (CORS) и страницы, которым можно встраивать фрейм, задаются явно.
+package httpsec
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy — настройки заголовков безопасности
+type Policy struct {
+	// AllowedOrigins — источники, которым разрешены кросс-доменные запросы:
+	// точные ("https://shop.example"), с маской поддомена ("https://*.example.com")
+	// или "*" для любых. Пустой список — CORS-заголовки не отдаются.
+	AllowedOrigins []string
+	// AllowCredentials разрешает запросы с cookie (нужно для /session);
+	// для "*" не действует: браузер отклоняет такую комбинацию
+	AllowCredentials bool
+	// FrameAncestors — источники, которые могут встраивать ответы во фрейм
+	// (CSP frame-ancestors). Пустой список запрещает встраивание вовсе.
+	FrameAncestors []string
+	// HSTSMaxAge > 0 включает Strict-Transport-Security
+	HSTSMaxAge time.Duration
+}
+
+// corsMaxAge — сколько браузер может кэшировать ответ на preflight
+const corsMaxAge = 10 * time.Minute
+
+// Secure добавляет стандартные заголовки безопасности и политику встраивания во фрейм
+func (p Policy) Secure(next http.Handler) http.Handler {
+	frameAncestors := "'none'"
+	if len(p.FrameAncestors) > 0 {
+		frameAncestors = strings.Join(p.FrameAncestors, " ")
+	}
+	// X-Frame-Options не умеет списки источников: для них остается только CSP
+	frameOptions := ""
+	switch frameAncestors {
+	case "'none'":
+		frameOptions = "DENY"
+	case "'self'":
+		frameOptions = "SAMEORIGIN"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		h.Set("Content-Security-Policy", "frame-ancestors "+frameAncestors)
+		if frameOptions != "" {
+			h.Set("X-Frame-Options", frameOptions)
+		}
+		if p.HSTSMaxAge > 0 {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(p.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORS разрешает кросс-доменные запросы с источников из AllowedOrigins и отвечает
+// на preflight. Запросы без Origin и с неразрешенных источников проходят дальше
+// без CORS-заголовков: браузер сам не отдаст ответ странице.
+func (p Policy) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(p.AllowedOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		allowOrigin, ok := p.allowOrigin(origin)
+		if !ok {
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", allowOrigin)
+		if p.AllowCredentials && allowOrigin != "*" {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowOrigin возвращает значение Access-Control-Allow-Origin для источника
+func (p Policy) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if MatchOrigin(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// MatchOrigin сравнивает источник с шаблоном; "*." в шаблоне соответствует
+// любому непустому поддомену, но не самому домену
+func MatchOrigin(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return strings.EqualFold(pattern, origin)
+	}
+	rest, ok := strings.CutPrefix(strings.ToLower(origin), strings.ToLower(scheme)+"://")
+	if !ok {
+		return false
+	}
+	sub, found := strings.CutSuffix(rest, "."+strings.ToLower(host))
+	return found && sub != ""
+}
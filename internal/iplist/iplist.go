@@ -0,0 +1,170 @@
+// Package iplist хранит списки IP/CIDR: allowlist пропускает клиента без задания,
+// denylist выдает самое сложное задание или блокирует запрос. Записи могут
+// истекать, списки сохраняются в JSON-файл и перечитываются при его изменении,
+// а каждое изменение пишется в журнал аудита.
+package iplist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// List — в каком списке запись
+type List string
+
+const (
+	Allow List = "allow"
+	Deny  List = "deny"
+)
+
+// Action — что делать с клиентом из denylist
+type Action string
+
+const (
+	// Hard — выдать задание максимальной сложности без невидимого режима и аттестации
+	Hard Action = "hard"
+	// Block — отказать в задании
+	Block Action = "block"
+)
+
+var (
+	ErrNotFound = errors.New("ip list entry not found")
+	ErrInvalid  = errors.New("invalid ip list entry")
+)
+
+// Entry — запись списка
+type Entry struct {
+	ID      string       `json:"id"`
+	Prefix  netip.Prefix `json:"cidr"`
+	List    List         `json:"list"`
+	Action  Action       `json:"action,omitempty"`
+	Comment string       `json:"comment,omitempty"`
+	// ExpiresAt — после этого момента запись не действует; нулевое — бессрочно
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Author — администратор, последним изменивший запись
+	Author string `json:"author,omitempty"`
+}
+
+// Expired сообщает, истекла ли запись к моменту now
+func (e Entry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// ParsePrefix принимает CIDR или отдельный адрес
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (e *Entry) validate() error {
+	if !e.Prefix.IsValid() {
+		return fmt.Errorf("%w: cidr is required", ErrInvalid)
+	}
+	switch e.List {
+	case Allow:
+		e.Action = ""
+	case Deny:
+		if e.Action == "" {
+			e.Action = Hard
+		}
+		if e.Action != Hard && e.Action != Block {
+			return fmt.Errorf("%w: deny action must be %q or %q", ErrInvalid, Hard, Block)
+		}
+	default:
+		return fmt.Errorf("%w: list must be %q or %q", ErrInvalid, Allow, Deny)
+	}
+	return nil
+}
+
+// Lists — записи обоих списков
+type Lists struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+func newLists() *Lists {
+	return &Lists{entries: make(map[string]Entry), now: time.Now}
+}
+
+// Match ищет действующую запись для адреса. Побеждает самый узкий префикс,
+// при равной длине denylist важнее allowlist.
+func (l *Lists) Match(addr netip.Addr) (Entry, bool) {
+	if !addr.IsValid() {
+		return Entry{}, false
+	}
+	addr = addr.Unmap()
+	now := l.now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var best Entry
+	found := false
+	for _, e := range l.entries {
+		if e.Expired(now) || !e.Prefix.Contains(addr) {
+			continue
+		}
+		if !found || e.Prefix.Bits() > best.Prefix.Bits() ||
+			(e.Prefix.Bits() == best.Prefix.Bits() && e.List == Deny && best.List == Allow) {
+			best, found = e, true
+		}
+	}
+	return best, found
+}
+
+// Entries — все записи, включая истекшие, по времени создания
+func (l *Lists) Entries() []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	list := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// purge удаляет истекшие записи и возвращает их
+func (l *Lists) purge() []Entry {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var expired []Entry
+	for id, e := range l.entries {
+		if e.Expired(now) {
+			expired = append(expired, e)
+			delete(l.entries, id)
+		}
+	}
+	return expired
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package iplist
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Proxies — доверенные прокси (балансеры, соседние инстансы, фронтовые
+// прокси): только им разрешено передавать адрес и страну клиента в запросе.
+// Остальным вызывающим верить нельзя — любой клиент подставил бы адрес из
+// allowlist и получил бы токен без задания.
+type Proxies []netip.Prefix
+
+// ParseProxies разбирает список CIDR или отдельных адресов
+func ParseProxies(list []string) (Proxies, error) {
+	proxies := make(Proxies, 0, len(list))
+	for _, s := range list {
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, nil
+}
+
+// Trusted сообщает, входит ли адрес peer в доверенные прокси
+func (p Proxies) Trusted(peer string) bool {
+	addr, err := netip.ParseAddr(peer)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForwardedClient — адрес клиента HTTP-запроса от peer с заголовком
+// X-Forwarded-For. Цепочка разбирается справа налево, пока адреса в ней —
+// доверенные прокси; от недоверенного peer заголовок не принимается.
+func (p Proxies) ForwardedClient(peer, forwardedFor string) string {
+	client := peer
+	if !p.Trusted(peer) {
+		return client
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		client = hop
+		if !p.Trusted(hop) {
+			break
+		}
+	}
+	return client
+}
@@ -0,0 +1,252 @@
+package iplist
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxAuditRead — сколько последних записей журнала аудита отдает Audit
+const maxAuditRead = 1000
+
+// AuditRecord — запись журнала аудита
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Author string    `json:"author"`
+	// Op — create, update, delete или expire
+	Op    string `json:"op"`
+	Entry Entry  `json:"entry"`
+}
+
+// Store — списки с сохранением в файл и журналом аудита. Без файла списки
+// живут только в памяти инстанса.
+type Store struct {
+	*Lists
+
+	// mu сериализует изменения и перечитывание файла
+	mu        sync.Mutex
+	path      string
+	auditPath string
+	modTime   time.Time
+	// recent — журнал аудита в памяти, когда списки не сохраняются в файл
+	recent []AuditRecord
+}
+
+// Open загружает списки из path (файла может еще не быть); audit — файл
+// журнала аудита, пустой — рядом со списками с суффиксом .audit.jsonl
+func Open(path, audit string) (*Store, error) {
+	s := &Store{Lists: newLists(), path: path, auditPath: audit}
+	if path == "" {
+		return s, nil
+	}
+	if s.auditPath == "" {
+		s.auditPath = path + ".audit.jsonl"
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload перечитывает файл, если он изменился с прошлой загрузки, и сообщает,
+// были ли применены новые записи
+func (s *Store) Reload() (bool, error) {
+	if s.path == "" {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat ip lists: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return false, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read ip lists: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return false, fmt.Errorf("failed to parse ip lists %s: %w", s.path, err)
+	}
+	loaded := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		if err := e.validate(); err != nil {
+			return false, fmt.Errorf("ip list entry %s: %w", e.ID, err)
+		}
+		if e.ID == "" {
+			e.ID = newID()
+		}
+		loaded[e.ID] = e
+	}
+	s.Lists.mu.Lock()
+	s.entries = loaded
+	s.Lists.mu.Unlock()
+	s.modTime = info.ModTime()
+	return true, nil
+}
+
+// Create добавляет запись
+func (s *Store) Create(e Entry, author string) (Entry, error) {
+	if err := e.validate(); err != nil {
+		return Entry{}, err
+	}
+	now := s.now()
+	e.ID = newID()
+	e.CreatedAt, e.UpdatedAt, e.Author = now, now, author
+	return e, s.change("create", author, func(entries map[string]Entry) (Entry, error) {
+		entries[e.ID] = e
+		return e, nil
+	})
+}
+
+// Update заменяет запись id; время создания сохраняется
+func (s *Store) Update(id string, e Entry, author string) (Entry, error) {
+	if err := e.validate(); err != nil {
+		return Entry{}, err
+	}
+	e.ID = id
+	e.UpdatedAt, e.Author = s.now(), author
+	err := s.change("update", author, func(entries map[string]Entry) (Entry, error) {
+		old, ok := entries[id]
+		if !ok {
+			return Entry{}, ErrNotFound
+		}
+		e.CreatedAt = old.CreatedAt
+		entries[id] = e
+		return e, nil
+	})
+	return e, err
+}
+
+// Delete удаляет запись id
+func (s *Store) Delete(id, author string) error {
+	return s.change("delete", author, func(entries map[string]Entry) (Entry, error) {
+		e, ok := entries[id]
+		if !ok {
+			return Entry{}, ErrNotFound
+		}
+		delete(entries, id)
+		return e, nil
+	})
+}
+
+// PurgeExpired удаляет истекшие записи с отметкой в журнале аудита
+func (s *Store) PurgeExpired() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := s.purge()
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	for _, e := range expired {
+		s.audit("expire", e, "system")
+	}
+	return expired, s.save()
+}
+
+// change применяет изменение к записям, сохраняет файл и пишет в журнал
+// аудита запись, которую вернул apply
+func (s *Store) change(op, author string, apply func(map[string]Entry) (Entry, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Lists.mu.Lock()
+	e, err := apply(s.entries)
+	s.Lists.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.audit(op, e, author)
+	return s.save()
+}
+
+// save атомарно перезаписывает файл списков; вызывается под mu
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save ip lists: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save ip lists: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save ip lists: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save ip lists: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		// Собственная запись не должна вызывать перечитывание
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// audit дописывает запись в журнал; сбой журнала не отменяет изменение.
+// Вызывается под mu.
+func (s *Store) audit(op string, e Entry, author string) {
+	r := AuditRecord{Time: s.now(), Author: author, Op: op, Entry: e}
+	if s.auditPath == "" {
+		s.recent = append(s.recent, r)
+		if len(s.recent) > maxAuditRead {
+			s.recent = s.recent[1:]
+		}
+		return
+	}
+	line, _ := json.Marshal(r)
+	f, err := os.OpenFile(s.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// Audit — последние записи журнала аудита, от старых к новым
+func (s *Store) Audit() ([]AuditRecord, error) {
+	if s.auditPath == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return append([]AuditRecord(nil), s.recent...), nil
+	}
+	f, err := os.Open(s.auditPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		records = append(records, r)
+		if len(records) > maxAuditRead {
+			records = records[1:]
+		}
+	}
+	return records, scanner.Err()
+}
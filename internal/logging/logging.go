@@ -0,0 +1,155 @@
+// Package logging — уровни логирования, переключаемые во время работы: глобально,
+// для отдельного компонента и для отдельного site key. Сообщения пишутся через
+// стандартный log, поэтому формат вывода не меняется.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Level — уровень важности сообщения
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// MarshalText позволяет отдавать уровни в JSON строками
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLevel разбирает имя уровня без учета регистра
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level %q", s)
+}
+
+// Component — подсистема, уровень которой настраивается отдельно
+type Component string
+
+const (
+	Generator    Component = "generator"
+	Balancer     Component = "balancer"
+	Verification Component = "verification"
+)
+
+// Components — все компоненты с отдельной настройкой уровня
+var Components = []Component{Generator, Balancer, Verification}
+
+// State — текущие настройки уровней
+type State struct {
+	Global     Level               `json:"global"`
+	Components map[Component]Level `json:"components"`
+	Sites      map[string]Level    `json:"sites"`
+}
+
+var (
+	mu    sync.RWMutex
+	state = State{Global: Info, Components: map[Component]Level{}, Sites: map[string]Level{}}
+)
+
+// SetGlobal задает уровень по умолчанию
+func SetGlobal(l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	state.Global = l
+}
+
+// SetComponent переопределяет уровень компонента; nil снимает переопределение
+func SetComponent(c Component, l *Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil {
+		delete(state.Components, c)
+		return
+	}
+	state.Components[c] = *l
+}
+
+// SetSite переопределяет уровень для сообщений о site key; nil снимает переопределение.
+// Уровень сайта важнее уровня компонента: так можно включить debug для одной
+// шумной интеграции, не заливая лог остальными.
+func SetSite(siteKey string, l *Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil {
+		delete(state.Sites, siteKey)
+		return
+	}
+	state.Sites[siteKey] = *l
+}
+
+// Snapshot возвращает копию текущих настроек
+func Snapshot() State {
+	mu.RLock()
+	defer mu.RUnlock()
+	s := State{Global: state.Global, Components: make(map[Component]Level, len(state.Components)), Sites: make(map[string]Level, len(state.Sites))}
+	for c, l := range state.Components {
+		s.Components[c] = l
+	}
+	for site, l := range state.Sites {
+		s.Sites[site] = l
+	}
+	return s
+}
+
+// Enabled сообщает, пишется ли сообщение уровня l компонента c о сайте siteKey
+func Enabled(c Component, siteKey string, l Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	if siteKey != "" {
+		if min, ok := state.Sites[siteKey]; ok {
+			return l >= min
+		}
+	}
+	if min, ok := state.Components[c]; ok {
+		return l >= min
+	}
+	return l >= state.Global
+}
+
+func logf(c Component, siteKey string, l Level, format string, args ...any) {
+	if Enabled(c, siteKey, l) {
+		log.Output(3, fmt.Sprintf(format, args...))
+	}
+}
+
+// Debugf пишет отладочное сообщение компонента; siteKey может быть пустым
+func Debugf(c Component, siteKey, format string, args ...any) {
+	logf(c, siteKey, Debug, format, args...)
+}
+
+// Infof пишет информационное сообщение компонента
+func Infof(c Component, siteKey, format string, args ...any) {
+	logf(c, siteKey, Info, format, args...)
+}
+
+// Warnf пишет предупреждение компонента
+func Warnf(c Component, siteKey, format string, args ...any) {
+	logf(c, siteKey, Warn, format, args...)
+}
+
+// Errorf пишет ошибку компонента
+func Errorf(c Component, siteKey, format string, args ...any) {
+	logf(c, siteKey, Error, format, args...)
+}
@@ -0,0 +1,156 @@
+package logging
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// Redaction — как чувствительное поле попадает в лог
+type Redaction int
+
+const (
+	// Mask — поле скрыто; у IP остается сеть (/24 для IPv4, /48 для IPv6)
+	Mask Redaction = iota
+	// Hash — вместо поля его HMAC-SHA256: одинаковые значения видны одинаковыми,
+	// но ответ или IP из лога не восстановить
+	Hash
+	// Plain — поле пишется как есть; только для отладки
+	Plain
+)
+
+var redactionNames = []string{"mask", "hash", "plain"}
+
+func (r Redaction) String() string {
+	if r < Mask || r > Plain {
+		return fmt.Sprintf("redaction(%d)", int(r))
+	}
+	return redactionNames[r]
+}
+
+// MarshalText позволяет отдавать режимы в JSON строками
+func (r Redaction) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ParseRedaction разбирает имя режима без учета регистра
+func ParseRedaction(s string) (Redaction, error) {
+	for i, name := range redactionNames {
+		if strings.EqualFold(s, name) {
+			return Redaction(i), nil
+		}
+	}
+	return Mask, fmt.Errorf("unknown redaction %q (want mask, hash or plain)", s)
+}
+
+// Field — класс чувствительных данных с отдельным режимом
+type Field string
+
+const (
+	// Solutions — правильные ответы и присланные решения
+	Solutions Field = "solutions"
+	// IPs — адреса клиентов и пиров
+	IPs Field = "ips"
+)
+
+// Fields — все классы чувствительных данных
+var Fields = []Field{Solutions, IPs}
+
+var (
+	solutionRedaction atomic.Int32
+	ipRedaction       atomic.Int32
+	// hashKey — ключ HMAC; по умолчанию случайный на процесс, общий ключ
+	// (LOG_HASH_KEY) нужен, чтобы сопоставлять хэши между инстансами
+	hashKey atomic.Pointer[[]byte]
+)
+
+func init() {
+	key := make([]byte, 32)
+	rand.Read(key)
+	hashKey.Store(&key)
+}
+
+func redactionFor(f Field) *atomic.Int32 {
+	if f == IPs {
+		return &ipRedaction
+	}
+	return &solutionRedaction
+}
+
+// SetRedaction задает режим класса данных; по умолчанию все поля маскируются
+func SetRedaction(f Field, r Redaction) {
+	redactionFor(f).Store(int32(r))
+}
+
+// RedactionOf возвращает текущий режим класса данных
+func RedactionOf(f Field) Redaction {
+	return Redaction(redactionFor(f).Load())
+}
+
+// SetHashKey задает ключ HMAC для режима hash; пустой ключ не меняет текущий
+func SetHashKey(key []byte) {
+	if len(key) > 0 {
+		hashKey.Store(&key)
+	}
+}
+
+// redacted откладывает форматирование до вывода: режим берется на момент записи
+type redacted struct {
+	field Field
+	value any
+}
+
+// Solution помечает ответ или решение для лога: Infof(..., "%v", Solution(x))
+func Solution(v any) fmt.Stringer {
+	return redacted{field: Solutions, value: v}
+}
+
+// IP помечает адрес ("192.0.2.1" или "192.0.2.1:443") для лога
+func IP(addr string) fmt.Stringer {
+	return redacted{field: IPs, value: addr}
+}
+
+func (r redacted) String() string {
+	plain := fmt.Sprint(r.value)
+	switch RedactionOf(r.field) {
+	case Plain:
+		return plain
+	case Hash:
+		if plain == "" {
+			return plain
+		}
+		mac := hmac.New(sha256.New, *hashKey.Load())
+		mac.Write([]byte(plain))
+		return "h:" + hex.EncodeToString(mac.Sum(nil)[:6])
+	}
+	if r.field == IPs {
+		return maskIP(plain)
+	}
+	return "[redacted]"
+}
+
+// maskIP оставляет от адреса сеть; порт отбрасывается
+func maskIP(addr string) string {
+	if addr == "" {
+		return addr
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return "[redacted]"
+	}
+	bits := 48
+	if ip.Is4() || ip.Is4In6() {
+		ip, bits = ip.Unmap(), 24
+	}
+	prefix, _ := ip.Prefix(bits)
+	return prefix.String()
+}
@@ -0,0 +1,238 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Desc — описание зарегистрированной метрики
+type Desc struct {
+	Name   string   `json:"name"`
+	Help   string   `json:"help"`
+	Type   string   `json:"type"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Describe — все зарегистрированные метрики, отсортированные по имени
+func Describe() []Desc {
+	registryMu.Lock()
+	list := make([]Desc, 0, len(registry))
+	for _, m := range registry {
+		var d Desc
+		switch m := m.(type) {
+		case *Counter:
+			d = Desc{Name: m.n, Help: m.h, Type: "counter"}
+		case *Gauge:
+			d = Desc{Name: m.n, Help: m.h, Type: "gauge"}
+		case *GaugeFunc:
+			d = Desc{Name: m.n, Help: m.h, Type: "gauge"}
+		case *CounterVec:
+			d = Desc{Name: m.n, Help: m.h, Type: "counter", Labels: m.labels}
+		case *GaugeVec:
+			d = Desc{Name: m.n, Help: m.h, Type: "gauge", Labels: m.labels}
+		case *Histogram:
+			d = Desc{Name: m.n, Help: m.h, Type: "histogram"}
+		default:
+			continue
+		}
+		list = append(list, d)
+	}
+	registryMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// DashboardOptions — параметры дашборда Grafana
+type DashboardOptions struct {
+	Title string
+	UID   string
+	// Datasource — UID источника Prometheus по умолчанию; пусто — выбирается в
+	// переменной дашборда
+	Datasource string
+}
+
+// Размер панели в сетке Grafana (24 колонки): две панели в ряд
+const (
+	dashboardPanelWidth  = 12
+	dashboardPanelHeight = 8
+)
+
+// Dashboard строит JSON-модель дашборда Grafana по текущему набору метрик:
+// по строке на подсистему (второе слово имени: captcha_<подсистема>_...) и по
+// панели на метрику. Счетчики показываются скоростью, gauge — значением по
+// инстансам, гистограммы — квантилями p50/p95/p99.
+func Dashboard(opts DashboardOptions) map[string]any {
+	descs := Describe()
+	ds := map[string]any{"type": "prometheus", "uid": "${datasource}"}
+
+	var panels []map[string]any
+	id, y := 1, 0
+	group := ""
+	half := 0
+	for _, d := range descs {
+		if g := subsystem(d.Name); g != group {
+			if half == 1 {
+				y += dashboardPanelHeight
+			}
+			group, half = g, 0
+			panels = append(panels, map[string]any{
+				"id": id, "type": "row", "title": g, "collapsed": false, "panels": []any{},
+				"gridPos": map[string]int{"h": 1, "w": 24, "x": 0, "y": y},
+			})
+			id++
+			y++
+		}
+		panels = append(panels, map[string]any{
+			"id":          id,
+			"type":        "timeseries",
+			"title":       d.Name,
+			"description": d.Help,
+			"datasource":  ds,
+			"targets":     targets(d),
+			"fieldConfig": map[string]any{"defaults": map[string]any{"unit": unit(d)}, "overrides": []any{}},
+			"gridPos":     map[string]int{"h": dashboardPanelHeight, "w": dashboardPanelWidth, "x": half * dashboardPanelWidth, "y": y},
+		})
+		id++
+		if half = 1 - half; half == 0 {
+			y += dashboardPanelHeight
+		}
+	}
+
+	return map[string]any{
+		"uid":           opts.UID,
+		"title":         opts.Title,
+		"tags":          []string{"captcha"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating":    map[string]any{"list": variables(descs, opts.Datasource)},
+		"panels":        panels,
+	}
+}
+
+// subsystem — второе слово имени метрики
+func subsystem(name string) string {
+	parts := strings.SplitN(name, "_", 3)
+	if len(parts) < 3 {
+		return name
+	}
+	return parts[1]
+}
+
+// selector — фильтр панели по переменным дашборда: service — постоянная
+// метка инстанса (SetConstLabels), instance — цель скрейпа, site — тенант
+func selector(d Desc) string {
+	sel := `service=~"$service", instance=~"$instance"`
+	if slices.Contains(d.Labels, "site") {
+		sel += `, site=~"$site"`
+	}
+	return "{" + sel + "}"
+}
+
+func targets(d Desc) []map[string]any {
+	sel := selector(d)
+	switch d.Type {
+	case "histogram":
+		var list []map[string]any
+		for i, q := range []string{"0.5", "0.95", "0.99"} {
+			list = append(list, map[string]any{
+				"refId":        string(rune('A' + i)),
+				"expr":         fmt.Sprintf("histogram_quantile(%s, sum by (le) (rate(%s_bucket%s[$__rate_interval])))", q, d.Name, sel),
+				"legendFormat": "p" + strings.TrimPrefix(q, "0."),
+			})
+		}
+		return list
+	case "counter":
+		return []map[string]any{{
+			"refId":        "A",
+			"expr":         fmt.Sprintf("sum%s (rate(%s%s[$__rate_interval]))", by(d.Labels), d.Name, sel),
+			"legendFormat": legend(d.Labels, d.Name),
+		}}
+	}
+	labels := append([]string{"instance"}, d.Labels...)
+	return []map[string]any{{
+		"refId":        "A",
+		"expr":         fmt.Sprintf("sum%s (%s%s)", by(labels), d.Name, sel),
+		"legendFormat": legend(labels, d.Name),
+	}}
+}
+
+func by(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return " by (" + strings.Join(labels, ", ") + ")"
+}
+
+func legend(labels []string, name string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = "{{" + l + "}}"
+	}
+	return strings.Join(parts, " ")
+}
+
+func unit(d Desc) string {
+	switch {
+	case strings.HasSuffix(d.Name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(d.Name, "_bytes"):
+		return "bytes"
+	case d.Type == "counter":
+		return "ops"
+	}
+	return "short"
+}
+
+// variables — переменные дашборда: источник данных, service, instance и site
+func variables(descs []Desc, datasource string) []map[string]any {
+	ds := map[string]any{"type": "datasource", "name": "datasource", "label": "Data source", "query": "prometheus"}
+	if datasource != "" {
+		ds["current"] = map[string]string{"value": datasource}
+	}
+	list := []map[string]any{ds}
+
+	// Значения меток берутся из метрики, которая есть у каждого инстанса
+	// независимо от трафика: из метрики без собственных меток
+	var always, sited string
+	for _, d := range descs {
+		if always == "" && len(d.Labels) == 0 && d.Type != "histogram" {
+			always = d.Name
+		}
+		if sited == "" && slices.Contains(d.Labels, "site") {
+			sited = d.Name
+		}
+	}
+	query := func(name, label, metric, filter string) map[string]any {
+		q := fmt.Sprintf("label_values(%s%s, %s)", metric, filter, label)
+		return map[string]any{
+			"type":       "query",
+			"name":       name,
+			"label":      name,
+			"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"definition": q,
+			"query":      map[string]string{"query": q, "refId": "PrometheusVariableQueryEditor-VariableQuery"},
+			"refresh":    2,
+			"includeAll": true,
+			"multi":      true,
+			"allValue":   ".*",
+			"current":    map[string]any{"text": "All", "value": "$__all"},
+			"sort":       1,
+		}
+	}
+	if always != "" {
+		list = append(list,
+			query("service", "service", always, ""),
+			query("instance", "instance", always, `{service=~"$service"}`))
+	}
+	if sited != "" {
+		list = append(list, query("site", "site", sited, `{service=~"$service"}`))
+	}
+	return list
+}
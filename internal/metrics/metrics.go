@@ -0,0 +1,395 @@
+// Package metrics — минимальный реестр метрик без внешних зависимостей.
+// Метрики отдаются в текстовом формате Prometheus через Handler
+// или отправляются в StatsD/DogStatsD через StatsD.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metric — любая метрика, умеющая записать себя в формате экспозиции
+type metric interface {
+	name() string
+	write(w io.Writer)
+	// collect передает текущие значения метрики для push-экспорта
+	collect(emit func(sample))
+}
+
+// sample — одно значение метрики с метками
+type sample struct {
+	name    string
+	counter bool // счетчик (экспортируется приростом) или gauge
+	labels  []string
+	values  []string
+	value   float64
+}
+
+// snapshot собирает значения всех метрик
+func snapshot() []sample {
+	registryMu.Lock()
+	list := make([]metric, 0, len(registry))
+	for _, m := range registry {
+		list = append(list, m)
+	}
+	registryMu.Unlock()
+
+	var samples []sample
+	for _, m := range list {
+		m.collect(func(s sample) { samples = append(samples, s) })
+	}
+	return samples
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]metric{}
+
+	// constNames и constValues — постоянные метки всех метрик (SetConstLabels)
+	constNames, constValues []string
+)
+
+// SetConstLabels задает метки, которые получает каждая метрика: в экспозиции
+// Prometheus и в тегах DogStatsD. Вызывается при старте, до первой отдачи метрик.
+func SetConstLabels(labels map[string]string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	constNames, constValues = nil, nil
+	for _, name := range sortedKeys(labels) {
+		constNames = append(constNames, name)
+		constValues = append(constValues, labels[name])
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[m.name()]; dup {
+		panic("metrics: duplicate metric " + m.name())
+	}
+	registry[m.name()] = m
+}
+
+// WriteAll пишет все зарегистрированные метрики, отсортированные по имени
+func WriteAll(w io.Writer) {
+	registryMu.Lock()
+	list := make([]metric, 0, len(registry))
+	for _, m := range registry {
+		list = append(list, m)
+	}
+	registryMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].name() < list[j].name() })
+	for _, m := range list {
+		m.write(w)
+	}
+}
+
+// Handler отдает метрики для скрейпа Prometheus
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteAll(w)
+	})
+}
+
+// Counter — монотонно растущий счетчик
+type Counter struct {
+	n, h string
+	v    atomic.Int64
+}
+
+// NewCounter создает и регистрирует счетчик
+func NewCounter(name, help string) *Counter {
+	c := &Counter{n: name, h: help}
+	register(c)
+	return c
+}
+
+func (c *Counter) Inc()         { c.v.Add(1) }
+func (c *Counter) Add(n int64)  { c.v.Add(n) }
+func (c *Counter) Value() int64 { return c.v.Load() }
+func (c *Counter) name() string { return c.n }
+func (c *Counter) collect(emit func(sample)) {
+	emit(sample{name: c.n, counter: true, value: float64(c.v.Load())})
+}
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.n, c.h, "counter")
+	fmt.Fprintf(w, "%s%s %d\n", c.n, formatLabels(nil, nil), c.v.Load())
+}
+
+// Gauge — значение, которое может как расти, так и уменьшаться
+type Gauge struct {
+	n, h string
+	v    atomic.Int64
+}
+
+// NewGauge создает и регистрирует gauge
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{n: name, h: help}
+	register(g)
+	return g
+}
+
+func (g *Gauge) Set(v int64)  { g.v.Store(v) }
+func (g *Gauge) Add(n int64)  { g.v.Add(n) }
+func (g *Gauge) Inc()         { g.v.Add(1) }
+func (g *Gauge) Dec()         { g.v.Add(-1) }
+func (g *Gauge) Value() int64 { return g.v.Load() }
+func (g *Gauge) name() string { return g.n }
+func (g *Gauge) collect(emit func(sample)) {
+	emit(sample{name: g.n, value: float64(g.v.Load())})
+}
+func (g *Gauge) write(w io.Writer) {
+	writeHeader(w, g.n, g.h, "gauge")
+	fmt.Fprintf(w, "%s%s %d\n", g.n, formatLabels(nil, nil), g.v.Load())
+}
+
+// GaugeFunc — gauge, значение которого вычисляется при каждом чтении
+type GaugeFunc struct {
+	n, h string
+	fn   func() int64
+}
+
+// NewGaugeFunc создает и регистрирует gauge, читающий значение из fn
+func NewGaugeFunc(name, help string, fn func() int64) *GaugeFunc {
+	g := &GaugeFunc{n: name, h: help, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.n }
+func (g *GaugeFunc) collect(emit func(sample)) {
+	emit(sample{name: g.n, value: float64(g.fn())})
+}
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.n, g.h, "gauge")
+	fmt.Fprintf(w, "%s%s %d\n", g.n, formatLabels(nil, nil), g.fn())
+}
+
+// CounterVec — набор счетчиков с метками
+type CounterVec struct {
+	n, h   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*atomic.Int64
+}
+
+// NewCounterVec создает и регистрирует счетчик с указанными именами меток
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{n: name, h: help, labels: labels, values: map[string]*atomic.Int64{}}
+	register(v)
+	return v
+}
+
+// Add увеличивает счетчик для набора значений меток (в порядке объявления)
+func (v *CounterVec) Add(n int64, labelValues ...string) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.n, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	c, ok := v.values[key]
+	if !ok {
+		c = new(atomic.Int64)
+		v.values[key] = c
+	}
+	v.mu.Unlock()
+	c.Add(n)
+}
+
+func (v *CounterVec) Inc(labelValues ...string) { v.Add(1, labelValues...) }
+
+// Value возвращает текущее значение для набора меток
+func (v *CounterVec) Value(labelValues ...string) int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.values[strings.Join(labelValues, "\xff")]; ok {
+		return c.Load()
+	}
+	return 0
+}
+
+func (v *CounterVec) name() string { return v.n }
+func (v *CounterVec) collect(emit func(sample)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for k, c := range v.values {
+		emit(sample{name: v.n, counter: true, labels: v.labels, values: strings.Split(k, "\xff"), value: float64(c.Load())})
+	}
+}
+func (v *CounterVec) write(w io.Writer) {
+	writeHeader(w, v.n, v.h, "counter")
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %d\n", v.n, formatLabels(v.labels, strings.Split(k, "\xff")), v.values[k].Load())
+	}
+	v.mu.Unlock()
+}
+
+// GaugeVec — набор gauge с метками
+type GaugeVec struct {
+	n, h   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+// NewGaugeVec создает и регистрирует gauge с указанными именами меток
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{n: name, h: help, labels: labels, values: map[string]int64{}}
+	register(v)
+	return v
+}
+
+// Set задает значение для набора значений меток (в порядке объявления)
+func (v *GaugeVec) Set(n int64, labelValues ...string) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.n, len(v.labels), len(labelValues)))
+	}
+	v.mu.Lock()
+	v.values[strings.Join(labelValues, "\xff")] = n
+	v.mu.Unlock()
+}
+
+// Value возвращает текущее значение для набора меток
+func (v *GaugeVec) Value(labelValues ...string) int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[strings.Join(labelValues, "\xff")]
+}
+
+func (v *GaugeVec) name() string { return v.n }
+func (v *GaugeVec) collect(emit func(sample)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for k, n := range v.values {
+		emit(sample{name: v.n, labels: v.labels, values: strings.Split(k, "\xff"), value: float64(n)})
+	}
+}
+func (v *GaugeVec) write(w io.Writer) {
+	writeHeader(w, v.n, v.h, "gauge")
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %d\n", v.n, formatLabels(v.labels, strings.Split(k, "\xff")), v.values[k])
+	}
+	v.mu.Unlock()
+}
+
+// Histogram — распределение значений по фиксированным корзинам
+type Histogram struct {
+	n, h    string
+	bounds  []float64
+	mu      sync.Mutex
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram создает и регистрирует гистограмму; bounds должны идти по возрастанию
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	h := &Histogram{n: name, h: help, bounds: bounds, buckets: make([]uint64, len(bounds))}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.bounds {
+		if v <= b {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) name() string { return h.n }
+
+// collect отдает только _count и _sum: корзины в StatsD не переносятся
+func (h *Histogram) collect(emit func(sample)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	emit(sample{name: h.n + "_count", counter: true, value: float64(h.count)})
+	emit(sample{name: h.n + "_sum", counter: true, value: h.sum})
+}
+func (h *Histogram) write(w io.Writer) {
+	writeHeader(w, h.n, h.h, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	le := []string{"le"}
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.n, formatLabels(le, []string{formatFloat(b)}), h.buckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", h.n, formatLabels(le, []string{"+Inf"}), h.count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", h.n, formatLabels(nil, nil), formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", h.n, formatLabels(nil, nil), h.count)
+}
+
+// ExponentialBuckets возвращает count границ, начиная со start и умножая на factor
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// formatLabels — метки сэмпла вместе с постоянными; без меток — пустая строка
+func formatLabels(names, values []string) string {
+	if len(constNames)+len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	write := func(names, values []string) {
+		for i, n := range names {
+			if b.Len() > 1 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=%q", n, values[i])
+		}
+	}
+	write(constNames, constValues)
+	write(names, values)
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы push-экспорта
+const (
+	// FlavorStatsD — классический StatsD без тегов: метки дописываются к имени
+	FlavorStatsD = "statsd"
+	// FlavorDogStatsD — DogStatsD (Datadog agent): метки уходят тегами
+	FlavorDogStatsD = "dogstatsd"
+)
+
+// maxDatagram — предел UDP-пакета, при котором он не фрагментируется в типичной сети
+const maxDatagram = 1432
+
+// StatsDConfig — настройки push-экспорта в агент StatsD/DogStatsD
+type StatsDConfig struct {
+	// Addr — адрес агента (host:port, UDP)
+	Addr string
+	// Flavor — FlavorStatsD или FlavorDogStatsD
+	Flavor string
+	// Prefix добавляется к имени каждой метрики
+	Prefix string
+	// Interval — период отправки
+	Interval time.Duration
+	// TagMap переименовывает метки и постоянные теги, например site -> tenant
+	TagMap map[string]string
+	// Tags — постоянные теги каждой метрики сверх меток SetConstLabels
+	Tags map[string]string
+}
+
+// StatsD периодически отправляет все зарегистрированные метрики в агент.
+// Счетчики уходят приростом с прошлой отправки (|c), gauge — значением (|g).
+type StatsD struct {
+	cfg  StatsDConfig
+	conn net.Conn
+	// last — значения счетчиков на момент прошлой отправки
+	last map[string]float64
+}
+
+// NewStatsD создает экспортер; соединение UDP не требует, чтобы агент уже слушал
+func NewStatsD(cfg StatsDConfig) (*StatsD, error) {
+	if cfg.Flavor != FlavorStatsD && cfg.Flavor != FlavorDogStatsD {
+		return nil, fmt.Errorf("unknown statsd flavor %q", cfg.Flavor)
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("statsd interval must be positive, got %s", cfg.Interval)
+	}
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial statsd agent %s: %w", cfg.Addr, err)
+	}
+	return &StatsD{cfg: cfg, conn: conn, last: map[string]float64{}}, nil
+}
+
+// Run отправляет метрики каждые Interval до отмены ctx; перед выходом делает последнюю отправку
+func (s *StatsD) Run(ctx context.Context) {
+	defer s.conn.Close()
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Flush()
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// Flush отправляет текущие значения; ошибки агента только логируются —
+// метрики не должны влиять на работу сервиса
+func (s *StatsD) Flush() {
+	samples := snapshot()
+	lines := make([]string, 0, len(samples))
+	for _, smp := range samples {
+		name, tags := s.nameAndTags(smp)
+		value, typ := smp.value, "g"
+		if smp.counter {
+			key := name + "|" + tags
+			value -= s.last[key]
+			s.last[key] = smp.value
+			if value == 0 {
+				continue
+			}
+			typ = "c"
+		}
+		line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + typ
+		if tags != "" {
+			line += "|#" + tags
+		}
+		lines = append(lines, line)
+	}
+
+	var packet strings.Builder
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxDatagram {
+			s.send(packet.String())
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		s.send(packet.String())
+	}
+}
+
+func (s *StatsD) send(packet string) {
+	if _, err := s.conn.Write([]byte(packet)); err != nil {
+		log.Printf("Failed to push metrics to statsd agent %s: %v", s.cfg.Addr, err)
+	}
+}
+
+// nameAndTags строит имя метрики и теги. В DogStatsD метки становятся тегами
+// (с переименованием по TagMap), в StatsD — сегментами имени после точки
+func (s *StatsD) nameAndTags(smp sample) (string, string) {
+	name := s.cfg.Prefix + smp.name
+	if s.cfg.Flavor == FlavorStatsD {
+		for _, v := range smp.values {
+			name += "." + strings.ReplaceAll(sanitize(v), ".", "_")
+		}
+		return name, ""
+	}
+
+	tags := make([]string, 0, len(smp.labels)+len(s.cfg.Tags))
+	for i, l := range smp.labels {
+		tags = append(tags, s.tag(l, smp.values[i]))
+	}
+	for k, v := range s.cfg.Tags {
+		tags = append(tags, s.tag(k, v))
+	}
+	for i, k := range constNames {
+		tags = append(tags, s.tag(k, constValues[i]))
+	}
+	sort.Strings(tags)
+	return name, strings.Join(tags, ",")
+}
+
+func (s *StatsD) tag(key, value string) string {
+	if mapped, ok := s.cfg.TagMap[key]; ok {
+		key = mapped
+	}
+	return key + ":" + sanitize(value)
+}
+
+// sanitize убирает символы, которые ломают строку протокола StatsD
+func sanitize(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, v)
+}
@@ -0,0 +1,136 @@
+// Package middleware проверяет сессионные cookie, которые инстанс капчи выставляет
+// после успешного решения. Серверные приложения оборачивают им обработчики, чтобы
+// пропускать серию запросов по одному решению без вызова Assess на каждый.
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultCookieName — имя сессионной cookie по умолчанию
+const DefaultCookieName = "captcha_session"
+
+var (
+	ErrNoSession      = errors.New("captcha session cookie is missing")
+	ErrInvalidSession = errors.New("captcha session cookie is malformed or has a bad signature")
+	ErrExpired        = errors.New("captcha session has expired")
+)
+
+// Claims — содержимое сессионной cookie
+type Claims struct {
+	ChallengeID string `json:"cid"`
+	SiteKey     string `json:"site"`
+	Action      string `json:"act"`
+	Confidence  int32  `json:"conf"`
+	// ExpiresAt — unix-время, после которого сессия недействительна
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Sign кодирует claims и подписывает их HMAC-SHA256: "<payload>.<signature>" в base64url
+func Sign(secret []byte, c Claims) string {
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(secret, encoded))
+}
+
+// Verify проверяет подпись и срок действия значения cookie
+func Verify(secret []byte, value string, now time.Time) (Claims, error) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return Claims{}, ErrInvalidSession
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, encoded)) {
+		return Claims{}, ErrInvalidSession
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalidSession
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Claims{}, ErrInvalidSession
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+func mac(secret []byte, data string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Options настраивает RequireSession
+type Options struct {
+	// CookieName — имя cookie; пусто — DefaultCookieName
+	CookieName string
+	// SiteKey и Action, если заданы, должны совпадать с claims сессии
+	SiteKey string
+	Action  string
+	// ClockSkew — допуск к сроку сессии на расхождение часов с инстансом капчи,
+	// выставившим cookie
+	ClockSkew time.Duration
+	// OnReject вызывается вместо next для запросов без валидной сессии;
+	// по умолчанию отвечает 403
+	OnReject func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Check проверяет значение cookie как Verify и сайт и действие сессии, если
+// они заданы в opts
+func (opts Options) Check(secret []byte, value string, now time.Time) (Claims, error) {
+	c, err := Verify(secret, value, now.Add(-opts.ClockSkew))
+	if err == nil && opts.SiteKey != "" && c.SiteKey != opts.SiteKey {
+		err = ErrInvalidSession
+	}
+	if err == nil && opts.Action != "" && c.Action != opts.Action {
+		err = ErrInvalidSession
+	}
+	return c, err
+}
+
+type claimsKey struct{}
+
+// RequireSession пропускает к next только запросы с валидной сессионной cookie;
+// claims доступны обработчику через ClaimsFromContext
+func RequireSession(secret []byte, opts Options, next http.Handler) http.Handler {
+	name := opts.CookieName
+	if name == "" {
+		name = DefaultCookieName
+	}
+	reject := opts.OnReject
+	if reject == nil {
+		reject = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			reject(w, r, ErrNoSession)
+			return
+		}
+		c, err := opts.Check(secret, cookie.Value, time.Now())
+		if err != nil {
+			reject(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, c)))
+	})
+}
+
+// ClaimsFromContext возвращает claims сессии, проверенной RequireSession
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
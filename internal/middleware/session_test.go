@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("session-secret")
+
+func testClaims(now time.Time) Claims {
+	return Claims{ChallengeID: "challenge-1", SiteKey: "site-1", Action: "login", Confidence: 90, ExpiresAt: now.Add(time.Minute).Unix()}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	value := Sign(testSecret, testClaims(now))
+	payload, sig, _ := strings.Cut(value, ".")
+	forged := Sign(testSecret, Claims{SiteKey: "site-1", Confidence: 100, ExpiresAt: now.Add(time.Hour).Unix()})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	for _, tc := range []struct {
+		name   string
+		secret []byte
+		value  string
+		now    time.Time
+		want   error
+	}{
+		{"valid", testSecret, value, now, nil},
+		{"tampered signature", testSecret, payload + "." + strings.Repeat("A", len(sig)), now, ErrInvalidSession},
+		{"swapped payload", testSecret, forgedPayload + "." + sig, now, ErrInvalidSession},
+		{"expired", testSecret, value, now.Add(time.Minute), ErrExpired},
+		{"truncated", testSecret, value[:len(value)-3], now, ErrInvalidSession},
+		{"no signature", testSecret, payload, now, ErrInvalidSession},
+		{"garbled", testSecret, "%%%.***", now, ErrInvalidSession},
+		{"empty", testSecret, "", now, ErrInvalidSession},
+		{"not json", testSecret, signRaw(testSecret, base64.RawURLEncoding.EncodeToString([]byte("not json"))), now, ErrInvalidSession},
+		{"rotated secret", []byte("new-secret"), value, now, ErrInvalidSession},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Verify(tc.secret, tc.value, tc.now)
+			if !errors.Is(err, tc.want) || (tc.want == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			if err == nil && c != testClaims(now) {
+				t.Fatalf("claims = %+v", c)
+			}
+		})
+	}
+}
+
+// signRaw подписывает произвольный payload, как Sign
+func signRaw(secret []byte, encoded string) string {
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(secret, encoded))
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	value := Sign(testSecret, testClaims(now))
+	for _, tc := range []struct {
+		name string
+		opts Options
+		now  time.Time
+		want error
+	}{
+		{"any site", Options{}, now, nil},
+		{"same site and action", Options{SiteKey: "site-1", Action: "login"}, now, nil},
+		{"other site", Options{SiteKey: "site-2"}, now, ErrInvalidSession},
+		{"other action", Options{Action: "signup"}, now, ErrInvalidSession},
+		{"expired within skew", Options{ClockSkew: 10 * time.Second}, now.Add(time.Minute + 5*time.Second), nil},
+		{"expired beyond skew", Options{ClockSkew: 10 * time.Second}, now.Add(time.Minute + 10*time.Second), ErrExpired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.opts.Check(testSecret, value, tc.now); !errors.Is(err, tc.want) || (tc.want == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	handler := RequireSession(testSecret, Options{SiteKey: "site-1"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, ok := ClaimsFromContext(r.Context()); !ok || c.ChallengeID != "challenge-1" {
+			t.Errorf("claims in context = %+v, %v", c, ok)
+		}
+	}))
+	for _, tc := range []struct {
+		name   string
+		cookie string
+		want   int
+	}{
+		{"valid", Sign(testSecret, testClaims(time.Now())), http.StatusOK},
+		{"missing", "", http.StatusForbidden},
+		{"expired", Sign(testSecret, testClaims(time.Now().Add(-time.Hour))), http.StatusForbidden},
+		{"rotated secret", Sign([]byte("old-secret"), testClaims(time.Now())), http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: tc.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d", w.Code, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package notify отправляет исходящие уведомления (вебхуки, письма об
+// исчерпанной квоте, доставка одноразовых кодов) через подключаемых
+// провайдеров. Встроены SMTP, HTTP (JSON-вебхук) и Slack; другие реализации
+// подключаются через Provider. Провайдеры и события, на которые они
+// подписаны, задаются для каждого тенанта отдельно.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// События, о которых сервис уведомляет тенантов
+const (
+	// EventQuotaExceeded — месячная квота тенанта исчерпана
+	EventQuotaExceeded = "quota_exceeded"
+	// EventOTP — одноразовый код для доставки пользователю
+	EventOTP = "otp"
+)
+
+// AnySite — ключ правил, которые действуют для всех тенантов
+const AnySite = "*"
+
+// Notification — одно уведомление
+type Notification struct {
+	Event   string `json:"event"`
+	SiteKey string `json:"site_key"`
+	// Subject — краткое описание (тема письма), Text — текст уведомления
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	// To — адресат для событий конкретному пользователю (OTP); пусто —
+	// адресаты из настроек провайдера
+	To     string            `json:"to,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Time   time.Time         `json:"time"`
+}
+
+// Provider доставляет уведомления одним каналом
+type Provider interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// ProviderFunc позволяет использовать функцию как Provider
+type ProviderFunc func(ctx context.Context, n Notification) error
+
+func (f ProviderFunc) Send(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// Route — провайдер и события, на которые он подписан (пусто — все события)
+type Route struct {
+	Name     string
+	Provider Provider
+	Events   []string
+}
+
+func (r Route) wants(event string) bool {
+	return len(r.Events) == 0 || slices.Contains(r.Events, event)
+}
+
+// Router выбирает провайдеров уведомления по тенанту и событию
+type Router struct {
+	routes map[string][]Route
+}
+
+// NewRouter создает маршрутизатор с провайдерами тенантов; маршруты под
+// ключом AnySite получают уведомления всех тенантов
+func NewRouter(routes map[string][]Route) *Router {
+	return &Router{routes: routes}
+}
+
+// Routes — провайдеры, которые получат уведомление n
+func (r *Router) Routes(n Notification) []Route {
+	var list []Route
+	for _, key := range []string{n.SiteKey, AnySite} {
+		for _, route := range r.routes[key] {
+			if route.wants(n.Event) {
+				list = append(list, route)
+			}
+		}
+		if n.SiteKey == AnySite {
+			break
+		}
+	}
+	return list
+}
+
+// Send отправляет уведомление всем подходящим провайдерам; ошибки провайдеров
+// объединяются, и сбой одного не мешает остальным
+func (r *Router) Send(ctx context.Context, n Notification) error {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	var errs []error
+	for _, route := range r.Routes(n) {
+		if err := route.Provider.Send(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", route.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ProviderConfig — провайдер в файле настроек
+type ProviderConfig struct {
+	// Type — smtp, http или slack
+	Type   string   `json:"type"`
+	Events []string `json:"events,omitempty"`
+	// URL — адрес вебхука (http, slack)
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Addr, From, To, Username — настройки SMTP. Пароль не хранится в файле:
+	// PasswordEnv — имя переменной окружения с ним.
+	Addr        string   `json:"addr,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+}
+
+// LoadFile читает провайдеров тенантов из JSON вида
+// {"site-key": [{"type": "slack", "url": "https://hooks.slack.com/...", "events": ["quota_exceeded"]}],
+// "*": [{"type": "smtp", "addr": "smtp.example.com:587", "from": "...", "to": ["ops@example.com"]}]}
+func LoadFile(path string, timeout time.Duration) (*Router, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifications file: %w", err)
+	}
+	var file map[string][]ProviderConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse notifications file %s: %w", path, err)
+	}
+	routes := make(map[string][]Route, len(file))
+	for siteKey, configs := range file {
+		for i, c := range configs {
+			p, err := c.provider(timeout)
+			if err != nil {
+				return nil, fmt.Errorf("notifications for %q, provider %d: %w", siteKey, i, err)
+			}
+			routes[siteKey] = append(routes[siteKey], Route{Name: c.Type, Provider: p, Events: c.Events})
+		}
+	}
+	return NewRouter(routes), nil
+}
+
+func (c ProviderConfig) provider(timeout time.Duration) (Provider, error) {
+	switch c.Type {
+	case "smtp":
+		if c.Addr == "" || c.From == "" {
+			return nil, errors.New("smtp provider needs addr and from")
+		}
+		return &SMTP{Addr: c.Addr, From: c.From, To: c.To, Username: c.Username, Password: os.Getenv(c.PasswordEnv)}, nil
+	case "http":
+		if c.URL == "" {
+			return nil, errors.New("http provider needs url")
+		}
+		return NewHTTP(c.URL, c.Headers, timeout), nil
+	case "slack":
+		if c.URL == "" {
+			return nil, errors.New("slack provider needs url")
+		}
+		return NewSlack(c.URL, timeout), nil
+	}
+	return nil, fmt.Errorf("unknown provider type %q", c.Type)
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTTP — JSON-вебхук: POST с Notification в теле
+type HTTP struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// NewHTTP создает вебхук с таймаутом одного запроса timeout
+func NewHTTP(url string, headers map[string]string, timeout time.Duration) *HTTP {
+	return &HTTP{URL: url, Headers: headers, Client: &http.Client{Timeout: timeout}}
+}
+
+func (h *HTTP) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, h.Client, h.URL, h.Headers, n)
+}
+
+// Slack — входящий вебхук Slack: текст уведомления и поля одним сообщением
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewSlack создает вебхук Slack с таймаутом одного запроса timeout
+func NewSlack(url string, timeout time.Duration) *Slack {
+	return &Slack{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+func (s *Slack) Send(ctx context.Context, n Notification) error {
+	if n.Event == EventOTP {
+		// Код пользователю в канал команды не отправляется
+		return nil
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "*%s*\n%s", n.Subject, n.Text)
+	for _, name := range sortedKeys(n.Fields) {
+		fmt.Fprintf(&text, "\n• %s: `%s`", name, n.Fields[name])
+	}
+	return postJSON(ctx, s.Client, s.URL, nil, map[string]string{"text": text.String()})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTP отправляет уведомление письмом. Адресаты — To из настроек, для
+// уведомлений пользователю (OTP) — Notification.To. С Username используется
+// PLAIN-аутентификация: net/smtp разрешает ее только поверх TLS или на localhost.
+type SMTP struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (s *SMTP) Send(ctx context.Context, n Notification) error {
+	to := s.To
+	if n.To != "" {
+		to = []string{n.To}
+	}
+	if len(to) == 0 {
+		return errors.New("smtp provider has no recipients")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	// net/smtp не принимает контекст: отправка идет в фоне, а ожидание
+	// ограничено ctx
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(s.Addr, auth, s.From, to, s.message(n, to)) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *SMTP) message(n Notification, to []string) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", headerValue(strings.Join(to, ", ")))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(n.Subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(n.Text)
+	msg.WriteString("\r\n")
+	for _, name := range sortedKeys(n.Fields) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", name, n.Fields[name])
+	}
+	return msg.Bytes()
+}
+
+// headerValue убирает переводы строк, которыми можно дописать заголовки письма
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
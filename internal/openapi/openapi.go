@@ -0,0 +1,161 @@
+// Package openapi собирает документ OpenAPI 3 по REST-ручкам при их
+// регистрации: ручка описывается там же, где попадает в mux, поэтому
+// /openapi.json не расходится с тем, что сервер на самом деле обслуживает.
+// Ответы с ошибкой у всех ручек — конверт internal/httperr.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Op — описание одной операции ручки
+type Op struct {
+	// Method — HTTP-метод; у шаблонов "POST /path" берется из шаблона
+	Method  string
+	Summary string
+	// Query и Form — параметры строки запроса и поля формы (все строковые)
+	Query []string
+	Form  []string
+	// JSONBody — тело запроса — JSON-объект
+	JSONBody bool
+	// ContentType ответа; пусто — application/json
+	ContentType string
+}
+
+// Document — документ OpenAPI; безопасен для Add во время обслуживания
+type Document struct {
+	mu    sync.RWMutex
+	title string
+	// paths — путь -> метод в нижнем регистре -> операция
+	paths map[string]map[string]operation
+}
+
+type operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Parameters  []parameter         `json:"parameters,omitempty"`
+	RequestBody *body               `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   map[string]any `json:"schema"`
+}
+
+type body struct {
+	Content map[string]media `json:"content"`
+}
+
+type response struct {
+	Description string           `json:"description"`
+	Content     map[string]media `json:"content,omitempty"`
+}
+
+type media struct {
+	Schema map[string]any `json:"schema"`
+}
+
+// New создает пустой документ сервиса title
+func New(title string) *Document {
+	return &Document{title: title, paths: make(map[string]map[string]operation)}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Add описывает ручку шаблона mux (pattern как в http.ServeMux.Handle)
+func (d *Document) Add(pattern string, ops ...Op) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.paths[path] == nil {
+		d.paths[path] = make(map[string]operation)
+	}
+	for _, op := range ops {
+		m := op.Method
+		if m == "" {
+			m = method
+		}
+		if m == "" {
+			m = http.MethodGet
+		}
+		d.paths[path][strings.ToLower(m)] = describe(path, op)
+	}
+}
+
+func describe(path string, op Op) operation {
+	o := operation{Summary: op.Summary, Responses: map[string]response{
+		"default": {Description: "Error", Content: map[string]media{
+			"application/json": {Schema: map[string]any{"$ref": "#/components/schemas/Error"}},
+		}},
+	}}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		o.Parameters = append(o.Parameters, parameter{Name: m[1], In: "path", Required: true, Schema: stringSchema()})
+	}
+	for _, name := range op.Query {
+		o.Parameters = append(o.Parameters, parameter{Name: name, In: "query", Schema: stringSchema()})
+	}
+	switch {
+	case len(op.Form) > 0:
+		props := make(map[string]any, len(op.Form))
+		for _, name := range op.Form {
+			props[name] = stringSchema()
+		}
+		o.RequestBody = &body{Content: map[string]media{
+			"application/x-www-form-urlencoded": {Schema: map[string]any{"type": "object", "properties": props}},
+		}}
+	case op.JSONBody:
+		o.RequestBody = &body{Content: map[string]media{
+			"application/json": {Schema: map[string]any{"type": "object"}},
+		}}
+	}
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	o.Responses["200"] = response{Description: "OK", Content: map[string]media{contentType: {Schema: map[string]any{}}}}
+	return o
+}
+
+func stringSchema() map[string]any {
+	return map[string]any{"type": "string"}
+}
+
+// errorSchema — схема httperr.Error
+var errorSchema = map[string]any{
+	"type":     "object",
+	"required": []string{"code", "message", "reason"},
+	"properties": map[string]any{
+		"code":       map[string]any{"type": "integer", "description": "HTTP status"},
+		"message":    map[string]any{"type": "string"},
+		"reason":     map[string]any{"type": "string", "description": "machine-readable reason, e.g. INVALID_ARGUMENT"},
+		"request_id": map[string]any{"type": "string", "description": "same as the X-Request-Id response header"},
+	},
+}
+
+// ServeHTTP отдает документ в JSON
+func (d *Document) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	d.mu.RLock()
+	doc := map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]string{"title": d.title, "version": "v1"},
+		"paths":      d.paths,
+		"components": map[string]any{"schemas": map[string]any{"Error": errorSchema}},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	d.mu.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
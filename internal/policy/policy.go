@@ -0,0 +1,146 @@
+// Package policy выбирает параметры задания по сайту и действию (action),
+// которым relying party помечает запрос: "login", "signup", "checkout" и т.п.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Wildcard — ключ политики, применяемой к любому сайту или действию без своей записи
+const Wildcard = "*"
+
+// Action — параметры задания для действия. Нулевые значения означают
+// "решает инстанс": сложность из запроса, тип по порогам сложности, порог 0.
+type Action struct {
+	// Complexity — сложность по умолчанию, если запрос ее не указал
+	Complexity int `json:"complexity"`
+	// ChallengeType — принудительный тип задания (slider-puzzle, slider-rotate, slider-multi)
+	ChallengeType string `json:"challenge_type"`
+	// ScoreThreshold — минимальная уверенность (0–100), при которой решение засчитывается
+	ScoreThreshold int `json:"score_threshold"`
+	// Invisible — невидимый режим: задание выдается, только если оценка риска
+	// клиента ниже PassScore (0 — порог инстанса), иначе сразу выдается токен
+	Invisible bool `json:"invisible"`
+	PassScore int  `json:"pass_score"`
+	// Locale закрепляет локаль заданий сайта ("ru"): фон и шаблон виджета
+	// выбираются для его рынка независимо от локали из запроса
+	Locale string `json:"locale"`
+	// Delivery — как виджет сайта получает картинки: DeliveryInline (data URI
+	// в HTML, для строгих песочниц) или DeliveryURL; пусто — настройка инстанса
+	Delivery string `json:"delivery"`
+	// GeoPin привязывает проверку к месту выдачи задания: GeoPinRegion — ответ
+	// принимается только инстансами региона, где задание выдано, GeoPinCountry —
+	// только из страны клиента при выдаче. Против ферм, которые решают задания в
+	// одной стране, а сдают в другой. Пусто — без привязки.
+	GeoPin string `json:"geo_pin"`
+}
+
+// Способы доставки картинок задания
+const (
+	DeliveryInline = "inline"
+	DeliveryURL    = "url"
+)
+
+// Привязки проверки к месту выдачи задания
+const (
+	GeoPinRegion  = "region"
+	GeoPinCountry = "country"
+)
+
+// Resolver — источник политик; инстанс спрашивает его на каждый NewChallenge
+type Resolver interface {
+	Resolve(siteKey, action string) Action
+}
+
+// Static — политики из конфигурационного файла: сайт -> действие -> параметры.
+// Поиск идет от точного совпадения к "*" сначала по действию, затем по сайту.
+type Static map[string]map[string]Action
+
+// LoadFile читает политики из JSON вида
+// {"site-key": {"login": {"complexity": 60, "score_threshold": 80}, "*": {...}}, "*": {...}}
+func LoadFile(path string) (Static, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var p Static
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate проверяет диапазоны значений всех политик
+func (p Static) Validate() error {
+	for site, actions := range p {
+		for name, a := range actions {
+			if a.Complexity < 0 || a.Complexity > 100 {
+				return fmt.Errorf("policy %s/%s: complexity %d is out of range 0-100", site, name, a.Complexity)
+			}
+			if a.ScoreThreshold < 0 || a.ScoreThreshold > 100 {
+				return fmt.Errorf("policy %s/%s: score threshold %d is out of range 0-100", site, name, a.ScoreThreshold)
+			}
+			if a.PassScore < 0 || a.PassScore > 100 {
+				return fmt.Errorf("policy %s/%s: pass score %d is out of range 0-100", site, name, a.PassScore)
+			}
+			if a.Delivery != "" && a.Delivery != DeliveryInline && a.Delivery != DeliveryURL {
+				return fmt.Errorf("policy %s/%s: delivery must be %q or %q", site, name, DeliveryInline, DeliveryURL)
+			}
+			if a.GeoPin != "" && a.GeoPin != GeoPinRegion && a.GeoPin != GeoPinCountry {
+				return fmt.Errorf("policy %s/%s: geo_pin must be %q or %q", site, name, GeoPinRegion, GeoPinCountry)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve возвращает политику для пары сайт/действие
+func (p Static) Resolve(siteKey, action string) Action {
+	for _, site := range []string{siteKey, Wildcard} {
+		actions, ok := p[site]
+		if !ok {
+			continue
+		}
+		if a, ok := actions[action]; ok && action != "" {
+			return a
+		}
+		if a, ok := actions[Wildcard]; ok {
+			return a
+		}
+	}
+	return Action{}
+}
+
+// Dynamic — политики, которые можно заменить на лету (например, откатом
+// конфигурации); запросы видят либо старый, либо новый набор целиком
+type Dynamic struct {
+	current atomic.Pointer[Static]
+}
+
+// NewDynamic создает заменяемый набор политик
+func NewDynamic(p Static) *Dynamic {
+	d := &Dynamic{}
+	d.Set(p)
+	return d
+}
+
+// Set заменяет политики
+func (d *Dynamic) Set(p Static) {
+	d.current.Store(&p)
+}
+
+// Current возвращает действующие политики
+func (d *Dynamic) Current() Static {
+	return *d.current.Load()
+}
+
+// Resolve возвращает политику для пары сайт/действие из действующего набора
+func (d *Dynamic) Resolve(siteKey, action string) Action {
+	return d.Current().Resolve(siteKey, action)
+}
@@ -0,0 +1,203 @@
+// Package quota считает месячное потребление заданий и проверок по site key
+// и ограничивает его квотами тарифа.
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSiteKey — тенант для запросов без site key
+const DefaultSiteKey = "default"
+
+// ErrExceeded — месячная квота тенанта исчерпана
+var ErrExceeded = errors.New("QUOTA_EXCEEDED")
+
+// Kind — что именно тарифицируется
+type Kind string
+
+const (
+	Challenges    Kind = "challenges"
+	Verifications Kind = "verifications"
+)
+
+// Limits — месячные квоты тенанта; 0 означает "без ограничения"
+type Limits struct {
+	Challenges    int64 `json:"challenges"`
+	Verifications int64 `json:"verifications"`
+}
+
+func (l Limits) of(kind Kind) int64 {
+	if kind == Verifications {
+		return l.Verifications
+	}
+	return l.Challenges
+}
+
+// Usage — потребление тенанта за расчетный месяц
+type Usage struct {
+	SiteKey       string `json:"site_key"`
+	Period        string `json:"period"`
+	Challenges    int64  `json:"challenges"`
+	Verifications int64  `json:"verifications"`
+	Limits        Limits `json:"limits"`
+}
+
+type counters struct {
+	period        string
+	challenges    int64
+	verifications int64
+}
+
+// Tracker хранит счетчики в памяти инстанса; расчетный период — календарный месяц UTC
+type Tracker struct {
+	mu       sync.Mutex
+	defaults Limits
+	limits   map[string]Limits
+	usage    map[string]*counters
+	now      func() time.Time
+}
+
+// New создает трекер с квотами по умолчанию и индивидуальными квотами тенантов
+func New(defaults Limits, perSite map[string]Limits) *Tracker {
+	if perSite == nil {
+		perSite = make(map[string]Limits)
+	}
+	return &Tracker{
+		defaults: defaults,
+		limits:   perSite,
+		usage:    make(map[string]*counters),
+		now:      time.Now,
+	}
+}
+
+// LoadFile читает квоты тенантов из JSON вида {"site-key": {"challenges": 100000, "verifications": 200000}}
+func LoadFile(path string) (map[string]Limits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota file: %w", err)
+	}
+	var limits map[string]Limits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("failed to parse quota file %s: %w", path, err)
+	}
+	return limits, nil
+}
+
+// SetLimits заменяет квоты по умолчанию и квоты тенантов; счетчики сохраняются
+func (t *Tracker) SetLimits(defaults Limits, perSite map[string]Limits) {
+	if perSite == nil {
+		perSite = make(map[string]Limits)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.defaults, t.limits = defaults, perSite
+}
+
+// Limits возвращает квоты по умолчанию и копию квот тенантов
+func (t *Tracker) Limits() (Limits, map[string]Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	perSite := make(map[string]Limits, len(t.limits))
+	for k, l := range t.limits {
+		perSite[k] = l
+	}
+	return t.defaults, perSite
+}
+
+// Consume учитывает одно задание или проверку. Если квота исчерпана,
+// счетчик не меняется и возвращается ErrExceeded.
+func (t *Tracker) Consume(siteKey string, kind Kind) error {
+	return t.consume(siteKey, kind, true)
+}
+
+// Check возвращает ErrExceeded, если Consume отказал бы, не учитывая запрос
+func (t *Tracker) Check(siteKey string, kind Kind) error {
+	return t.consume(siteKey, kind, false)
+}
+
+func (t *Tracker) consume(siteKey string, kind Kind, count bool) error {
+	siteKey = normalize(siteKey)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.countersLocked(siteKey)
+	limit := t.limitsLocked(siteKey).of(kind)
+	n := &c.challenges
+	if kind == Verifications {
+		n = &c.verifications
+	}
+	if limit > 0 && *n >= limit {
+		return fmt.Errorf("%w: %s quota of %d for %s in %s", ErrExceeded, kind, limit, siteKey, c.period)
+	}
+	if count {
+		*n++
+	}
+	return nil
+}
+
+// Usage возвращает потребление тенанта за текущий месяц
+func (t *Tracker) Usage(siteKey string) Usage {
+	siteKey = normalize(siteKey)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.countersLocked(siteKey)
+	return Usage{
+		SiteKey:       siteKey,
+		Period:        c.period,
+		Challenges:    c.challenges,
+		Verifications: c.verifications,
+		Limits:        t.limitsLocked(siteKey),
+	}
+}
+
+// All возвращает потребление всех тенантов, у которых есть счетчики или квоты
+func (t *Tracker) All() []Usage {
+	t.mu.Lock()
+	keys := make(map[string]struct{}, len(t.usage)+len(t.limits))
+	for k := range t.usage {
+		keys[k] = struct{}{}
+	}
+	for k := range t.limits {
+		keys[k] = struct{}{}
+	}
+	t.mu.Unlock()
+
+	list := make([]Usage, 0, len(keys))
+	for k := range keys {
+		list = append(list, t.Usage(k))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SiteKey < list[j].SiteKey })
+	return list
+}
+
+// countersLocked возвращает счетчики текущего месяца, обнуляя их при смене периода
+func (t *Tracker) countersLocked(siteKey string) *counters {
+	period := t.now().UTC().Format("2006-01")
+	c, ok := t.usage[siteKey]
+	if !ok || c.period != period {
+		c = &counters{period: period}
+		t.usage[siteKey] = c
+	}
+	return c
+}
+
+func (t *Tracker) limitsLocked(siteKey string) Limits {
+	if l, ok := t.limits[siteKey]; ok {
+		return l
+	}
+	return t.defaults
+}
+
+func normalize(siteKey string) string {
+	if siteKey == "" {
+		return DefaultSiteKey
+	}
+	return siteKey
+}
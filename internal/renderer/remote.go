@@ -0,0 +1,114 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	rendererpb "captcha-service/api/renderer/v1"
+	"captcha-service/internal/generator"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// maxResponseSize — предел ответа рендерера: в ленивом режиме картинки идут
+// в ответе целиком и не ограничены бюджетом HTML
+const maxResponseSize = 16 << 20
+
+// Remote отдает отрисовку пулу рендереров (cmd/renderer). Картинки возвращаются
+// в ответе и раздаются HTTP-сервером инстанса, поэтому рендерер строит их адреса
+// от адреса инстанса; подписывает их рендерер своим ключом, который должен
+// совпадать с ключом инстанса.
+type Remote struct {
+	conn    *grpc.ClientConn
+	client  rendererpb.RendererServiceClient
+	assets  generator.AssetOptions
+	timeout time.Duration
+}
+
+// Dial подключается к пулу рендереров по addr. Адрес может указывать на
+// несколько бэкендов (dns:///renderer:50061): вызовы распределяются по кругу.
+// Из assets передаются адрес и привязка отрисовки; assets.Sign не используется.
+func Dial(addr string, assets generator.AssetOptions, timeout time.Duration, opts ...grpc.DialOption) (*Remote, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin": {}}]}`),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxResponseSize)),
+	}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to renderer at %s: %w", addr, err)
+	}
+	return &Remote{
+		conn:    conn,
+		client:  rendererpb.NewRendererServiceClient(conn),
+		assets:  assets,
+		timeout: timeout,
+	}, nil
+}
+
+func (r *Remote) Render(ctx context.Context, req Request) (*generator.Challenge, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	rr := &rendererpb.RenderRequest{
+		Kind:         req.Kind,
+		Pieces:       int32(req.Pieces),
+		AssetBaseUrl: r.assets.BaseURL,
+		BindRender:   r.assets.BindRender,
+		Locale:       req.Locale,
+		AnswerSchema: req.Widget.AnswerSchema,
+		Obfuscation:  req.Widget.Obfuscation,
+	}
+	if req.Inline {
+		rr.AssetBaseUrl, rr.BindRender = "", false
+	}
+	res, err := r.client.Render(ctx, rr)
+	if status.Code(err) == codes.ResourceExhausted {
+		return nil, fmt.Errorf("%w: %s", generator.ErrHTMLTooLarge, status.Convert(err).Message())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote render failed: %w", err)
+	}
+	return fromProto(res), nil
+}
+
+// Close закрывает соединение с пулом
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+func fromProto(res *rendererpb.RenderResponse) *generator.Challenge {
+	c := &generator.Challenge{
+		Kind:     res.GetKind(),
+		HTML:     res.GetHtml(),
+		X:        int(res.GetX()),
+		Width:    int(res.GetWidth()),
+		Height:   int(res.GetHeight()),
+		Step:     res.GetStep(),
+		Angle:    res.GetAngle(),
+		AssetKey: res.GetAssetKey(),
+		Stages: generator.Stages{
+			Render:   time.Duration(res.GetStages().GetRenderUs()) * time.Microsecond,
+			Encode:   time.Duration(res.GetStages().GetEncodeUs()) * time.Microsecond,
+			Template: time.Duration(res.GetStages().GetTemplateUs()) * time.Microsecond,
+		},
+		Template: generator.TemplateKey{
+			Kind:    res.GetTemplate().GetKind(),
+			Version: res.GetTemplate().GetVersion(),
+			Locale:  res.GetTemplate().GetLocale(),
+		},
+	}
+	for _, p := range res.GetPieces() {
+		c.Pieces = append(c.Pieces, generator.PieceAnswer{ID: p.GetId(), X: int(p.GetX())})
+	}
+	for _, a := range res.GetAssets() {
+		c.Assets = append(c.Assets, generator.Asset{Name: a.GetName(), MIME: a.GetMime(), Data: a.GetData()})
+	}
+	return c
+}
@@ -0,0 +1,48 @@
+// Package renderer — отрисовка заданий капчи: в процессе инстанса или
+// в отдельном пуле рендереров по gRPC за одним интерфейсом.
+package renderer
+
+import (
+	"context"
+
+	"captcha-service/internal/generator"
+)
+
+// Request — что отрисовать
+type Request struct {
+	Kind string
+	// Pieces — число фрагментов для generator.KindMulti
+	Pieces int
+	// Locale выбирает фон и шаблон виджета; пустая — по умолчанию
+	Locale string
+	// Widget — схема ответа и обфускация по возможностям виджета
+	Widget generator.Widget
+	// Inline встраивает картинки в HTML как data URI, даже если у рендерера
+	// есть адрес картинок
+	Inline bool
+}
+
+// Renderer отрисовывает картинки и HTML задания
+type Renderer interface {
+	Render(ctx context.Context, req Request) (*generator.Challenge, error)
+}
+
+// Local рисует задания генератором в том же процессе
+type Local struct {
+	gen    *generator.Generator
+	assets generator.AssetOptions
+}
+
+// NewLocal создает рендерер поверх генератора; assets — адреса картинок
+// ленивой загрузки (пустой BaseURL — картинки встраиваются в HTML)
+func NewLocal(gen *generator.Generator, assets generator.AssetOptions) *Local {
+	return &Local{gen: gen, assets: assets}
+}
+
+func (l *Local) Render(ctx context.Context, req Request) (*generator.Challenge, error) {
+	assets := l.assets
+	if req.Inline {
+		assets = generator.AssetOptions{}
+	}
+	return l.gen.GenerateKind(ctx, req.Kind, req.Pieces, req.Locale, req.Widget, assets)
+}
@@ -0,0 +1,70 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+
+	rendererpb "captcha-service/api/renderer/v1"
+	"captcha-service/internal/generator"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server — gRPC-сервис пула рендереров поверх локального генератора
+type Server struct {
+	rendererpb.UnimplementedRendererServiceServer
+	gen *generator.Generator
+	// sign подписывает адреса картинок; nil — адреса не подписываются
+	sign func(key, name string) string
+}
+
+// NewServer создает сервис отрисовки; sign должен подписывать адреса тем же
+// ключом, которым их проверяют инстансы капчи
+func NewServer(gen *generator.Generator, sign func(key, name string) string) *Server {
+	return &Server{gen: gen, sign: sign}
+}
+
+func (s *Server) Render(ctx context.Context, req *rendererpb.RenderRequest) (*rendererpb.RenderResponse, error) {
+	assets := generator.AssetOptions{BaseURL: req.GetAssetBaseUrl(), BindRender: req.GetBindRender()}
+	if assets.BaseURL != "" {
+		assets.Sign = s.sign
+	}
+	c, err := s.gen.GenerateKind(ctx, req.GetKind(), int(req.GetPieces()), req.GetLocale(), generator.Widget{
+		AnswerSchema: req.GetAnswerSchema(),
+		Obfuscation:  req.GetObfuscation(),
+	}, assets)
+	if errors.Is(err, generator.ErrHTMLTooLarge) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &rendererpb.RenderResponse{
+		Kind:     c.Kind,
+		Html:     c.HTML,
+		X:        int32(c.X),
+		Width:    int32(c.Width),
+		Height:   int32(c.Height),
+		Step:     c.Step,
+		Angle:    c.Angle,
+		AssetKey: c.AssetKey,
+		Stages: &rendererpb.Stages{
+			RenderUs:   c.Stages.Render.Microseconds(),
+			EncodeUs:   c.Stages.Encode.Microseconds(),
+			TemplateUs: c.Stages.Template.Microseconds(),
+		},
+		Template: &rendererpb.Template{
+			Kind:    c.Template.Kind,
+			Version: c.Template.Version,
+			Locale:  c.Template.Locale,
+		},
+	}
+	for _, p := range c.Pieces {
+		res.Pieces = append(res.Pieces, &rendererpb.PieceAnswer{Id: p.ID, X: int32(p.X)})
+	}
+	for _, a := range c.Assets {
+		res.Assets = append(res.Assets, &rendererpb.Asset{Name: a.Name, Mime: a.MIME, Data: a.Data})
+	}
+	return res, nil
+}
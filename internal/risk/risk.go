@@ -0,0 +1,96 @@
+// Package risk оценивает клиента до выдачи задания: сайты в невидимом режиме
+// пропускают клиента без визуального задания, если оценка не ниже порога.
+// Оценка — базовое значение плюс поправки правил, в диапазоне 0–100.
+package risk
+
+import (
+	"strings"
+)
+
+// Signals — что известно о клиенте до выдачи задания
+type Signals struct {
+	ClientIP  string
+	UserAgent string
+	// RecentFailures и RecentSolves — исходы проверок этого клиента на инстансе
+	// за окно истории
+	RecentFailures int
+	RecentSolves   int
+	// SharedChallenges — задания клиента, на которые отвечали и из другого
+	// стрима (пересылка токена, ферма решателей)
+	SharedChallenges int
+}
+
+// Rule — правило оценки: возвращает поправку к оценке (0 — правило не сработало)
+type Rule struct {
+	Name  string
+	Check func(Signals) int
+}
+
+// Assessment — итог оценки и сработавшие правила
+type Assessment struct {
+	Score   int32
+	Reasons []string
+}
+
+// Engine применяет правила к базовой оценке
+type Engine struct {
+	Base  int
+	Rules []Rule
+}
+
+// NewEngine создает оценщик с правилами по умолчанию
+func NewEngine(base int) *Engine {
+	return &Engine{Base: base, Rules: DefaultRules()}
+}
+
+// Score оценивает клиента
+func (e *Engine) Score(s Signals) Assessment {
+	score := e.Base
+	var reasons []string
+	for _, r := range e.Rules {
+		if d := r.Check(s); d != 0 {
+			score += d
+			reasons = append(reasons, r.Name)
+		}
+	}
+	return Assessment{Score: int32(min(max(score, 0), 100)), Reasons: reasons}
+}
+
+// automationMarkers — подстроки User-Agent headless-браузеров и HTTP-библиотек
+var automationMarkers = []string{
+	"headlesschrome", "phantomjs", "selenium", "puppeteer", "playwright",
+	"curl/", "wget/", "python-requests", "python-urllib", "go-http-client", "okhttp",
+}
+
+// DefaultRules — правила по умолчанию: без User-Agent и с признаками
+// автоматизации клиент получает задание, недавние провалы снижают оценку,
+// недавние решения немного повышают, задания, поделенные с другими стримами,
+// снижают сильно
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "no_user_agent", Check: func(s Signals) int {
+			if strings.TrimSpace(s.UserAgent) == "" {
+				return -30
+			}
+			return 0
+		}},
+		{Name: "automation_user_agent", Check: func(s Signals) int {
+			ua := strings.ToLower(s.UserAgent)
+			for _, m := range automationMarkers {
+				if strings.Contains(ua, m) {
+					return -60
+				}
+			}
+			return 0
+		}},
+		{Name: "recent_failures", Check: func(s Signals) int {
+			return -15 * min(s.RecentFailures, 4)
+		}},
+		{Name: "recent_solves", Check: func(s Signals) int {
+			return 10 * min(s.RecentSolves, 2)
+		}},
+		{Name: "shared_challenges", Check: func(s Signals) int {
+			return -40 * min(s.SharedChallenges, 2)
+		}},
+	}
+}
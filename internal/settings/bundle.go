@@ -0,0 +1,168 @@
+package settings
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"captcha-service/internal/policy"
+	"captcha-service/internal/quota"
+)
+
+// ErrSignature — подпись пакета не совпала: пакет изменен или подписан
+// секретом другого флота
+var ErrSignature = errors.New("bundle signature is invalid")
+
+// Tenant — конфигурация одного тенанта: политики его действий и квота
+type Tenant struct {
+	Policies map[string]policy.Action `json:"policies,omitempty"`
+	// Quota — собственная квота; nil — действуют квоты по умолчанию
+	Quota *quota.Limits `json:"quota,omitempty"`
+}
+
+// Bundle — конфигурация всех тенантов для переноса между окружениями
+// (staging -> prod). Квоты по умолчанию в пакет не входят: они у каждого
+// окружения свои.
+type Bundle struct {
+	// Environment — окружение, из которого снят пакет
+	Environment string    `json:"environment,omitempty"`
+	Exported    time.Time `json:"exported"`
+	// Version — версия настроек, с которой снят пакет
+	Version int               `json:"version"`
+	Tenants map[string]Tenant `json:"tenants"`
+}
+
+// SignedBundle — пакет и HMAC-SHA256 его байтов в hex. Подписываются байты
+// как есть, поэтому пакет не нужно приводить к каноническому виду.
+type SignedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"`
+}
+
+// Tenants раскладывает настройки по тенантам
+func Tenants(s Settings) map[string]Tenant {
+	tenants := make(map[string]Tenant)
+	for site, actions := range s.Policies {
+		t := tenants[site]
+		t.Policies = maps.Clone(actions)
+		tenants[site] = t
+	}
+	for site, l := range s.Quotas {
+		t := tenants[site]
+		t.Quota = &l
+		tenants[site] = t
+	}
+	return tenants
+}
+
+// Sign подписывает пакет секретом secret
+func Sign(b Bundle, secret []byte) (SignedBundle, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return SignedBundle{}, err
+	}
+	return SignedBundle{Bundle: data, Signature: hex.EncodeToString(bundleMAC(data, secret))}, nil
+}
+
+// Verify проверяет подпись пакета за постоянное время и разбирает его
+func (sb SignedBundle) Verify(secret []byte) (Bundle, error) {
+	sig, err := hex.DecodeString(sb.Signature)
+	if err != nil || !hmac.Equal(sig, bundleMAC(sb.Bundle, secret)) {
+		return Bundle{}, ErrSignature
+	}
+	var b Bundle
+	if err := json.Unmarshal(sb.Bundle, &b); err != nil {
+		return Bundle{}, err
+	}
+	return b, nil
+}
+
+func bundleMAC(data, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Что делать с тенантом, который уже есть и настроен иначе
+const (
+	// OnConflictFail — импорт не выполняется, пока есть конфликты
+	OnConflictFail = "fail"
+	// OnConflictOverwrite — конфигурация тенанта заменяется конфигурацией из пакета
+	OnConflictOverwrite = "overwrite"
+	// OnConflictSkip — тенант остается как есть
+	OnConflictSkip = "skip"
+)
+
+// TenantResult — что импорт делает с тенантом
+type TenantResult struct {
+	Site string `json:"site"`
+	// Result — added, updated, unchanged, skipped или conflict
+	Result string `json:"result"`
+	// Changes — отличия конфигурации из пакета от текущей
+	Changes []string `json:"changes,omitempty"`
+}
+
+// Merge накладывает тенантов из пакета на current и возвращает новые
+// настройки и результат по каждому тенанту пакета. Тенанты, которых нет в
+// пакете, не меняются; тенант из пакета заменяется целиком.
+func Merge(current Settings, tenants map[string]Tenant, onConflict string) (Settings, []TenantResult) {
+	next := Settings{
+		Policies:      maps.Clone(current.Policies),
+		QuotaDefaults: current.QuotaDefaults,
+		Quotas:        maps.Clone(current.Quotas),
+	}
+	if next.Policies == nil {
+		next.Policies = policy.Static{}
+	}
+	if next.Quotas == nil {
+		next.Quotas = map[string]quota.Limits{}
+	}
+	existing := Tenants(current)
+	results := make([]TenantResult, 0, len(tenants))
+	for _, site := range slices.Sorted(maps.Keys(tenants)) {
+		t := tenants[site]
+		old, ok := existing[site]
+		res := TenantResult{Site: site, Changes: Diff(old.settings(site), t.settings(site))}
+		switch {
+		case !ok:
+			res.Result = "added"
+		case len(res.Changes) == 0:
+			res.Result = "unchanged"
+		case onConflict == OnConflictOverwrite:
+			res.Result = "updated"
+		case onConflict == OnConflictSkip:
+			res.Result = "skipped"
+		default:
+			res.Result = "conflict"
+		}
+		if res.Result == "added" || res.Result == "updated" {
+			delete(next.Policies, site)
+			delete(next.Quotas, site)
+			if len(t.Policies) > 0 {
+				next.Policies[site] = maps.Clone(t.Policies)
+			}
+			if t.Quota != nil {
+				next.Quotas[site] = *t.Quota
+			}
+		}
+		results = append(results, res)
+	}
+	return next, results
+}
+
+// settings — конфигурация тенанта в виде настроек (для Diff)
+func (t Tenant) settings(site string) Settings {
+	s := Settings{}
+	if len(t.Policies) > 0 {
+		s.Policies = policy.Static{site: t.Policies}
+	}
+	if t.Quota != nil {
+		s.Quotas = map[string]quota.Limits{site: *t.Quota}
+	}
+	return s
+}
@@ -0,0 +1,279 @@
+// Package settings хранит конфигурацию тенантов и глобальную конфигурацию
+// (политики действий и квоты) как историю версий: каждое изменение — новый
+// снимок с автором, временем и списком изменений, а откат к любой версии
+// записывается как еще один снимок.
+package settings
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"captcha-service/internal/policy"
+	"captcha-service/internal/quota"
+)
+
+var (
+	// ErrNotFound — запрошенной версии нет в истории
+	ErrNotFound = errors.New("settings version not found")
+	// ErrInvalid — версию отвергла проверка
+	ErrInvalid = errors.New("invalid settings")
+)
+
+// Settings — конфигурация, которую можно менять и откатывать на лету
+type Settings struct {
+	Policies policy.Static `json:"policies"`
+	// QuotaDefaults — квоты тенантов без собственной записи в Quotas
+	QuotaDefaults quota.Limits            `json:"quota_defaults"`
+	Quotas        map[string]quota.Limits `json:"quotas"`
+}
+
+// Snapshot — версия конфигурации
+type Snapshot struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Author  string    `json:"author"`
+	Comment string    `json:"comment,omitempty"`
+	// RollbackOf — версия, к которой откатывали; 0 — обычное изменение
+	RollbackOf int `json:"rollback_of,omitempty"`
+	// Changes — что изменилось относительно предыдущей версии
+	Changes  []string `json:"changes,omitempty"`
+	Settings Settings `json:"settings,omitzero"`
+}
+
+// History — история версий. С файлом каждая версия дописывается в него
+// строкой JSON и переживает перезапуск, без файла живет в памяти инстанса.
+// Файл на общем томе — общая история флота: инстансы дописывают в него свои
+// изменения и перечитывают чужие (Reload).
+type History struct {
+	mu   sync.Mutex
+	path string
+	// size — размер файла при последнем чтении
+	size      int64
+	validate  func(Settings) error
+	snapshots []Snapshot
+	now       func() time.Time
+}
+
+// Open загружает историю из path (файла может еще не быть). validate
+// проверяет каждую новую версию, включая откаты, до ее записи.
+func Open(path string, validate func(Settings) error) (*History, error) {
+	h := &History{path: path, validate: validate, now: time.Now}
+	if _, err := h.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Reload перечитывает файл истории, если его дописал другой инстанс (файл на
+// общем томе); true — появились новые версии. Без файла ничего не делает.
+func (h *History) Reload() (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reloadLocked()
+}
+
+// reloadLocked читает файл целиком, если его размер изменился с прошлого
+// чтения; вызывается под mu
+func (h *History) reloadLocked() (bool, error) {
+	if h.path == "" {
+		return false, nil
+	}
+	f, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open settings history: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to read settings history: %w", err)
+	}
+	if info.Size() == h.size {
+		return false, nil
+	}
+	var snapshots []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return false, fmt.Errorf("failed to parse settings history %s: %w", h.path, err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read settings history: %w", err)
+	}
+	changed := lastVersion(snapshots) != lastVersion(h.snapshots)
+	h.snapshots, h.size = snapshots, info.Size()
+	return changed, nil
+}
+
+func lastVersion(list []Snapshot) int {
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+// Current возвращает последнюю версию; false — история пуста
+func (h *History) Current() (Snapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return h.snapshots[len(h.snapshots)-1], true
+}
+
+// List возвращает все версии от новых к старым без самих настроек
+func (h *History) List() []Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]Snapshot, 0, len(h.snapshots))
+	for i := len(h.snapshots) - 1; i >= 0; i-- {
+		s := h.snapshots[i]
+		s.Settings = Settings{}
+		list = append(list, s)
+	}
+	return list
+}
+
+// Get возвращает версию целиком
+func (h *History) Get(version int) (Snapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.getLocked(version)
+}
+
+func (h *History) getLocked(version int) (Snapshot, error) {
+	for _, s := range h.snapshots {
+		if s.Version == version {
+			return s, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("%w: %d", ErrNotFound, version)
+}
+
+// Commit записывает новую версию конфигурации
+func (h *History) Commit(s Settings, author, comment string) (Snapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.appendLocked(Snapshot{Author: author, Comment: comment, Settings: s})
+}
+
+// Rollback записывает новой версией копию версии version; 0 — предыдущая
+// перед текущей
+func (h *History) Rollback(version int, author string) (Snapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if version == 0 {
+		if len(h.snapshots) < 2 {
+			return Snapshot{}, fmt.Errorf("%w: no previous version", ErrNotFound)
+		}
+		version = h.snapshots[len(h.snapshots)-2].Version
+	}
+	target, err := h.getLocked(version)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return h.appendLocked(Snapshot{
+		Author:     author,
+		Comment:    fmt.Sprintf("rollback to version %d", version),
+		RollbackOf: version,
+		Settings:   target.Settings,
+	})
+}
+
+// appendLocked проверяет и нумерует снимок, считает изменения и сохраняет его;
+// вызывается под mu
+func (h *History) appendLocked(s Snapshot) (Snapshot, error) {
+	// Номер версии продолжает историю с учетом версий других инстансов
+	if _, err := h.reloadLocked(); err != nil {
+		return Snapshot{}, err
+	}
+	if h.validate != nil {
+		if err := h.validate(s.Settings); err != nil {
+			return Snapshot{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+	var prev Settings
+	if n := len(h.snapshots); n > 0 {
+		prev = h.snapshots[n-1].Settings
+		s.Version = h.snapshots[n-1].Version + 1
+	} else {
+		s.Version = 1
+	}
+	s.Time = h.now()
+	s.Changes = Diff(prev, s.Settings)
+	if h.path != "" {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to save settings history: %w", err)
+		}
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to save settings history: %w", err)
+		}
+	}
+	h.snapshots = append(h.snapshots, s)
+	return s, nil
+}
+
+// Diff перечисляет отличия b от a: добавленные, удаленные и измененные политики и квоты
+func Diff(a, b Settings) []string {
+	before, after := flatten(a), flatten(b)
+	var changes []string
+	for k, v := range after {
+		old, ok := before[k]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("added %s: %s", k, v))
+		case old != v:
+			changes = append(changes, fmt.Sprintf("changed %s: %s -> %s", k, old, v))
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok {
+			changes = append(changes, fmt.Sprintf("removed %s: %s", k, v))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// flatten раскладывает настройки в пары путь -> JSON значения
+func flatten(s Settings) map[string]string {
+	m := make(map[string]string)
+	put := func(key string, v any) {
+		data, _ := json.Marshal(v)
+		m[key] = string(data)
+	}
+	for site, actions := range s.Policies {
+		for action, a := range actions {
+			put("policy "+site+"/"+action, a)
+		}
+	}
+	if s.QuotaDefaults != (quota.Limits{}) {
+		put("quota defaults", s.QuotaDefaults)
+	}
+	for site, l := range s.Quotas {
+		put("quota "+site, l)
+	}
+	return m
+}
@@ -0,0 +1,341 @@
+// Package slo считает цели уровня обслуживания (SLO) вызовов сервиса прямо в
+// инстансе: доля "хороших" вызовов метода (без ошибки сервера и не дольше
+// порога задержки) против цели, например 99.9% NewChallenge быстрее 300ms.
+// Алерты — по скорости сжигания бюджета ошибок в двух окнах (multiwindow
+// burn rate): быстрое сжигание будит дежурного, медленное заводит задачу.
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Objective — цель для одного метода
+type Objective struct {
+	Method string `json:"method"`
+	// Target — доля хороших вызовов в процентах, например 99.9
+	Target float64 `json:"target"`
+	// Latency — вызов дольше считается плохим; 0 — только доступность
+	Latency time.Duration `json:"-"`
+}
+
+func (o Objective) MarshalJSON() ([]byte, error) {
+	type plain Objective
+	return json.Marshal(struct {
+		plain
+		Latency string `json:"latency,omitempty"`
+	}{plain(o), durationString(o.Latency)})
+}
+
+func (o Objective) String() string {
+	s := fmt.Sprintf("%s %g%%", o.Method, o.Target)
+	if o.Latency > 0 {
+		s += " under " + o.Latency.String()
+	}
+	return s
+}
+
+// Rule — условие алерта: скорость сжигания не ниже Burn и в длинном, и в
+// коротком окне. Короткое окно гасит алерт вскоре после того, как проблема ушла.
+type Rule struct {
+	Severity string
+	Long     time.Duration
+	Short    time.Duration
+	Burn     float64
+}
+
+// DefaultRules — правила из SRE workbook для 30-дневного бюджета: за час с
+// сжиганием 14.4 уходит 2% бюджета, за 6 часов с сжиганием 6 — 5%
+var DefaultRules = []Rule{
+	{Severity: "page", Long: time.Hour, Short: 5 * time.Minute, Burn: 14.4},
+	{Severity: "ticket", Long: 6 * time.Hour, Short: 30 * time.Minute, Burn: 6},
+}
+
+// minRequests — меньше вызовов в длинном окне не хватает для вывода: один
+// сбой на десяти запросах дал бы сжигание в сотни раз
+const minRequests = 20
+
+// ParseObjectives читает цели вида {"NewChallenge": "99.9/300ms", "Assess": "99.95"}
+func ParseObjectives(spec map[string]string) ([]Objective, error) {
+	var list []Objective
+	for method, value := range spec {
+		target, latency, _ := strings.Cut(value, "/")
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(target, "%"), 64)
+		if err != nil || pct <= 0 || pct >= 100 {
+			return nil, fmt.Errorf("%s: target %q must be a percentage between 0 and 100", method, target)
+		}
+		o := Objective{Method: method, Target: pct}
+		if latency != "" {
+			if o.Latency, err = time.ParseDuration(latency); err != nil || o.Latency <= 0 {
+				return nil, fmt.Errorf("%s: invalid latency threshold %q", method, latency)
+			}
+		}
+		list = append(list, o)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Method < list[j].Method })
+	return list, nil
+}
+
+// bucket — вызовы за одну минуту
+type bucket struct {
+	minute    int64
+	total, ok int64
+}
+
+// series — поминутные счетчики вызовов метода за самое длинное окно правил
+type series struct {
+	objective Objective
+	buckets   []bucket
+	firing    map[string]bool
+}
+
+func (s *series) add(now time.Time, good bool) {
+	m := now.Unix() / 60
+	b := &s.buckets[m%int64(len(s.buckets))]
+	if b.minute != m {
+		*b = bucket{minute: m}
+	}
+	b.total++
+	if good {
+		b.ok++
+	}
+}
+
+// sum — вызовы и плохие вызовы за последние window
+func (s *series) sum(now time.Time, window time.Duration) (total, bad int64) {
+	m := now.Unix() / 60
+	from := m - int64(window/time.Minute)
+	for _, b := range s.buckets {
+		if b.minute > from && b.minute <= m {
+			total += b.total
+			bad += b.total - b.ok
+		}
+	}
+	return total, bad
+}
+
+// burn — скорость сжигания бюджета: доля плохих вызовов к допустимой доле,
+// с точностью до сотых
+func (s *series) burn(total, bad int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rate := float64(bad) / float64(total) / ((100 - s.objective.Target) / 100)
+	return math.Round(rate*100) / 100
+}
+
+// Window — вызовы и скорость сжигания за окно
+type Window struct {
+	Window time.Duration `json:"-"`
+	Total  int64         `json:"total"`
+	Bad    int64         `json:"bad"`
+	Burn   float64       `json:"burn_rate"`
+}
+
+func (w Window) MarshalJSON() ([]byte, error) {
+	type plain Window
+	return json.Marshal(struct {
+		Window string `json:"window"`
+		plain
+	}{w.Window.String(), plain(w)})
+}
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// Status — состояние цели на момент проверки
+type Status struct {
+	Objective Objective `json:"objective"`
+	Windows   []Window  `json:"windows"`
+	// Firing — серьезность сработавших правил
+	Firing []string `json:"firing"`
+}
+
+// Alert — тело POST в вебхук при срабатывании правила и при его снятии
+type Alert struct {
+	// Alert — slo_burn_rate или slo_burn_rate_resolved
+	Alert     string    `json:"alert"`
+	Severity  string    `json:"severity"`
+	Objective Objective `json:"objective"`
+	// Summary — описание для людей: что и насколько быстро сжигается
+	Summary string    `json:"summary"`
+	Long    Window    `json:"long"`
+	Short   Window    `json:"short"`
+	Time    time.Time `json:"time"`
+}
+
+// Алерты, которые Tracker отправляет в вебхуки
+const (
+	AlertBurnRate         = "slo_burn_rate"
+	AlertBurnRateResolved = "slo_burn_rate_resolved"
+)
+
+// Tracker копит вызовы методов и проверяет правила
+type Tracker struct {
+	rules    []Rule
+	webhooks []string
+	client   *http.Client
+
+	// OnAlert получает каждый алерт (для метрик и лога)
+	OnAlert func(Alert)
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// NewTracker создает трекер целей objectives с правилами rules
+func NewTracker(objectives []Objective, rules []Rule, webhooks []string, timeout time.Duration) *Tracker {
+	var longest time.Duration
+	for _, r := range rules {
+		longest = max(longest, r.Long, r.Short)
+	}
+	t := &Tracker{rules: rules, webhooks: webhooks, client: &http.Client{Timeout: timeout}, series: map[string]*series{}}
+	for _, o := range objectives {
+		t.series[o.Method] = &series{
+			objective: o,
+			buckets:   make([]bucket, int(longest/time.Minute)+1),
+			firing:    map[string]bool{},
+		}
+	}
+	return t
+}
+
+// Record учитывает вызов method длительностью elapsed; failed — ошибка на
+// стороне сервиса (ошибки клиента бюджет не сжигают). Возвращает, хорош ли
+// вызов; tracked — у метода есть цель (вызовы остальных не учитываются).
+func (t *Tracker) Record(method string, elapsed time.Duration, failed bool) (good, tracked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.series[method]
+	if !ok {
+		return false, false
+	}
+	good = !failed && (s.objective.Latency == 0 || elapsed <= s.objective.Latency)
+	s.add(time.Now(), good)
+	return good, true
+}
+
+// Run проверяет правила каждые interval, пока не отменен ctx
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Check()
+		}
+	}
+}
+
+// Check считает скорости сжигания и отправляет алерты о срабатывании и
+// снятии правил; алерт уходит только при смене состояния
+func (t *Tracker) Check() []Status {
+	list, alerts := t.evaluate(true)
+	for _, alert := range alerts {
+		if t.OnAlert != nil {
+			t.OnAlert(alert)
+		}
+		go t.notify(alert)
+	}
+	return list
+}
+
+// Status — состояние целей на момент последней проверки правил
+func (t *Tracker) Status() []Status {
+	list, _ := t.evaluate(false)
+	return list
+}
+
+// evaluate считает окна всех целей; с update — меняет состояние правил и
+// возвращает алерты о переходах
+func (t *Tracker) evaluate(update bool) ([]Status, []Alert) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var alerts []Alert
+	list := make([]Status, 0, len(t.series))
+	for _, s := range t.series {
+		st := Status{Objective: s.objective, Firing: []string{}}
+		for _, r := range t.rules {
+			long, short := s.window(now, r.Long), s.window(now, r.Short)
+			st.Windows = append(st.Windows, long, short)
+			firing := long.Total >= minRequests && long.Burn >= r.Burn && short.Burn >= r.Burn
+			if update && firing != s.firing[r.Severity] {
+				s.firing[r.Severity] = firing
+				alert := Alert{Alert: AlertBurnRate, Severity: r.Severity, Objective: s.objective, Long: long, Short: short, Time: now}
+				if !firing {
+					alert.Alert = AlertBurnRateResolved
+				}
+				alert.Summary = fmt.Sprintf("%s: error budget burns %.1fx over %s and %.1fx over %s, threshold %gx",
+					s.objective, long.Burn, r.Long, short.Burn, r.Short, r.Burn)
+				alerts = append(alerts, alert)
+			}
+			if s.firing[r.Severity] {
+				st.Firing = append(st.Firing, r.Severity)
+			}
+		}
+		st.Windows = dedupWindows(st.Windows)
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Objective.Method < list[j].Objective.Method })
+	return list, alerts
+}
+
+func (s *series) window(now time.Time, d time.Duration) Window {
+	total, bad := s.sum(now, d)
+	return Window{Window: d, Total: total, Bad: bad, Burn: s.burn(total, bad)}
+}
+
+// dedupWindows сортирует окна по длительности и убирает повторы
+func dedupWindows(list []Window) []Window {
+	sort.Slice(list, func(i, j int) bool { return list[i].Window < list[j].Window })
+	out := list[:0]
+	for _, w := range list {
+		if len(out) == 0 || out[len(out)-1].Window != w.Window {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// notify отправляет алерт во все вебхуки; ошибки только логируются
+func (t *Tracker) notify(alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Failed to encode SLO alert: %v", err)
+		return
+	}
+	for _, url := range t.webhooks {
+		if err := t.post(url, body); err != nil {
+			log.Printf("Failed to send SLO alert to %s: %v", url, err)
+		}
+	}
+}
+
+func (t *Tracker) post(url string, body []byte) error {
+	resp, err := t.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
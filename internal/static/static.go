@@ -0,0 +1,97 @@
+// Package static хранит неизменяемые файлы виджета (CSS и JS оболочек) под
+// именами из хэша содержимого. Файл по имени никогда не меняется, поэтому он
+// отдается с годовым Cache-Control: immutable и кэшируется CDN перед сервисом;
+// новая версия виджета или ротация обфускации дает новые имена, а не
+// инвалидацию кэша.
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+
+	"captcha-service/internal/httperr"
+)
+
+// CacheControl — заголовок ответа с файлом
+const CacheControl = "public, max-age=31536000, immutable"
+
+// DefaultLimit — сколько файлов хранится по умолчанию
+const DefaultLimit = 2048
+
+// Store — хранилище файлов по хэшу содержимого. При переполнении удаляются
+// самые старые файлы: на них ссылаются только давно выданные задания, а у CDN
+// они уже закэшированы.
+type Store struct {
+	mu    sync.RWMutex
+	files map[string]file
+	order []string
+	limit int
+}
+
+type file struct {
+	mime string
+	data []byte
+}
+
+// New создает хранилище не более чем на limit файлов (0 — DefaultLimit)
+func New(limit int) *Store {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Store{files: make(map[string]file), limit: limit}
+}
+
+// Put сохраняет data с расширением ext (".css", ".js") и возвращает имя файла
+func (s *Store) Put(ext string, data []byte) string {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:12]) + ext
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[name]; ok {
+		return name
+	}
+	for len(s.order) >= s.limit {
+		delete(s.files, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.files[name] = file{mime: mime.TypeByExtension(ext), data: data}
+	s.order = append(s.order, name)
+	return name
+}
+
+// Len — число файлов в хранилище
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.files)
+}
+
+// ServeHTTP отдает файл {name} из шаблона ручки
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.PathValue("name"))
+	s.mu.RLock()
+	f, ok := s.files[name]
+	s.mu.RUnlock()
+	if !ok {
+		httperr.Write(w, r, http.StatusNotFound, "file not found")
+		return
+	}
+	h := w.Header()
+	// Имя — хэш содержимого, поэтому оно же служит ETag
+	etag := strconv.Quote(name)
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", CacheControl)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", f.mime)
+	h.Set("Content-Length", strconv.Itoa(len(f.data)))
+	w.Write(f.data)
+}
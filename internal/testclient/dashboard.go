@@ -0,0 +1,326 @@
+package testclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	// dashboardDefault и dashboardMax — сколько заданий дашборд выдает за раз
+	dashboardDefault = 4
+	dashboardMax     = 16
+	// feedKeepAlive — комментарий SSE, чтобы прокси не закрывали простаивающий поток
+	feedKeepAlive = 15 * time.Second
+)
+
+// feedEvent — событие живой ленты дашборда: issued, submitted, result или control
+type feedEvent struct {
+	Type        string `json:"type"`
+	ChallengeID string `json:"challenge_id"`
+	// IssueMs — сколько шел NewChallenge; SolveMs — от выдачи до отправки решения;
+	// VerifyMs — от отправки решения до ответа сервиса
+	IssueMs    int64  `json:"issue_ms,omitempty"`
+	SolveMs    int64  `json:"solve_ms,omitempty"`
+	VerifyMs   int64  `json:"verify_ms,omitempty"`
+	Confidence int32  `json:"confidence"`
+	Kind       string `json:"kind,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// challengeTiming — моменты жизни задания для расчета времен
+type challengeTiming struct {
+	issued    time.Time
+	submitted time.Time
+}
+
+// eventFeed раздает события заданий всем открытым дашбордам по SSE
+type eventFeed struct {
+	timings *cache.Cache
+
+	mu   sync.Mutex
+	subs map[chan feedEvent]struct{}
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{timings: cache.New(challengeTTL, challengeTTL), subs: make(map[chan feedEvent]struct{})}
+}
+
+func (f *eventFeed) timing(id string) *challengeTiming {
+	if t, ok := f.timings.Get(id); ok {
+		return t.(*challengeTiming)
+	}
+	return &challengeTiming{}
+}
+
+func (f *eventFeed) issued(id string, took time.Duration) {
+	f.timings.SetDefault(id, &challengeTiming{issued: time.Now()})
+	f.publish(feedEvent{Type: "issued", ChallengeID: id, IssueMs: took.Milliseconds()})
+}
+
+func (f *eventFeed) submitted(id string) {
+	t := f.timing(id)
+	t.submitted = time.Now()
+	f.timings.SetDefault(id, t)
+	ev := feedEvent{Type: "submitted", ChallengeID: id}
+	if !t.issued.IsZero() {
+		ev.SolveMs = t.submitted.Sub(t.issued).Milliseconds()
+	}
+	f.publish(ev)
+}
+
+// fromService превращает событие gRPC-стрима в событие ленты
+func (f *eventFeed) fromService(res *captchapb.ServerEvent) {
+	var ev feedEvent
+	switch {
+	case res.GetResult() != nil:
+		ev = feedEvent{Type: "result", ChallengeID: res.GetResult().GetChallengeId(), Confidence: res.GetResult().GetConfidencePercent()}
+	case res.GetControl() != nil:
+		ctrl := res.GetControl()
+		ev = feedEvent{Type: "control", ChallengeID: ctrl.GetChallengeId(), Kind: ctrl.GetKind().String(), Message: ctrl.GetMessage()}
+	default:
+		return
+	}
+	if t := f.timing(ev.ChallengeID); !t.submitted.IsZero() {
+		ev.VerifyMs = time.Since(t.submitted).Milliseconds()
+	}
+	f.publish(ev)
+}
+
+// publish рассылает событие; медленный дашборд пропускает события, а не тормозит стрим
+func (f *eventFeed) publish(ev feedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (f *eventFeed) subscribe() (<-chan feedEvent, func()) {
+	ch := make(chan feedEvent, 64)
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}
+}
+
+// handleEvents — GET /events: лента событий заданий в формате Server-Sent Events
+func (f *eventFeed) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	events, unsubscribe := f.subscribe()
+	defer unsubscribe()
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(feedKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case ev := <-events:
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+// handleDashboard — GET /dashboard?n=4: выдает n заданий одновременно и
+// показывает их рядом с живой лентой результатов и временами каждого задания
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	n := dashboardDefault
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > dashboardMax {
+			http.Error(w, fmt.Sprintf("n must be between 1 and %d", dashboardMax), http.StatusBadRequest)
+			return
+		}
+	}
+	type card struct {
+		ID    string
+		Error string
+	}
+	cards := make([]card, n)
+	var wg sync.WaitGroup
+	for i := range cards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.issue(r)
+			if err != nil {
+				log.Printf("Error from NewChallenge: %v", err)
+				cards[i].Error = err.Error()
+				return
+			}
+			cards[i].ID = res.GetChallengeId()
+		}()
+	}
+	wg.Wait()
+	w.Header().Set("Content-Type", "text/html")
+	s.dashboard.Execute(w, map[string]any{
+		"Cards":        cards,
+		"N":            n,
+		"WidgetOrigin": s.widgetOrigin(r),
+	})
+}
+
+// handleRefresh — POST /refresh: новое задание вместо истекающего; дашборд
+// подставляет его в тот же iframe
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	res, err := s.issue(r)
+	if err != nil {
+		log.Printf("Error from NewChallenge: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"challengeId": res.GetChallengeId()})
+}
+
+// dashboardTemplate — несколько виджетов сразу и таблица с живыми результатами.
+// Решения идут через /solve, результаты приходят из /events, а не из ответа /solve.
+const dashboardTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Captcha Test Dashboard</title>
+    <style>
+        body { font-family: sans-serif; margin: 20px; }
+        .grid { display: flex; flex-wrap: wrap; gap: 12px; }
+        .card { border: 1px solid #ccc; padding: 8px; }
+        .card h3 { font-size: 12px; font-family: monospace; margin: 0 0 6px; }
+        iframe { border: 0; width: 420px; height: 420px; }
+        table { border-collapse: collapse; margin-top: 16px; }
+        td, th { border: 1px solid #ddd; padding: 4px 8px; font-family: monospace; font-size: 12px; }
+        .result-ok { color: green; } .result-fail { color: red; } .control { color: #b60; }
+    </style>
+</head>
+<body>
+    <h1>Captcha Test Dashboard</h1>
+    <form method="get">
+        <label>Challenges: <input type="number" name="n" min="1" max="16" value="{{.N}}"></label>
+        <button type="submit">Issue</button>
+        <span id="feed-state">connecting…</span>
+    </form>
+    <div class="grid">
+    {{range .Cards}}
+        <div class="card">
+            {{if .ID}}
+            <h3>{{.ID}}</h3>
+            <iframe data-challenge="{{.ID}}" src="{{$.WidgetOrigin}}/challenge/{{.ID}}"></iframe>
+            {{else}}
+            <h3>failed to issue: {{.Error}}</h3>
+            {{end}}
+        </div>
+    {{end}}
+    </div>
+    <table>
+        <thead><tr><th>challenge</th><th>status</th><th>issue ms</th><th>solve ms</th><th>verify ms</th><th>confidence</th></tr></thead>
+        <tbody id="rows"></tbody>
+    </table>
+
+    <script>
+        const widgetOrigin = "{{.WidgetOrigin}}";
+        const rows = {};
+        function row(id) {
+            if (!rows[id]) {
+                const tr = document.createElement("tr");
+                tr.innerHTML = "<td>" + id + "</td><td></td><td></td><td></td><td></td><td></td>";
+                document.getElementById("rows").appendChild(tr);
+                rows[id] = tr.children;
+            }
+            return rows[id];
+        }
+
+        // Живая лента: события всех выданных заданий, в том числе из других вкладок
+        const feed = new EventSource("/events");
+        feed.onopen = () => document.getElementById("feed-state").innerText = "live";
+        feed.onerror = () => document.getElementById("feed-state").innerText = "reconnecting…";
+        feed.onmessage = (m) => {
+            const ev = JSON.parse(m.data);
+            const cells = row(ev.challenge_id);
+            if (ev.issue_ms) cells[2].innerText = ev.issue_ms;
+            if (ev.solve_ms) cells[3].innerText = ev.solve_ms;
+            if (ev.verify_ms) cells[4].innerText = ev.verify_ms;
+            if (ev.type === "result") {
+                cells[1].innerText = ev.confidence > 0 ? "solved" : "failed";
+                cells[1].className = ev.confidence > 0 ? "result-ok" : "result-fail";
+                cells[5].innerText = ev.confidence + "%";
+            } else if (ev.type === "control") {
+                cells[1].innerText = ev.kind + (ev.message ? ": " + ev.message : "");
+                cells[1].className = "control";
+            } else {
+                cells[1].innerText = ev.type;
+            }
+        };
+
+        // Решение приходит из iframe: задание определяем по окну-отправителю
+        window.addEventListener("message", (e) => {
+            if (e.origin !== widgetOrigin) {
+                return;
+            }
+            const frame = [...document.querySelectorAll("iframe")].find(f => f.contentWindow === e.source);
+            if (!frame) {
+                return;
+            }
+            // Задание скоро истечет: подставляем новое в тот же iframe
+            if (e.data?.type === "captcha:refresh") {
+                const old = frame.dataset.challenge;
+                fetch("/refresh", { method: 'POST' }).then(res => res.json()).then(data => {
+                    const cells = row(old);
+                    cells[1].innerText = "refreshed";
+                    frame.dataset.challenge = data.challengeId;
+                    frame.previousElementSibling.innerText = data.challengeId;
+                    frame.src = widgetOrigin + "/challenge/" + data.challengeId;
+                });
+                return;
+            }
+            if (e.data?.type === "captcha:calibrate") {
+                fetch("/calibrate", {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ challengeId: frame.dataset.challenge, width: e.data.width, height: e.data.height, dpr: e.data.dpr })
+                });
+                return;
+            }
+            if (e.data?.type !== "captcha:sendData") {
+                return;
+            }
+            fetch("/solve", {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    challengeId: frame.dataset.challenge,
+                    solution: e.data.data,
+                    fingerprint: e.data.fingerprint
+                })
+            });
+        });
+    </script>
+</body>
+</html>
+`
@@ -0,0 +1,372 @@
+// Package testclient — тестовая страница, которая встраивает виджет капчи в
+// iframe и проксирует решение в gRPC-стрим сервиса. Ее поднимают
+// cmd/test_client и режим captcha dev.
+package testclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+	"captcha-service/internal/answer"
+	_ "captcha-service/internal/grpczstd" // клиент объявляет zstd в grpc-accept-encoding
+	"captcha-service/internal/httpcompress"
+
+	"github.com/patrickmn/go-cache"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip" // и gzip
+	"google.golang.org/grpc/status"
+)
+
+// parentTemplate - это HTML-страница, которая будет хостить нашу капчу в iframe
+const parentTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Captcha Test Page</title>
+    <style>
+        body { font-family: sans-serif; display: flex; flex-direction: column; align-items: center; padding-top: 50px; }
+        iframe { border: 1px solid #ccc; }
+    </style>
+</head>
+<body>
+    <h1>Test Harness for Captcha Service</h1>
+    <p>The captcha below is loaded from our gRPC service and displayed in an iframe served from {{.WidgetOrigin}}.</p>
+    <iframe id="captcha-frame" src="{{.WidgetOrigin}}/challenge/{{.ChallengeID}}" width="420" height="420"></iframe>
+    <h2 id="result"></h2>
+
+    <script>
+        const resultEl = document.getElementById('result');
+        const challengeId = "{{.ChallengeID}}";
+        const widgetOrigin = "{{.WidgetOrigin}}";
+
+        // Слушаем сообщения из iframe; как и настоящая интеграция, принимаем их
+        // только от origin виджета
+        window.addEventListener("message", (e) => {
+            if (e.origin !== widgetOrigin) {
+                console.warn("Ignoring message from unexpected origin", e.origin);
+                return;
+            }
+            // Задание скоро истечет: берем новое, как при REFRESH от сервера
+            if (e.data?.type === "captcha:refresh") {
+                location.reload();
+                return;
+            }
+            // Калибровка приходит до ответа и пересылается в стрим как есть
+            if (e.data?.type === "captcha:calibrate") {
+                fetch("/calibrate", {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ challengeId: challengeId, width: e.data.width, height: e.data.height, dpr: e.data.dpr })
+                });
+                return;
+            }
+            if (e.data?.type === "captcha:sendData") {
+                console.log("Received data from iframe:", e.data.data);
+                resultEl.innerText = "Checking solution...";
+
+                // Отправляем решение на наш тестовый сервер, который проксирует его в gRPC
+                fetch("/solve", {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({
+                        challengeId: challengeId,
+                        solution: e.data.data,
+                        fingerprint: e.data.fingerprint
+                    })
+                }).then(res => res.json()).then(data => {
+                    console.log("Received result from server:", data);
+                    if (data.success) {
+                        resultEl.innerText = "SUCCESS! Confidence: " + data.confidence + "%";
+                        resultEl.style.color = 'green';
+                    } else {
+                        resultEl.innerText = "FAILED. Please reload and try again.";
+                        resultEl.style.color = 'red';
+                    }
+                });
+            }
+        });
+    </script>
+</body>
+</html>
+`
+
+// gRPCClient управляет соединением и стримом
+type gRPCClient struct {
+	conn   *grpc.ClientConn
+	client captchapb.CaptchaServiceClient
+	stream captchapb.CaptchaService_MakeEventStreamClient
+	mu     sync.Mutex
+	// onEvent получает каждое событие стрима (для живой ленты дашборда)
+	onEvent func(*captchapb.ServerEvent)
+}
+
+func (c *gRPCClient) init(addr string) error {
+	var err error
+	// Устанавливаем соединение
+	c.conn, err = grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("did not connect to captcha service: %w", err)
+	}
+	c.client = captchapb.NewCaptchaServiceClient(c.conn)
+
+	// Открываем стрим
+	c.stream, err = c.client.MakeEventStream(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to open event stream: %w", err)
+	}
+
+	// В фоне слушаем ответы от сервера (результаты проверки)
+	go func() {
+		for {
+			res, err := c.stream.Recv()
+			if err == io.EOF {
+				log.Println("gRPC stream closed by server")
+				return
+			}
+			if err != nil {
+				log.Printf("Error receiving from gRPC stream: %v", err)
+				return
+			}
+			if c.onEvent != nil {
+				c.onEvent(res)
+			}
+			if ctrl := res.GetControl(); ctrl != nil {
+				log.Printf("Received control message from captcha service: Kind=%s, ChallengeID=%s, Message=%q, RetryAfter=%ds",
+					ctrl.GetKind(), ctrl.GetChallengeId(), ctrl.GetMessage(), ctrl.GetRetryAfterSeconds())
+				continue
+			}
+			log.Printf("Received async result from captcha service: ChallengeID=%s, Confidence=%d, Passed=%t",
+				res.GetResult().GetChallengeId(), res.GetResult().GetConfidencePercent(), res.GetResult().GetPassed())
+		}
+	}()
+
+	return nil
+}
+
+// tooManyChallenges возвращает сообщение для пользователя, если сервис отказал
+// в задании из-за лимита частоты (причина TOO_MANY_CHALLENGES)
+func tooManyChallenges(err error) (string, bool) {
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return "", false
+	}
+	limited := false
+	msg := st.Message()
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			limited = d.GetReason() == "TOO_MANY_CHALLENGES"
+		case *errdetails.LocalizedMessage:
+			msg = d.GetMessage()
+		}
+	}
+	return msg, limited
+}
+
+// challengeTTL — сколько HTML задания доступен по /challenge/{id}
+const challengeTTL = 5 * time.Minute
+
+// widgetVersion — версия тестовой страницы как загрузчика виджета
+const widgetVersion = 1
+
+// Server — тестовая страница поверх соединения с сервисом: инстансом или балансером
+type Server struct {
+	// WidgetOrigin — origin, с которого iframe загружает виджет ("http://127.0.0.1:8080").
+	// Пустой — origin страницы с заменой localhost на 127.0.0.1 и обратно: так
+	// виджет всегда живет в другом origin, как у настоящих интеграций.
+	WidgetOrigin string
+
+	client     *gRPCClient
+	tmpl       *template.Template
+	dashboard  *template.Template
+	challenges *cache.Cache
+	feed       *eventFeed
+}
+
+// New подключается к сервису по addr и открывает стрим событий
+func New(addr string) (*Server, error) {
+	s := &Server{
+		tmpl:       template.Must(template.New("").Parse(parentTemplate)),
+		dashboard:  template.Must(template.New("").Parse(dashboardTemplate)),
+		challenges: cache.New(challengeTTL, challengeTTL),
+		feed:       newEventFeed(),
+	}
+	s.client = &gRPCClient{onEvent: s.feed.fromService}
+	if err := s.client.init(addr); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// issue запрашивает задание для клиента запроса r и запоминает его HTML для
+// /challenge/{id} и время выдачи для дашборда
+func (s *Server) issue(r *http.Request) (*captchapb.ChallengeResponse, error) {
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	start := time.Now()
+	res, err := s.client.client.NewChallenge(context.Background(), &captchapb.ChallengeRequest{
+		Complexity: 50,
+		Client: &captchapb.ClientContext{
+			Ip:        host,
+			UserAgent: r.UserAgent(),
+			Hostname:  r.Host,
+			// Страница пересылает data как есть, поэтому понимает любую схему ответа
+			Capabilities: &captchapb.WidgetCapabilities{WidgetVersion: widgetVersion, AnswerSchemas: answer.Schemas},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.challenges.SetDefault(res.ChallengeId, res.Html)
+	s.feed.issued(res.ChallengeId, time.Since(start))
+	return res, nil
+}
+
+// widgetOrigin — origin iframe для страницы, открытой по r
+func (s *Server) widgetOrigin(r *http.Request) string {
+	if s.WidgetOrigin != "" {
+		return s.WidgetOrigin
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, ""
+	}
+	switch host {
+	case "localhost":
+		host = "127.0.0.1"
+	case "127.0.0.1":
+		host = "localhost"
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	return "http://" + host
+}
+
+// Close закрывает соединение с сервисом
+func (s *Server) Close() error {
+	return s.client.conn.Close()
+}
+
+// Handler — страница с капчей ("/"), дашборд нескольких заданий ("/dashboard")
+// с живой лентой результатов ("/events", SSE), HTML задания для iframe
+// ("/challenge/{id}") и прием решения ("/solve")
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /dashboard", httpcompress.Handler(http.HandlerFunc(s.handleDashboard)))
+	mux.HandleFunc("GET /events", s.feed.handleEvents)
+	mux.HandleFunc("POST /refresh", s.handleRefresh)
+
+	// HTTP-хендлер для главной страницы; HTML капчи весит мегабайты, поэтому сжимаем ответ
+	mux.Handle("/", httpcompress.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 1. Запрашиваем новую капчу у сервиса
+		res, err := s.issue(r)
+		if msg, ok := tooManyChallenges(err); ok {
+			http.Error(w, msg, http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get challenge from service", http.StatusInternalServerError)
+			log.Printf("Error from NewChallenge: %v", err)
+			return
+		}
+
+		// 2. Рендерим страницу-обертку с iframe; сам виджет iframe загрузит по URL
+		data := map[string]interface{}{
+			"ChallengeID":  res.ChallengeId,
+			"WidgetOrigin": s.widgetOrigin(r),
+		}
+		w.Header().Set("Content-Type", "text/html")
+		s.tmpl.Execute(w, data)
+	})))
+
+	// HTML задания отдается отдельным документом, как виджет у настоящих интеграций
+	mux.Handle("GET /challenge/{id}", httpcompress.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		html, ok := s.challenges.Get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "challenge not found or expired", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, html.(string))
+	})))
+
+	// HTTP-хендлер для приема решения от фронтенда
+	mux.HandleFunc("/solve", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ChallengeID string `json:"challengeId"`
+			Solution    string `json:"solution"`
+			Fingerprint string `json:"fingerprint"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 3. Отправляем решение в gRPC-стрим
+		s.feed.submitted(req.ChallengeID)
+		s.client.mu.Lock()
+		defer s.client.mu.Unlock()
+		err := s.client.stream.Send(&captchapb.ClientEvent{
+			EventType:   captchapb.ClientEvent_FRONTEND_EVENT,
+			ChallengeId: req.ChallengeID,
+			Data:        []byte(req.Solution),
+			Fingerprint: req.Fingerprint,
+		})
+		if err != nil {
+			http.Error(w, "Failed to send solution via gRPC", http.StatusInternalServerError)
+			log.Printf("Error sending to gRPC stream: %v", err)
+			return
+		}
+
+		// В реальной системе ответ придет асинхронно. Здесь для простоты мы просто говорим "ок"
+		// А результат смотрим в логах
+		w.Header().Set("Content-Type", "application/json")
+		// Это заглушка, т.к. реальный ответ приходит в горутине-слушателе
+		// Для теста этого достаточно.
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "confidence": "check_logs"})
+	})
+
+	// Калибровка виджета: размер отрисованного задания до ответа
+	mux.HandleFunc("POST /calibrate", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ChallengeID string  `json:"challengeId"`
+			Width       float64 `json:"width"`
+			Height      float64 `json:"height"`
+			DPR         float64 `json:"dpr"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.client.mu.Lock()
+		defer s.client.mu.Unlock()
+		err := s.client.stream.Send(&captchapb.ClientEvent{
+			EventType:   captchapb.ClientEvent_CALIBRATION,
+			ChallengeId: req.ChallengeID,
+			Calibration: &captchapb.Calibration{
+				RenderedWidth:    req.Width,
+				RenderedHeight:   req.Height,
+				DevicePixelRatio: req.DPR,
+			},
+		})
+		if err != nil {
+			http.Error(w, "Failed to send calibration via gRPC", http.StatusInternalServerError)
+			log.Printf("Error sending calibration to gRPC stream: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
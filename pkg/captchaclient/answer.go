@@ -0,0 +1,60 @@
+package captchaclient
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"captcha-service/internal/answer"
+)
+
+// Answer — решение задания в формате виджета (см. описание пакета internal/answer)
+type Answer interface {
+	Payload() string
+}
+
+// Slider — ответ слайдера: X левого края пазла в пикселях изображения
+type Slider struct {
+	X float64
+}
+
+func (a Slider) Payload() string {
+	return strconv.FormatFloat(a.X, 'f', -1, 64)
+}
+
+// Rotate — ответ пазла с вращением: X и угол по часовой стрелке в градусах
+type Rotate struct {
+	X     float64
+	Angle float64
+}
+
+func (a Rotate) Payload() string {
+	return fmt.Sprintf("%s,%s", strconv.FormatFloat(a.X, 'f', -1, 64), strconv.FormatFloat(a.Angle, 'f', -1, 64))
+}
+
+// Multi — ответ многопазлового задания: X по ID фрагмента (data-piece)
+type Multi map[string]float64
+
+func (a Multi) Payload() string {
+	parts := make([]string, 0, len(a))
+	for _, id := range slices.Sorted(maps.Keys(a)) {
+		parts = append(parts, id+":"+strconv.FormatFloat(a[id], 'f', -1, 64))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Encode упаковывает ответ в ClientEvent.data схемы schema (Negotiated.answer_schema);
+// в SchemaText отпечаток передается отдельно полем ClientEvent.fingerprint.
+// Для SchemaSealed нужен key — ChallengeResponse.answer_key задания.
+func Encode(schema uint32, key []byte, a Answer, fingerprint string) ([]byte, error) {
+	return answer.Wrap(schema, key, a.Payload(), fingerprint)
+}
+
+// Схемы ответа, которые понимает сервер
+const (
+	SchemaText     = answer.SchemaText
+	SchemaEnvelope = answer.SchemaEnvelope
+	SchemaSealed   = answer.SchemaSealed
+)
@@ -0,0 +1,197 @@
+// Package captchaclient — клиент CaptchaService для бэкендов сайтов с
+// настройками по умолчанию: дедлайн на каждый вызов, прозрачные повторы
+// при UNAVAILABLE и, по желанию, хеджирование NewChallenge на второй адрес.
+//
+//	c, err := captchaclient.Dial("balancer:50051", captchaclient.Options{})
+//	challenge, err := c.NewChallenge(ctx, &captchapb.ChallengeRequest{SiteKey: key, Action: "login"})
+//	...
+//	res, err := c.Assess(ctx, &captchapb.AssessRequest{Token: token, SiteKey: key, Action: "login"})
+package captchaclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	captchapb "captcha-service/api/captcha/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// DefaultTimeout — дедлайн вызова, если у контекста его нет
+	DefaultTimeout = 5 * time.Second
+	// DefaultMaxAttempts — попыток вызова с учетом первой при UNAVAILABLE
+	DefaultMaxAttempts = 4
+	// DefaultHedgeDelay — через сколько без ответа NewChallenge уходит на HedgeAddr
+	DefaultHedgeDelay = 150 * time.Millisecond
+)
+
+// Options — настройки клиента; нулевые поля получают значения по умолчанию
+type Options struct {
+	// Timeout — дедлайн одного вызова, если у контекста вызова его нет
+	Timeout time.Duration
+	// MaxAttempts — попыток унарного вызова при UNAVAILABLE (2-5); 1 отключает повторы
+	MaxAttempts int
+	// HedgeAddr — второй балансер или инстанс для хеджирования NewChallenge;
+	// пусто — без хеджирования
+	HedgeAddr string
+	// HedgeDelay — задержка перед хеджированным запросом
+	HedgeDelay time.Duration
+	// DialOptions добавляются к опциям по умолчанию (insecure-транспорт, повторы)
+	DialOptions []grpc.DialOption
+}
+
+// Client — обертка над CaptchaServiceClient. Все методы, кроме NewChallenge,
+// идут на основной адрес; для остальных RPC есть Raw.
+type Client struct {
+	conn   *grpc.ClientConn
+	hedge  *grpc.ClientConn
+	client captchapb.CaptchaServiceClient
+	// hedgeClient — nil без HedgeAddr
+	hedgeClient captchapb.CaptchaServiceClient
+	opts        Options
+}
+
+// Dial подключается к балансеру или инстансу по addr
+func Dial(addr string, opts Options) (*Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.HedgeDelay <= 0 {
+		opts.HedgeDelay = DefaultHedgeDelay
+	}
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(serviceConfig(opts.MaxAttempts)),
+	}, opts.DialOptions...)
+
+	c := &Client{opts: opts}
+	var err error
+	if c.conn, err = grpc.NewClient(addr, dialOpts...); err != nil {
+		return nil, fmt.Errorf("failed to connect to captcha service at %s: %w", addr, err)
+	}
+	c.client = captchapb.NewCaptchaServiceClient(c.conn)
+	if opts.HedgeAddr != "" {
+		if c.hedge, err = grpc.NewClient(opts.HedgeAddr, dialOpts...); err != nil {
+			c.conn.Close()
+			return nil, fmt.Errorf("failed to connect to captcha service at %s: %w", opts.HedgeAddr, err)
+		}
+		c.hedgeClient = captchapb.NewCaptchaServiceClient(c.hedge)
+	}
+	return c, nil
+}
+
+// serviceConfig — повторы унарных вызовов при UNAVAILABLE: запрос не дошел
+// до инстанса или инстанс уходил на перезапуск. ForwardSolution не
+// повторяется: решение проверяется один раз.
+func serviceConfig(attempts int) string {
+	if attempts < 2 {
+		return `{}`
+	}
+	return fmt.Sprintf(`{"methodConfig": [{
+		"name": [
+			{"service": "captcha.v1.CaptchaService", "method": "NewChallenge"},
+			{"service": "captcha.v1.CaptchaService", "method": "PrewarmChallenge"},
+			{"service": "captcha.v1.CaptchaService", "method": "GetChallenge"},
+			{"service": "captcha.v1.CaptchaService", "method": "GetChallengeResult"},
+			{"service": "captcha.v1.CaptchaService", "method": "Assess"}
+		],
+		"retryPolicy": {
+			"maxAttempts": %d,
+			"initialBackoff": "0.05s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]}`, min(attempts, 5))
+}
+
+// Raw — сгенерированный клиент основного адреса для RPC без обертки
+// (MakeEventStream, GetChallengeAssets и т.д.)
+func (c *Client) Raw() captchapb.CaptchaServiceClient {
+	return c.client
+}
+
+// withTimeout добавляет дедлайн Options.Timeout, если у ctx его нет
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.opts.Timeout)
+}
+
+// NewChallenge выдает задание. С HedgeAddr запрос, не получивший ответа за
+// HedgeDelay (или упавший), повторяется на втором адресе, и побеждает первый
+// ответ; задание проигравшего остается невостребованным и истекает само.
+func (c *Client) NewChallenge(ctx context.Context, req *captchapb.ChallengeRequest) (*captchapb.ChallengeResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	if c.hedgeClient == nil {
+		return c.client.NewChallenge(ctx, req)
+	}
+
+	type result struct {
+		res *captchapb.ChallengeResponse
+		err error
+	}
+	// Отмена ctx прерывает и проигравший запрос
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	results := make(chan result, 2)
+	call := func(client captchapb.CaptchaServiceClient) {
+		res, err := client.NewChallenge(ctx, req)
+		results <- result{res, err}
+	}
+
+	go call(c.client)
+	timer := time.NewTimer(c.opts.HedgeDelay)
+	defer timer.Stop()
+	pending, hedged := 1, false
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.res, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+		}
+		if !hedged {
+			hedged = true
+			pending++
+			go call(c.hedgeClient)
+		}
+	}
+	return nil, firstErr
+}
+
+// Assess проверяет токен пройденного задания
+func (c *Client) Assess(ctx context.Context, req *captchapb.AssessRequest) (*captchapb.AssessResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Assess(ctx, req)
+}
+
+// GetChallengeResult возвращает результат задания для ожидающего бэкенда
+func (c *Client) GetChallengeResult(ctx context.Context, req *captchapb.ChallengeResultRequest) (*captchapb.ChallengeResultResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.GetChallengeResult(ctx, req)
+}
+
+// Close закрывает соединения
+func (c *Client) Close() error {
+	if c.hedge != nil {
+		c.hedge.Close()
+	}
+	return c.conn.Close()
+}
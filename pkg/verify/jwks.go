@@ -0,0 +1,114 @@
+package verify
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minRefetch — как часто незнакомый kid может вызывать перезапрос JWKS:
+// токены с мусорным kid не должны превращаться в поток запросов к сервису
+const minRefetch = 30 * time.Second
+
+// keySet — закэшированный JWKS одного адреса. fetching — идущий запрос JWKS:
+// канал закрывается по его окончании.
+type keySet struct {
+	mu       sync.Mutex
+	keys     map[string]ed25519.PublicKey
+	fetched  time.Time
+	fetching chan struct{}
+}
+
+// keySets — кэш JWKS по адресу, общий для всех вызовов Verify
+var keySets sync.Map
+
+// jwksKey возвращает ключ kid из JWKS по url. Набор перезапрашивается, когда
+// он старше ttl или в нем нет kid (ротация ключа), но не чаще minRefetch.
+// Запрос идет без блокировки набора и один на адрес: пока он идет, известные
+// ключи выдаются из кэша, а незнакомый kid ждет его окончания.
+func jwksKey(ctx context.Context, client *http.Client, url string, ttl time.Duration, kid string) (ed25519.PublicKey, error) {
+	v, _ := keySets.LoadOrStore(url, &keySet{})
+	set := v.(*keySet)
+	for {
+		set.mu.Lock()
+		key, known := set.keys[kid]
+		age := time.Since(set.fetched)
+		switch {
+		case known && (age < ttl || set.fetching != nil):
+			set.mu.Unlock()
+			return key, nil
+		case !known && set.keys != nil && age < minRefetch:
+			set.mu.Unlock()
+			return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+		case set.fetching != nil:
+			wait := set.fetching
+			set.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		set.fetching = done
+		set.mu.Unlock()
+
+		keys, err := fetchJWKS(ctx, client, url)
+		set.mu.Lock()
+		set.fetching = nil
+		if err == nil {
+			set.keys, set.fetched = keys, time.Now()
+		}
+		set.mu.Unlock()
+		close(done)
+
+		if err != nil {
+			if known {
+				// Сервис недоступен, но ключ уже известен: офлайн-путь продолжает работать
+				return key, nil
+			}
+			return nil, err
+		}
+		if key, known = keys[kid]; !known {
+			return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+		}
+		return key, nil
+	}
+}
+
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (map[string]ed25519.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[k.Kid] = ed25519.PublicKey(x)
+	}
+	return keys, nil
+}
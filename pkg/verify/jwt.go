@@ -0,0 +1,118 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Issuer — iss в JWT результата
+const Issuer = "captcha-service"
+
+// Claims — содержимое JWT результата задания
+type Claims struct {
+	Issuer      string `json:"iss"`
+	ChallengeID string `json:"sub"`
+	// SiteKey — тенант задания; пустой у заданий без site_key
+	SiteKey  string `json:"aud,omitempty"`
+	Action   string `json:"act"`
+	Hostname string `json:"hostname,omitempty"`
+	// Region — регион инстанса, проверившего решение; пусто — не задан
+	Region string `json:"region,omitempty"`
+	// Confidence и Threshold — уверенность решения и порог политики действия
+	// на момент проверки: офлайн актуальный порог узнать неоткуда
+	Confidence int32 `json:"conf"`
+	Threshold  int32 `json:"thr"`
+	IssuedAt   int64 `json:"iat"`
+	ExpiresAt  int64 `json:"exp"`
+}
+
+// JWK — публичный ключ Ed25519 в формате RFC 8037
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS — ответ /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyID — kid ключа: первые байты SHA-256 публичного ключа; у инстансов
+// с одним RESULT_JWT_KEY он совпадает
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+// PublicJWK описывает публичный ключ для JWKS
+func PublicJWK(pub ed25519.PublicKey) JWK {
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(pub),
+		Kid: KeyID(pub),
+		Alg: "EdDSA",
+		Use: "sig",
+	}
+}
+
+// Sign кодирует claims в JWT, подписанный EdDSA
+func Sign(key ed25519.PrivateKey, c Claims) string {
+	header, _ := json.Marshal(map[string]string{
+		"alg": "EdDSA",
+		"typ": "JWT",
+		"kid": KeyID(key.Public().(ed25519.PublicKey)),
+	})
+	payload, _ := json.Marshal(c)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+}
+
+// isJWT отличает JWT от одноразового токена "<challenge_id>.<secret>"
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// parseJWT проверяет подпись ключом, который возвращает key по kid, и
+// разбирает claims; срок и содержимое claims проверяет вызывающий
+func parseJWT(token string, key func(kid string) (ed25519.PublicKey, error)) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil || header.Alg != "EdDSA" {
+		return Claims{}, ErrInvalidToken
+	}
+	pub, err := key(header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return Claims{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Issuer != Issuer {
+		return Claims{}, ErrInvalidToken
+	}
+	return c, nil
+}
@@ -0,0 +1,239 @@
+// Package verify проверяет результат капчи на бэкенде сайта. Виджет передает
+// бэкенду токен из ChallengeResult, и Verify принимает оба его вида.
+//
+// JWT (ChallengeResult.jwt, инстанс с RESULT_JWT_KEY) проверяется офлайн по
+// ключам из /.well-known/jwks.json, которые кэшируются между вызовами. JWT не
+// одноразовый: повтор в пределах exp бэкенд отсекает сам (по ChallengeID).
+//
+// Одноразовый токен (ChallengeResult.token) погашается запросом POST /siteverify
+// к HTTP-серверу инстанса, выдавшего задание.
+//
+//	res, err := verify.Verify(ctx, token, verify.Options{
+//		SiteKey:       "site-1",
+//		Action:        "login",
+//		Hostnames:     []string{"example.com"},
+//		JWKSURL:       "https://captcha.example.com/.well-known/jwks.json",
+//		SiteverifyURL: "https://captcha.example.com/siteverify",
+//	})
+package verify
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultJWKSTTL — как долго кэшируется JWKS
+const DefaultJWKSTTL = 10 * time.Minute
+
+// DefaultClockSkew — допустимое расхождение часов бэкенда и инстанса, выдавшего JWT
+const DefaultClockSkew = 30 * time.Second
+
+var (
+	ErrInvalidToken     = errors.New("captcha token is invalid")
+	ErrExpired          = errors.New("captcha token has expired")
+	ErrSiteMismatch     = errors.New("captcha token was issued for another site")
+	ErrActionMismatch   = errors.New("captcha token was issued for another action")
+	ErrHostnameMismatch = errors.New("captcha was solved on an unexpected hostname")
+	// ErrNotPassed — токен подлинный, но задание не решено или уверенность ниже порога
+	ErrNotPassed = errors.New("captcha was not passed")
+)
+
+// Options — ожидания бэкенда от результата и адреса сервиса
+type Options struct {
+	// SiteKey — site_key сайта. Аудитория JWT сверяется всегда: пустой SiteKey
+	// принимает только JWT заданий без site_key. /siteverify сверяет сайт,
+	// только если SiteKey передан, поэтому сайтам с site_key он обязателен.
+	SiteKey string
+	// Action должно совпадать с действием задания, как в Assess
+	Action string
+	// Hostnames — хосты страниц сайта (ClientContext.hostname); пусто — не проверяется
+	Hostnames []string
+	// MinConfidence — минимальная уверенность поверх порога политики действия
+	MinConfidence int32
+
+	// JWKSURL — адрес /.well-known/jwks.json; без него JWT не принимаются
+	JWKSURL string
+	// JWKSTTL — срок кэша JWKS; 0 — DefaultJWKSTTL
+	JWKSTTL time.Duration
+	// SiteverifyURL — адрес POST /siteverify; без него не принимаются одноразовые токены
+	SiteverifyURL string
+	// HTTPClient — клиент для JWKS и siteverify; nil — http.DefaultClient
+	HTTPClient *http.Client
+	// Now — текущее время для проверки exp; nil — time.Now
+	Now func() time.Time
+	// ClockSkew — допуск к exp и iat JWT на расхождение часов; 0 — DefaultClockSkew,
+	// отрицательный — без допуска
+	ClockSkew time.Duration
+}
+
+// Result — проверенный результат задания
+type Result struct {
+	ChallengeID string
+	SiteKey     string
+	Action      string
+	Hostname    string
+	// Region — регион инстанса, проверившего решение (для анализа задержек)
+	Region     string
+	Confidence int32
+	Threshold  int32
+	// Offline — результат проверен по JWT, без обращения к сервису
+	Offline bool
+}
+
+// SiteverifyResponse — ответ POST /siteverify
+type SiteverifyResponse struct {
+	Success     bool   `json:"success"`
+	Decision    string `json:"decision"`
+	Reason      string `json:"reason"`
+	ChallengeID string `json:"challenge_id"`
+	Action      string `json:"action"`
+	Hostname    string `json:"hostname,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Confidence  int32  `json:"confidence"`
+	Threshold   int32  `json:"threshold"`
+}
+
+// Verify проверяет токен и возвращает результат. Ошибка — одна из Err* этого
+// пакета (через errors.Is) или сбой обращения к сервису.
+func Verify(ctx context.Context, token string, opts Options) (*Result, error) {
+	client := cmp.Or(opts.HTTPClient, http.DefaultClient)
+	var (
+		res *Result
+		err error
+	)
+	if isJWT(token) {
+		res, err = verifyJWT(ctx, client, token, opts)
+	} else {
+		res, err = siteverify(ctx, client, token, opts)
+	}
+	if err != nil {
+		return res, err
+	}
+	if len(opts.Hostnames) > 0 && !slices.ContainsFunc(opts.Hostnames, func(h string) bool {
+		return NormalizeHostname(h) == res.Hostname
+	}) {
+		return res, fmt.Errorf("%w: %q", ErrHostnameMismatch, res.Hostname)
+	}
+	if res.Confidence < opts.MinConfidence {
+		return res, fmt.Errorf("%w: confidence %d%% is below %d%%", ErrNotPassed, res.Confidence, opts.MinConfidence)
+	}
+	return res, nil
+}
+
+func verifyJWT(ctx context.Context, client *http.Client, token string, opts Options) (*Result, error) {
+	if opts.JWKSURL == "" {
+		return nil, errors.New("JWT captcha tokens require Options.JWKSURL")
+	}
+	ttl := cmp.Or(opts.JWKSTTL, DefaultJWKSTTL)
+	c, err := parseJWT(token, func(kid string) (ed25519.PublicKey, error) {
+		return jwksKey(ctx, client, opts.JWKSURL, ttl, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{
+		ChallengeID: c.ChallengeID,
+		SiteKey:     c.SiteKey,
+		Action:      c.Action,
+		Hostname:    c.Hostname,
+		Region:      c.Region,
+		Confidence:  c.Confidence,
+		Threshold:   c.Threshold,
+		Offline:     true,
+	}
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	skew := cmp.Or(opts.ClockSkew, DefaultClockSkew)
+	if skew < 0 {
+		skew = 0
+	}
+	t := now()
+	switch {
+	case t.Add(-skew).Unix() >= c.ExpiresAt:
+		return res, ErrExpired
+	case t.Add(skew).Unix() < c.IssuedAt:
+		// Токен из будущего: часы инстанса убежали дальше допуска
+		return res, fmt.Errorf("%w: issued at %d, in the future", ErrInvalidToken, c.IssuedAt)
+	case c.SiteKey != opts.SiteKey:
+		return res, ErrSiteMismatch
+	case c.Action != opts.Action:
+		return res, fmt.Errorf("%w: expected %q, token has %q", ErrActionMismatch, opts.Action, c.Action)
+	case c.Confidence == 0:
+		return res, fmt.Errorf("%w: challenge was not solved", ErrNotPassed)
+	case c.Confidence < c.Threshold:
+		return res, fmt.Errorf("%w: confidence is below action threshold", ErrNotPassed)
+	}
+	return res, nil
+}
+
+func siteverify(ctx context.Context, client *http.Client, token string, opts Options) (*Result, error) {
+	if opts.SiteverifyURL == "" {
+		return nil, errors.New("one-time captcha tokens require Options.SiteverifyURL")
+	}
+	form := url.Values{"token": {token}, "site_key": {opts.SiteKey}, "action": {opts.Action}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.SiteverifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("siteverify failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("siteverify failed: %s", resp.Status)
+	}
+	var sv SiteverifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sv); err != nil {
+		return nil, fmt.Errorf("siteverify failed: invalid response: %w", err)
+	}
+	res := &Result{
+		ChallengeID: sv.ChallengeID,
+		SiteKey:     opts.SiteKey,
+		Action:      sv.Action,
+		Hostname:    sv.Hostname,
+		Region:      sv.Region,
+		Confidence:  sv.Confidence,
+		Threshold:   sv.Threshold,
+	}
+	if !sv.Success {
+		return res, fmt.Errorf("%w: %s (%s)", siteverifyError(sv.Reason), sv.Decision, sv.Reason)
+	}
+	return res, nil
+}
+
+// siteverifyError сопоставляет причину отказа Assess с ошибкой пакета
+func siteverifyError(reason string) error {
+	switch {
+	case strings.HasPrefix(reason, "action mismatch"):
+		return ErrActionMismatch
+	case strings.Contains(reason, "another site"):
+		return ErrSiteMismatch
+	case strings.Contains(reason, "invalid, expired or already used"):
+		return ErrInvalidToken
+	}
+	return ErrNotPassed
+}
+
+// NormalizeHostname приводит хост к виду, в котором он хранится в результате:
+// нижний регистр, без порта и завершающей точки
+func NormalizeHostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,176 @@
+package verify
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKey — ключ инстанса, опубликованный в JWKS тестового сервера
+func testKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// jwksServer отдает JWKS с публичными ключами keys
+func jwksServer(t *testing.T, keys ...ed25519.PrivateKey) *httptest.Server {
+	t.Helper()
+	var set JWKS
+	for _, key := range keys {
+		set.Keys = append(set.Keys, PublicJWK(key.Public().(ed25519.PublicKey)))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// validClaims — решенное задание сайта site-1, выданное минуту назад
+func validClaims(now time.Time) Claims {
+	return Claims{
+		Issuer:      Issuer,
+		ChallengeID: "challenge-1",
+		SiteKey:     "site-1",
+		Action:      "login",
+		Hostname:    "example.com",
+		Confidence:  90,
+		Threshold:   50,
+		IssuedAt:    now.Add(-time.Minute).Unix(),
+		ExpiresAt:   now.Add(time.Minute).Unix(),
+	}
+}
+
+// resign меняет заголовок JWT и подписывает его заново ключом key
+func resign(key ed25519.PrivateKey, header map[string]string, c Claims) string {
+	rawHeader, _ := json.Marshal(header)
+	payload, _ := json.Marshal(c)
+	signed := base64.RawURLEncoding.EncodeToString(rawHeader) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	key, other := testKey(t), testKey(t)
+	srv := jwksServer(t, key)
+	now := time.Now()
+	kid := KeyID(key.Public().(ed25519.PublicKey))
+
+	tampered := func() string {
+		parts := strings.Split(Sign(key, validClaims(now)), ".")
+		c := validClaims(now)
+		c.Confidence = 100
+		payload, _ := json.Marshal(c)
+		parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+		return strings.Join(parts, ".")
+	}
+
+	for _, tc := range []struct {
+		name    string
+		token   string
+		siteKey string
+		want    error
+	}{
+		{"valid", Sign(key, validClaims(now)), "site-1", nil},
+		{"expired", Sign(key, func() Claims {
+			c := validClaims(now)
+			c.IssuedAt, c.ExpiresAt = now.Add(-time.Hour).Unix(), now.Add(-time.Minute).Unix()
+			return c
+		}()), "site-1", ErrExpired},
+		{"bad kid", Sign(other, validClaims(now)), "site-1", ErrInvalidToken},
+		{"wrong alg", resign(key, map[string]string{"alg": "HS256", "typ": "JWT", "kid": kid}, validClaims(now)), "site-1", ErrInvalidToken},
+		{"no alg", resign(key, map[string]string{"typ": "JWT", "kid": kid}, validClaims(now)), "site-1", ErrInvalidToken},
+		{"tampered payload", tampered(), "site-1", ErrInvalidToken},
+		{"wrong audience", Sign(key, validClaims(now)), "site-2", ErrSiteMismatch},
+		{"missing audience", Sign(key, validClaims(now)), "", ErrSiteMismatch},
+		{"not passed", Sign(key, func() Claims {
+			c := validClaims(now)
+			c.Confidence = 0
+			return c
+		}()), "site-1", ErrNotPassed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Verify(context.Background(), tc.token, Options{
+				SiteKey:   tc.siteKey,
+				Action:    "login",
+				Hostnames: []string{"example.com"},
+				JWKSURL:   srv.URL,
+				Now:       func() time.Time { return now },
+			})
+			if !errors.Is(err, tc.want) || (tc.want == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			if err == nil && (!res.Offline || res.ChallengeID != "challenge-1") {
+				t.Fatalf("unexpected result %+v", res)
+			}
+		})
+	}
+}
+
+// Ошибка HTTP siteverify — сбой обращения, а не ответ сервиса
+func TestSiteverifyStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(SiteverifyResponse{Success: true, Confidence: 100})
+	}))
+	defer srv.Close()
+	res, err := Verify(context.Background(), "challenge-1.secret", Options{SiteKey: "site-1", Action: "login", SiteverifyURL: srv.URL})
+	if err == nil || res != nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("res = %+v, err = %v, want a 502 failure", res, err)
+	}
+}
+
+// Пока JWKS перезапрашивается, известный ключ выдается из кэша без ожидания
+func TestJWKSRefetchDoesNotBlockKnownKeys(t *testing.T) {
+	key := testKey(t)
+	pub := PublicJWK(key.Public().(ed25519.PublicKey))
+	release := make(chan struct{})
+	requests := make(chan struct{}, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests <- struct{}{}
+		if len(requests) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{pub}})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx := context.Background()
+	if _, err := jwksKey(ctx, srv.Client(), srv.URL, time.Hour, pub.Kid); err != nil {
+		t.Fatal(err)
+	}
+	// Незнакомый kid после minRefetch запускает перезапрос, который висит
+	v, _ := keySets.Load(srv.URL)
+	set := v.(*keySet)
+	set.mu.Lock()
+	set.fetched = time.Now().Add(-minRefetch)
+	set.mu.Unlock()
+	go jwksKey(ctx, srv.Client(), srv.URL, time.Hour, "rotated")
+	<-requests
+	<-requests
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jwksKey(ctx, srv.Client(), srv.URL, time.Hour, pub.Kid)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("known key lookup blocked behind the JWKS refetch")
+	}
+}